@@ -0,0 +1,317 @@
+package gomall
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"` // 注销前需再次确认密码
+}
+
+// UserDataExport 用户数据导出内容
+type UserDataExport struct {
+	Profile       User           `json:"profile"`
+	Roles         []string       `json:"roles"`
+	Orders        []Order        `json:"orders"`
+	Addresses     []string       `json:"addresses"` // 历史订单中使用过的收货地址
+	CartItems     []CartItem     `json:"cart_items"`
+	Favorites     []Favorite     `json:"favorites"`
+	SavedSearches []SavedSearch  `json:"saved_searches"`
+	CartShares    []CartShare    `json:"cart_shares"`
+	Quotes        []Quote        `json:"quotes"`
+	PointsLedger  []PointsLedger `json:"points_ledger"`
+	WalletLedger  []WalletLedger `json:"wallet_ledger"`
+	Notifications []Notification `json:"notifications"`
+	ExportedAt    time.Time      `json:"exported_at"`
+}
+
+// 收集用户的全部数据
+func collectUserData(userID uint) (*UserDataExport, error) {
+	data := &UserDataExport{ExportedAt: time.Now()}
+
+	if err := DB.First(&data.Profile, userID).Error; err != nil {
+		return nil, err
+	}
+	roles, err := GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	data.Roles = roles
+
+	queries := []struct {
+		query *gorm.DB
+		dest  interface{}
+	}{
+		{DB.Preload("OrderItems.Product").Preload("DeliveryProofs").Order("created_at ASC"), &data.Orders},
+		{DB.Preload("Product"), &data.CartItems},
+		{DB.Preload("Product").Order("created_at ASC"), &data.Favorites},
+		{DB.Order("created_at ASC"), &data.SavedSearches},
+		{DB.Preload("Items"), &data.CartShares},
+		{DB.Order("created_at ASC"), &data.Quotes},
+		{DB.Order("id ASC"), &data.PointsLedger},
+		{DB.Order("id ASC"), &data.WalletLedger},
+		{DB.Order("created_at ASC"), &data.Notifications},
+	}
+	for _, q := range queries {
+		if err := q.query.Where("user_id = ?", userID).Find(q.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	data.Addresses = make([]string, 0)
+	for _, order := range data.Orders {
+		if order.ShippingAddress != "" && !seen[order.ShippingAddress] {
+			seen[order.ShippingAddress] = true
+			data.Addresses = append(data.Addresses, order.ShippingAddress)
+		}
+	}
+	return data, nil
+}
+
+// 将导出数据写为CSV压缩包，每类数据一个文件
+func writeUserDataCSV(c *gin.Context, data *UserDataExport) error {
+	archive := zip.NewWriter(c.Writer)
+	defer archive.Close()
+
+	writeFile := func(name string, rows [][]string) error {
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(f)
+		w.WriteAll(rows)
+		return w.Error()
+	}
+
+	formatTime := func(t time.Time) string { return t.Format(time.RFC3339) }
+	p := data.Profile
+
+	files := map[string][][]string{
+		"profile.csv": {
+			{"id", "username", "email", "phone", "real_name", "avatar", "status", "created_at"},
+			{strconv.Itoa(int(p.ID)), p.Username, p.Email, p.Phone, p.RealName, p.Avatar, strconv.Itoa(p.Status), formatTime(p.CreatedAt)},
+		},
+		"orders.csv":        {{"order_no", "status", "total_amount", "shipping_address", "created_at"}},
+		"order_items.csv":   {{"order_no", "product_id", "product_name", "quantity", "price"}},
+		"addresses.csv":     {{"address"}},
+		"cart_items.csv":    {{"product_id", "product_name", "quantity", "created_at"}},
+		"favorites.csv":     {{"product_id", "product_name", "created_at"}},
+		"quotes.csv":        {{"id", "product_id", "quantity", "status", "quoted_price", "created_at"}},
+		"notifications.csv": {{"type", "title", "content", "is_read", "created_at"}},
+	}
+	for _, role := range data.Roles {
+		files["profile.csv"] = append(files["profile.csv"], []string{"role", role})
+	}
+	for _, o := range data.Orders {
+		files["orders.csv"] = append(files["orders.csv"], []string{o.OrderNo, o.Status, fmt.Sprintf("%.2f", o.TotalAmount), o.ShippingAddress, formatTime(o.CreatedAt)})
+		for _, item := range o.OrderItems {
+			files["order_items.csv"] = append(files["order_items.csv"], []string{o.OrderNo, strconv.Itoa(int(item.ProductID)), item.ProductName, strconv.Itoa(item.Quantity), fmt.Sprintf("%.2f", item.Price)})
+		}
+	}
+	for _, address := range data.Addresses {
+		files["addresses.csv"] = append(files["addresses.csv"], []string{address})
+	}
+	for _, item := range data.CartItems {
+		files["cart_items.csv"] = append(files["cart_items.csv"], []string{strconv.Itoa(int(item.ProductID)), item.Product.Name, strconv.Itoa(item.Quantity), formatTime(item.CreatedAt)})
+	}
+	for _, f := range data.Favorites {
+		files["favorites.csv"] = append(files["favorites.csv"], []string{strconv.Itoa(int(f.ProductID)), f.Product.Name, formatTime(f.CreatedAt)})
+	}
+	for _, q := range data.Quotes {
+		files["quotes.csv"] = append(files["quotes.csv"], []string{strconv.Itoa(int(q.ID)), strconv.Itoa(int(q.ProductID)), strconv.Itoa(q.Quantity), q.Status, fmt.Sprintf("%.2f", q.QuotedPrice), formatTime(q.CreatedAt)})
+	}
+	for _, n := range data.Notifications {
+		files["notifications.csv"] = append(files["notifications.csv"], []string{n.Type, n.Title, n.Content, strconv.FormatBool(n.IsRead), formatTime(n.CreatedAt)})
+	}
+
+	for _, name := range []string{"profile.csv", "orders.csv", "order_items.csv", "addresses.csv", "cart_items.csv", "favorites.csv", "quotes.csv", "notifications.csv"} {
+		if err := writeFile(name, files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportUserData 导出个人数据
+// @Summary 导出个人数据
+// @Description 导出当前用户的个人资料、订单、收货地址、购物车、收藏、询价单和站内通知；format=csv 时返回包含多个CSV文件的zip压缩包
+// @Tags 用户管理
+// @Accept json
+// @Produce json,application/zip
+// @Param format query string false "导出格式：json、csv" default(json)
+// @Success 200 {object} ApiResponse{data=UserDataExport} "导出成功"
+// @Failure 400 {object} ApiResponse "不支持的导出格式"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/export [get]
+func ExportUserData(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		BadRequestError(c, "不支持的导出格式")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	data, err := collectUserData(userID.(uint))
+	if err != nil {
+		InternalServerError(c, "数据导出失败")
+		return
+	}
+
+	if format == "json" {
+		SuccessResponse(c, data)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=gomall-export-%d-%s.zip", data.Profile.ID, data.ExportedAt.Format("20060102")))
+	if err := writeUserDataCSV(c, data); err != nil {
+		c.Error(err)
+	}
+}
+
+// DeleteAccount 注销账号
+// @Summary 注销账号
+// @Description 校验密码后注销当前账号：取消待支付订单、清空购物车、关闭分享链接、抹除个人信息并使所有登录失效；历史订单为财务留存保留，但收货地址会被清除；钱包还有余额时不能注销
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "确认密码"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "注销成功"
+// @Failure 400 {object} ApiResponse "参数验证失败、存在进行中的订单或钱包还有余额"
+// @Failure 401 {object} ApiResponse "密码错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/account [delete]
+func DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+	if !VerifyPassword(req.Password, user.PasswordHash) {
+		UnauthorizedError(c, "密码错误")
+		return
+	}
+
+	// 已支付或配送中的订单需要处理完毕后才能注销
+	var activeOrders int64
+	DB.Model(&Order{}).Where("user_id = ? AND status IN ?", user.ID, []string{OrderStatusPaid, OrderStatusShipped}).Count(&activeOrders)
+	if activeOrders > 0 {
+		BadRequestError(c, "存在已支付或配送中的订单，请在订单完成后再注销账号")
+		return
+	}
+
+	var pendingOrderIDs []uint
+	DB.Model(&Order{}).Where("user_id = ? AND status = ?", user.ID, OrderStatusPending).Pluck("id", &pendingOrderIDs)
+
+	// 钱包余额（包括待支付订单已用余额支付的部分）用完后才能注销
+	var walletPaid int64
+	if len(pendingOrderIDs) > 0 {
+		DB.Model(&Payment{}).
+			Where("order_id IN ? AND channel = ? AND status = ?", pendingOrderIDs, PaymentChannelWallet, PaymentStatusSucceeded).
+			Count(&walletPaid)
+	}
+	if user.Balance > 0 || walletPaid > 0 {
+		BadRequestError(c, "钱包中还有余额，请在使用完后再注销账号")
+		return
+	}
+
+	randomPassword, err := generateSecureToken(16)
+	if err != nil {
+		InternalServerError(c, "账号注销失败")
+		return
+	}
+
+	// 待付款订单取消时在同一事务中退回库存，提交后再退回可售库存
+	released := make(map[uint]int)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, orderID := range pendingOrderIDs {
+			var order Order
+			if err := tx.First(&order, orderID).Error; err != nil {
+				return err
+			}
+			if err := transitionOrder(tx, &order, OrderStatusCancelled, OrderActor{Role: RoleCustomer, UserID: user.ID}, "注销账号"); err != nil {
+				return err
+			}
+			quantities, err := releaseOrderStockInTx(tx, orderID)
+			if err != nil {
+				return err
+			}
+			for productID, quantity := range quantities {
+				released[productID] += quantity
+			}
+		}
+		if err := tx.Model(&Order{}).Where("user_id = ?", user.ID).Update("shipping_address", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&CartItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&SavedItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&Favorite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&SavedSearch{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&RestockSubscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&CartShare{}).Where("user_id = ?", user.ID).Update("status", 0).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Quote{}).Where("user_id = ? AND status IN ?", user.ID, []string{QuoteStatusPending, QuoteStatusQuoted}).
+			Update("status", QuoteStatusCancelled).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&Notification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+
+		// 抹除个人信息，用户名和邮箱改为占位值以释放唯一索引
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"username":      fmt.Sprintf("deleted_%d", user.ID),
+			"email":         fmt.Sprintf("deleted_%d@deleted.local", user.ID),
+			"phone":         "",
+			"real_name":     "",
+			"avatar":        "",
+			"password_hash": HashPassword(randomPassword),
+			"status":        0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		InternalServerError(c, "账号注销失败")
+		return
+	}
+
+	GlobalStockManager.releaseAll(released)
+	InvalidateUserSessions(user.ID)
+	invalidateCartCache(user.ID)
+
+	SuccessResponse(c, gin.H{"message": "账号已注销"})
+}
@@ -0,0 +1,275 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UpdateOrderAddressRequest 修改收货地址请求
+type UpdateOrderAddressRequest struct {
+	ShippingAddress string `json:"shipping_address" binding:"required"`
+}
+
+// AddOrderNoteRequest 添加订单备注请求
+type AddOrderNoteRequest struct {
+	Content string `json:"content" binding:"required,max=500"`
+}
+
+// ForceCancelOrderRequest 管理员取消订单请求
+type ForceCancelOrderRequest struct {
+	Reason string `json:"reason" binding:"required,max=200"` // 取消原因，记入状态历史并通知用户
+}
+
+// ShipOrderRequest 订单发货请求
+type ShipOrderRequest struct {
+	Carrier    string `json:"carrier" binding:"required,max=50"`      // 物流公司
+	TrackingNo string `json:"tracking_no" binding:"required,max=100"` // 运单号
+}
+
+// 按路径参数加载订单，失败时已写入响应
+func loadAdminOrder(c *gin.Context) (*Order, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return nil, false
+	}
+	var order Order
+	if err := DB.First(&order, orderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return nil, false
+	}
+	return &order, true
+}
+
+// 提交管理员发起的订单状态变更，失败时已写入响应
+func runAdminOrderTransition(c *gin.Context, order *Order, req UpdateOrderStatusRequest, message string) bool {
+	req.actor = OrderActor{Role: RoleAdmin, UserID: c.GetUint("user_id")}
+	if err := checkOrderTransition(order, req.Status, req.actor); err != nil {
+		orderTransitionError(c, err, message)
+		return false
+	}
+	if err := runOrderStatusJob(order.ID, c.GetUint("user_id"), req); err != nil {
+		if errors.Is(err, errOrderJobTimeout) {
+			InternalServerError(c, message+"：处理超时")
+			return false
+		}
+		orderTransitionError(c, err, message)
+		return false
+	}
+	return true
+}
+
+// UpdateOrderAddress 修改订单收货地址
+// @Summary 修改订单收货地址
+// @Description 管理员修改待支付或已支付（未发货）订单的收货地址，新地址需满足订单商品的配送地区和空运限制
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param address body UpdateOrderAddressRequest true "新收货地址"
+// @Success 200 {object} ApiResponse{data=Order} "修改成功"
+// @Failure 400 {object} ApiResponse "参数验证失败、订单已发货或商品无法配送到新地址"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/orders/{id}/address [put]
+func UpdateOrderAddress(c *gin.Context) {
+	var req UpdateOrderAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	address := strings.TrimSpace(req.ShippingAddress)
+	if address == "" {
+		BadRequestError(c, "收货地址不能为空")
+		return
+	}
+
+	order, ok := loadAdminOrder(c)
+	if !ok {
+		return
+	}
+	if order.Status != OrderStatusPending && order.Status != OrderStatusPaid {
+		BadRequestError(c, "订单已"+orderStatusLabel(order.Status)+"，不能修改收货地址")
+		return
+	}
+
+	var orderItems []OrderItem
+	if err := DB.Preload("Product").Where("order_id = ?", order.ID).Find(&orderItems).Error; err != nil {
+		InternalServerError(c, "订单商品查询失败")
+		return
+	}
+	items := make([]shippingItem, 0, len(orderItems))
+	for i := range orderItems {
+		items = append(items, shippingItem{Product: &orderItems[i].Product, Quantity: orderItems[i].Quantity})
+	}
+	if err := CheckShippingConstraints(items, address); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	// 按状态条件更新，避免与发货并发时改掉已发出订单的地址
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Order{}).
+			Where("id = ? AND status IN ?", order.ID, []string{OrderStatusPending, OrderStatusPaid}).
+			Update("shipping_address", address)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderStatusChanged
+		}
+		actor := OrderActor{Role: RoleAdmin, UserID: c.GetUint("user_id")}
+		return recordOrderEvent(tx, order.ID, OrderEventAddressChanged, actor, "新收货地址："+address)
+	})
+	if err != nil {
+		orderTransitionError(c, err, "收货地址修改失败")
+		return
+	}
+
+	recordAdminAudit(c, "order.address", "order", order.ID, fmt.Sprintf("收货地址：%s → %s", order.ShippingAddress, address))
+	SendNotification(order.UserID, NotificationTypeOrder, "订单收货地址已修改",
+		fmt.Sprintf("您的订单 %s 收货地址已修改为：%s", order.OrderNo, address))
+
+	order.ShippingAddress = address
+	SuccessResponse(c, order)
+}
+
+// AddOrderNote 添加订单内部备注
+// @Summary 添加订单内部备注
+// @Description 为订单添加仅管理员可见的内部备注，备注在订单详情的 notes 字段中按时间顺序展示，买家看不到
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param note body AddOrderNoteRequest true "备注内容"
+// @Success 200 {object} ApiResponse{data=OrderNote} "添加成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/orders/{id}/notes [post]
+func AddOrderNote(c *gin.Context) {
+	var req AddOrderNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		BadRequestError(c, "备注内容不能为空")
+		return
+	}
+
+	order, ok := loadAdminOrder(c)
+	if !ok {
+		return
+	}
+
+	note := OrderNote{
+		OrderID: order.ID,
+		AdminID: c.GetUint("user_id"),
+		Content: content,
+	}
+	if err := DB.Create(&note).Error; err != nil {
+		InternalServerError(c, "订单备注添加失败")
+		return
+	}
+
+	recordAdminAudit(c, "order.note", "order", order.ID, content)
+	SuccessResponse(c, note)
+}
+
+// ForceCancelOrder 管理员取消订单
+// @Summary 管理员取消订单
+// @Description 取消待支付或已支付（未发货）的订单，退回库存和积分，取消原因记入状态历史并通知用户
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param cancel body ForceCancelOrderRequest true "取消原因"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "取消成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或订单状态不允许取消"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "服务器内部错误或超时"
+// @Security Bearer
+// @Router /api/admin/orders/{id}/cancel [post]
+func ForceCancelOrder(c *gin.Context) {
+	var req ForceCancelOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	order, ok := loadAdminOrder(c)
+	if !ok {
+		return
+	}
+	fromStatus := order.Status
+	if !runAdminOrderTransition(c, order, UpdateOrderStatusRequest{Status: OrderStatusCancelled, Note: req.Reason}, "订单取消失败") {
+		return
+	}
+
+	recordAdminAudit(c, "order.cancel", "order", order.ID, fmt.Sprintf("%s → %s，原因：%s",
+		orderStatusLabel(fromStatus), orderStatusLabel(OrderStatusCancelled), req.Reason))
+	SendNotification(order.UserID, NotificationTypeOrder, "订单已取消",
+		fmt.Sprintf("您的订单 %s 已被取消，原因：%s", order.OrderNo, req.Reason))
+
+	SuccessResponse(c, gin.H{"message": "订单取消成功"})
+}
+
+// ShipOrder 订单发货
+// @Summary 订单发货
+// @Description 登记物流公司和运单号并将订单标记为已发货，适用于已支付的在线支付订单和待支付的货到付款订单，发货后通知用户
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param shipment body ShipOrderRequest true "物流信息"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "发货成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或订单状态不允许发货"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "服务器内部错误或超时"
+// @Security Bearer
+// @Router /api/admin/orders/{id}/ship [put]
+func ShipOrder(c *gin.Context) {
+	var req ShipOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	carrier := strings.TrimSpace(req.Carrier)
+	trackingNo := strings.TrimSpace(req.TrackingNo)
+	if carrier == "" || trackingNo == "" {
+		BadRequestError(c, "物流公司和运单号不能为空")
+		return
+	}
+
+	order, ok := loadAdminOrder(c)
+	if !ok {
+		return
+	}
+	update := UpdateOrderStatusRequest{
+		Status:     OrderStatusShipped,
+		Note:       fmt.Sprintf("%s %s", carrier, trackingNo),
+		carrier:    carrier,
+		trackingNo: trackingNo,
+	}
+	if !runAdminOrderTransition(c, order, update, "订单发货失败") {
+		return
+	}
+
+	recordAdminAudit(c, "order.ship", "order", order.ID, fmt.Sprintf("物流公司：%s，运单号：%s", carrier, trackingNo))
+	SendNotification(order.UserID, NotificationTypeOrder, "订单已发货",
+		fmt.Sprintf("您的订单 %s 已发货，物流公司：%s，运单号：%s", order.OrderNo, carrier, trackingNo))
+
+	SuccessResponse(c, gin.H{"message": "订单发货成功"})
+}
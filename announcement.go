@@ -0,0 +1,400 @@
+package gomall
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 公告缓存键
+const activeAnnouncementsCacheKey = "announcements:active"
+
+// 公告请求结构体
+type CreateAnnouncementRequest struct {
+	Title           string     `json:"title" binding:"required,min=1,max=200"`
+	Content         string     `json:"content" binding:"required"`
+	Type            string     `json:"type" binding:"omitempty,oneof=notice maintenance promotion"`
+	AudienceRole    string     `json:"audience_role"`
+	AudienceSegment string     `json:"audience_segment"` // 目标客户分群，如 champions、at_risk
+	StartAt         *time.Time `json:"start_at"`
+	EndAt           *time.Time `json:"end_at"`
+}
+
+type UpdateAnnouncementRequest struct {
+	Title           string     `json:"title,omitempty"`
+	Content         string     `json:"content,omitempty"`
+	Type            string     `json:"type,omitempty" binding:"omitempty,oneof=notice maintenance promotion"`
+	AudienceRole    *string    `json:"audience_role,omitempty"`
+	AudienceSegment *string    `json:"audience_segment,omitempty"`
+	StartAt         *time.Time `json:"start_at,omitempty"`
+	EndAt           *time.Time `json:"end_at,omitempty"`
+	Status          *int       `json:"status,omitempty"`
+}
+
+// 公告缓存管理
+func CacheActiveAnnouncements(announcements []Announcement) error {
+	data, err := json.Marshal(announcements)
+	if err != nil {
+		return err
+	}
+	return RDB.Set(CTX, activeAnnouncementsCacheKey, data, time.Minute).Err() // 1分钟过期，保证定时公告及时生效
+}
+
+func GetCachedActiveAnnouncements() ([]Announcement, error) {
+	data, err := RDB.Get(CTX, activeAnnouncementsCacheKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	var announcements []Announcement
+	err = json.Unmarshal([]byte(data), &announcements)
+	return announcements, err
+}
+
+func DeleteCachedActiveAnnouncements() error {
+	return RDB.Del(CTX, activeAnnouncementsCacheKey).Err()
+}
+
+// 校验目标角色是否存在
+func validAudienceRole(role string) bool {
+	if role == "" {
+		return true
+	}
+	_, ok := defaultRoles[role]
+	return ok
+}
+
+// 校验目标客户分群是否存在
+func validAudienceSegment(segment string) bool {
+	return segment == "" || customerSegments[segment]
+}
+
+// StartAnnouncementScheduler 启动公告投递协程，按生效时间将公告投递到站内通知
+func StartAnnouncementScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			deliverDueAnnouncements()
+			<-ticker.C
+		}
+	}()
+	log.Println("公告投递任务已启动")
+}
+
+// 投递已到生效时间的公告
+func deliverDueAnnouncements() {
+	now := time.Now()
+	var announcements []Announcement
+	err := DB.Where("status = ? AND delivered = ? AND start_at <= ?", 1, false, now).
+		Where("end_at IS NULL OR end_at > ?", now).
+		Find(&announcements).Error
+	if err != nil {
+		log.Printf("查询待投递公告失败: %v", err)
+		return
+	}
+
+	for _, announcement := range announcements {
+		if err := deliverAnnouncement(&announcement); err != nil {
+			log.Printf("公告 %d 投递失败: %v", announcement.ID, err)
+			continue
+		}
+		DB.Model(&announcement).Update("delivered", true)
+		DeleteCachedActiveAnnouncements()
+		log.Printf("公告 %d 已投递到站内通知", announcement.ID)
+	}
+}
+
+// 将公告分批投递给目标用户
+func deliverAnnouncement(announcement *Announcement) error {
+	query := DB.Model(&User{}).Where("users.status = ?", 1)
+	if announcement.AudienceRole != "" {
+		query = query.Joins("JOIN user_roles ON user_roles.user_id = users.id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", announcement.AudienceRole)
+	}
+	if announcement.AudienceSegment != "" {
+		query = query.Joins("JOIN customer_metrics ON customer_metrics.user_id = users.id").
+			Where("customer_metrics.segment = ?", announcement.AudienceSegment)
+	}
+
+	var users []User
+	return query.Select("users.id").FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+		userIDs := make([]uint, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, user.ID)
+		}
+		return SendNotifications(userIDs, NotificationTypeAnnouncement, announcement.Title, announcement.Content)
+	}).Error
+}
+
+// GetActiveAnnouncements 获取当前生效的公告
+// @Summary 获取当前生效的公告
+// @Description 获取当前时间窗口内生效的公告，用于横幅展示；登录用户还能看到面向其角色和客户分群的公告
+// @Tags 公告
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Announcement} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/announcements/active [get]
+func GetActiveAnnouncements(c *gin.Context) {
+	announcements, err := GetCachedActiveAnnouncements()
+	if err != nil {
+		now := time.Now()
+		err = DB.Where("status = ? AND start_at <= ?", 1, now).
+			Where("end_at IS NULL OR end_at > ?", now).
+			Order("start_at DESC").
+			Find(&announcements).Error
+		if err != nil {
+			InternalServerError(c, "公告查询失败")
+			return
+		}
+		CacheActiveAnnouncements(announcements)
+	}
+
+	// 按用户角色和客户分群过滤，客户分群只在有分群公告时查询
+	var segment *string
+	visible := make([]Announcement, 0, len(announcements))
+	for _, announcement := range announcements {
+		if announcement.AudienceRole != "" && !HasRole(c, announcement.AudienceRole) {
+			continue
+		}
+		if announcement.AudienceSegment != "" {
+			if segment == nil {
+				value := ""
+				if userID, ok := c.Get("user_id"); ok {
+					value = userCustomerSegment(userID.(uint))
+				}
+				segment = &value
+			}
+			if *segment != announcement.AudienceSegment {
+				continue
+			}
+		}
+		visible = append(visible, announcement)
+	}
+
+	SuccessResponse(c, visible)
+}
+
+// GetAnnouncements 获取公告列表（管理端）
+// @Summary 获取公告列表
+// @Description 分页获取全部公告
+// @Tags 公告
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Announcement}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/announcements [get]
+func GetAnnouncements(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	var total int64
+	DB.Model(&Announcement{}).Count(&total)
+
+	var announcements []Announcement
+	offset := (page - 1) * pageSize
+	if err := DB.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&announcements).Error; err != nil {
+		InternalServerError(c, "公告查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, announcements, total, page, pageSize)
+}
+
+// CreateAnnouncement 创建公告
+// @Summary 创建公告
+// @Description 创建全站公告，可设置生效时间、结束时间、目标角色和目标客户分群（按RFM评分划分，用于定向营销）；到达生效时间后自动投递到站内通知
+// @Tags 公告
+// @Accept json
+// @Produce json
+// @Param announcement body CreateAnnouncementRequest true "公告信息"
+// @Success 200 {object} ApiResponse{data=Announcement} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/announcements [post]
+func CreateAnnouncement(c *gin.Context) {
+	var req CreateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	if !validAudienceRole(req.AudienceRole) {
+		BadRequestError(c, "目标角色不存在")
+		return
+	}
+	if !validAudienceSegment(req.AudienceSegment) {
+		BadRequestError(c, "目标客户分群不存在")
+		return
+	}
+
+	startAt := time.Now()
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+	if req.EndAt != nil && !req.EndAt.After(startAt) {
+		BadRequestError(c, "结束时间必须晚于生效时间")
+		return
+	}
+
+	announcementType := req.Type
+	if announcementType == "" {
+		announcementType = "notice"
+	}
+
+	userID, _ := c.Get("user_id")
+	announcement := Announcement{
+		Title:           req.Title,
+		Content:         req.Content,
+		Type:            announcementType,
+		AudienceRole:    req.AudienceRole,
+		AudienceSegment: req.AudienceSegment,
+		StartAt:         startAt,
+		EndAt:           req.EndAt,
+		Status:          1,
+		CreatedBy:       userID.(uint),
+	}
+
+	if err := DB.Create(&announcement).Error; err != nil {
+		InternalServerError(c, "公告创建失败")
+		return
+	}
+
+	DeleteCachedActiveAnnouncements()
+
+	SuccessResponse(c, announcement)
+}
+
+// UpdateAnnouncement 更新公告
+// @Summary 更新公告
+// @Description 更新公告内容、时间窗口、目标角色、目标客户分群或状态
+// @Tags 公告
+// @Accept json
+// @Produce json
+// @Param id path int true "公告ID"
+// @Param announcement body UpdateAnnouncementRequest true "更新的公告信息"
+// @Success 200 {object} ApiResponse{data=Announcement} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "公告不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/announcements/{id} [put]
+func UpdateAnnouncement(c *gin.Context) {
+	announcementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的公告ID")
+		return
+	}
+
+	var req UpdateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var announcement Announcement
+	if err := DB.First(&announcement, announcementID).Error; err != nil {
+		NotFoundError(c, "公告不存在")
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Content != "" {
+		updates["content"] = req.Content
+	}
+	if req.Type != "" {
+		updates["type"] = req.Type
+	}
+	if req.AudienceRole != nil {
+		if !validAudienceRole(*req.AudienceRole) {
+			BadRequestError(c, "目标角色不存在")
+			return
+		}
+		updates["audience_role"] = *req.AudienceRole
+	}
+	if req.AudienceSegment != nil {
+		if !validAudienceSegment(*req.AudienceSegment) {
+			BadRequestError(c, "目标客户分群不存在")
+			return
+		}
+		updates["audience_segment"] = *req.AudienceSegment
+	}
+	if req.StartAt != nil {
+		updates["start_at"] = *req.StartAt
+	}
+	if req.EndAt != nil {
+		updates["end_at"] = *req.EndAt
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if err := DB.Model(&announcement).Updates(updates).Error; err != nil {
+		InternalServerError(c, "公告更新失败")
+		return
+	}
+
+	DB.First(&announcement, announcementID)
+	DeleteCachedActiveAnnouncements()
+
+	SuccessResponse(c, announcement)
+}
+
+// DeleteAnnouncement 删除公告
+// @Summary 删除公告
+// @Description 删除公告，已投递的站内通知不受影响
+// @Tags 公告
+// @Accept json
+// @Produce json
+// @Param id path int true "公告ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 400 {object} ApiResponse "无效的公告ID"
+// @Failure 404 {object} ApiResponse "公告不存在"
+// @Security Bearer
+// @Router /api/admin/announcements/{id} [delete]
+func DeleteAnnouncement(c *gin.Context) {
+	announcementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的公告ID")
+		return
+	}
+
+	result := DB.Delete(&Announcement{}, announcementID)
+	if result.Error != nil {
+		InternalServerError(c, "公告删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "公告不存在")
+		return
+	}
+
+	DeleteCachedActiveAnnouncements()
+
+	SuccessResponse(c, gin.H{"message": "公告删除成功"})
+}
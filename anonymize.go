@@ -0,0 +1,131 @@
+package gomall
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 脱敏后统一使用的登录密码，便于测试环境登录
+const anonymizedPassword = "gomall123"
+
+var (
+	fakeSurnames   = []string{"王", "李", "张", "刘", "陈", "杨", "黄", "赵", "吴", "周", "徐", "孙", "马", "朱", "胡", "郭"}
+	fakeGivenNames = []string{"伟", "芳", "娜", "敏", "静", "磊", "洋", "勇", "艳", "杰", "军", "涛", "明", "超", "霞", "平", "刚", "桂英"}
+	fakeCities     = []string{"北京市朝阳区", "上海市浦东新区", "广州市天河区", "深圳市南山区", "杭州市西湖区", "成都市武侯区", "南京市鼓楼区", "武汉市洪山区"}
+	fakeStreets    = []string{"建设路", "人民路", "解放路", "中山路", "和平路", "文化路", "新华路", "胜利路"}
+	fakePhonePre   = []string{"130", "131", "135", "136", "138", "139", "150", "158", "177", "186", "188", "199"}
+)
+
+// 脱敏数据生成器，使用固定种子保证同一数据库多次执行结果一致
+type anonymizer struct {
+	rng *rand.Rand
+}
+
+func (a *anonymizer) pick(items []string) string {
+	return items[a.rng.Intn(len(items))]
+}
+
+func (a *anonymizer) name() string {
+	return a.pick(fakeSurnames) + a.pick(fakeGivenNames)
+}
+
+func (a *anonymizer) phone() string {
+	return fmt.Sprintf("%s%08d", a.pick(fakePhonePre), a.rng.Intn(100000000))
+}
+
+func (a *anonymizer) address() string {
+	return fmt.Sprintf("%s%s%d号%d栋%d室", a.pick(fakeCities), a.pick(fakeStreets), a.rng.Intn(300)+1, a.rng.Intn(30)+1, a.rng.Intn(2000)+101)
+}
+
+// RunAnonymizeCommand 执行数据脱敏子命令
+// 用法: GoMall anonymize -db gomall_staging [-seed 1]
+func RunAnonymizeCommand(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	dbName := fs.String("db", "", "要脱敏的数据库名（必须是生产库的副本）")
+	seed := fs.Int64("seed", 1, "随机种子，相同种子生成相同的脱敏数据")
+	force := fs.Bool("force", false, "允许对DB_NAME配置的数据库执行脱敏")
+	fs.Parse(args)
+
+	if *dbName == "" {
+		return fmt.Errorf("请通过 -db 指定要脱敏的数据库")
+	}
+	if *dbName == AppConfig.DBName && !*force {
+		return fmt.Errorf("目标数据库 %s 与当前配置的业务库相同，如确认执行请添加 -force", *dbName)
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		AppConfig.DBUser,
+		AppConfig.DBPassword,
+		AppConfig.DBHost,
+		AppConfig.DBPort,
+		*dbName)
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %v", err)
+	}
+
+	a := &anonymizer{rng: rand.New(rand.NewSource(*seed))}
+	return db.Transaction(func(tx *gorm.DB) error {
+		userCount, err := anonymizeUsers(tx, a)
+		if err != nil {
+			return err
+		}
+		orderCount, err := anonymizeOrders(tx, a)
+		if err != nil {
+			return err
+		}
+		log.Printf("数据脱敏完成: 用户 %d 个，订单 %d 个；所有用户密码已重置为 %s", userCount, orderCount, anonymizedPassword)
+		return nil
+	})
+}
+
+// 脱敏用户信息，保留ID以维持关联关系
+func anonymizeUsers(tx *gorm.DB, a *anonymizer) (int, error) {
+	var users []User
+	if err := tx.Select("id", "phone").Order("id ASC").Find(&users).Error; err != nil {
+		return 0, fmt.Errorf("查询用户失败: %v", err)
+	}
+
+	passwordHash := HashPassword(anonymizedPassword)
+	for _, user := range users {
+		updates := map[string]interface{}{
+			"username":      fmt.Sprintf("user%d", user.ID),
+			"email":         fmt.Sprintf("user%d@example.com", user.ID),
+			"real_name":     a.name(),
+			"avatar":        "",
+			"password_hash": passwordHash,
+		}
+		if user.Phone != "" {
+			updates["phone"] = a.phone()
+		}
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return 0, fmt.Errorf("用户 %d 脱敏失败: %v", user.ID, err)
+		}
+	}
+	return len(users), nil
+}
+
+// 脱敏订单收货地址
+func anonymizeOrders(tx *gorm.DB, a *anonymizer) (int, error) {
+	var orders []Order
+	if err := tx.Select("id").Order("id ASC").Find(&orders).Error; err != nil {
+		return 0, fmt.Errorf("查询订单失败: %v", err)
+	}
+
+	for _, order := range orders {
+		address := fmt.Sprintf("%s %s %s", a.name(), a.phone(), a.address())
+		if err := tx.Model(&Order{}).Where("id = ?", order.ID).Update("shipping_address", address).Error; err != nil {
+			return 0, fmt.Errorf("订单 %d 脱敏失败: %v", order.ID, err)
+		}
+	}
+	return len(orders), nil
+}
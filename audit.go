@@ -0,0 +1,85 @@
+package gomall
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录管理员操作，写入失败只记日志，不影响已完成的操作
+func recordAdminAudit(c *gin.Context, action, targetType string, targetID uint, detail string) {
+	entry := AdminAuditLog{
+		AdminID:    c.GetUint("user_id"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Detail:     detail,
+		IP:         c.ClientIP(),
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		log.Printf("管理员操作日志记录失败（%s %s %d）: %v", action, targetType, targetID, err)
+	}
+}
+
+// GetAdminAuditLogs 获取管理员操作日志
+// @Summary 获取管理员操作日志
+// @Description 分页查询管理员操作日志，按时间倒序，可按操作人、操作和操作对象筛选
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param admin_id query int false "操作人ID"
+// @Param action query string false "操作，如 order.cancel"
+// @Param target_type query string false "操作对象类型，如 order"
+// @Param target_id query int false "操作对象ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]AdminAuditLog}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/audit-logs [get]
+func GetAdminAuditLogs(c *gin.Context) {
+	page := 1
+	pageSize := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&AdminAuditLog{})
+	if value := c.Query("admin_id"); value != "" {
+		if adminID, err := strconv.ParseUint(value, 10, 32); err == nil {
+			query = query.Where("admin_id = ?", adminID)
+		}
+	}
+	if value := c.Query("action"); value != "" {
+		query = query.Where("action = ?", value)
+	}
+	if value := c.Query("target_type"); value != "" {
+		query = query.Where("target_type = ?", value)
+	}
+	if value := c.Query("target_id"); value != "" {
+		if targetID, err := strconv.ParseUint(value, 10, 32); err == nil {
+			query = query.Where("target_id = ?", targetID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var logs []AdminAuditLog
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&logs).Error; err != nil {
+		InternalServerError(c, "操作日志查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, logs, total, page, pageSize)
+}
@@ -0,0 +1,196 @@
+package gomall
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 头像图片参数
+const (
+	avatarJPEGQuality = 90
+	avatarMaxPixels   = 40000000 // 原图像素上限，防止解码超大图片耗尽内存
+	avatarURLPrefix   = "/upload/avatars/"
+)
+
+// 居中裁剪为正方形并缩放到指定边长，透明背景填充为白色
+func resizeAvatar(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	// 先铺白底再叠加原图，避免透明区域编码为JPEG后变黑
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), src, crop.Min, draw.Over)
+
+	// 区域平均缩放：目标像素取原图对应区域的平均色，放大时退化为最近邻
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := y * side / size
+		y1 := max((y+1)*side/size, y0+1)
+		for x := 0; x < size; x++ {
+			x0 := x * side / size
+			x1 := max((x+1)*side/size, x0+1)
+
+			var r, g, b, count uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					offset := square.PixOffset(sx, sy)
+					r += uint32(square.Pix[offset])
+					g += uint32(square.Pix[offset+1])
+					b += uint32(square.Pix[offset+2])
+					count++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = 255
+		}
+	}
+	return dst
+}
+
+// UploadAvatar 上传头像
+// @Summary 上传头像
+// @Description 上传头像图片（jpg、png、gif），服务端居中裁剪并缩放为统一尺寸的JPEG后更新用户头像，旧头像文件会被删除
+// @Tags 用户管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "头像图片"
+// @Success 200 {object} ApiResponse{data=User} "上传成功"
+// @Failure 400 {object} ApiResponse "未选择文件、格式不支持或文件过大"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/avatar [post]
+func UploadAvatar(c *gin.Context) {
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		BadRequestError(c, "请选择头像图片")
+		return
+	}
+	if !isValidImageFile(file) {
+		BadRequestError(c, "文件不是有效的图片格式")
+		return
+	}
+	if file.Size > AppConfig.MaxFileSize {
+		BadRequestError(c, "文件大小超过限制")
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		BadRequestError(c, "文件读取失败")
+		return
+	}
+	defer src.Close()
+
+	// 先读取尺寸，拒绝像素过多的图片
+	config, _, err := image.DecodeConfig(src)
+	if err != nil {
+		BadRequestError(c, "不支持的图片格式，请上传jpg、png或gif图片")
+		return
+	}
+	if config.Width*config.Height > avatarMaxPixels {
+		BadRequestError(c, "图片分辨率过大")
+		return
+	}
+	if _, err := src.Seek(0, 0); err != nil {
+		InternalServerError(c, "文件读取失败")
+		return
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		BadRequestError(c, "图片解码失败")
+		return
+	}
+
+	uploadDir := AppConfig.UploadPath + "/avatars"
+	os.MkdirAll(uploadDir, 0755)
+
+	userID, _ := c.Get("user_id")
+	random, err := generateSecureToken(12)
+	if err != nil {
+		InternalServerError(c, "头像保存失败")
+		return
+	}
+	filename := fmt.Sprintf("avatar_%d_%s.jpg", userID.(uint), random)
+	savePath := filepath.Join(uploadDir, filename)
+
+	out, err := os.Create(savePath)
+	if err != nil {
+		InternalServerError(c, "头像保存失败")
+		return
+	}
+	size := AppConfig.AvatarSize
+	if size <= 0 {
+		size = 256
+	}
+	err = jpeg.Encode(out, resizeAvatar(img, size), &jpeg.Options{Quality: avatarJPEGQuality})
+	out.Close()
+	if err != nil {
+		os.Remove(savePath)
+		InternalServerError(c, "头像保存失败")
+		return
+	}
+	info, _ := os.Stat(savePath)
+
+	// 上传记录和用户头像在同一事务中更新，失败时删除已写入的文件
+	var user User
+	var oldAvatar string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		oldAvatar = user.Avatar
+
+		uploadedFile := UploadedFile{
+			OriginalName: file.Filename,
+			FileName:     filename,
+			FilePath:     avatarURLPrefix + filename,
+			FileSize:     info.Size(),
+			MimeType:     "image/jpeg",
+			UploadedBy:   user.ID,
+		}
+		if err := tx.Create(&uploadedFile).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("avatar", uploadedFile.FilePath).Error; err != nil {
+			return err
+		}
+		user.Avatar = uploadedFile.FilePath
+		return nil
+	})
+	if err != nil {
+		os.Remove(savePath)
+		InternalServerError(c, "头像更新失败")
+		return
+	}
+
+	// 删除本人之前上传的头像文件；头像地址可通过资料接口随意填写，不能删除他人的文件
+	oldName := filepath.Base(strings.TrimPrefix(oldAvatar, avatarURLPrefix))
+	if strings.HasPrefix(oldAvatar, avatarURLPrefix) && strings.HasPrefix(oldName, fmt.Sprintf("avatar_%d_", user.ID)) {
+		if err := os.Remove(filepath.Join(uploadDir, oldName)); err != nil && !os.IsNotExist(err) {
+			log.Printf("旧头像删除失败: %v", err)
+		}
+		DB.Where("file_path = ?", oldAvatar).Delete(&UploadedFile{})
+	}
+
+	SuccessResponse(c, user)
+}
@@ -0,0 +1,441 @@
+package gomall
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 备份目录结构：<BACKUP_DIR>/gomall-20060102-150405/{database.sql.gz, uploads.tar.gz, manifest.json}
+const (
+	backupNamePrefix   = "gomall-"
+	backupTimeLayout   = "20060102-150405"
+	backupDatabaseFile = "database.sql.gz"
+	backupUploadsFile  = "uploads.tar.gz"
+	backupManifestFile = "manifest.json"
+)
+
+var backupDBNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// BackupManifest 备份清单，记录备份来源和各文件的校验值
+type BackupManifest struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Database  string            `json:"database"`
+	UploadDir string            `json:"upload_dir"`
+	Files     []BackupFileEntry `json:"files"`
+}
+
+// BackupFileEntry 备份文件的大小和SHA256
+type BackupFileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// RunBackupCommand 执行备份子命令
+// 用法:
+//
+//	GoMall backup [-dest ./backups] [-keep 7]
+//	GoMall backup verify -path ./backups/gomall-20240101-030000
+//	GoMall backup restore -path ./backups/gomall-20240101-030000 -db gomall_restore [-upload-dir ./restore/upload] [-force]
+func RunBackupCommand(args []string) error {
+	action := "create"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "create":
+		fs := flag.NewFlagSet("backup", flag.ExitOnError)
+		dest := fs.String("dest", AppConfig.BackupDir, "备份存放目录")
+		keep := fs.Int("keep", AppConfig.BackupKeep, "保留最近的备份数量，0表示不清理")
+		fs.Parse(args)
+
+		path, err := CreateBackup(*dest, *keep)
+		if err != nil {
+			return err
+		}
+		log.Printf("备份完成: %s", path)
+		return nil
+	case "verify":
+		fs := flag.NewFlagSet("backup verify", flag.ExitOnError)
+		path := fs.String("path", "", "要校验的备份目录")
+		fs.Parse(args)
+
+		if *path == "" {
+			return fmt.Errorf("请通过 -path 指定备份目录")
+		}
+		manifest, err := VerifyBackup(*path)
+		if err != nil {
+			return err
+		}
+		log.Printf("备份校验通过: 数据库 %s，创建于 %s", manifest.Database, manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+		return nil
+	case "restore":
+		fs := flag.NewFlagSet("backup restore", flag.ExitOnError)
+		path := fs.String("path", "", "要恢复的备份目录")
+		dbName := fs.String("db", "", "恢复到的数据库名，不存在时自动创建")
+		uploadDir := fs.String("upload-dir", "", "上传文件恢复目录，默认为UPLOAD_PATH")
+		force := fs.Bool("force", false, "允许覆盖DB_NAME配置的业务库和当前上传目录")
+		fs.Parse(args)
+
+		if *path == "" || *dbName == "" {
+			return fmt.Errorf("请通过 -path 指定备份目录，-db 指定目标数据库")
+		}
+		if *uploadDir == "" {
+			*uploadDir = AppConfig.UploadPath
+		}
+		return RestoreBackup(*path, *dbName, *uploadDir, *force)
+	default:
+		return fmt.Errorf("未知的备份操作: %s（支持 verify、restore）", action)
+	}
+}
+
+// StartBackupScheduler 启动定时备份任务，BACKUP_INTERVAL_HOURS 为0时不启用
+func StartBackupScheduler() {
+	interval := time.Duration(AppConfig.BackupIntervalHours) * time.Hour
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// 多实例部署时每个周期只由一个实例执行备份
+			key := fmt.Sprintf("backup:lock:%d", time.Now().Unix()/int64(interval.Seconds()))
+			if ok, _ := RDB.SetNX(CTX, key, 1, interval).Result(); !ok {
+				continue
+			}
+			if path, err := CreateBackup(AppConfig.BackupDir, AppConfig.BackupKeep); err != nil {
+				log.Printf("定时备份失败: %v", err)
+			} else {
+				log.Printf("定时备份完成: %s", path)
+			}
+		}
+	}()
+	log.Println("定时备份任务已启动")
+}
+
+// 连接MySQL命令行工具的公共参数，密码通过环境变量传递，避免出现在进程列表中
+func mysqlCommand(binary string, args ...string) *exec.Cmd {
+	base := []string{"-h", AppConfig.DBHost, "-P", AppConfig.DBPort, "-u", AppConfig.DBUser, "--default-character-set=utf8mb4"}
+	cmd := exec.Command(binary, append(base, args...)...)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+AppConfig.DBPassword)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// CreateBackup 导出数据库并打包上传目录，完成后按保留数量清理旧备份
+func CreateBackup(dest string, keep int) (string, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", fmt.Errorf("创建备份目录失败: %v", err)
+	}
+
+	now := time.Now()
+	name := backupNamePrefix + now.Format(backupTimeLayout)
+	finalPath := filepath.Join(dest, name)
+	// 先写入临时目录，全部成功后再重命名，中途失败不会留下看似完整的备份
+	workPath := filepath.Join(dest, "."+name+".tmp")
+	if err := os.MkdirAll(workPath, 0755); err != nil {
+		return "", fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	defer os.RemoveAll(workPath)
+
+	if err := dumpDatabase(filepath.Join(workPath, backupDatabaseFile)); err != nil {
+		return "", fmt.Errorf("数据库导出失败: %v", err)
+	}
+	if err := archiveDirectory(AppConfig.UploadPath, filepath.Join(workPath, backupUploadsFile)); err != nil {
+		return "", fmt.Errorf("上传目录打包失败: %v", err)
+	}
+
+	manifest := BackupManifest{CreatedAt: now, Database: AppConfig.DBName, UploadDir: AppConfig.UploadPath}
+	for _, file := range []string{backupDatabaseFile, backupUploadsFile} {
+		size, sum, err := fileDigest(filepath.Join(workPath, file))
+		if err != nil {
+			return "", err
+		}
+		manifest.Files = append(manifest.Files, BackupFileEntry{Name: file, Size: size, SHA256: sum})
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(filepath.Join(workPath, backupManifestFile), data, 0644); err != nil {
+		return "", fmt.Errorf("写入备份清单失败: %v", err)
+	}
+
+	if err := os.Rename(workPath, finalPath); err != nil {
+		return "", fmt.Errorf("保存备份失败: %v", err)
+	}
+
+	if keep > 0 {
+		if err := rotateBackups(dest, keep); err != nil {
+			log.Printf("清理旧备份失败: %v", err)
+		}
+	}
+	return finalPath, nil
+}
+
+// 使用mysqldump导出表结构和数据，gzip压缩后写入文件
+func dumpDatabase(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	// 不使用 --databases，导出内容不绑定库名，可以恢复到任意数据库
+	cmd := mysqlCommand(AppConfig.MySQLDumpPath, "--single-transaction", "--quick", "--routines", "--triggers", AppConfig.DBName)
+	cmd.Stdout = gz
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// 将目录打包为tar.gz，目录不存在时生成空归档
+func archiveDirectory(dir, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(dir, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && current == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		// 只打包普通文件和目录，跳过符号链接等特殊文件
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, current)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(current)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// 计算文件大小和SHA256
+func fileDigest(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// 按名称中的时间排序，只保留最近的 keep 个备份
+func rotateBackups(dest string, keep int) error {
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), backupNamePrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for i := 0; i < len(names)-keep; i++ {
+		if err := os.RemoveAll(filepath.Join(dest, names[i])); err != nil {
+			return err
+		}
+		log.Printf("已删除旧备份: %s", names[i])
+	}
+	return nil
+}
+
+// VerifyBackup 校验备份文件的大小、SHA256，并确认压缩包可以完整读取
+func VerifyBackup(path string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(path, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %v", err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("备份清单格式错误: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, entry := range manifest.Files {
+		size, sum, err := fileDigest(filepath.Join(path, entry.Name))
+		if err != nil {
+			return nil, fmt.Errorf("读取备份文件 %s 失败: %v", entry.Name, err)
+		}
+		if size != entry.Size || sum != entry.SHA256 {
+			return nil, fmt.Errorf("备份文件 %s 校验失败，文件可能已损坏", entry.Name)
+		}
+		found[entry.Name] = true
+	}
+	if !found[backupDatabaseFile] || !found[backupUploadsFile] {
+		return nil, fmt.Errorf("备份清单缺少数据库或上传文件归档")
+	}
+
+	if err := readGzip(filepath.Join(path, backupDatabaseFile), func(r io.Reader) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("数据库备份无法解压: %v", err)
+	}
+	if err := readGzip(filepath.Join(path, backupUploadsFile), func(r io.Reader) error {
+		return walkTar(r, func(*tar.Header, io.Reader) error { return nil })
+	}); err != nil {
+		return nil, fmt.Errorf("上传文件归档无法读取: %v", err)
+	}
+	return &manifest, nil
+}
+
+func readGzip(path string, fn func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return fn(gz)
+}
+
+func walkTar(r io.Reader, fn func(*tar.Header, io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreBackup 校验备份后恢复数据库和上传文件
+func RestoreBackup(path, dbName, uploadDir string, force bool) error {
+	if !backupDBNamePattern.MatchString(dbName) {
+		return fmt.Errorf("数据库名只能包含字母、数字和下划线")
+	}
+	if !force && (dbName == AppConfig.DBName || filepath.Clean(uploadDir) == filepath.Clean(AppConfig.UploadPath)) {
+		return fmt.Errorf("恢复目标与当前业务库或上传目录相同，如确认覆盖请添加 -force")
+	}
+
+	if _, err := VerifyBackup(path); err != nil {
+		return err
+	}
+
+	err := readGzip(filepath.Join(path, backupDatabaseFile), func(r io.Reader) error {
+		prelude := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;\nUSE `%s`;\n", dbName, dbName)
+		cmd := mysqlCommand(AppConfig.MySQLClientPath)
+		cmd.Stdin = io.MultiReader(strings.NewReader(prelude), r)
+		return cmd.Run()
+	})
+	if err != nil {
+		return fmt.Errorf("数据库恢复失败: %v", err)
+	}
+	log.Printf("数据库已恢复到 %s", dbName)
+
+	err = readGzip(filepath.Join(path, backupUploadsFile), func(r io.Reader) error {
+		return walkTar(r, func(header *tar.Header, content io.Reader) error {
+			// 拒绝绝对路径和 .. 开头的条目，防止写到目标目录之外
+			name := filepath.Clean(filepath.FromSlash(header.Name))
+			if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("归档包含非法路径: %s", header.Name)
+			}
+			target := filepath.Join(uploadDir, name)
+
+			switch header.Typeflag {
+			case tar.TypeDir:
+				return os.MkdirAll(target, 0755)
+			case tar.TypeReg:
+				if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+					return err
+				}
+				out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(out, content); err != nil {
+					out.Close()
+					return err
+				}
+				return out.Close()
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("上传文件恢复失败: %v", err)
+	}
+	log.Printf("上传文件已恢复到 %s", uploadDir)
+	return nil
+}
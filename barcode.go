@@ -0,0 +1,88 @@
+package gomall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 条形码只允许字母、数字和连字符，兼容 EAN/UPC 和 Code 128 常见编码
+var barcodePattern = regexp.MustCompile(`^[0-9A-Za-z-]{4,64}$`)
+
+// 规范化条形码：去除首尾空白并统一为大写
+func normalizeBarcode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !barcodePattern.MatchString(code) {
+		return "", fmt.Errorf("条形码只能包含字母、数字和连字符，长度为4-64位")
+	}
+	return code, nil
+}
+
+// 条形码是否已被其他商品使用，exceptID 为当前商品ID
+func barcodeTaken(code string, exceptID uint) bool {
+	var count int64
+	DB.Model(&Product{}).Where("barcode = ? AND id <> ?", code, exceptID).Count(&count)
+	return count > 0
+}
+
+// 解析商品的条形码设置，空字符串表示清除条形码（存为 NULL，不占用唯一索引）
+func parseProductBarcode(code string, productID uint) (*string, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, nil
+	}
+	barcode, err := normalizeBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+	if barcodeTaken(barcode, productID) {
+		return nil, fmt.Errorf("条形码 %s 已被其他商品使用", barcode)
+	}
+	return &barcode, nil
+}
+
+// GetProductByBarcode 按条形码查询商品
+// @Summary 按条形码查询商品
+// @Description 供收银和仓库扫码枪使用，按条形码精确查询商品；管理员可查询未上架的商品
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param code path string true "条形码"
+// @Success 200 {object} ApiResponse{data=Product} "查询成功"
+// @Failure 400 {object} ApiResponse "条形码格式错误"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Router /api/products/by-barcode/{code} [get]
+func GetProductByBarcode(c *gin.Context) {
+	code, err := normalizeBarcode(c.Param("code"))
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	// 条形码走唯一索引只查询ID，商品详情优先从缓存读取
+	var productIDs []uint
+	DB.Model(&Product{}).Where("barcode = ?", code).Limit(1).Pluck("id", &productIDs)
+	if len(productIDs) == 0 {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+	productID := productIDs[0]
+
+	if product, err := GetCachedProduct(productID); err == nil {
+		SuccessResponse(c, product)
+		return
+	}
+
+	var product Product
+	if err := DB.Preload("Category").Preload("Brand").First(&product, productID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+	if product.Status != 1 && !HasRole(c, RoleAdmin) {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+
+	SuccessResponse(c, product)
+}
@@ -0,0 +1,273 @@
+package gomall
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 品牌请求结构体
+type CreateBrandRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Logo        string `json:"logo" binding:"max=255"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+type UpdateBrandRequest struct {
+	Name        string `json:"name,omitempty" binding:"max=100"`
+	Logo        string `json:"logo,omitempty" binding:"max=255"`
+	Description string `json:"description,omitempty"`
+	SortOrder   int    `json:"sort_order,omitempty"`
+}
+
+// 品牌缓存管理
+func CacheBrands(brands []Brand) error {
+	key := "brands:all"
+	data, err := json.Marshal(brands)
+	if err != nil {
+		return err
+	}
+	return RDB.Set(CTX, key, data, AppConfig.BrandCache.Expiration()).Err()
+}
+
+func GetCachedBrands() ([]Brand, error) {
+	key := "brands:all"
+	data, err := RDB.Get(CTX, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	var brands []Brand
+	err = json.Unmarshal([]byte(data), &brands)
+	return brands, err
+}
+
+func DeleteCachedBrands() error {
+	key := "brands:all"
+	return RDB.Del(CTX, key).Err()
+}
+
+// 品牌名称是否已被其他启用的品牌使用
+func brandNameTaken(name string, excludeID uint) bool {
+	var count int64
+	DB.Model(&Brand{}).Where("name = ? AND status = ? AND id <> ?", name, 1, excludeID).Count(&count)
+	return count > 0
+}
+
+// CreateBrand 创建品牌
+// @Summary 创建品牌
+// @Description 创建商品品牌，包括名称、Logo和描述
+// @Tags 商品品牌
+// @Accept json
+// @Produce json
+// @Param brand body CreateBrandRequest true "品牌信息"
+// @Success 200 {object} ApiResponse{data=Brand} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或品牌名称已存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/brands [post]
+func CreateBrand(c *gin.Context) {
+	var req CreateBrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	if brandNameTaken(req.Name, 0) {
+		BadRequestError(c, "品牌名称已存在")
+		return
+	}
+
+	brand := Brand{
+		Name:        req.Name,
+		Logo:        req.Logo,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+		Status:      1,
+	}
+	if err := DB.Create(&brand).Error; err != nil {
+		InternalServerError(c, "品牌创建失败")
+		return
+	}
+
+	// 清除品牌缓存
+	DeleteCachedBrands()
+
+	SuccessResponse(c, brand)
+}
+
+// GetBrands 获取品牌列表
+// @Summary 获取品牌列表
+// @Description 获取所有启用的品牌，按排序值升序排列
+// @Tags 商品品牌
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Brand} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/brands [get]
+func GetBrands(c *gin.Context) {
+	// 尝试从缓存获取
+	if brands, err := GetCachedBrands(); err == nil {
+		SuccessResponse(c, brands)
+		return
+	}
+
+	// 从数据库查询
+	var brands []Brand
+	if err := DB.Where("status = ?", 1).Order("sort_order ASC, created_at ASC").Find(&brands).Error; err != nil {
+		InternalServerError(c, "品牌查询失败")
+		return
+	}
+
+	// 缓存品牌列表
+	CacheBrands(brands)
+
+	SuccessResponse(c, brands)
+}
+
+// GetBrand 获取品牌详情
+// @Summary 获取品牌详情
+// @Description 根据品牌ID获取品牌的详细信息
+// @Tags 商品品牌
+// @Accept json
+// @Produce json
+// @Param id path int true "品牌ID"
+// @Success 200 {object} ApiResponse{data=Brand} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的品牌ID"
+// @Failure 404 {object} ApiResponse "品牌不存在或已禁用"
+// @Router /api/brands/{id} [get]
+func GetBrand(c *gin.Context) {
+	brandID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的品牌ID")
+		return
+	}
+
+	var brand Brand
+	if err := DB.First(&brand, brandID).Error; err != nil {
+		NotFoundError(c, "品牌不存在")
+		return
+	}
+
+	if brand.Status != 1 {
+		NotFoundError(c, "品牌已禁用")
+		return
+	}
+
+	SuccessResponse(c, brand)
+}
+
+// UpdateBrand 更新品牌信息
+// @Summary 更新品牌信息
+// @Description 更新品牌的名称、Logo、描述和排序
+// @Tags 商品品牌
+// @Accept json
+// @Produce json
+// @Param id path int true "品牌ID"
+// @Param brand body UpdateBrandRequest true "更新的品牌信息"
+// @Success 200 {object} ApiResponse{data=Brand} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或品牌名称已存在"
+// @Failure 404 {object} ApiResponse "品牌不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/brands/{id} [put]
+func UpdateBrand(c *gin.Context) {
+	brandID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的品牌ID")
+		return
+	}
+
+	var req UpdateBrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var brand Brand
+	if err := DB.First(&brand, brandID).Error; err != nil {
+		NotFoundError(c, "品牌不存在")
+		return
+	}
+
+	// 准备更新数据
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		if brandNameTaken(req.Name, brand.ID) {
+			BadRequestError(c, "品牌名称已存在")
+			return
+		}
+		updates["name"] = req.Name
+	}
+	if req.Logo != "" {
+		updates["logo"] = req.Logo
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.SortOrder != 0 {
+		updates["sort_order"] = req.SortOrder
+	}
+
+	if len(updates) > 0 {
+		if err := DB.Model(&brand).Updates(updates).Error; err != nil {
+			InternalServerError(c, "品牌更新失败")
+			return
+		}
+	}
+
+	// 重新查询更新后的品牌
+	DB.First(&brand, brandID)
+
+	// 清除品牌缓存
+	DeleteCachedBrands()
+
+	SuccessResponse(c, brand)
+}
+
+// DeleteBrand 删除品牌（软删除）
+// @Summary 删除品牌
+// @Description 软删除品牌，如果品牌下有在售商品则不能删除
+// @Tags 商品品牌
+// @Accept json
+// @Produce json
+// @Param id path int true "品牌ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 400 {object} ApiResponse "无效的品牌ID或品牌下有商品"
+// @Failure 404 {object} ApiResponse "品牌不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/brands/{id} [delete]
+func DeleteBrand(c *gin.Context) {
+	brandID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的品牌ID")
+		return
+	}
+
+	var brand Brand
+	if err := DB.First(&brand, brandID).Error; err != nil {
+		NotFoundError(c, "品牌不存在")
+		return
+	}
+
+	// 检查是否有商品使用此品牌
+	var productCount int64
+	DB.Model(&Product{}).Where("brand_id = ? AND status = ?", brandID, 1).Count(&productCount)
+	if productCount > 0 {
+		BadRequestError(c, "该品牌下有商品，无法删除")
+		return
+	}
+
+	// 软删除：设置状态为0
+	if err := DB.Model(&brand).Update("status", 0).Error; err != nil {
+		InternalServerError(c, "品牌删除失败")
+		return
+	}
+
+	// 清除品牌缓存
+	DeleteCachedBrands()
+
+	SuccessResponse(c, gin.H{"message": "品牌删除成功"})
+}
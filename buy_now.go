@@ -0,0 +1,69 @@
+package gomall
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BuyNowRequest 立即购买请求，订单设置与购物车下单相同
+type BuyNowRequest struct {
+	ProductID       uint   `json:"product_id" binding:"required"`
+	Quantity        int    `json:"quantity" binding:"required,min=1"`
+	ShippingAddress string `json:"shipping_address"`                                    // 含实物商品时必填
+	PaymentMethod   string `json:"payment_method" binding:"omitempty,oneof=online cod"` // 默认在线支付
+	RedeemPoints    int    `json:"redeem_points" binding:"omitempty,min=0"`             // 使用积分抵扣
+	GiftRecipient   string `json:"gift_recipient"`                                      // 收礼人用户名或手机号，填写后为礼物订单
+	GiftMessage     string `json:"gift_message" binding:"max=200"`                      // 礼物留言
+	Remark          string `json:"remark" binding:"max=200"`                            // 买家备注
+}
+
+// 立即购买的商品行，不对应购物车项（ID 为0），按当前价格结算
+func buyNowLine(db *gorm.DB, userID, productID uint, quantity int) (CartItem, error) {
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return CartItem{}, fmt.Errorf("商品 %d 不存在", productID)
+	}
+	if product.Status != 1 {
+		return CartItem{}, fmt.Errorf("商品 %s 已下架", product.Name)
+	}
+	return CartItem{
+		UserID:    userID,
+		ProductID: product.ID,
+		Product:   product,
+		Quantity:  quantity,
+		Price:     product.Price,
+	}, nil
+}
+
+// BuyNow 立即购买
+// @Summary 立即购买
+// @Description 直接购买单个商品，不经过也不修改购物车；库存预占、限购、配送限制、运费、积分抵扣和礼物订单的规则与购物车下单相同
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param order body BuyNowRequest true "商品、数量和订单信息"
+// @Success 200 {object} ApiResponse{data=CreateOrderResponse} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "订单创建失败或超时"
+// @Security Bearer
+// @Router /api/orders/buy-now [post]
+func BuyNow(c *gin.Context) {
+	var req BuyNowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	submitCreateOrder(c, CreateOrderRequest{
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		RedeemPoints:    req.RedeemPoints,
+		GiftRecipient:   req.GiftRecipient,
+		GiftMessage:     req.GiftMessage,
+		Remark:          req.Remark,
+		buyNowProductID: req.ProductID,
+		buyNowQuantity:  req.Quantity,
+	})
+}
@@ -0,0 +1,204 @@
+package gomall
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 购物车已满时跳过的原因，其他原因与购物车商品提醒相同
+const CartSkipReasonCartFull = "cart_full"
+
+// BatchAddCartRequest 批量加入购物车请求
+type BatchAddCartRequest struct {
+	Items []AddCartRequest `json:"items" binding:"required,min=1,max=50,dive"`
+}
+
+// CartAddedItem 已加入购物车的商品
+type CartAddedItem struct {
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Requested   int    `json:"requested"` // 请求加入的数量
+	Quantity    int    `json:"quantity"`  // 实际加入的数量，受库存和限购限制可能少于请求数量
+}
+
+// CartSkippedItem 未能加入购物车的商品
+type CartSkippedItem struct {
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name,omitempty"`
+	Requested   int    `json:"requested"`
+	Reason      string `json:"reason"` // delisted、out_of_stock、insufficient_stock、purchase_limit、cart_full
+	Message     string `json:"message"`
+}
+
+// CartAddResult 批量加入购物车的结果
+type CartAddResult struct {
+	Added   []CartAddedItem   `json:"added"`
+	Skipped []CartSkippedItem `json:"skipped"`
+}
+
+// 按顺序将商品加入购物车，数量按库存和限购截断；商品不存在、已下架或一件都不能加入时记入跳过列表
+func addProductsToCart(tx *gorm.DB, userID uint, productIDs []uint, quantities map[uint]int) (*CartAddResult, error) {
+	var products []Product
+	if err := tx.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	result := &CartAddResult{Added: []CartAddedItem{}, Skipped: []CartSkippedItem{}}
+	for _, productID := range productIDs {
+		requested := quantities[productID]
+		product, ok := byID[productID]
+		if !ok || product.Status != 1 {
+			skipped := CartSkippedItem{ProductID: productID, Requested: requested, Reason: CartWarningDelisted, Message: "商品已下架"}
+			if ok {
+				skipped.ProductName = product.Name
+			}
+			result.Skipped = append(result.Skipped, skipped)
+			continue
+		}
+
+		quantity, err := addProductToCart(tx, userID, product, requested)
+		if errors.Is(err, errCartFull) {
+			result.Skipped = append(result.Skipped, CartSkippedItem{
+				ProductID:   productID,
+				ProductName: product.Name,
+				Requested:   requested,
+				Reason:      CartSkipReasonCartFull,
+				Message:     err.Error(),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if quantity > 0 {
+			result.Added = append(result.Added, CartAddedItem{
+				ProductID:   productID,
+				ProductName: product.Name,
+				Requested:   requested,
+				Quantity:    quantity,
+			})
+			continue
+		}
+
+		skipped := CartSkippedItem{ProductID: productID, ProductName: product.Name, Requested: requested}
+		switch {
+		case product.orderableStock() <= 0:
+			skipped.Reason, skipped.Message = CartWarningOutOfStock, "商品已售罄"
+		case product.MaxPerUser > 0:
+			skipped.Reason, skipped.Message = CartWarningPurchaseLimit, "已达到每人限购数量"
+		default:
+			skipped.Reason, skipped.Message = CartWarningInsufficientStock, "购物车中的数量已达到库存上限"
+		}
+		result.Skipped = append(result.Skipped, skipped)
+	}
+	return result, nil
+}
+
+// BatchAddToCart 批量加入购物车
+// @Summary 批量加入购物车
+// @Description 一次加入多个商品，购物车中已有的商品累加数量；数量超过库存或限购时按可购买数量加入，商品已下架、售罄或已达限购时跳过并说明原因
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param items body BatchAddCartRequest true "商品及数量"
+// @Success 200 {object} ApiResponse{data=CartAddResult} "加入完成"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/batch-add [post]
+func BatchAddToCart(c *gin.Context) {
+	var req BatchAddCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	// 同一商品出现多次时合并数量
+	productIDs := make([]uint, 0, len(req.Items))
+	quantities := make(map[uint]int, len(req.Items))
+	for _, item := range req.Items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	userID := c.GetUint("user_id")
+	var result *CartAddResult
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = addProductsToCart(tx, userID, productIDs, quantities)
+		return err
+	})
+	invalidateCartCache(userID)
+	if err != nil {
+		InternalServerError(c, "加入购物车失败")
+		return
+	}
+	if len(result.Added) > 0 {
+		publishCartEvent(userID, CartEvent{Type: CartEventRefresh})
+	}
+
+	SuccessResponse(c, result)
+}
+
+// RebuyOrder 再次购买
+// @Summary 再次购买
+// @Description 将历史订单中的全部商品按原数量加入购物车（按当前价格结算），已下架、售罄或已达限购的商品跳过并在结果中说明原因
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=CartAddResult} "加入完成"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/rebuy [post]
+func RebuyOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	var order Order
+	if err := DB.Preload("OrderItems").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	productIDs := make([]uint, 0, len(order.OrderItems))
+	quantities := make(map[uint]int, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	var result *CartAddResult
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = addProductsToCart(tx, userID, productIDs, quantities)
+		return err
+	})
+	invalidateCartCache(userID)
+	if err != nil {
+		InternalServerError(c, "加入购物车失败")
+		return
+	}
+	if len(result.Added) > 0 {
+		publishCartEvent(userID, CartEvent{Type: CartEventRefresh})
+	}
+
+	SuccessResponse(c, result)
+}
@@ -0,0 +1,104 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errCartFull = errors.New("购物车商品已达上限，请先结算或删除部分商品")
+
+// 加入新商品前检查购物车商品种数是否已达上限
+func checkCartSize(db *gorm.DB, userID uint) error {
+	if AppConfig.MaxCartItems <= 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&CartItem{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= int64(AppConfig.MaxCartItems) {
+		return fmt.Errorf("%w（最多 %d 种）", errCartFull, AppConfig.MaxCartItems)
+	}
+	return nil
+}
+
+// 移除下架或删除超过 StaleCartItemDays 天的商品，按用户通知被移除的商品。返回移除的购物车项数
+func cleanupStaleCartItems(now time.Time) (int, error) {
+	if AppConfig.StaleCartItemDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.AddDate(0, 0, -AppConfig.StaleCartItemDays)
+
+	// 没有下架时间的旧数据以最后更新时间为准；商品记录已不存在的购物车项也一并移除
+	var rows []struct {
+		ID          uint
+		UserID      uint
+		ProductName string
+	}
+	err := DB.Table("cart_items ci").
+		Select("ci.id, ci.user_id, p.name AS product_name").
+		Joins("LEFT JOIN products p ON p.id = ci.product_id").
+		Where("p.id IS NULL OR (p.status = ? AND COALESCE(p.delisted_at, p.updated_at) <= ?)", 0, cutoff).
+		Order("ci.user_id, ci.id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, len(rows))
+	names := make(map[uint][]string)
+	removed := make(map[uint][]uint)
+	for i, row := range rows {
+		ids[i] = row.ID
+		removed[row.UserID] = append(removed[row.UserID], row.ID)
+		name := row.ProductName
+		if name == "" {
+			name = "已删除的商品"
+		}
+		names[row.UserID] = append(names[row.UserID], "「"+name+"」")
+	}
+	if err := DB.Where("id IN ?", ids).Delete(&CartItem{}).Error; err != nil {
+		return 0, err
+	}
+
+	for userID, products := range names {
+		invalidateCartCache(userID)
+		publishCartRemoved(userID, removed[userID]...)
+		SendNotification(userID, NotificationTypeSystem, "购物车商品已移除",
+			fmt.Sprintf("%s 已下架超过 %d 天，已从您的购物车中移除", strings.Join(products, "、"), AppConfig.StaleCartItemDays))
+	}
+	return len(ids), nil
+}
+
+// StartCartCleanupScheduler 启动购物车失效商品清理任务，每小时检查一次
+func StartCartCleanupScheduler() {
+	if AppConfig.StaleCartItemDays <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			// 多实例部署时同一小时只由一个实例执行
+			key := "cart:cleanup:" + time.Now().Format("2006010215")
+			if ok, _ := RDB.SetNX(CTX, key, 1, 2*time.Hour).Result(); ok {
+				if count, err := cleanupStaleCartItems(time.Now()); err != nil {
+					log.Printf("购物车失效商品清理失败: %v", err)
+				} else if count > 0 {
+					log.Printf("已从购物车移除 %d 个失效商品", count)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("购物车失效商品清理任务已启动")
+}
@@ -0,0 +1,175 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 购物车变更事件类型
+const (
+	CartEventAdded   = "added"   // 新加入的商品，item 为购物车项
+	CartEventUpdated = "updated" // 数量或价格变化，item 为变更后的购物车项
+	CartEventRemoved = "removed" // 删除或下单后移出，item_ids 为移出的购物车项ID
+	CartEventCleared = "cleared" // 购物车已清空
+	CartEventRefresh = "refresh" // 批量加入等一次修改多项，客户端应重新获取购物车
+)
+
+const (
+	cartEventChannelPrefix = "cart:events:"
+	cartEventHeartbeat     = 25 * time.Second // 心跳间隔，防止代理断开空闲连接
+	cartEventBuffer        = 16               // 每个连接缓冲的事件数，客户端处理过慢时丢弃事件
+)
+
+// CartEvent 购物车变更事件
+type CartEvent struct {
+	Type    string         `json:"type"`
+	Item    *CartEventItem `json:"item,omitempty"`
+	ItemIDs []uint         `json:"item_ids,omitempty"`
+	At      time.Time      `json:"at"`
+}
+
+// CartEventItem 事件中的购物车项，只含数量和价格，商品详情由客户端通过购物车接口获取，
+// 事件不经过字段可见性过滤，不能带上商品的成本价、下载地址等字段
+type CartEventItem struct {
+	ID        uint    `json:"id"`
+	ProductID uint    `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+// 当前实例上各用户的事件连接
+var cartEventSubscribers = struct {
+	sync.Mutex
+	byUser map[uint]map[chan string]struct{}
+}{byUser: make(map[uint]map[chan string]struct{})}
+
+func subscribeCartEvents(userID uint) chan string {
+	ch := make(chan string, cartEventBuffer)
+	cartEventSubscribers.Lock()
+	defer cartEventSubscribers.Unlock()
+	if cartEventSubscribers.byUser[userID] == nil {
+		cartEventSubscribers.byUser[userID] = make(map[chan string]struct{})
+	}
+	cartEventSubscribers.byUser[userID][ch] = struct{}{}
+	return ch
+}
+
+func unsubscribeCartEvents(userID uint, ch chan string) {
+	cartEventSubscribers.Lock()
+	defer cartEventSubscribers.Unlock()
+	delete(cartEventSubscribers.byUser[userID], ch)
+	if len(cartEventSubscribers.byUser[userID]) == 0 {
+		delete(cartEventSubscribers.byUser, userID)
+	}
+}
+
+// 将事件分发给当前实例上该用户的所有连接
+func dispatchCartEvent(userID uint, payload string) {
+	cartEventSubscribers.Lock()
+	defer cartEventSubscribers.Unlock()
+	for ch := range cartEventSubscribers.byUser[userID] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// 通过Redis发布购物车变更事件，所有实例上该用户的连接都能收到
+func publishCartEvent(userID uint, event CartEvent) {
+	event.At = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := RDB.Publish(CTX, fmt.Sprintf("%s%d", cartEventChannelPrefix, userID), data).Err(); err != nil {
+		log.Printf("购物车事件发布失败（用户 %d）: %v", userID, err)
+	}
+}
+
+// 购物车项加入或更新后调用
+func publishCartItemEvent(eventType string, item *CartItem) {
+	publishCartEvent(item.UserID, CartEvent{Type: eventType, Item: &CartEventItem{
+		ID:        item.ID,
+		ProductID: item.ProductID,
+		Quantity:  item.Quantity,
+		Price:     item.Price,
+	}})
+}
+
+func publishCartRemoved(userID uint, itemIDs ...uint) {
+	if len(itemIDs) > 0 {
+		publishCartEvent(userID, CartEvent{Type: CartEventRemoved, ItemIDs: itemIDs})
+	}
+}
+
+// StartCartEventListener 订阅Redis中的购物车事件并转发给本实例上的连接
+func StartCartEventListener() {
+	go func() {
+		for {
+			pubsub := RDB.PSubscribe(CTX, cartEventChannelPrefix+"*")
+			for msg := range pubsub.Channel() {
+				userID, err := strconv.ParseUint(strings.TrimPrefix(msg.Channel, cartEventChannelPrefix), 10, 32)
+				if err != nil {
+					continue
+				}
+				dispatchCartEvent(uint(userID), msg.Payload)
+			}
+			pubsub.Close()
+			log.Println("购物车事件订阅已断开，稍后重新订阅")
+			time.Sleep(time.Second)
+		}
+	}()
+	log.Println("购物车事件订阅已启动")
+}
+
+// CartEvents 购物车变更事件流
+// @Summary 购物车变更事件流
+// @Description 以 Server-Sent Events 推送当前用户购物车的变更，同一账号在多个设备上打开时保持购物车同步。事件名为类型：added 新加入（data.item，含购物车项ID、商品ID、数量和单价）、updated 数量或价格变化（data.item）、removed 删除或下单后移出（data.item_ids）、cleared 已清空、refresh 一次修改了多项，需重新获取购物车。连接建立时先发送 ready 事件，之后每隔一段时间发送心跳注释。需要在请求头中携带 Authorization，浏览器原生 EventSource 不支持自定义请求头，可使用基于 fetch 的 SSE 客户端
+// @Tags 购物车管理
+// @Produce text/event-stream
+// @Success 200 {object} CartEvent "事件流"
+// @Failure 401 {object} ApiResponse "未登录"
+// @Security Bearer
+// @Router /api/cart/events [get]
+func CartEvents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	ch := subscribeCartEvents(userID)
+	defer unsubscribeCartEvents(userID, ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭Nginx缓冲
+
+	heartbeat := time.NewTicker(cartEventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ready", gin.H{"user_id": userID})
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case payload := <-ch:
+			var event CartEvent
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				return true
+			}
+			c.SSEvent(event.Type, payload)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+}
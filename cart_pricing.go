@@ -0,0 +1,90 @@
+package gomall
+
+// CartPricingSummary 购物车价格汇总，与下单时的计价规则一致
+type CartPricingSummary struct {
+	ItemSubtotal      float64             `json:"item_subtotal"`          // 商品金额合计
+	Promotions        []PricingAdjustment `json:"promotions"`             // 适用的促销活动
+	PromotionDiscount float64             `json:"promotion_discount"`     // 促销优惠合计（负数）
+	CouponDiscount    float64             `json:"coupon_discount"`        // 优惠券抵扣预览（负数）
+	PointsDiscount    float64             `json:"points_discount"`        // 积分抵扣预览（负数）
+	PointsError       string              `json:"points_error,omitempty"` // 请求的积分不能使用的原因
+	ShippingFee       float64             `json:"shipping_fee"`           // 预估运费
+	TaxAmount         float64             `json:"tax_amount"`             // 税费
+	PayableTotal      float64             `json:"payable_total"`          // 应付金额
+	Trace             *PricingTrace       `json:"pricing_trace"`          // 完整计价明细
+}
+
+// 按购物车项计算订单价格：记录商品行，再按商品金额计入运费。
+// 下单和购物车价格汇总都调用这里，新增的促销规则也应加在 applySplitPricing 中，保证购物车和结算金额一致
+func applyCartPricing(trace *PricingTrace, items []CartItem) {
+	applySplitPricing([]*PricingTrace{trace}, [][]CartItem{items})
+}
+
+// 拆单后分别计价，traces 与 groups 一一对应：商品行和超大件附加费计入各自的子订单；
+// 是否包邮按整单商品金额判断，基础运费只计入第一个含实物商品的子订单，各子订单合计与不拆单时相同；税费按各子订单商品金额计算
+func applySplitPricing(traces []*PricingTrace, groups [][]CartItem) {
+	var subtotalCents int64
+	for _, items := range groups {
+		for i := range items {
+			subtotalCents += toCents(items[i].Product.Price) * int64(items[i].Quantity)
+		}
+	}
+
+	baseCharged := false
+	for g, items := range groups {
+		for i := range items {
+			traces[g].AddLine(&items[i].Product, items[i].Product.Price, items[i].Quantity)
+		}
+		for _, line := range shippingFeeLines(orderShippingItems(items), subtotalCents) {
+			if line.RuleID == shippingBaseRuleID {
+				if baseCharged {
+					continue
+				}
+				baseCharged = true
+			}
+			traces[g].AddAdjustment(PricingAdjustmentShipping, line.RuleID, line.Description, toCents(line.Amount))
+		}
+		addTaxAdjustment(traces[g])
+	}
+}
+
+// 计算购物车价格汇总，redeemPoints 大于0时预览积分抵扣，不扣除积分
+func summarizeCartPricing(userID uint, items []CartItem, redeemPoints int) CartPricingSummary {
+	trace := NewPricingTrace()
+	applyCartPricing(trace, items)
+
+	summary := CartPricingSummary{Promotions: []PricingAdjustment{}}
+	if redeemPoints > 0 {
+		if err := checkRedeemPoints(userID, redeemPoints, trace.Total()); err != nil {
+			summary.PointsError = err.Error()
+		} else if discountCents, points := pointsDiscount(redeemPoints); discountCents > 0 {
+			addPointsAdjustment(trace, points, discountCents)
+		}
+	}
+
+	summary.ItemSubtotal = fromCents(trace.BaseCents)
+	var promotionCents, couponCents, pointsCents, shippingCents, taxCents int64
+	for _, adjustment := range trace.Adjustments {
+		switch adjustment.Type {
+		case PricingAdjustmentPromotion:
+			summary.Promotions = append(summary.Promotions, adjustment)
+			promotionCents += adjustment.AmountCents
+		case PricingAdjustmentCoupon:
+			couponCents += adjustment.AmountCents
+		case PricingAdjustmentPoints:
+			pointsCents += adjustment.AmountCents
+		case PricingAdjustmentShipping:
+			shippingCents += adjustment.AmountCents
+		case PricingAdjustmentTax:
+			taxCents += adjustment.AmountCents
+		}
+	}
+	summary.PromotionDiscount = fromCents(promotionCents)
+	summary.CouponDiscount = fromCents(couponCents)
+	summary.PointsDiscount = fromCents(pointsCents)
+	summary.ShippingFee = fromCents(shippingCents)
+	summary.TaxAmount = fromCents(taxCents)
+	summary.PayableTotal = trace.Total()
+	summary.Trace = trace
+	return summary
+}
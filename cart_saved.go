@@ -0,0 +1,172 @@
+package gomall
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errSavedItemUnavailable = errors.New("商品已售罄或已达到限购数量，暂时不能加入购物车")
+
+// SaveCartItem 购物车商品移入稍后购买
+// @Summary 移入稍后购买
+// @Description 将购物车中的商品移入稍后购买列表，稍后购买中已有该商品时累加数量；清空购物车不影响稍后购买列表
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param id path int true "购物车项ID"
+// @Success 200 {object} ApiResponse{data=SavedItem} "移入成功"
+// @Failure 400 {object} ApiResponse "无效的购物车项ID"
+// @Failure 404 {object} ApiResponse "购物车项不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/{id}/save [post]
+func SaveCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的购物车项ID")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	var cartItem CartItem
+	if err := DB.Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
+		NotFoundError(c, "购物车项不存在")
+		return
+	}
+
+	var saved SavedItem
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND product_id = ?", userID, cartItem.ProductID).First(&saved).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			saved = SavedItem{UserID: userID, ProductID: cartItem.ProductID, Quantity: cartItem.Quantity, Price: cartItem.Price}
+			if err := tx.Create(&saved).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			saved.Quantity += cartItem.Quantity
+			if err := tx.Model(&saved).Updates(map[string]interface{}{"quantity": saved.Quantity, "price": cartItem.Price}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&cartItem).Error
+	})
+	if err != nil {
+		InternalServerError(c, "移入稍后购买失败")
+		return
+	}
+	uncacheCartItem(userID, cartItem.ID)
+	publishCartRemoved(userID, cartItem.ID)
+
+	DB.Preload("Product").First(&saved, saved.ID)
+	SuccessResponse(c, saved)
+}
+
+// GetSavedItems 获取稍后购买列表
+// @Summary 获取稍后购买列表
+// @Description 获取当前用户稍后购买的商品，按移入时间倒序
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]SavedItem} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/saved [get]
+func GetSavedItems(c *gin.Context) {
+	var items []SavedItem
+	if err := DB.Preload("Product").Where("user_id = ?", c.GetUint("user_id")).Order("created_at DESC").Find(&items).Error; err != nil {
+		InternalServerError(c, "稍后购买列表查询失败")
+		return
+	}
+
+	SuccessResponse(c, items)
+}
+
+// MoveSavedItemToCart 稍后购买的商品移回购物车
+// @Summary 移回购物车
+// @Description 将稍后购买的商品按保存的数量移回购物车（数量按库存和限购截断），成功后从稍后购买列表中删除
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param id path int true "稍后购买项ID"
+// @Success 200 {object} ApiResponse{data=CartItem} "移回成功"
+// @Failure 400 {object} ApiResponse "商品已下架、售罄或已达限购数量"
+// @Failure 404 {object} ApiResponse "稍后购买项不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/saved/{id}/move [post]
+func MoveSavedItemToCart(c *gin.Context) {
+	savedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的稍后购买项ID")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	var saved SavedItem
+	if err := DB.Preload("Product").Where("id = ? AND user_id = ?", savedID, userID).First(&saved).Error; err != nil {
+		NotFoundError(c, "稍后购买项不存在")
+		return
+	}
+	if saved.Product.ID == 0 || saved.Product.Status != 1 {
+		BadRequestError(c, "商品已下架")
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		quantity, err := addProductToCart(tx, userID, &saved.Product, saved.Quantity)
+		if err != nil {
+			return err
+		}
+		if quantity == 0 {
+			return errSavedItemUnavailable
+		}
+		return tx.Delete(&saved).Error
+	})
+	invalidateCartCache(userID)
+	if errors.Is(err, errSavedItemUnavailable) || errors.Is(err, errCartFull) {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if err != nil {
+		InternalServerError(c, "移回购物车失败")
+		return
+	}
+
+	var cartItem CartItem
+	DB.Preload("Product").Where("user_id = ? AND product_id = ?", userID, saved.ProductID).First(&cartItem)
+	publishCartEvent(userID, CartEvent{Type: CartEventRefresh})
+	SuccessResponse(c, cartItem)
+}
+
+// DeleteSavedItem 删除稍后购买的商品
+// @Summary 删除稍后购买的商品
+// @Description 从稍后购买列表中删除指定商品
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param id path int true "稍后购买项ID"
+// @Success 200 {object} ApiResponse "删除成功"
+// @Failure 400 {object} ApiResponse "无效的稍后购买项ID"
+// @Failure 404 {object} ApiResponse "稍后购买项不存在"
+// @Security Bearer
+// @Router /api/cart/saved/{id} [delete]
+func DeleteSavedItem(c *gin.Context) {
+	savedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的稍后购买项ID")
+		return
+	}
+
+	result := DB.Where("id = ? AND user_id = ?", savedID, c.GetUint("user_id")).Delete(&SavedItem{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		NotFoundError(c, "稍后购买项不存在")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "删除成功"})
+}
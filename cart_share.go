@@ -0,0 +1,351 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 分享链接可见范围
+const (
+	ShareVisibilityPublic     = "public"     // 任何持有链接的人可见
+	ShareVisibilityRegistered = "registered" // 仅登录用户可见
+)
+
+// 分享链接有效期限制（小时）
+const (
+	defaultShareExpireHours = 72
+	maxShareExpireHours     = 24 * 30
+)
+
+// 购物车分享请求结构体
+type CreateCartShareRequest struct {
+	CartItemIDs []uint `json:"cart_item_ids"` // 为空表示分享整个购物车
+	Title       string `json:"title" binding:"max=200"`
+	Note        string `json:"note"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public registered"`
+	ShowOwner   bool   `json:"show_owner"`
+	ExpireHours int    `json:"expire_hours" binding:"omitempty,min=1"` // 有效期（小时），默认72小时，最长30天
+}
+
+type AddSharedItemsRequest struct {
+	ProductIDs []uint `json:"product_ids"` // 为空表示加入分享中的全部商品
+}
+
+// CartShareView 分享链接查看响应
+type CartShareView struct {
+	Title       string          `json:"title"`
+	Note        string          `json:"note"`
+	Owner       string          `json:"owner,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at"`
+	Items       []CartShareItem `json:"items"`
+	TotalAmount float64         `json:"total_amount"`
+}
+
+// 查询可访问的分享链接
+func findActiveCartShare(token string) (*CartShare, error) {
+	var share CartShare
+	err := DB.Preload("Items.Product").Preload("User").
+		Where("token = ? AND status = ?", token, 1).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&share).Error
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// 将商品加入用户购物车，已存在时累加数量，数量不超过库存和限购剩余的数量
+func addProductToCart(tx *gorm.DB, userID uint, product *Product, quantity int) (int, error) {
+	maxQuantity := product.orderableStock()
+	if product.MaxPerUser > 0 {
+		purchased, err := purchasedQuantities(tx, userID, []uint{product.ID})
+		if err != nil {
+			return 0, err
+		}
+		if remaining := product.MaxPerUser - purchased[product.ID]; remaining < maxQuantity {
+			maxQuantity = remaining
+		}
+	}
+
+	var item CartItem
+	if err := tx.Where("user_id = ? AND product_id = ?", userID, product.ID).First(&item).Error; err == nil {
+		newQuantity := item.Quantity + quantity
+		if newQuantity > maxQuantity {
+			newQuantity = maxQuantity
+		}
+		if newQuantity <= item.Quantity {
+			return 0, nil
+		}
+		return newQuantity - item.Quantity, tx.Model(&item).Updates(map[string]interface{}{"quantity": newQuantity, "price": product.Price}).Error
+	}
+
+	if quantity > maxQuantity {
+		quantity = maxQuantity
+	}
+	if quantity <= 0 {
+		return 0, nil
+	}
+	if err := checkCartSize(tx, userID); err != nil {
+		return 0, err
+	}
+	return quantity, tx.Create(&CartItem{UserID: userID, ProductID: product.ID, Quantity: quantity, Price: product.Price}).Error
+}
+
+// CreateCartShare 创建购物车分享链接
+// @Summary 创建购物车分享链接
+// @Description 将购物车中选中的商品（默认全部）生成只读分享链接，可设置有效期、可见范围以及是否展示分享人
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param share body CreateCartShareRequest true "分享设置"
+// @Success 200 {object} ApiResponse{data=CartShare} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或购物车为空"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/shares [post]
+func CreateCartShare(c *gin.Context) {
+	var req CreateCartShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	query := DB.Where("user_id = ?", userID)
+	if len(req.CartItemIDs) > 0 {
+		query = query.Where("id IN ?", req.CartItemIDs)
+	}
+	var cartItems []CartItem
+	if err := query.Find(&cartItems).Error; err != nil {
+		InternalServerError(c, "购物车查询失败")
+		return
+	}
+	if len(cartItems) == 0 {
+		BadRequestError(c, "没有可分享的购物车商品")
+		return
+	}
+
+	expireHours := req.ExpireHours
+	if expireHours == 0 {
+		expireHours = defaultShareExpireHours
+	}
+	if expireHours > maxShareExpireHours {
+		BadRequestError(c, fmt.Sprintf("分享有效期最长为 %d 小时", maxShareExpireHours))
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(expireHours) * time.Hour)
+
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = ShareVisibilityPublic
+	}
+
+	token, err := generateSecureToken(16)
+	if err != nil {
+		InternalServerError(c, "分享链接生成失败")
+		return
+	}
+
+	share := CartShare{
+		Token:      token,
+		UserID:     userID.(uint),
+		Title:      req.Title,
+		Note:       req.Note,
+		Visibility: visibility,
+		ShowOwner:  req.ShowOwner,
+		ExpiresAt:  &expiresAt,
+		Status:     1,
+	}
+	for _, item := range cartItems {
+		share.Items = append(share.Items, CartShareItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	if err := DB.Create(&share).Error; err != nil {
+		InternalServerError(c, "分享链接创建失败")
+		return
+	}
+
+	DB.Preload("Items.Product").First(&share, share.ID)
+	SuccessResponse(c, share)
+}
+
+// GetMyCartShares 获取我的分享链接
+// @Summary 获取我的分享链接
+// @Description 获取当前用户创建的购物车分享链接，包括已过期和已关闭的链接
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]CartShare} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/shares [get]
+func GetMyCartShares(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var shares []CartShare
+	if err := DB.Preload("Items.Product").Where("user_id = ?", userID).Order("created_at DESC").Find(&shares).Error; err != nil {
+		InternalServerError(c, "分享链接查询失败")
+		return
+	}
+
+	SuccessResponse(c, shares)
+}
+
+// RevokeCartShare 关闭分享链接
+// @Summary 关闭分享链接
+// @Description 关闭指定的购物车分享链接，关闭后他人无法再访问
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param id path int true "分享ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "关闭成功"
+// @Failure 400 {object} ApiResponse "无效的分享ID"
+// @Failure 404 {object} ApiResponse "分享链接不存在"
+// @Security Bearer
+// @Router /api/cart/shares/{id} [delete]
+func RevokeCartShare(c *gin.Context) {
+	shareID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的分享ID")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result := DB.Model(&CartShare{}).Where("id = ? AND user_id = ?", shareID, userID).Update("status", 0)
+	if result.Error != nil {
+		InternalServerError(c, "分享链接关闭失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "分享链接不存在")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "分享链接已关闭"})
+}
+
+// GetCartShare 查看分享链接
+// @Summary 查看分享链接
+// @Description 根据分享标识查看分享的商品列表（只读），仅登录可见的链接需要携带令牌
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param token path string true "分享标识"
+// @Success 200 {object} ApiResponse{data=CartShareView} "查询成功"
+// @Failure 401 {object} ApiResponse "需要登录后查看"
+// @Failure 404 {object} ApiResponse "分享链接不存在或已过期"
+// @Router /api/shares/{token} [get]
+func GetCartShare(c *gin.Context) {
+	share, err := findActiveCartShare(c.Param("token"))
+	if err != nil {
+		NotFoundError(c, "分享链接不存在或已过期")
+		return
+	}
+
+	if share.Visibility == ShareVisibilityRegistered {
+		if _, exists := c.Get("user_id"); !exists {
+			UnauthorizedError(c, "该分享仅限登录用户查看")
+			return
+		}
+	}
+
+	DB.Model(share).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1))
+
+	view := CartShareView{
+		Title:     share.Title,
+		Note:      share.Note,
+		ExpiresAt: share.ExpiresAt,
+		Items:     share.Items,
+	}
+	if share.ShowOwner {
+		view.Owner = share.User.Username
+	}
+	for _, item := range share.Items {
+		view.TotalAmount += item.Product.Price * float64(item.Quantity)
+	}
+
+	SuccessResponse(c, view)
+}
+
+// AddSharedItemsToCart 将分享的商品加入购物车
+// @Summary 将分享的商品加入购物车
+// @Description 将分享链接中的商品（默认全部）加入当前用户的购物车，已下架商品和购物车已满时加入的新商品自动跳过，数量不超过库存
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Param token path string true "分享标识"
+// @Param items body AddSharedItemsRequest false "要加入的商品"
+// @Success 200 {object} ApiResponse{data=object{added=int,skipped=[]uint}} "加入成功"
+// @Failure 404 {object} ApiResponse "分享链接不存在或已过期"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/shares/{token}/cart [post]
+func AddSharedItemsToCart(c *gin.Context) {
+	var req AddSharedItemsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestError(c, "参数验证失败: "+err.Error())
+			return
+		}
+	}
+
+	share, err := findActiveCartShare(c.Param("token"))
+	if err != nil {
+		NotFoundError(c, "分享链接不存在或已过期")
+		return
+	}
+
+	selected := make(map[uint]bool, len(req.ProductIDs))
+	for _, productID := range req.ProductIDs {
+		selected[productID] = true
+	}
+
+	userID, _ := c.Get("user_id")
+	added := 0
+	skipped := make([]uint, 0)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range share.Items {
+			if len(selected) > 0 && !selected[item.ProductID] {
+				continue
+			}
+			if item.Product.Status != 1 {
+				skipped = append(skipped, item.ProductID)
+				continue
+			}
+			quantity, err := addProductToCart(tx, userID.(uint), &item.Product, item.Quantity)
+			if errors.Is(err, errCartFull) {
+				skipped = append(skipped, item.ProductID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if quantity == 0 {
+				skipped = append(skipped, item.ProductID)
+				continue
+			}
+			added++
+		}
+		return nil
+	})
+	invalidateCartCache(userID.(uint))
+	if err != nil {
+		InternalServerError(c, "加入购物车失败")
+		return
+	}
+	if added > 0 {
+		publishCartEvent(userID.(uint), CartEvent{Type: CartEventRefresh})
+	}
+
+	SuccessResponse(c, gin.H{"added": added, "skipped": skipped})
+}
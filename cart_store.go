@@ -0,0 +1,255 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 购物车存储方式
+const (
+	CartStorageMySQL = "mysql" // 每次读取都查询数据库
+	CartStorageRedis = "redis" // 活跃购物车缓存在Redis哈希中，写入时先写数据库再同步到Redis
+)
+
+// 哈希中标记购物车已完整加载的字段，购物车为空时也能命中缓存
+const cartLoadedField = "loaded"
+
+// 加载购物车到Redis，加载期间购物车被修改过（版本号变化）时放弃写入，避免旧数据覆盖新数据
+var fillCartScript = redis.NewScript(`
+local version = redis.call('GET', KEYS[2]) or ''
+if version ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// 写入一个购物车项，购物车未加载到Redis时只更新版本号
+var setCartItemScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+return 0
+`)
+
+// 删除一个购物车项
+var deleteCartItemScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[1], ARGV[1])
+return 0
+`)
+
+// 整个购物车失效，下次读取时从数据库重新加载
+var invalidateCartScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[1])
+redis.call('DEL', KEYS[1])
+return 0
+`)
+
+// Redis中保存的购物车项，商品信息读取时再关联
+type cachedCartItem struct {
+	ID        uint      `json:"id"`
+	ProductID uint      `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Price     float64   `json:"price"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func cartCacheEnabled() bool {
+	return AppConfig.CartStorage == CartStorageRedis
+}
+
+func cartCacheKeys(userID uint) []string {
+	return []string{fmt.Sprintf("cart:items:%d", userID), fmt.Sprintf("cart:version:%d", userID)}
+}
+
+func cartCacheTTLSeconds() int {
+	return AppConfig.CartCacheMinutes * 60
+}
+
+func encodeCartItem(item *CartItem) (string, error) {
+	data, err := json.Marshal(cachedCartItem{
+		ID:        item.ID,
+		ProductID: item.ProductID,
+		Quantity:  item.Quantity,
+		Price:     item.Price,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	})
+	return string(data), err
+}
+
+// 写入数据库后同步购物车项到Redis，同步失败时让整个购物车失效
+func cacheCartItem(item *CartItem) {
+	if !cartCacheEnabled() {
+		return
+	}
+	value, err := encodeCartItem(item)
+	if err == nil {
+		err = setCartItemScript.Run(CTX, RDB, cartCacheKeys(item.UserID), item.ID, value, cartCacheTTLSeconds()).Err()
+	}
+	if err != nil {
+		log.Printf("购物车缓存写入失败（用户 %d）: %v", item.UserID, err)
+		invalidateCartCache(item.UserID)
+	}
+}
+
+// 从数据库删除购物车项后同步删除Redis中的记录
+func uncacheCartItem(userID, itemID uint) {
+	if !cartCacheEnabled() {
+		return
+	}
+	if err := deleteCartItemScript.Run(CTX, RDB, cartCacheKeys(userID), itemID, cartCacheTTLSeconds()).Err(); err != nil {
+		log.Printf("购物车缓存删除失败（用户 %d）: %v", userID, err)
+		invalidateCartCache(userID)
+	}
+}
+
+// 批量修改或在事务中修改购物车后调用，让用户的购物车缓存失效
+func invalidateCartCache(userID uint) {
+	if !cartCacheEnabled() {
+		return
+	}
+	if err := invalidateCartScript.Run(CTX, RDB, cartCacheKeys(userID), cartCacheTTLSeconds()).Err(); err != nil {
+		log.Printf("购物车缓存失效失败（用户 %d）: %v", userID, err)
+	}
+}
+
+// 从Redis读取购物车项，购物车未加载时 ok 为 false
+func getCachedCartItems(userID uint) (items []CartItem, ok bool, err error) {
+	values, err := RDB.HGetAll(CTX, cartCacheKeys(userID)[0]).Result()
+	if err != nil || values[cartLoadedField] == "" {
+		return nil, false, err
+	}
+	items = make([]CartItem, 0, len(values)-1)
+	for field, value := range values {
+		if field == cartLoadedField {
+			continue
+		}
+		var cached cachedCartItem
+		if err := json.Unmarshal([]byte(value), &cached); err != nil {
+			return nil, false, err
+		}
+		items = append(items, CartItem{
+			ID:        cached.ID,
+			UserID:    userID,
+			ProductID: cached.ProductID,
+			Quantity:  cached.Quantity,
+			Price:     cached.Price,
+			CreatedAt: cached.CreatedAt,
+			UpdatedAt: cached.UpdatedAt,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, true, nil
+}
+
+// 从数据库加载购物车项并写入Redis，写入失败不影响返回结果
+func fillCartCache(userID uint) ([]CartItem, error) {
+	keys := cartCacheKeys(userID)
+	version, versionErr := RDB.Get(CTX, keys[1]).Result()
+
+	var items []CartItem
+	if err := DB.Where("user_id = ?", userID).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	// Redis不可用时直接返回数据库中的数据
+	if versionErr != nil && versionErr != redis.Nil {
+		log.Printf("购物车缓存加载失败（用户 %d）: %v", userID, versionErr)
+		return items, nil
+	}
+
+	args := []interface{}{version, cartCacheTTLSeconds(), cartLoadedField, 1}
+	for i := range items {
+		value, err := encodeCartItem(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strconv.FormatUint(uint64(items[i].ID), 10), value)
+	}
+	if err := fillCartScript.Run(CTX, RDB, keys, args...).Err(); err != nil {
+		log.Printf("购物车缓存加载失败（用户 %d）: %v", userID, err)
+	}
+	return items, nil
+}
+
+// 为购物车项关联商品信息。freshProducts 为 false 时优先使用商品缓存，
+// 需要准确库存和状态的场景（如购物车校验）传 true 直接查询数据库
+func attachCartProducts(items []CartItem, freshProducts bool) error {
+	missing := make([]uint, 0, len(items))
+	products := make(map[uint]*Product, len(items))
+	for _, item := range items {
+		if _, ok := products[item.ProductID]; ok {
+			continue
+		}
+		if !freshProducts {
+			if product, err := GetCachedProduct(item.ProductID); err == nil {
+				// 商品缓存来自详情页，购物车中不返回详情内容
+				product.ContentBlocks = nil
+				product.Attributes = nil
+				products[item.ProductID] = product
+				continue
+			}
+		}
+		products[item.ProductID] = nil
+		missing = append(missing, item.ProductID)
+	}
+
+	if len(missing) > 0 {
+		var loaded []Product
+		if err := DB.Where("id IN ?", missing).Find(&loaded).Error; err != nil {
+			return err
+		}
+		for i := range loaded {
+			products[loaded[i].ID] = &loaded[i]
+		}
+	}
+
+	// 商品已删除时保持零值，与预加载的结果一致
+	for i := range items {
+		if product := products[items[i].ProductID]; product != nil {
+			items[i].Product = *product
+		}
+	}
+	return nil
+}
+
+// 读取用户的购物车项（含商品信息），按ID排序。
+// CART_STORAGE=redis 时从Redis读取，未命中时从数据库加载并写入Redis
+func loadCartItems(userID uint, freshProducts bool) ([]CartItem, error) {
+	if !cartCacheEnabled() {
+		var items []CartItem
+		err := DB.Preload("Product").Where("user_id = ?", userID).Order("id").Find(&items).Error
+		return items, err
+	}
+
+	items, ok, err := getCachedCartItems(userID)
+	if err != nil {
+		log.Printf("购物车缓存读取失败（用户 %d）: %v", userID, err)
+	}
+	if !ok {
+		if items, err = fillCartCache(userID); err != nil {
+			return nil, err
+		}
+	}
+	if err := attachCartProducts(items, freshProducts); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
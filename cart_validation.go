@@ -0,0 +1,119 @@
+package gomall
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 购物车商品提醒
+const (
+	CartWarningPriceChanged      = "price_changed"      // 价格与加入购物车时不同
+	CartWarningOutOfStock        = "out_of_stock"       // 已售罄
+	CartWarningInsufficientStock = "insufficient_stock" // 库存少于购物车中的数量
+	CartWarningDelisted          = "delisted"           // 商品已下架或已删除
+	CartWarningPurchaseLimit     = "purchase_limit"     // 超出每人限购数量
+)
+
+// CartWarning 购物车商品提醒
+type CartWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CartValidationItem 购物车商品的校验结果
+type CartValidationItem struct {
+	CartItemID     uint          `json:"cart_item_id"`
+	ProductID      uint          `json:"product_id"`
+	ProductName    string        `json:"product_name"`
+	Quantity       int           `json:"quantity"`
+	AddedPrice     float64       `json:"added_price"`     // 加入购物车时的单价，早期加入的商品为0
+	CurrentPrice   float64       `json:"current_price"`   // 当前单价，下单时按此价格结算
+	AvailableStock int           `json:"available_stock"` // 当前可购买数量
+	Warnings       []CartWarning `json:"warnings"`
+}
+
+// CartValidationResult 购物车校验结果
+type CartValidationResult struct {
+	Valid       bool                 `json:"valid"` // 没有会导致下单失败的问题（价格变化不影响下单）
+	Items       []CartValidationItem `json:"items"`
+	TotalAmount float64              `json:"total_amount"` // 按当前价格计算的可下单商品金额
+}
+
+// 校验单个购物车商品，返回提醒和是否会导致下单失败
+func validateCartItem(userID uint, item *CartItem) (CartValidationItem, bool) {
+	product := &item.Product
+	result := CartValidationItem{
+		CartItemID:     item.ID,
+		ProductID:      item.ProductID,
+		ProductName:    product.Name,
+		Quantity:       item.Quantity,
+		AddedPrice:     item.Price,
+		CurrentPrice:   product.Price,
+		AvailableStock: product.orderableStock(),
+		Warnings:       []CartWarning{},
+	}
+	if product.ID == 0 || product.Status != 1 {
+		result.AvailableStock = 0
+		result.Warnings = append(result.Warnings, CartWarning{Code: CartWarningDelisted, Message: "商品已下架"})
+		return result, false
+	}
+
+	ok := true
+	if item.Price > 0 && item.Price != product.Price {
+		message := fmt.Sprintf("商品已降价 %.2f 元，当前价格 %.2f 元", item.Price-product.Price, product.Price)
+		if product.Price > item.Price {
+			message = fmt.Sprintf("商品已涨价 %.2f 元，当前价格 %.2f 元", product.Price-item.Price, product.Price)
+		}
+		result.Warnings = append(result.Warnings, CartWarning{Code: CartWarningPriceChanged, Message: message})
+	}
+	if result.AvailableStock <= 0 {
+		result.Warnings = append(result.Warnings, CartWarning{Code: CartWarningOutOfStock, Message: "商品已售罄"})
+		ok = false
+	} else if result.AvailableStock < item.Quantity {
+		message := fmt.Sprintf("库存不足，当前可购买数量: %d", result.AvailableStock)
+		result.Warnings = append(result.Warnings, CartWarning{Code: CartWarningInsufficientStock, Message: message})
+		ok = false
+	}
+	if err := checkPurchaseLimits(DB, userID, map[uint]int{product.ID: item.Quantity}); err != nil {
+		result.Warnings = append(result.Warnings, CartWarning{Code: CartWarningPurchaseLimit, Message: err.Error()})
+		ok = false
+	}
+	return result, ok
+}
+
+// ValidateCart 校验购物车
+// @Summary 校验购物车
+// @Description 按当前数据重新检查购物车中每个商品的价格、库存和上架状态，返回逐项提醒：price_changed 价格与加入时不同（下单按当前价格结算，不影响下单）、out_of_stock 已售罄、insufficient_stock 库存少于购物车数量、delisted 已下架、purchase_limit 超出每人限购。前端可在结算前提示用户，避免下单时才失败
+// @Tags 购物车管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=CartValidationResult} "校验完成"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/cart/validate [get]
+func ValidateCart(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	// 需要准确的库存和上架状态，商品信息不使用缓存
+	cartItems, err := loadCartItems(userID, true)
+	if err != nil {
+		InternalServerError(c, "购物车查询失败")
+		return
+	}
+
+	result := CartValidationResult{Valid: true, Items: make([]CartValidationItem, 0, len(cartItems))}
+	var total int64
+	for i := range cartItems {
+		item, ok := validateCartItem(userID, &cartItems[i])
+		if ok {
+			total += toCents(item.CurrentPrice) * int64(item.Quantity)
+		} else {
+			result.Valid = false
+		}
+		result.Items = append(result.Items, item)
+	}
+	result.TotalAmount = fromCents(total)
+
+	SuccessResponse(c, result)
+}
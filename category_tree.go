@@ -0,0 +1,203 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 分类层级的最大深度，防止数据异常时无限循环
+const categoryMaxDepth = 64
+
+// MoveCategoryRequest 移动分类
+type MoveCategoryRequest struct {
+	ParentID uint `json:"parent_id"` // 新的父分类ID，0表示移到顶级
+}
+
+// MergeCategoryRequest 合并分类
+type MergeCategoryRequest struct {
+	TargetID uint `json:"target_id" binding:"required"` // 合并到的分类ID
+}
+
+// 判断 categoryID 是否为 ancestorID 本身或其子孙分类
+func isCategoryInSubtree(categoryID, ancestorID uint) (bool, error) {
+	current := categoryID
+	for depth := 0; current != 0 && depth < categoryMaxDepth; depth++ {
+		if current == ancestorID {
+			return true, nil
+		}
+		var category Category
+		if err := DB.Select("id, parent_id").First(&category, current).Error; err != nil {
+			return false, err
+		}
+		current = category.ParentID
+	}
+	if current != 0 {
+		return false, fmt.Errorf("分类层级超过%d层", categoryMaxDepth)
+	}
+	return false, nil
+}
+
+// 校验把分类移到新父分类下不会形成循环
+func checkCategoryParent(categoryID, parentID uint) error {
+	if parentID == 0 {
+		return nil
+	}
+	inSubtree, err := isCategoryInSubtree(parentID, categoryID)
+	if err != nil {
+		return fmt.Errorf("父分类不存在")
+	}
+	if inSubtree {
+		return fmt.Errorf("不能移动到自身或其子分类下")
+	}
+	return nil
+}
+
+// 分类调整后清除分类缓存，以及商品详情（含分类信息）和商品列表缓存
+func invalidateCategoryCaches(productIDs []uint) {
+	DeleteCachedCategories()
+	for _, productID := range productIDs {
+		DeleteCachedProduct(productID)
+	}
+	for _, pattern := range []string{"products:list:*", "products:hot:*"} {
+		if keys, _ := RDB.Keys(CTX, pattern).Result(); len(keys) > 0 {
+			RDB.Del(CTX, keys...)
+		}
+	}
+}
+
+func loadCategoryParam(c *gin.Context) (*Category, bool) {
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的分类ID")
+		return nil, false
+	}
+	var category Category
+	if err := DB.Where("status = ?", 1).First(&category, categoryID).Error; err != nil {
+		NotFoundError(c, "分类不存在")
+		return nil, false
+	}
+	return &category, true
+}
+
+// MoveCategory 移动分类
+// @Summary 移动分类
+// @Description 将分类连同其所有子分类移到新的父分类下，不能移到自身或其子分类下；parent_id 为0时移到顶级
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "分类ID"
+// @Param move body MoveCategoryRequest true "新的父分类"
+// @Success 200 {object} ApiResponse{data=Category} "移动成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或会形成循环"
+// @Failure 404 {object} ApiResponse "分类或父分类不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/categories/{id}/move [put]
+func MoveCategory(c *gin.Context) {
+	var req MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	category, ok := loadCategoryParam(c)
+	if !ok {
+		return
+	}
+
+	if req.ParentID > 0 {
+		var parent Category
+		if err := DB.Where("status = ?", 1).First(&parent, req.ParentID).Error; err != nil {
+			NotFoundError(c, "父分类不存在")
+			return
+		}
+	}
+	if err := checkCategoryParent(category.ID, req.ParentID); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	if err := DB.Model(category).Update("parent_id", req.ParentID).Error; err != nil {
+		InternalServerError(c, "分类移动失败")
+		return
+	}
+	DB.First(category, category.ID)
+	invalidateCategoryCaches(nil)
+
+	SuccessResponse(c, category)
+}
+
+// MergeCategory 合并分类
+// @Summary 合并分类
+// @Description 将分类下的全部商品和子分类转移到目标分类，然后禁用原分类；目标分类不能是原分类本身或其子分类
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "被合并的分类ID"
+// @Param merge body MergeCategoryRequest true "目标分类"
+// @Success 200 {object} ApiResponse{data=object{target=Category,moved_products=int,moved_children=int}} "合并成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或目标分类无效"
+// @Failure 404 {object} ApiResponse "分类或目标分类不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/categories/{id}/merge [post]
+func MergeCategory(c *gin.Context) {
+	var req MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	source, ok := loadCategoryParam(c)
+	if !ok {
+		return
+	}
+
+	var target Category
+	if err := DB.Where("status = ?", 1).First(&target, req.TargetID).Error; err != nil {
+		NotFoundError(c, "目标分类不存在")
+		return
+	}
+	inSubtree, err := isCategoryInSubtree(target.ID, source.ID)
+	if err != nil {
+		InternalServerError(c, "分类层级查询失败")
+		return
+	}
+	if inSubtree {
+		BadRequestError(c, "不能合并到自身或其子分类")
+		return
+	}
+
+	var productIDs []uint
+	var movedChildren int64
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Product{}).Where("category_id = ?", source.ID).Pluck("id", &productIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Product{}).Where("category_id = ?", source.ID).
+			Update("category_id", target.ID).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&Category{}).Where("parent_id = ? AND status = ?", source.ID, 1).
+			Update("parent_id", target.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		movedChildren = result.RowsAffected
+		return tx.Model(source).Update("status", 0).Error
+	})
+	if err != nil {
+		InternalServerError(c, "分类合并失败")
+		return
+	}
+	invalidateCategoryCaches(productIDs)
+
+	SuccessResponse(c, gin.H{
+		"target":         target,
+		"moved_products": len(productIDs),
+		"moved_children": movedChildren,
+	})
+}
@@ -0,0 +1,8 @@
+// GoMall 独立服务入口，子命令（anonymize、backup）与服务启动均由 gomall.Main 处理
+package main
+
+import gomall "GoMall"
+
+func main() {
+	gomall.Main()
+}
@@ -0,0 +1,259 @@
+package gomall
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 货到付款结算状态
+const (
+	CODStatusCollected = "collected" // 配送员已收款
+	CODStatusSettled   = "settled"   // 已交回平台
+)
+
+type CollectCODRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"` // 实收金额，必须与订单金额一致
+	Note   string  `json:"note"`
+}
+
+type SettleCODRequest struct {
+	Note string `json:"note"`
+}
+
+// CheckCODEligibility 检查订单是否可以使用货到付款
+func CheckCODEligibility(amount float64, shippingAddress string) error {
+	if !AppConfig.CODEnabled {
+		return fmt.Errorf("暂不支持货到付款")
+	}
+	if AppConfig.CODMaxAmount > 0 && amount > AppConfig.CODMaxAmount {
+		return fmt.Errorf("订单金额超过 %.2f 元，不支持货到付款", AppConfig.CODMaxAmount)
+	}
+
+	regions := splitEnvList(AppConfig.CODRegions)
+	if len(regions) == 0 {
+		return nil
+	}
+	address := strings.TrimSpace(shippingAddress)
+	for _, region := range regions {
+		if strings.HasPrefix(address, region) {
+			return nil
+		}
+	}
+	return fmt.Errorf("该收货地区暂不支持货到付款")
+}
+
+// GetCODEligibility 查询货到付款可用性
+// @Summary 查询货到付款可用性
+// @Description 结算页根据订单金额和收货地址查询是否可以选择货到付款
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param amount query number true "订单金额"
+// @Param shipping_address query string true "收货地址"
+// @Success 200 {object} ApiResponse{data=object{eligible=bool,reason=string}} "查询成功"
+// @Failure 400 {object} ApiResponse "参数错误"
+// @Security Bearer
+// @Router /api/orders/cod-eligibility [get]
+func GetCODEligibility(c *gin.Context) {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil || amount <= 0 {
+		BadRequestError(c, "无效的订单金额")
+		return
+	}
+
+	result := gin.H{"eligible": true}
+	if err := validateOrderAmount(amount); err != nil {
+		result = gin.H{"eligible": false, "reason": err.Error()}
+	} else if err := CheckCODEligibility(amount, c.Query("shipping_address")); err != nil {
+		result = gin.H{"eligible": false, "reason": err.Error()}
+	}
+
+	SuccessResponse(c, result)
+}
+
+// CollectCODPayment 货到付款收款
+// @Summary 货到付款收款
+// @Description 配送员或管理员登记货到付款订单的现金收款，金额须与订单金额一致，登记后订单标记为已送达
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param payment body CollectCODRequest true "收款信息"
+// @Success 200 {object} ApiResponse{data=CODSettlement} "登记成功"
+// @Failure 400 {object} ApiResponse "非货到付款订单、订单状态不符或金额不一致"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单已登记收款"
+// @Security Bearer
+// @Router /api/delivery/orders/{id}/cod-collect [post]
+func CollectCODPayment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	var req CollectCODRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var order Order
+	if err := DB.First(&order, orderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+	if order.PaymentMethod != PaymentMethodCOD {
+		BadRequestError(c, "该订单不是货到付款订单")
+		return
+	}
+	if order.Status != OrderStatusShipped {
+		BadRequestError(c, "只有已发货的订单可以登记收款")
+		return
+	}
+	if math.Abs(req.Amount-order.TotalAmount) >= 0.01 {
+		BadRequestError(c, fmt.Sprintf("实收金额与订单金额 %.2f 元不一致", order.TotalAmount))
+		return
+	}
+
+	var existing int64
+	DB.Model(&CODSettlement{}).Where("order_id = ?", order.ID).Count(&existing)
+	if existing > 0 {
+		ConflictError(c, "该订单已登记收款")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	settlement := CODSettlement{
+		OrderID:     order.ID,
+		Amount:      order.TotalAmount,
+		Status:      CODStatusCollected,
+		CollectedBy: userID.(uint),
+		CollectedAt: time.Now(),
+		Note:        req.Note,
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&settlement).Error; err != nil {
+			return err
+		}
+		if err := transitionOrder(tx, &order, OrderStatusDelivered, orderActorFromContext(c), "货到付款已收款"); err != nil {
+			return err
+		}
+		// 货到付款订单在收款后发放积分
+		return awardOrderPoints(tx, &order)
+	})
+	if err != nil {
+		orderTransitionError(c, err, "收款登记失败")
+		return
+	}
+
+	SendNotification(order.UserID, NotificationTypeOrder, "订单已签收",
+		fmt.Sprintf("您的货到付款订单 %s 已完成收款 %.2f 元，感谢您的购买。", order.OrderNo, order.TotalAmount))
+
+	SuccessResponse(c, settlement)
+}
+
+// GetCODSettlements 获取货到付款结算列表（管理端）
+// @Summary 获取货到付款结算列表
+// @Description 分页获取货到付款收款记录，可按状态筛选待交回平台的款项
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param status query string false "结算状态：collected、settled"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]CODSettlement}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/cod-settlements [get]
+func GetCODSettlements(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&CODSettlement{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var settlements []CODSettlement
+	offset := (page - 1) * pageSize
+	if err := query.Order("collected_at DESC").Limit(pageSize).Offset(offset).Find(&settlements).Error; err != nil {
+		InternalServerError(c, "结算记录查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, settlements, total, page, pageSize)
+}
+
+// SettleCODPayment 确认货到付款款项已交回
+// @Summary 确认货到付款款项已交回
+// @Description 管理员确认配送员代收的货款已交回平台，完成结算
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "结算记录ID"
+// @Param settlement body SettleCODRequest false "备注"
+// @Success 200 {object} ApiResponse{data=CODSettlement} "结算成功"
+// @Failure 400 {object} ApiResponse "结算记录状态不允许结算"
+// @Failure 404 {object} ApiResponse "结算记录不存在"
+// @Security Bearer
+// @Router /api/admin/cod-settlements/{id}/settle [put]
+func SettleCODPayment(c *gin.Context) {
+	settlementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的结算记录ID")
+		return
+	}
+
+	var req SettleCODRequest
+	c.ShouldBindJSON(&req)
+
+	var settlement CODSettlement
+	if err := DB.First(&settlement, settlementID).Error; err != nil {
+		NotFoundError(c, "结算记录不存在")
+		return
+	}
+	if settlement.Status != CODStatusCollected {
+		BadRequestError(c, "该款项已结算")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	updates := map[string]interface{}{
+		"status":     CODStatusSettled,
+		"settled_by": userID,
+		"settled_at": time.Now(),
+	}
+	if req.Note != "" {
+		updates["note"] = req.Note
+	}
+	if err := DB.Model(&settlement).Updates(updates).Error; err != nil {
+		InternalServerError(c, "结算失败")
+		return
+	}
+
+	DB.First(&settlement, settlement.ID)
+	SuccessResponse(c, settlement)
+}
@@ -0,0 +1,348 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 专题请求结构体
+type CreateCollectionRequest struct {
+	Slug        string `json:"slug" binding:"required,min=1,max=100"`
+	Title       string `json:"title" binding:"required,min=1,max=200"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+type UpdateCollectionRequest struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	SortOrder   *int   `json:"sort_order,omitempty"`
+	Status      *int   `json:"status,omitempty"`
+}
+
+type SetCollectionProductsRequest struct {
+	ProductIDs []uint `json:"product_ids" binding:"required"` // 按展示顺序排列
+}
+
+// CollectionDetail 专题详情响应
+type CollectionDetail struct {
+	Collection
+	Products []Product `json:"products"`
+}
+
+// 专题缓存管理
+func collectionCacheKey(slug string) string {
+	return "collections:" + slug
+}
+
+func CacheCollection(detail *CollectionDetail) error {
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	return RDB.Set(CTX, collectionCacheKey(detail.Slug), data, AppConfig.CollectionCache.Expiration()).Err()
+}
+
+func GetCachedCollection(slug string) (*CollectionDetail, error) {
+	data, err := RDB.Get(CTX, collectionCacheKey(slug)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var detail CollectionDetail
+	err = json.Unmarshal([]byte(data), &detail)
+	return &detail, err
+}
+
+func DeleteCachedCollection(slug string) error {
+	return RDB.Del(CTX, collectionCacheKey(slug)).Err()
+}
+
+// GetCollection 获取专题商品
+// @Summary 获取专题商品
+// @Description 根据专题标识获取运营配置的有序商品列表，用于首页等栏目展示
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Param slug path string true "专题标识"
+// @Success 200 {object} ApiResponse{data=CollectionDetail} "查询成功"
+// @Failure 404 {object} ApiResponse "专题不存在"
+// @Router /api/collections/{slug} [get]
+func GetCollection(c *gin.Context) {
+	slug := c.Param("slug")
+
+	// 尝试从缓存获取
+	if detail, err := GetCachedCollection(slug); err == nil {
+		SuccessResponse(c, detail)
+		return
+	}
+
+	var collection Collection
+	if err := DB.Where("slug = ? AND status = ?", slug, 1).First(&collection).Error; err != nil {
+		NotFoundError(c, "专题不存在")
+		return
+	}
+
+	// 按运营配置顺序查询上架商品
+	var items []CollectionItem
+	err := DB.Preload("Product.Category").
+		Joins("JOIN products ON products.id = collection_items.product_id").
+		Where("collection_items.collection_id = ? AND products.status = ?", collection.ID, 1).
+		Order("collection_items.position ASC").
+		Find(&items).Error
+	if err != nil {
+		InternalServerError(c, "专题商品查询失败")
+		return
+	}
+
+	detail := CollectionDetail{Collection: collection, Products: make([]Product, 0, len(items))}
+	for _, item := range items {
+		detail.Products = append(detail.Products, item.Product)
+	}
+
+	// 缓存专题
+	CacheCollection(&detail)
+
+	SuccessResponse(c, detail)
+}
+
+// GetCollections 获取专题列表（管理端）
+// @Summary 获取专题列表
+// @Description 获取全部专题，包括已停用的专题
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Collection} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/collections [get]
+func GetCollections(c *gin.Context) {
+	var collections []Collection
+	if err := DB.Order("sort_order ASC, id ASC").Find(&collections).Error; err != nil {
+		InternalServerError(c, "专题查询失败")
+		return
+	}
+	SuccessResponse(c, collections)
+}
+
+// CreateCollection 创建专题
+// @Summary 创建专题
+// @Description 创建新的商品专题
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Param collection body CreateCollectionRequest true "专题信息"
+// @Success 200 {object} ApiResponse{data=Collection} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 409 {object} ApiResponse "专题标识已存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/collections [post]
+func CreateCollection(c *gin.Context) {
+	var req CreateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var count int64
+	DB.Model(&Collection{}).Where("slug = ?", req.Slug).Count(&count)
+	if count > 0 {
+		ConflictError(c, "专题标识已存在")
+		return
+	}
+
+	collection := Collection{
+		Slug:        req.Slug,
+		Title:       req.Title,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+		Status:      1,
+	}
+	if err := DB.Create(&collection).Error; err != nil {
+		InternalServerError(c, "专题创建失败")
+		return
+	}
+
+	SuccessResponse(c, collection)
+}
+
+// UpdateCollection 更新专题
+// @Summary 更新专题
+// @Description 更新专题的标题、描述、排序和状态
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Param id path int true "专题ID"
+// @Param collection body UpdateCollectionRequest true "更新的专题信息"
+// @Success 200 {object} ApiResponse{data=Collection} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "专题不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/collections/{id} [put]
+func UpdateCollection(c *gin.Context) {
+	collectionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的专题ID")
+		return
+	}
+
+	var req UpdateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var collection Collection
+	if err := DB.First(&collection, collectionID).Error; err != nil {
+		NotFoundError(c, "专题不存在")
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if err := DB.Model(&collection).Updates(updates).Error; err != nil {
+		InternalServerError(c, "专题更新失败")
+		return
+	}
+
+	DB.First(&collection, collectionID)
+	DeleteCachedCollection(collection.Slug)
+
+	SuccessResponse(c, collection)
+}
+
+// DeleteCollection 删除专题
+// @Summary 删除专题
+// @Description 删除专题及其商品配置
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Param id path int true "专题ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 400 {object} ApiResponse "无效的专题ID"
+// @Failure 404 {object} ApiResponse "专题不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/collections/{id} [delete]
+func DeleteCollection(c *gin.Context) {
+	collectionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的专题ID")
+		return
+	}
+
+	var collection Collection
+	if err := DB.First(&collection, collectionID).Error; err != nil {
+		NotFoundError(c, "专题不存在")
+		return
+	}
+
+	tx := DB.Begin()
+	if err := tx.Where("collection_id = ?", collection.ID).Delete(&CollectionItem{}).Error; err != nil {
+		tx.Rollback()
+		InternalServerError(c, "专题删除失败")
+		return
+	}
+	if err := tx.Delete(&collection).Error; err != nil {
+		tx.Rollback()
+		InternalServerError(c, "专题删除失败")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		InternalServerError(c, "专题删除失败")
+		return
+	}
+
+	DeleteCachedCollection(collection.Slug)
+
+	SuccessResponse(c, gin.H{"message": "专题删除成功"})
+}
+
+// SetCollectionProducts 设置专题商品
+// @Summary 设置专题商品
+// @Description 按给定顺序整体替换专题下的商品列表
+// @Tags 商品专题
+// @Accept json
+// @Produce json
+// @Param id path int true "专题ID"
+// @Param products body SetCollectionProductsRequest true "有序商品ID列表"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "设置成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或商品不存在"
+// @Failure 404 {object} ApiResponse "专题不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/collections/{id}/products [put]
+func SetCollectionProducts(c *gin.Context) {
+	collectionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的专题ID")
+		return
+	}
+
+	var req SetCollectionProductsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var collection Collection
+	if err := DB.First(&collection, collectionID).Error; err != nil {
+		NotFoundError(c, "专题不存在")
+		return
+	}
+
+	// 检查商品是否存在
+	seen := make(map[uint]bool)
+	for _, productID := range req.ProductIDs {
+		if seen[productID] {
+			BadRequestError(c, fmt.Sprintf("商品 %d 重复", productID))
+			return
+		}
+		seen[productID] = true
+	}
+	var count int64
+	DB.Model(&Product{}).Where("id IN ?", req.ProductIDs).Count(&count)
+	if int(count) != len(req.ProductIDs) {
+		BadRequestError(c, "部分商品不存在")
+		return
+	}
+
+	tx := DB.Begin()
+	if err := tx.Where("collection_id = ?", collection.ID).Delete(&CollectionItem{}).Error; err != nil {
+		tx.Rollback()
+		InternalServerError(c, "专题商品设置失败")
+		return
+	}
+	for i, productID := range req.ProductIDs {
+		item := CollectionItem{CollectionID: collection.ID, ProductID: productID, Position: i}
+		if err := tx.Create(&item).Error; err != nil {
+			tx.Rollback()
+			InternalServerError(c, "专题商品设置失败")
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		InternalServerError(c, "专题商品设置失败")
+		return
+	}
+
+	DeleteCachedCollection(collection.Slug)
+
+	SuccessResponse(c, gin.H{"message": "专题商品设置成功"})
+}
@@ -1,13 +1,38 @@
-package main
+package gomall
 
 import (
 	"log"
+	"math/rand"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// CachePolicy 缓存策略
+type CachePolicy struct {
+	TTLSeconds         int // 过期时间（秒）
+	JitterPercent      int // 过期时间随机浮动的百分比，避免大量缓存同时过期
+	NegativeTTLSeconds int // 不存在的数据的缓存时间（秒），0表示不缓存
+}
+
+// Expiration 计算本次写入缓存的过期时间，在TTL上下随机浮动
+func (p CachePolicy) Expiration() time.Duration {
+	ttl := time.Duration(p.TTLSeconds) * time.Second
+	if p.JitterPercent > 0 && ttl > 0 {
+		jitter := int64(ttl) * int64(p.JitterPercent) / 100
+		ttl += time.Duration(rand.Int63n(2*jitter+1) - jitter)
+	}
+	return ttl
+}
+
+// NegativeExpiration 不存在的数据的缓存时间
+func (p CachePolicy) NegativeExpiration() time.Duration {
+	return time.Duration(p.NegativeTTLSeconds) * time.Second
+}
+
 // Config 应用配置结构体
 type Config struct {
 	// 数据库配置
@@ -22,21 +47,187 @@ type Config struct {
 	RedisPort     string
 	RedisPassword string
 
+	// 商品目录缓存策略
+	ProductCache     CachePolicy // 商品详情
+	ProductListCache CachePolicy // 商品列表、热门商品
+	CategoryCache    CachePolicy // 分类列表
+	BrandCache       CachePolicy // 品牌列表
+	CollectionCache  CachePolicy // 专题
+	RelatedCache     CachePolicy // 相关商品推荐
+
 	// JWT配置
-	JWTSecret string
+	JWTSecret                string
+	AccessTokenExpireMinutes int
+	RefreshTokenExpireHours  int
 
 	// 服务器配置
-	ServerPort string
-	GinMode    string
+	ServerPort      string
+	GinMode         string
+	TrustedProxies  string // 可信代理IP/CIDR，逗号分隔
+	RemoteIPHeaders string // 读取客户端真实IP的请求头，逗号分隔
 
 	// 文件上传配置
 	UploadPath       string
 	MaxFileSize      int64
 	AllowedFileTypes string
+	AvatarSize       int    // 头像统一边长（像素）
+	KYCUploadPath    string // 商家入驻资料存放目录，不能放在公开访问的上传目录下
+
+	// 商品图片配置
+	ProductPlaceholderImage string // 商品没有可用图片时前台展示的占位图
+	HideImagelessProducts   bool   // 商品列表和搜索结果中隐藏没有可用图片的商品
 
 	// 缓存配置
 	CacheDefaultExpiration int
 	CacheCleanupInterval   int
+
+	// 会话安全配置
+	SessionAnomalyAction string // 会话异常处理策略：flag、challenge
+	MaxSessionsPerUser   int    // 每个用户同时有效的会话上限，0表示不限制
+
+	// 邮件配置
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// 找回密码配置
+	PasswordResetCodeTTLMinutes  int
+	PasswordResetCooldownSeconds int
+	PasswordResetHourlyLimit     int
+
+	// 短信配置
+	SMSProvider              string // 短信通道：log、aliyun、twilio
+	SMSDefaultCountryCode    string
+	AliyunSMSAccessKeyID     string
+	AliyunSMSAccessKeySecret string
+	AliyunSMSSignName        string
+	AliyunSMSTemplateCode    string
+	TwilioAccountSID         string
+	TwilioAuthToken          string
+	TwilioFromNumber         string
+
+	// 短信验证码登录配置
+	SMSCodeTTLMinutes      int
+	SMSCodeCooldownSeconds int
+	SMSCodeDailyLimit      int // 同一手机号每日发送上限
+
+	// 验证码配置
+	CaptchaProvider           string // 验证码通道：image、hcaptcha、turnstile、none
+	CaptchaSiteKey            string
+	CaptchaSecret             string
+	LoginCaptchaAfterFailures int // 连续登录失败多少次后要求验证码
+
+	// 下单金额与货到付款配置
+	OrderMinAmount float64 // 单笔订单最低金额，0表示不限制
+	OrderMaxAmount float64 // 单笔订单最高金额，0表示不限制
+	CODEnabled     bool
+	CODMaxAmount   float64 // 货到付款订单金额上限，0表示不限制
+	CODRegions     string  // 支持货到付款的地区（按收货地址前缀匹配），逗号分隔，为空表示全部地区
+
+	// 运费配置
+	ShippingBaseFee        float64 // 基础运费，0表示不收取
+	FreeShippingThreshold  float64 // 商品金额满多少包邮（超大件附加费除外），0表示不包邮
+	ShippingAirOnlyRegions string  // 只能空运到达的地区（按收货地址前缀匹配），逗号分隔，禁止空运的商品不能发往这些地区
+
+	// 税费配置
+	TaxRate float64 // 按商品金额加收的税率，如 0.13；商品价格已含税时为0
+
+	// 发票配置
+	InvoiceSellerName      string // 发票上的销售方名称
+	InvoiceSellerTaxNumber string // 销售方纳税人识别号
+
+	// 支付配置
+	PaymentChannels      string // 启用的支付渠道，逗号分隔：mock、stripe
+	PaymentMockSecret    string // 模拟支付回调的签名密钥，为空时拒绝所有模拟支付回调
+	StripeSecretKey      string
+	StripeWebhookSecret  string // Stripe Webhook 签名密钥（whsec_开头）
+	StripeCurrency       string // Stripe 结算币种，如 cny、usd
+	PaymentQuerySeconds  int    // 查询支付状态时向支付渠道主动查询的最小间隔（秒），同一支付单在间隔内只查询一次
+	PaymentReconcileHour int    // 每天对账前一天支付的时刻（0-23点）
+
+	// 运营日报配置
+	DigestRecipients  string // 收件人及订阅栏目，格式：a@x.com:orders,revenue;b@x.com
+	DigestSendHour    int    // 每天发送时刻（0-23点）
+	LowStockThreshold int    // 低库存阈值
+
+	// 订单履约时效配置
+	OrderShipSLAHours     int // 已支付订单的发货时限（小时）
+	OrderDeliverySLAHours int // 已发货订单的送达时限（小时）
+
+	// 虚拟商品配置
+	DigitalDownloadExpireHours int    // 下载链接有效期（小时），0表示不过期
+	DigitalDownloadHosts       string // 允许转发的下载地址域名，逗号分隔，以 . 开头时匹配所有子域名；为空时不允许设置下载地址
+
+	// 库存同步配置
+	StockReconcileMinutes   int // Redis可售库存与数据库库存对账的间隔（分钟）
+	StockReservationMinutes int // 在线支付订单预占库存的时长（分钟），超时未付款自动取消订单，0表示不过期
+
+	// 购物车配置
+	MaxCartItems      int    // 每个用户购物车中最多的商品种数，0表示不限制
+	StaleCartItemDays int    // 商品下架或删除超过该天数后自动从购物车移除，0表示不清理
+	CartStorage       string // 购物车读取方式：mysql 直接查询数据库，redis 缓存活跃购物车（写入仍以数据库为准）
+	CartCacheMinutes  int    // Redis中购物车的过期时间（分钟），每次修改后重新计时
+
+	// 外部仓储系统（WMS）同步配置
+	WMSWebhookURL    string // 库存变动推送地址，为空时不推送
+	WMSWebhookSecret string // 推送和回调签名使用的密钥，为空时拒绝所有回调
+	WMSPushSeconds   int    // 库存变动推送间隔（秒）
+
+	// 商品热度配置
+	ViewCountFlushSeconds int     // 浏览量从Redis写回数据库的间隔（秒）
+	HotProductsWindowDays int     // 按浏览量或热度排序时默认统计的天数
+	PopularityViewWeight  float64 // 热度分中每次浏览的权重
+	PopularitySalesWeight float64 // 热度分中每件销量的权重
+
+	// 商品搜索配置
+	SearchPriceRanges    string  // 搜索结果价格区间聚合的分界点，逗号分隔
+	SearchNameMatchBoost float64 // 商品名称命中搜索词的加分
+	SearchSalesWeight    float64 // 销量加分权重，按 ln(1+销量) 计算
+	SearchInStockBoost   float64 // 有库存商品的加分
+	SearchNewnessBoost   float64 // 新品加分，随上架天数线性递减
+	SearchNewnessDays    int     // 新品加分的持续天数，0表示不加分
+
+	// 客户价值配置
+	RFMComputeHour   int     // 每晚计算RFM评分的时刻（0-23点）
+	CustomerLTVYears float64 // 估算生命周期价值时的预计客户生命周期（年）
+
+	// 会员积分配置
+	PointsPerYuan        float64 // 每消费1元获得的积分
+	PointsRedeemRate     int     // 抵扣1元所需的积分，0表示不支持积分抵扣
+	PointsMaxRedeemRatio float64 // 积分最多抵扣订单金额的比例
+	MemberSilverSpend    float64 // 银牌会员累计消费门槛
+	MemberGoldSpend      float64 // 金牌会员累计消费门槛
+
+	// 邀请奖励配置
+	ReferralReferrerPoints int // 被邀请人完成首单后邀请人获得的积分
+	ReferralRefereePoints  int // 被邀请人完成首单后获得的积分
+
+	// 消息屏蔽配置
+	SuppressionWebhookToken string // 服务商退信回调地址携带的令牌，为空时拒绝所有回调
+
+	// SEO配置
+	SiteBaseURL           string // 前台站点地址，用于生成sitemap中的绝对URL
+	SitemapRefreshMinutes int    // sitemap重新生成间隔（分钟）
+	RobotsAllow           string // robots.txt 允许的路径，逗号分隔
+	RobotsDisallow        string // robots.txt 禁止的路径，逗号分隔
+	RobotsDisallowAll     bool   // 禁止所有爬虫（测试环境使用）
+
+	// 维护模式配置
+	MaintenanceMode     bool   // 启动时默认开启维护模式（管理员通过接口修改后以接口设置为准）
+	MaintenanceMessage  string // 维护提示信息
+	MaintenanceAllowIPs string // 维护期间始终可访问的IP或网段，逗号分隔
+
+	// 备份配置
+	BackupDir           string // 备份存放目录
+	BackupKeep          int    // 保留最近的备份数量，0表示不清理
+	BackupIntervalHours int    // 定时备份间隔（小时），0表示不启用
+	MySQLDumpPath       string // mysqldump 可执行文件路径
+	MySQLClientPath     string // mysql 客户端可执行文件路径，恢复备份时使用
+
+	// 大宗询价配置
+	QuoteMinQuantity int // 发起询价的最小采购数量
 }
 
 // LoadConfig 加载配置
@@ -59,26 +250,212 @@ func LoadConfig() *Config {
 		RedisPort:     getEnv("REDIS_PORT", "6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 
-		// JWT密钥
-		JWTSecret: getEnv("JWT_SECRET", "gomall_jwt_secret_key_2024_very_secure"),
+		// 商品目录缓存策略
+		ProductCache:     loadCachePolicy("PRODUCT", 7200, 60),
+		ProductListCache: loadCachePolicy("PRODUCT_LIST", 600, 0),
+		CategoryCache:    loadCachePolicy("CATEGORY", 21600, 0),
+		BrandCache:       loadCachePolicy("BRAND", 21600, 0),
+		CollectionCache:  loadCachePolicy("COLLECTION", 600, 0),
+		RelatedCache:     loadCachePolicy("RELATED", 21600, 0),
+
+		// JWT配置
+		JWTSecret:                getEnv("JWT_SECRET", "gomall_jwt_secret_key_2024_very_secure"),
+		AccessTokenExpireMinutes: getEnvAsInt("ACCESS_TOKEN_EXPIRE_MINUTES", 15), // 15分钟
+		RefreshTokenExpireHours:  getEnvAsInt("REFRESH_TOKEN_EXPIRE_HOURS", 168), // 7天
 
 		// 服务器配置
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		GinMode:    getEnv("GIN_MODE", "debug"),
+		ServerPort:      getEnv("SERVER_PORT", "8080"),
+		GinMode:         getEnv("GIN_MODE", "debug"),
+		TrustedProxies:  getEnv("TRUSTED_PROXIES", "127.0.0.1,::1"),
+		RemoteIPHeaders: getEnv("REMOTE_IP_HEADERS", "X-Forwarded-For,X-Real-IP"),
 
 		// 文件上传配置
 		UploadPath:       getEnv("UPLOAD_PATH", "./upload"),
 		MaxFileSize:      getEnvAsInt64("MAX_FILE_SIZE", 10485760), // 10MB
 		AllowedFileTypes: getEnv("ALLOWED_FILE_TYPES", "jpg,jpeg,png,gif,txt,md,pdf,doc,docx"),
+		AvatarSize:       getEnvAsInt("AVATAR_SIZE", 256),
+		KYCUploadPath:    getEnv("KYC_UPLOAD_PATH", "./private/kyc"),
+
+		// 商品图片配置
+		ProductPlaceholderImage: getEnv("PRODUCT_PLACEHOLDER_IMAGE", ""),
+		HideImagelessProducts:   getEnv("HIDE_IMAGELESS_PRODUCTS", "false") == "true",
 
 		// 缓存配置
 		CacheDefaultExpiration: getEnvAsInt("CACHE_DEFAULT_EXPIRATION", 3600),   // 1小时
 		CacheCleanupInterval:   getEnvAsInt("CACHE_CLEANUP_INTERVAL", 600),     // 10分钟
+
+		// 会话安全配置
+		SessionAnomalyAction: getEnv("SESSION_ANOMALY_ACTION", "flag"),
+		MaxSessionsPerUser:   getEnvAsInt("MAX_SESSIONS_PER_USER", 5),
+
+		// 邮件配置（未配置SMTP_HOST时仅输出日志）
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "25"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "noreply@gomall.local"),
+
+		// 找回密码配置
+		PasswordResetCodeTTLMinutes:  getEnvAsInt("PASSWORD_RESET_CODE_TTL_MINUTES", 10),
+		PasswordResetCooldownSeconds: getEnvAsInt("PASSWORD_RESET_COOLDOWN_SECONDS", 60),
+		PasswordResetHourlyLimit:     getEnvAsInt("PASSWORD_RESET_HOURLY_LIMIT", 5),
+
+		// 短信配置（默认仅输出日志）
+		SMSProvider:              getEnv("SMS_PROVIDER", "log"),
+		SMSDefaultCountryCode:    getEnv("SMS_DEFAULT_COUNTRY_CODE", "+86"),
+		AliyunSMSAccessKeyID:     getEnv("ALIYUN_SMS_ACCESS_KEY_ID", ""),
+		AliyunSMSAccessKeySecret: getEnv("ALIYUN_SMS_ACCESS_KEY_SECRET", ""),
+		AliyunSMSSignName:        getEnv("ALIYUN_SMS_SIGN_NAME", ""),
+		AliyunSMSTemplateCode:    getEnv("ALIYUN_SMS_TEMPLATE_CODE", ""),
+		TwilioAccountSID:         getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:          getEnv("TWILIO_AUTH_TOKEN", ""),
+		TwilioFromNumber:         getEnv("TWILIO_FROM_NUMBER", ""),
+
+		// 短信验证码登录配置
+		SMSCodeTTLMinutes:      getEnvAsInt("SMS_CODE_TTL_MINUTES", 5),
+		SMSCodeCooldownSeconds: getEnvAsInt("SMS_CODE_COOLDOWN_SECONDS", 60),
+		SMSCodeDailyLimit:      getEnvAsInt("SMS_CODE_DAILY_LIMIT", 10),
+
+		// 验证码配置
+		CaptchaProvider:           getEnv("CAPTCHA_PROVIDER", "image"),
+		CaptchaSiteKey:            getEnv("CAPTCHA_SITE_KEY", ""),
+		CaptchaSecret:             getEnv("CAPTCHA_SECRET", ""),
+		LoginCaptchaAfterFailures: getEnvAsInt("LOGIN_CAPTCHA_AFTER_FAILURES", 3),
+
+		// 下单金额与货到付款配置
+		OrderMinAmount: getEnvAsFloat("ORDER_MIN_AMOUNT", 0),
+		OrderMaxAmount: getEnvAsFloat("ORDER_MAX_AMOUNT", 50000),
+		CODEnabled:     getEnv("COD_ENABLED", "false") == "true",
+		CODMaxAmount:   getEnvAsFloat("COD_MAX_AMOUNT", 2000),
+		CODRegions:     getEnv("COD_REGIONS", ""),
+
+		// 运费配置
+		ShippingBaseFee:        getEnvAsFloat("SHIPPING_BASE_FEE", 0),
+		FreeShippingThreshold:  getEnvAsFloat("FREE_SHIPPING_THRESHOLD", 0),
+		ShippingAirOnlyRegions: getEnv("SHIPPING_AIR_ONLY_REGIONS", ""),
+
+		// 税费配置
+		TaxRate: getEnvAsFloat("TAX_RATE", 0),
+
+		// 发票配置
+		InvoiceSellerName:      getEnv("INVOICE_SELLER_NAME", "GoMall"),
+		InvoiceSellerTaxNumber: getEnv("INVOICE_SELLER_TAX_NUMBER", ""),
+
+		// 支付配置
+		PaymentChannels:      getEnv("PAYMENT_CHANNELS", "mock"),
+		PaymentMockSecret:    getEnv("PAYMENT_MOCK_SECRET", ""),
+		StripeSecretKey:      getEnv("STRIPE_SECRET_KEY", ""),
+		StripeWebhookSecret:  getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		StripeCurrency:       getEnv("STRIPE_CURRENCY", "cny"),
+		PaymentQuerySeconds:  getEnvAsInt("PAYMENT_QUERY_SECONDS", 5),
+		PaymentReconcileHour: getEnvAsInt("PAYMENT_RECONCILE_HOUR", 3),
+
+		// 运营日报配置
+		DigestRecipients:  getEnv("DIGEST_RECIPIENTS", ""),
+		DigestSendHour:    getEnvAsInt("DIGEST_SEND_HOUR", 8),
+		LowStockThreshold: getEnvAsInt("LOW_STOCK_THRESHOLD", 10),
+
+		// 订单履约时效配置
+		OrderShipSLAHours:     getEnvAsInt("ORDER_SHIP_SLA_HOURS", 48),
+		OrderDeliverySLAHours: getEnvAsInt("ORDER_DELIVERY_SLA_HOURS", 168),
+
+		// 虚拟商品配置
+		DigitalDownloadExpireHours: getEnvAsInt("DIGITAL_DOWNLOAD_EXPIRE_HOURS", 72),
+		DigitalDownloadHosts:       getEnv("DIGITAL_DOWNLOAD_HOSTS", ""),
+
+		// 库存同步配置
+		StockReconcileMinutes:   getEnvAsInt("STOCK_RECONCILE_MINUTES", 30),
+		StockReservationMinutes: getEnvAsInt("STOCK_RESERVATION_MINUTES", 30),
+
+		// 购物车配置
+		MaxCartItems:      getEnvAsInt("MAX_CART_ITEMS", 100),
+		StaleCartItemDays: getEnvAsInt("STALE_CART_ITEM_DAYS", 30),
+		CartStorage:       getEnv("CART_STORAGE", CartStorageMySQL),
+		CartCacheMinutes:  getEnvAsInt("CART_CACHE_MINUTES", 60),
+
+		// 外部仓储系统（WMS）同步配置
+		WMSWebhookURL:    getEnv("WMS_WEBHOOK_URL", ""),
+		WMSWebhookSecret: getEnv("WMS_WEBHOOK_SECRET", ""),
+		WMSPushSeconds:   getEnvAsInt("WMS_PUSH_SECONDS", 10),
+
+		// 商品热度配置
+		ViewCountFlushSeconds: getEnvAsInt("VIEW_COUNT_FLUSH_SECONDS", 60),
+		HotProductsWindowDays: getEnvAsInt("HOT_PRODUCTS_WINDOW_DAYS", 7),
+		PopularityViewWeight:  getEnvAsFloat("POPULARITY_VIEW_WEIGHT", 1),
+		PopularitySalesWeight: getEnvAsFloat("POPULARITY_SALES_WEIGHT", 20),
+
+		// 商品搜索配置
+		SearchPriceRanges:    getEnv("SEARCH_PRICE_RANGES", "0,50,100,200,500,1000"),
+		SearchNameMatchBoost: getEnvAsFloat("SEARCH_NAME_MATCH_BOOST", 100),
+		SearchSalesWeight:    getEnvAsFloat("SEARCH_SALES_WEIGHT", 10),
+		SearchInStockBoost:   getEnvAsFloat("SEARCH_IN_STOCK_BOOST", 50),
+		SearchNewnessBoost:   getEnvAsFloat("SEARCH_NEWNESS_BOOST", 30),
+		SearchNewnessDays:    getEnvAsInt("SEARCH_NEWNESS_DAYS", 30),
+
+		// 客户价值配置
+		RFMComputeHour:   getEnvAsInt("RFM_COMPUTE_HOUR", 2),
+		CustomerLTVYears: getEnvAsFloat("CUSTOMER_LTV_YEARS", 3),
+
+		// 会员积分配置
+		PointsPerYuan:        getEnvAsFloat("POINTS_PER_YUAN", 1),
+		PointsRedeemRate:     getEnvAsInt("POINTS_REDEEM_RATE", 100),
+		PointsMaxRedeemRatio: getEnvAsFloat("POINTS_MAX_REDEEM_RATIO", 0.5),
+		MemberSilverSpend:    getEnvAsFloat("MEMBER_SILVER_SPEND", 1000),
+		MemberGoldSpend:      getEnvAsFloat("MEMBER_GOLD_SPEND", 5000),
+
+		// 邀请奖励配置
+		ReferralReferrerPoints: getEnvAsInt("REFERRAL_REFERRER_POINTS", 500),
+		ReferralRefereePoints:  getEnvAsInt("REFERRAL_REFEREE_POINTS", 200),
+
+		// 消息屏蔽配置
+		SuppressionWebhookToken: getEnv("SUPPRESSION_WEBHOOK_TOKEN", ""),
+
+		// SEO配置
+		SiteBaseURL:           getEnv("SITE_BASE_URL", "http://localhost:8080"),
+		SitemapRefreshMinutes: getEnvAsInt("SITEMAP_REFRESH_MINUTES", 60),
+		RobotsAllow:           getEnv("ROBOTS_ALLOW", ""),
+		RobotsDisallow:        getEnv("ROBOTS_DISALLOW", "/api/,/admin/"),
+		RobotsDisallowAll:     getEnv("ROBOTS_DISALLOW_ALL", "false") == "true",
+
+		// 维护模式配置
+		MaintenanceMode:     getEnv("MAINTENANCE_MODE", "false") == "true",
+		MaintenanceMessage:  getEnv("MAINTENANCE_MESSAGE", ""),
+		MaintenanceAllowIPs: getEnv("MAINTENANCE_ALLOW_IPS", ""),
+
+		// 备份配置
+		BackupDir:           getEnv("BACKUP_DIR", "./backups"),
+		BackupKeep:          getEnvAsInt("BACKUP_KEEP", 7),
+		BackupIntervalHours: getEnvAsInt("BACKUP_INTERVAL_HOURS", 0),
+		MySQLDumpPath:       getEnv("MYSQLDUMP_PATH", "mysqldump"),
+		MySQLClientPath:     getEnv("MYSQL_CLIENT_PATH", "mysql"),
+
+		// 大宗询价配置
+		QuoteMinQuantity: getEnvAsInt("QUOTE_MIN_QUANTITY", 50),
 	}
 
 	return config
 }
 
+// splitEnvList 将逗号分隔的配置项拆分为列表，忽略空白项
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadCachePolicy 读取缓存策略：<PREFIX>_CACHE_TTL_SECONDS、<PREFIX>_CACHE_JITTER_PERCENT、<PREFIX>_CACHE_NEGATIVE_TTL_SECONDS
+func loadCachePolicy(prefix string, defaultTTLSeconds, defaultNegativeTTLSeconds int) CachePolicy {
+	return CachePolicy{
+		TTLSeconds:         getEnvAsInt(prefix+"_CACHE_TTL_SECONDS", defaultTTLSeconds),
+		JitterPercent:      getEnvAsInt(prefix+"_CACHE_JITTER_PERCENT", 10),
+		NegativeTTLSeconds: getEnvAsInt(prefix+"_CACHE_NEGATIVE_TTL_SECONDS", defaultNegativeTTLSeconds),
+	}
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -97,6 +474,16 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsFloat 获取环境变量并转换为float64，如果不存在或转换失败则返回默认值
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvAsInt64 获取环境变量并转换为int64，如果不存在或转换失败则返回默认值
 func getEnvAsInt64(key string, defaultValue int64) int64 {
 	if value := os.Getenv(key); value != "" {
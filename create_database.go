@@ -1,4 +1,4 @@
-package main
+package gomall
 
 import (
 	"database/sql"
@@ -1,4 +1,4 @@
-package main
+package gomall
 
 import (
 	"context"
@@ -21,16 +21,20 @@ var (
 
 // User 用户模型
 type User struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	Username     string    `json:"username" gorm:"type:varchar(50);uniqueIndex;not null"`
-	Email        string    `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
-	PasswordHash string    `json:"-" gorm:"type:varchar(255);not null"`
-	Phone        string    `json:"phone" gorm:"type:varchar(20)"`
-	RealName     string    `json:"real_name" gorm:"type:varchar(50)"`
-	Avatar       string    `json:"avatar" gorm:"type:varchar(255)"`
-	Status       int       `json:"status" gorm:"default:1"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Username     string         `json:"username" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Email        string         `json:"email" gorm:"type:varchar(100);uniqueIndex;not null" visible:"admin,owner" mask:"email"`
+	PasswordHash string         `json:"-" gorm:"type:varchar(255);not null"`
+	Phone        string         `json:"phone" gorm:"type:varchar(20)" visible:"admin,owner" mask:"phone"`
+	RealName     string         `json:"real_name" gorm:"type:varchar(50)" visible:"admin,owner" mask:"name"`
+	Avatar       string         `json:"avatar" gorm:"type:varchar(255)"`
+	Status       int            `json:"status" gorm:"default:1"`
+	Points       int            `json:"points" gorm:"default:0"`                                           // 积分余额
+	TotalSpent   float64        `json:"total_spent" gorm:"type:decimal(12,2);default:0"`                   // 累计消费金额，用于计算会员等级
+	Balance      float64        `json:"balance" gorm:"type:decimal(12,2);default:0" visible:"admin,owner"` // 钱包余额
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"` // 注销时间（软删除）
 }
 
 // Category 商品分类模型
@@ -44,22 +48,100 @@ type Category struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// Product 商品模型
-type Product struct {
+// Brand 商品品牌模型
+type Brand struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name" gorm:"type:varchar(200);not null"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null;index"`
+	Logo        string    `json:"logo" gorm:"type:varchar(255)"`
 	Description string    `json:"description" gorm:"type:text"`
-	Price       float64   `json:"price" gorm:"type:decimal(10,2);not null"`
-	Stock       int       `json:"stock" gorm:"default:0"`
-	CategoryID  uint      `json:"category_id"`
-	Category    Category  `json:"category" gorm:"foreignKey:CategoryID"`
-	Images      string    `json:"images" gorm:"type:json"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
 	Status      int       `json:"status" gorm:"default:1"`
-	SalesCount  int       `json:"sales_count" gorm:"default:0"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Product 商品模型
+type Product struct {
+	ID              uint                    `json:"id" gorm:"primaryKey"`
+	Name            string                  `json:"name" gorm:"type:varchar(200);not null"`
+	Description     string                  `json:"description" gorm:"type:text"`
+	Price           float64                 `json:"price" gorm:"type:decimal(10,2);not null"`
+	CostPrice       float64                 `json:"cost_price" gorm:"type:decimal(10,2);default:0" visible:"admin,owner"` // 成本价，仅管理员和商品所属商家可见
+	Stock           int                     `json:"stock" gorm:"default:0"`
+	CategoryID      uint                    `json:"category_id"`
+	Category        Category                `json:"category" gorm:"foreignKey:CategoryID"`
+	BrandID         *uint                   `json:"brand_id" gorm:"index"` // 品牌，可为空
+	Brand           *Brand                  `json:"brand,omitempty" gorm:"foreignKey:BrandID"`
+	Images          string                  `json:"images" gorm:"type:json"`
+	Tags            string                  `json:"tags" gorm:"type:varchar(700)"`                                        // 商品标签，逗号分隔
+	ShipRegions     string                  `json:"ship_regions" gorm:"type:varchar(500)"`                                // 限定配送地区（按收货地址前缀匹配），逗号分隔，为空表示全部地区
+	NoAirTransport  bool                    `json:"no_air_transport" gorm:"default:false"`                                // 禁止空运（如含锂电池），不能发往只能空运的地区
+	OversizeFee     float64                 `json:"oversize_fee" gorm:"type:decimal(10,2);default:0"`                     // 超大件附加运费（每件）
+	ProductType     string                  `json:"product_type" gorm:"type:varchar(20);default:physical"`                // 商品类型：physical 实物、digital 虚拟
+	DownloadURL     string                  `json:"download_url" gorm:"type:varchar(500)" visible:"admin,owner"`          // 虚拟商品的下载地址，仅管理员和商品所属商家可见，为空时付款后发放激活码
+	Barcode         *string                 `json:"barcode" gorm:"type:varchar(64);uniqueIndex"`                          // 条形码，为空时存为 NULL
+	Presale         bool                    `json:"presale" gorm:"default:false"`                                         // 预售商品，库存不足时仍可下单
+	PresaleQuota    int                     `json:"presale_quota" gorm:"default:0"`                                       // 超出库存后最多还能预售的数量
+	PresaleShipDate *time.Time              `json:"presale_ship_date"`                                                    // 预售商品的预计发货日期
+	MaxPerUser      int                     `json:"max_per_user" gorm:"default:0"`                                        // 每人限购数量（按历史未取消订单累计），0表示不限购
+	Status          int                     `json:"status" gorm:"default:1"`                                              // 前台是否可见，已发布时为1
+	PublishStatus   string                  `json:"publish_status" gorm:"type:varchar(20);default:published;index"`       // 生命周期：draft、pending_review、published、archived
+	SellerID        *uint                   `json:"seller_id,omitempty" gorm:"index"`                                     // 创建商品的商家，管理员创建时为空
+	ReviewNote      string                  `json:"review_note,omitempty" gorm:"type:varchar(500)" visible:"admin,owner"` // 审核意见，仅管理员和商品所属商家可见
+	PublishedAt     *time.Time              `json:"published_at"`
+	PublishAt       *time.Time              `json:"publish_at" gorm:"index"`   // 定时上架时间
+	UnpublishAt     *time.Time              `json:"unpublish_at" gorm:"index"` // 定时下架时间
+	DelistedAt      *time.Time              `json:"delisted_at,omitempty"`     // 最近一次下架或删除的时间，重新上架时清空
+	SalesCount      int                     `json:"sales_count" gorm:"default:0"`
+	ViewCount       int                     `json:"view_count" gorm:"default:0"` // 浏览量，定期从Redis写回
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	ContentBlocks   []ProductContentBlock   `json:"content_blocks,omitempty" gorm:"foreignKey:ProductID"` // 详情页内容块，仅商品详情返回
+	Attributes      []ProductAttributeValue `json:"attributes,omitempty" gorm:"foreignKey:ProductID"`     // 商品规格参数，仅商品详情返回
+	Favorited       *bool                   `json:"favorited,omitempty" gorm:"-"`                         // 当前用户是否已收藏，仅登录用户查看商品详情时返回
+	CoverImage      string                  `json:"cover_image,omitempty" gorm:"-"`                       // 列表封面图，第一张可用图片或占位图
+	ImageIssue      string                  `json:"image_issue,omitempty" gorm:"-"`                       // 图片问题：missing 没有图片、broken 图片文件不存在
+}
+
+// ProductContentBlock 商品详情页内容块，按 position 顺序展示
+type ProductContentBlock struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProductID uint      `json:"product_id" gorm:"not null;index"`
+	Position  int       `json:"position" gorm:"default:0"`
+	Type      string    `json:"type" gorm:"type:varchar(10);not null"` // 类型：text、image、video
+	Text      string    `json:"text,omitempty" gorm:"type:text"`
+	URL       string    `json:"url,omitempty" gorm:"type:varchar(500)"`
+	Poster    string    `json:"poster,omitempty" gorm:"type:varchar(500)"` // 视频封面
+	Caption   string    `json:"caption,omitempty" gorm:"type:varchar(200)"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryAttribute 分类属性定义，子分类继承上级分类的属性
+type CategoryAttribute struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CategoryID uint      `json:"category_id" gorm:"not null;index"`
+	Name       string    `json:"name" gorm:"type:varchar(50);not null"`
+	Type       string    `json:"type" gorm:"type:varchar(10);not null"` // 类型：text、number、enum
+	Options    string    `json:"options" gorm:"type:varchar(1000)"`     // enum 类型的可选值，逗号分隔
+	Unit       string    `json:"unit" gorm:"type:varchar(20)"`          // number 类型的单位，如 英寸
+	Required   bool      `json:"required" gorm:"default:false"`
+	Filterable bool      `json:"filterable" gorm:"default:true"` // 是否可用于商品列表筛选
+	SortOrder  int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ProductAttributeValue 商品属性值
+type ProductAttributeValue struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	ProductID   uint              `json:"product_id" gorm:"not null;uniqueIndex:idx_product_attribute"`
+	AttributeID uint              `json:"attribute_id" gorm:"not null;uniqueIndex:idx_product_attribute;index:idx_attribute_value"`
+	Attribute   CategoryAttribute `json:"attribute" gorm:"foreignKey:AttributeID"`
+	Value       string            `json:"value" gorm:"type:varchar(200);not null;index:idx_attribute_value"`
+	NumberValue *float64          `json:"-" gorm:"index"` // number 类型的数值，用于范围筛选
+}
+
 // CartItem 购物车项目模型
 type CartItem struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -68,32 +150,514 @@ type CartItem struct {
 	ProductID uint      `json:"product_id" gorm:"not null"`
 	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
 	Quantity  int       `json:"quantity" gorm:"not null"`
+	Price     float64   `json:"price" gorm:"type:decimal(10,2)"` // 加入购物车时的单价，用于提示降价或涨价
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SavedItem 稍后购买的商品，与购物车分开保存，清空购物车时不受影响
+type SavedItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_saved_item_user_product"`
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_saved_item_user_product"`
+	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity  int       `json:"quantity" gorm:"not null"`
+	Price     float64   `json:"price" gorm:"type:decimal(10,2)"` // 加入购物车时的单价
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Favorite 商品收藏模型
+type Favorite struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_favorite_user_product"`
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_favorite_user_product;index"`
+	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestockSubscription 到货提醒订阅，商品补货后通知一次并删除
+type RestockSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_restock_user_product"`
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_restock_user_product;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DigitalDelivery 虚拟商品交付记录，订单付款时生成，每件商品一条
+type DigitalDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	OrderID       uint       `json:"order_id" gorm:"not null;index"`
+	OrderItemID   uint       `json:"order_item_id" gorm:"not null;index"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	ProductID     uint       `json:"product_id" gorm:"not null"`
+	LicenseCode   string     `json:"license_code,omitempty" gorm:"type:varchar(64)"` // 激活码
+	DownloadToken string     `json:"-" gorm:"type:varchar(64);index"`                // 下载令牌
+	DownloadLink  string     `json:"download_link,omitempty" gorm:"-"`               // 下载链接，查询时由令牌生成
+	DownloadCount int        `json:"download_count" gorm:"default:0"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // 下载链接过期时间，为空表示不过期
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// SearchSynonym 搜索同义词组，搜索组内任意一个词时同时匹配其他词
+type SearchSynonym struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Terms     string    `json:"terms" gorm:"type:varchar(500);not null"` // 同义词，逗号分隔，统一为小写
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SearchRule 搜索干预规则，将商品在指定搜索词的结果中置顶或沉底
+type SearchRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Keyword   string    `json:"keyword" gorm:"type:varchar(50);not null;uniqueIndex:idx_search_rule_keyword_product"` // 搜索词，统一为小写
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_search_rule_keyword_product"`
+	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
+	Action    string    `json:"action" gorm:"type:varchar(10);not null"` // pin 置顶、bury 沉底
+	Position  int       `json:"position" gorm:"default:0"`               // 置顶商品之间的顺序，越小越靠前
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SavedSearch 用户保存的搜索条件，有新商品发布并匹配时通知用户
+type SavedSearch struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         uint       `json:"user_id" gorm:"not null;index"`
+	Keyword        string     `json:"keyword" gorm:"type:varchar(50);not null"` // 搜索词，统一为小写
+	CategoryID     uint       `json:"category_id" gorm:"default:0"`             // 0 表示不限
+	BrandID        uint       `json:"brand_id" gorm:"default:0"`
+	MinPrice       float64    `json:"min_price" gorm:"type:decimal(10,2);default:0"`
+	MaxPrice       float64    `json:"max_price" gorm:"type:decimal(10,2);default:0"` // 不含该价格，0 表示不限
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// StockReservation 下单时预占的库存，付款后转为实际扣减，超时未付款或取消订单时释放
+type StockReservation struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	OrderID   uint       `json:"order_id" gorm:"not null;index"`
+	ProductID uint       `json:"product_id" gorm:"not null;index:idx_reservation_product_status"`
+	Quantity  int        `json:"quantity" gorm:"not null"`
+	Status    string     `json:"status" gorm:"type:varchar(20);not null;index:idx_reservation_product_status;index:idx_reservation_status_expires"` // reserved 预占中、committed 已扣减、released 已释放
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index:idx_reservation_status_expires"`                                                            // 预占到期时间，为空表示不过期（货到付款）
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Warehouse 仓库
+type Warehouse struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"type:varchar(32);uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	Regions   string    `json:"regions" gorm:"type:varchar(500)"` // 就近发货的地区，逗号分隔，如 上海,江苏,浙江
+	Address   string    `json:"address" gorm:"type:varchar(255)"`
+	Priority  int       `json:"priority" gorm:"default:0"` // 地区都不匹配时按优先级从高到低分配
+	Status    int       `json:"status" gorm:"default:1"`   // 1 启用，0 停用（不再分配新订单）
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// InventoryRecord 商品在各仓库的库存，商品有仓库库存时 Product.Stock 为各仓库之和
+type InventoryRecord struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	WarehouseID uint      `json:"warehouse_id" gorm:"not null;uniqueIndex:idx_inventory_warehouse_product"`
+	ProductID   uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_inventory_warehouse_product;index"`
+	Quantity    int       `json:"quantity" gorm:"not null;default:0"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// InventoryMovement 库存流水，记录每次数据库库存变动
+type InventoryMovement struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ProductID   uint      `json:"product_id" gorm:"not null;index:idx_movement_product_time"`
+	WarehouseID *uint     `json:"warehouse_id,omitempty" gorm:"index"`
+	Type        string    `json:"type" gorm:"type:varchar(20);not null"`    // order 订单扣减、cancel 取消退回、manual 后台调整、transfer 调拨、import 导入、purchase 采购入库、wms WMS回传
+	Reason      string    `json:"reason,omitempty" gorm:"type:varchar(20)"` // 后台调整原因：damage 报损、recount 盘点修正、return 退货入库
+	Quantity    int       `json:"quantity" gorm:"not null"`                 // 变动数量，增加为正，减少为负
+	StockAfter  int       `json:"stock_after"`                              // 变动后的商品总库存
+	OrderID     *uint     `json:"order_id,omitempty" gorm:"index"`
+	OperatorID  *uint     `json:"operator_id,omitempty"`
+	Note        string    `json:"note,omitempty" gorm:"type:varchar(200)"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index:idx_movement_product_time"`
+}
+
+// Supplier 供应商
+type Supplier struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
+	ContactName string    `json:"contact_name" gorm:"type:varchar(50)"`
+	Phone       string    `json:"phone" gorm:"type:varchar(20)"`
+	Email       string    `json:"email" gorm:"type:varchar(100)"`
+	Address     string    `json:"address" gorm:"type:varchar(255)"`
+	Status      int       `json:"status" gorm:"default:1"` // 1 合作中，0 停止合作（不能再创建采购单）
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PurchaseOrder 采购单
+type PurchaseOrder struct {
+	ID          uint                `json:"id" gorm:"primaryKey"`
+	PONo        string              `json:"po_no" gorm:"column:po_no;type:varchar(50);uniqueIndex;not null"`
+	SupplierID  uint                `json:"supplier_id" gorm:"not null;index"`
+	Supplier    Supplier            `json:"supplier" gorm:"foreignKey:SupplierID"`
+	WarehouseID *uint               `json:"warehouse_id,omitempty"`                             // 收货仓库，商品分仓管理时必填
+	Status      string              `json:"status" gorm:"type:varchar(20);default:draft;index"` // 状态：draft 草稿、ordered 已下单、received 已收货
+	TotalCost   float64             `json:"total_cost" gorm:"type:decimal(10,2)"`
+	Note        string              `json:"note" gorm:"type:text"`
+	CreatedBy   uint                `json:"created_by"`
+	OrderedAt   *time.Time          `json:"ordered_at"`
+	ReceivedAt  *time.Time          `json:"received_at"`
+	ReceivedBy  *uint               `json:"received_by,omitempty"`
+	Items       []PurchaseOrderItem `json:"items" gorm:"foreignKey:PurchaseOrderID"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// PurchaseOrderItem 采购单商品
+type PurchaseOrderItem struct {
+	ID              uint    `json:"id" gorm:"primaryKey"`
+	PurchaseOrderID uint    `json:"purchase_order_id" gorm:"not null;index"`
+	ProductID       uint    `json:"product_id" gorm:"not null"`
+	Product         Product `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity        int     `json:"quantity" gorm:"not null"`
+	UnitCost        float64 `json:"unit_cost" gorm:"type:decimal(10,2);not null"` // 采购单价
+}
+
+// InventorySnapshot 每日库存快照，每天开始时记录各商品的总库存，用于库存审计
+type InventorySnapshot struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	SnapshotDate time.Time `json:"snapshot_date" gorm:"type:date;not null;uniqueIndex:idx_snapshot_date_product"`
+	ProductID    uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_snapshot_date_product"`
+	Stock        int       `json:"stock"`    // 快照时的商品总库存
+	Reserved     int       `json:"reserved"` // 快照时待付款订单预占的数量
+	CreatedAt    time.Time `json:"created_at"`
+}
+
 // Order 订单模型
 type Order struct {
-	ID              uint        `json:"id" gorm:"primaryKey"`
-	UserID          uint        `json:"user_id" gorm:"not null"`
-	User            User        `json:"user" gorm:"foreignKey:UserID"`
-	OrderNo         string      `json:"order_no" gorm:"type:varchar(50);uniqueIndex;not null"`
-	TotalAmount     float64     `json:"total_amount" gorm:"type:decimal(10,2);not null"`
-	Status          string      `json:"status" gorm:"type:varchar(20);default:pending"`
-	ShippingAddress string      `json:"shipping_address" gorm:"type:text"`
-	OrderItems      []OrderItem `json:"order_items" gorm:"foreignKey:OrderID"`
-	CreatedAt       time.Time   `json:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at"`
+	ID              uint            `json:"id" gorm:"primaryKey"`
+	UserID          uint            `json:"user_id" gorm:"not null"`
+	User            User            `json:"user" gorm:"foreignKey:UserID"`
+	OrderNo         string          `json:"order_no" gorm:"type:varchar(50);uniqueIndex;not null"`
+	ParentOrderNo   string          `json:"parent_order_no,omitempty" gorm:"type:varchar(50);index"` // 拆单时共用的父订单号，未拆单为空
+	TotalAmount     float64         `json:"total_amount" gorm:"type:decimal(10,2);not null"`         // 应付金额
+	ItemAmount      float64         `json:"item_amount" gorm:"type:decimal(10,2);default:0"`         // 商品原价合计
+	ItemDiscount    float64         `json:"item_discount" gorm:"type:decimal(10,2);default:0"`       // 商品优惠（促销、协议价、线下成交价），负数
+	CouponDiscount  float64         `json:"coupon_discount" gorm:"type:decimal(10,2);default:0"`     // 优惠券抵扣，负数
+	PointsDiscount  float64         `json:"points_discount" gorm:"type:decimal(10,2);default:0"`     // 积分抵扣，负数
+	ShippingFee     float64         `json:"shipping_fee" gorm:"type:decimal(10,2);default:0"`        // 运费
+	TaxAmount       float64         `json:"tax_amount" gorm:"type:decimal(10,2);default:0"`          // 税费
+	Status          string          `json:"status" gorm:"type:varchar(20);default:pending"`
+	PaymentMethod   string          `json:"payment_method" gorm:"type:varchar(20);default:online"` // 支付方式：online、cod
+	Channel         string          `json:"channel" gorm:"type:varchar(20);default:web"`           // 下单渠道：web、offline、phone
+	ExternalRef     string          `json:"external_ref,omitempty" gorm:"type:varchar(64);index"`  // 线下渠道的原始单号
+	PaymentRef      string          `json:"payment_ref,omitempty" gorm:"type:varchar(100)"`        // 线下收款流水号
+	PricingTrace    string          `json:"-" gorm:"type:text"`                                    // 计价明细（JSON）
+	ShippingAddress string          `json:"shipping_address" gorm:"type:text"`
+	RecipientID     *uint           `json:"recipient_id,omitempty" gorm:"index"`             // 礼物订单的收礼人
+	GiftMessage     string          `json:"gift_message,omitempty" gorm:"type:varchar(200)"` // 礼物留言，随包裹附上
+	Remark          string          `json:"remark,omitempty" gorm:"type:varchar(200)"`       // 买家备注，如送货时间要求
+	StatusChangedAt *time.Time      `json:"status_changed_at,omitempty" gorm:"index"`        // 进入当前状态的时间，用于履约时效监控
+	WarehouseID     *uint           `json:"warehouse_id,omitempty" gorm:"index"`             // 发货仓库，商品未分仓管理时为空
+	SellerID        *uint           `json:"seller_id,omitempty" gorm:"index"`                // 商品所属商家，平台自营为空
+	Carrier         string          `json:"carrier,omitempty" gorm:"type:varchar(50)"`       // 物流公司
+	TrackingNo      string          `json:"tracking_no,omitempty" gorm:"type:varchar(100)"`  // 运单号
+	ShippedAt       *time.Time      `json:"shipped_at,omitempty"`                            // 发货时间
+	OrderItems      []OrderItem     `json:"order_items" gorm:"foreignKey:OrderID"`
+	DeliveryProofs  []DeliveryProof `json:"delivery_proofs,omitempty" gorm:"foreignKey:OrderID"`
+	Notes           []OrderNote     `json:"notes,omitempty" gorm:"foreignKey:OrderID" visible:"admin"` // 内部备注，仅管理员可见
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// Invoice 订单发票，每个订单开具一张
+type Invoice struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	OrderID   uint       `json:"order_id" gorm:"not null;uniqueIndex"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	InvoiceNo string     `json:"invoice_no" gorm:"type:varchar(32);not null;uniqueIndex"`
+	TitleType string     `json:"title_type" gorm:"type:varchar(20);not null"`   // 抬头类型：personal 个人、company 单位
+	Title     string     `json:"title" gorm:"type:varchar(100);not null"`       // 发票抬头
+	TaxNumber string     `json:"tax_number,omitempty" gorm:"type:varchar(20)"`  // 单位的纳税人识别号
+	Amount    float64    `json:"amount" gorm:"type:decimal(10,2);not null"`     // 开票金额，即订单应付金额
+	Status    string     `json:"status" gorm:"type:varchar(20);default:issued"` // 状态：issued 已开具、voided 已作废（订单取消）
+	IssuedAt  time.Time  `json:"issued_at"`
+	VoidedAt  *time.Time `json:"voided_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Payment 订单支付单，每次发起支付生成一条，通过 PaymentNo 与支付渠道的交易对应
+type Payment struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	PaymentNo      string     `json:"payment_no" gorm:"type:varchar(32);not null;uniqueIndex"` // 商户支付单号，传给支付渠道
+	OrderID        uint       `json:"order_id" gorm:"not null;index"`                          // 钱包充值时为0
+	UserID         uint       `json:"user_id" gorm:"not null;index"`
+	Purpose        string     `json:"purpose" gorm:"type:varchar(20);default:order"` // 用途：order 订单支付、topup 钱包充值
+	Channel        string     `json:"channel" gorm:"type:varchar(20);not null"`      // 支付渠道：wallet 钱包余额、mock、stripe
+	Amount         float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
+	RefundedAmount float64    `json:"refunded_amount" gorm:"type:decimal(10,2);default:0"`  // 已退款金额，组合支付的订单退款时按支付单分别退回
+	Status         string     `json:"status" gorm:"type:varchar(20);default:pending;index"` // 状态：pending 待支付、succeeded 成功、failed 失败、closed 已关闭、refunded 已退回钱包
+	TradeNo        string     `json:"trade_no,omitempty" gorm:"type:varchar(64);index"`     // 支付渠道的交易号
+	FailReason     string     `json:"fail_reason,omitempty" gorm:"type:varchar(200)"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ReconciliationRun 支付对账记录，每个支付渠道每天一条，重新对账时覆盖
+type ReconciliationRun struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Channel          string    `json:"channel" gorm:"type:varchar(20);not null;uniqueIndex:idx_reconciliation_channel_date"`
+	StatementDate    time.Time `json:"statement_date" gorm:"type:date;not null;uniqueIndex:idx_reconciliation_channel_date"`
+	Source           string    `json:"source" gorm:"type:varchar(20)"` // 对账单来源：api 渠道接口拉取、import 上传对账文件
+	RemoteCount      int       `json:"remote_count"`                   // 对账单交易笔数
+	RemoteAmount     float64   `json:"remote_amount" gorm:"type:decimal(12,2)"`
+	LocalCount       int       `json:"local_count"` // 本地当天支付成功的支付单数
+	LocalAmount      float64   `json:"local_amount" gorm:"type:decimal(12,2)"`
+	MatchedCount     int       `json:"matched_count"`
+	DiscrepancyCount int       `json:"discrepancy_count"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// PaymentDiscrepancy 支付对账差异
+type PaymentDiscrepancy struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	RunID         uint       `json:"run_id" gorm:"not null;index"`
+	Channel       string     `json:"channel" gorm:"type:varchar(20);not null"`
+	StatementDate time.Time  `json:"statement_date" gorm:"type:date;not null;index"`
+	Type          string     `json:"type" gorm:"type:varchar(30);not null"` // 差异类型：missing_local 渠道有收款本地无支付单、missing_remote 本地支付成功渠道无交易、amount_mismatch 金额不一致、status_mismatch 渠道已收款本地未成功
+	PaymentID     *uint      `json:"payment_id,omitempty"`
+	PaymentNo     string     `json:"payment_no" gorm:"type:varchar(32)"`
+	TradeNo       string     `json:"trade_no" gorm:"type:varchar(64)"`
+	LocalAmount   float64    `json:"local_amount" gorm:"type:decimal(10,2)"`
+	RemoteAmount  float64    `json:"remote_amount" gorm:"type:decimal(10,2)"`
+	Status        string     `json:"status" gorm:"type:varchar(20);default:open;index"` // 状态：open 待处理、resolved 已处理
+	Note          string     `json:"note,omitempty" gorm:"type:varchar(500)"`           // 处理说明
+	ResolvedBy    *uint      `json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Refund 退款申请，未发货订单整单退款，已发货订单可按商品部分退款
+type Refund struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	RefundNo   string          `json:"refund_no" gorm:"type:varchar(32);not null;uniqueIndex"`
+	OrderID    uint            `json:"order_id" gorm:"not null;index"`
+	UserID     uint            `json:"user_id" gorm:"not null;index"`
+	Amount     float64         `json:"amount" gorm:"type:decimal(10,2);not null"`
+	Reason     string          `json:"reason" gorm:"type:varchar(500)"`
+	Status     string          `json:"status" gorm:"type:varchar(20);default:pending;index"` // 状态：pending 待审核、rejected 已驳回、processing 退款中、succeeded 已退款、failed 退款失败
+	Restock    bool            `json:"restock"`                                              // 退货商品退款后重新入库
+	ToWallet   bool            `json:"to_wallet"`                                            // 审批时选择全部退回钱包余额，不原路退回
+	ReviewNote string          `json:"review_note,omitempty" gorm:"type:varchar(500)"`
+	ReviewedBy *uint           `json:"reviewed_by,omitempty" visible:"admin"`
+	ReviewedAt *time.Time      `json:"reviewed_at,omitempty"`
+	FailReason string          `json:"fail_reason,omitempty" gorm:"type:varchar(200)"`
+	RefundedAt *time.Time      `json:"refunded_at,omitempty"`
+	Items      []RefundItem    `json:"items" gorm:"foreignKey:RefundID"`
+	Legs       []RefundPayment `json:"legs" gorm:"foreignKey:RefundID"` // 按支付单分摊的退款明细
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// RefundPayment 退款分摊到各笔支付的退款明细，组合支付（钱包余额加支付渠道）的订单按支付单分别原路退回
+type RefundPayment struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	RefundID   uint       `json:"refund_id" gorm:"not null;index"`
+	RefundNo   string     `json:"refund_no" gorm:"type:varchar(40);not null;uniqueIndex"` // 传给支付渠道的退款单号：退款单号-序号
+	PaymentID  *uint      `json:"payment_id,omitempty" gorm:"index"`                      // 原支付单，线下退款时为空
+	Channel    string     `json:"channel" gorm:"type:varchar(20);not null"`               // 退款渠道：原支付渠道、wallet 退回钱包余额、offline 线下退款
+	Amount     float64    `json:"amount" gorm:"type:decimal(10,2);not null"`
+	Status     string     `json:"status" gorm:"type:varchar(20);default:processing;index"` // 状态：processing 退款中、succeeded 已退款、failed 退款失败
+	Attempts   int        `json:"attempts" gorm:"default:1"`                               // 执行次数，退款失败后重新审批时递增
+	TradeNo    string     `json:"trade_no,omitempty" gorm:"type:varchar(64)"`              // 支付渠道的退款单号
+	FailReason string     `json:"fail_reason,omitempty" gorm:"type:varchar(200)"`
+	RefundedAt *time.Time `json:"refunded_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// RefundItem 退款的订单商品
+type RefundItem struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	RefundID    uint    `json:"refund_id" gorm:"not null;index"`
+	OrderItemID uint    `json:"order_item_id" gorm:"not null;index"`
+	ProductID   uint    `json:"product_id" gorm:"not null"`
+	Quantity    int     `json:"quantity" gorm:"not null"`
+	Amount      float64 `json:"amount" gorm:"type:decimal(10,2);not null"` // 按实付比例分摊的退款金额
+}
+
+// OrderNote 订单内部备注，仅管理员可见
+type OrderNote struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"not null;index"`
+	AdminID   uint      `json:"admin_id" gorm:"not null"`
+	Content   string    `json:"content" gorm:"type:varchar(500);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminAuditLog 管理员操作日志
+type AdminAuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	AdminID    uint      `json:"admin_id" gorm:"not null;index"`
+	Action     string    `json:"action" gorm:"type:varchar(50);not null;index"` // 操作，如 order.cancel
+	TargetType string    `json:"target_type" gorm:"type:varchar(30);not null"`  // 操作对象类型，如 order
+	TargetID   uint      `json:"target_id" gorm:"index"`
+	Detail     string    `json:"detail" gorm:"type:text"` // 操作内容
+	IP         string    `json:"ip" gorm:"type:varchar(45)"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// OrderStatusHistory 订单状态变更记录
+type OrderStatusHistory struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	OrderID    uint      `json:"order_id" gorm:"not null;index"`
+	FromStatus string    `json:"from_status" gorm:"type:varchar(20);not null"`
+	ToStatus   string    `json:"to_status" gorm:"type:varchar(20);not null"`
+	ActorRole  string    `json:"actor_role" gorm:"type:varchar(20);not null"` // 操作方：customer、admin、courier、system
+	ActorID    *uint     `json:"actor_id"`                                    // 操作人，系统自动流转时为空
+	Note       string    `json:"note" gorm:"type:varchar(200)"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderEvent 订单事件，构成用户和客服看到的订单时间线
+type OrderEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"not null;index"`
+	Type      string    `json:"type" gorm:"type:varchar(30);not null"`       // 事件类型：created、paid、shipped、delivered、completed、cancelled、address_changed、refund_requested
+	ActorRole string    `json:"actor_role" gorm:"type:varchar(20);not null"` // 操作方：customer、admin、courier、system
+	ActorID   *uint     `json:"actor_id"`                                    // 操作人，系统事件为空
+	Detail    string    `json:"detail" gorm:"type:varchar(500)"`             // 事件说明，如取消原因、物流信息
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductPriceHistory 商品价格变更记录
+type ProductPriceHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProductID uint      `json:"product_id" gorm:"not null;index"`
+	OldPrice  float64   `json:"old_price" gorm:"type:decimal(10,2);not null"`
+	NewPrice  float64   `json:"new_price" gorm:"type:decimal(10,2);not null"`
+	ChangedBy *uint     `json:"changed_by"`                              // 操作人
+	Source    string    `json:"source" gorm:"type:varchar(20);not null"` // 变更来源：manual、import
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
 }
 
 // OrderItem 订单商品模型
 type OrderItem struct {
-	ID        uint    `json:"id" gorm:"primaryKey"`
-	OrderID   uint    `json:"order_id" gorm:"not null"`
-	ProductID uint    `json:"product_id" gorm:"not null"`
-	Product   Product `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity  int     `json:"quantity" gorm:"not null"`
-	Price     float64 `json:"price" gorm:"type:decimal(10,2);not null"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	OrderID           uint       `json:"order_id" gorm:"not null"`
+	ProductID         uint       `json:"product_id" gorm:"not null"`
+	Product           Product    `json:"product" gorm:"foreignKey:ProductID"`
+	ProductName       string     `json:"product_name" gorm:"type:varchar(200)"`  // 下单时的商品名称快照
+	ProductSpecs      string     `json:"product_specs" gorm:"type:text"`         // 下单时的规格参数快照，如「颜色：黑色；屏幕尺寸：6.1英寸」
+	ProductImage      string     `json:"product_image" gorm:"type:varchar(500)"` // 下单时的商品主图快照
+	Quantity          int        `json:"quantity" gorm:"not null"`
+	Price             float64    `json:"price" gorm:"type:decimal(10,2);not null"`
+	CostPrice         float64    `json:"cost_price" gorm:"type:decimal(10,2);default:0" visible:"admin"` // 下单时的成本价快照，仅管理员可见
+	FulfillmentStatus string     `json:"fulfillment_status" gorm:"type:varchar(20);default:ready"`       // 履约状态：ready 现货、presale 预售待发货、shipped 已发货
+	ExpectedShipDate  *time.Time `json:"expected_ship_date,omitempty"`                                   // 预售商品的预计发货日期
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// DeliveryProof 签收凭证模型
+type DeliveryProof struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	OrderID    uint      `json:"order_id" gorm:"not null;index"`
+	Type       string    `json:"type" gorm:"type:varchar(20);not null"` // 凭证类型：photo、signature
+	FilePath   string    `json:"file_path" gorm:"type:varchar(500);not null"`
+	Note       string    `json:"note" gorm:"type:varchar(500)" visible:"admin,courier"` // 配送员内部备注，买家不可见
+	UploadedBy uint      `json:"uploaded_by" visible:"admin,courier"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CODSettlement 货到付款收款结算模型
+type CODSettlement struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	OrderID     uint       `json:"order_id" gorm:"not null;uniqueIndex"`
+	Order       Order      `json:"-" gorm:"foreignKey:OrderID"`
+	Amount      float64    `json:"amount" gorm:"type:decimal(10,2);not null"`              // 代收金额
+	Status      string     `json:"status" gorm:"type:varchar(20);default:collected;index"` // 状态：collected（配送员已收款）、settled（已交回平台）
+	CollectedBy uint       `json:"collected_by"`
+	CollectedAt time.Time  `json:"collected_at"`
+	SettledBy   uint       `json:"settled_by"`
+	SettledAt   *time.Time `json:"settled_at"`
+	Note        string     `json:"note" gorm:"type:varchar(500)"`
+}
+
+// PointsLedger 积分流水模型
+type PointsLedger struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	Type        string    `json:"type" gorm:"type:varchar(20);not null"` // 流水类型：earn、redeem、refund、revoke
+	Points      int       `json:"points" gorm:"not null"`                // 变动积分，获得为正数，扣减为负数
+	Balance     int       `json:"balance"`                               // 变动后余额
+	OrderID     uint      `json:"order_id" gorm:"index"`                 // 关联订单
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WalletLedger 钱包余额流水
+type WalletLedger struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	Type        string    `json:"type" gorm:"type:varchar(20);not null"`     // 流水类型：topup、payment、refund、adjust
+	Amount      float64   `json:"amount" gorm:"type:decimal(12,2);not null"` // 变动金额，入账为正数，支出为负数
+	Balance     float64   `json:"balance" gorm:"type:decimal(12,2)"`         // 变动后余额
+	OrderID     uint      `json:"order_id" gorm:"index"`                     // 关联订单
+	PaymentID   uint      `json:"payment_id"`                                // 关联支付单（充值、订单支付）
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReferralCode 用户邀请码模型
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex"`
+	Code      string    `json:"code" gorm:"type:varchar(16);not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Referral 邀请注册记录模型
+type Referral struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	ReferrerID uint       `json:"referrer_id" gorm:"not null;index"`      // 邀请人
+	RefereeID  uint       `json:"referee_id" gorm:"not null;uniqueIndex"` // 被邀请人，每个用户只能被邀请一次
+	Referee    User       `json:"-" gorm:"foreignKey:RefereeID"`
+	Status     string     `json:"status" gorm:"type:varchar(20);default:pending"` // 状态：pending、rewarded
+	OrderID    uint       `json:"order_id"`                                       // 触发奖励的首笔订单
+	RewardedAt *time.Time `json:"rewarded_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CustomerMetrics 客户价值指标模型，每晚根据有效订单重新计算
+type CustomerMetrics struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex"`
+	User         User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	RecencyDays  int       `json:"recency_days"`                       // 距最近一次购买的天数
+	Frequency    int       `json:"frequency"`                          // 有效订单数
+	Monetary     float64   `json:"monetary" gorm:"type:decimal(12,2)"` // 累计消费金额
+	FirstOrderAt time.Time `json:"first_order_at"`
+	LastOrderAt  time.Time `json:"last_order_at"`
+	RScore       int       `json:"r_score"` // 1-5分，越近越高
+	FScore       int       `json:"f_score"` // 1-5分，越频繁越高
+	MScore       int       `json:"m_score"` // 1-5分，消费越多越高
+	RFMScore     string    `json:"rfm_score" gorm:"type:varchar(3);index"`
+	Segment      string    `json:"segment" gorm:"type:varchar(20);index"` // 客户分群
+	LTV          float64   `json:"ltv" gorm:"type:decimal(12,2);index"`   // 预计生命周期价值
+	ComputedAt   time.Time `json:"computed_at"`
+}
+
+// MessageSuppression 邮件/短信屏蔽名单模型
+type MessageSuppression struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Channel   string    `json:"channel" gorm:"type:varchar(10);not null;uniqueIndex:idx_suppression"`  // 通道：email、sms
+	Address   string    `json:"address" gorm:"type:varchar(255);not null;uniqueIndex:idx_suppression"` // 邮箱或手机号
+	Reason    string    `json:"reason" gorm:"type:varchar(20);not null;uniqueIndex:idx_suppression"`   // 原因：hard_bounce、unsubscribe、legal_block
+	Source    string    `json:"source" gorm:"type:varchar(20)"`                                        // 来源：admin、sendgrid、ses、twilio
+	Note      string    `json:"note" gorm:"type:varchar(500)"`
+	CreatedBy uint      `json:"created_by"` // 手动添加的管理员，回调写入时为0
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -110,6 +674,156 @@ type UploadedFile struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// SellerApplication 商家入驻申请模型
+type SellerApplication struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	User          User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ShopName      string     `json:"shop_name" gorm:"type:varchar(50);not null"`
+	BusinessName  string     `json:"business_name" gorm:"type:varchar(100);not null"` // 企业名称
+	LicenseNumber string     `json:"license_number" gorm:"type:varchar(50);not null"` // 统一社会信用代码
+	ContactPhone  string     `json:"contact_phone" gorm:"type:varchar(20);not null"`
+	LicenseFile   string     `json:"-" gorm:"type:varchar(500);not null"`                  // 营业执照，存放在非公开目录
+	IDFrontFile   string     `json:"-" gorm:"type:varchar(500);not null"`                  // 身份证人像面
+	IDBackFile    string     `json:"-" gorm:"type:varchar(500);not null"`                  // 身份证国徽面
+	Status        string     `json:"status" gorm:"type:varchar(20);default:pending;index"` // 状态：pending、approved、rejected
+	RejectReason  string     `json:"reject_reason,omitempty" gorm:"type:varchar(500)"`
+	ReviewedBy    *uint      `json:"reviewed_by,omitempty" visible:"admin"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Shop 店铺模型，商家入驻审核通过后自动开通
+type Shop struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	OwnerID      uint      `json:"owner_id" gorm:"not null;uniqueIndex"`
+	Name         string    `json:"name" gorm:"type:varchar(50);not null;uniqueIndex"`
+	Description  string    `json:"description" gorm:"type:text"`
+	Logo         string    `json:"logo" gorm:"type:varchar(500)"`
+	ContactPhone string    `json:"contact_phone" gorm:"type:varchar(20)"`
+	ReturnDays   int       `json:"return_days" gorm:"default:7"` // 无理由退货天数
+	Status       int       `json:"status" gorm:"default:1"`      // 1营业中，0已关闭
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Collection 商品专题模型（首页运营位）
+type Collection struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Slug        string           `json:"slug" gorm:"type:varchar(100);uniqueIndex;not null"` // 专题标识
+	Title       string           `json:"title" gorm:"type:varchar(200);not null"`
+	Description string           `json:"description" gorm:"type:text"`
+	SortOrder   int              `json:"sort_order" gorm:"default:0"`
+	Status      int              `json:"status" gorm:"default:1"`
+	Items       []CollectionItem `json:"items,omitempty" gorm:"foreignKey:CollectionID"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CollectionItem 专题商品模型
+type CollectionItem struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CollectionID uint      `json:"collection_id" gorm:"not null;index"`
+	ProductID    uint      `json:"product_id" gorm:"not null"`
+	Product      Product   `json:"product" gorm:"foreignKey:ProductID"`
+	Position     int       `json:"position" gorm:"default:0"` // 排列顺序
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Notification 站内通知模型
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Type      string     `json:"type" gorm:"type:varchar(30);not null"` // 通知类型：announcement、order、system等
+	Title     string     `json:"title" gorm:"type:varchar(200);not null"`
+	Content   string     `json:"content" gorm:"type:text"`
+	IsRead    bool       `json:"is_read" gorm:"default:false"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Announcement 全站公告模型
+type Announcement struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	Title           string     `json:"title" gorm:"type:varchar(200);not null"`
+	Content         string     `json:"content" gorm:"type:text"`
+	Type            string     `json:"type" gorm:"type:varchar(30);default:notice"` // 公告类型：notice、maintenance、promotion
+	AudienceRole    string     `json:"audience_role" gorm:"type:varchar(50)"`       // 目标角色，为空表示全部用户
+	AudienceSegment string     `json:"audience_segment" gorm:"type:varchar(20)"`    // 目标客户分群，为空表示不限
+	StartAt         time.Time  `json:"start_at"`                                    // 生效时间
+	EndAt           *time.Time `json:"end_at"`                                      // 结束时间，为空表示长期有效
+	Status          int        `json:"status" gorm:"default:1"`
+	Delivered       bool       `json:"delivered" gorm:"default:false" visible:"admin"` // 是否已投递到站内通知
+	CreatedBy       uint       `json:"created_by" visible:"admin"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CartShare 购物车分享链接模型
+type CartShare struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	Token      string          `json:"token" gorm:"type:varchar(64);uniqueIndex;not null"` // 分享链接标识
+	UserID     uint            `json:"user_id" gorm:"not null;index"`
+	User       User            `json:"-" gorm:"foreignKey:UserID"`
+	Title      string          `json:"title" gorm:"type:varchar(200)"`
+	Note       string          `json:"note" gorm:"type:text"`
+	Visibility string          `json:"visibility" gorm:"type:varchar(20);default:public"` // 可见范围：public、registered
+	ShowOwner  bool            `json:"show_owner" gorm:"default:false"`                   // 是否展示分享人昵称
+	ExpiresAt  *time.Time      `json:"expires_at"`                                        // 过期时间，为空表示长期有效
+	Status     int             `json:"status" gorm:"default:1"`
+	ViewCount  int             `json:"view_count" gorm:"default:0"`
+	Items      []CartShareItem `json:"items,omitempty" gorm:"foreignKey:ShareID"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// CartShareItem 分享商品模型
+type CartShareItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ShareID   uint      `json:"share_id" gorm:"not null;index"`
+	ProductID uint      `json:"product_id" gorm:"not null"`
+	Product   Product   `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity  int       `json:"quantity" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Quote 大宗采购询价单模型
+type Quote struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	User         User       `json:"-" gorm:"foreignKey:UserID"`
+	ProductID    uint       `json:"product_id" gorm:"not null"`
+	Product      Product    `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity     int        `json:"quantity" gorm:"not null"`
+	BuyerNote    string     `json:"buyer_note" gorm:"type:text"`                          // 采购需求说明
+	Status       string     `json:"status" gorm:"type:varchar(20);default:pending;index"` // 状态：pending、quoted、accepted、rejected、cancelled、expired
+	QuotedPrice  float64    `json:"quoted_price" gorm:"type:decimal(10,2)"`               // 报价单价
+	ValidUntil   *time.Time `json:"valid_until"`                                          // 报价有效期
+	MerchantNote string     `json:"merchant_note" gorm:"type:text"`
+	RespondedBy  uint       `json:"responded_by" visible:"admin,seller"`
+	OrderID      uint       `json:"order_id"` // 接受报价后生成的订单
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Role 角色模型
+type Role struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"type:varchar(50);uniqueIndex;not null"` // 角色标识：admin、seller、customer
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UserRole 用户角色关联模型
+type UserRole struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_role"`
+	RoleID    uint      `json:"role_id" gorm:"not null;uniqueIndex:idx_user_role"`
+	Role      Role      `json:"role" gorm:"foreignKey:RoleID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // InitDatabase 初始化数据库连接
 func InitDatabase(config *Config) error {
 	var err error
@@ -141,13 +855,50 @@ func InitDatabase(config *Config) error {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
+	if err := PrepareSchema(); err != nil {
+		return err
+	}
+
+	log.Println("MySQL数据库连接成功")
+	return nil
+}
+
+// PrepareSchema 迁移表结构、补全旧数据并初始化默认角色，使用外部传入的数据库连接时也需调用
+func PrepareSchema() error {
 	// 自动迁移数据库表结构
-	err = AutoMigrate()
-	if err != nil {
+	if err := AutoMigrate(); err != nil {
 		return fmt.Errorf("数据库迁移失败: %v", err)
 	}
 
-	log.Println("MySQL数据库连接成功")
+	// 补全旧商品的生命周期状态
+	if err := migrateProductLifecycle(); err != nil {
+		return fmt.Errorf("商品状态迁移失败: %v", err)
+	}
+
+	// 补全旧订单的状态变更时间
+	if err := migrateOrderStatusChangedAt(); err != nil {
+		return fmt.Errorf("订单状态时间迁移失败: %v", err)
+	}
+
+	// 补全旧订单的金额构成
+	if err := migrateOrderAmounts(); err != nil {
+		return fmt.Errorf("订单金额迁移失败: %v", err)
+	}
+
+	// 补全旧订单商品的商品快照
+	if err := migrateOrderItemSnapshots(); err != nil {
+		return fmt.Errorf("订单商品快照迁移失败: %v", err)
+	}
+
+	// 补全旧订单的下单事件
+	if err := migrateOrderEvents(); err != nil {
+		return fmt.Errorf("订单事件迁移失败: %v", err)
+	}
+
+	// 初始化默认角色
+	if err := SeedRoles(); err != nil {
+		return fmt.Errorf("角色初始化失败: %v", err)
+	}
 	return nil
 }
 
@@ -176,11 +927,61 @@ func AutoMigrate() error {
 	return DB.AutoMigrate(
 		&User{},
 		&Category{},
+		&Brand{},
 		&Product{},
+		&ProductContentBlock{},
+		&ProductPriceHistory{},
+		&CategoryAttribute{},
+		&ProductAttributeValue{},
+		&SellerApplication{},
+		&Shop{},
 		&CartItem{},
+		&SavedItem{},
+		&Favorite{},
+		&RestockSubscription{},
+		&DigitalDelivery{},
+		&SearchSynonym{},
+		&SearchRule{},
+		&SavedSearch{},
+		&StockReservation{},
+		&Warehouse{},
+		&InventoryRecord{},
+		&InventoryMovement{},
+		&Supplier{},
+		&PurchaseOrder{},
+		&PurchaseOrderItem{},
+		&InventorySnapshot{},
 		&Order{},
 		&OrderItem{},
+		&OrderStatusHistory{},
+		&OrderEvent{},
+		&OrderNote{},
+		&Invoice{},
+		&Payment{},
+		&Refund{},
+		&ReconciliationRun{},
+		&PaymentDiscrepancy{},
+		&RefundItem{},
+		&RefundPayment{},
+		&AdminAuditLog{},
 		&UploadedFile{},
+		&Role{},
+		&UserRole{},
+		&Collection{},
+		&CollectionItem{},
+		&Notification{},
+		&Announcement{},
+		&CartShare{},
+		&CartShareItem{},
+		&Quote{},
+		&DeliveryProof{},
+		&CODSettlement{},
+		&PointsLedger{},
+		&WalletLedger{},
+		&MessageSuppression{},
+		&ReferralCode{},
+		&Referral{},
+		&CustomerMetrics{},
 	)
 }
 
@@ -0,0 +1,160 @@
+package gomall
+
+import (
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 签收凭证类型
+const (
+	DeliveryProofPhoto     = "photo"     // 签收照片
+	DeliveryProofSignature = "signature" // 签名图片
+)
+
+// 单次最多上传的签收照片数量
+const maxDeliveryPhotos = 5
+
+// 保存签收凭证图片，文件名使用随机令牌避免被枚举
+func saveDeliveryProofFile(c *gin.Context, file *multipart.FileHeader, orderID uint) (string, error) {
+	if !isValidImageFile(file) {
+		return "", fmt.Errorf("文件 %s 不是有效的图片格式", file.Filename)
+	}
+	if file.Size > AppConfig.MaxFileSize {
+		return "", fmt.Errorf("文件 %s 大小超过限制", file.Filename)
+	}
+
+	uploadDir := AppConfig.UploadPath + "/delivery"
+	os.MkdirAll(uploadDir, 0755)
+
+	random, err := generateSecureToken(12)
+	if err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("delivery_%d_%s%s", orderID, random, strings.ToLower(filepath.Ext(file.Filename)))
+	if err := c.SaveUploadedFile(file, filepath.Join(uploadDir, filename)); err != nil {
+		return "", fmt.Errorf("文件 %s 保存失败", file.Filename)
+	}
+
+	userID, _ := c.Get("user_id")
+	uploadedFile := UploadedFile{
+		OriginalName: file.Filename,
+		FileName:     filename,
+		FilePath:     "/upload/delivery/" + filename,
+		FileSize:     file.Size,
+		MimeType:     file.Header.Get("Content-Type"),
+		UploadedBy:   userID.(uint),
+	}
+	if err := DB.Create(&uploadedFile).Error; err != nil {
+		return "", fmt.Errorf("文件 %s 记录失败", file.Filename)
+	}
+	return uploadedFile.FilePath, nil
+}
+
+// UploadDeliveryProof 上传签收凭证
+// @Summary 上传签收凭证
+// @Description 配送员或管理员为已发货订单上传签收照片和签名图片，可同时将订单标记为已送达；买家可在订单详情中查看
+// @Tags 订单管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param photos formData file false "签收照片（最多5张）"
+// @Param signature formData file false "签名图片"
+// @Param note formData string false "备注"
+// @Param mark_delivered formData bool false "是否同时标记为已送达"
+// @Success 200 {object} ApiResponse{data=object{proofs=[]DeliveryProof,errors=[]string}} "上传成功"
+// @Failure 400 {object} ApiResponse "订单状态不允许上传或未选择文件"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Security Bearer
+// @Router /api/delivery/orders/{id}/proofs [post]
+func UploadDeliveryProof(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	var order Order
+	if err := DB.First(&order, orderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+	if order.Status != OrderStatusShipped && order.Status != OrderStatusDelivered {
+		BadRequestError(c, "只有已发货或已送达的订单可以上传签收凭证")
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		BadRequestError(c, "文件解析失败")
+		return
+	}
+
+	photos := form.File["photos"]
+	signatures := form.File["signature"]
+	if len(photos) == 0 && len(signatures) == 0 {
+		BadRequestError(c, "请上传签收照片或签名图片")
+		return
+	}
+	if len(photos) > maxDeliveryPhotos {
+		BadRequestError(c, fmt.Sprintf("最多只能上传%d张签收照片", maxDeliveryPhotos))
+		return
+	}
+	if len(signatures) > 1 {
+		signatures = signatures[:1]
+	}
+
+	note := c.PostForm("note")
+	userID, _ := c.Get("user_id")
+
+	var proofs []DeliveryProof
+	var uploadErrors []string
+	upload := func(files []*multipart.FileHeader, proofType string) {
+		for _, file := range files {
+			path, err := saveDeliveryProofFile(c, file, order.ID)
+			if err != nil {
+				uploadErrors = append(uploadErrors, err.Error())
+				continue
+			}
+			proofs = append(proofs, DeliveryProof{
+				OrderID:    order.ID,
+				Type:       proofType,
+				FilePath:   path,
+				Note:       note,
+				UploadedBy: userID.(uint),
+			})
+		}
+	}
+	upload(photos, DeliveryProofPhoto)
+	upload(signatures, DeliveryProofSignature)
+
+	if len(proofs) == 0 {
+		BadRequestError(c, "签收凭证上传失败: "+strings.Join(uploadErrors, "；"))
+		return
+	}
+
+	if err := DB.Create(&proofs).Error; err != nil {
+		InternalServerError(c, "签收凭证保存失败")
+		return
+	}
+
+	if c.PostForm("mark_delivered") == "true" && order.Status == OrderStatusShipped {
+		if err := transitionOrder(DB, &order, OrderStatusDelivered, orderActorFromContext(c), "上传签收凭证"); err != nil {
+			uploadErrors = append(uploadErrors, "标记已送达失败: "+err.Error())
+		} else {
+			SendNotification(order.UserID, NotificationTypeOrder, "订单已送达",
+				fmt.Sprintf("您的订单 %s 已送达，可在订单详情中查看签收凭证。", order.OrderNo))
+		}
+	}
+
+	result := gin.H{"proofs": proofs}
+	if len(uploadErrors) > 0 {
+		result["errors"] = uploadErrors
+	}
+	SuccessResponse(c, result)
+}
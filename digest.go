@@ -0,0 +1,188 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 运营日报栏目
+const (
+	DigestSectionOrders   = "orders"    // 订单概况
+	DigestSectionRevenue  = "revenue"   // 营收
+	DigestSectionLowStock = "low_stock" // 低库存商品
+	DigestSectionCOD      = "cod"       // 待交回的货到付款
+	DigestSectionQuotes   = "quotes"    // 待处理询价
+)
+
+// 未指定栏目时发送全部栏目
+var allDigestSections = []string{
+	DigestSectionOrders,
+	DigestSectionRevenue,
+	DigestSectionLowStock,
+	DigestSectionCOD,
+	DigestSectionQuotes,
+}
+
+// 低库存栏目最多列出的商品数量
+const digestLowStockLimit = 20
+
+// DigestRecipient 日报收件人及其订阅的栏目
+type DigestRecipient struct {
+	Email    string
+	Sections map[string]bool
+}
+
+// 解析日报收件人配置，格式：a@x.com:orders,revenue;b@x.com（不指定栏目表示全部）
+func parseDigestRecipients(value string) []DigestRecipient {
+	var recipients []DigestRecipient
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		email, sectionList, _ := strings.Cut(entry, ":")
+		sections := splitEnvList(sectionList)
+		if len(sections) == 0 {
+			sections = allDigestSections
+		}
+
+		recipient := DigestRecipient{Email: strings.TrimSpace(email), Sections: make(map[string]bool)}
+		for _, section := range sections {
+			recipient.Sections[section] = true
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients
+}
+
+// StartDigestScheduler 启动运营日报任务，每天在配置的时刻发送前一天的数据
+func StartDigestScheduler() {
+	recipients := parseDigestRecipients(AppConfig.DigestRecipients)
+	if len(recipients) == 0 {
+		log.Println("未配置运营日报收件人，跳过日报任务")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			if now.Hour() == AppConfig.DigestSendHour {
+				// 多实例部署时只由一个实例发送
+				key := "digest:sent:" + now.Format("20060102")
+				if ok, _ := RDB.SetNX(CTX, key, 1, 25*time.Hour).Result(); ok {
+					SendDailyDigest(recipients, now.AddDate(0, 0, -1))
+				}
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("运营日报任务已启动")
+}
+
+// SendDailyDigest 生成指定日期的运营日报并发送给各收件人
+func SendDailyDigest(recipients []DigestRecipient, day time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	sections := map[string]string{
+		DigestSectionOrders:   digestOrders(start, end),
+		DigestSectionRevenue:  digestRevenue(start, end),
+		DigestSectionLowStock: digestLowStock(),
+		DigestSectionCOD:      digestCOD(),
+		DigestSectionQuotes:   digestQuotes(),
+	}
+
+	subject := fmt.Sprintf("GoMall运营日报 %s", start.Format("2006-01-02"))
+	for _, recipient := range recipients {
+		var body strings.Builder
+		body.WriteString(subject + "\n")
+		for _, section := range allDigestSections {
+			if recipient.Sections[section] {
+				body.WriteString("\n" + sections[section])
+			}
+		}
+
+		if err := Mailer.SendEmail(recipient.Email, subject, body.String()); err != nil {
+			log.Printf("运营日报发送给 %s 失败: %v", recipient.Email, err)
+		}
+	}
+	log.Printf("运营日报 %s 已发送给 %d 位收件人", start.Format("2006-01-02"), len(recipients))
+}
+
+// 订单概况：按状态统计当日新增订单
+func digestOrders(start, end time.Time) string {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	DB.Model(&Order{}).Select("status, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("status").Scan(&rows)
+
+	var total int64
+	var lines []string
+	for _, row := range rows {
+		total += row.Count
+		lines = append(lines, fmt.Sprintf("  %s: %d", row.Status, row.Count))
+	}
+	return fmt.Sprintf("【订单】新增订单 %d 笔\n%s\n", total, strings.Join(lines, "\n"))
+}
+
+// 营收：当日新增且未取消订单的金额，按支付方式拆分
+func digestRevenue(start, end time.Time) string {
+	var rows []struct {
+		PaymentMethod string
+		Amount        float64
+	}
+	DB.Model(&Order{}).Select("payment_method, SUM(total_amount) AS amount").
+		Where("created_at >= ? AND created_at < ? AND status <> ?", start, end, OrderStatusCancelled).
+		Group("payment_method").Scan(&rows)
+
+	var total float64
+	var lines []string
+	for _, row := range rows {
+		total += row.Amount
+		lines = append(lines, fmt.Sprintf("  %s: %.2f 元", row.PaymentMethod, row.Amount))
+	}
+	return fmt.Sprintf("【营收】下单金额 %.2f 元（不含已取消订单）\n%s\n", total, strings.Join(lines, "\n"))
+}
+
+// 低库存商品
+func digestLowStock() string {
+	var products []Product
+	DB.Where("status = ? AND stock <= ?", 1, AppConfig.LowStockThreshold).
+		Order("stock ASC").Limit(digestLowStockLimit).Find(&products)
+
+	if len(products) == 0 {
+		return fmt.Sprintf("【低库存】无库存低于 %d 的在售商品\n", AppConfig.LowStockThreshold)
+	}
+	lines := make([]string, 0, len(products))
+	for _, product := range products {
+		lines = append(lines, fmt.Sprintf("  #%d %s 库存 %d", product.ID, product.Name, product.Stock))
+	}
+	return fmt.Sprintf("【低库存】库存低于 %d 的在售商品（最多列出%d个）\n%s\n", AppConfig.LowStockThreshold, digestLowStockLimit, strings.Join(lines, "\n"))
+}
+
+// 待交回平台的货到付款
+func digestCOD() string {
+	var result struct {
+		Count  int64
+		Amount float64
+	}
+	DB.Model(&CODSettlement{}).Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("status = ?", CODStatusCollected).Scan(&result)
+	return fmt.Sprintf("【货到付款】待交回 %d 笔，共 %.2f 元\n", result.Count, result.Amount)
+}
+
+// 待处理询价
+func digestQuotes() string {
+	var count int64
+	DB.Model(&Quote{}).Where("status = ?", QuoteStatusPending).Count(&count)
+	return fmt.Sprintf("【大宗询价】待报价 %d 单\n", count)
+}
@@ -0,0 +1,283 @@
+package gomall
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 商品类型
+const (
+	ProductTypePhysical = "physical" // 实物商品，需要配送
+	ProductTypeDigital  = "digital"  // 虚拟商品，付款后在线交付
+)
+
+// 从商品的下载地址转发文件，文件可能很大，不限制整体时长，只限制等待源站响应的时间。
+// 下载地址由商家填写，只能连接白名单域名解析出的公网地址；不使用代理，否则检查的是代理的地址
+var digitalDownloadClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, Control: publicAddressOnly}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	},
+	// 跳转后的地址同样需要满足下载地址的限制
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return fmt.Errorf("下载地址跳转次数过多")
+		}
+		return validateDownloadURL(req.URL.String())
+	},
+}
+
+// 校验虚拟商品的下载地址：必须是https链接，且域名在 DIGITAL_DOWNLOAD_HOSTS 白名单中
+func validateDownloadURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("下载地址必须是https链接")
+	}
+	if !downloadHostAllowed(u.Hostname()) {
+		return fmt.Errorf("下载地址的域名 %s 不在允许范围内", u.Hostname())
+	}
+	return nil
+}
+
+// 下载地址的域名是否在白名单中，以 . 开头的白名单项匹配其所有子域名
+func downloadHostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range splitEnvList(AppConfig.DigitalDownloadHosts) {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 建立连接前检查实际连接的IP，拒绝内网、回环和链路本地地址（如云主机元数据接口），
+// 在连接时检查可以防止域名解析结果被篡改为内网地址
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("下载地址解析到了非公网地址 %s", host)
+	}
+	return nil
+}
+
+// 激活码字符集，去掉容易混淆的 0、O、1、I
+const licenseCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// IsDigital 是否为虚拟商品
+func (p *Product) IsDigital() bool {
+	return p.ProductType == ProductTypeDigital
+}
+
+// 生成形如 XXXXX-XXXXX-XXXXX-XXXXX 的激活码
+func generateLicenseCode() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, b := range buf {
+		if i > 0 && i%5 == 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(licenseCodeAlphabet[int(b)%len(licenseCodeAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+func digitalDownloadLink(token string) string {
+	return "/api/downloads/" + token
+}
+
+// 订单付款后为虚拟商品生成交付记录：有下载地址的商品每个订单项生成一个下载链接，
+// 否则每件生成一个激活码。订单只包含虚拟商品时直接完成，不经过发货流程
+func fulfillDigitalItems(tx *gorm.DB, order *Order) error {
+	var items []OrderItem
+	if err := tx.Preload("Product").Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	var existing int64
+	if err := tx.Model(&DigitalDelivery{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+	if hours := AppConfig.DigitalDownloadExpireHours; hours > 0 {
+		t := time.Now().Add(time.Duration(hours) * time.Hour)
+		expiresAt = &t
+	}
+
+	allDigital := len(items) > 0
+	for _, item := range items {
+		if !item.Product.IsDigital() {
+			allDigital = false
+			continue
+		}
+		// 重复付款回调时不重复发放
+		if existing > 0 {
+			continue
+		}
+
+		delivery := DigitalDelivery{
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			UserID:      order.UserID,
+			ProductID:   item.ProductID,
+		}
+		if item.Product.DownloadURL != "" {
+			token, err := generateSecureToken(32)
+			if err != nil {
+				return err
+			}
+			delivery.DownloadToken = token
+			delivery.ExpiresAt = expiresAt
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		for i := 0; i < item.Quantity; i++ {
+			code, err := generateLicenseCode()
+			if err != nil {
+				return err
+			}
+			license := delivery
+			license.LicenseCode = code
+			if err := tx.Create(&license).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if allDigital {
+		if err := transitionOrder(tx, order, OrderStatusCompleted, systemOrderActor, "虚拟商品已发放"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrderDigitalDeliveries 获取订单的虚拟商品
+// @Summary 获取订单的虚拟商品
+// @Description 获取已付款订单中虚拟商品的下载链接和激活码，下载链接过期后不能再使用
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=[]DigitalDelivery} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/digital [get]
+func GetOrderDigitalDeliveries(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	var order Order
+	if err := DB.Where("id = ? AND user_id = ?", orderID, c.GetUint("user_id")).First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	var deliveries []DigitalDelivery
+	if err := DB.Where("order_id = ?", order.ID).Order("id ASC").Find(&deliveries).Error; err != nil {
+		InternalServerError(c, "虚拟商品查询失败")
+		return
+	}
+	for i := range deliveries {
+		if deliveries[i].DownloadToken != "" {
+			deliveries[i].DownloadLink = digitalDownloadLink(deliveries[i].DownloadToken)
+		}
+	}
+
+	SuccessResponse(c, deliveries)
+}
+
+// DownloadDigitalProduct 下载虚拟商品
+// @Summary 下载虚拟商品
+// @Description 通过付款后生成的下载链接下载虚拟商品，校验有效期后由服务端从商品的下载地址转发文件，不向用户暴露源地址，下载链接过期后无法再下载。只转发 DIGITAL_DOWNLOAD_HOSTS 白名单域名下解析到公网地址的https链接
+// @Tags 订单管理
+// @Produce application/octet-stream
+// @Param token path string true "下载令牌"
+// @Success 200 {file} file "商品文件"
+// @Failure 404 {object} ApiResponse "下载链接无效"
+// @Failure 410 {object} ApiResponse "下载链接已过期"
+// @Failure 502 {object} ApiResponse "下载地址暂时无法访问或不满足转发限制"
+// @Router /api/downloads/{token} [get]
+func DownloadDigitalProduct(c *gin.Context) {
+	var delivery DigitalDelivery
+	if err := DB.Where("download_token = ?", c.Param("token")).First(&delivery).Error; err != nil {
+		NotFoundError(c, "下载链接无效")
+		return
+	}
+	if delivery.ExpiresAt != nil && time.Now().After(*delivery.ExpiresAt) {
+		ErrorResponse(c, http.StatusGone, "下载链接已过期")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id, download_url").First(&product, delivery.ProductID).Error; err != nil || product.DownloadURL == "" {
+		NotFoundError(c, "下载地址不存在")
+		return
+	}
+
+	// 白名单可能在商品保存后调整过，转发前重新校验
+	if err := validateDownloadURL(product.DownloadURL); err != nil {
+		log.Printf("商品 %d 的下载地址不可转发: %v", product.ID, err)
+		ErrorResponse(c, http.StatusBadGateway, "下载地址暂时无法访问")
+		return
+	}
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, product.DownloadURL, nil)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadGateway, "下载地址暂时无法访问")
+		return
+	}
+	resp, err := digitalDownloadClient.Do(req)
+	if err != nil {
+		log.Printf("商品 %d 的下载地址请求失败: %v", product.ID, err)
+		ErrorResponse(c, http.StatusBadGateway, "下载地址暂时无法访问")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ErrorResponse(c, http.StatusBadGateway, "下载地址暂时无法访问")
+		return
+	}
+
+	DB.Model(&delivery).UpdateColumn("download_count", gorm.Expr("download_count + ?", 1))
+	disposition := resp.Header.Get("Content-Disposition")
+	if disposition == "" {
+		disposition = fmt.Sprintf("attachment; filename=%q", path.Base(resp.Request.URL.Path))
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, resp.Body, map[string]string{
+		"Content-Disposition": disposition,
+		"Cache-Control":       "no-store",
+	})
+}
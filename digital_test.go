@@ -0,0 +1,81 @@
+package gomall_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 下载地址只能是白名单域名下的https链接
+func TestProductDownloadURLValidation(t *testing.T) {
+	kit := testkit.New(t)
+	path := "/api/products/" + strconv.FormatUint(uint64(kit.Fixtures.Product.ID), 10)
+
+	cases := []struct {
+		url    string
+		status int
+	}{
+		{"http://files.example.com/a.zip", http.StatusBadRequest},
+		{"https://169.254.169.254/latest/meta-data/", http.StatusBadRequest},
+		{"https://files.example.com.evil.test/a.zip", http.StatusBadRequest},
+		{"https://files.example.com/a.zip", http.StatusOK},
+	}
+	for _, tc := range cases {
+		resp := kit.DoAs(kit.Fixtures.Admin, http.MethodPut, path, map[string]string{"download_url": tc.url})
+		if resp.StatusCode != tc.status {
+			t.Errorf("设置下载地址 %s 返回 %d，期望 %d: %s", tc.url, resp.StatusCode, tc.status, resp.Body)
+		}
+	}
+}
+
+// 即使域名在白名单中，解析到内网或回环地址时也不转发
+func TestDownloadRefusesPrivateAddresses(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+
+	// 统计建立的连接而不是请求，证书不受信任时请求也到不了服务端
+	var conns atomic.Int32
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.StartTLS()
+	defer upstream.Close()
+	kit.Config.DigitalDownloadHosts = "files.example.com,127.0.0.1"
+
+	product := kit.CreateProduct(f.Seller.ID, gomall.Product{
+		Name:        "内网商品",
+		Price:       10,
+		Stock:       10,
+		ProductType: gomall.ProductTypeDigital,
+		DownloadURL: upstream.URL + "/file.zip",
+	})
+	delivery := gomall.DigitalDelivery{
+		OrderID:       1,
+		OrderItemID:   1,
+		UserID:        f.Customer.ID,
+		ProductID:     product.ID,
+		DownloadToken: "testkit-private-download",
+	}
+	if err := kit.DB.Create(&delivery).Error; err != nil {
+		t.Fatalf("交付记录创建失败: %v", err)
+	}
+
+	resp := kit.Do(http.MethodGet, "/api/downloads/"+delivery.DownloadToken, "", nil)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("转发回环地址返回 %d，期望 502: %s", resp.StatusCode, resp.Body)
+	}
+	if conns.Load() != 0 {
+		t.Fatalf("下载请求连接了回环地址上的服务")
+	}
+}
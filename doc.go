@@ -0,0 +1,7 @@
+// Package gomall 是 GoMall 电商平台的服务端。
+//
+// 独立运行时由 cmd/gomall 调用 Main；嵌入到其他程序时通过 New 创建 http.Handler，
+// 挂载到宿主的路由中：
+//
+//	handler, err := gomall.New(gomall.WithDB(db), gomall.WithRedis(rdb), gomall.WithRoutesPrefix("/shop"))
+package gomall
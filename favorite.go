@@ -0,0 +1,146 @@
+package gomall
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddFavoriteRequest 收藏商品
+type AddFavoriteRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+}
+
+// 查询用户是否已收藏商品
+func isProductFavorited(userID, productID uint) bool {
+	var count int64
+	DB.Model(&Favorite{}).Where("user_id = ? AND product_id = ?", userID, productID).Count(&count)
+	return count > 0
+}
+
+// 登录用户查看商品详情时标记是否已收藏，需在写入缓存之后调用
+func markProductFavorited(c *gin.Context, product *Product) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		return
+	}
+	favorited := isProductFavorited(userID, product.ID)
+	product.Favorited = &favorited
+}
+
+// AddFavorite 收藏商品
+// @Summary 收藏商品
+// @Description 将商品加入当前用户的收藏夹，重复收藏时返回已有的收藏记录
+// @Tags 商品收藏
+// @Accept json
+// @Produce json
+// @Param favorite body AddFavoriteRequest true "收藏的商品"
+// @Success 200 {object} ApiResponse{data=Favorite} "收藏成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或商品已下架"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/favorites [post]
+func AddFavorite(c *gin.Context) {
+	var req AddFavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID := c.GetUint("user_id")
+
+	var product Product
+	if err := DB.First(&product, req.ProductID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+	if product.Status != 1 {
+		BadRequestError(c, "商品已下架")
+		return
+	}
+
+	favorite := Favorite{UserID: userID, ProductID: product.ID}
+	if err := DB.Where("user_id = ? AND product_id = ?", userID, product.ID).FirstOrCreate(&favorite).Error; err != nil {
+		InternalServerError(c, "收藏失败")
+		return
+	}
+	favorite.Product = product
+
+	SuccessResponse(c, favorite)
+}
+
+// RemoveFavorite 取消收藏
+// @Summary 取消收藏
+// @Description 将商品从当前用户的收藏夹中移除
+// @Tags 商品收藏
+// @Accept json
+// @Produce json
+// @Param product_id path int true "商品ID"
+// @Success 200 {object} ApiResponse "取消成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID"
+// @Failure 404 {object} ApiResponse "未收藏该商品"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/favorites/{product_id} [delete]
+func RemoveFavorite(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	result := DB.Where("user_id = ? AND product_id = ?", c.GetUint("user_id"), productID).Delete(&Favorite{})
+	if result.Error != nil {
+		InternalServerError(c, "取消收藏失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "未收藏该商品")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// GetFavorites 获取收藏列表
+// @Summary 获取收藏列表
+// @Description 分页获取当前用户收藏的商品，按收藏时间倒序；已下架的商品仍会保留在列表中，可通过商品状态区分
+// @Tags 商品收藏
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Favorite}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/favorites [get]
+func GetFavorites(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&Favorite{}).Where("user_id = ?", c.GetUint("user_id"))
+
+	var total int64
+	query.Count(&total)
+
+	var favorites []Favorite
+	if err := query.Preload("Product").Order("created_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&favorites).Error; err != nil {
+		InternalServerError(c, "收藏列表查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, favorites, total, page, pageSize)
+}
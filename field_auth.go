@@ -0,0 +1,244 @@
+package gomall
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字段级权限控制
+//
+// 模型字段通过标签声明可见范围，响应序列化时统一过滤，处理函数无需逐个处理：
+//
+//	visible:"admin,seller"  只有列出的角色可见，其他用户看不到该字段
+//	visible:"admin,owner"   owner 表示数据归属于当前用户（见 ownedResource）
+//	mask:"phone"            不可见时返回脱敏值而不是删除字段，支持 phone、email、name
+const (
+	fieldVisibleTag = "visible"
+	fieldMaskTag    = "mask"
+	ownerPseudoRole = "owner"
+)
+
+// ownedResource 归属于某个用户的数据，用于判断 owner 可见性
+type ownedResource interface {
+	OwnerUserID() uint
+}
+
+// OwnerUserID 用户资料归属于用户本人
+func (u User) OwnerUserID() uint { return u.ID }
+
+// OwnerUserID 订单归属于下单用户
+func (o Order) OwnerUserID() uint { return o.UserID }
+
+// OwnerUserID 商品归属于创建商品的商家，管理员创建的商品没有归属
+func (p Product) OwnerUserID() uint {
+	if p.SellerID == nil {
+		return 0
+	}
+	return *p.SellerID
+}
+
+// 当前请求的查看者
+type fieldViewer struct {
+	userID uint
+	roles  map[string]bool
+}
+
+func viewerFromContext(c *gin.Context) fieldViewer {
+	viewer := fieldViewer{roles: make(map[string]bool)}
+	if userID, ok := c.Get("user_id"); ok {
+		viewer.userID, _ = userID.(uint)
+	}
+	if value, ok := c.Get("roles"); ok {
+		roles, _ := value.([]string)
+		for _, role := range roles {
+			viewer.roles[role] = true
+		}
+	}
+	return viewer
+}
+
+// 查看者是否满足字段的可见范围
+func (v fieldViewer) canSee(visible string, owner uint) bool {
+	for _, role := range strings.Split(visible, ",") {
+		role = strings.TrimSpace(role)
+		if role == ownerPseudoRole {
+			if v.userID != 0 && owner == v.userID {
+				return true
+			}
+		} else if v.roles[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// 字段脱敏
+func maskFieldValue(kind string, value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return value
+	}
+	switch kind {
+	case "phone":
+		if len(s) >= 7 {
+			return s[:3] + strings.Repeat("*", len(s)-7) + s[len(s)-4:]
+		}
+	case "email":
+		if name, domain, found := strings.Cut(s, "@"); found {
+			return maskUsername(name) + "@" + domain
+		}
+	case "name":
+		return maskUsername(s)
+	}
+	return strings.Repeat("*", len([]rune(s)))
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	// 类型是否包含受控字段的缓存
+	restrictedTypeCache sync.Map
+)
+
+// 判断类型中是否可能包含受控字段，不包含时直接原样序列化
+func hasRestrictedFields(t reflect.Type) bool {
+	if cached, ok := restrictedTypeCache.Load(t); ok {
+		return cached.(bool)
+	}
+	// 先标记为false，防止 User -> Order -> User 这类递归类型死循环
+	restrictedTypeCache.Store(t, false)
+	result := computeRestrictedFields(t)
+	restrictedTypeCache.Store(t, result)
+	return result
+}
+
+func computeRestrictedFields(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		// 接口的实际类型只能在运行时判断
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasRestrictedFields(t.Elem())
+	case reflect.Struct:
+		if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			return false
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+			if field.Tag.Get(fieldVisibleTag) != "" || hasRestrictedFields(field.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterFields 按查看者权限过滤响应数据中的受控字段
+func FilterFields(c *gin.Context, data interface{}) interface{} {
+	if data == nil {
+		return nil
+	}
+	return filterValue(reflect.ValueOf(data), viewerFromContext(c))
+}
+
+func filterValue(v reflect.Value, viewer fieldViewer) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !hasRestrictedFields(v.Type()) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return filterValue(v.Elem(), viewer)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = filterValue(v.Index(i), viewer)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		result := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), reflect.TypeOf((*interface{})(nil)).Elem()), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			filtered := filterValue(iter.Value(), viewer)
+			if filtered == nil {
+				result.SetMapIndex(iter.Key(), reflect.Zero(result.Type().Elem()))
+			} else {
+				result.SetMapIndex(iter.Key(), reflect.ValueOf(filtered))
+			}
+		}
+		return result.Interface()
+	case reflect.Struct:
+		result := make(map[string]interface{})
+		filterStruct(v, viewer, result)
+		return result
+	}
+	return v.Interface()
+}
+
+// 将结构体按json标签展开为map，匿名嵌入的结构体字段提升到外层
+func filterStruct(v reflect.Value, viewer fieldViewer, result map[string]interface{}) {
+	var owner uint
+	if resource, ok := v.Interface().(ownedResource); ok {
+		owner = resource.OwnerUserID()
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			// 外层字段优先于嵌入结构体的同名字段
+			embedded := make(map[string]interface{})
+			filterStruct(fieldValue, viewer, embedded)
+			for key, value := range embedded {
+				if _, exists := result[key]; !exists {
+					result[key] = value
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && fieldValue.IsZero() {
+			continue
+		}
+
+		value := filterValue(fieldValue, viewer)
+		if visible := field.Tag.Get(fieldVisibleTag); visible != "" && !viewer.canSee(visible, owner) {
+			mask := field.Tag.Get(fieldMaskTag)
+			if mask == "" {
+				continue
+			}
+			value = maskFieldValue(mask, value)
+		}
+		result[name] = value
+	}
+}
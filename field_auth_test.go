@@ -0,0 +1,95 @@
+package gomall_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 成本价、下载地址和审核意见只对管理员和商品所属商家可见
+func TestProductSensitiveFieldsVisibility(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+	otherSeller := kit.CreateUser("other-seller", gomall.RoleSeller)
+	path := "/api/products/" + strconv.FormatUint(uint64(f.Product.ID), 10)
+	if err := kit.DB.Model(&f.Product).Update("review_note", "主图需要更换").Error; err != nil {
+		t.Fatalf("审核意见写入失败: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		token   string
+		visible bool
+	}{
+		{"anonymous", "", false},
+		{"customer", kit.Token(f.Customer), false},
+		{"other seller", kit.Token(otherSeller), false},
+		{"owner seller", kit.Token(f.Seller), true},
+		{"admin", kit.Token(f.Admin), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := kit.Do(http.MethodGet, path, tc.token, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("商品详情返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+			}
+			var product map[string]interface{}
+			if err := resp.Decode(&product); err != nil {
+				t.Fatalf("响应解析失败: %v", err)
+			}
+			for _, field := range []string{"cost_price", "download_url", "review_note"} {
+				if _, ok := product[field]; ok != tc.visible {
+					t.Errorf("%s 可见性为 %v，期望 %v", field, ok, tc.visible)
+				}
+			}
+		})
+	}
+}
+
+// 订单商品的成本价快照只对管理员可见，商家角色的用户购买其他店铺的商品时也看不到
+func TestOrderItemCostPriceHiddenFromBuyingSeller(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+	buyer := kit.CreateUser("buying-seller", gomall.RoleSeller)
+
+	order := gomall.Order{
+		UserID:      buyer.ID,
+		OrderNo:     "TESTKIT0001",
+		TotalAmount: f.Product.Price,
+		OrderItems: []gomall.OrderItem{{
+			ProductID: f.Product.ID,
+			Quantity:  1,
+			Price:     f.Product.Price,
+			CostPrice: f.Product.CostPrice,
+		}},
+	}
+	if err := kit.DB.Create(&order).Error; err != nil {
+		t.Fatalf("订单创建失败: %v", err)
+	}
+
+	resp := kit.DoAs(buyer, http.MethodGet, "/api/orders/"+strconv.FormatUint(uint64(order.ID), 10), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("订单详情返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+	}
+	var detail struct {
+		OrderItems []map[string]interface{} `json:"order_items"`
+	}
+	if err := resp.Decode(&detail); err != nil {
+		t.Fatalf("响应解析失败: %v", err)
+	}
+	if len(detail.OrderItems) != 1 {
+		t.Fatalf("订单商品数量为 %d，期望 1", len(detail.OrderItems))
+	}
+	item := detail.OrderItems[0]
+	if _, ok := item["cost_price"]; ok {
+		t.Errorf("订单商品的 cost_price 对购买商品的商家可见")
+	}
+	if product, _ := item["product"].(map[string]interface{}); product != nil {
+		if _, ok := product["cost_price"]; ok {
+			t.Errorf("订单商品关联商品的 cost_price 对购买商品的商家可见")
+		}
+	}
+}
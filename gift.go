@@ -0,0 +1,167 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GiftItemView 收礼人看到的礼物商品，不含价格
+type GiftItemView struct {
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Image       string `json:"image"`
+	Quantity    int    `json:"quantity"`
+}
+
+// GiftReceivedView 收礼人看到的礼物订单，隐藏订单金额、商品价格和支付信息
+type GiftReceivedView struct {
+	OrderID         uint            `json:"order_id"`
+	OrderNo         string          `json:"order_no"`
+	Status          string          `json:"status"`
+	Sender          string          `json:"sender"` // 送礼人用户名
+	GiftMessage     string          `json:"gift_message"`
+	ShippingAddress string          `json:"shipping_address"`
+	Items           []GiftItemView  `json:"items"`
+	DeliveryProofs  []DeliveryProof `json:"delivery_proofs,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// 按用户名或手机号查找收礼人，不能送给自己
+func findGiftRecipient(senderID uint, recipient string) (*User, error) {
+	recipient = strings.TrimSpace(recipient)
+	var user User
+	if err := DB.Where("username = ? OR phone = ?", recipient, recipient).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("收礼人 %s 不存在", recipient)
+	}
+	if user.Status != 1 {
+		return nil, fmt.Errorf("收礼人账号不可用")
+	}
+	if user.ID == senderID {
+		return nil, fmt.Errorf("不能给自己送礼")
+	}
+	return &user, nil
+}
+
+// 礼物订单创建后通知收礼人，通知中不包含金额
+func notifyGiftRecipient(order *Order) {
+	if order.RecipientID == nil {
+		return
+	}
+	var sender User
+	DB.Select("username").First(&sender, order.UserID)
+
+	content := fmt.Sprintf("%s 送您的礼物（订单号 %s）已下单，将配送至您的收货地址。", sender.Username, order.OrderNo)
+	if order.GiftMessage != "" {
+		content += "留言：" + order.GiftMessage
+	}
+	SendNotification(*order.RecipientID, NotificationTypeOrder, "您收到一份礼物", content)
+}
+
+func giftReceivedView(order *Order) GiftReceivedView {
+	view := GiftReceivedView{
+		OrderID:         order.ID,
+		OrderNo:         order.OrderNo,
+		Status:          order.Status,
+		Sender:          order.User.Username,
+		GiftMessage:     order.GiftMessage,
+		ShippingAddress: order.ShippingAddress,
+		Items:           make([]GiftItemView, 0, len(order.OrderItems)),
+		DeliveryProofs:  order.DeliveryProofs,
+		CreatedAt:       order.CreatedAt,
+	}
+	for _, item := range order.OrderItems {
+		view.Items = append(view.Items, GiftItemView{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Image:       item.ProductImage,
+			Quantity:    item.Quantity,
+		})
+	}
+	return view
+}
+
+// GetReceivedGifts 获取收到的礼物
+// @Summary 获取收到的礼物
+// @Description 分页查看其他用户送给我的礼物订单，不显示订单金额和商品价格
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]GiftReceivedView}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/gifts-received [get]
+func GetReceivedGifts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&Order{}).Where("recipient_id = ?", userID)
+
+	var total int64
+	query.Count(&total)
+
+	var orders []Order
+	if err := query.Preload("User").Preload("OrderItems.Product").
+		Order("created_at DESC").Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&orders).Error; err != nil {
+		InternalServerError(c, "礼物查询失败")
+		return
+	}
+
+	gifts := make([]GiftReceivedView, 0, len(orders))
+	for i := range orders {
+		gifts = append(gifts, giftReceivedView(&orders[i]))
+	}
+	PaginationSuccessResponse(c, gifts, total, page, pageSize)
+}
+
+// GetReceivedGift 获取收到的礼物详情
+// @Summary 获取收到的礼物详情
+// @Description 查看礼物订单的配送状态和签收凭证，不显示订单金额和商品价格
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=GiftReceivedView} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "礼物订单不存在"
+// @Security Bearer
+// @Router /api/orders/gifts-received/{id} [get]
+func GetReceivedGift(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var order Order
+	if err := DB.Preload("User").Preload("OrderItems.Product").Preload("DeliveryProofs").
+		Where("id = ? AND recipient_id = ?", orderID, userID).
+		First(&order).Error; err != nil {
+		NotFoundError(c, "礼物订单不存在")
+		return
+	}
+
+	SuccessResponse(c, giftReceivedView(&order))
+}
@@ -0,0 +1,395 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 库存变动类型
+const (
+	MovementTypeOrder    = "order"    // 订单付款或发货扣减
+	MovementTypeCancel   = "cancel"   // 订单取消退回
+	MovementTypeManual   = "manual"   // 后台调整、盘点或编辑商品库存
+	MovementTypeTransfer = "transfer" // 仓库间调拨
+	MovementTypeImport   = "import"   // 批量导入商品
+	MovementTypePurchase = "purchase" // 采购单收货入库
+	MovementTypeWMS      = "wms"      // 外部仓储系统回传库存
+	MovementTypeReturn   = "return"   // 退款退货入库
+)
+
+// 手工调整原因
+const (
+	AdjustReasonDamage  = "damage"  // 报损
+	AdjustReasonRecount = "recount" // 盘点修正
+	AdjustReasonReturn  = "return"  // 退货入库
+)
+
+// AdjustInventoryRequest 库存调整请求
+type AdjustInventoryRequest struct {
+	WarehouseID *uint  `json:"warehouse_id"` // 分仓管理的商品必填
+	ProductID   uint   `json:"product_id" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required"` // 调整数量，正数入库，负数出库
+	Reason      string `json:"reason" binding:"required,oneof=damage recount return"`
+	Note        string `json:"note" binding:"max=200"`
+}
+
+// StocktakeItem 盘点的单个商品
+type StocktakeItem struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Counted   int  `json:"counted" binding:"min=0"` // 实盘数量
+}
+
+// StocktakeRequest 盘点请求
+type StocktakeRequest struct {
+	WarehouseID *uint           `json:"warehouse_id"` // 盘点的仓库，商品未分仓管理时不填
+	Items       []StocktakeItem `json:"items" binding:"required,min=1,max=200,dive"`
+	Note        string          `json:"note" binding:"max=200"`
+}
+
+// StocktakeLine 盘点结果
+type StocktakeLine struct {
+	ProductID  uint `json:"product_id"`
+	Previous   int  `json:"previous"`   // 盘点前的账面数量
+	Counted    int  `json:"counted"`    // 实盘数量
+	Difference int  `json:"difference"` // 盘盈为正，盘亏为负
+}
+
+// 当前操作的管理员
+func operatorID(c *gin.Context) *uint {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return &userID
+	}
+	return nil
+}
+
+// 写入库存流水，变动后的商品总库存在写入时读取，调用前需已完成库存修改
+func recordInventoryMovement(tx *gorm.DB, movement InventoryMovement) error {
+	if movement.Quantity == 0 {
+		return nil
+	}
+	if err := tx.Model(&Product{}).Where("id = ?", movement.ProductID).Select("stock").Scan(&movement.StockAfter).Error; err != nil {
+		return err
+	}
+	return tx.Create(&movement).Error
+}
+
+// 记录编辑或导入商品时直接修改的库存
+func recordStockEdit(tx *gorm.DB, productID uint, oldStock, newStock int, movementType string, changedBy uint) error {
+	movement := InventoryMovement{
+		ProductID: productID,
+		Type:      movementType,
+		Quantity:  newStock - oldStock,
+	}
+	if changedBy != 0 {
+		movement.OperatorID = &changedBy
+	}
+	return recordInventoryMovement(tx, movement)
+}
+
+// 商品在仓库中（warehouseID 为空时为商品总库存）的账面数量，商品首次入仓时原有库存计入该仓库
+func locationStock(tx *gorm.DB, warehouseID *uint, productID uint) (int, error) {
+	var product Product
+	if err := tx.Select("id, stock").First(&product, productID).Error; err != nil {
+		return 0, fmt.Errorf("商品 %d 不存在", productID)
+	}
+	managed := productHasWarehouseStock(tx, productID)
+	if warehouseID == nil {
+		if managed {
+			return 0, fmt.Errorf("商品 %d 已分仓管理，请指定仓库", productID)
+		}
+		return product.Stock, nil
+	}
+
+	var record InventoryRecord
+	err := tx.Where("warehouse_id = ? AND product_id = ?", *warehouseID, productID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if managed {
+			return 0, nil
+		}
+		return product.Stock, nil
+	}
+	return record.Quantity, err
+}
+
+// 在事务中调整商品库存并写入流水，warehouseID 不为空时同时调整该仓库的库存。
+// 出库数量不能超过未被待付款订单占用的库存。返回调整前后的商品总库存
+func adjustStock(tx *gorm.DB, warehouseID *uint, productID uint, delta int, movement InventoryMovement) (oldStock, newStock int, err error) {
+	available, err := lockAvailableStock(tx, []uint{productID})
+	if err != nil {
+		return 0, 0, err
+	}
+	current, err := locationStock(tx, warehouseID, productID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if delta < 0 {
+		free := available[productID]
+		if warehouseID != nil {
+			reserved, err := warehouseReservedStock(tx, []uint{productID})
+			if err != nil {
+				return 0, 0, err
+			}
+			// 仓库中还要扣除已分配到该仓库的订单，取两者较小值
+			if warehouseFree := current - reserved[*warehouseID][productID]; warehouseFree < free {
+				free = warehouseFree
+			}
+		}
+		if free+delta < 0 {
+			return 0, 0, fmt.Errorf("商品 %d 可用库存不足，当前可用: %d", productID, free)
+		}
+	}
+
+	if warehouseID != nil {
+		record := InventoryRecord{WarehouseID: *warehouseID, ProductID: productID}
+		if err := tx.Where(&record).FirstOrInit(&record).Error; err != nil {
+			return 0, 0, err
+		}
+		record.Quantity = current + delta
+		if err := tx.Save(&record).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var product Product
+	if err := tx.Select("id, stock").First(&product, productID).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Model(&Product{}).Where("id = ?", productID).
+		UpdateColumn("stock", gorm.Expr("stock + ?", delta)).Error; err != nil {
+		return 0, 0, err
+	}
+
+	movement.ProductID = productID
+	movement.WarehouseID = warehouseID
+	movement.Quantity = delta
+	if err := recordInventoryMovement(tx, movement); err != nil {
+		return 0, 0, err
+	}
+	return product.Stock, product.Stock + delta, nil
+}
+
+// 库存调整后同步Redis可售库存和商品缓存
+func syncAdjustedStock(productID uint, oldStock, newStock int) {
+	GlobalStockManager.SetStock(productID, newStock)
+	notifyRestockIfReplenished(productID, oldStock, newStock)
+	DeleteCachedProduct(productID)
+}
+
+// AdjustInventory 调整库存
+// @Summary 调整库存
+// @Description 按原因码（damage 报损、recount 盘点修正、return 退货入库）入库（正数）或出库（负数），每次调整写入库存流水。分仓管理的商品需指定仓库；商品首次指定仓库入库时原有库存计入该仓库，之后只能按仓库调整。出库数量不能超过未被待付款订单占用的库存
+// @Tags 库存管理
+// @Accept json
+// @Produce json
+// @Param adjustment body AdjustInventoryRequest true "调整信息"
+// @Success 200 {object} ApiResponse{data=[]WarehouseStock} "调整成功，返回商品分仓库存"
+// @Failure 400 {object} ApiResponse "参数验证失败或库存不足"
+// @Failure 404 {object} ApiResponse "仓库不存在"
+// @Security Bearer
+// @Router /api/admin/inventory/adjustments [post]
+func AdjustInventory(c *gin.Context) {
+	var req AdjustInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	if req.WarehouseID != nil {
+		if _, ok := findWarehouse(c, *req.WarehouseID); !ok {
+			return
+		}
+	}
+
+	movement := InventoryMovement{
+		Type:       MovementTypeManual,
+		Reason:     req.Reason,
+		OperatorID: operatorID(c),
+		Note:       req.Note,
+	}
+	var oldStock, newStock int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		oldStock, newStock, err = adjustStock(tx, req.WarehouseID, req.ProductID, req.Quantity, movement)
+		return err
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	syncAdjustedStock(req.ProductID, oldStock, newStock)
+
+	stocks, _ := productWarehouseStocks(req.ProductID)
+	SuccessResponse(c, stocks)
+}
+
+// StocktakeInventory 提交盘点结果
+// @Summary 提交盘点结果
+// @Description 按实盘数量修正账面库存，差异按 recount 原因写入库存流水；盘点的商品在同一事务中处理，任一商品失败（如盘亏后库存不足以覆盖待付款订单）时全部不生效
+// @Tags 库存管理
+// @Accept json
+// @Produce json
+// @Param stocktake body StocktakeRequest true "盘点结果"
+// @Success 200 {object} ApiResponse{data=[]StocktakeLine} "盘点完成，返回每个商品的差异"
+// @Failure 400 {object} ApiResponse "参数验证失败或库存不足"
+// @Failure 404 {object} ApiResponse "仓库不存在"
+// @Security Bearer
+// @Router /api/admin/inventory/stocktakes [post]
+func StocktakeInventory(c *gin.Context) {
+	var req StocktakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	if req.WarehouseID != nil {
+		if _, ok := findWarehouse(c, *req.WarehouseID); !ok {
+			return
+		}
+	}
+
+	productIDs := make([]uint, len(req.Items))
+	seen := make(map[uint]bool, len(req.Items))
+	for i, item := range req.Items {
+		if seen[item.ProductID] {
+			BadRequestError(c, fmt.Sprintf("商品 %d 重复", item.ProductID))
+			return
+		}
+		seen[item.ProductID] = true
+		productIDs[i] = item.ProductID
+	}
+
+	movement := InventoryMovement{
+		Type:       MovementTypeManual,
+		Reason:     AdjustReasonRecount,
+		OperatorID: operatorID(c),
+		Note:       req.Note,
+	}
+	lines := make([]StocktakeLine, 0, len(req.Items))
+	stockChanges := make(map[uint][2]int)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 先按顺序锁定全部商品，盘点期间账面数量不会被订单修改
+		if _, err := lockAvailableStock(tx, productIDs); err != nil {
+			return err
+		}
+		for _, item := range req.Items {
+			previous, err := locationStock(tx, req.WarehouseID, item.ProductID)
+			if err != nil {
+				return err
+			}
+			difference := item.Counted - previous
+			lines = append(lines, StocktakeLine{
+				ProductID:  item.ProductID,
+				Previous:   previous,
+				Counted:    item.Counted,
+				Difference: difference,
+			})
+			if difference == 0 {
+				continue
+			}
+			oldStock, newStock, err := adjustStock(tx, req.WarehouseID, item.ProductID, difference, movement)
+			if err != nil {
+				return err
+			}
+			stockChanges[item.ProductID] = [2]int{oldStock, newStock}
+		}
+		return nil
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	for productID, change := range stockChanges {
+		syncAdjustedStock(productID, change[0], change[1])
+	}
+
+	SuccessResponse(c, lines)
+}
+
+// GetInventoryMovements 获取商品库存流水
+// @Summary 获取商品库存流水
+// @Description 按时间倒序追溯商品的每次库存变动：订单扣减（order）、取消退回（cancel）、后台调整和盘点（manual，含原因码）、仓库调拨（transfer）、批量导入（import）、采购收货（purchase）、WMS回传（wms），每条记录变动后的商品总库存
+// @Tags 库存管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param warehouse_id query int false "仓库ID"
+// @Param type query string false "变动类型" Enums(order, cancel, manual, transfer, import, purchase, wms)
+// @Param start_date query string false "开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "结束日期（YYYY-MM-DD，含当天）"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]InventoryMovement}} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID或日期格式错误"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/products/{id}/inventory/movements [get]
+func GetInventoryMovements(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id").First(&product, productID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&InventoryMovement{}).Where("product_id = ?", product.ID)
+	if value := c.Query("warehouse_id"); value != "" {
+		if warehouseID, err := strconv.ParseUint(value, 10, 32); err == nil {
+			query = query.Where("warehouse_id = ?", warehouseID)
+		}
+	}
+	if value := c.Query("type"); value != "" {
+		query = query.Where("type = ?", value)
+	}
+	if value := c.Query("start_date"); value != "" {
+		start, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "开始日期格式错误")
+			return
+		}
+		query = query.Where("created_at >= ?", start)
+	}
+	if value := c.Query("end_date"); value != "" {
+		end, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "结束日期格式错误")
+			return
+		}
+		query = query.Where("created_at < ?", end.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var movements []InventoryMovement
+	if err := query.Order("created_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&movements).Error; err != nil {
+		InternalServerError(c, "库存流水查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, movements, total, page, pageSize)
+}
@@ -0,0 +1,313 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 库存审计发现的问题
+const (
+	AuditIssueLedgerMismatch = "ledger_mismatch" // 期间库存变化与库存流水合计不一致，存在未记流水的库存修改
+	AuditIssueSalesMismatch  = "sales_mismatch"  // 有效订单售出数量与订单扣减流水不一致
+	AuditIssueOversold       = "oversold"        // 期末库存低于允许的下限（预售商品为负的预售数量）
+)
+
+// 审计的最大天数
+const inventoryAuditMaxDays = 92
+
+// InventoryAuditLine 单个商品的审计结果
+type InventoryAuditLine struct {
+	ProductID        uint     `json:"product_id"`
+	ProductName      string   `json:"product_name"`
+	OpeningStock     *int     `json:"opening_stock"`     // 开始日期的快照库存，没有快照时为空
+	ClosingStock     *int     `json:"closing_stock"`     // 结束日期次日的快照库存，结束日期为今天时取当前库存
+	MovementNet      int      `json:"movement_net"`      // 期间库存流水合计
+	LedgerDifference int      `json:"ledger_difference"` // 期末减期初再减流水合计，缺少快照时为0
+	SoldQuantity     int      `json:"sold_quantity"`     // 期间创建的有效订单售出数量
+	DeductedQuantity int      `json:"deducted_quantity"` // 这些订单的库存扣减流水数量
+	SalesDifference  int      `json:"sales_difference"`  // 售出减扣减，大于0表示售出未扣库存
+	Issues           []string `json:"issues"`
+}
+
+// InventoryAuditReport 库存审计报告
+type InventoryAuditReport struct {
+	StartDate      string               `json:"start_date"`
+	EndDate        string               `json:"end_date"`
+	ProductCount   int                  `json:"product_count"` // 参与审计的商品数
+	LedgerMismatch int                  `json:"ledger_mismatch"`
+	SalesMismatch  int                  `json:"sales_mismatch"`
+	Oversold       int                  `json:"oversold"`
+	Products       []InventoryAuditLine `json:"products"` // 默认只返回有问题的商品
+}
+
+// 记录当天的库存快照，同一天已记录时不重复写入。返回写入的商品数
+func SnapshotInventory(now time.Time) (int, error) {
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// 一次查询读出全部商品库存，保证快照是同一时刻的数据
+	var products []Product
+	if err := DB.Select("id, stock").Find(&products).Error; err != nil {
+		return 0, fmt.Errorf("商品库存查询失败: %v", err)
+	}
+	if len(products) == 0 {
+		return 0, nil
+	}
+	var reserved []struct {
+		ProductID uint
+		Quantity  int
+	}
+	err := DB.Model(&StockReservation{}).
+		Select("product_id, SUM(quantity) AS quantity").
+		Where("status = ?", ReservationStatusReserved).
+		Group("product_id").
+		Scan(&reserved).Error
+	if err != nil {
+		return 0, fmt.Errorf("预占库存查询失败: %v", err)
+	}
+	reservedByProduct := make(map[uint]int, len(reserved))
+	for _, r := range reserved {
+		reservedByProduct[r.ProductID] = r.Quantity
+	}
+
+	snapshots := make([]InventorySnapshot, len(products))
+	for i, product := range products {
+		snapshots[i] = InventorySnapshot{
+			SnapshotDate: date,
+			ProductID:    product.ID,
+			Stock:        product.Stock,
+			Reserved:     reservedByProduct[product.ID],
+			CreatedAt:    now,
+		}
+	}
+	err = DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(snapshots, 500).Error
+	if err != nil {
+		return 0, fmt.Errorf("库存快照保存失败: %v", err)
+	}
+	return len(snapshots), nil
+}
+
+// StartInventorySnapshotScheduler 启动每日库存快照任务，每天第一次检查时记录快照
+func StartInventorySnapshotScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			// 多实例部署时每天只由一个实例记录
+			key := "inventory:snapshot:" + now.Format("20060102")
+			if ok, _ := RDB.SetNX(CTX, key, 1, 25*time.Hour).Result(); ok {
+				if count, err := SnapshotInventory(now); err != nil {
+					log.Printf("库存快照记录失败: %v", err)
+					RDB.Del(CTX, key)
+				} else {
+					log.Printf("库存快照已记录，共 %d 个商品", count)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("库存快照任务已启动")
+}
+
+// 某天的库存快照和记录时间，没有快照时返回空
+func inventorySnapshotsOn(db *gorm.DB, date time.Time) (map[uint]int, *time.Time, error) {
+	var snapshots []InventorySnapshot
+	if err := db.Where("snapshot_date = ?", date.Format("2006-01-02")).Find(&snapshots).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, nil, nil
+	}
+	stocks := make(map[uint]int, len(snapshots))
+	takenAt := snapshots[0].CreatedAt
+	for _, snapshot := range snapshots {
+		stocks[snapshot.ProductID] = snapshot.Stock
+		if snapshot.CreatedAt.Before(takenAt) {
+			takenAt = snapshot.CreatedAt
+		}
+	}
+	return stocks, &takenAt, nil
+}
+
+// 按商品汇总的数量
+func sumByProduct(query *gorm.DB, expr string) (map[uint]int, error) {
+	var rows []struct {
+		ProductID uint
+		Quantity  int
+	}
+	if err := query.Select("product_id, " + expr + " AS quantity").Group("product_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	sums := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		sums[row.ProductID] = row.Quantity
+	}
+	return sums, nil
+}
+
+// 核对 [start, end] 期间的库存：期初快照加流水合计应等于期末库存，
+// 期间创建的有效订单售出数量应等于这些订单的扣减流水，期末库存不能低于允许的下限
+func auditInventory(start, end time.Time) (*InventoryAuditReport, error) {
+	now := time.Now()
+	opening, openedAt, err := inventorySnapshotsOn(DB, start)
+	if err != nil {
+		return nil, err
+	}
+	closing, closedAt, err := inventorySnapshotsOn(DB, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	var products []Product
+	if err := DB.Select("id, name, stock, presale, presale_quota").Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	// 结束日期次日还没有快照（结束日期是今天）时以当前库存为期末
+	if closing == nil && !end.AddDate(0, 0, 1).Before(now) {
+		closing = make(map[uint]int, len(products))
+		for _, product := range products {
+			closing[product.ID] = product.Stock
+		}
+		closedAt = &now
+	}
+
+	movementNet := map[uint]int{}
+	if openedAt != nil && closedAt != nil {
+		movementNet, err = sumByProduct(DB.Model(&InventoryMovement{}).
+			Where("created_at >= ? AND created_at < ?", *openedAt, *closedAt), "SUM(quantity)")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sold, err := sumByProduct(DB.Table("order_items oi").
+		Joins("JOIN orders o ON o.id = oi.order_id").
+		Where("o.status IN ? AND o.created_at >= ? AND o.created_at < ?", reportOrderStatuses, start, end.AddDate(0, 0, 1)),
+		"SUM(oi.quantity)")
+	if err != nil {
+		return nil, err
+	}
+	deducted, err := sumByProduct(DB.Table("inventory_movements m").
+		Joins("JOIN orders o ON o.id = m.order_id").
+		Where("m.type = ? AND o.status IN ? AND o.created_at >= ? AND o.created_at < ?",
+			MovementTypeOrder, reportOrderStatuses, start, end.AddDate(0, 0, 1)),
+		"-SUM(m.quantity)")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &InventoryAuditReport{
+		StartDate:    start.Format("2006-01-02"),
+		EndDate:      end.Format("2006-01-02"),
+		ProductCount: len(products),
+	}
+	for _, product := range products {
+		line := InventoryAuditLine{
+			ProductID:        product.ID,
+			ProductName:      product.Name,
+			MovementNet:      movementNet[product.ID],
+			SoldQuantity:     sold[product.ID],
+			DeductedQuantity: deducted[product.ID],
+		}
+		if stock, ok := opening[product.ID]; ok {
+			line.OpeningStock = &stock
+		}
+		if stock, ok := closing[product.ID]; ok {
+			line.ClosingStock = &stock
+		}
+
+		if line.OpeningStock != nil && line.ClosingStock != nil {
+			line.LedgerDifference = *line.ClosingStock - *line.OpeningStock - line.MovementNet
+			if line.LedgerDifference != 0 {
+				line.Issues = append(line.Issues, AuditIssueLedgerMismatch)
+				report.LedgerMismatch++
+			}
+		}
+		line.SalesDifference = line.SoldQuantity - line.DeductedQuantity
+		if line.SalesDifference != 0 {
+			line.Issues = append(line.Issues, AuditIssueSalesMismatch)
+			report.SalesMismatch++
+		}
+		if line.ClosingStock != nil {
+			floor := 0
+			if product.Presale {
+				floor = -product.PresaleQuota
+			}
+			if *line.ClosingStock < floor {
+				line.Issues = append(line.Issues, AuditIssueOversold)
+				report.Oversold++
+			}
+		}
+		report.Products = append(report.Products, line)
+	}
+	return report, nil
+}
+
+// GetInventoryAudit 获取库存审计报告
+// @Summary 获取库存审计报告
+// @Description 对比每日库存快照、库存流水和订单销量，发现超卖或库存流失：ledger_mismatch 表示期初快照加期间流水不等于期末库存（有未记流水的库存修改），sales_mismatch 表示期间创建的已支付、已发货、已送达、已完成订单的售出数量与这些订单的扣减流水不一致，oversold 表示期末库存低于0（预售商品低于负的预售数量）。快照每天开始时自动记录，缺少期初或期末快照时不做流水核对
+// @Tags 库存管理
+// @Accept json
+// @Produce json
+// @Param start_date query string false "开始日期（YYYY-MM-DD），默认7天前"
+// @Param end_date query string false "结束日期（YYYY-MM-DD，含当天），默认昨天"
+// @Param all query bool false "返回全部商品，默认只返回有问题的商品"
+// @Success 200 {object} ApiResponse{data=InventoryAuditReport} "查询成功"
+// @Failure 400 {object} ApiResponse "日期格式错误或范围超过92天"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/inventory/audit [get]
+func GetInventoryAudit(c *gin.Context) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := today.AddDate(0, 0, -1)
+	start := today.AddDate(0, 0, -7)
+
+	if value := c.Query("start_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			BadRequestError(c, "开始日期格式错误")
+			return
+		}
+		start = parsed
+	}
+	if value := c.Query("end_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			BadRequestError(c, "结束日期格式错误")
+			return
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		BadRequestError(c, "结束日期不能早于开始日期")
+		return
+	}
+	if end.Sub(start) >= inventoryAuditMaxDays*24*time.Hour {
+		BadRequestError(c, fmt.Sprintf("审计范围不能超过 %d 天", inventoryAuditMaxDays))
+		return
+	}
+
+	report, err := auditInventory(start, end)
+	if err != nil {
+		InternalServerError(c, "库存审计失败")
+		return
+	}
+	if c.Query("all") != "true" {
+		issues := make([]InventoryAuditLine, 0)
+		for _, line := range report.Products {
+			if len(line.Issues) > 0 {
+				issues = append(issues, line)
+			}
+		}
+		report.Products = issues
+	}
+
+	SuccessResponse(c, report)
+}
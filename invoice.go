@@ -0,0 +1,282 @@
+package gomall
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 发票抬头类型
+const (
+	InvoiceTitlePersonal = "personal" // 个人
+	InvoiceTitleCompany  = "company"  // 单位
+)
+
+// 发票状态
+const (
+	InvoiceStatusIssued = "issued" // 已开具
+	InvoiceStatusVoided = "voided" // 已作废
+)
+
+// 纳税人识别号：统一社会信用代码18位，旧税号15、17或20位
+var taxNumberPattern = regexp.MustCompile(`^[0-9A-Z]{15,20}$`)
+
+// RequestInvoiceRequest 申请发票请求
+type RequestInvoiceRequest struct {
+	TitleType string `json:"title_type" binding:"required,oneof=personal company"`
+	Title     string `json:"title" binding:"required,max=100"`
+	TaxNumber string `json:"tax_number" binding:"max=20"` // 单位抬头必填
+}
+
+// 订单是否已付款：在线支付订单付款后，货到付款订单签收收款后
+func orderPaid(order *Order) bool {
+	switch order.Status {
+	case OrderStatusDelivered, OrderStatusCompleted:
+		return true
+	case OrderStatusPaid, OrderStatusShipped:
+		return order.PaymentMethod != PaymentMethodCOD
+	}
+	return false
+}
+
+// 订单取消时在同一事务中作废已开具的发票
+func voidOrderInvoice(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&Invoice{}).
+		Where("order_id = ? AND status = ?", orderID, InvoiceStatusIssued).
+		Updates(map[string]interface{}{"status": InvoiceStatusVoided, "voided_at": time.Now()}).Error
+}
+
+// 按订单ID加载订单，普通用户只能访问自己的订单
+func loadInvoiceOrder(c *gin.Context) (*Order, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return nil, false
+	}
+	query := DB.Where("id = ?", orderID)
+	if !HasRole(c, RoleAdmin) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order Order
+	if err := query.First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return nil, false
+	}
+	return &order, true
+}
+
+// 生成发票PDF：购买方、销售方、商品明细和金额合计，作废的发票标注已作废
+func renderInvoicePDF(w io.Writer, invoice *Invoice, order *Order) error {
+	const (
+		left     = 40.0
+		right    = pdfPageWidth - 40
+		pageEnd  = pdfPageHeight - 60
+		rowSpace = 18.0
+	)
+	doc := newPDFDocument()
+
+	title := "电子发票（普通发票）"
+	doc.Text((pdfPageWidth-pdfTextWidth(title, 18))/2, 60, 18, title)
+	if invoice.Status == InvoiceStatusVoided {
+		doc.Text(left, 60, 16, "已作废")
+	}
+	doc.TextRight(right, 90, 10, "发票号码："+invoice.InvoiceNo)
+	doc.TextRight(right, 106, 10, "开票日期："+invoice.IssuedAt.Format("2006年01月02日"))
+	doc.Line(left, 116, right, 116)
+
+	y := 138.0
+	doc.Text(left, y, 11, "购买方名称："+invoice.Title)
+	if invoice.TaxNumber != "" {
+		doc.Text(left, y+rowSpace, 11, "纳税人识别号："+invoice.TaxNumber)
+	}
+	doc.Text(300, y, 11, "销售方名称："+AppConfig.InvoiceSellerName)
+	if AppConfig.InvoiceSellerTaxNumber != "" {
+		doc.Text(300, y+rowSpace, 11, "纳税人识别号："+AppConfig.InvoiceSellerTaxNumber)
+	}
+	y += rowSpace * 2
+	doc.Text(left, y, 11, "订单号："+order.OrderNo)
+	y += 12
+	doc.Line(left, y, right, y)
+
+	header := func() {
+		y += rowSpace
+		doc.Text(left, y, 10, "项目名称")
+		doc.Text(250, y, 10, "规格")
+		doc.TextRight(400, y, 10, "数量")
+		doc.TextRight(480, y, 10, "单价")
+		doc.TextRight(right, y, 10, "金额")
+		y += 6
+		doc.Line(left, y, right, y)
+	}
+	header()
+	for _, item := range order.OrderItems {
+		if y+rowSpace > pageEnd {
+			doc.AddPage()
+			y = 40
+			header()
+		}
+		y += rowSpace
+		doc.Text(left, y, 10, pdfTruncate(item.ProductName, 10, 200))
+		doc.Text(250, y, 10, pdfTruncate(item.ProductSpecs, 10, 110))
+		doc.TextRight(400, y, 10, strconv.Itoa(item.Quantity))
+		doc.TextRight(480, y, 10, fmt.Sprintf("%.2f", item.Price))
+		doc.TextRight(right, y, 10, fmt.Sprintf("%.2f", item.Price*float64(item.Quantity)))
+	}
+
+	// 金额合计，优惠为商品优惠、优惠券和积分抵扣之和
+	summary := []struct {
+		label  string
+		amount float64
+	}{
+		{"运费", order.ShippingFee},
+		{"税费", order.TaxAmount},
+		{"优惠", order.ItemDiscount + order.CouponDiscount + order.PointsDiscount},
+	}
+	if y+rowSpace*float64(len(summary)+2) > pageEnd {
+		doc.AddPage()
+		y = 40
+	}
+	y += 8
+	doc.Line(left, y, right, y)
+	for _, line := range summary {
+		if line.amount == 0 {
+			continue
+		}
+		y += rowSpace
+		doc.Text(360, y, 10, line.label)
+		doc.TextRight(right, y, 10, fmt.Sprintf("%.2f", line.amount))
+	}
+	y += rowSpace + 4
+	doc.Text(360, y, 12, "价税合计（小写）")
+	doc.TextRight(right, y, 12, fmt.Sprintf("¥%.2f", invoice.Amount))
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// RequestInvoice 申请订单发票
+// @Summary 申请订单发票
+// @Description 为已付款的订单开具电子普通发票（在线支付订单付款后，货到付款订单签收后），开票金额为订单应付金额；抬头类型为单位时必须填写纳税人识别号。每个订单只能开具一次，订单取消后发票自动作废
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param invoice body RequestInvoiceRequest true "发票抬头"
+// @Success 200 {object} ApiResponse{data=Invoice} "开票成功"
+// @Failure 400 {object} ApiResponse "参数验证失败、纳税人识别号格式错误或订单未付款"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单已开具发票"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/invoice [post]
+func RequestInvoice(c *gin.Context) {
+	var req RequestInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	title := strings.TrimSpace(req.Title)
+	taxNumber := strings.ToUpper(strings.TrimSpace(req.TaxNumber))
+	if title == "" {
+		BadRequestError(c, "发票抬头不能为空")
+		return
+	}
+	if req.TitleType == InvoiceTitleCompany && !taxNumberPattern.MatchString(taxNumber) {
+		BadRequestError(c, "单位抬头需要填写正确的纳税人识别号")
+		return
+	}
+	if req.TitleType == InvoiceTitlePersonal {
+		taxNumber = ""
+	}
+
+	order, ok := loadInvoiceOrder(c)
+	if !ok {
+		return
+	}
+	if order.UserID != c.GetUint("user_id") {
+		ForbiddenError(c, "只有下单用户可以申请发票")
+		return
+	}
+	if !orderPaid(order) {
+		BadRequestError(c, "订单"+orderStatusLabel(order.Status)+"，暂不能开具发票")
+		return
+	}
+
+	var existing int64
+	DB.Model(&Invoice{}).Where("order_id = ?", order.ID).Count(&existing)
+	if existing > 0 {
+		ConflictError(c, "订单已开具发票")
+		return
+	}
+
+	now := time.Now()
+	invoice := Invoice{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		InvoiceNo: fmt.Sprintf("FP%s%08d", now.Format("20060102"), order.ID),
+		TitleType: req.TitleType,
+		Title:     title,
+		TaxNumber: taxNumber,
+		Amount:    order.TotalAmount,
+		Status:    InvoiceStatusIssued,
+		IssuedAt:  now,
+	}
+	if err := DB.Create(&invoice).Error; err != nil {
+		// 并发申请时由订单ID唯一索引保证只开一张
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			ConflictError(c, "订单已开具发票")
+			return
+		}
+		InternalServerError(c, "发票开具失败")
+		return
+	}
+
+	SuccessResponse(c, invoice)
+}
+
+// GetInvoice 下载订单发票
+// @Summary 下载订单发票
+// @Description 下载订单的电子发票PDF，下单用户和管理员可以下载；format=json 时返回发票信息
+// @Tags 订单管理
+// @Produce application/pdf
+// @Param id path int true "订单ID"
+// @Param format query string false "返回格式" Enums(pdf, json)
+// @Success 200 {file} file "发票PDF"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在或尚未开具发票"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/invoice [get]
+func GetInvoice(c *gin.Context) {
+	order, ok := loadInvoiceOrder(c)
+	if !ok {
+		return
+	}
+
+	var invoice Invoice
+	if err := DB.Where("order_id = ?", order.ID).First(&invoice).Error; err != nil {
+		NotFoundError(c, "订单尚未开具发票")
+		return
+	}
+	if c.Query("format") == "json" {
+		SuccessResponse(c, invoice)
+		return
+	}
+
+	if err := DB.Where("order_id = ?", order.ID).Order("id").Find(&order.OrderItems).Error; err != nil {
+		InternalServerError(c, "订单商品查询失败")
+		return
+	}
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="invoice-%s.pdf"`, invoice.InvoiceNo))
+	c.Header("Cache-Control", "no-store")
+	if err := renderInvoicePDF(c.Writer, &invoice, order); err != nil {
+		InternalServerError(c, "发票生成失败")
+	}
+}
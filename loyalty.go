@@ -0,0 +1,285 @@
+package gomall
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 会员等级
+const (
+	MemberTierBronze = "bronze" // 铜牌会员
+	MemberTierSilver = "silver" // 银牌会员
+	MemberTierGold   = "gold"   // 金牌会员
+)
+
+// 积分流水类型
+const (
+	PointsTypeEarn     = "earn"     // 订单支付获得
+	PointsTypeRedeem   = "redeem"   // 下单抵扣
+	PointsTypeRefund   = "refund"   // 订单取消退回抵扣的积分
+	PointsTypeRevoke   = "revoke"   // 订单取消扣回获得的积分
+	PointsTypeReferral = "referral" // 邀请奖励
+)
+
+// MemberTier 根据累计消费金额计算会员等级
+func MemberTier(totalSpent float64) string {
+	switch {
+	case totalSpent >= AppConfig.MemberGoldSpend:
+		return MemberTierGold
+	case totalSpent >= AppConfig.MemberSilverSpend:
+		return MemberTierSilver
+	default:
+		return MemberTierBronze
+	}
+}
+
+// 距离下一等级所需的消费金额，已是最高等级时返回空
+func nextMemberTier(totalSpent float64) (string, float64) {
+	switch MemberTier(totalSpent) {
+	case MemberTierBronze:
+		return MemberTierSilver, AppConfig.MemberSilverSpend - totalSpent
+	case MemberTierSilver:
+		return MemberTierGold, AppConfig.MemberGoldSpend - totalSpent
+	default:
+		return "", 0
+	}
+}
+
+// 在事务中变更用户积分并记录流水
+func changePoints(tx *gorm.DB, userID uint, points int, pointsType string, orderID uint, description string) error {
+	query := tx.Model(&User{}).Where("id = ?", userID)
+	if points < 0 {
+		// 条件更新保证并发下余额不会被扣成负数
+		query = query.Where("points >= ?", -points)
+	}
+	result := query.UpdateColumn("points", gorm.Expr("points + ?", points))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("积分余额不足")
+	}
+
+	var balance int
+	if err := tx.Model(&User{}).Where("id = ?", userID).Pluck("points", &balance).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&PointsLedger{
+		UserID:      userID,
+		Type:        pointsType,
+		Points:      points,
+		Balance:     balance,
+		OrderID:     orderID,
+		Description: description,
+	}).Error
+}
+
+// 订单积分流水合计
+func orderPointsTotal(tx *gorm.DB, orderID uint, pointsType string) int {
+	var total int
+	tx.Model(&PointsLedger{}).Select("COALESCE(SUM(points), 0)").
+		Where("order_id = ? AND type = ?", orderID, pointsType).Scan(&total)
+	return total
+}
+
+// 积分抵扣可用的最多积分数
+func maxRedeemablePoints(amountCents int64) int {
+	if AppConfig.PointsRedeemRate <= 0 {
+		return 0
+	}
+	maxCents := int64(math.Floor(float64(amountCents) * AppConfig.PointsMaxRedeemRatio))
+	return int(maxCents * int64(AppConfig.PointsRedeemRate) / 100)
+}
+
+// 下单前检查积分余额和抵扣上限
+func checkRedeemPoints(userID uint, points int, amount float64) error {
+	if AppConfig.PointsRedeemRate <= 0 {
+		return fmt.Errorf("暂不支持积分抵扣")
+	}
+	if limit := maxRedeemablePoints(toCents(amount)); points > limit {
+		return fmt.Errorf("本单最多可使用 %d 积分", limit)
+	}
+
+	var user User
+	if err := DB.Select("points").First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在")
+	}
+	if user.Points < points {
+		return fmt.Errorf("积分余额不足")
+	}
+	return nil
+}
+
+// 下单时使用积分抵扣，抵扣金额记入计价明细
+func redeemOrderPoints(tx *gorm.DB, order *Order, points int, trace *PricingTrace) error {
+	if AppConfig.PointsRedeemRate <= 0 {
+		return fmt.Errorf("暂不支持积分抵扣")
+	}
+	if limit := maxRedeemablePoints(trace.TotalCents); points > limit {
+		return fmt.Errorf("本单最多可使用 %d 积分", limit)
+	}
+
+	discountCents, points := pointsDiscount(points)
+	if discountCents == 0 {
+		return nil
+	}
+
+	if err := changePoints(tx, order.UserID, -points, PointsTypeRedeem, order.ID,
+		fmt.Sprintf("订单 %s 抵扣 %.2f 元", order.OrderNo, fromCents(discountCents))); err != nil {
+		return err
+	}
+	addPointsAdjustment(trace, points, discountCents)
+	return nil
+}
+
+// 积分可抵扣的金额（分）和实际使用的积分，只按整分抵扣，不足一分的零头积分不使用
+func pointsDiscount(points int) (int64, int) {
+	discountCents := int64(points) * 100 / int64(AppConfig.PointsRedeemRate)
+	return discountCents, int(discountCents * int64(AppConfig.PointsRedeemRate) / 100)
+}
+
+// 积分抵扣记入计价明细
+func addPointsAdjustment(trace *PricingTrace, points int, discountCents int64) {
+	trace.AddAdjustment(PricingAdjustmentPoints, fmt.Sprintf("points:%d", points),
+		fmt.Sprintf("使用 %d 积分抵扣", points), -discountCents)
+}
+
+// 订单支付后发放积分并累计消费金额，重复调用不会重复发放
+func awardOrderPoints(tx *gorm.DB, order *Order) error {
+	var awarded int64
+	tx.Model(&PointsLedger{}).Where("order_id = ? AND type = ?", order.ID, PointsTypeEarn).Count(&awarded)
+	if awarded > 0 {
+		return nil
+	}
+
+	if err := tx.Model(&User{}).Where("id = ?", order.UserID).
+		UpdateColumn("total_spent", gorm.Expr("total_spent + ?", order.TotalAmount)).Error; err != nil {
+		return err
+	}
+	if err := rewardReferral(tx, order); err != nil {
+		return err
+	}
+
+	points := int(math.Floor(order.TotalAmount * AppConfig.PointsPerYuan))
+	if points <= 0 {
+		return nil
+	}
+	return changePoints(tx, order.UserID, points, PointsTypeEarn, order.ID,
+		fmt.Sprintf("订单 %s 支付获得", order.OrderNo))
+}
+
+// 订单取消后退回抵扣的积分，并扣回已发放的积分和累计消费
+func reverseOrderPoints(tx *gorm.DB, order *Order) error {
+	if redeemed := orderPointsTotal(tx, order.ID, PointsTypeRedeem) + orderPointsTotal(tx, order.ID, PointsTypeRefund); redeemed < 0 {
+		if err := changePoints(tx, order.UserID, -redeemed, PointsTypeRefund, order.ID,
+			fmt.Sprintf("订单 %s 取消退回", order.OrderNo)); err != nil {
+			return err
+		}
+	}
+
+	earned := orderPointsTotal(tx, order.ID, PointsTypeEarn)
+	if earned == 0 {
+		return nil
+	}
+	if orderPointsTotal(tx, order.ID, PointsTypeRevoke) != 0 {
+		return nil
+	}
+
+	if err := tx.Model(&User{}).Where("id = ?", order.UserID).
+		UpdateColumn("total_spent", gorm.Expr("GREATEST(total_spent - ?, 0)", order.TotalAmount)).Error; err != nil {
+		return err
+	}
+
+	// 已发放的积分可能已被使用，最多扣回当前余额
+	var balance int
+	tx.Model(&User{}).Where("id = ?", order.UserID).Pluck("points", &balance)
+	if revoke := min(earned, balance); revoke > 0 {
+		return changePoints(tx, order.UserID, -revoke, PointsTypeRevoke, order.ID,
+			fmt.Sprintf("订单 %s 取消扣回", order.OrderNo))
+	}
+	return nil
+}
+
+// GetMemberPoints 获取会员积分和等级
+// @Summary 获取会员积分和等级
+// @Description 获取当前用户的积分余额、累计消费、会员等级以及升级到下一等级还需消费的金额
+// @Tags 会员积分
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{points=int,total_spent=number,tier=string,next_tier=string,next_tier_gap=number,redeem_rate=int}} "查询成功"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Security Bearer
+// @Router /api/users/points [get]
+func GetMemberPoints(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+
+	nextTier, gap := nextMemberTier(user.TotalSpent)
+	SuccessResponse(c, gin.H{
+		"points":        user.Points,
+		"total_spent":   user.TotalSpent,
+		"tier":          MemberTier(user.TotalSpent),
+		"next_tier":     nextTier,
+		"next_tier_gap": math.Round(gap*100) / 100,
+		"redeem_rate":   AppConfig.PointsRedeemRate,
+	})
+}
+
+// GetPointsHistory 获取积分流水
+// @Summary 获取积分流水
+// @Description 分页获取当前用户的积分获得、抵扣、退回记录
+// @Tags 会员积分
+// @Accept json
+// @Produce json
+// @Param type query string false "流水类型：earn、redeem、refund、revoke、referral"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]PointsLedger}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/points/history [get]
+func GetPointsHistory(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&PointsLedger{}).Where("user_id = ?", userID)
+	if pointsType := c.Query("type"); pointsType != "" {
+		query = query.Where("type = ?", pointsType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []PointsLedger
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&entries).Error; err != nil {
+		InternalServerError(c, "积分流水查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, entries, total, page, pageSize)
+}
@@ -0,0 +1,254 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 维护模式状态保存在Redis中，所有实例共享，管理员可随时开关
+const maintenanceStateKey = "maintenance:state"
+
+// 各实例本地缓存维护状态的时间，避免每个请求都访问Redis
+const maintenanceCacheTTL = 5 * time.Second
+
+// 维护期间仍然放行的路径前缀：健康检查、静态文件、管理后台、登录（管理员和白名单用户需要登录）和第三方回调
+var maintenanceExemptPrefixes = []string{
+	"/health",
+	"/public/",
+	"/upload/",
+	"/api/admin/",
+	"/api/captcha",
+	"/api/users/login",
+	"/api/users/sms-code",
+	"/api/users/refresh",
+	"/api/webhooks/",
+}
+
+// MaintenanceState 维护模式状态
+type MaintenanceState struct {
+	Enabled      bool       `json:"enabled"`
+	Message      string     `json:"message"`        // 展示给用户的提示
+	EndAt        *time.Time `json:"end_at"`         // 预计恢复时间
+	AllowIPs     []string   `json:"allow_ips"`      // 维护期间可访问的IP或网段（CIDR）
+	AllowUserIDs []uint     `json:"allow_user_ids"` // 维护期间可访问的用户，用于上线前验收
+	UpdatedBy    uint       `json:"updated_by"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// UpdateMaintenanceRequest 更新维护模式请求
+type UpdateMaintenanceRequest struct {
+	Enabled      bool       `json:"enabled"`
+	Message      string     `json:"message" binding:"max=500"`
+	EndAt        *time.Time `json:"end_at"`
+	AllowIPs     []string   `json:"allow_ips"`
+	AllowUserIDs []uint     `json:"allow_user_ids"`
+}
+
+var maintenanceCache struct {
+	sync.Mutex
+	state    MaintenanceState
+	loadedAt time.Time
+}
+
+// 默认维护状态，Redis中没有记录时使用环境变量配置
+func defaultMaintenanceState() MaintenanceState {
+	return MaintenanceState{Enabled: AppConfig.MaintenanceMode, Message: AppConfig.MaintenanceMessage}
+}
+
+// 获取当前维护状态，Redis不可用时沿用上一次读取的状态
+func currentMaintenanceState() MaintenanceState {
+	maintenanceCache.Lock()
+	defer maintenanceCache.Unlock()
+
+	if !maintenanceCache.loadedAt.IsZero() && time.Since(maintenanceCache.loadedAt) < maintenanceCacheTTL {
+		return maintenanceCache.state
+	}
+
+	data, err := RDB.Get(CTX, maintenanceStateKey).Result()
+	switch {
+	case err == nil:
+		var state MaintenanceState
+		if json.Unmarshal([]byte(data), &state) == nil {
+			maintenanceCache.state = state
+		}
+	case maintenanceCache.loadedAt.IsZero():
+		maintenanceCache.state = defaultMaintenanceState()
+	}
+	maintenanceCache.loadedAt = time.Now()
+	return maintenanceCache.state
+}
+
+func saveMaintenanceState(state MaintenanceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := RDB.Set(CTX, maintenanceStateKey, data, 0).Err(); err != nil {
+		return err
+	}
+
+	maintenanceCache.Lock()
+	maintenanceCache.state = state
+	maintenanceCache.loadedAt = time.Now()
+	maintenanceCache.Unlock()
+	return nil
+}
+
+// 判断IP是否在白名单中，白名单项可以是单个IP或CIDR网段
+func ipAllowed(clientIP string, allowList []string) bool {
+	ip := net.ParseIP(clientIP)
+	for _, item := range allowList {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(item); allowed != nil && ip != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从请求携带的token中识别用户，只校验签名、有效期和注销状态，完整的会话校验由路由自身的认证中间件完成
+func maintenanceRequestUser(c *gin.Context) (uint, []string) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		return 0, nil
+	}
+	claims, err := ParseJWT(token)
+	if err != nil || claims.ExpiresAt < time.Now().Unix() || IsTokenRevoked(claims) {
+		return 0, nil
+	}
+	return claims.UserID, claims.Roles
+}
+
+// 判断请求在维护期间是否可以继续访问
+func maintenanceAllows(c *gin.Context, state MaintenanceState) bool {
+	path := c.Request.URL.Path
+	for _, prefix := range maintenanceExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	if ipAllowed(c.ClientIP(), splitEnvList(AppConfig.MaintenanceAllowIPs)) || ipAllowed(c.ClientIP(), state.AllowIPs) {
+		return true
+	}
+
+	userID, roles := maintenanceRequestUser(c)
+	if userID == 0 {
+		return false
+	}
+	for _, role := range roles {
+		if role == RoleAdmin {
+			return true
+		}
+	}
+	for _, allowed := range state.AllowUserIDs {
+		if allowed == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceMode 维护模式中间件，开启后对普通用户返回503
+func MaintenanceMode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := currentMaintenanceState()
+		if !state.Enabled || maintenanceAllows(c, state) {
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = "系统维护中，请稍后再访问"
+		}
+		if state.EndAt != nil {
+			if seconds := int(time.Until(*state.EndAt).Seconds()); seconds > 0 {
+				c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, ApiResponse{
+			Code:    http.StatusServiceUnavailable,
+			Message: message,
+			Data: gin.H{
+				"maintenance": true,
+				"end_at":      state.EndAt,
+			},
+		})
+	}
+}
+
+// GetMaintenance 获取维护模式状态
+// @Summary 获取维护模式状态
+// @Description 获取当前维护模式开关、提示信息、预计恢复时间和访问白名单
+// @Tags 系统管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=MaintenanceState} "查询成功"
+// @Security Bearer
+// @Router /api/admin/maintenance [get]
+func GetMaintenance(c *gin.Context) {
+	SuccessResponse(c, currentMaintenanceState())
+}
+
+// UpdateMaintenance 开启或关闭维护模式
+// @Summary 开启或关闭维护模式
+// @Description 开启后普通用户访问返回503，管理员、管理后台、健康检查、登录接口和白名单中的IP/用户不受影响；设置在所有实例上几秒内生效
+// @Tags 系统管理
+// @Accept json
+// @Produce json
+// @Param maintenance body UpdateMaintenanceRequest true "维护模式设置"
+// @Success 200 {object} ApiResponse{data=MaintenanceState} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/maintenance [put]
+func UpdateMaintenance(c *gin.Context) {
+	var req UpdateMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	for _, item := range req.AllowIPs {
+		if net.ParseIP(item) == nil {
+			if _, _, err := net.ParseCIDR(item); err != nil {
+				BadRequestError(c, "无效的IP或网段: "+item)
+				return
+			}
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	state := MaintenanceState{
+		Enabled:      req.Enabled,
+		Message:      req.Message,
+		EndAt:        req.EndAt,
+		AllowIPs:     req.AllowIPs,
+		AllowUserIDs: req.AllowUserIDs,
+		UpdatedBy:    userID.(uint),
+		UpdatedAt:    time.Now(),
+	}
+	if err := saveMaintenanceState(state); err != nil {
+		InternalServerError(c, "维护模式设置失败")
+		return
+	}
+	log.Printf("管理员 %d 将维护模式设置为 %v", state.UpdatedBy, state.Enabled)
+
+	SuccessResponse(c, state)
+}
@@ -0,0 +1,165 @@
+package gomall
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 计入销售报表的订单状态
+var reportOrderStatuses = []string{OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusCompleted}
+
+// ProductResponse 商品接口响应，附带定价提醒
+type ProductResponse struct {
+	Product
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// QuoteResponse 报价接口响应，附带定价提醒
+type QuoteResponse struct {
+	Quote
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// 售价低于成本时返回提醒，未录入成本价时不检查
+func belowCostWarnings(product *Product, price float64, scene string) []string {
+	if product.CostPrice <= 0 || price >= product.CostPrice {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s %.2f 元低于商品「%s」的成本价 %.2f 元，每件亏损 %.2f 元",
+		scene, price, product.Name, product.CostPrice, product.CostPrice-price)}
+}
+
+// 毛利率（百分比，保留两位小数）
+func marginRate(revenue, cost float64) float64 {
+	if revenue <= 0 {
+		return 0
+	}
+	return math.Round((revenue-cost)/revenue*10000) / 100
+}
+
+// ProductSalesReport 单个商品的销售毛利
+type ProductSalesReport struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Revenue     float64 `json:"revenue"`
+	Cost        float64 `json:"cost"`
+	GrossProfit float64 `json:"gross_profit"`
+	MarginRate  float64 `json:"margin_rate"`  // 毛利率（%）
+	MissingCost bool    `json:"missing_cost"` // 有销售记录缺少成本价，毛利偏高
+}
+
+// SalesReport 销售毛利报表
+type SalesReport struct {
+	StartDate   string               `json:"start_date"`
+	EndDate     string               `json:"end_date"`
+	OrderCount  int64                `json:"order_count"`
+	Revenue     float64              `json:"revenue"`
+	Cost        float64              `json:"cost"`
+	GrossProfit float64              `json:"gross_profit"`
+	MarginRate  float64              `json:"margin_rate"` // 毛利率（%）
+	Products    []ProductSalesReport `json:"products"`
+}
+
+// GetSalesReport 获取销售毛利报表
+// @Summary 获取销售毛利报表
+// @Description 统计时间段内已支付、已发货、已送达订单的商品销售额、成本和毛利；成本优先取下单时的成本快照，历史订单没有快照时使用商品当前成本价。销售额按商品成交价计算，不扣除订单级的积分抵扣
+// @Tags 报表
+// @Accept json
+// @Produce json
+// @Param start_date query string false "开始日期（YYYY-MM-DD），默认30天前"
+// @Param end_date query string false "结束日期（YYYY-MM-DD，含当天），默认今天"
+// @Param category_id query int false "分类ID"
+// @Success 200 {object} ApiResponse{data=SalesReport} "查询成功"
+// @Failure 400 {object} ApiResponse "日期格式错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/reports/sales [get]
+func GetSalesReport(c *gin.Context) {
+	now := time.Now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := end.AddDate(0, 0, -30)
+
+	if value := c.Query("start_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			BadRequestError(c, "开始日期格式错误")
+			return
+		}
+		start = parsed
+	}
+	if value := c.Query("end_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			BadRequestError(c, "结束日期格式错误")
+			return
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		BadRequestError(c, "结束日期不能早于开始日期")
+		return
+	}
+
+	query := DB.Table("order_items oi").
+		Joins("JOIN orders o ON o.id = oi.order_id").
+		Joins("JOIN products p ON p.id = oi.product_id").
+		Where("o.status IN ? AND o.created_at >= ? AND o.created_at < ?", reportOrderStatuses, start, end.AddDate(0, 0, 1))
+	if categoryParam := c.Query("category_id"); categoryParam != "" {
+		if categoryID, err := strconv.ParseUint(categoryParam, 10, 32); err == nil {
+			query = query.Where("p.category_id = ?", categoryID)
+		}
+	}
+
+	var rows []struct {
+		ProductID   uint
+		ProductName string
+		Quantity    int
+		Revenue     float64
+		Cost        float64
+		MissingCost int
+	}
+	err := query.Session(&gorm.Session{}).Select(`oi.product_id, p.name AS product_name,
+		SUM(oi.quantity) AS quantity,
+		SUM(oi.price * oi.quantity) AS revenue,
+		SUM(COALESCE(NULLIF(oi.cost_price, 0), p.cost_price) * oi.quantity) AS cost,
+		SUM(CASE WHEN COALESCE(NULLIF(oi.cost_price, 0), p.cost_price) = 0 THEN 1 ELSE 0 END) AS missing_cost`).
+		Group("oi.product_id, p.name").Order("revenue DESC").Scan(&rows).Error
+	if err != nil {
+		InternalServerError(c, "销售报表查询失败")
+		return
+	}
+
+	report := SalesReport{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+		Products:  make([]ProductSalesReport, 0, len(rows)),
+	}
+	query.Session(&gorm.Session{}).Distinct("o.id").Count(&report.OrderCount)
+
+	for _, row := range rows {
+		report.Revenue += row.Revenue
+		report.Cost += row.Cost
+		report.Products = append(report.Products, ProductSalesReport{
+			ProductID:   row.ProductID,
+			ProductName: row.ProductName,
+			Quantity:    row.Quantity,
+			Revenue:     row.Revenue,
+			Cost:        row.Cost,
+			GrossProfit: math.Round((row.Revenue-row.Cost)*100) / 100,
+			MarginRate:  marginRate(row.Revenue, row.Cost),
+			MissingCost: row.MissingCost > 0,
+		})
+	}
+	report.Revenue = math.Round(report.Revenue*100) / 100
+	report.Cost = math.Round(report.Cost*100) / 100
+	report.GrossProfit = math.Round((report.Revenue-report.Cost)*100) / 100
+	report.MarginRate = marginRate(report.Revenue, report.Cost)
+
+	SuccessResponse(c, report)
+}
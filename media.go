@@ -0,0 +1,171 @@
+package gomall
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 商品图片问题
+const (
+	ImageIssueMissing = "missing" // 没有图片
+	ImageIssueBroken  = "broken"  // 图片文件不存在或地址无效
+)
+
+// ProductMediaIssue 图片有问题的商品
+type ProductMediaIssue struct {
+	ProductID    uint     `json:"product_id"`
+	Name         string   `json:"name"`
+	Status       int      `json:"status"`
+	Issue        string   `json:"issue"`
+	BrokenImages []string `json:"broken_images,omitempty"`
+}
+
+// 解析商品图片列表
+func productImages(product *Product) []string {
+	var images []string
+	if product.Images != "" {
+		json.Unmarshal([]byte(product.Images), &images)
+	}
+	return images
+}
+
+// 图片地址是否可用：站内上传和静态资源检查文件是否存在，外部链接只检查协议
+func imagePathValid(path string) bool {
+	path = strings.TrimSpace(path)
+	switch {
+	case path == "":
+		return false
+	case strings.HasPrefix(path, "http://"), strings.HasPrefix(path, "https://"):
+		return true
+	case strings.HasPrefix(path, "/upload/"):
+		return localFileExists(AppConfig.UploadPath, strings.TrimPrefix(path, "/upload/"))
+	case strings.HasPrefix(path, "/public/"):
+		return localFileExists("./public", strings.TrimPrefix(path, "/public/"))
+	}
+	return false
+}
+
+// 文件是否存在于目录下，不允许通过 .. 访问目录之外的文件
+func localFileExists(dir, name string) bool {
+	cleaned := filepath.Clean("/" + name)
+	info, err := os.Stat(filepath.Join(dir, cleaned))
+	return err == nil && !info.IsDir()
+}
+
+// 检查商品图片，返回第一张可用图片、问题类型和不可用的图片；只要有一张可用图片就不算有问题
+func inspectProductImages(product *Product) (cover string, issue string, broken []string) {
+	images := productImages(product)
+	if len(images) == 0 {
+		return "", ImageIssueMissing, nil
+	}
+	for _, image := range images {
+		if !imagePathValid(image) {
+			broken = append(broken, image)
+		} else if cover == "" {
+			cover = image
+		}
+	}
+	if cover == "" {
+		issue = ImageIssueBroken
+	}
+	return cover, issue, broken
+}
+
+// 列表和搜索结果的图片后处理：设置封面图，没有可用图片时使用占位图并标记问题，
+// 开启 HIDE_IMAGELESS_PRODUCTS 时直接过滤掉这些商品
+func prepareProductMedia(products []Product) []Product {
+	result := make([]Product, 0, len(products))
+	for i := range products {
+		product := products[i]
+		cover, issue, _ := inspectProductImages(&product)
+		if issue != "" {
+			if AppConfig.HideImagelessProducts {
+				continue
+			}
+			cover = AppConfig.ProductPlaceholderImage
+		}
+		product.CoverImage = cover
+		product.ImageIssue = issue
+		result = append(result, product)
+	}
+	return result
+}
+
+// GetProductMediaIssues 图片问题商品报告
+// @Summary 图片问题商品报告
+// @Description 列出没有图片或图片文件不存在的商品，便于补齐商品图片；外部图片链接只检查协议，不发起网络请求
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param issue query string false "问题类型" Enums(missing, broken)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]ProductMediaIssue}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/products/media-issues [get]
+func GetProductMediaIssues(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+	issueFilter := c.Query("issue")
+
+	// 图片是否可用需要逐个检查文件，分批扫描全部商品
+	issues := []ProductMediaIssue{}
+	var products []Product
+	err := DB.Select("id, name, status, images").Order("id").FindInBatches(&products, 1000, func(tx *gorm.DB, batch int) error {
+		for i := range products {
+			// 部分图片不可用的商品前台仍能展示，但也列入报告
+			_, issue, broken := inspectProductImages(&products[i])
+			if issue == "" && len(broken) == 0 {
+				continue
+			}
+			if issue == "" {
+				issue = ImageIssueBroken
+			}
+			if issueFilter != "" && issue != issueFilter {
+				continue
+			}
+			issues = append(issues, ProductMediaIssue{
+				ProductID:    products[i].ID,
+				Name:         products[i].Name,
+				Status:       products[i].Status,
+				Issue:        issue,
+				BrokenImages: broken,
+			})
+		}
+		return nil
+	}).Error
+	if err != nil {
+		InternalServerError(c, "图片问题商品查询失败")
+		return
+	}
+
+	total := int64(len(issues))
+	start := (page - 1) * pageSize
+	if start > len(issues) {
+		start = len(issues)
+	}
+	end := start + pageSize
+	if end > len(issues) {
+		end = len(issues)
+	}
+	PaginationSuccessResponse(c, issues[start:end], total, page, pageSize)
+}
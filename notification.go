@@ -0,0 +1,172 @@
+package gomall
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 通知类型常量
+const (
+	NotificationTypeAnnouncement = "announcement" // 公告
+	NotificationTypeOrder        = "order"        // 订单
+	NotificationTypeSystem       = "system"       // 系统
+	NotificationTypeRestock      = "restock"      // 到货提醒
+	NotificationTypeSavedSearch  = "saved_search" // 搜索订阅
+)
+
+// SendNotification 向用户发送站内通知
+func SendNotification(userID uint, notifyType, title, content string) error {
+	notification := Notification{
+		UserID:  userID,
+		Type:    notifyType,
+		Title:   title,
+		Content: content,
+	}
+	return DB.Create(&notification).Error
+}
+
+// SendNotifications 批量向用户发送相同的站内通知
+func SendNotifications(userIDs []uint, notifyType, title, content string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	notifications := make([]Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		notifications = append(notifications, Notification{
+			UserID:  userID,
+			Type:    notifyType,
+			Title:   title,
+			Content: content,
+		})
+	}
+	return DB.CreateInBatches(notifications, 500).Error
+}
+
+// GetNotifications 获取站内通知列表
+// @Summary 获取站内通知列表
+// @Description 获取当前用户的站内通知，支持分页和只看未读
+// @Tags 站内通知
+// @Accept json
+// @Produce json
+// @Param unread query bool false "只看未读"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Notification}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/notifications [get]
+func GetNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var notifications []Notification
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&notifications).Error; err != nil {
+		InternalServerError(c, "通知查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, notifications, total, page, pageSize)
+}
+
+// GetUnreadNotificationCount 获取未读通知数量
+// @Summary 获取未读通知数量
+// @Description 获取当前用户的未读站内通知数量
+// @Tags 站内通知
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{count=int}} "查询成功"
+// @Security Bearer
+// @Router /api/notifications/unread-count [get]
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var count int64
+	DB.Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count)
+
+	SuccessResponse(c, gin.H{"count": count})
+}
+
+// MarkNotificationRead 标记通知为已读
+// @Summary 标记通知为已读
+// @Description 将指定通知标记为已读
+// @Tags 站内通知
+// @Accept json
+// @Produce json
+// @Param id path int true "通知ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "标记成功"
+// @Failure 400 {object} ApiResponse "无效的通知ID"
+// @Failure 404 {object} ApiResponse "通知不存在"
+// @Security Bearer
+// @Router /api/notifications/{id}/read [put]
+func MarkNotificationRead(c *gin.Context) {
+	notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的通知ID")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result := DB.Model(&Notification{}).
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		Updates(map[string]interface{}{"is_read": true, "read_at": time.Now()})
+	if result.Error != nil {
+		InternalServerError(c, "通知更新失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "通知不存在")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "已标记为已读"})
+}
+
+// MarkAllNotificationsRead 全部标记为已读
+// @Summary 全部标记为已读
+// @Description 将当前用户的所有未读通知标记为已读
+// @Tags 站内通知
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{message=string}} "标记成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/notifications/read-all [put]
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	err := DB.Model(&Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": time.Now()}).Error
+	if err != nil {
+		InternalServerError(c, "通知更新失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "已全部标记为已读"})
+}
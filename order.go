@@ -1,11 +1,12 @@
-package main
+package gomall
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,9 +19,23 @@ const (
 	OrderStatusPaid      = "paid"      // 已支付
 	OrderStatusShipped   = "shipped"   // 已发货
 	OrderStatusDelivered = "delivered" // 已送达
+	OrderStatusCompleted = "completed" // 已完成（只含虚拟商品的订单付款后直接完成）
 	OrderStatusCancelled = "cancelled" // 已取消
 )
 
+// 支付方式常量
+const (
+	PaymentMethodOnline = "online" // 在线支付
+	PaymentMethodCOD    = "cod"    // 货到付款
+)
+
+// 下单渠道常量
+const (
+	OrderChannelWeb     = "web"     // 线上商城
+	OrderChannelOffline = "offline" // 线下门店
+	OrderChannelPhone   = "phone"   // 电话订购
+)
+
 // 购物车相关请求结构
 type AddCartRequest struct {
 	ProductID uint `json:"product_id" binding:"required"`
@@ -33,12 +48,26 @@ type UpdateCartRequest struct {
 
 // 订单相关请求结构
 type CreateOrderRequest struct {
-	ShippingAddress string `json:"shipping_address" binding:"required"`
+	ShippingAddress string `json:"shipping_address"` // 含实物商品时必填
 	CartItemIDs     []uint `json:"cart_item_ids" binding:"required"`
+	PaymentMethod   string `json:"payment_method" binding:"omitempty,oneof=online cod"` // 默认在线支付
+	RedeemPoints    int    `json:"redeem_points" binding:"omitempty,min=0"`             // 使用积分抵扣
+	GiftRecipient   string `json:"gift_recipient"`                                      // 收礼人用户名或手机号，填写后为礼物订单
+	GiftMessage     string `json:"gift_message" binding:"max=200"`                      // 礼物留言，非礼物订单也可填写，随包裹附上
+	Remark          string `json:"remark" binding:"max=200"`                            // 买家备注
+
+	recipientID     uint // 解析后的收礼人ID
+	buyNowProductID uint // 立即购买的商品，不为0时不使用购物车项
+	buyNowQuantity  int  // 立即购买的数量
 }
 
 type UpdateOrderStatusRequest struct {
 	Status string `json:"status" binding:"required"`
+	Note   string `json:"note" binding:"max=200"` // 变更备注，记入状态历史
+
+	actor      OrderActor // 操作方
+	carrier    string     // 发货时的物流公司
+	trackingNo string     // 发货时的运单号
 }
 
 // 订单处理任务
@@ -47,19 +76,14 @@ type OrderJob struct {
 	UserID  uint
 	Type    string // "create", "update", "cancel"
 	Data    interface{}
+	Created *[]Order // 创建订单任务成功后写入创建的订单，拆单时为全部子订单
 	Result  chan error
 }
 
-// 库存管理器
-type StockManager struct {
-	stocks map[uint]*StockChannel
-	mutex  sync.RWMutex
-}
-
-type StockChannel struct {
-	productID uint
-	ch        chan int
-	current   int
+// CreateOrderResponse 创建订单结果
+type CreateOrderResponse struct {
+	ParentOrderNo string  `json:"parent_order_no,omitempty"` // 拆单时的父订单号，未拆单时为空
+	Orders        []Order `json:"orders"`                    // 创建的订单，拆单时为全部子订单
 }
 
 var (
@@ -73,9 +97,7 @@ var (
 
 // 初始化订单服务
 func InitOrderService() {
-	GlobalStockManager = &StockManager{
-		stocks: make(map[uint]*StockChannel),
-	}
+	GlobalStockManager = &StockManager{}
 	
 	// 创建订单任务队列
 	OrderJobQueue = make(chan OrderJob, 100)
@@ -122,43 +144,40 @@ func OrderWorker(workerID int) {
 func processCreateOrder(job OrderJob) error {
 	orderData := job.Data.(CreateOrderRequest)
 	
-	// 并发检查库存和创建订单
-	var wg sync.WaitGroup
-	errors := make(chan error, 2)
-	
-	// 协程1: 检查库存
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := checkInventoryForOrder(job.UserID, orderData.CartItemIDs); err != nil {
-			errors <- fmt.Errorf("库存检查失败: %v", err)
-		}
-	}()
-	
-	// 协程2: 计算订单金额
-	wg.Add(1)
-	var totalAmount float64
-	go func() {
-		defer wg.Done()
-		if amount, err := calculateOrderAmount(orderData.CartItemIDs); err != nil {
-			errors <- fmt.Errorf("金额计算失败: %v", err)
-		} else {
-			totalAmount = amount
-		}
-	}()
+	// 加载下单的商品行：购物车项或立即购买的商品
+	lines, err := loadOrderLines(DB, job.UserID, orderData)
+	if err != nil {
+		return err
+	}
 	
-	wg.Wait()
-	close(errors)
+	// 检查并预占库存
+	if err := checkInventoryForOrder(lines); err != nil {
+		return fmt.Errorf("库存检查失败: %v", err)
+	}
 	
-	// 检查是否有错误
-	for err := range errors {
-		if err != nil {
-			return err
-		}
+	// 校验配送限制，运费在创建订单时按拆单后的计价明细计入
+	shippingItems := orderShippingItems(lines)
+	if strings.TrimSpace(orderData.ShippingAddress) == "" && hasPhysicalItems(shippingItems) {
+		err = fmt.Errorf("收货地址不能为空")
+	}
+	if err == nil {
+		err = CheckShippingConstraints(shippingItems, orderData.ShippingAddress)
+	}
+	if err != nil {
+		releaseReservedCartItems(lines)
+		return err
 	}
 	
-	// 开始创建订单（数据库事务）
-	return createOrderInDB(job.UserID, orderData, totalAmount)
+	// 开始创建订单（数据库事务），失败时释放预占的库存
+	orders, err := createOrderInDB(job.UserID, orderData)
+	if err != nil {
+		releaseReservedCartItems(lines)
+		return err
+	}
+	if job.Created != nil {
+		*job.Created = orders
+	}
+	return nil
 }
 
 // 处理更新订单任务
@@ -170,107 +189,78 @@ func processUpdateOrder(job OrderJob) error {
 		return fmt.Errorf("订单不存在")
 	}
 	
-	// 更新订单状态
-	if err := DB.Model(&order).Update("status", updateData.Status).Error; err != nil {
-		return fmt.Errorf("订单状态更新失败: %v", err)
-	}
-	
-	// 如果是取消订单，需要恢复库存
-	if updateData.Status == OrderStatusCancelled {
-		go restoreOrderStock(job.OrderID)
+	// 按状态机校验并更新订单状态，同时发放或退回会员积分；付款或发货后预占的库存转为实际扣减，
+	// 取消时在同一事务中退回库存和已用的钱包余额
+	var released map[uint]int
+	from := order.Status
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := transitionOrder(tx, &order, updateData.Status, updateData.actor, updateData.Note); err != nil {
+			return err
+		}
+		if updateData.Status != OrderStatusCancelled {
+			if err := commitOrderReservations(tx, order.ID); err != nil {
+				return err
+			}
+		}
+		if updateData.Status == OrderStatusCancelled {
+			quantities, err := releaseOrderStockInTx(tx, order.ID)
+			if err != nil {
+				return err
+			}
+			released = quantities
+		}
+		switch updateData.Status {
+		case OrderStatusShipped:
+			shipment := map[string]interface{}{"shipped_at": time.Now()}
+			if updateData.trackingNo != "" {
+				shipment["carrier"] = updateData.carrier
+				shipment["tracking_no"] = updateData.trackingNo
+			}
+			if err := tx.Model(&Order{}).Where("id = ?", order.ID).Updates(shipment).Error; err != nil {
+				return err
+			}
+			return markOrderItemsShipped(tx, order.ID)
+		case OrderStatusPaid:
+			if err := awardOrderPoints(tx, &order); err != nil {
+				return err
+			}
+			return fulfillDigitalItems(tx, &order)
+		case OrderStatusCancelled:
+			if err := voidOrderInvoice(tx, order.ID); err != nil {
+				return err
+			}
+			// 已付款订单取消后通过退款退回，未付清的订单直接退回已用的钱包余额
+			if from == OrderStatusPending {
+				if err := returnOrderWalletPayments(tx, &order); err != nil {
+					return err
+				}
+			}
+			return reverseOrderPoints(tx, &order)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("订单状态更新失败: %w", err)
 	}
+	GlobalStockManager.releaseAll(released)
 	
 	return nil
 }
 
-// 处理取消订单任务
+// 处理取消订单任务，Data 为操作方，未指定时为系统自动取消
 func processCancelOrder(job OrderJob) error {
+	actor, ok := job.Data.(OrderActor)
+	if !ok {
+		actor = systemOrderActor
+	}
 	return processUpdateOrder(OrderJob{
 		OrderID: job.OrderID,
 		UserID:  job.UserID,
 		Type:    "update",
-		Data:    UpdateOrderStatusRequest{Status: OrderStatusCancelled},
+		Data:    UpdateOrderStatusRequest{Status: OrderStatusCancelled, actor: actor},
 	})
 }
 
-// 库存管理相关函数
-
-// 获取或创建库存通道
-func (sm *StockManager) getStockChannel(productID uint) *StockChannel {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
-	
-	if stockCh, exists := sm.stocks[productID]; exists {
-		return stockCh
-	}
-	
-	// 从数据库获取当前库存
-	var product Product
-	if err := DB.First(&product, productID).Error; err != nil {
-		return nil
-	}
-	
-	stockCh := &StockChannel{
-		productID: productID,
-		ch:        make(chan int, 1),
-		current:   product.Stock,
-	}
-	
-	// 初始化通道
-	stockCh.ch <- product.Stock
-	sm.stocks[productID] = stockCh
-	
-	return stockCh
-}
-
-// 扣减库存
-func (sm *StockManager) DeductStock(productID uint, quantity int) error {
-	stockCh := sm.getStockChannel(productID)
-	if stockCh == nil {
-		return fmt.Errorf("无法获取商品 %d 的库存信息", productID)
-	}
-	
-	// 从通道获取当前库存
-	currentStock := <-stockCh.ch
-	
-	if currentStock < quantity {
-		// 库存不足，放回原值
-		stockCh.ch <- currentStock
-		return fmt.Errorf("商品 %d 库存不足，当前库存: %d，需要: %d", productID, currentStock, quantity)
-	}
-	
-	newStock := currentStock - quantity
-	stockCh.ch <- newStock
-	stockCh.current = newStock
-	
-	// 同步更新数据库
-	go func() {
-		DB.Model(&Product{}).Where("id = ?", productID).Update("stock", newStock)
-	}()
-	
-	return nil
-}
-
-// 恢复库存
-func (sm *StockManager) RestoreStock(productID uint, quantity int) error {
-	stockCh := sm.getStockChannel(productID)
-	if stockCh == nil {
-		return fmt.Errorf("无法获取商品 %d 的库存信息", productID)
-	}
-	
-	currentStock := <-stockCh.ch
-	newStock := currentStock + quantity
-	stockCh.ch <- newStock
-	stockCh.current = newStock
-	
-	// 同步更新数据库
-	go func() {
-		DB.Model(&Product{}).Where("id = ?", productID).Update("stock", newStock)
-	}()
-	
-	return nil
-}
-
 // 购物车功能实现
 
 // AddToCart 添加商品到购物车
@@ -281,7 +271,7 @@ func (sm *StockManager) RestoreStock(productID uint, quantity int) error {
 // @Produce json
 // @Param cart body AddCartRequest true "购物车信息"
 // @Success 200 {object} ApiResponse{data=CartItem} "添加成功"
-// @Failure 400 {object} ApiResponse "参数验证失败或库存不足"
+// @Failure 400 {object} ApiResponse "参数验证失败、库存不足、超出限购数量或购物车已满"
 // @Failure 404 {object} ApiResponse "商品不存在"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
@@ -309,8 +299,8 @@ func AddToCart(c *gin.Context) {
 	}
 	
 	// 检查库存
-	if product.Stock < req.Quantity {
-		BadRequestError(c, fmt.Sprintf("库存不足，当前库存: %d", product.Stock))
+	if product.orderableStock() < req.Quantity {
+		BadRequestError(c, fmt.Sprintf("库存不足，当前可购买数量: %d", product.orderableStock()))
 		return
 	}
 	
@@ -318,28 +308,44 @@ func AddToCart(c *gin.Context) {
 	var existingItem CartItem
 	result := DB.Where("user_id = ? AND product_id = ?", userID, req.ProductID).First(&existingItem)
 	
+	// 检查限购：购物车中的数量加上历史购买数量不能超过限购数量
+	newQuantity := req.Quantity
+	if result.Error == nil {
+		newQuantity += existingItem.Quantity
+	}
+	if err := checkPurchaseLimits(DB, userID.(uint), map[uint]int{product.ID: newQuantity}); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	
 	if result.Error == nil {
 		// 更新数量
-		newQuantity := existingItem.Quantity + req.Quantity
-		if product.Stock < newQuantity {
+		if product.orderableStock() < newQuantity {
 			BadRequestError(c, "库存不足")
 			return
 		}
 		
-		if err := DB.Model(&existingItem).Update("quantity", newQuantity).Error; err != nil {
+		if err := DB.Model(&existingItem).Updates(map[string]interface{}{"quantity": newQuantity, "price": product.Price}).Error; err != nil {
 			InternalServerError(c, "购物车更新失败")
 			return
 		}
 		
 		// 预加载商品信息
 		DB.Preload("Product").First(&existingItem, existingItem.ID)
+		cacheCartItem(&existingItem)
+		publishCartItemEvent(CartEventUpdated, &existingItem)
 		SuccessResponse(c, existingItem)
 	} else {
 		// 创建新的购物车项
+		if err := checkCartSize(DB, userID.(uint)); err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
 		cartItem := CartItem{
 			UserID:    userID.(uint),
 			ProductID: req.ProductID,
 			Quantity:  req.Quantity,
+			Price:     product.Price,
 		}
 		
 		if err := DB.Create(&cartItem).Error; err != nil {
@@ -349,25 +355,30 @@ func AddToCart(c *gin.Context) {
 		
 		// 预加载商品信息
 		DB.Preload("Product").First(&cartItem, cartItem.ID)
+		cacheCartItem(&cartItem)
+		publishCartItemEvent(CartEventAdded, &cartItem)
 		SuccessResponse(c, cartItem)
 	}
 }
 
 // GetCart 获取购物车列表
 // @Summary 获取购物车列表
-// @Description 获取当前用户的购物车列表，包括总金额计算
+// @Description 获取当前用户的购物车列表，包括总金额计算；pricing 按下单时的计价规则给出商品金额、促销、优惠券和积分抵扣预览、预估运费和应付金额，可通过 cart_item_ids 只汇总选中的商品
 // @Tags 购物车管理
 // @Accept json
 // @Produce json
-// @Success 200 {object} ApiResponse{data=object{items=[]CartItem,total_amount=number,total_count=int}} "查询成功"
+// @Param cart_item_ids query string false "参与价格汇总的购物车项ID，逗号分隔，默认全部"
+// @Param redeem_points query int false "预览使用的积分数"
+// @Success 200 {object} ApiResponse{data=object{items=[]CartItem,total_amount=number,total_count=int,pricing=CartPricingSummary}} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的购物车项ID"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
 // @Router /api/cart [get]
 func GetCart(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	
-	var cartItems []CartItem
-	if err := DB.Preload("Product").Where("user_id = ?", userID).Find(&cartItems).Error; err != nil {
+	cartItems, err := loadCartItems(userID.(uint), false)
+	if err != nil {
 		InternalServerError(c, "购物车查询失败")
 		return
 	}
@@ -378,10 +389,29 @@ func GetCart(c *gin.Context) {
 		totalAmount += item.Product.Price * float64(item.Quantity)
 	}
 	
+	// 价格汇总，只包含选中的商品
+	selected := make(map[uint]bool)
+	for _, value := range splitEnvList(c.Query("cart_item_ids")) {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			BadRequestError(c, "无效的购物车项ID")
+			return
+		}
+		selected[uint(id)] = true
+	}
+	pricedItems := make([]CartItem, 0, len(cartItems))
+	for _, item := range cartItems {
+		if len(selected) == 0 || selected[item.ID] {
+			pricedItems = append(pricedItems, item)
+		}
+	}
+	redeemPoints, _ := strconv.Atoi(c.Query("redeem_points"))
+	
 	result := gin.H{
 		"items":        cartItems,
 		"total_amount": totalAmount,
 		"total_count":  len(cartItems),
+		"pricing":      summarizeCartPricing(userID.(uint), pricedItems, redeemPoints),
 	}
 	
 	SuccessResponse(c, result)
@@ -396,7 +426,7 @@ func GetCart(c *gin.Context) {
 // @Param id path int true "购物车项ID"
 // @Param cart body UpdateCartRequest true "更新的数量"
 // @Success 200 {object} ApiResponse{data=CartItem} "更新成功"
-// @Failure 400 {object} ApiResponse "参数验证失败或库存不足"
+// @Failure 400 {object} ApiResponse "参数验证失败、库存不足或超出限购数量"
 // @Failure 404 {object} ApiResponse "购物车项不存在"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
@@ -431,8 +461,12 @@ func UpdateCartItem(c *gin.Context) {
 		return
 	}
 	
-	if product.Stock < req.Quantity {
-		BadRequestError(c, fmt.Sprintf("库存不足，当前库存: %d", product.Stock))
+	if product.orderableStock() < req.Quantity {
+		BadRequestError(c, fmt.Sprintf("库存不足，当前可购买数量: %d", product.orderableStock()))
+		return
+	}
+	if err := checkPurchaseLimits(DB, cartItem.UserID, map[uint]int{product.ID: req.Quantity}); err != nil {
+		BadRequestError(c, err.Error())
 		return
 	}
 	
@@ -444,6 +478,8 @@ func UpdateCartItem(c *gin.Context) {
 	
 	// 预加载商品信息
 	DB.Preload("Product").First(&cartItem, cartItem.ID)
+	cacheCartItem(&cartItem)
+	publishCartItemEvent(CartEventUpdated, &cartItem)
 	SuccessResponse(c, cartItem)
 }
 
@@ -481,6 +517,8 @@ func DeleteCartItem(c *gin.Context) {
 		NotFoundError(c, "购物车项不存在")
 		return
 	}
+	uncacheCartItem(userID.(uint), uint(itemID))
+	publishCartRemoved(userID.(uint), uint(itemID))
 	
 	SuccessResponse(c, gin.H{"message": "删除成功"})
 }
@@ -502,6 +540,8 @@ func ClearCart(c *gin.Context) {
 		InternalServerError(c, "清空购物车失败")
 		return
 	}
+	invalidateCartCache(userID.(uint))
+	publishCartEvent(userID.(uint), CartEvent{Type: CartEventCleared})
 	
 	SuccessResponse(c, gin.H{"message": "购物车已清空"})
 }
@@ -510,12 +550,12 @@ func ClearCart(c *gin.Context) {
 
 // CreateOrder 创建订单（使用并发处理）
 // @Summary 创建订单
-// @Description 根据购物车项创建订单，使用并发处理提高性能
+// @Description 根据购物车项创建订单，使用并发处理提高性能；校验商品的配送限制并计入运费（基础运费、超大件附加费）；可通过 redeem_points 使用积分抵扣部分金额；填写 gift_recipient 可送给其他注册用户，收货地址填写收礼人的地址，收礼人在收到的礼物中看不到价格；商品来自不同商家或没有能整单发货的仓库时自动拆分为多个子订单，子订单共用 parent_order_no、各自发货和流转状态，运费和积分抵扣按整单计算后分摊；返回本次创建的全部订单（orders）和拆单时的 parent_order_no；下单时预占库存，在线支付订单超过 STOCK_RESERVATION_MINUTES 未付款自动取消并释放库存；设置了每人限购的商品，本次购买数量加上历史未取消订单中的购买数量不能超过限购数量
 // @Tags 订单管理
 // @Accept json
 // @Produce json
 // @Param order body CreateOrderRequest true "订单信息"
-// @Success 200 {object} ApiResponse{data=Order} "创建成功"
+// @Success 200 {object} ApiResponse{data=CreateOrderResponse} "创建成功"
 // @Failure 400 {object} ApiResponse "参数验证失败"
 // @Failure 500 {object} ApiResponse "订单创建失败或超时"
 // @Security Bearer
@@ -527,14 +567,35 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 	
+	submitCreateOrder(c, req)
+}
+
+// 提交创建订单任务并等待结果，购物车下单和立即购买共用
+func submitCreateOrder(c *gin.Context, req CreateOrderRequest) {
 	userID, _ := c.Get("user_id")
 	
+	// 礼物订单：确认收礼人，货到付款需要收礼人付款，不允许使用
+	if req.GiftRecipient != "" {
+		if req.PaymentMethod == PaymentMethodCOD {
+			BadRequestError(c, "礼物订单不支持货到付款")
+			return
+		}
+		recipient, err := findGiftRecipient(userID.(uint), req.GiftRecipient)
+		if err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		req.recipientID = recipient.ID
+	}
+	
 	// 创建订单任务
+	var created []Order
 	orderJob := OrderJob{
-		UserID: userID.(uint),
-		Type:   "create",
-		Data:   req,
-		Result: make(chan error, 1),
+		UserID:  userID.(uint),
+		Type:    "create",
+		Data:    req,
+		Created: &created,
+		Result:  make(chan error, 1),
 	}
 	
 	// 提交到协程池处理
@@ -548,11 +609,18 @@ func CreateOrder(c *gin.Context) {
 			return
 		}
 		
-		// 获取创建的订单信息
-		var order Order
-		DB.Preload("OrderItems.Product").Where("user_id = ?", userID).Order("created_at DESC").First(&order)
+		// 获取本次创建的全部订单信息
+		orderIDs := make([]uint, 0, len(created))
+		for _, order := range created {
+			orderIDs = append(orderIDs, order.ID)
+		}
+		result := CreateOrderResponse{Orders: []Order{}}
+		if len(created) > 0 {
+			result.ParentOrderNo = created[0].ParentOrderNo
+			DB.Preload("OrderItems.Product").Where("id IN ?", orderIDs).Order("id").Find(&result.Orders)
+		}
 		
-		SuccessResponse(c, order)
+		SuccessResponse(c, result)
 		
 	case <-time.After(30 * time.Second):
 		InternalServerError(c, "订单创建超时")
@@ -562,13 +630,22 @@ func CreateOrder(c *gin.Context) {
 
 // GetOrders 获取订单列表
 // @Summary 获取订单列表
-// @Description 获取当前用户的订单列表，支持分页
+// @Description 获取当前用户的订单列表，支持分页，可按状态、下单日期、订单号、商品名称和金额范围筛选
 // @Tags 订单管理
 // @Accept json
 // @Produce json
+// @Param status query string false "订单状态，多个用逗号分隔"
+// @Param start_date query string false "下单开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "下单结束日期（YYYY-MM-DD，含当天）"
+// @Param order_no query string false "订单号（模糊匹配）"
+// @Param parent_order_no query string false "父订单号，查询同一次下单拆分出的子订单"
+// @Param product_name query string false "商品名称（模糊匹配）"
+// @Param min_amount query number false "最低订单金额"
+// @Param max_amount query number false "最高订单金额"
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10) maximum(100)
 // @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Order}} "查询成功"
+// @Failure 400 {object} ApiResponse "筛选参数格式错误"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
 // @Router /api/orders [get]
@@ -594,11 +671,15 @@ func GetOrders(c *gin.Context) {
 	var orders []Order
 	var total int64
 	
-	query := DB.Model(&Order{}).Where("user_id = ?", userID)
+	query, err := applyOrderFilters(c, DB.Model(&Order{}).Where("orders.user_id = ?", userID))
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
 	query.Count(&total)
 	
 	offset := (page - 1) * pageSize
-	err := query.Preload("OrderItems.Product").
+	err = query.Preload("OrderItems.Product").
 		Order("created_at DESC").
 		Limit(pageSize).
 		Offset(offset).
@@ -614,7 +695,7 @@ func GetOrders(c *gin.Context) {
 
 // GetOrder 获取订单详情
 // @Summary 获取订单详情
-// @Description 根据订单ID获取订单的详细信息
+// @Description 根据订单ID获取订单的详细信息，包括买家备注和礼物留言；内部备注（notes）仅管理员可见
 // @Tags 订单管理
 // @Accept json
 // @Produce json
@@ -635,7 +716,7 @@ func GetOrder(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	
 	var order Order
-	if err := DB.Preload("OrderItems.Product").
+	if err := DB.Preload("OrderItems.Product").Preload("DeliveryProofs").Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
 		Where("id = ? AND user_id = ?", oID, userID).
 		First(&order).Error; err != nil {
 		NotFoundError(c, "订单不存在")
@@ -647,14 +728,17 @@ func GetOrder(c *gin.Context) {
 
 // UpdateOrderStatus 更新订单状态
 // @Summary 更新订单状态
-// @Description 更新订单的状态，支持的状态包括：pending、paid、shipped、delivered、cancelled
+// @Description 按订单状态机变更订单状态并记录变更历史。允许的流转：pending→paid（支付回调或管理员确认线下付款，仅在线支付；用户通过 POST /api/orders/{id}/pay 付款）、pending→shipped（管理员，仅货到付款）、pending→cancelled（用户或管理员）、paid→shipped（管理员）、paid→cancelled（管理员）、shipped→delivered（用户确认收货、配送员或管理员，货到付款订单不能由用户确认）、delivered→completed（用户或管理员）；其他变更（如 delivered→pending）一律拒绝。普通用户只能取消或确认收货自己的订单
 // @Tags 订单管理
 // @Accept json
 // @Produce json
 // @Param id path int true "订单ID"
 // @Param status body UpdateOrderStatusRequest true "订单状态"
 // @Success 200 {object} ApiResponse{data=object{message=string}} "更新成功"
-// @Failure 400 {object} ApiResponse "参数验证失败或无效的订单状态"
+// @Failure 400 {object} ApiResponse "参数验证失败、无效的订单状态或不允许的状态变更"
+// @Failure 403 {object} ApiResponse "无权执行此状态变更"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单状态已被其他操作修改"
 // @Failure 500 {object} ApiResponse "服务器内部错误或超时"
 // @Security Bearer
 // @Router /api/orders/{id}/status [put]
@@ -673,25 +757,42 @@ func UpdateOrderStatus(c *gin.Context) {
 	}
 	
 	// 验证状态值
-	validStatuses := map[string]bool{
-		OrderStatusPending:   true,
-		OrderStatusPaid:      true,
-		OrderStatusShipped:   true,
-		OrderStatusDelivered: true,
-		OrderStatusCancelled: true,
-	}
-	
-	if !validStatuses[req.Status] {
+	if _, ok := orderStatusLabels[req.Status]; !ok {
 		BadRequestError(c, "无效的订单状态")
 		return
 	}
 	
 	userID, _ := c.Get("user_id")
 	
-	// 创建更新任务
+	// 普通用户只能查看和操作自己的订单，先校验流转规则，处理任务中会在事务内再次校验
+	req.actor = orderActorFromContext(c)
+	var order Order
+	if err := DB.First(&order, oID).Error; err != nil || (req.actor.Role == RoleCustomer && order.UserID != req.actor.UserID) {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+	if err := checkOrderTransition(&order, req.Status, req.actor); err != nil {
+		orderTransitionError(c, err, "订单状态更新失败")
+		return
+	}
+	
+	if err := runOrderStatusJob(uint(oID), userID.(uint), req); err != nil {
+		if errors.Is(err, errOrderJobTimeout) {
+			InternalServerError(c, "订单状态更新超时")
+			return
+		}
+		orderTransitionError(c, err, "订单状态更新失败")
+		return
+	}
+	
+	SuccessResponse(c, gin.H{"message": "订单状态更新成功"})
+}
+
+// 提交订单状态更新任务并等待处理结果，超时返回 errOrderJobTimeout
+func runOrderStatusJob(orderID, userID uint, req UpdateOrderStatusRequest) error {
 	updateJob := OrderJob{
-		OrderID: uint(oID),
-		UserID:  userID.(uint),
+		OrderID: orderID,
+		UserID:  userID,
 		Type:    "update",
 		Data:    req,
 		Result:  make(chan error, 1),
@@ -703,16 +804,9 @@ func UpdateOrderStatus(c *gin.Context) {
 	// 等待处理结果
 	select {
 	case err := <-updateJob.Result:
-		if err != nil {
-			InternalServerError(c, "订单状态更新失败: "+err.Error())
-			return
-		}
-		
-		SuccessResponse(c, gin.H{"message": "订单状态更新成功"})
-		
+		return err
 	case <-time.After(10 * time.Second):
-		InternalServerError(c, "订单状态更新超时")
-		return
+		return errOrderJobTimeout
 	}
 }
 
@@ -757,6 +851,7 @@ func CancelOrder(c *gin.Context) {
 		OrderID: uint(oID),
 		UserID:  userID.(uint),
 		Type:    "cancel",
+		Data:    OrderActor{Role: RoleCustomer, UserID: userID.(uint)},
 		Result:  make(chan error, 1),
 	}
 	
@@ -767,7 +862,7 @@ func CancelOrder(c *gin.Context) {
 	select {
 	case err := <-cancelJob.Result:
 		if err != nil {
-			InternalServerError(c, "订单取消失败: "+err.Error())
+			orderTransitionError(c, err, "订单取消失败")
 			return
 		}
 		
@@ -781,115 +876,255 @@ func CancelOrder(c *gin.Context) {
 
 // 辅助函数
 
-// 检查订单库存
-func checkInventoryForOrder(userID uint, cartItemIDs []uint) error {
-	for _, itemID := range cartItemIDs {
+// 加载订单的商品行（含商品信息）：立即购买时为单个商品，否则按请求顺序加载购物车项
+func loadOrderLines(db *gorm.DB, userID uint, req CreateOrderRequest) ([]CartItem, error) {
+	if req.buyNowProductID != 0 {
+		line, err := buyNowLine(db, userID, req.buyNowProductID, req.buyNowQuantity)
+		if err != nil {
+			return nil, err
+		}
+		return []CartItem{line}, nil
+	}
+	
+	lines := make([]CartItem, 0, len(req.CartItemIDs))
+	for _, itemID := range req.CartItemIDs {
 		var cartItem CartItem
-		if err := DB.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
-			return fmt.Errorf("购物车项 %d 不存在", itemID)
+		if err := db.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
+			return nil, fmt.Errorf("购物车项 %d 不存在", itemID)
 		}
-		
+		lines = append(lines, cartItem)
+	}
+	return lines, nil
+}
+
+// 检查并预占订单库存，任一商品不足时释放已预占的部分
+func checkInventoryForOrder(lines []CartItem) error {
+	var reserved []CartItem
+	for _, cartItem := range lines {
 		// 并发检查库存
-		if err := GlobalStockManager.DeductStock(cartItem.ProductID, cartItem.Quantity); err != nil {
+		if err := GlobalStockManager.ReserveStock(cartItem.ProductID, cartItem.Quantity); err != nil {
+			releaseReservedCartItems(reserved)
 			return err
 		}
+		reserved = append(reserved, cartItem)
 	}
 	
 	return nil
 }
 
-// 计算订单金额
-func calculateOrderAmount(cartItemIDs []uint) (float64, error) {
-	var totalAmount float64
+func releaseReservedCartItems(cartItems []CartItem) {
+	for _, cartItem := range cartItems {
+		GlobalStockManager.ReleaseStock(cartItem.ProductID, cartItem.Quantity)
+	}
+}
+
+// 校验单笔订单金额是否在允许范围内
+func validateOrderAmount(amount float64) error {
+	if AppConfig.OrderMinAmount > 0 && amount < AppConfig.OrderMinAmount {
+		return fmt.Errorf("订单金额不能低于 %.2f 元", AppConfig.OrderMinAmount)
+	}
+	if AppConfig.OrderMaxAmount > 0 && amount > AppConfig.OrderMaxAmount {
+		return fmt.Errorf("订单金额不能超过 %.2f 元", AppConfig.OrderMaxAmount)
+	}
+	return nil
+}
+
+// 按各子订单计价明细的合计金额（积分抵扣前）校验订单金额限制、货到付款条件和积分抵扣，与实际收取的金额一致
+func checkOrderTotal(userID uint, req CreateOrderRequest, traces []*PricingTrace) error {
+	var totalCents int64
+	for _, trace := range traces {
+		totalCents += trace.TotalCents
+	}
+	totalAmount := fromCents(totalCents)
 	
-	for _, itemID := range cartItemIDs {
-		var cartItem CartItem
-		if err := DB.Preload("Product").First(&cartItem, itemID).Error; err != nil {
-			return 0, fmt.Errorf("购物车项 %d 不存在", itemID)
+	if err := validateOrderAmount(totalAmount); err != nil {
+		return err
+	}
+	if req.PaymentMethod == PaymentMethodCOD {
+		if err := CheckCODEligibility(totalAmount, req.ShippingAddress); err != nil {
+			return err
 		}
-		
-		totalAmount += cartItem.Product.Price * float64(cartItem.Quantity)
 	}
-	
-	return totalAmount, nil
+	if req.RedeemPoints > 0 {
+		return checkRedeemPoints(userID, req.RedeemPoints, totalAmount)
+	}
+	return nil
 }
 
-// 在数据库中创建订单
-func createOrderInDB(userID uint, req CreateOrderRequest, totalAmount float64) error {
+// 在数据库中创建订单，商品来自不同商家或需要从不同仓库发货时拆分为共用父订单号的多个子订单
+func createOrderInDB(userID uint, req CreateOrderRequest) ([]Order, error) {
 	// 开始数据库事务
 	tx := DB.Begin()
 	
-	// 生成订单号
-	orderNo := generateOrderNumber()
-	
-	// 创建订单
-	order := Order{
-		UserID:          userID,
-		OrderNo:         orderNo,
-		TotalAmount:     totalAmount,
-		Status:          OrderStatusPending,
-		ShippingAddress: req.ShippingAddress,
+	paymentMethod := req.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = PaymentMethodOnline
 	}
 	
-	if err := tx.Create(&order).Error; err != nil {
+	// 先锁定商品行并检查库存和限购，再按商家和发货仓库拆单
+	lines, err := loadOrderLines(tx, userID, req)
+	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("订单创建失败: %v", err)
+		return nil, err
+	}
+	if err := checkAvailableStock(tx, lineQuantities(lines)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := checkPurchaseLimits(tx, userID, lineQuantities(lines)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	groups, err := planOrderSplit(tx, req.ShippingAddress, lines)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	productIDs := make([]uint, 0, len(lines))
+	for _, cartItem := range lines {
+		productIDs = append(productIDs, cartItem.ProductID)
+	}
+	specs, err := productSpecTexts(tx, productIDs)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("商品规格查询失败: %v", err)
 	}
 	
-	// 创建订单项并清除购物车
-	for _, itemID := range req.CartItemIDs {
-		var cartItem CartItem
-		if err := tx.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
+	// 商品行和运费，与购物车价格汇总使用同一套规则
+	traces := make([]*PricingTrace, len(groups))
+	groupLines := make([][]CartItem, len(groups))
+	for i, group := range groups {
+		traces[i] = NewPricingTrace()
+		groupLines[i] = group.Lines
+	}
+	applySplitPricing(traces, groupLines)
+	if err := checkOrderTotal(userID, req, traces); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	redeemPoints := make([]int, len(groups))
+	if req.RedeemPoints > 0 {
+		if redeemPoints, err = splitRedeemPoints(req.RedeemPoints, traces); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("购物车项 %d 不存在", itemID)
+			return nil, err
 		}
+	}
+	
+	// 拆单时子订单号为父订单号加序号
+	parentOrderNo := ""
+	if len(groups) > 1 {
+		parentOrderNo = generateOrderNumber()
+	}
+	
+	orders := make([]Order, len(groups))
+	for i, group := range groups {
+		trace := traces[i]
 		
-		// 创建订单项
-		orderItem := OrderItem{
-			OrderID:   order.ID,
-			ProductID: cartItem.ProductID,
-			Quantity:  cartItem.Quantity,
-			Price:     cartItem.Product.Price,
+		// 创建订单
+		now := time.Now()
+		order := Order{
+			UserID:          userID,
+			OrderNo:         generateOrderNumber(),
+			ParentOrderNo:   parentOrderNo,
+			TotalAmount:     trace.Total(),
+			Status:          OrderStatusPending,
+			StatusChangedAt: &now,
+			PaymentMethod:   paymentMethod,
+			ShippingAddress: req.ShippingAddress,
+			GiftMessage:     strings.TrimSpace(req.GiftMessage),
+			Remark:          strings.TrimSpace(req.Remark),
+			SellerID:        group.SellerID,
+			WarehouseID:     group.WarehouseID,
+		}
+		if parentOrderNo != "" {
+			order.OrderNo = fmt.Sprintf("%s-%d", parentOrderNo, i+1)
+		}
+		if req.recipientID != 0 {
+			order.RecipientID = &req.recipientID
 		}
 		
-		if err := tx.Create(&orderItem).Error; err != nil {
+		if err := tx.Create(&order).Error; err != nil {
 			tx.Rollback()
-			return fmt.Errorf("订单项创建失败: %v", err)
+			return nil, fmt.Errorf("订单创建失败: %v", err)
 		}
-		
-		// 删除购物车项
-		if err := tx.Delete(&cartItem).Error; err != nil {
+		detail := ""
+		if parentOrderNo != "" {
+			detail = fmt.Sprintf("订单 %s 拆分为 %d 个子订单", parentOrderNo, len(groups))
+		}
+		if err := recordOrderEvent(tx, order.ID, OrderEventCreated, OrderActor{Role: RoleCustomer, UserID: userID}, detail); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("购物车清理失败: %v", err)
+			return nil, fmt.Errorf("订单事件记录失败: %v", err)
+		}
+		if err := createStockReservations(tx, &order, lineQuantities(group.Lines)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		
+		// 创建订单项并清除购物车
+		for _, cartItem := range group.Lines {
+			// 创建订单项
+			orderItem := OrderItem{
+				OrderID:   order.ID,
+				ProductID: cartItem.ProductID,
+				Quantity:  cartItem.Quantity,
+				Price:     cartItem.Product.Price,
+				CostPrice: cartItem.Product.CostPrice,
+			}
+			applyItemFulfillment(&orderItem, &cartItem.Product)
+			applyItemSnapshot(&orderItem, &cartItem.Product, specs)
+			
+			if err := tx.Create(&orderItem).Error; err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("订单项创建失败: %v", err)
+			}
+			
+			// 删除购物车项，立即购买的商品不在购物车中
+			if cartItem.ID != 0 {
+				if err := tx.Delete(&cartItem).Error; err != nil {
+					tx.Rollback()
+					return nil, fmt.Errorf("购物车清理失败: %v", err)
+				}
+			}
+			
+			// 更新商品销量
+			tx.Model(&Product{}).Where("id = ?", cartItem.ProductID).
+				UpdateColumn("sales_count", gorm.Expr("sales_count + ?", cartItem.Quantity))
+		}
+		
+		// 使用积分抵扣
+		if redeemPoints[i] > 0 {
+			if err := redeemOrderPoints(tx, &order, redeemPoints[i], trace); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
 		}
 		
-		// 更新商品销量
-		tx.Model(&Product{}).Where("id = ?", cartItem.ProductID).
-			UpdateColumn("sales_count", gorm.Expr("sales_count + ?", cartItem.Quantity))
+		// 以分为单位的计价结果为准，避免浮点累加误差，同时保存运费、税费和各项优惠
+		applyOrderAmounts(&order, trace)
+		if err := tx.Model(&order).Updates(orderAmountColumns(&order)).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("计价明细保存失败: %v", err)
+		}
+		orders[i] = order
 	}
 	
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("事务提交失败: %v", err)
+		return nil, fmt.Errorf("事务提交失败: %v", err)
 	}
-	
-	return nil
-}
-
-// 恢复订单库存
-func restoreOrderStock(orderID uint) {
-	var orderItems []OrderItem
-	if err := DB.Where("order_id = ?", orderID).Find(&orderItems).Error; err != nil {
-		log.Printf("查询订单项失败: %v", err)
-		return
+	if req.buyNowProductID == 0 {
+		invalidateCartCache(userID)
+		removed := make([]uint, 0, len(lines))
+		for _, cartItem := range lines {
+			removed = append(removed, cartItem.ID)
+		}
+		publishCartRemoved(userID, removed...)
 	}
 	
-	for _, item := range orderItems {
-		if err := GlobalStockManager.RestoreStock(item.ProductID, item.Quantity); err != nil {
-			log.Printf("恢复库存失败 - 商品ID: %d, 数量: %d, 错误: %v", 
-				item.ProductID, item.Quantity, err)
-		}
+	for i := range orders {
+		notifyGiftRecipient(&orders[i])
 	}
+	return orders, nil
 }
 
 // 生成订单号
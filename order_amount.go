@@ -0,0 +1,103 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// 计价明细中的商品金额（分）：商品原价加上促销、协议价、线下成交价等商品优惠，作为计税基数
+func goodsCents(trace *PricingTrace) int64 {
+	cents := trace.BaseCents
+	for _, adjustment := range trace.Adjustments {
+		switch adjustment.Type {
+		case PricingAdjustmentPromotion, PricingAdjustmentQuote, PricingAdjustmentOffline:
+			cents += adjustment.AmountCents
+		}
+	}
+	return cents
+}
+
+// 按 TAX_RATE 计算商品金额的税费（分），运费不计税
+func goodsTaxCents(amountCents int64) int64 {
+	if AppConfig.TaxRate <= 0 || amountCents <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(amountCents) * AppConfig.TaxRate))
+}
+
+// 税费记入计价明细，须在商品行和商品优惠全部记录之后调用
+func addTaxAdjustment(trace *PricingTrace) {
+	if cents := goodsTaxCents(goodsCents(trace)); cents > 0 {
+		trace.AddAdjustment(PricingAdjustmentTax, "tax:rate",
+			fmt.Sprintf("税费（税率 %g%%）", AppConfig.TaxRate*100), cents)
+	}
+}
+
+// 按计价明细填写订单的金额构成，应付金额即 TotalAmount
+func applyOrderAmounts(order *Order, trace *PricingTrace) {
+	var itemDiscount, couponDiscount, pointsDiscount, shippingFee, taxAmount int64
+	for _, adjustment := range trace.Adjustments {
+		switch adjustment.Type {
+		case PricingAdjustmentPromotion, PricingAdjustmentQuote, PricingAdjustmentOffline:
+			itemDiscount += adjustment.AmountCents
+		case PricingAdjustmentCoupon:
+			couponDiscount += adjustment.AmountCents
+		case PricingAdjustmentPoints:
+			pointsDiscount += adjustment.AmountCents
+		case PricingAdjustmentShipping:
+			shippingFee += adjustment.AmountCents
+		case PricingAdjustmentTax:
+			taxAmount += adjustment.AmountCents
+		}
+	}
+	order.ItemAmount = fromCents(trace.BaseCents)
+	order.ItemDiscount = fromCents(itemDiscount)
+	order.CouponDiscount = fromCents(couponDiscount)
+	order.PointsDiscount = fromCents(pointsDiscount)
+	order.ShippingFee = fromCents(shippingFee)
+	order.TaxAmount = fromCents(taxAmount)
+	order.TotalAmount = trace.Total()
+	order.PricingTrace = trace.JSON()
+}
+
+// 订单金额构成对应的更新字段
+func orderAmountColumns(order *Order) map[string]interface{} {
+	return map[string]interface{}{
+		"item_amount":     order.ItemAmount,
+		"item_discount":   order.ItemDiscount,
+		"coupon_discount": order.CouponDiscount,
+		"points_discount": order.PointsDiscount,
+		"shipping_fee":    order.ShippingFee,
+		"tax_amount":      order.TaxAmount,
+		"total_amount":    order.TotalAmount,
+		"pricing_trace":   order.PricingTrace,
+	}
+}
+
+// 旧订单没有金额构成，按计价明细补全；没有计价明细的订单全部计为商品金额
+func migrateOrderAmounts() error {
+	var orders []Order
+	return DB.Select("id, total_amount, pricing_trace").
+		Where("item_amount = 0 AND total_amount > 0").
+		FindInBatches(&orders, 200, func(tx *gorm.DB, batch int) error {
+			for i := range orders {
+				order := &orders[i]
+				var trace PricingTrace
+				if order.PricingTrace == "" || json.Unmarshal([]byte(order.PricingTrace), &trace) != nil {
+					order.ItemAmount = order.TotalAmount
+				} else {
+					applyOrderAmounts(order, &trace)
+				}
+				columns := orderAmountColumns(order)
+				delete(columns, "total_amount")
+				delete(columns, "pricing_trace")
+				if err := DB.Model(&Order{}).Where("id = ?", order.ID).UpdateColumns(columns).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
@@ -0,0 +1,83 @@
+package gomall
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 订单事件类型，状态变更事件与目标状态同名
+const (
+	OrderEventCreated         = "created"            // 下单
+	OrderEventPaid            = OrderStatusPaid      // 付款
+	OrderEventShipped         = OrderStatusShipped   // 发货
+	OrderEventDelivered       = OrderStatusDelivered // 送达
+	OrderEventCompleted       = OrderStatusCompleted // 完成
+	OrderEventCancelled       = OrderStatusCancelled // 取消
+	OrderEventAddressChanged  = "address_changed"    // 管理员修改收货地址
+	OrderEventRefundRequested = "refund_requested"   // 用户申请退款
+	OrderEventRefundRejected  = "refund_rejected"    // 退款申请被驳回
+	OrderEventRefunded        = "refunded"           // 退款到账
+)
+
+// 在事务中记录订单事件，与引起事件的数据变更一同提交
+func recordOrderEvent(tx *gorm.DB, orderID uint, eventType string, actor OrderActor, detail string) error {
+	event := OrderEvent{
+		OrderID:   orderID,
+		Type:      eventType,
+		ActorRole: actor.Role,
+		Detail:    detail,
+	}
+	if actor.UserID != 0 {
+		event.ActorID = &actor.UserID
+	}
+	return tx.Create(&event).Error
+}
+
+// 旧订单没有下单事件，以下单时间补全
+func migrateOrderEvents() error {
+	return DB.Exec(`INSERT INTO order_events (order_id, type, actor_role, actor_id, created_at)
+		SELECT o.id, ?, ?, o.user_id, o.created_at FROM orders o
+		WHERE NOT EXISTS (SELECT 1 FROM order_events e WHERE e.order_id = o.id AND e.type = ?)`,
+		OrderEventCreated, RoleCustomer, OrderEventCreated).Error
+}
+
+// GetOrderTimeline 获取订单时间线
+// @Summary 获取订单时间线
+// @Description 按时间顺序返回订单发生的所有事件：created 下单、paid 付款、shipped 发货（含物流信息）、delivered 送达、completed 完成、cancelled 取消（含原因）、address_changed 修改收货地址、refund_requested 申请退款，以及每个事件的操作方。下单用户和管理员可以查看
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=[]OrderEvent} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/timeline [get]
+func GetOrderTimeline(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	query := DB.Where("id = ?", orderID)
+	if !HasRole(c, RoleAdmin) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order Order
+	if err := query.First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	var events []OrderEvent
+	if err := DB.Where("order_id = ?", order.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		InternalServerError(c, "订单时间线查询失败")
+		return
+	}
+
+	SuccessResponse(c, events)
+}
@@ -0,0 +1,132 @@
+package gomall
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 订单导出的列：每个订单商品一行，订单信息在该订单的每一行重复，便于财务按行汇总
+var orderExportColumns = []string{
+	"order_no", "parent_order_no", "created_at", "user_id", "username", "status", "payment_method", "channel",
+	"item_amount", "item_discount", "coupon_discount", "points_discount", "shipping_fee", "tax_amount", "total_amount",
+	"product_id", "product_name", "product_specs", "quantity", "price", "subtotal",
+}
+
+// ExportAdminOrders 导出订单（财务对账）
+// @Summary 导出订单
+// @Description 以CSV或XLSX格式导出订单及商品明细，每个订单商品一行，筛选条件与管理端订单列表相同；数据分批读取并直接写入响应，适合导出大量订单
+// @Tags 订单管理
+// @Produce octet-stream
+// @Param format query string false "导出格式：csv、xlsx" default(csv)
+// @Param user_id query int false "下单用户ID"
+// @Param status query string false "订单状态，多个用逗号分隔"
+// @Param start_date query string false "下单开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "下单结束日期（YYYY-MM-DD，含当天）"
+// @Param order_no query string false "订单号（模糊匹配）"
+// @Param parent_order_no query string false "父订单号"
+// @Param product_name query string false "商品名称（模糊匹配）"
+// @Param min_amount query number false "最低订单金额"
+// @Param max_amount query number false "最高订单金额"
+// @Success 200 {file} file "订单明细文件"
+// @Failure 400 {object} ApiResponse "不支持的导出格式或筛选参数格式错误"
+// @Security Bearer
+// @Router /api/admin/orders/export [get]
+func ExportAdminOrders(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		BadRequestError(c, "导出格式只支持csv或xlsx")
+		return
+	}
+
+	query := DB.Model(&Order{})
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			BadRequestError(c, "无效的用户ID")
+			return
+		}
+		query = query.Where("orders.user_id = ?", userID)
+	}
+	query, err := applyOrderFilters(c, query)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	recordAdminAudit(c, "order.export", "order", 0, c.Request.URL.RawQuery)
+
+	filename := fmt.Sprintf("gomall-orders-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	var writeRow func([]string) error
+	var finish func() error
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		xw, err := newXLSXWriter(c.Writer)
+		if err != nil {
+			InternalServerError(c, "导出失败")
+			return
+		}
+		writeRow, finish = xw.WriteRow, xw.Close
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		// 写入BOM，Excel打开时中文不乱码
+		c.Writer.WriteString("\ufeff")
+		cw := csv.NewWriter(c.Writer)
+		writeRow = cw.Write
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	if err := writeRow(orderExportColumns); err != nil {
+		return
+	}
+
+	// 按订单ID分批读取，每批一次查询商品明细，内存中只保留当前批次
+	var orders []Order
+	query.Preload("User").Preload("OrderItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).FindInBatches(&orders, 500, func(tx *gorm.DB, batch int) error {
+		for _, order := range orders {
+			head := []string{
+				order.OrderNo,
+				order.ParentOrderNo,
+				order.CreatedAt.Format("2006-01-02 15:04:05"),
+				strconv.FormatUint(uint64(order.UserID), 10),
+				order.User.Username,
+				order.Status,
+				order.PaymentMethod,
+				order.Channel,
+				fmt.Sprintf("%.2f", order.ItemAmount),
+				fmt.Sprintf("%.2f", order.ItemDiscount),
+				fmt.Sprintf("%.2f", order.CouponDiscount),
+				fmt.Sprintf("%.2f", order.PointsDiscount),
+				fmt.Sprintf("%.2f", order.ShippingFee),
+				fmt.Sprintf("%.2f", order.TaxAmount),
+				fmt.Sprintf("%.2f", order.TotalAmount),
+			}
+			for _, item := range order.OrderItems {
+				record := append(head[:len(head):len(head)],
+					strconv.FormatUint(uint64(item.ProductID), 10),
+					item.ProductName,
+					item.ProductSpecs,
+					strconv.Itoa(item.Quantity),
+					fmt.Sprintf("%.2f", item.Price),
+					fmt.Sprintf("%.2f", item.Price*float64(item.Quantity)),
+				)
+				if err := writeRow(record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	finish()
+}
@@ -0,0 +1,383 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单次导入的最大行数
+const orderImportMaxRows = 5000
+
+// 线下订单中的一个商品行
+type offlineOrderLine struct {
+	row       int
+	productID uint
+	quantity  int
+	unitPrice float64 // 成交单价，0表示按商品售价
+}
+
+// 按 ref 分组后的一笔线下订单
+type offlineOrder struct {
+	ref           string
+	firstRow      int
+	customer      string
+	address       string
+	paymentMethod string
+	paymentRef    string
+	channel       string
+	lines         []offlineOrderLine
+}
+
+// OfflineOrderImported 导入成功的订单
+type OfflineOrderImported struct {
+	Ref         string  `json:"ref"`
+	OrderID     uint    `json:"order_id"`
+	OrderNo     string  `json:"order_no"`
+	TotalAmount float64 `json:"total_amount"`
+	Status      string  `json:"status"`
+}
+
+// OrderImportResult 订单导入结果
+type OrderImportResult struct {
+	Created int                    `json:"created"`
+	Failed  int                    `json:"failed"`
+	Orders  []OfflineOrderImported `json:"orders"`
+	Errors  []ImportRowError       `json:"errors"`
+}
+
+// 同一订单的多行中，订单级字段只需在第一行填写，后续行填写时必须一致
+func mergeOrderField(field *string, value, name string) error {
+	if value == "" {
+		return nil
+	}
+	if *field == "" {
+		*field = value
+		return nil
+	}
+	if *field != value {
+		return fmt.Errorf("同一订单的%s不一致", name)
+	}
+	return nil
+}
+
+func (o *offlineOrder) addRow(line int, row map[string]string) error {
+	for _, field := range []struct {
+		target *string
+		column string
+		name   string
+	}{
+		{&o.customer, "customer", "客户"},
+		{&o.address, "shipping_address", "收货地址"},
+		{&o.paymentMethod, "payment_method", "支付方式"},
+		{&o.paymentRef, "payment_ref", "支付流水号"},
+		{&o.channel, "channel", "下单渠道"},
+	} {
+		if err := mergeOrderField(field.target, row[field.column], field.name); err != nil {
+			return err
+		}
+	}
+
+	productID, err := strconv.ParseUint(row["product_id"], 10, 32)
+	if err != nil || productID == 0 {
+		return fmt.Errorf("无效的商品ID")
+	}
+	quantity, err := strconv.Atoi(row["quantity"])
+	if err != nil || quantity < 1 {
+		return fmt.Errorf("数量必须是大于0的整数")
+	}
+	var unitPrice float64
+	if value := row["unit_price"]; value != "" {
+		if unitPrice, err = strconv.ParseFloat(value, 64); err != nil || unitPrice <= 0 {
+			return fmt.Errorf("成交单价必须是大于0的数字")
+		}
+	}
+
+	o.lines = append(o.lines, offlineOrderLine{row: line, productID: uint(productID), quantity: quantity, unitPrice: unitPrice})
+	return nil
+}
+
+// 按用户名、邮箱或手机号查找客户
+func findOfflineCustomer(customer string) (*User, error) {
+	var user User
+	if err := DB.Where("username = ? OR email = ? OR phone = ?", customer, customer, customer).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("客户 %s 不存在", customer)
+	}
+	if user.Status != 1 {
+		return nil, fmt.Errorf("客户 %s 已被禁用", customer)
+	}
+	return &user, nil
+}
+
+// 与线上下单相同的校验和库存扣减流程创建线下订单，填写支付流水号的订单直接标记为已支付
+func createOfflineOrder(o *offlineOrder, actor OrderActor) (*Order, error) {
+	if o.paymentMethod == "" {
+		o.paymentMethod = PaymentMethodOnline
+	}
+	if o.channel == "" {
+		o.channel = OrderChannelOffline
+	}
+	switch {
+	case o.customer == "":
+		return nil, fmt.Errorf("客户不能为空")
+	case o.address == "":
+		return nil, fmt.Errorf("收货地址不能为空")
+	case o.paymentMethod != PaymentMethodOnline && o.paymentMethod != PaymentMethodCOD:
+		return nil, fmt.Errorf("支付方式只能是 online 或 cod")
+	case o.paymentMethod == PaymentMethodCOD && o.paymentRef != "":
+		return nil, fmt.Errorf("货到付款订单不能填写支付流水号")
+	case o.channel != OrderChannelOffline && o.channel != OrderChannelPhone:
+		return nil, fmt.Errorf("下单渠道只能是 offline 或 phone")
+	}
+
+	// 重复导入同一文件时不重复建单
+	var existing int64
+	DB.Model(&Order{}).Where("channel = ? AND external_ref = ?", o.channel, o.ref).Count(&existing)
+	if existing > 0 {
+		return nil, fmt.Errorf("外部单号 %s 已导入", o.ref)
+	}
+
+	user, err := findOfflineCustomer(o.customer)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按商品售价记录商品行，线下成交价与售价的差额作为调整项
+	trace := NewPricingTrace()
+	products := make([]Product, len(o.lines))
+	items := make([]shippingItem, len(o.lines))
+	for i, line := range o.lines {
+		if err := DB.Where("status = ?", 1).First(&products[i], line.productID).Error; err != nil {
+			return nil, fmt.Errorf("第%d行商品 %d 不存在或已下架", line.row, line.productID)
+		}
+		items[i] = shippingItem{Product: &products[i], Quantity: line.quantity}
+		trace.AddLine(&products[i], products[i].Price, line.quantity)
+		if line.unitPrice > 0 && toCents(line.unitPrice) != toCents(products[i].Price) {
+			trace.AddAdjustment(PricingAdjustmentOffline, "offline:"+o.ref,
+				fmt.Sprintf("「%s」线下成交单价 %.2f 元", products[i].Name, line.unitPrice),
+				(toCents(line.unitPrice)-toCents(products[i].Price))*int64(line.quantity))
+		}
+	}
+
+	// 线下成交价已含运费，只校验配送限制
+	if err := CheckShippingConstraints(items, o.address); err != nil {
+		return nil, err
+	}
+	if err := validateOrderAmount(trace.Total()); err != nil {
+		return nil, err
+	}
+	if o.paymentMethod == PaymentMethodCOD {
+		if err := CheckCODEligibility(trace.Total(), o.address); err != nil {
+			return nil, err
+		}
+	}
+
+	// 预扣可售库存，任一商品不足时退回已预扣的部分
+	quantities := make(map[uint]int)
+	for _, line := range o.lines {
+		quantities[line.productID] += line.quantity
+	}
+	reserved := make(map[uint]int)
+	for productID, quantity := range quantities {
+		if err := GlobalStockManager.ReserveStock(productID, quantity); err != nil {
+			GlobalStockManager.releaseAll(reserved)
+			return nil, err
+		}
+		reserved[productID] = quantity
+	}
+
+	status := OrderStatusPending
+	if o.paymentRef != "" {
+		status = OrderStatusPaid
+	}
+	now := time.Now()
+	order := Order{
+		UserID:          user.ID,
+		OrderNo:         generateOrderNumber(),
+		Status:          status,
+		StatusChangedAt: &now,
+		PaymentMethod:   o.paymentMethod,
+		Channel:         o.channel,
+		ExternalRef:     o.ref,
+		PaymentRef:      o.paymentRef,
+		ShippingAddress: o.address,
+	}
+	// 线下成交价已是最终价格，不另计税费
+	applyOrderAmounts(&order, trace)
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 线下订单已成交，锁定商品行并分配发货仓库后直接扣减数据库库存
+		if err := checkAvailableStock(tx, quantities); err != nil {
+			return err
+		}
+		warehouseID, err := allocateWarehouse(tx, order.ShippingAddress, quantities)
+		if err != nil {
+			return err
+		}
+		order.WarehouseID = warehouseID
+		if err := tx.Create(&order).Error; err != nil {
+			return fmt.Errorf("订单创建失败: %v", err)
+		}
+		if err := recordOrderEvent(tx, order.ID, OrderEventCreated, actor, fmt.Sprintf("线下导入（%s），外部单号 %s", o.channel, o.ref)); err != nil {
+			return err
+		}
+		if status == OrderStatusPaid {
+			if err := recordOrderEvent(tx, order.ID, OrderEventPaid, actor, "收款流水号 "+o.paymentRef); err != nil {
+				return err
+			}
+		}
+		if err := deductStockInTx(tx, &order, quantities); err != nil {
+			return err
+		}
+		productIDs := make([]uint, len(o.lines))
+		for i, line := range o.lines {
+			productIDs[i] = line.productID
+		}
+		specs, err := productSpecTexts(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		for i, line := range o.lines {
+			price := products[i].Price
+			if line.unitPrice > 0 {
+				price = line.unitPrice
+			}
+			orderItem := OrderItem{
+				OrderID:   order.ID,
+				ProductID: line.productID,
+				Quantity:  line.quantity,
+				Price:     price,
+				CostPrice: products[i].CostPrice,
+			}
+			applyItemFulfillment(&orderItem, &products[i])
+			applyItemSnapshot(&orderItem, &products[i], specs)
+			if err := tx.Create(&orderItem).Error; err != nil {
+				return fmt.Errorf("订单项创建失败: %v", err)
+			}
+			if err := tx.Model(&Product{}).Where("id = ?", line.productID).
+				UpdateColumn("sales_count", gorm.Expr("sales_count + ?", line.quantity)).Error; err != nil {
+				return err
+			}
+		}
+		if status == OrderStatusPaid {
+			if err := awardOrderPoints(tx, &order); err != nil {
+				return err
+			}
+			return fulfillDigitalItems(tx, &order)
+		}
+		return nil
+	})
+	if err != nil {
+		GlobalStockManager.releaseAll(reserved)
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ImportOfflineOrders 导入线下订单
+// @Summary 导入线下/电话订单
+// @Description 上传CSV或XLSX文件批量录入线下门店和电话订单，每行一个商品，ref 相同的行属于同一订单。列：ref（外部单号，必填）、customer（客户用户名、邮箱或手机号）、shipping_address、product_id、quantity、unit_price（成交单价，不填按售价）、payment_method（online/cod）、payment_ref（支付流水号，填写后订单为已支付）、channel（offline/phone）。订单级字段只需在每单第一行填写。与线上下单一样校验商品、金额限制和库存；每笔订单独立处理，失败的订单不影响其他订单，同一外部单号不会重复导入
+// @Tags 订单管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV或XLSX文件"
+// @Success 200 {object} ApiResponse{data=OrderImportResult} "导入完成"
+// @Failure 400 {object} ApiResponse "文件格式错误或缺少必要的列"
+// @Security Bearer
+// @Router /api/admin/orders/import [post]
+func ImportOfflineOrders(c *gin.Context) {
+	result := OrderImportResult{Orders: []OfflineOrderImported{}, Errors: []ImportRowError{}}
+
+	var headers []string
+	var orders []*offlineOrder
+	byRef := make(map[string]*offlineOrder)
+	failedRefs := make(map[string]bool)
+	rows := 0
+
+	err := readSheetRows(c, func(line int, values []string) error {
+		if headers == nil {
+			headers = make([]string, len(values))
+			for i, value := range values {
+				headers[i] = strings.ToLower(strings.TrimSpace(value))
+			}
+			for _, required := range []string{"ref", "product_id", "quantity"} {
+				if !containsString(headers, required) {
+					return fmt.Errorf("表头缺少 %s 列", required)
+				}
+			}
+			return nil
+		}
+
+		row := make(map[string]string, len(headers))
+		empty := true
+		for i, header := range headers {
+			if i < len(values) {
+				row[header] = strings.TrimSpace(values[i])
+				empty = empty && row[header] == ""
+			}
+		}
+		if empty {
+			return nil
+		}
+		if rows++; rows > orderImportMaxRows {
+			return fmt.Errorf("单次最多导入%d行", orderImportMaxRows)
+		}
+
+		ref := row["ref"]
+		if ref == "" {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Message: "外部单号不能为空"})
+			return nil
+		}
+		order := byRef[ref]
+		if order == nil {
+			order = &offlineOrder{ref: ref, firstRow: line}
+			byRef[ref] = order
+			orders = append(orders, order)
+		}
+		if err := order.addRow(line, row); err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Message: err.Error()})
+			failedRefs[ref] = true
+		}
+		return nil
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if headers == nil {
+		BadRequestError(c, "文件内容为空")
+		return
+	}
+
+	for _, o := range orders {
+		// 有行解析失败的订单整单不导入，避免部分商品漏单
+		if failedRefs[o.ref] {
+			result.Failed++
+			continue
+		}
+		order, err := createOfflineOrder(o, OrderActor{Role: RoleAdmin, UserID: c.GetUint("user_id")})
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportRowError{Row: o.firstRow, Message: fmt.Sprintf("订单 %s: %v", o.ref, err)})
+			continue
+		}
+		result.Created++
+		result.Orders = append(result.Orders, OfflineOrderImported{
+			Ref:         o.ref,
+			OrderID:     order.ID,
+			OrderNo:     order.OrderNo,
+			TotalAmount: order.TotalAmount,
+			Status:      order.Status,
+		})
+	}
+
+	userID, _ := c.Get("user_id")
+	log.Printf("管理员 %v 导入线下订单: 成功 %d 笔，失败 %d 笔", userID, result.Created, result.Failed)
+
+	SuccessResponse(c, result)
+}
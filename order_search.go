@@ -0,0 +1,137 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 按查询参数筛选订单：status（逗号分隔）、start_date/end_date（下单日期）、order_no（订单号片段）、
+// parent_order_no（拆单的父订单号）、product_name（按下单时的商品名称匹配）、
+// min_amount/max_amount（订单金额），参数格式错误时返回错误
+func applyOrderFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if value := c.Query("status"); value != "" {
+		statuses := splitEnvList(value)
+		for _, status := range statuses {
+			if _, ok := orderStatusLabels[status]; !ok {
+				return nil, fmt.Errorf("无效的订单状态: %s", status)
+			}
+		}
+		query = query.Where("orders.status IN ?", statuses)
+	}
+	if value := c.Query("start_date"); value != "" {
+		start, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("开始日期格式错误")
+		}
+		query = query.Where("orders.created_at >= ?", start)
+	}
+	if value := c.Query("end_date"); value != "" {
+		end, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("结束日期格式错误")
+		}
+		query = query.Where("orders.created_at < ?", end.AddDate(0, 0, 1))
+	}
+	if value := strings.TrimSpace(c.Query("order_no")); value != "" {
+		query = query.Where("orders.order_no LIKE ?", "%"+escapeLikePattern(value)+"%")
+	}
+	if value := strings.TrimSpace(c.Query("parent_order_no")); value != "" {
+		query = query.Where("orders.parent_order_no = ?", value)
+	}
+	if value := strings.TrimSpace(c.Query("product_name")); value != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product_name LIKE ?)",
+			"%"+escapeLikePattern(value)+"%")
+	}
+	if value := c.Query("min_amount"); value != "" {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("最低金额格式错误")
+		}
+		query = query.Where("orders.total_amount >= ?", amount)
+	}
+	if value := c.Query("max_amount"); value != "" {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("最高金额格式错误")
+		}
+		query = query.Where("orders.total_amount <= ?", amount)
+	}
+	return query, nil
+}
+
+// GetAdminOrders 获取全部订单（管理端）
+// @Summary 获取全部订单
+// @Description 分页查询所有用户的订单，筛选条件与用户订单列表相同，另可按下单用户筛选
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param user_id query int false "下单用户ID"
+// @Param status query string false "订单状态，多个用逗号分隔"
+// @Param start_date query string false "下单开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "下单结束日期（YYYY-MM-DD，含当天）"
+// @Param order_no query string false "订单号（模糊匹配）"
+// @Param parent_order_no query string false "父订单号，查询同一次下单拆分出的子订单"
+// @Param product_name query string false "商品名称（模糊匹配）"
+// @Param min_amount query number false "最低订单金额"
+// @Param max_amount query number false "最高订单金额"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Order}} "查询成功"
+// @Failure 400 {object} ApiResponse "筛选参数格式错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/orders [get]
+func GetAdminOrders(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&Order{})
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			BadRequestError(c, "无效的用户ID")
+			return
+		}
+		query = query.Where("orders.user_id = ?", userID)
+	}
+	query, err := applyOrderFilters(c, query)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		InternalServerError(c, "订单查询失败")
+		return
+	}
+
+	var orders []Order
+	if err := query.Preload("User").Preload("OrderItems.Product").
+		Order("orders.created_at DESC, orders.id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&orders).Error; err != nil {
+		InternalServerError(c, "订单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, orders, total, page, pageSize)
+}
@@ -0,0 +1,217 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 履约时效类型
+const (
+	OrderSLAUnshipped   = "unshipped"   // 已支付未发货
+	OrderSLAUndelivered = "undelivered" // 已发货长时间未送达
+)
+
+// 同一订单在同一状态下只告警一次
+const orderSLAAlertTTL = 30 * 24 * time.Hour
+
+// 单条告警通知中最多列出的订单数
+const orderSLAAlertMaxOrders = 20
+
+// OrderSLABreach 超时订单
+type OrderSLABreach struct {
+	OrderID         uint      `json:"order_id"`
+	OrderNo         string    `json:"order_no"`
+	UserID          uint      `json:"user_id"`
+	Status          string    `json:"status"`
+	PaymentMethod   string    `json:"payment_method"`
+	StatusChangedAt time.Time `json:"status_changed_at"` // 进入当前状态的时间
+	HoursInState    float64   `json:"hours_in_state"`
+	SLAHours        int       `json:"sla_hours"`
+	OverdueHours    float64   `json:"overdue_hours"`
+}
+
+// 订单状态变更的更新字段，同时记录进入新状态的时间
+func orderStatusUpdates(status string) map[string]interface{} {
+	return map[string]interface{}{
+		"status":            status,
+		"status_changed_at": time.Now(),
+	}
+}
+
+// 旧订单没有状态变更时间，以最后更新时间补全
+func migrateOrderStatusChangedAt() error {
+	return DB.Model(&Order{}).
+		Where("status_changed_at IS NULL").
+		UpdateColumn("status_changed_at", gorm.Expr("updated_at")).Error
+}
+
+// 各时效类型对应的订单状态和时限（小时）
+func orderSLARule(slaType string) (string, int, bool) {
+	switch slaType {
+	case OrderSLAUnshipped:
+		return OrderStatusPaid, AppConfig.OrderShipSLAHours, true
+	case OrderSLAUndelivered:
+		return OrderStatusShipped, AppConfig.OrderDeliverySLAHours, true
+	}
+	return "", 0, false
+}
+
+// 查询超过时限的订单，按进入状态的时间从早到晚排列
+func findOrderSLABreaches(slaType string, limit, offset int) ([]OrderSLABreach, int64, error) {
+	status, slaHours, ok := orderSLARule(slaType)
+	if !ok || slaHours <= 0 {
+		return []OrderSLABreach{}, 0, nil
+	}
+
+	now := time.Now()
+	query := DB.Model(&Order{}).
+		Where("status = ? AND status_changed_at < ?", status, now.Add(-time.Duration(slaHours)*time.Hour))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []Order
+	if err := query.Order("status_changed_at ASC").Limit(limit).Offset(offset).Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+
+	breaches := make([]OrderSLABreach, 0, len(orders))
+	for _, order := range orders {
+		hours := now.Sub(*order.StatusChangedAt).Hours()
+		breaches = append(breaches, OrderSLABreach{
+			OrderID:         order.ID,
+			OrderNo:         order.OrderNo,
+			UserID:          order.UserID,
+			Status:          order.Status,
+			PaymentMethod:   order.PaymentMethod,
+			StatusChangedAt: *order.StatusChangedAt,
+			HoursInState:    hours,
+			SLAHours:        slaHours,
+			OverdueHours:    hours - float64(slaHours),
+		})
+	}
+	return breaches, total, nil
+}
+
+// 查询全部管理员，用于发送告警
+func adminUserIDs() []uint {
+	var ids []uint
+	DB.Model(&UserRole{}).
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("roles.name = ?", RoleAdmin).
+		Pluck("user_roles.user_id", &ids)
+	return ids
+}
+
+// 检查超时订单，对新出现的超时订单向管理员发送站内通知
+func checkOrderSLAs() {
+	admins := adminUserIDs()
+	if len(admins) == 0 {
+		return
+	}
+
+	titles := map[string]string{
+		OrderSLAUnshipped:   "订单发货超时",
+		OrderSLAUndelivered: "订单配送超时",
+	}
+	for _, slaType := range []string{OrderSLAUnshipped, OrderSLAUndelivered} {
+		breaches, _, err := findOrderSLABreaches(slaType, 1000, 0)
+		if err != nil {
+			log.Printf("超时订单查询失败: %v", err)
+			continue
+		}
+
+		var orderNos []string
+		for _, breach := range breaches {
+			key := fmt.Sprintf("orders:sla:alerted:%s:%d", slaType, breach.OrderID)
+			if ok, _ := RDB.SetNX(CTX, key, 1, orderSLAAlertTTL).Result(); ok {
+				orderNos = append(orderNos, breach.OrderNo)
+			}
+		}
+		if len(orderNos) == 0 {
+			continue
+		}
+
+		_, slaHours, _ := orderSLARule(slaType)
+		content := fmt.Sprintf("%d 个订单超过 %d 小时未处理：%s", len(orderNos), slaHours, strings.Join(orderNos, "、"))
+		if len(orderNos) > orderSLAAlertMaxOrders {
+			content = fmt.Sprintf("%d 个订单超过 %d 小时未处理，包括：%s 等", len(orderNos), slaHours,
+				strings.Join(orderNos[:orderSLAAlertMaxOrders], "、"))
+		}
+		for _, adminID := range admins {
+			SendNotification(adminID, NotificationTypeSystem, titles[slaType], content)
+		}
+		log.Printf("%s：%d 个订单", titles[slaType], len(orderNos))
+	}
+}
+
+// StartOrderSLAMonitor 启动订单履约时效检查任务，每小时检查一次
+func StartOrderSLAMonitor() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			// 多实例部署时每小时只由一个实例检查
+			key := "orders:sla:checked:" + time.Now().Format("2006010215")
+			if ok, _ := RDB.SetNX(CTX, key, 1, 2*time.Hour).Result(); ok {
+				checkOrderSLAs()
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("订单履约时效检查任务已启动")
+}
+
+// GetOrderSLABreaches 获取超时订单
+// @Summary 获取超时订单
+// @Description 查询超过履约时限的订单：unshipped 为已支付超过 ORDER_SHIP_SLA_HOURS 小时未发货，undelivered 为已发货超过 ORDER_DELIVERY_SLA_HOURS 小时未送达；按超时时长从长到短排列
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param type query string false "超时类型" Enums(unshipped, undelivered) default(unshipped)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]OrderSLABreach}} "查询成功"
+// @Failure 400 {object} ApiResponse "超时类型无效"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/orders/sla-breaches [get]
+func GetOrderSLABreaches(c *gin.Context) {
+	slaType := c.DefaultQuery("type", OrderSLAUnshipped)
+	if _, _, ok := orderSLARule(slaType); !ok {
+		BadRequestError(c, "超时类型只能是 unshipped 或 undelivered")
+		return
+	}
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	breaches, total, err := findOrderSLABreaches(slaType, pageSize, (page-1)*pageSize)
+	if err != nil {
+		InternalServerError(c, "超时订单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, breaches, total, page, pageSize)
+}
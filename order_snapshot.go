@@ -0,0 +1,50 @@
+package gomall
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 商品规格参数的文字描述，如「颜色：黑色；屏幕尺寸：6.1英寸」，按分类属性的排序
+func productSpecTexts(db *gorm.DB, productIDs []uint) (map[uint]string, error) {
+	var values []ProductAttributeValue
+	err := db.Joins("Attribute").
+		Where("product_attribute_values.product_id IN ?", productIDs).
+		Order("Attribute.sort_order, Attribute.id").
+		Find(&values).Error
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[uint][]string)
+	for _, value := range values {
+		parts[value.ProductID] = append(parts[value.ProductID], value.Attribute.Name+"："+value.Value+value.Attribute.Unit)
+	}
+	specs := make(map[uint]string, len(parts))
+	for productID, texts := range parts {
+		specs[productID] = strings.Join(texts, "；")
+	}
+	return specs, nil
+}
+
+// 下单时保存商品名称、规格和主图，之后商品改名、换图或修改规格不影响历史订单
+func applyItemSnapshot(item *OrderItem, product *Product, specs map[uint]string) {
+	item.ProductName = product.Name
+	item.ProductSpecs = specs[product.ID]
+	cover, _, _ := inspectProductImages(product)
+	if cover == "" {
+		if images := productImages(product); len(images) > 0 {
+			cover = images[0]
+		}
+	}
+	item.ProductImage = cover
+}
+
+// 旧订单商品没有快照，以当前的商品名称和第一张图片补全
+func migrateOrderItemSnapshots() error {
+	return DB.Exec(`UPDATE order_items oi JOIN products p ON p.id = oi.product_id
+		SET oi.product_name = p.name,
+			oi.product_image = COALESCE(JSON_UNQUOTE(JSON_EXTRACT(p.images, '$[0]')), '')
+		WHERE oi.product_name = ''`).Error
+}
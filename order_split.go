@@ -0,0 +1,115 @@
+package gomall
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 拆单后的一个子订单：同一商家、同一发货仓库的商品行
+type orderGroup struct {
+	SellerID    *uint
+	WarehouseID *uint
+	Lines       []CartItem
+}
+
+func sameOptionalID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// 按商家和发货仓库拆分订单：不同商家的商品分别成单；同一商家的商品优先整单从一个仓库发货，
+// 没有能整单发货的仓库时按商品分别分配仓库，分到不同仓库的商品各自成单，未分仓管理的商品随第一个子订单发货。
+// 调用前需通过 checkAvailableStock 锁定商品行
+func planOrderSplit(tx *gorm.DB, address string, lines []CartItem) ([]orderGroup, error) {
+	var sellerGroups []orderGroup
+	for _, line := range lines {
+		found := false
+		for i := range sellerGroups {
+			if sameOptionalID(sellerGroups[i].SellerID, line.Product.SellerID) {
+				sellerGroups[i].Lines = append(sellerGroups[i].Lines, line)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sellerGroups = append(sellerGroups, orderGroup{SellerID: line.Product.SellerID, Lines: []CartItem{line}})
+		}
+	}
+
+	var groups []orderGroup
+	for _, sellerGroup := range sellerGroups {
+		split, err := splitByWarehouse(tx, address, sellerGroup)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, split...)
+	}
+	return groups, nil
+}
+
+// 为同一商家的商品分配发货仓库，必要时拆成多个子订单
+func splitByWarehouse(tx *gorm.DB, address string, group orderGroup) ([]orderGroup, error) {
+	warehouseID, err := allocateWarehouse(tx, address, lineQuantities(group.Lines))
+	if err == nil {
+		group.WarehouseID = warehouseID
+		return []orderGroup{group}, nil
+	}
+
+	var groups []orderGroup
+	var unmanaged []CartItem
+	for _, line := range group.Lines {
+		warehouseID, err := allocateWarehouse(tx, address, map[uint]int{line.ProductID: line.Quantity})
+		if err != nil {
+			return nil, fmt.Errorf("商品「%s」%v", line.Product.Name, err)
+		}
+		if warehouseID == nil {
+			unmanaged = append(unmanaged, line)
+			continue
+		}
+		found := false
+		for i := range groups {
+			if sameOptionalID(groups[i].WarehouseID, warehouseID) {
+				groups[i].Lines = append(groups[i].Lines, line)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, orderGroup{SellerID: group.SellerID, WarehouseID: warehouseID, Lines: []CartItem{line}})
+		}
+	}
+	if len(groups) == 0 {
+		return []orderGroup{{SellerID: group.SellerID, Lines: unmanaged}}, nil
+	}
+	groups[0].Lines = append(groups[0].Lines, unmanaged...)
+	return groups, nil
+}
+
+// 按商品汇总商品行的数量
+func lineQuantities(lines []CartItem) map[uint]int {
+	quantities := make(map[uint]int, len(lines))
+	for _, line := range lines {
+		quantities[line.ProductID] += line.Quantity
+	}
+	return quantities
+}
+
+// 将下单时使用的积分依次分摊到各子订单，每个子订单不超过其抵扣上限
+func splitRedeemPoints(points int, traces []*PricingTrace) ([]int, error) {
+	allocated := make([]int, len(traces))
+	remaining := points
+	limit := 0
+	for i, trace := range traces {
+		maxPoints := maxRedeemablePoints(trace.TotalCents)
+		limit += maxPoints
+		allocated[i] = min(remaining, maxPoints)
+		remaining -= allocated[i]
+	}
+	if remaining > 0 {
+		return nil, fmt.Errorf("本单最多可使用 %d 积分", limit)
+	}
+	return allocated, nil
+}
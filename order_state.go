@@ -0,0 +1,195 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 系统自动变更订单状态时的操作方（超时取消、虚拟商品自动完成等），其他操作方使用角色名
+const OrderActorSystem = "system"
+
+var (
+	errIllegalOrderTransition   = errors.New("订单状态不允许此变更")
+	errOrderTransitionForbidden = errors.New("无权执行此订单状态变更")
+	errOrderStatusChanged       = errors.New("订单状态已被其他操作修改，请刷新后重试")
+	errOrderJobTimeout          = errors.New("订单处理超时")
+)
+
+// OrderActor 订单状态变更的操作方
+type OrderActor struct {
+	Role   string // customer（下单用户本人）、admin、courier、system
+	UserID uint   // 系统操作时为0
+}
+
+var systemOrderActor = OrderActor{Role: OrderActorSystem}
+
+// 订单状态名称
+var orderStatusLabels = map[string]string{
+	OrderStatusPending:   "待支付",
+	OrderStatusPaid:      "已支付",
+	OrderStatusShipped:   "已发货",
+	OrderStatusDelivered: "已送达",
+	OrderStatusCompleted: "已完成",
+	OrderStatusCancelled: "已取消",
+}
+
+// 订单状态流转规则：当前状态 -> 目标状态 -> 允许的操作方，表中没有的变更（如 delivered→pending）一律拒绝
+var orderTransitions = map[string]map[string][]string{
+	OrderStatusPending: {
+		OrderStatusPaid:      {RoleAdmin, OrderActorSystem}, // 在线支付订单由支付回调标记已付款，管理员可确认线下付款
+		OrderStatusShipped:   {RoleAdmin},                   // 货到付款订单无需付款直接发货
+		OrderStatusCancelled: {RoleCustomer, RoleAdmin, OrderActorSystem},
+	},
+	OrderStatusPaid: {
+		OrderStatusShipped:   {RoleAdmin},
+		OrderStatusCompleted: {OrderActorSystem}, // 只含虚拟商品的订单发放后自动完成
+		OrderStatusCancelled: {RoleAdmin},        // 发货前由管理员取消，退回库存和积分
+	},
+	OrderStatusShipped: {
+		OrderStatusDelivered: {RoleCustomer, RoleAdmin, RoleCourier}, // 用户确认收货或配送员签收
+	},
+	OrderStatusDelivered: {
+		OrderStatusCompleted: {RoleCustomer, RoleAdmin, OrderActorSystem},
+	},
+}
+
+func orderStatusLabel(status string) string {
+	if label, ok := orderStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// 按当前请求用户的角色确定操作方，同时拥有多个角色时按管理员、配送员、普通用户的顺序
+func orderActorFromContext(c *gin.Context) OrderActor {
+	actor := OrderActor{Role: RoleCustomer, UserID: c.GetUint("user_id")}
+	if HasRole(c, RoleAdmin) {
+		actor.Role = RoleAdmin
+	} else if HasRole(c, RoleCourier) {
+		actor.Role = RoleCourier
+	}
+	return actor
+}
+
+// 检查操作方能否将订单变更到目标状态
+func checkOrderTransition(order *Order, to string, actor OrderActor) error {
+	roles, ok := orderTransitions[order.Status][to]
+	if !ok {
+		return fmt.Errorf("%w：%s → %s", errIllegalOrderTransition, orderStatusLabel(order.Status), orderStatusLabel(to))
+	}
+
+	allowed := false
+	for _, role := range roles {
+		if role == actor.Role {
+			allowed = true
+			break
+		}
+	}
+	// 普通用户只能操作自己的订单
+	if !allowed || (actor.Role == RoleCustomer && order.UserID != actor.UserID) {
+		return fmt.Errorf("%w：%s → %s", errOrderTransitionForbidden, orderStatusLabel(order.Status), orderStatusLabel(to))
+	}
+
+	// 货到付款订单在收款时完成支付，在线支付订单必须先付款再发货
+	switch {
+	case order.PaymentMethod == PaymentMethodCOD && to == OrderStatusPaid:
+		return fmt.Errorf("%w：货到付款订单在签收时收款", errIllegalOrderTransition)
+	case order.PaymentMethod != PaymentMethodCOD && order.Status == OrderStatusPending && to == OrderStatusShipped:
+		return fmt.Errorf("%w：在线支付订单需付款后才能发货", errIllegalOrderTransition)
+	case order.PaymentMethod == PaymentMethodCOD && to == OrderStatusDelivered && actor.Role == RoleCustomer:
+		return fmt.Errorf("%w：货到付款订单由配送员收款后签收", errIllegalOrderTransition)
+	}
+	return nil
+}
+
+// 在事务中校验并变更订单状态，记录变更历史和订单事件。按变更前的状态条件更新，
+// 并发修改时只有一个能成功。成功后 order.Status 为新状态
+func transitionOrder(tx *gorm.DB, order *Order, to string, actor OrderActor, note string) error {
+	if err := checkOrderTransition(order, to, actor); err != nil {
+		return err
+	}
+
+	result := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, order.Status).Updates(orderStatusUpdates(to))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errOrderStatusChanged
+	}
+
+	history := OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		ActorRole:  actor.Role,
+		Note:       note,
+	}
+	if actor.UserID != 0 {
+		history.ActorID = &actor.UserID
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+	if err := recordOrderEvent(tx, order.ID, to, actor, note); err != nil {
+		return err
+	}
+	order.Status = to
+	return nil
+}
+
+// 按订单状态变更的错误返回对应的HTTP状态
+func orderTransitionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errOrderTransitionForbidden):
+		ForbiddenError(c, err.Error())
+	case errors.Is(err, errIllegalOrderTransition):
+		BadRequestError(c, err.Error())
+	case errors.Is(err, errOrderStatusChanged):
+		ConflictError(c, err.Error())
+	default:
+		InternalServerError(c, message+": "+err.Error())
+	}
+}
+
+// GetOrderStatusHistory 获取订单状态变更历史
+// @Summary 获取订单状态变更历史
+// @Description 按时间顺序返回订单的状态变更记录，包括操作方和备注；下单用户和管理员可以查看
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=[]OrderStatusHistory} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/history [get]
+func GetOrderStatusHistory(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	query := DB.Where("id = ?", orderID)
+	if !HasRole(c, RoleAdmin) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order Order
+	if err := query.First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	var history []OrderStatusHistory
+	if err := DB.Where("order_id = ?", order.ID).Order("id").Find(&history).Error; err != nil {
+		InternalServerError(c, "订单状态历史查询失败")
+		return
+	}
+
+	SuccessResponse(c, history)
+}
@@ -0,0 +1,200 @@
+package gomall
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 找回密码验证码的最大校验次数
+const passwordResetMaxAttempts = 5
+
+type ForgotPasswordRequest struct {
+	Account string `json:"account" binding:"required"`                // 用户名、邮箱或手机号
+	Channel string `json:"channel" binding:"required,oneof=email sms"` // 接收渠道：email、sms
+}
+
+type ResetPasswordRequest struct {
+	Account     string `json:"account" binding:"required"`
+	Code        string `json:"code" binding:"required,len=6"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+func passwordResetCodeKey(userID uint) string {
+	return fmt.Sprintf("password_reset:code:%d", userID)
+}
+
+func passwordResetLimitKey(userID uint) string {
+	return fmt.Sprintf("password_reset:limit:%d", userID)
+}
+
+func passwordResetCooldownKey(userID uint) string {
+	return fmt.Sprintf("password_reset:cooldown:%d", userID)
+}
+
+// 生成6位数字验证码
+func generateVerifyCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// 根据用户名、邮箱或手机号查找用户
+func findUserByAccount(account string) (*User, error) {
+	var user User
+	err := DB.Where("username = ? OR email = ? OR phone = ?", account, account, account).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ForgotPassword 发送找回密码验证码
+// @Summary 发送找回密码验证码
+// @Description 向账号绑定的邮箱或手机号发送6位验证码，同一账号有发送频率限制
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "账号与接收渠道"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "发送成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或账号未绑定该渠道"
+// @Failure 429 {object} ApiResponse "请求过于频繁"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/users/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	// 账号不存在时返回相同提示，避免泄露账号信息
+	user, err := findUserByAccount(req.Account)
+	if err != nil {
+		SuccessResponse(c, gin.H{"message": "如果账号存在，验证码已发送"})
+		return
+	}
+
+	if req.Channel == "sms" && user.Phone == "" {
+		BadRequestError(c, "该账号未绑定手机号")
+		return
+	}
+
+	// 发送间隔限制
+	cooldown := time.Duration(AppConfig.PasswordResetCooldownSeconds) * time.Second
+	if ok, _ := RDB.SetNX(CTX, passwordResetCooldownKey(user.ID), 1, cooldown).Result(); !ok {
+		TooManyRequestsError(c, "验证码发送过于频繁，请稍后再试")
+		return
+	}
+
+	// 每小时发送次数限制
+	limitKey := passwordResetLimitKey(user.ID)
+	count, err := RDB.Incr(CTX, limitKey).Result()
+	if err != nil {
+		InternalServerError(c, "验证码发送失败")
+		return
+	}
+	if count == 1 {
+		RDB.Expire(CTX, limitKey, time.Hour)
+	}
+	if count > int64(AppConfig.PasswordResetHourlyLimit) {
+		TooManyRequestsError(c, "验证码发送次数已达上限，请一小时后再试")
+		return
+	}
+
+	code, err := generateVerifyCode()
+	if err != nil {
+		InternalServerError(c, "验证码生成失败")
+		return
+	}
+
+	ttl := time.Duration(AppConfig.PasswordResetCodeTTLMinutes) * time.Minute
+	codeKey := passwordResetCodeKey(user.ID)
+	pipe := RDB.TxPipeline()
+	pipe.HSet(CTX, codeKey, "code", code, "attempts", 0)
+	pipe.Expire(CTX, codeKey, ttl)
+	if _, err := pipe.Exec(CTX); err != nil {
+		InternalServerError(c, "验证码保存失败")
+		return
+	}
+
+	if req.Channel == "sms" {
+		err = SMSProvider.SendVerifyCode(user.Phone, code)
+	} else {
+		content := fmt.Sprintf("您的GoMall密码重置验证码为 %s，%d分钟内有效。如非本人操作请忽略。", code, AppConfig.PasswordResetCodeTTLMinutes)
+		err = Mailer.SendEmail(user.Email, "GoMall密码重置", content)
+	}
+	if err != nil {
+		RDB.Del(CTX, codeKey)
+		// 屏蔽的地址按已发送处理，避免泄露账号状态
+		if errors.Is(err, ErrMessageSuppressed) {
+			SuccessResponse(c, gin.H{"message": "如果账号存在，验证码已发送"})
+			return
+		}
+		InternalServerError(c, "验证码发送失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "如果账号存在，验证码已发送"})
+}
+
+// ResetPassword 使用验证码重置密码
+// @Summary 重置密码
+// @Description 校验找回密码验证码并设置新密码，成功后该账号所有登录会话失效
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "验证码与新密码"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "重置成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或验证码错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/users/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	user, err := findUserByAccount(req.Account)
+	if err != nil {
+		BadRequestError(c, "验证码错误或已过期")
+		return
+	}
+
+	codeKey := passwordResetCodeKey(user.ID)
+	stored, err := RDB.HGet(CTX, codeKey, "code").Result()
+	if err != nil {
+		BadRequestError(c, "验证码错误或已过期")
+		return
+	}
+
+	// 校验次数限制，超过后验证码作废
+	attempts, _ := RDB.HIncrBy(CTX, codeKey, "attempts", 1).Result()
+	if attempts > passwordResetMaxAttempts {
+		RDB.Del(CTX, codeKey)
+		BadRequestError(c, "验证码错误次数过多，请重新获取")
+		return
+	}
+
+	if stored != req.Code {
+		BadRequestError(c, "验证码错误或已过期")
+		return
+	}
+
+	if err := DB.Model(user).Update("password_hash", HashPassword(req.NewPassword)).Error; err != nil {
+		InternalServerError(c, "密码更新失败")
+		return
+	}
+
+	RDB.Del(CTX, codeKey)
+	InvalidateUserSessions(user.ID)
+
+	SuccessResponse(c, gin.H{"message": "密码重置成功，请重新登录"})
+}
@@ -0,0 +1,394 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支付单状态
+const (
+	PaymentStatusPending   = "pending"   // 待支付
+	PaymentStatusSucceeded = "succeeded" // 支付成功
+	PaymentStatusFailed    = "failed"    // 支付失败
+	PaymentStatusClosed    = "closed"    // 已关闭（重新发起支付后旧的支付单关闭）
+	PaymentStatusRefunded  = "refunded"  // 已退回（未付清的订单取消时钱包支付退回余额）
+)
+
+// PayOrderRequest 发起支付请求
+type PayOrderRequest struct {
+	Channel      string  `json:"channel" binding:"required"`    // 支付渠道：wallet 钱包余额全额支付，或 PAYMENT_CHANNELS 中启用的 mock、stripe
+	WalletAmount float64 `json:"wallet_amount" binding:"min=0"` // 使用支付渠道时先用钱包余额支付的金额，其余通过支付渠道支付
+}
+
+// PayOrderResponse 发起支付结果
+type PayOrderResponse struct {
+	Payment Payment        `json:"payment"`          // 钱包余额付清时为钱包支付单
+	Intent  *PaymentIntent `json:"intent,omitempty"` // 客户端拉起支付所需的参数
+}
+
+func generatePaymentNumber() string {
+	return fmt.Sprintf("PAY%s%06d", time.Now().Format("20060102150405"), rand.Intn(1000000))
+}
+
+// 失败原因按字段长度截断
+func paymentFailReason(reason string) string {
+	if runes := []rune(reason); len(runes) > 200 {
+		return string(runes[:200])
+	}
+	return reason
+}
+
+// PayOrder 发起订单支付
+// @Summary 发起订单支付
+// @Description 为待支付的在线支付订单创建支付单并在支付渠道创建交易，返回客户端拉起支付所需的参数；支付结果以支付渠道的回调为准。重新发起支付时关闭之前未完成的支付单。channel 为 wallet 时用钱包余额支付全部待支付金额；使用支付渠道时可通过 wallet_amount 先用余额支付一部分，支付渠道只需支付剩余金额，订单付清后标记为已支付，未付清即取消时余额退回钱包。应付金额为0的订单直接标记为已支付
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param payment body PayOrderRequest true "支付渠道"
+// @Success 200 {object} ApiResponse{data=PayOrderResponse} "支付单创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败、不支持的支付渠道、钱包余额不足或订单无需支付"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "支付渠道创建交易失败"
+// @Security Bearer
+// @Router /api/orders/{id}/pay [post]
+func PayOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	var req PayOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	gateway, ok := PaymentGateways[req.Channel]
+	if !ok && req.Channel != PaymentChannelWallet {
+		BadRequestError(c, "不支持的支付渠道")
+		return
+	}
+
+	var order Order
+	if err := DB.Where("id = ? AND user_id = ?", orderID, c.GetUint("user_id")).First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+	if order.PaymentMethod != PaymentMethodOnline {
+		BadRequestError(c, "货到付款订单在签收时付款")
+		return
+	}
+	if order.Status != OrderStatusPending {
+		BadRequestError(c, "订单"+orderStatusLabel(order.Status)+"，无需支付")
+		return
+	}
+
+	// 积分等全额抵扣的订单无需经过支付渠道
+	if order.TotalAmount <= 0 {
+		statusReq := UpdateOrderStatusRequest{Status: OrderStatusPaid, Note: "应付金额为0，无需支付", actor: systemOrderActor}
+		if err := runOrderStatusJob(order.ID, order.UserID, statusReq); err != nil {
+			if errors.Is(err, errOrderJobTimeout) {
+				InternalServerError(c, "订单状态更新超时")
+				return
+			}
+			orderTransitionError(c, err, "订单支付失败")
+			return
+		}
+		SuccessResponse(c, PayOrderResponse{})
+		return
+	}
+
+	// 先用钱包余额支付，之前已用余额支付的部分不再重复支付
+	outstandingCents := toCents(order.TotalAmount) - orderPaidCents(DB, order.ID)
+	walletCents := toCents(req.WalletAmount)
+	if req.Channel == PaymentChannelWallet {
+		walletCents = outstandingCents
+	}
+	if walletCents > outstandingCents {
+		BadRequestError(c, fmt.Sprintf("余额支付金额超过待支付金额 %.2f 元", fromCents(outstandingCents)))
+		return
+	}
+	var result PayOrderResponse
+	if walletCents > 0 {
+		walletPayment, err := payOrderFromWallet(&order, walletCents)
+		switch {
+		case errors.Is(err, errInsufficientBalance):
+			BadRequestError(c, err.Error())
+			return
+		case errors.Is(err, errOrderStatusChanged):
+			ConflictError(c, err.Error())
+			return
+		case err != nil:
+			InternalServerError(c, "钱包余额支付失败")
+			return
+		}
+		result.Payment = *walletPayment
+		outstandingCents -= walletCents
+	}
+	// 已用余额付清（包括之前付清但订单状态未更新成功的情况）
+	if outstandingCents <= 0 {
+		if err := settleOrderPayment(&order, "钱包余额支付"); err != nil {
+			if errors.Is(err, errOrderJobTimeout) {
+				InternalServerError(c, "订单状态更新超时")
+				return
+			}
+			orderTransitionError(c, err, "订单支付失败")
+			return
+		}
+		SuccessResponse(c, result)
+		return
+	}
+
+	payment := Payment{
+		PaymentNo: generatePaymentNumber(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Purpose:   PaymentPurposeOrder,
+		Channel:   req.Channel,
+		Amount:    fromCents(outstandingCents),
+		Status:    PaymentStatusPending,
+	}
+	if err := DB.Create(&payment).Error; err != nil {
+		InternalServerError(c, "支付单创建失败")
+		return
+	}
+
+	intent, err := gateway.CreatePayment(&payment, "GoMall订单 "+order.OrderNo)
+	if err != nil {
+		DB.Model(&payment).Updates(map[string]interface{}{"status": PaymentStatusFailed, "fail_reason": paymentFailReason(err.Error())})
+		log.Printf("订单 %s 支付单 %s 创建交易失败: %v", order.OrderNo, payment.PaymentNo, err)
+		InternalServerError(c, "支付渠道暂时不可用，请稍后重试")
+		return
+	}
+	if intent.TradeNo != "" {
+		payment.TradeNo = intent.TradeNo
+		DB.Model(&payment).Update("trade_no", payment.TradeNo)
+	}
+	DB.Model(&Payment{}).
+		Where("order_id = ? AND status = ? AND id <> ?", order.ID, PaymentStatusPending, payment.ID).
+		Update("status", PaymentStatusClosed)
+
+	SuccessResponse(c, PayOrderResponse{Payment: payment, Intent: intent})
+}
+
+// 处理支付渠道的支付结果：更新支付单，支付成功时将订单标记为已支付。
+// 重复回调不会重复处理；订单仍待支付但状态更新失败时返回错误，由支付渠道重试回调
+func completePayment(channel string, notification *PaymentNotification) error {
+	var payment Payment
+	if err := DB.Where("payment_no = ? AND channel = ?", notification.PaymentNo, channel).First(&payment).Error; err != nil {
+		return fmt.Errorf("支付单 %s 不存在", notification.PaymentNo)
+	}
+
+	if !notification.Succeeded {
+		DB.Model(&Payment{}).Where("id = ? AND status = ?", payment.ID, PaymentStatusPending).
+			Updates(map[string]interface{}{"status": PaymentStatusFailed, "fail_reason": paymentFailReason(notification.FailReason)})
+		return nil
+	}
+	if notification.AmountCents != toCents(payment.Amount) {
+		log.Printf("支付单 %s 实付金额 %.2f 与应付金额 %.2f 不一致", payment.PaymentNo, fromCents(notification.AmountCents), payment.Amount)
+		return fmt.Errorf("支付单 %s 金额不一致", payment.PaymentNo)
+	}
+	if payment.Purpose == PaymentPurposeTopup {
+		return completeTopup(&payment, notification.TradeNo)
+	}
+
+	if payment.Status != PaymentStatusSucceeded {
+		now := time.Now()
+		err := DB.Model(&Payment{}).Where("id = ? AND status <> ?", payment.ID, PaymentStatusSucceeded).
+			Updates(map[string]interface{}{
+				"status":      PaymentStatusSucceeded,
+				"trade_no":    notification.TradeNo,
+				"paid_at":     now,
+				"fail_reason": "",
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var order Order
+	if err := DB.First(&order, payment.OrderID).Error; err != nil {
+		return fmt.Errorf("订单 %d 不存在", payment.OrderID)
+	}
+	switch order.Status {
+	case OrderStatusPending:
+		// 部分用余额支付的订单，支付渠道只支付剩余金额
+		if paid := orderPaidCents(DB, order.ID); paid < toCents(order.TotalAmount) {
+			log.Printf("订单 %s 已支付 %.2f 元，未付清应付金额 %.2f 元", order.OrderNo, fromCents(paid), order.TotalAmount)
+			return nil
+		}
+		return settleOrderPayment(&order, fmt.Sprintf("%s 支付成功，交易号 %s", channel, notification.TradeNo))
+	case OrderStatusCancelled:
+		// 超时取消后才完成的支付，需要人工退款
+		log.Printf("订单 %s 已取消，支付单 %s 收到付款 %.2f 元，需要退款", order.OrderNo, payment.PaymentNo, payment.Amount)
+	default:
+		var paid int64
+		DB.Model(&Payment{}).Where("order_id = ? AND status = ? AND id <> ?", order.ID, PaymentStatusSucceeded, payment.ID).Count(&paid)
+		if paid > 0 {
+			log.Printf("订单 %s 重复支付，支付单 %s 收到付款 %.2f 元，需要退款", order.OrderNo, payment.PaymentNo, payment.Amount)
+		}
+	}
+	return nil
+}
+
+// 订单付清后标记为已支付并通知用户
+func settleOrderPayment(order *Order, note string) error {
+	req := UpdateOrderStatusRequest{Status: OrderStatusPaid, Note: note, actor: systemOrderActor}
+	if err := runOrderStatusJob(order.ID, order.UserID, req); err != nil {
+		return err
+	}
+	SendNotification(order.UserID, NotificationTypeOrder, "支付成功",
+		fmt.Sprintf("您的订单 %s 已支付成功，金额 %.2f 元，我们将尽快为您发货。", order.OrderNo, order.TotalAmount))
+	return nil
+}
+
+// PaymentStatusResponse 订单支付状态
+type PaymentStatusResponse struct {
+	OrderStatus string    `json:"order_status"`
+	Paid        bool      `json:"paid"`              // 订单是否已完成支付
+	PaidAmount  float64   `json:"paid_amount"`       // 各笔支付成功的金额合计，组合支付时为钱包余额与支付渠道之和
+	Payment     *Payment  `json:"payment,omitempty"` // 最近一次发起的支付单
+	Payments    []Payment `json:"payments"`          // 订单的全部支付单，按发起顺序排列
+}
+
+// 客户端轮询时向支付渠道查询支付单的结果，同一支付单在 PAYMENT_QUERY_SECONDS 内只查询一次，
+// 查到支付结果时按回调相同的流程处理
+func syncPaymentWithGateway(payment *Payment) {
+	gateway, ok := PaymentGateways[payment.Channel]
+	if !ok || AppConfig.PaymentQuerySeconds <= 0 {
+		return
+	}
+	key := fmt.Sprintf("payment:query:%s", payment.PaymentNo)
+	if ok, _ := RDB.SetNX(CTX, key, 1, time.Duration(AppConfig.PaymentQuerySeconds)*time.Second).Result(); !ok {
+		return
+	}
+
+	notification, err := gateway.QueryPayment(payment)
+	if err != nil {
+		log.Printf("支付单 %s 查询失败: %v", payment.PaymentNo, err)
+		return
+	}
+	if notification == nil {
+		return
+	}
+	if err := completePayment(payment.Channel, notification); err != nil {
+		log.Printf("支付单 %s 查询结果处理失败: %v", payment.PaymentNo, err)
+	}
+}
+
+// GetPaymentStatus 查询订单支付状态
+// @Summary 查询订单支付状态
+// @Description 客户端从支付页面或支付应用返回后轮询订单是否已支付，同时返回订单的全部支付单和已支付金额（组合支付时包含钱包余额与支付渠道各一笔）。订单待支付且最近的支付单未完成时，会向支付渠道主动查询（同一支付单在 PAYMENT_QUERY_SECONDS 秒内只查询一次），不必等待支付回调
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=PaymentStatusResponse} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Security Bearer
+// @Router /api/orders/{id}/payment-status [get]
+func GetPaymentStatus(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	query := DB.Where("id = ?", orderID)
+	if !HasRole(c, RoleAdmin) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order Order
+	if err := query.First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	var payment Payment
+	found := DB.Where("order_id = ?", order.ID).Order("id DESC").Limit(1).Find(&payment).RowsAffected > 0
+	if found && order.Status == OrderStatusPending && payment.Status == PaymentStatusPending {
+		syncPaymentWithGateway(&payment)
+		DB.First(&order, order.ID)
+		DB.First(&payment, payment.ID)
+	}
+
+	result := PaymentStatusResponse{
+		OrderStatus: order.Status,
+		Paid:        order.Status != OrderStatusPending && order.Status != OrderStatusCancelled,
+	}
+	if order.PaymentMethod == PaymentMethodCOD {
+		// 货到付款订单在签收收款后才算已支付
+		result.Paid = order.Status == OrderStatusDelivered || order.Status == OrderStatusCompleted
+	}
+	if found {
+		result.Payment = &payment
+	}
+	if err := DB.Where("order_id = ?", order.ID).Order("id").Find(&result.Payments).Error; err != nil {
+		InternalServerError(c, "支付单查询失败")
+		return
+	}
+	var paidCents int64
+	for _, leg := range result.Payments {
+		if leg.Status == PaymentStatusSucceeded {
+			paidCents += toCents(leg.Amount)
+		}
+	}
+	result.PaidAmount = fromCents(paidCents)
+	SuccessResponse(c, result)
+}
+
+// PaymentNotify 支付渠道回调
+// @Summary 支付渠道回调
+// @Description 接收支付渠道的支付结果通知（Stripe 为 Webhook），按渠道规则校验签名后更新支付单和订单状态，重复通知不会重复处理。处理失败时返回错误，由支付渠道重试
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param channel path string true "支付渠道：mock、stripe"
+// @Success 200 {object} ApiResponse "处理成功"
+// @Failure 400 {object} ApiResponse "回调内容错误或处理失败"
+// @Failure 401 {object} ApiResponse "签名无效"
+// @Failure 404 {object} ApiResponse "支付渠道未启用"
+// @Router /api/webhooks/payments/{channel} [post]
+func PaymentNotify(c *gin.Context) {
+	channel := c.Param("channel")
+	gateway, ok := PaymentGateways[channel]
+	if !ok {
+		NotFoundError(c, "支付渠道未启用")
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		BadRequestError(c, "请求体读取失败")
+		return
+	}
+
+	notification, err := gateway.ParseNotification(c.Request.Header, body)
+	if errors.Is(err, errInvalidPaymentSignature) {
+		UnauthorizedError(c, err.Error())
+		return
+	}
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if notification == nil {
+		SuccessResponse(c, gin.H{"message": "已忽略"})
+		return
+	}
+
+	if err := completePayment(channel, notification); err != nil {
+		log.Printf("%s 支付回调处理失败: %v", channel, err)
+		BadRequestError(c, err.Error())
+		return
+	}
+	SuccessResponse(c, gin.H{"message": "处理成功"})
+}
@@ -0,0 +1,481 @@
+package gomall
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支付渠道
+const (
+	PaymentChannelMock   = "mock"   // 模拟支付，开发和测试环境使用
+	PaymentChannelStripe = "stripe" // Stripe
+)
+
+// 支付回调签名无效
+var errInvalidPaymentSignature = errors.New("支付回调签名无效")
+
+// 支付渠道没有对账单接口，只能上传对账文件
+var errStatementUnavailable = errors.New("支付渠道不支持拉取对账单，请上传对账文件")
+
+// PaymentIntent 发起支付后返回给客户端的参数，客户端据此跳转支付页面或拉起支付SDK
+type PaymentIntent struct {
+	TradeNo      string `json:"-"`                       // 支付渠道在创建时分配的交易号
+	PayURL       string `json:"pay_url,omitempty"`       // 支付页面地址
+	ClientSecret string `json:"client_secret,omitempty"` // 客户端SDK确认支付使用的凭证
+}
+
+// PaymentNotification 支付渠道回调中解析出的支付结果
+type PaymentNotification struct {
+	PaymentNo   string
+	TradeNo     string
+	AmountCents int64 // 实付金额（分）
+	Succeeded   bool
+	FailReason  string
+}
+
+// RefundResult 支付渠道返回的退款结果
+type RefundResult struct {
+	TradeNo    string // 支付渠道的退款单号
+	Status     string // RefundStatusSucceeded、RefundStatusProcessing、RefundStatusFailed
+	FailReason string
+}
+
+// StatementEntry 对账单中一笔成功收款的交易
+type StatementEntry struct {
+	PaymentNo   string // 商户支付单号，渠道对账单中没有时为空
+	TradeNo     string // 支付渠道的交易号
+	AmountCents int64  // 收款金额（分）
+	PaidAt      time.Time
+}
+
+// PaymentGateway 支付渠道接口
+type PaymentGateway interface {
+	// CreatePayment 在支付渠道创建交易，金额为 payment.Amount
+	CreatePayment(payment *Payment, subject string) (*PaymentIntent, error)
+	// ParseNotification 校验回调签名并解析支付结果，与支付结果无关的回调返回 nil
+	ParseNotification(header http.Header, body []byte) (*PaymentNotification, error)
+	// QueryPayment 向支付渠道查询交易结果，尚未支付完成时返回 nil
+	QueryPayment(payment *Payment) (*PaymentNotification, error)
+	// Refund 将 refund.Amount 原路退回到支付单，渠道异步处理时返回退款中
+	Refund(payment *Payment, refund *RefundPayment) (*RefundResult, error)
+	// QueryRefund 查询退款中的退款结果
+	QueryRefund(payment *Payment, refund *RefundPayment) (*RefundResult, error)
+	// FetchStatement 拉取 [start, end) 内成功收款的交易，不支持时返回 errStatementUnavailable
+	FetchStatement(start, end time.Time) ([]StatementEntry, error)
+}
+
+// 已启用的支付渠道
+var PaymentGateways = map[string]PaymentGateway{}
+
+// InitPaymentGateways 根据配置初始化支付渠道
+func InitPaymentGateways(config *Config) {
+	for _, channel := range splitEnvList(config.PaymentChannels) {
+		switch channel {
+		case PaymentChannelMock:
+			PaymentGateways[channel] = &MockPaymentGateway{Secret: config.PaymentMockSecret}
+		case PaymentChannelStripe:
+			if config.StripeSecretKey == "" {
+				log.Printf("未配置 STRIPE_SECRET_KEY，Stripe 支付未启用")
+				continue
+			}
+			PaymentGateways[channel] = &StripePaymentGateway{
+				SecretKey:     config.StripeSecretKey,
+				WebhookSecret: config.StripeWebhookSecret,
+				Currency:      config.StripeCurrency,
+			}
+		default:
+			log.Printf("未知的支付渠道: %s", channel)
+		}
+	}
+}
+
+// 支付接口统一使用的HTTP客户端
+var paymentHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// 回调时间戳与服务器时间的最大误差，防止重放
+const paymentSignatureMaxAge = 5 * time.Minute
+
+func paymentTimestampValid(timestamp string) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(unix, 0))
+	return age <= paymentSignatureMaxAge && age >= -paymentSignatureMaxAge
+}
+
+// HMAC-SHA256(secret, 时间戳 + "." + 请求体) 的十六进制
+func paymentSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 模拟支付回调的签名请求头，签名方式与WMS回调相同
+const (
+	mockPaymentTimestampHeader = "X-Signature-Timestamp"
+	mockPaymentSignatureHeader = "X-Signature"
+)
+
+// MockPaymentGateway 模拟支付渠道：发起支付时只记录日志，由测试脚本或开发者按签名规则调用回调完成支付
+type MockPaymentGateway struct {
+	Secret string
+}
+
+// CreatePayment 记录模拟支付请求
+func (g *MockPaymentGateway) CreatePayment(payment *Payment, subject string) (*PaymentIntent, error) {
+	log.Printf("[支付] 模拟支付 支付单号: %s 金额: %.2f 商品: %s", payment.PaymentNo, payment.Amount, subject)
+	return &PaymentIntent{TradeNo: "MOCK" + payment.PaymentNo}, nil
+}
+
+// ParseNotification 解析模拟支付回调，请求体为 {"payment_no","trade_no","amount_cents","status","fail_reason"}
+func (g *MockPaymentGateway) ParseNotification(header http.Header, body []byte) (*PaymentNotification, error) {
+	timestamp := header.Get(mockPaymentTimestampHeader)
+	if g.Secret == "" || !paymentTimestampValid(timestamp) {
+		return nil, errInvalidPaymentSignature
+	}
+	expected := paymentSignature(g.Secret, timestamp, body)
+	if subtle.ConstantTimeCompare([]byte(header.Get(mockPaymentSignatureHeader)), []byte(expected)) != 1 {
+		return nil, errInvalidPaymentSignature
+	}
+
+	var event struct {
+		PaymentNo   string `json:"payment_no"`
+		TradeNo     string `json:"trade_no"`
+		AmountCents int64  `json:"amount_cents"`
+		Status      string `json:"status"` // succeeded、failed
+		FailReason  string `json:"fail_reason"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("回调内容解析失败: %v", err)
+	}
+	return &PaymentNotification{
+		PaymentNo:   event.PaymentNo,
+		TradeNo:     event.TradeNo,
+		AmountCents: event.AmountCents,
+		Succeeded:   event.Status == PaymentStatusSucceeded,
+		FailReason:  event.FailReason,
+	}, nil
+}
+
+// QueryPayment 模拟支付没有远端交易，支付结果只能通过回调获得
+func (g *MockPaymentGateway) QueryPayment(payment *Payment) (*PaymentNotification, error) {
+	return nil, nil
+}
+
+// Refund 模拟退款，直接成功
+func (g *MockPaymentGateway) Refund(payment *Payment, refund *RefundPayment) (*RefundResult, error) {
+	log.Printf("[支付] 模拟退款 支付单号: %s 退款单号: %s 金额: %.2f", payment.PaymentNo, refund.RefundNo, refund.Amount)
+	return &RefundResult{TradeNo: "MOCK" + refund.RefundNo, Status: RefundStatusSucceeded}, nil
+}
+
+// QueryRefund 模拟退款不会处于退款中
+func (g *MockPaymentGateway) QueryRefund(payment *Payment, refund *RefundPayment) (*RefundResult, error) {
+	return &RefundResult{TradeNo: refund.TradeNo, Status: RefundStatusSucceeded}, nil
+}
+
+// FetchStatement 模拟支付没有远端对账单
+func (g *MockPaymentGateway) FetchStatement(start, end time.Time) ([]StatementEntry, error) {
+	return nil, errStatementUnavailable
+}
+
+// StripePaymentGateway Stripe支付渠道，使用 PaymentIntents API，客户端用 client_secret 确认支付
+type StripePaymentGateway struct {
+	SecretKey     string
+	WebhookSecret string
+	Currency      string
+}
+
+// CreatePayment 创建 Stripe PaymentIntent，支付单号记录在 metadata 中
+func (g *StripePaymentGateway) CreatePayment(payment *Payment, subject string) (*PaymentIntent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(toCents(payment.Amount), 10))
+	form.Set("currency", g.Currency)
+	form.Set("description", subject)
+	form.Set("metadata[payment_no]", payment.PaymentNo)
+	form.Set("metadata[order_id]", strconv.FormatUint(uint64(payment.OrderID), 10))
+	form.Set("automatic_payment_methods[enabled]", "true")
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.stripe.com/v1/payment_intents", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(g.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 同一支付单重试时不会重复创建交易
+	req.Header.Set("Idempotency-Key", payment.PaymentNo)
+
+	resp, err := paymentHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Stripe 支付创建失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID           string `json:"id"`
+		ClientSecret string `json:"client_secret"`
+		Error        struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Stripe 支付创建失败: %v", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("Stripe 支付创建失败: %s", result.Error.Message)
+	}
+	return &PaymentIntent{TradeNo: result.ID, ClientSecret: result.ClientSecret}, nil
+}
+
+// ParseNotification 校验 Stripe-Signature 并解析 payment_intent.succeeded、payment_intent.payment_failed 事件
+func (g *StripePaymentGateway) ParseNotification(header http.Header, body []byte) (*PaymentNotification, error) {
+	// 签名头格式：t=时间戳,v1=签名[,v1=签名]
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if g.WebhookSecret == "" || !paymentTimestampValid(timestamp) {
+		return nil, errInvalidPaymentSignature
+	}
+	expected := paymentSignature(g.WebhookSecret, timestamp, body)
+	valid := false
+	for _, signature := range signatures {
+		if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1 {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errInvalidPaymentSignature
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID               string            `json:"id"`
+				AmountReceived   int64             `json:"amount_received"`
+				Metadata         map[string]string `json:"metadata"`
+				LastPaymentError *struct {
+					Message string `json:"message"`
+				} `json:"last_payment_error"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("回调内容解析失败: %v", err)
+	}
+	intent := event.Data.Object
+	notification := &PaymentNotification{
+		PaymentNo:   intent.Metadata["payment_no"],
+		TradeNo:     intent.ID,
+		AmountCents: intent.AmountReceived,
+	}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		notification.Succeeded = true
+	case "payment_intent.payment_failed":
+		if intent.LastPaymentError != nil {
+			notification.FailReason = intent.LastPaymentError.Message
+		}
+	default:
+		return nil, nil
+	}
+	return notification, nil
+}
+
+// QueryPayment 查询 PaymentIntent 的状态：succeeded 为支付成功，上一次支付失败且等待重新支付时为支付失败
+func (g *StripePaymentGateway) QueryPayment(payment *Payment) (*PaymentNotification, error) {
+	if payment.TradeNo == "" {
+		return nil, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://api.stripe.com/v1/payment_intents/"+url.PathEscape(payment.TradeNo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(g.SecretKey, "")
+
+	resp, err := paymentHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Stripe 支付查询失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID               string `json:"id"`
+		Status           string `json:"status"`
+		AmountReceived   int64  `json:"amount_received"`
+		LastPaymentError *struct {
+			Message string `json:"message"`
+		} `json:"last_payment_error"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Stripe 支付查询失败: %v", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("Stripe 支付查询失败: %s", result.Error.Message)
+	}
+
+	notification := &PaymentNotification{PaymentNo: payment.PaymentNo, TradeNo: result.ID, AmountCents: result.AmountReceived}
+	switch {
+	case result.Status == "succeeded":
+		notification.Succeeded = true
+	case result.Status == "requires_payment_method" && result.LastPaymentError != nil:
+		notification.FailReason = result.LastPaymentError.Message
+	default:
+		return nil, nil
+	}
+	return notification, nil
+}
+
+// Stripe 退款对象的状态转换为退款结果
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+	Error         struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (r *stripeRefund) result() *RefundResult {
+	result := &RefundResult{TradeNo: r.ID, Status: RefundStatusProcessing}
+	switch r.Status {
+	case "succeeded":
+		result.Status = RefundStatusSucceeded
+	case "failed", "canceled":
+		result.Status = RefundStatusFailed
+		result.FailReason = r.FailureReason
+	}
+	return result
+}
+
+func (g *StripePaymentGateway) doRefundRequest(req *http.Request) (*RefundResult, error) {
+	req.SetBasicAuth(g.SecretKey, "")
+	resp, err := paymentHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Stripe 退款请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var refund stripeRefund
+	if err := json.NewDecoder(resp.Body).Decode(&refund); err != nil {
+		return nil, fmt.Errorf("Stripe 退款请求失败: %v", err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("Stripe 退款请求失败: %s", refund.Error.Message)
+	}
+	return refund.result(), nil
+}
+
+// Refund 对支付单的 PaymentIntent 发起部分或全额退款
+func (g *StripePaymentGateway) Refund(payment *Payment, refund *RefundPayment) (*RefundResult, error) {
+	form := url.Values{}
+	form.Set("payment_intent", payment.TradeNo)
+	form.Set("amount", strconv.FormatInt(toCents(refund.Amount), 10))
+	form.Set("metadata[refund_no]", refund.RefundNo)
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.stripe.com/v1/refunds", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 同一次执行的请求重发时不会重复退款，退款失败后重新审批使用新的幂等键
+	req.Header.Set("Idempotency-Key", refund.RefundNo+"-"+strconv.Itoa(refund.Attempts))
+	return g.doRefundRequest(req)
+}
+
+// QueryRefund 查询 Stripe 退款状态
+func (g *StripePaymentGateway) QueryRefund(payment *Payment, refund *RefundPayment) (*RefundResult, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.stripe.com/v1/refunds/"+url.PathEscape(refund.TradeNo), nil)
+	if err != nil {
+		return nil, err
+	}
+	return g.doRefundRequest(req)
+}
+
+// FetchStatement 分页拉取时间范围内成功的 Charge，交易号为 Charge 所属的 PaymentIntent
+func (g *StripePaymentGateway) FetchStatement(start, end time.Time) ([]StatementEntry, error) {
+	var entries []StatementEntry
+	startingAfter := ""
+	for {
+		query := url.Values{}
+		query.Set("created[gte]", strconv.FormatInt(start.Unix(), 10))
+		query.Set("created[lt]", strconv.FormatInt(end.Unix(), 10))
+		query.Set("limit", "100")
+		if startingAfter != "" {
+			query.Set("starting_after", startingAfter)
+		}
+		req, err := http.NewRequest(http.MethodGet, "https://api.stripe.com/v1/charges?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(g.SecretKey, "")
+
+		resp, err := paymentHTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("Stripe 对账单拉取失败: %v", err)
+		}
+		var page struct {
+			Data []struct {
+				ID            string            `json:"id"`
+				Amount        int64             `json:"amount"`
+				Status        string            `json:"status"`
+				PaymentIntent string            `json:"payment_intent"`
+				Created       int64             `json:"created"`
+				Metadata      map[string]string `json:"metadata"`
+			} `json:"data"`
+			HasMore bool `json:"has_more"`
+			Error   struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Stripe 对账单拉取失败: %v", err)
+		}
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			return nil, fmt.Errorf("Stripe 对账单拉取失败: %s", page.Error.Message)
+		}
+
+		for _, charge := range page.Data {
+			startingAfter = charge.ID
+			if charge.Status != "succeeded" {
+				continue
+			}
+			entries = append(entries, StatementEntry{
+				PaymentNo:   charge.Metadata["payment_no"],
+				TradeNo:     charge.PaymentIntent,
+				AmountCents: charge.Amount,
+				PaidAt:      time.Unix(charge.Created, 0),
+			})
+		}
+		if !page.HasMore || len(page.Data) == 0 {
+			return entries, nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package gomall
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 简易PDF生成：A4纵向页面，只支持文字和直线，满足发票等单据输出。
+// 中文使用阅读器内置的宋体（STSong-Light，UniGB-UCS2-H 编码），不嵌入字体文件
+
+const (
+	pdfPageWidth  = 595.0 // A4 宽度（pt）
+	pdfPageHeight = 842.0 // A4 高度（pt）
+)
+
+// 固定的字体对象：Type0 组合字体、CID 字体和字体描述，编号 3~5
+var pdfFontObjects = []string{
+	"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>",
+	"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> " +
+		"/FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>",
+	"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+}
+
+// pdfDocument 按页累积绘制指令，坐标原点在页面左上角，单位为pt
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.AddPage()
+	return doc
+}
+
+// AddPage 新增一页，之后的绘制都在新页面上
+func (d *pdfDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text 在 (x, y) 处写一行文字，y 为文字基线
+func (d *pdfDocument) Text(x, y, size float64, text string) {
+	fmt.Fprintf(d.page(), "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, pdfPageHeight-y, pdfEncodeText(text))
+}
+
+// TextRight 右对齐写一行文字，right 为文字右边缘
+func (d *pdfDocument) TextRight(right, y, size float64, text string) {
+	d.Text(right-pdfTextWidth(text, size), y, size, text)
+}
+
+// Line 画一条直线
+func (d *pdfDocument) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// 文字宽度估算：ASCII 字符半角，其余全角
+func pdfTextWidth(text string, size float64) float64 {
+	var width float64
+	for _, r := range text {
+		if r < 0x80 {
+			width += size / 2
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// 截断超出宽度的文字，末尾加省略号
+func pdfTruncate(text string, size, maxWidth float64) string {
+	if pdfTextWidth(text, size) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"…", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// 按 UCS-2 大端编码为十六进制字符串，基本多文种平面之外的字符替换为问号
+func pdfEncodeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF || r < 0x20 {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// WriteTo 输出完整的PDF文件
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	// 对象编号：1 目录，2 页面树，3~5 字体，之后每页依次为页面对象和内容流
+	firstPage := 3 + len(pdfFontObjects)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, font := range pdfFontObjects {
+		writeObject(font)
+	}
+	for i, content := range d.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, firstPage+i*2+1))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
@@ -0,0 +1,172 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 浏览量先累加在Redis中，定期批量写回MySQL，避免每次浏览都写数据库
+const productViewsPendingKey = "products:views:pending"
+
+// 按天统计的浏览量，用于计算时间窗口内的热度
+const (
+	productViewsDailyPrefix = "products:views:daily:"
+	popularityMaxWindowDays = 30
+)
+
+// 热门商品排序方式
+const (
+	HotSortSales      = "sales"      // 累计销量
+	HotSortViews      = "views"      // 时间窗口内浏览量
+	HotSortPopularity = "popularity" // 时间窗口内浏览量和销量加权
+)
+
+func productViewsDailyKey(day time.Time) string {
+	return productViewsDailyPrefix + day.Format("20060102")
+}
+
+// RecordProductView 记录一次商品浏览
+func RecordProductView(productID uint) {
+	field := strconv.FormatUint(uint64(productID), 10)
+	dailyKey := productViewsDailyKey(time.Now())
+
+	pipe := RDB.TxPipeline()
+	pipe.HIncrBy(CTX, productViewsPendingKey, field, 1)
+	pipe.HIncrBy(CTX, dailyKey, field, 1)
+	pipe.Expire(CTX, dailyKey, (popularityMaxWindowDays+1)*24*time.Hour)
+	pipe.Exec(CTX)
+}
+
+// 将Redis中累计的浏览量写回数据库，先改名再读取，多实例同时执行也不会重复计数
+func flushProductViews() {
+	flushingKey := fmt.Sprintf("%s:flushing:%d", productViewsPendingKey, time.Now().UnixNano())
+	if err := RDB.Rename(CTX, productViewsPendingKey, flushingKey).Err(); err != nil {
+		return // 没有待写回的浏览量
+	}
+
+	counts, err := RDB.HGetAll(CTX, flushingKey).Result()
+	if err != nil {
+		log.Printf("读取商品浏览量失败: %v", err)
+		return
+	}
+	for field, value := range counts {
+		productID, err1 := strconv.ParseUint(field, 10, 32)
+		views, err2 := strconv.ParseInt(value, 10, 64)
+		if err1 != nil || err2 != nil || views <= 0 {
+			continue
+		}
+		err := DB.Model(&Product{}).Where("id = ?", productID).
+			UpdateColumn("view_count", gorm.Expr("view_count + ?", views)).Error
+		if err != nil {
+			// 写入失败的计数放回待写回队列，下次重试
+			RDB.HIncrBy(CTX, productViewsPendingKey, field, views)
+			log.Printf("商品 %d 浏览量写回失败: %v", productID, err)
+		}
+	}
+	RDB.Del(CTX, flushingKey)
+}
+
+// StartViewCountFlusher 启动浏览量定期写回任务
+func StartViewCountFlusher() {
+	interval := time.Duration(AppConfig.ViewCountFlushSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			flushProductViews()
+		}
+	}()
+	log.Println("商品浏览量写回任务已启动")
+}
+
+// 统计时间窗口内每个商品的浏览量
+func productViewsInWindow(days int) map[uint]float64 {
+	views := make(map[uint]float64)
+	now := time.Now()
+	for i := 0; i < days; i++ {
+		counts, err := RDB.HGetAll(CTX, productViewsDailyKey(now.AddDate(0, 0, -i))).Result()
+		if err != nil {
+			continue
+		}
+		for field, value := range counts {
+			productID, err1 := strconv.ParseUint(field, 10, 32)
+			count, err2 := strconv.ParseFloat(value, 64)
+			if err1 == nil && err2 == nil {
+				views[uint(productID)] += count
+			}
+		}
+	}
+	return views
+}
+
+// 统计时间窗口内每个商品的有效订单销量
+func productSalesInWindow(days int) map[uint]float64 {
+	var rows []struct {
+		ProductID uint
+		Quantity  float64
+	}
+	DB.Table("order_items").
+		Select("order_items.product_id, SUM(order_items.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.status IN ? AND orders.created_at >= ?", reportOrderStatuses, time.Now().AddDate(0, 0, -days)).
+		Group("order_items.product_id").
+		Scan(&rows)
+
+	sales := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		sales[row.ProductID] = row.Quantity
+	}
+	return sales
+}
+
+// 按时间窗口内的浏览量或加权热度排序热门商品
+func rankHotProducts(sortBy string, days int, categoryID uint64, limit int) ([]Product, error) {
+	scores := productViewsInWindow(days)
+	if sortBy == HotSortPopularity {
+		for productID, views := range scores {
+			scores[productID] = views * AppConfig.PopularityViewWeight
+		}
+		for productID, quantity := range productSalesInWindow(days) {
+			scores[productID] += quantity * AppConfig.PopularitySalesWeight
+		}
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for productID, score := range scores {
+		if score > 0 {
+			ids = append(ids, productID)
+		}
+	}
+	if len(ids) == 0 {
+		return []Product{}, nil
+	}
+
+	query := DB.Preload("Category").Preload("Brand").Where("id IN ? AND status = ?", ids, 1)
+	if categoryID > 0 {
+		query = query.Where("category_id = ?", categoryID)
+	}
+	var products []Product
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		if scores[products[i].ID] != scores[products[j].ID] {
+			return scores[products[i].ID] > scores[products[j].ID]
+		}
+		return products[i].SalesCount > products[j].SalesCount
+	})
+	if len(products) > limit {
+		products = products[:limit]
+	}
+	return products, nil
+}
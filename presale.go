@@ -0,0 +1,73 @@
+package gomall
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 订单商品履约状态，与订单状态分开记录，预售商品到货前订单已付款但商品尚不能发货
+const (
+	FulfillmentStatusReady   = "ready"   // 现货，付款后即可发货
+	FulfillmentStatusPresale = "presale" // 预售，等待到货后按预计发货日期发货
+	FulfillmentStatusShipped = "shipped" // 已发货
+)
+
+// 可下单的数量：预售商品在库存之外还可以预售 PresaleQuota 件，库存可以扣减为负数表示缺货待发
+func (p *Product) orderableStock() int {
+	if p.Presale {
+		return p.Stock + p.PresaleQuota
+	}
+	return p.Stock
+}
+
+// 预售商品超出库存可下单的数量，非预售商品为0
+func presaleAllowances(db *gorm.DB, productIDs []uint) (map[uint]int, error) {
+	var products []Product
+	err := db.Select("id, presale, presale_quota").
+		Where("id IN ? AND presale = ?", productIDs, true).
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	allowances := make(map[uint]int, len(products))
+	for _, product := range products {
+		allowances[product.ID] = product.PresaleQuota
+	}
+	return allowances, nil
+}
+
+// 校验预售设置：预售商品必须填写预计发货日期，且不能早于今天
+func validatePresale(presale bool, quota int, shipDate *time.Time) error {
+	if !presale {
+		return nil
+	}
+	if quota < 0 {
+		return fmt.Errorf("预售数量不能为负数")
+	}
+	if shipDate == nil {
+		return fmt.Errorf("预售商品必须填写预计发货日期")
+	}
+	now := time.Now()
+	if shipDate.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		return fmt.Errorf("预计发货日期不能早于今天")
+	}
+	return nil
+}
+
+// 下单时根据商品是否预售设置订单商品的履约状态和预计发货日期
+func applyItemFulfillment(item *OrderItem, product *Product) {
+	item.FulfillmentStatus = FulfillmentStatusReady
+	if product.Presale {
+		item.FulfillmentStatus = FulfillmentStatusPresale
+		item.ExpectedShipDate = product.PresaleShipDate
+	}
+}
+
+// 订单发货时标记全部订单商品已发货
+func markOrderItemsShipped(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&OrderItem{}).
+		Where("order_id = ? AND fulfillment_status <> ?", orderID, FulfillmentStatusShipped).
+		Update("fulfillment_status", FulfillmentStatusShipped).Error
+}
@@ -0,0 +1,107 @@
+package gomall
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 价格变更来源
+const (
+	PriceChangeSourceManual = "manual" // 后台编辑商品
+	PriceChangeSourceImport = "import" // 批量导入
+)
+
+// 记录商品售价变更，价格未变化时不记录
+func recordPriceChange(tx *gorm.DB, product *Product, newPrice float64, changedBy uint, source string) error {
+	if toCents(product.Price) == toCents(newPrice) {
+		return nil
+	}
+	history := ProductPriceHistory{
+		ProductID: product.ID,
+		OldPrice:  product.Price,
+		NewPrice:  newPrice,
+		Source:    source,
+	}
+	if changedBy != 0 {
+		history.ChangedBy = &changedBy
+	}
+	return tx.Create(&history).Error
+}
+
+// GetProductPriceHistory 获取商品价格历史
+// @Summary 获取商品价格历史
+// @Description 按时间倒序查看商品售价的每次变更，包括原价、新价、操作人和来源（manual 后台编辑、import 批量导入），可按时间范围筛选，用于促销合规核查
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param start_date query string false "开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "结束日期（YYYY-MM-DD，含当天）"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]ProductPriceHistory}} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID或日期格式错误"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/products/{id}/price-history [get]
+func GetProductPriceHistory(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id").First(&product, productID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&ProductPriceHistory{}).Where("product_id = ?", product.ID)
+	if value := c.Query("start_date"); value != "" {
+		start, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "开始日期格式错误")
+			return
+		}
+		query = query.Where("created_at >= ?", start)
+	}
+	if value := c.Query("end_date"); value != "" {
+		end, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "结束日期格式错误")
+			return
+		}
+		query = query.Where("created_at < ?", end.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var history []ProductPriceHistory
+	if err := query.Order("created_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&history).Error; err != nil {
+		InternalServerError(c, "价格历史查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, history, total, page, pageSize)
+}
@@ -0,0 +1,160 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 计价调整类型
+const (
+	PricingAdjustmentPromotion = "promotion" // 促销活动
+	PricingAdjustmentCoupon    = "coupon"    // 优惠券
+	PricingAdjustmentPoints    = "points"    // 积分抵扣
+	PricingAdjustmentShipping  = "shipping"  // 运费
+	PricingAdjustmentTax       = "tax"       // 税费
+	PricingAdjustmentQuote     = "quote"     // 大宗询价协议价
+	PricingAdjustmentOffline   = "offline"   // 线下订单成交价
+)
+
+// 订单计价币种
+const pricingCurrency = "CNY"
+
+// PricingLine 计价明细中的商品行，金额单位为分
+type PricingLine struct {
+	ProductID      uint   `json:"product_id"`
+	ProductName    string `json:"product_name"`
+	UnitPriceCents int64  `json:"unit_price_cents"`
+	Quantity       int    `json:"quantity"`
+	SubtotalCents  int64  `json:"subtotal_cents"`
+}
+
+// PricingAdjustment 计价调整项，优惠为负数，加收为正数
+type PricingAdjustment struct {
+	Type        string `json:"type"`
+	RuleID      string `json:"rule_id"` // 触发调整的规则标识，如 coupon:12、quote:3
+	Description string `json:"description"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+// PricingTrace 订单计价过程记录，用于核对"为什么收了这么多钱"
+type PricingTrace struct {
+	Currency    string              `json:"currency"`
+	Lines       []PricingLine       `json:"lines"`
+	BaseCents   int64               `json:"base_cents"` // 商品原价合计
+	Adjustments []PricingAdjustment `json:"adjustments"`
+	TotalCents  int64               `json:"total_cents"`
+	ComputedAt  time.Time           `json:"computed_at"`
+}
+
+// 元转分，按四舍五入处理浮点误差
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// 分转元
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// NewPricingTrace 创建空的计价记录
+func NewPricingTrace() *PricingTrace {
+	return &PricingTrace{
+		Currency:    pricingCurrency,
+		Lines:       []PricingLine{},
+		Adjustments: []PricingAdjustment{},
+		ComputedAt:  time.Now(),
+	}
+}
+
+// AddLine 记录商品行
+func (t *PricingTrace) AddLine(product *Product, unitPrice float64, quantity int) {
+	unitCents := toCents(unitPrice)
+	line := PricingLine{
+		ProductID:      product.ID,
+		ProductName:    product.Name,
+		UnitPriceCents: unitCents,
+		Quantity:       quantity,
+		SubtotalCents:  unitCents * int64(quantity),
+	}
+	t.Lines = append(t.Lines, line)
+	t.BaseCents += line.SubtotalCents
+	t.TotalCents += line.SubtotalCents
+}
+
+// AddAdjustment 记录一项计价调整
+func (t *PricingTrace) AddAdjustment(adjustmentType, ruleID, description string, amountCents int64) {
+	t.Adjustments = append(t.Adjustments, PricingAdjustment{
+		Type:        adjustmentType,
+		RuleID:      ruleID,
+		Description: description,
+		AmountCents: amountCents,
+	})
+	t.TotalCents += amountCents
+}
+
+// Total 应付金额（元）
+func (t *PricingTrace) Total() float64 {
+	return fromCents(t.TotalCents)
+}
+
+// JSON 序列化为订单中保存的格式
+func (t *PricingTrace) JSON() string {
+	data, _ := json.Marshal(t)
+	return string(data)
+}
+
+// AdminOrderDetail 管理端订单详情
+type AdminOrderDetail struct {
+	Order
+	Pricing       *PricingTrace        `json:"pricing_trace"`
+	StatusHistory []OrderStatusHistory `json:"status_history"` // 状态变更历史
+}
+
+// GetAdminOrder 获取订单详情（管理端）
+// @Summary 获取订单详情（管理端）
+// @Description 获取任意订单的详情，包括下单用户、计价明细（商品原价及每一项优惠、运费、税费调整及其规则ID）、内部备注和状态变更历史，用于客服处理金额争议。查看记入管理员操作日志
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=AdminOrderDetail} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Security Bearer
+// @Router /api/admin/orders/{id} [get]
+func GetAdminOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return
+	}
+
+	var order Order
+	if err := DB.Preload("User").Preload("OrderItems.Product").Preload("DeliveryProofs").Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&order, orderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	detail := AdminOrderDetail{Order: order}
+	if order.PricingTrace != "" {
+		var trace PricingTrace
+		if err := json.Unmarshal([]byte(order.PricingTrace), &trace); err != nil {
+			InternalServerError(c, fmt.Sprintf("订单 %s 计价明细解析失败", order.OrderNo))
+			return
+		}
+		detail.Pricing = &trace
+	}
+	if err := DB.Where("order_id = ?", order.ID).Order("id").Find(&detail.StatusHistory).Error; err != nil {
+		InternalServerError(c, "订单状态历史查询失败")
+		return
+	}
+
+	recordAdminAudit(c, "order.view", "order", order.ID, "")
+	SuccessResponse(c, detail)
+}
@@ -1,7 +1,8 @@
-package main
+package gomall
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"os"
@@ -11,36 +12,65 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 商品请求和响应结构体
 type CreateProductRequest struct {
-	Name        string  `json:"name" binding:"required,min=1,max=200"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Stock       int     `json:"stock" binding:"min=0"`
-	CategoryID  uint    `json:"category_id" binding:"required"`
-	Images      []string `json:"images"`
+	Name            string     `json:"name" binding:"required,min=1,max=200"`
+	Description     string     `json:"description"`
+	Price           float64    `json:"price" binding:"required,gt=0"`
+	CostPrice       float64    `json:"cost_price" binding:"min=0"` // 成本价（可选）
+	Stock           int        `json:"stock" binding:"min=0"`
+	CategoryID      uint       `json:"category_id" binding:"required"`
+	BrandID         uint       `json:"brand_id"` // 品牌ID（可选）
+	Images          []string   `json:"images"`
+	Tags            []string   `json:"tags"`
+	ShipRegions     []string   `json:"ship_regions"`                                            // 限定配送地区，为空表示全部地区
+	NoAirTransport  bool       `json:"no_air_transport"`                                        // 禁止空运
+	OversizeFee     float64    `json:"oversize_fee" binding:"min=0"`                            // 超大件附加运费（每件）
+	ProductType     string     `json:"product_type" binding:"omitempty,oneof=physical digital"` // 商品类型，默认实物
+	DownloadURL     string     `json:"download_url" binding:"omitempty,url"`                    // 虚拟商品的下载地址，为空时付款后发放激活码，须为 DIGITAL_DOWNLOAD_HOSTS 白名单域名下的https链接
+	Barcode         string     `json:"barcode"`                                                 // 条形码（可选）
+	Presale         bool       `json:"presale"`                                                 // 预售商品，库存不足时仍可下单
+	PresaleQuota    int        `json:"presale_quota" binding:"min=0"`                           // 超出库存后最多还能预售的数量
+	PresaleShipDate *time.Time `json:"presale_ship_date"`                                       // 预计发货日期，预售商品必填
+	MaxPerUser      int        `json:"max_per_user" binding:"min=0"`                            // 每人限购数量，0表示不限购
+	Draft           bool       `json:"draft"`                                                   // 保存为草稿，暂不上架（商家创建的商品总是草稿）
 }
 
 type UpdateProductRequest struct {
-	Name        string  `json:"name,omitempty"`
-	Description string  `json:"description,omitempty"`
-	Price       float64 `json:"price,omitempty"`
-	Stock       int     `json:"stock,omitempty"`
-	CategoryID  uint    `json:"category_id,omitempty"`
-	Images      []string `json:"images,omitempty"`
+	Name            string     `json:"name,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	Price           float64    `json:"price,omitempty"`
+	CostPrice       float64    `json:"cost_price,omitempty"`
+	Stock           int        `json:"stock,omitempty"`
+	CategoryID      uint       `json:"category_id,omitempty"`
+	BrandID         uint       `json:"brand_id,omitempty"`
+	Images          []string   `json:"images,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	ShipRegions     []string   `json:"ship_regions,omitempty"`
+	NoAirTransport  *bool      `json:"no_air_transport,omitempty"`
+	OversizeFee     *float64   `json:"oversize_fee,omitempty" binding:"omitempty,min=0"`
+	ProductType     string     `json:"product_type,omitempty" binding:"omitempty,oneof=physical digital"`
+	DownloadURL     *string    `json:"download_url,omitempty"` // 传空字符串清除下载地址，须为 DIGITAL_DOWNLOAD_HOSTS 白名单域名下的https链接
+	Barcode         *string    `json:"barcode,omitempty"`      // 传空字符串清除条形码
+	Presale         *bool      `json:"presale,omitempty"`
+	PresaleQuota    *int       `json:"presale_quota,omitempty" binding:"omitempty,min=0"`
+	PresaleShipDate *time.Time `json:"presale_ship_date,omitempty"`
+	MaxPerUser      *int       `json:"max_per_user,omitempty" binding:"omitempty,min=0"` // 传0取消限购
 }
 
 type ProductQueryRequest struct {
-	Page       int    `form:"page,default=1"`
-	PageSize   int    `form:"page_size,default=10"`
-	CategoryID uint   `form:"category_id"`
-	Keyword    string `form:"keyword"`
+	Page       int     `form:"page,default=1"`
+	PageSize   int     `form:"page_size,default=10"`
+	CategoryID uint    `form:"category_id"`
+	BrandID    uint    `form:"brand_id"`
+	Keyword    string  `form:"keyword"`
 	MinPrice   float64 `form:"min_price"`
 	MaxPrice   float64 `form:"max_price"`
-	SortBy     string `form:"sort_by,default=created_at"` // created_at, price, sales_count
-	SortOrder  string `form:"sort_order,default=desc"`   // asc, desc
+	SortBy     string  `form:"sort_by,default=created_at"` // created_at, price, sales_count
+	SortOrder  string  `form:"sort_order,default=desc"`    // asc, desc
 }
 
 type CreateCategoryRequest struct {
@@ -64,7 +94,8 @@ func CacheProduct(productID uint, product *Product) error {
 	if err != nil {
 		return err
 	}
-	return RDB.Set(CTX, key, data, time.Hour*2).Err() // 2小时过期
+	RDB.Del(CTX, missingProductCacheKey(productID))
+	return RDB.Set(CTX, key, data, AppConfig.ProductCache.Expiration()).Err()
 }
 
 func GetCachedProduct(productID uint) (*Product, error) {
@@ -80,7 +111,23 @@ func GetCachedProduct(productID uint) (*Product, error) {
 
 func DeleteCachedProduct(productID uint) error {
 	key := fmt.Sprintf("product:%d", productID)
-	return RDB.Del(CTX, key).Err()
+	return RDB.Del(CTX, key, missingProductCacheKey(productID)).Err()
+}
+
+// 不存在的商品ID单独缓存，防止用随机ID穿透缓存直接查询数据库
+func missingProductCacheKey(productID uint) string {
+	return fmt.Sprintf("product:missing:%d", productID)
+}
+
+func CacheMissingProduct(productID uint) {
+	if ttl := AppConfig.ProductCache.NegativeExpiration(); ttl > 0 {
+		RDB.Set(CTX, missingProductCacheKey(productID), 1, ttl)
+	}
+}
+
+func IsCachedMissingProduct(productID uint) bool {
+	exists, err := RDB.Exists(CTX, missingProductCacheKey(productID)).Result()
+	return err == nil && exists > 0
 }
 
 // 商品列表缓存
@@ -93,7 +140,7 @@ func CacheProductList(cacheKey string, products []Product, total int64) error {
 	if err != nil {
 		return err
 	}
-	return RDB.Set(CTX, cacheKey, jsonData, time.Minute*10).Err() // 10分钟过期
+	return RDB.Set(CTX, cacheKey, jsonData, AppConfig.ProductListCache.Expiration()).Err()
 }
 
 func GetCachedProductList(cacheKey string) ([]Product, int64, error) {
@@ -123,7 +170,7 @@ func CacheCategories(categories []Category) error {
 	if err != nil {
 		return err
 	}
-	return RDB.Set(CTX, key, data, time.Hour*6).Err() // 6小时过期
+	return RDB.Set(CTX, key, data, AppConfig.CategoryCache.Expiration()).Err()
 }
 
 func GetCachedCategories() ([]Category, error) {
@@ -144,14 +191,14 @@ func DeleteCachedCategories() error {
 
 // CreateProduct 创建商品
 // @Summary 创建新商品
-// @Description 创建新的商品信息，包括名称、描述、价格、库存等
+// @Description 创建新的商品信息，包括名称、描述、价格、库存等；管理员创建的商品默认直接发布，商家创建的商品为草稿，需提交审核
 // @Tags 商品管理
 // @Accept json
 // @Produce json
 // @Param product body CreateProductRequest true "商品信息"
-// @Success 200 {object} ApiResponse{data=Product} "创建成功"
+// @Success 200 {object} ApiResponse{data=ProductResponse} "创建成功"
 // @Failure 400 {object} ApiResponse "参数验证失败"
-// @Failure 404 {object} ApiResponse "商品分类不存在"
+// @Failure 404 {object} ApiResponse "商品分类或品牌不存在"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
 // @Router /api/products [post]
@@ -169,6 +216,36 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// 检查品牌是否存在
+	if req.BrandID > 0 {
+		var brand Brand
+		if err := DB.Where("status = ?", 1).First(&brand, req.BrandID).Error; err != nil {
+			NotFoundError(c, "商品品牌不存在")
+			return
+		}
+	}
+
+	tags, err := normalizeProductTags(req.Tags)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	barcode, err := parseProductBarcode(req.Barcode, 0)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if req.DownloadURL != "" {
+		if err := validateDownloadURL(req.DownloadURL); err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+	}
+	if err := validatePresale(req.Presale, req.PresaleQuota, req.PresaleShipDate); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
 	// 处理图片数组转JSON字符串
 	imagesJSON := ""
 	if len(req.Images) > 0 {
@@ -176,25 +253,65 @@ func CreateProduct(c *gin.Context) {
 		imagesJSON = string(imagesData)
 	}
 
+	// 商家创建的商品先保存为草稿，审核通过后才上架
+	publishStatus := ProductStatePublished
+	var sellerID *uint
+	if !HasRole(c, RoleAdmin) {
+		userID := c.GetUint("user_id")
+		sellerID = &userID
+		publishStatus = ProductStateDraft
+	} else if req.Draft {
+		publishStatus = ProductStateDraft
+	}
+
 	// 创建商品
 	product := Product{
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Stock:       req.Stock,
-		CategoryID:  req.CategoryID,
-		Images:      imagesJSON,
-		Status:      1,
-		SalesCount:  0,
+		Name:            req.Name,
+		Description:     req.Description,
+		Price:           req.Price,
+		CostPrice:       req.CostPrice,
+		Stock:           req.Stock,
+		CategoryID:      req.CategoryID,
+		Images:          imagesJSON,
+		Tags:            tags,
+		ShipRegions:     strings.Join(req.ShipRegions, ","),
+		NoAirTransport:  req.NoAirTransport,
+		OversizeFee:     req.OversizeFee,
+		ProductType:     ProductTypePhysical,
+		DownloadURL:     req.DownloadURL,
+		Barcode:         barcode,
+		Presale:         req.Presale,
+		PresaleQuota:    req.PresaleQuota,
+		PresaleShipDate: req.PresaleShipDate,
+		MaxPerUser:      req.MaxPerUser,
+		Status:          productStatusFlag(publishStatus),
+		PublishStatus:   publishStatus,
+		SellerID:        sellerID,
+		SalesCount:      0,
+	}
+	if req.BrandID > 0 {
+		product.BrandID = &req.BrandID
+	}
+	if req.ProductType != "" {
+		product.ProductType = req.ProductType
+	}
+	if publishStatus == ProductStatePublished {
+		now := time.Now()
+		product.PublishedAt = &now
 	}
 
 	if err := DB.Create(&product).Error; err != nil {
 		InternalServerError(c, "商品创建失败")
 		return
 	}
+	recordStockEdit(DB, product.ID, 0, product.Stock, MovementTypeManual, c.GetUint("user_id"))
+	AddProductID(product.ID)
+	if publishStatus == ProductStatePublished {
+		notifySavedSearchesOnPublish(product.ID)
+	}
 
 	// 预加载分类信息
-	DB.Preload("Category").First(&product, product.ID)
+	DB.Preload("Category").Preload("Brand").First(&product, product.ID)
 
 	// 缓存新商品
 	CacheProduct(product.ID, &product)
@@ -206,21 +323,23 @@ func CreateProduct(c *gin.Context) {
 		RDB.Del(CTX, keys...)
 	}
 
-	SuccessResponse(c, product)
+	SuccessResponse(c, ProductResponse{Product: product, Warnings: belowCostWarnings(&product, product.Price, "售价")})
 }
 
 // GetProducts 获取商品列表
 // @Summary 获取商品列表
-// @Description 获取商品列表，支持分页、分类筛选、关键字搜索、价格范围筛选和排序
+// @Description 获取商品列表，支持分页、分类和品牌筛选、关键字搜索、价格范围筛选和排序；cover_image 为第一张可用图片，没有可用图片时为占位图并通过 image_issue 标记
 // @Tags 商品管理
 // @Accept json
 // @Produce json
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10)
 // @Param category_id query int false "分类ID"
+// @Param brand_id query int false "品牌ID"
 // @Param keyword query string false "搜索关键字"
 // @Param min_price query number false "最低价格"
 // @Param max_price query number false "最高价格"
+// @Param attr query object false "属性筛选，如 attr[3]=棉|麻、attr[5]=10~20（数值范围）"
 // @Param sort_by query string false "排序字段" Enums(created_at, price, sales_count) default(created_at)
 // @Param sort_order query string false "排序方式" Enums(asc, desc) default(desc)
 // @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Product}} "查询成功"
@@ -242,25 +361,34 @@ func GetProducts(c *gin.Context) {
 		req.PageSize = 10
 	}
 
+	// 属性筛选：attr[属性ID]=值
+	query, attrFilter, err := applyAttributeFilters(DB.Model(&Product{}).Where("status = ?", 1), c.QueryMap("attr"))
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
 	// 构建缓存键
-	cacheKey := fmt.Sprintf("products:list:%d:%d:%d:%s:%.2f:%.2f:%s:%s",
-		req.Page, req.PageSize, req.CategoryID, req.Keyword,
-		req.MinPrice, req.MaxPrice, req.SortBy, req.SortOrder)
+	cacheKey := fmt.Sprintf("products:list:%d:%d:%d:%d:%s:%.2f:%.2f:%s:%s:%s",
+		req.Page, req.PageSize, req.CategoryID, req.BrandID, req.Keyword,
+		req.MinPrice, req.MaxPrice, req.SortBy, req.SortOrder, attrFilter)
 
 	// 尝试从缓存获取
 	if products, total, err := GetCachedProductList(cacheKey); err == nil {
-		PaginationSuccessResponse(c, products, total, req.Page, req.PageSize)
+		PaginationSuccessResponse(c, prepareProductMedia(products), total, req.Page, req.PageSize)
 		return
 	}
 
-	// 构建查询
-	query := DB.Model(&Product{}).Where("status = ?", 1)
-
 	// 分类筛选
 	if req.CategoryID > 0 {
 		query = query.Where("category_id = ?", req.CategoryID)
 	}
 
+	// 品牌筛选
+	if req.BrandID > 0 {
+		query = query.Where("brand_id = ?", req.BrandID)
+	}
+
 	// 关键字搜索
 	if req.Keyword != "" {
 		keyword := "%" + req.Keyword + "%"
@@ -293,7 +421,7 @@ func GetProducts(c *gin.Context) {
 	// 分页查询
 	var products []Product
 	offset := (req.Page - 1) * req.PageSize
-	err := query.Preload("Category").
+	err = query.Preload("Category").Preload("Brand").
 		Order(orderBy).
 		Limit(req.PageSize).
 		Offset(offset).
@@ -307,12 +435,12 @@ func GetProducts(c *gin.Context) {
 	// 缓存结果
 	CacheProductList(cacheKey, products, total)
 
-	PaginationSuccessResponse(c, products, total, req.Page, req.PageSize)
+	PaginationSuccessResponse(c, prepareProductMedia(products), total, req.Page, req.PageSize)
 }
 
 // GetProduct 获取商品详情
 // @Summary 获取商品详情
-// @Description 根据商品ID获取商品的详细信息
+// @Description 根据商品ID获取商品的详细信息，content_blocks 为按顺序排列的图文视频详情内容；登录用户额外返回 favorited 表示是否已收藏
 // @Tags 商品管理
 // @Accept json
 // @Produce json
@@ -331,13 +459,24 @@ func GetProduct(c *gin.Context) {
 
 	// 尝试从缓存获取
 	if product, err := GetCachedProduct(uint(productID)); err == nil {
+		RecordProductView(product.ID)
+		markProductFavorited(c, product)
 		SuccessResponse(c, product)
 		return
 	}
 
+	// 不在商品ID集合中或已缓存为不存在的ID直接返回，不查询数据库
+	if !productIDMayExist(uint(productID)) || IsCachedMissingProduct(uint(productID)) {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+
 	// 从数据库查询
 	var product Product
-	if err := DB.Preload("Category").First(&product, productID).Error; err != nil {
+	if err := DB.Preload("Category").Preload("Brand").Preload("ContentBlocks", preloadContentBlocks).Preload("Attributes.Attribute").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			CacheMissingProduct(uint(productID))
+		}
 		NotFoundError(c, "商品不存在")
 		return
 	}
@@ -350,21 +489,23 @@ func GetProduct(c *gin.Context) {
 
 	// 缓存商品信息
 	CacheProduct(product.ID, &product)
+	RecordProductView(product.ID)
+	markProductFavorited(c, &product)
 
 	SuccessResponse(c, product)
 }
 
 // UpdateProduct 更新商品信息
 // @Summary 更新商品信息
-// @Description 更新商品的名称、描述、价格、库存、分类等信息
+// @Description 更新商品的名称、描述、价格、成本价、库存、分类等信息；售价低于成本价时在 warnings 中提醒；分仓管理的商品不能直接修改库存
 // @Tags 商品管理
 // @Accept json
 // @Produce json
 // @Param id path int true "商品ID"
 // @Param product body UpdateProductRequest true "更新的商品信息"
-// @Success 200 {object} ApiResponse{data=Product} "更新成功"
+// @Success 200 {object} ApiResponse{data=ProductResponse} "更新成功"
 // @Failure 400 {object} ApiResponse "参数验证失败"
-// @Failure 404 {object} ApiResponse "商品不存在或分类不存在"
+// @Failure 404 {object} ApiResponse "商品、分类或品牌不存在"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Security Bearer
 // @Router /api/products/{id} [put]
@@ -401,7 +542,15 @@ func UpdateProduct(c *gin.Context) {
 	if req.Price > 0 {
 		updates["price"] = req.Price
 	}
+	if req.CostPrice > 0 {
+		updates["cost_price"] = req.CostPrice
+	}
 	if req.Stock >= 0 {
+		// 分仓管理的商品总库存由各仓库汇总，只能通过仓库调整
+		if req.Stock != product.Stock && productHasWarehouseStock(DB, product.ID) {
+			BadRequestError(c, "该商品已分仓管理，请通过仓库库存调整修改库存")
+			return
+		}
 		updates["stock"] = req.Stock
 	}
 	if req.CategoryID > 0 {
@@ -413,22 +562,114 @@ func UpdateProduct(c *gin.Context) {
 		}
 		updates["category_id"] = req.CategoryID
 	}
+	if req.BrandID > 0 {
+		// 检查品牌是否存在
+		var brand Brand
+		if err := DB.Where("status = ?", 1).First(&brand, req.BrandID).Error; err != nil {
+			NotFoundError(c, "商品品牌不存在")
+			return
+		}
+		updates["brand_id"] = req.BrandID
+	}
 	if req.Images != nil {
 		imagesData, _ := json.Marshal(req.Images)
 		updates["images"] = string(imagesData)
 	}
+	if req.Tags != nil {
+		tags, err := normalizeProductTags(req.Tags)
+		if err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		updates["tags"] = tags
+	}
+	if req.ShipRegions != nil {
+		updates["ship_regions"] = strings.Join(req.ShipRegions, ",")
+	}
+	if req.NoAirTransport != nil {
+		updates["no_air_transport"] = *req.NoAirTransport
+	}
+	if req.OversizeFee != nil {
+		updates["oversize_fee"] = *req.OversizeFee
+	}
+	if req.ProductType != "" {
+		updates["product_type"] = req.ProductType
+	}
+	if req.DownloadURL != nil {
+		if *req.DownloadURL != "" {
+			if err := validateDownloadURL(*req.DownloadURL); err != nil {
+				BadRequestError(c, err.Error())
+				return
+			}
+		}
+		updates["download_url"] = *req.DownloadURL
+	}
+	if req.Barcode != nil {
+		barcode, err := parseProductBarcode(*req.Barcode, product.ID)
+		if err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		updates["barcode"] = barcode
+	}
+	if req.Presale != nil || req.PresaleQuota != nil || req.PresaleShipDate != nil {
+		// 按更新后的完整预售设置校验
+		presale, quota, shipDate := product.Presale, product.PresaleQuota, product.PresaleShipDate
+		if req.Presale != nil {
+			presale = *req.Presale
+		}
+		if req.PresaleQuota != nil {
+			quota = *req.PresaleQuota
+		}
+		if req.PresaleShipDate != nil {
+			shipDate = req.PresaleShipDate
+		}
+		if err := validatePresale(presale, quota, shipDate); err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		updates["presale"] = presale
+		updates["presale_quota"] = quota
+		updates["presale_ship_date"] = shipDate
+	}
+	if req.MaxPerUser != nil {
+		updates["max_per_user"] = *req.MaxPerUser
+	}
 
-	// 更新商品
-	if err := DB.Model(&product).Updates(updates).Error; err != nil {
+	// 更新商品，售价变化时记录价格历史
+	oldStock := product.Stock
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if price, ok := updates["price"].(float64); ok {
+			if err := recordPriceChange(tx, &product, price, c.GetUint("user_id"), PriceChangeSourceManual); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+		if stock, ok := updates["stock"].(int); ok {
+			return recordStockEdit(tx, product.ID, oldStock, stock, MovementTypeManual, c.GetUint("user_id"))
+		}
+		return nil
+	})
+	if err != nil {
 		InternalServerError(c, "商品更新失败")
 		return
 	}
+	if stock, ok := updates["stock"].(int); ok {
+		GlobalStockManager.SetStock(product.ID, stock)
+		notifyRestockIfReplenished(product.ID, oldStock, stock)
+	} else if _, ok := updates["presale"]; ok {
+		// 预售设置变化会改变可售库存
+		GlobalStockManager.SetStock(product.ID, oldStock)
+	}
 
 	// 重新查询更新后的商品
-	DB.Preload("Category").First(&product, productID)
+	DB.Preload("Category").Preload("Brand").Preload("ContentBlocks", preloadContentBlocks).Preload("Attributes.Attribute").First(&product, productID)
 
 	// 更新缓存
 	CacheProduct(product.ID, &product)
+	RDB.Del(CTX, relatedProductsCacheKey(product.ID))
 
 	// 清除商品列表缓存
 	pattern := "products:list:*"
@@ -437,7 +678,7 @@ func UpdateProduct(c *gin.Context) {
 		RDB.Del(CTX, keys...)
 	}
 
-	SuccessResponse(c, product)
+	SuccessResponse(c, ProductResponse{Product: product, Warnings: belowCostWarnings(&product, product.Price, "售价")})
 }
 
 // DeleteProduct 删除商品（软删除）
@@ -468,8 +709,12 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// 软删除：设置状态为0
-	if err := DB.Model(&product).Update("status", 0).Error; err != nil {
+	// 软删除：设置状态为0并归档
+	updates := map[string]interface{}{"status": 0, "publish_status": ProductStateArchived}
+	if product.DelistedAt == nil {
+		updates["delisted_at"] = time.Now()
+	}
+	if err := DB.Model(&product).Updates(updates).Error; err != nil {
 		InternalServerError(c, "商品删除失败")
 		return
 	}
@@ -650,6 +895,10 @@ func UpdateCategory(c *gin.Context) {
 				NotFoundError(c, "父分类不存在")
 				return
 			}
+			if err := checkCategoryParent(category.ID, req.ParentID); err != nil {
+				BadRequestError(c, err.Error())
+				return
+			}
 		}
 		updates["parent_id"] = req.ParentID
 	}
@@ -857,12 +1106,16 @@ func generateRandomString(length int) string {
 
 // GetHotProducts 获取热门商品
 // @Summary 获取热门商品
-// @Description 根据销量获取热门商品列表
+// @Description 获取热门商品列表，可按分类筛选；默认按累计销量排序，也可按时间窗口内的浏览量或浏览量与销量的加权热度排序
 // @Tags 商品管理
 // @Accept json
 // @Produce json
+// @Param category_id query int false "分类ID"
 // @Param limit query int false "返回数量限制" default(10) maximum(50)
+// @Param sort_by query string false "排序方式" Enums(sales, views, popularity) default(sales)
+// @Param window_days query int false "浏览量和热度的统计天数，默认取配置" maximum(30)
 // @Success 200 {object} ApiResponse{data=[]Product} "查询成功"
+// @Failure 400 {object} ApiResponse "参数错误"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Router /api/products/hot [get]
 func GetHotProducts(c *gin.Context) {
@@ -873,19 +1126,60 @@ func GetHotProducts(c *gin.Context) {
 		}
 	}
 
+	var categoryID uint64
+	if categoryParam := c.Query("category_id"); categoryParam != "" {
+		id, err := strconv.ParseUint(categoryParam, 10, 32)
+		if err != nil {
+			BadRequestError(c, "无效的分类ID")
+			return
+		}
+		categoryID = id
+	}
+
+	sortBy := c.DefaultQuery("sort_by", HotSortSales)
+	if sortBy != HotSortSales && sortBy != HotSortViews && sortBy != HotSortPopularity {
+		BadRequestError(c, "排序方式只能是 sales、views 或 popularity")
+		return
+	}
+	windowDays := AppConfig.HotProductsWindowDays
+	if windowParam := c.Query("window_days"); windowParam != "" {
+		if d, err := strconv.Atoi(windowParam); err == nil && d > 0 && d <= popularityMaxWindowDays {
+			windowDays = d
+		}
+	}
+
 	// 缓存键
-	cacheKey := fmt.Sprintf("products:hot:%d", limit)
+	cacheKey := fmt.Sprintf("products:hot:%d:%d", categoryID, limit)
+	if sortBy != HotSortSales {
+		cacheKey = fmt.Sprintf("products:hot:%s:%d:%d:%d", sortBy, windowDays, categoryID, limit)
+	}
 
 	// 尝试从缓存获取
 	if products, _, err := GetCachedProductList(cacheKey); err == nil {
-		SuccessResponse(c, products)
+		SuccessResponse(c, prepareProductMedia(products))
+		return
+	}
+
+	// 按浏览量或热度排序
+	if sortBy != HotSortSales {
+		products, err := rankHotProducts(sortBy, windowDays, categoryID, limit)
+		if err != nil {
+			InternalServerError(c, "热门商品查询失败")
+			return
+		}
+		CacheProductList(cacheKey, products, int64(len(products)))
+		SuccessResponse(c, prepareProductMedia(products))
 		return
 	}
 
 	// 查询热门商品（按销量排序）
+	query := DB.Preload("Category").Preload("Brand").Where("status = ?", 1)
+	if categoryID > 0 {
+		query = query.Where("category_id = ?", categoryID)
+	}
+
 	var products []Product
-	err := DB.Preload("Category").
-		Where("status = ?", 1).
+	err := query.
 		Order("sales_count DESC, created_at DESC").
 		Limit(limit).
 		Find(&products).Error
@@ -898,20 +1192,25 @@ func GetHotProducts(c *gin.Context) {
 	// 缓存结果
 	CacheProductList(cacheKey, products, int64(len(products)))
 
-	SuccessResponse(c, products)
+	SuccessResponse(c, prepareProductMedia(products))
 }
 
 // SearchProducts 搜索商品
 // @Summary 搜索商品
-// @Description 根据关键字搜索商品名称和描述
+// @Description 根据关键字搜索商品名称和描述，可按分类、品牌、价格筛选；facets 返回分类、品牌、价格区间的商品数量，计算某一维度时不应用该维度自身的筛选条件
 // @Tags 商品管理
 // @Accept json
 // @Produce json
 // @Param keyword query string true "搜索关键字"
+// @Param category_id query int false "分类ID"
+// @Param brand_id query int false "品牌ID"
+// @Param min_price query number false "最低价格（含）"
+// @Param max_price query number false "最高价格（不含）"
+// @Param sort_by query string false "排序方式：relevance 综合、sales 销量、newest 最新、price_asc 价格升序、price_desc 价格降序" default(relevance)
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10) maximum(100)
-// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Product}} "搜索成功"
-// @Failure 400 {object} ApiResponse "搜索关键字不能为空"
+// @Success 200 {object} ApiResponse{data=SearchResponse{list=[]Product}} "搜索成功"
+// @Failure 400 {object} ApiResponse "搜索关键字不能为空或筛选参数错误"
 // @Failure 500 {object} ApiResponse "服务器内部错误"
 // @Router /api/products/search [get]
 func SearchProducts(c *gin.Context) {
@@ -920,6 +1219,18 @@ func SearchProducts(c *gin.Context) {
 		BadRequestError(c, "搜索关键字不能为空")
 		return
 	}
+	recordSearchQuery(keyword)
+
+	var filters SearchFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	sortBy := c.DefaultQuery("sort_by", SearchSortRelevance)
+	if !validSearchSort(sortBy) {
+		BadRequestError(c, "无效的排序方式")
+		return
+	}
 
 	page := 1
 	pageSize := 10
@@ -936,18 +1247,26 @@ func SearchProducts(c *gin.Context) {
 		}
 	}
 
+	// 按同义词扩展搜索词
+	terms := expandSearchKeyword(keyword)
+
+	facets, err := getSearchFacets(keyword, terms, filters)
+	if err != nil {
+		InternalServerError(c, "商品搜索失败")
+		return
+	}
+
 	// 构建缓存键
-	cacheKey := fmt.Sprintf("products:search:%s:%d:%d", keyword, page, pageSize)
+	cacheKey := fmt.Sprintf("products:search:%s:%s:%s:%d:%d", keyword, filters.cacheKey(), sortBy, page, pageSize)
 
 	// 尝试从缓存获取
 	if products, total, err := GetCachedProductList(cacheKey); err == nil {
-		PaginationSuccessResponse(c, products, total, page, pageSize)
+		searchSuccessResponse(c, prepareProductMedia(products), total, page, pageSize, facets)
 		return
 	}
 
 	// 搜索商品
-	searchTerm := "%" + keyword + "%"
-	query := DB.Model(&Product{}).Where("status = ? AND (name LIKE ? OR description LIKE ?)", 1, searchTerm, searchTerm)
+	query := searchQuery(terms, filters, "")
 
 	// 获取总数
 	var total int64
@@ -956,8 +1275,8 @@ func SearchProducts(c *gin.Context) {
 	// 分页查询
 	var products []Product
 	offset := (page - 1) * pageSize
-	err := query.Preload("Category").
-		Order("sales_count DESC, created_at DESC").
+	err = applySearchRanking(query, keyword, terms, sortBy).
+		Preload("Category").Preload("Brand").
 		Limit(pageSize).
 		Offset(offset).
 		Find(&products).Error
@@ -970,5 +1289,5 @@ func SearchProducts(c *gin.Context) {
 	// 缓存结果
 	CacheProductList(cacheKey, products, total)
 
-	PaginationSuccessResponse(c, products, total, page, pageSize)
+	searchSuccessResponse(c, prepareProductMedia(products), total, page, pageSize, facets)
 }
\ No newline at end of file
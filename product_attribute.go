@@ -0,0 +1,484 @@
+package gomall
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 属性类型
+const (
+	AttributeTypeText   = "text"   // 文本
+	AttributeTypeNumber = "number" // 数值，支持范围筛选
+	AttributeTypeEnum   = "enum"   // 枚举，只能取可选值之一
+)
+
+// 商品列表单次最多按多少个属性筛选
+const attributeFilterMaxCount = 10
+
+// CategoryAttributeRequest 创建或更新分类属性
+type CategoryAttributeRequest struct {
+	Name       string   `json:"name" binding:"required,max=50"`
+	Type       string   `json:"type" binding:"required,oneof=text number enum"`
+	Options    []string `json:"options"` // enum 类型必填
+	Unit       string   `json:"unit" binding:"max=20"`
+	Required   bool     `json:"required"`
+	Filterable *bool    `json:"filterable"` // 默认可筛选
+	SortOrder  int      `json:"sort_order"`
+}
+
+// ProductAttributeInput 商品属性值
+type ProductAttributeInput struct {
+	AttributeID uint   `json:"attribute_id" binding:"required"`
+	Value       string `json:"value" binding:"max=200"`
+}
+
+// SetProductAttributesRequest 整体设置商品属性值
+type SetProductAttributesRequest struct {
+	Values []ProductAttributeInput `json:"values" binding:"dive"`
+}
+
+func (r *CategoryAttributeRequest) toAttribute(attribute *CategoryAttribute) error {
+	options := ""
+	if r.Type == AttributeTypeEnum {
+		var values []string
+		for _, option := range r.Options {
+			option = strings.TrimSpace(strings.ReplaceAll(option, ",", ""))
+			if option != "" && !containsString(values, option) {
+				values = append(values, option)
+			}
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("枚举属性必须设置可选值")
+		}
+		options = strings.Join(values, ",")
+		if len(options) > 1000 {
+			return fmt.Errorf("可选值过多")
+		}
+	}
+
+	attribute.Name = strings.TrimSpace(r.Name)
+	attribute.Type = r.Type
+	attribute.Options = options
+	attribute.Unit = r.Unit
+	attribute.Required = r.Required
+	attribute.Filterable = r.Filterable == nil || *r.Filterable
+	attribute.SortOrder = r.SortOrder
+	return nil
+}
+
+// 分类及其全部上级分类的ID，子分类继承上级分类的属性
+func categoryLineage(categoryID uint) []uint {
+	var ids []uint
+	current := categoryID
+	for depth := 0; current != 0 && depth < categoryMaxDepth; depth++ {
+		ids = append(ids, current)
+		var category Category
+		if err := DB.Select("id, parent_id").First(&category, current).Error; err != nil {
+			break
+		}
+		current = category.ParentID
+	}
+	return ids
+}
+
+// 分类可用的全部属性（含继承自上级分类的属性）
+func categoryAttributes(categoryID uint) []CategoryAttribute {
+	attributes := []CategoryAttribute{}
+	if lineage := categoryLineage(categoryID); len(lineage) > 0 {
+		DB.Where("category_id IN ?", lineage).Order("sort_order ASC, id ASC").Find(&attributes)
+	}
+	return attributes
+}
+
+// 校验并规范化属性值，number 类型同时返回数值
+func normalizeAttributeValue(attribute *CategoryAttribute, value string) (string, *float64, error) {
+	value = strings.TrimSpace(value)
+	switch attribute.Type {
+	case AttributeTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("属性「%s」必须是数字", attribute.Name)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), &number, nil
+	case AttributeTypeEnum:
+		if !containsString(splitEnvList(attribute.Options), value) {
+			return "", nil, fmt.Errorf("属性「%s」只能是 %s 之一", attribute.Name, strings.ReplaceAll(attribute.Options, ",", "、"))
+		}
+	}
+	return value, nil, nil
+}
+
+// 按属性筛选商品：attr[属性ID]=值，多个值用|分隔表示任一匹配；数值属性可用 最小值~最大值 表示范围
+// 返回追加了筛选条件的查询和用于缓存键的规范化筛选串
+func applyAttributeFilters(query *gorm.DB, filters map[string]string) (*gorm.DB, string, error) {
+	if len(filters) == 0 {
+		return query, "", nil
+	}
+	if len(filters) > attributeFilterMaxCount {
+		return nil, "", fmt.Errorf("最多同时按%d个属性筛选", attributeFilterMaxCount)
+	}
+
+	ids := make([]uint, 0, len(filters))
+	for key := range filters {
+		id, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return nil, "", fmt.Errorf("无效的属性ID: %s", key)
+		}
+		ids = append(ids, uint(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var attributes []CategoryAttribute
+	DB.Where("id IN ? AND filterable = ?", ids, true).Find(&attributes)
+	byID := make(map[uint]*CategoryAttribute, len(attributes))
+	for i := range attributes {
+		byID[attributes[i].ID] = &attributes[i]
+	}
+
+	var keyParts []string
+	for _, id := range ids {
+		attribute, ok := byID[id]
+		if !ok {
+			return nil, "", fmt.Errorf("属性 %d 不存在或不可筛选", id)
+		}
+		raw := strings.TrimSpace(filters[strconv.FormatUint(uint64(id), 10)])
+		if raw == "" {
+			continue
+		}
+		sub := DB.Model(&ProductAttributeValue{}).Select("product_id").Where("attribute_id = ?", id)
+
+		if attribute.Type == AttributeTypeNumber && strings.Contains(raw, "~") {
+			bounds := strings.SplitN(raw, "~", 2)
+			if min := strings.TrimSpace(bounds[0]); min != "" {
+				value, err := strconv.ParseFloat(min, 64)
+				if err != nil {
+					return nil, "", fmt.Errorf("属性「%s」的筛选范围无效", attribute.Name)
+				}
+				sub = sub.Where("number_value >= ?", value)
+			}
+			if max := strings.TrimSpace(bounds[1]); max != "" {
+				value, err := strconv.ParseFloat(max, 64)
+				if err != nil {
+					return nil, "", fmt.Errorf("属性「%s」的筛选范围无效", attribute.Name)
+				}
+				sub = sub.Where("number_value <= ?", value)
+			}
+		} else {
+			var values []string
+			for _, value := range strings.Split(raw, "|") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+			sub = sub.Where("value IN ?", values)
+		}
+
+		query = query.Where("id IN (?)", sub)
+		keyParts = append(keyParts, fmt.Sprintf("%d=%s", id, raw))
+	}
+	return query, strings.Join(keyParts, ";"), nil
+}
+
+func loadAttributeCategory(c *gin.Context) (*Category, bool) {
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的分类ID")
+		return nil, false
+	}
+	var category Category
+	if err := DB.First(&category, categoryID).Error; err != nil {
+		NotFoundError(c, "分类不存在")
+		return nil, false
+	}
+	return &category, true
+}
+
+// 属性定义变化后，商品详情和列表缓存中的属性信息随之失效
+func invalidateAttributeCaches(attributeID uint) {
+	var productIDs []uint
+	DB.Model(&ProductAttributeValue{}).Where("attribute_id = ?", attributeID).Pluck("product_id", &productIDs)
+	for _, productID := range productIDs {
+		DeleteCachedProduct(productID)
+	}
+	if keys, _ := RDB.Keys(CTX, "products:list:*").Result(); len(keys) > 0 {
+		RDB.Del(CTX, keys...)
+	}
+}
+
+// GetCategoryAttributes 获取分类属性
+// @Summary 获取分类属性
+// @Description 获取分类可用的属性定义，包括继承自上级分类的属性，用于商品编辑和列表筛选
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "分类ID"
+// @Success 200 {object} ApiResponse{data=[]CategoryAttribute} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的分类ID"
+// @Failure 404 {object} ApiResponse "分类不存在"
+// @Router /api/categories/{id}/attributes [get]
+func GetCategoryAttributes(c *gin.Context) {
+	category, ok := loadAttributeCategory(c)
+	if !ok {
+		return
+	}
+
+	SuccessResponse(c, categoryAttributes(category.ID))
+}
+
+// CreateCategoryAttribute 创建分类属性
+// @Summary 创建分类属性
+// @Description 为分类添加属性定义（如屏幕尺寸、材质），子分类自动继承；enum 类型需提供可选值
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "分类ID"
+// @Param attribute body CategoryAttributeRequest true "属性定义"
+// @Success 200 {object} ApiResponse{data=CategoryAttribute} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或属性名重复"
+// @Failure 404 {object} ApiResponse "分类不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/categories/{id}/attributes [post]
+func CreateCategoryAttribute(c *gin.Context) {
+	var req CategoryAttributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	category, ok := loadAttributeCategory(c)
+	if !ok {
+		return
+	}
+
+	attribute := CategoryAttribute{CategoryID: category.ID}
+	if err := req.toAttribute(&attribute); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	for _, existing := range categoryAttributes(category.ID) {
+		if existing.Name == attribute.Name {
+			BadRequestError(c, "属性「"+attribute.Name+"」已存在")
+			return
+		}
+	}
+
+	if err := DB.Create(&attribute).Error; err != nil {
+		InternalServerError(c, "属性创建失败")
+		return
+	}
+
+	SuccessResponse(c, attribute)
+}
+
+// UpdateCategoryAttribute 更新分类属性
+// @Summary 更新分类属性
+// @Description 更新属性定义；属性类型不能修改，enum 类型删除的可选值对应的商品属性值会被清除
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "分类ID"
+// @Param attr_id path int true "属性ID"
+// @Param attribute body CategoryAttributeRequest true "属性定义"
+// @Success 200 {object} ApiResponse{data=CategoryAttribute} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "属性不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/categories/{id}/attributes/{attr_id} [put]
+func UpdateCategoryAttribute(c *gin.Context) {
+	var req CategoryAttributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var attribute CategoryAttribute
+	if err := DB.Where("id = ? AND category_id = ?", c.Param("attr_id"), c.Param("id")).First(&attribute).Error; err != nil {
+		NotFoundError(c, "属性不存在")
+		return
+	}
+	if req.Type != attribute.Type {
+		BadRequestError(c, "属性类型不能修改")
+		return
+	}
+	if err := req.toAttribute(&attribute); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&attribute).Error; err != nil {
+			return err
+		}
+		if attribute.Type == AttributeTypeEnum {
+			return tx.Where("attribute_id = ? AND value NOT IN ?", attribute.ID, splitEnvList(attribute.Options)).
+				Delete(&ProductAttributeValue{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		InternalServerError(c, "属性更新失败")
+		return
+	}
+	invalidateAttributeCaches(attribute.ID)
+
+	SuccessResponse(c, attribute)
+}
+
+// DeleteCategoryAttribute 删除分类属性
+// @Summary 删除分类属性
+// @Description 删除属性定义及全部商品上的该属性值
+// @Tags 商品分类
+// @Accept json
+// @Produce json
+// @Param id path int true "分类ID"
+// @Param attr_id path int true "属性ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 404 {object} ApiResponse "属性不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/categories/{id}/attributes/{attr_id} [delete]
+func DeleteCategoryAttribute(c *gin.Context) {
+	var attribute CategoryAttribute
+	if err := DB.Where("id = ? AND category_id = ?", c.Param("attr_id"), c.Param("id")).First(&attribute).Error; err != nil {
+		NotFoundError(c, "属性不存在")
+		return
+	}
+
+	// 先清除缓存，删除后无法再查到使用该属性的商品
+	invalidateAttributeCaches(attribute.ID)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", attribute.ID).Delete(&ProductAttributeValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&attribute).Error
+	})
+	if err != nil {
+		InternalServerError(c, "属性删除失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "属性删除成功"})
+}
+
+// GetProductAttributes 获取商品属性
+// @Summary 获取商品属性
+// @Description 获取商品的规格参数，商品详情接口的 attributes 字段与此一致
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=[]ProductAttributeValue} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID"
+// @Failure 404 {object} ApiResponse "商品不存在或已下架"
+// @Router /api/products/{id}/attributes [get]
+func GetProductAttributes(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id, status").First(&product, productID).Error; err != nil || product.Status != 1 {
+		NotFoundError(c, "商品不存在或已下架")
+		return
+	}
+
+	values := []ProductAttributeValue{}
+	DB.Preload("Attribute").Where("product_id = ?", product.ID).Find(&values)
+	SuccessResponse(c, values)
+}
+
+// SetProductAttributes 设置商品属性
+// @Summary 设置商品属性
+// @Description 整体替换商品的属性值，属性必须属于商品所在分类或其上级分类；商家只能编辑自己的草稿商品；必填属性必须填写，值为空的属性会被清除
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param attributes body SetProductAttributesRequest true "属性值列表"
+// @Success 200 {object} ApiResponse{data=[]ProductAttributeValue} "保存成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/products/{id}/attributes [put]
+func SetProductAttributes(c *gin.Context) {
+	var req SetProductAttributesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	product, ok := loadContentEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	attributes := categoryAttributes(product.CategoryID)
+	byID := make(map[uint]*CategoryAttribute, len(attributes))
+	for i := range attributes {
+		byID[attributes[i].ID] = &attributes[i]
+	}
+
+	values := make([]ProductAttributeValue, 0, len(req.Values))
+	filled := make(map[uint]bool, len(req.Values))
+	for _, input := range req.Values {
+		attribute, ok := byID[input.AttributeID]
+		if !ok {
+			BadRequestError(c, fmt.Sprintf("属性 %d 不属于商品所在分类", input.AttributeID))
+			return
+		}
+		if filled[attribute.ID] {
+			BadRequestError(c, "属性「"+attribute.Name+"」重复")
+			return
+		}
+		if strings.TrimSpace(input.Value) == "" {
+			continue
+		}
+		value, number, err := normalizeAttributeValue(attribute, input.Value)
+		if err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		filled[attribute.ID] = true
+		values = append(values, ProductAttributeValue{
+			ProductID:   product.ID,
+			AttributeID: attribute.ID,
+			Value:       value,
+			NumberValue: number,
+		})
+	}
+	for _, attribute := range attributes {
+		if attribute.Required && !filled[attribute.ID] {
+			BadRequestError(c, "属性「"+attribute.Name+"」为必填")
+			return
+		}
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&ProductAttributeValue{}).Error; err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return tx.Create(&values).Error
+	})
+	if err != nil {
+		InternalServerError(c, "商品属性保存失败")
+		return
+	}
+	invalidateProductCaches(product.ID)
+
+	result := []ProductAttributeValue{}
+	DB.Preload("Attribute").Where("product_id = ?", product.ID).Find(&result)
+	SuccessResponse(c, result)
+}
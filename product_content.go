@@ -0,0 +1,248 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 详情页内容块类型
+const (
+	ContentBlockText  = "text"  // 文字段落
+	ContentBlockImage = "image" // 图片
+	ContentBlockVideo = "video" // 视频
+)
+
+// 单个商品最多的内容块数量
+const productContentMaxBlocks = 50
+
+// ContentBlockRequest 内容块
+type ContentBlockRequest struct {
+	Type    string `json:"type" binding:"required,oneof=text image video"`
+	Text    string `json:"text" binding:"max=10000"`  // 文字内容，text 类型必填
+	URL     string `json:"url" binding:"max=500"`     // 图片或视频地址，image、video 类型必填
+	Poster  string `json:"poster" binding:"max=500"`  // 视频封面
+	Caption string `json:"caption" binding:"max=200"` // 图片或视频说明
+}
+
+// ReplaceContentBlocksRequest 按顺序整体替换详情内容
+type ReplaceContentBlocksRequest struct {
+	Blocks []ContentBlockRequest `json:"blocks" binding:"dive"`
+}
+
+// 按位置顺序预加载详情内容块
+func preloadContentBlocks(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+func (r *ContentBlockRequest) validate() error {
+	switch r.Type {
+	case ContentBlockText:
+		if strings.TrimSpace(r.Text) == "" {
+			return fmt.Errorf("文字块内容不能为空")
+		}
+	case ContentBlockImage, ContentBlockVideo:
+		if strings.TrimSpace(r.URL) == "" {
+			return fmt.Errorf("图片和视频块必须填写地址")
+		}
+	}
+	return nil
+}
+
+func (r *ContentBlockRequest) toBlock(productID uint, position int) ProductContentBlock {
+	return ProductContentBlock{
+		ProductID: productID,
+		Position:  position,
+		Type:      r.Type,
+		Text:      r.Text,
+		URL:       r.URL,
+		Poster:    r.Poster,
+		Caption:   r.Caption,
+	}
+}
+
+// 加载可编辑详情内容的商品：商家只能编辑自己的草稿商品
+func loadContentEditableProduct(c *gin.Context) (*Product, bool) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return nil, false
+	}
+	if !HasRole(c, RoleAdmin) && product.PublishStatus != ProductStateDraft {
+		BadRequestError(c, "只能编辑草稿状态的商品")
+		return nil, false
+	}
+	return product, true
+}
+
+func productContentBlocks(productID uint) []ProductContentBlock {
+	blocks := []ProductContentBlock{}
+	DB.Where("product_id = ?", productID).Order("position").Find(&blocks)
+	return blocks
+}
+
+// GetProductContent 获取商品详情内容
+// @Summary 获取商品详情内容
+// @Description 按顺序获取商品详情页的图文视频内容块，商品详情接口的 content_blocks 字段与此一致
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=[]ProductContentBlock} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID"
+// @Failure 404 {object} ApiResponse "商品不存在或已下架"
+// @Router /api/products/{id}/content [get]
+func GetProductContent(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id, status").First(&product, productID).Error; err != nil || product.Status != 1 {
+		NotFoundError(c, "商品不存在或已下架")
+		return
+	}
+
+	SuccessResponse(c, productContentBlocks(product.ID))
+}
+
+// ReplaceProductContent 替换商品详情内容
+// @Summary 替换商品详情内容
+// @Description 按提交的顺序整体替换商品详情页的内容块（文字、图片、视频），用于编辑和调整顺序；提交空列表清空详情内容
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param content body ReplaceContentBlocksRequest true "内容块列表"
+// @Success 200 {object} ApiResponse{data=[]ProductContentBlock} "保存成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/products/{id}/content [put]
+func ReplaceProductContent(c *gin.Context) {
+	var req ReplaceContentBlocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	if len(req.Blocks) > productContentMaxBlocks {
+		BadRequestError(c, fmt.Sprintf("详情内容最多%d块", productContentMaxBlocks))
+		return
+	}
+	for i := range req.Blocks {
+		if err := req.Blocks[i].validate(); err != nil {
+			BadRequestError(c, fmt.Sprintf("第%d块: %v", i+1, err))
+			return
+		}
+	}
+
+	product, ok := loadContentEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&ProductContentBlock{}).Error; err != nil {
+			return err
+		}
+		if len(req.Blocks) == 0 {
+			return nil
+		}
+		blocks := make([]ProductContentBlock, len(req.Blocks))
+		for i := range req.Blocks {
+			blocks[i] = req.Blocks[i].toBlock(product.ID, i+1)
+		}
+		return tx.Create(&blocks).Error
+	})
+	if err != nil {
+		InternalServerError(c, "详情内容保存失败")
+		return
+	}
+	DeleteCachedProduct(product.ID)
+
+	SuccessResponse(c, productContentBlocks(product.ID))
+}
+
+// AddProductContentBlock 追加详情内容块
+// @Summary 追加详情内容块
+// @Description 在商品详情内容末尾追加一个内容块
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param block body ContentBlockRequest true "内容块"
+// @Success 200 {object} ApiResponse{data=ProductContentBlock} "添加成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或内容块数量超过上限"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/products/{id}/content [post]
+func AddProductContentBlock(c *gin.Context) {
+	var req ContentBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	if err := req.validate(); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	product, ok := loadContentEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	var stats struct {
+		Count       int
+		MaxPosition int
+	}
+	DB.Model(&ProductContentBlock{}).Select("COUNT(*) AS count, COALESCE(MAX(position), 0) AS max_position").
+		Where("product_id = ?", product.ID).Scan(&stats)
+	if stats.Count >= productContentMaxBlocks {
+		BadRequestError(c, fmt.Sprintf("详情内容最多%d块", productContentMaxBlocks))
+		return
+	}
+
+	block := req.toBlock(product.ID, stats.MaxPosition+1)
+	if err := DB.Create(&block).Error; err != nil {
+		InternalServerError(c, "内容块添加失败")
+		return
+	}
+	DeleteCachedProduct(product.ID)
+
+	SuccessResponse(c, block)
+}
+
+// DeleteProductContentBlock 删除详情内容块
+// @Summary 删除详情内容块
+// @Description 删除商品详情中的一个内容块，其余内容块顺序不变
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param block_id path int true "内容块ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 404 {object} ApiResponse "商品或内容块不存在"
+// @Security Bearer
+// @Router /api/products/{id}/content/{block_id} [delete]
+func DeleteProductContentBlock(c *gin.Context) {
+	product, ok := loadContentEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	result := DB.Where("id = ? AND product_id = ?", c.Param("block_id"), product.ID).Delete(&ProductContentBlock{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		NotFoundError(c, "内容块不存在")
+		return
+	}
+	DeleteCachedProduct(product.ID)
+
+	SuccessResponse(c, gin.H{"message": "内容块删除成功"})
+}
@@ -0,0 +1,103 @@
+package gomall
+
+import (
+	"log"
+	"time"
+)
+
+// 全部商品ID的集合，GetProduct 在查询数据库前先检查，用随机或连续ID探测的请求直接返回404
+const productIDSetKey = "products:ids"
+
+// 重建集合时的分布式锁，避免多个实例或请求同时重建
+const productIDSetLockKey = "products:ids:rebuilding"
+
+// 没有任何商品时写入的空标记，标记过期前不再重建，避免每次查询都触发重建
+const productIDSetEmptyKey = "products:ids:empty"
+
+const productIDSetEmptyTTL = time.Minute
+
+const productIDSetBatchSize = 1000
+
+// 按ID顺序分批读取大于 afterID 的商品ID写入集合，返回最大的ID
+func addProductIDsAfter(key string, afterID uint) (uint, error) {
+	for {
+		var ids []uint
+		if err := DB.Model(&Product{}).Where("id > ?", afterID).Order("id").
+			Limit(productIDSetBatchSize).Pluck("id", &ids).Error; err != nil {
+			return afterID, err
+		}
+		if len(ids) == 0 {
+			return afterID, nil
+		}
+		members := make([]interface{}, len(ids))
+		for i, id := range ids {
+			members[i] = id
+		}
+		if err := RDB.SAdd(CTX, key, members...).Err(); err != nil {
+			return afterID, err
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
+
+// RebuildProductIDSet 从数据库重建商品ID集合，先写入临时键再改名，重建期间不影响查询
+func RebuildProductIDSet() error {
+	if ok, _ := RDB.SetNX(CTX, productIDSetLockKey, 1, 5*time.Minute).Result(); !ok {
+		return nil
+	}
+	defer RDB.Del(CTX, productIDSetLockKey)
+
+	tempKey := productIDSetKey + ":building"
+	RDB.Del(CTX, tempKey)
+	lastID, err := addProductIDsAfter(tempKey, 0)
+	if err != nil {
+		RDB.Del(CTX, tempKey)
+		return err
+	}
+	if lastID == 0 {
+		// 还没有商品，不建立集合，查询时直接走数据库
+		return RDB.Set(CTX, productIDSetEmptyKey, 1, productIDSetEmptyTTL).Err()
+	}
+	if err := RDB.Rename(CTX, tempKey, productIDSetKey).Err(); err != nil {
+		return err
+	}
+	RDB.Del(CTX, productIDSetEmptyKey)
+
+	// 补上重建期间新建的商品
+	_, err = addProductIDsAfter(productIDSetKey, lastID)
+	return err
+}
+
+// AddProductID 新建商品后加入集合；集合尚未建立时不写入，避免只包含部分ID，
+// 并清除空标记，下次查询时重建集合
+func AddProductID(productID uint) {
+	if exists, err := RDB.Exists(CTX, productIDSetKey).Result(); err == nil && exists > 0 {
+		RDB.SAdd(CTX, productIDSetKey, productID)
+	} else if err == nil {
+		RDB.Del(CTX, productIDSetEmptyKey)
+	}
+}
+
+// 判断商品ID是否可能存在。集合不存在（如Redis被清空）或Redis出错时视为可能存在，并在后台重建集合；
+// 没有商品时只在空标记过期后重建
+func productIDMayExist(productID uint) bool {
+	pipe := RDB.Pipeline()
+	exists := pipe.Exists(CTX, productIDSetKey)
+	member := pipe.SIsMember(CTX, productIDSetKey, productID)
+	empty := pipe.Exists(CTX, productIDSetEmptyKey)
+	if _, err := pipe.Exec(CTX); err != nil {
+		return true
+	}
+	if exists.Val() == 0 {
+		if empty.Val() > 0 {
+			return true
+		}
+		go func() {
+			if err := RebuildProductIDSet(); err != nil {
+				log.Printf("商品ID集合重建失败: %v", err)
+			}
+		}()
+		return true
+	}
+	return member.Val()
+}
@@ -0,0 +1,42 @@
+package gomall_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 没有商品时写入空标记，标记有效期内查询商品不再触发集合重建
+func TestProductIDSetEmptyMarker(t *testing.T) {
+	kit := testkit.New(t)
+
+	if err := kit.DB.Where("1 = 1").Delete(&gomall.Product{}).Error; err != nil {
+		t.Fatalf("商品删除失败: %v", err)
+	}
+	kit.Redis.Del(kit.Redis.Context(), "products:ids")
+
+	kit.Do(http.MethodGet, "/api/products/1", "", nil)
+	deadline := time.Now().Add(2 * time.Second)
+	for kit.Redis.Exists(kit.Redis.Context(), "products:ids:empty").Val() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("没有商品时未写入空标记")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ttl := kit.Redis.TTL(kit.Redis.Context(), "products:ids:empty").Val(); ttl <= 0 {
+		t.Fatalf("空标记没有过期时间: %v", ttl)
+	}
+
+	// 新建商品后清除空标记，下次查询时重建集合
+	product := gomall.Product{Name: "新商品", Price: 1, Stock: 1, CategoryID: kit.Fixtures.Category.ID}
+	if err := kit.DB.Create(&product).Error; err != nil {
+		t.Fatalf("商品创建失败: %v", err)
+	}
+	gomall.AddProductID(product.ID)
+	if kit.Redis.Exists(kit.Redis.Context(), "products:ids:empty").Val() != 0 {
+		t.Fatalf("新建商品后空标记未清除")
+	}
+}
@@ -0,0 +1,469 @@
+package gomall
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 商品导入导出的列，导入时按表头名称匹配，列顺序不限
+var productSheetColumns = []string{"id", "name", "description", "price", "cost_price", "stock", "category_id", "brand_id", "images", "tags", "status", "barcode"}
+
+// 单次导入的最大行数
+const productImportMaxRows = 10000
+
+// ImportRowError 导入失败的行，商品和订单导入共用
+type ImportRowError struct {
+	Row     int    `json:"row"` // 文件中的行号（含表头）
+	Message string `json:"message"`
+}
+
+// ProductImportResult 导入结果
+type ProductImportResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// 逐行读取上传的CSV或XLSX文件
+func readSheetRows(c *gin.Context, fn func(line int, values []string) error) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return fmt.Errorf("请选择要导入的文件")
+	}
+	if fileHeader.Size > AppConfig.MaxFileSize {
+		return fmt.Errorf("文件大小超过限制")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return fmt.Errorf("文件读取失败")
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".xlsx":
+		return readXLSXRows(file, fileHeader.Size, fn)
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("第%d行CSV格式错误: %v", line, err)
+			}
+			if line == 1 && len(record) > 0 {
+				// Excel另存的CSV带有UTF-8 BOM
+				record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			}
+			if err := fn(line, record); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("仅支持CSV或XLSX文件")
+	}
+}
+
+// 商品导入时引用的分类和品牌，导入前一次性加载
+type productImportRefs struct {
+	categories map[uint]bool
+	brands     map[uint]bool
+}
+
+func loadProductImportRefs() (*productImportRefs, error) {
+	refs := &productImportRefs{categories: make(map[uint]bool), brands: make(map[uint]bool)}
+	var categoryIDs, brandIDs []uint
+	if err := DB.Model(&Category{}).Where("status = ?", 1).Pluck("id", &categoryIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Brand{}).Where("status = ?", 1).Pluck("id", &brandIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range categoryIDs {
+		refs.categories[id] = true
+	}
+	for _, id := range brandIDs {
+		refs.brands[id] = true
+	}
+	return refs, nil
+}
+
+// 将一行数据解析为字段更新，create 为 true 时检查新建商品的必填项
+func parseProductRow(row map[string]string, refs *productImportRefs, create bool) (map[string]interface{}, error) {
+	updates := make(map[string]interface{})
+
+	if name := row["name"]; name != "" {
+		if len([]rune(name)) > 200 {
+			return nil, fmt.Errorf("商品名称不能超过200个字符")
+		}
+		updates["name"] = name
+	} else if create {
+		return nil, fmt.Errorf("商品名称不能为空")
+	}
+	if description := row["description"]; description != "" {
+		updates["description"] = description
+	}
+
+	if value := row["price"]; value != "" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("价格必须是大于0的数字")
+		}
+		updates["price"] = price
+	} else if create {
+		return nil, fmt.Errorf("价格不能为空")
+	}
+	if value := row["cost_price"]; value != "" {
+		costPrice, err := strconv.ParseFloat(value, 64)
+		if err != nil || costPrice < 0 {
+			return nil, fmt.Errorf("成本价必须是不小于0的数字")
+		}
+		updates["cost_price"] = costPrice
+	}
+	if value := row["stock"]; value != "" {
+		stock, err := strconv.Atoi(value)
+		if err != nil || stock < 0 {
+			return nil, fmt.Errorf("库存必须是不小于0的整数")
+		}
+		updates["stock"] = stock
+	}
+
+	if value := row["category_id"]; value != "" {
+		categoryID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || !refs.categories[uint(categoryID)] {
+			return nil, fmt.Errorf("分类 %s 不存在", value)
+		}
+		updates["category_id"] = uint(categoryID)
+	} else if create {
+		return nil, fmt.Errorf("分类ID不能为空")
+	}
+	if value := row["brand_id"]; value != "" {
+		brandID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || !refs.brands[uint(brandID)] {
+			return nil, fmt.Errorf("品牌 %s 不存在", value)
+		}
+		updates["brand_id"] = uint(brandID)
+	}
+
+	// 多张图片用 | 分隔
+	if value := row["images"]; value != "" {
+		var images []string
+		for _, image := range strings.Split(value, "|") {
+			if image = strings.TrimSpace(image); image != "" {
+				images = append(images, image)
+			}
+		}
+		data, _ := json.Marshal(images)
+		updates["images"] = string(data)
+	}
+	// 多个标签用 | 分隔
+	if value := row["tags"]; value != "" {
+		tags, err := normalizeProductTags(strings.Split(value, "|"))
+		if err != nil {
+			return nil, err
+		}
+		updates["tags"] = tags
+	}
+	if value := row["status"]; value != "" {
+		if value != "0" && value != "1" {
+			return nil, fmt.Errorf("状态只能是0（下架）或1（上架）")
+		}
+		updates["status"], _ = strconv.Atoi(value)
+		updates["publish_status"] = ProductStatePublished
+		if value == "0" {
+			updates["publish_status"] = ProductStateArchived
+		}
+	}
+	return updates, nil
+}
+
+// 按ID更新已有商品，没有ID时新建商品
+func upsertProductRow(row map[string]string, refs *productImportRefs, userID uint) (created bool, productID uint, err error) {
+	var product Product
+	if value := row["id"]; value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return false, 0, fmt.Errorf("无效的商品ID")
+		}
+		if err := DB.First(&product, id).Error; err != nil {
+			return false, 0, fmt.Errorf("商品 %d 不存在", id)
+		}
+	}
+
+	create := product.ID == 0
+	updates, err := parseProductRow(row, refs, create)
+	if err != nil {
+		return false, 0, err
+	}
+	if value := row["barcode"]; value != "" {
+		barcode, err := parseProductBarcode(value, product.ID)
+		if err != nil {
+			return false, 0, err
+		}
+		updates["barcode"] = barcode
+	}
+
+	if !create {
+		if stock, ok := updates["stock"].(int); ok && stock != product.Stock && productHasWarehouseStock(DB, product.ID) {
+			return false, 0, fmt.Errorf("商品已分仓管理，请通过仓库库存调整修改库存")
+		}
+		if len(updates) > 0 {
+			oldStock := product.Stock
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				if price, ok := updates["price"].(float64); ok {
+					if err := recordPriceChange(tx, &product, price, userID, PriceChangeSourceImport); err != nil {
+						return err
+					}
+				}
+				if err := tx.Model(&product).Updates(updates).Error; err != nil {
+					return err
+				}
+				if stock, ok := updates["stock"].(int); ok {
+					return recordStockEdit(tx, product.ID, oldStock, stock, MovementTypeImport, userID)
+				}
+				return nil
+			})
+			if err != nil {
+				return false, 0, fmt.Errorf("商品更新失败")
+			}
+			if stock, ok := updates["stock"].(int); ok {
+				GlobalStockManager.SetStock(product.ID, stock)
+				notifyRestockIfReplenished(product.ID, oldStock, stock)
+			}
+		}
+		return false, product.ID, nil
+	}
+
+	product = Product{
+		Name:          updates["name"].(string),
+		Price:         updates["price"].(float64),
+		CategoryID:    updates["category_id"].(uint),
+		Status:        1,
+		PublishStatus: ProductStatePublished,
+	}
+	if value, ok := updates["description"].(string); ok {
+		product.Description = value
+	}
+	if value, ok := updates["cost_price"].(float64); ok {
+		product.CostPrice = value
+	}
+	if value, ok := updates["stock"].(int); ok {
+		product.Stock = value
+	}
+	if value, ok := updates["brand_id"].(uint); ok {
+		product.BrandID = &value
+	}
+	if value, ok := updates["images"].(string); ok {
+		product.Images = value
+	}
+	if value, ok := updates["tags"].(string); ok {
+		product.Tags = value
+	}
+	if value, ok := updates["barcode"].(*string); ok {
+		product.Barcode = value
+	}
+	if value, ok := updates["status"].(int); ok {
+		product.Status = value
+		product.PublishStatus = updates["publish_status"].(string)
+	}
+	if err := DB.Create(&product).Error; err != nil {
+		return false, 0, fmt.Errorf("商品创建失败")
+	}
+	recordStockEdit(DB, product.ID, 0, product.Stock, MovementTypeImport, userID)
+	AddProductID(product.ID)
+	if product.Status == 1 {
+		notifySavedSearchesOnPublish(product.ID)
+	}
+	return true, product.ID, nil
+}
+
+// ImportProducts 批量导入商品
+// @Summary 批量导入商品
+// @Description 上传CSV或XLSX文件批量新建或更新商品。首行为表头，支持的列：id、name、description、price、cost_price、stock、category_id、brand_id、images（多张用|分隔）、tags（多个用|分隔）、status、barcode；填写id时更新该商品的非空列，不填id时新建商品（name、price、category_id必填）。逐行处理，失败的行不影响其他行，结果中返回每个失败行的原因
+// @Tags 商品管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV或XLSX文件"
+// @Success 200 {object} ApiResponse{data=ProductImportResult} "导入完成"
+// @Failure 400 {object} ApiResponse "文件格式错误或缺少必要的列"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/products/import [post]
+func ImportProducts(c *gin.Context) {
+	refs, err := loadProductImportRefs()
+	if err != nil {
+		InternalServerError(c, "分类和品牌查询失败")
+		return
+	}
+
+	result := ProductImportResult{Errors: []ImportRowError{}}
+	var headers []string
+	var touched []uint
+	rows := 0
+
+	err = readSheetRows(c, func(line int, values []string) error {
+		if headers == nil {
+			headers = make([]string, len(values))
+			for i, value := range values {
+				headers[i] = strings.ToLower(strings.TrimSpace(value))
+			}
+			if !containsString(headers, "id") && !containsString(headers, "name") {
+				return fmt.Errorf("表头必须包含 id 或 name 列")
+			}
+			return nil
+		}
+
+		row := make(map[string]string, len(headers))
+		empty := true
+		for i, header := range headers {
+			if i < len(values) {
+				row[header] = strings.TrimSpace(values[i])
+				empty = empty && row[header] == ""
+			}
+		}
+		if empty {
+			return nil
+		}
+		if rows++; rows > productImportMaxRows {
+			return fmt.Errorf("单次最多导入%d行", productImportMaxRows)
+		}
+
+		created, productID, err := upsertProductRow(row, refs, c.GetUint("user_id"))
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Message: err.Error()})
+			return nil
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		touched = append(touched, productID)
+		return nil
+	})
+
+	// 已处理的行即使后续出错也已写入，需要清除缓存
+	for _, productID := range touched {
+		DeleteCachedProduct(productID)
+	}
+	if len(touched) > 0 {
+		if keys, _ := RDB.Keys(CTX, "products:list:*").Result(); len(keys) > 0 {
+			RDB.Del(CTX, keys...)
+		}
+	}
+
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if headers == nil {
+		BadRequestError(c, "文件内容为空")
+		return
+	}
+	SuccessResponse(c, result)
+}
+
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportProducts 导出商品目录
+// @Summary 导出商品目录
+// @Description 以CSV或XLSX格式导出全部商品（含已下架），列与导入格式一致，修改后可直接重新导入
+// @Tags 商品管理
+// @Produce octet-stream
+// @Param format query string false "导出格式：csv、xlsx" default(csv)
+// @Success 200 {file} file "商品目录文件"
+// @Failure 400 {object} ApiResponse "不支持的导出格式"
+// @Security Bearer
+// @Router /api/admin/products/export [get]
+func ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		BadRequestError(c, "导出格式只支持csv或xlsx")
+		return
+	}
+
+	filename := fmt.Sprintf("gomall-products-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	var writeRow func([]string) error
+	var finish func() error
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		xw, err := newXLSXWriter(c.Writer)
+		if err != nil {
+			InternalServerError(c, "导出失败")
+			return
+		}
+		writeRow, finish = xw.WriteRow, xw.Close
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		// 写入BOM，Excel打开时中文不乱码
+		c.Writer.WriteString("\ufeff")
+		cw := csv.NewWriter(c.Writer)
+		writeRow = cw.Write
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	if err := writeRow(productSheetColumns); err != nil {
+		return
+	}
+
+	// 分批读取并直接写入响应，避免整个商品目录加载到内存
+	var products []Product
+	DB.Order("id").FindInBatches(&products, 1000, func(tx *gorm.DB, batch int) error {
+		for _, product := range products {
+			var images []string
+			json.Unmarshal([]byte(product.Images), &images)
+			brandID := ""
+			if product.BrandID != nil {
+				brandID = strconv.FormatUint(uint64(*product.BrandID), 10)
+			}
+			barcode := ""
+			if product.Barcode != nil {
+				barcode = *product.Barcode
+			}
+			record := []string{
+				strconv.FormatUint(uint64(product.ID), 10),
+				product.Name,
+				product.Description,
+				fmt.Sprintf("%.2f", product.Price),
+				fmt.Sprintf("%.2f", product.CostPrice),
+				strconv.Itoa(product.Stock),
+				strconv.FormatUint(uint64(product.CategoryID), 10),
+				brandID,
+				strings.Join(images, "|"),
+				strings.ReplaceAll(product.Tags, ",", "|"),
+				strconv.Itoa(product.Status),
+				barcode,
+			}
+			if err := writeRow(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	finish()
+}
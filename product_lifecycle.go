@@ -0,0 +1,557 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 商品生命周期状态，只有已发布的商品在前台可见（status 同步为 1）
+const (
+	ProductStateDraft         = "draft"          // 草稿
+	ProductStatePendingReview = "pending_review" // 待审核
+	ProductStatePublished     = "published"      // 已发布
+	ProductStateArchived      = "archived"       // 已归档（下架）
+)
+
+// 允许的状态流转
+var productTransitions = map[string][]string{
+	ProductStateDraft:         {ProductStatePendingReview, ProductStatePublished, ProductStateArchived},
+	ProductStatePendingReview: {ProductStatePublished, ProductStateDraft, ProductStateArchived},
+	ProductStatePublished:     {ProductStateArchived},
+	ProductStateArchived:      {ProductStateDraft, ProductStatePublished},
+}
+
+// ReviewProductRequest 审核或流转商品的说明
+type ReviewProductRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+// ScheduleProductRequest 定时上下架设置，传 null 取消对应的定时
+type ScheduleProductRequest struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// 前台可见标志：已发布为1，其他状态为0
+func productStatusFlag(state string) int {
+	if state == ProductStatePublished {
+		return 1
+	}
+	return 0
+}
+
+func validProductState(state string) bool {
+	_, ok := productTransitions[state]
+	return ok
+}
+
+func canTransitionProduct(from, to string) bool {
+	return containsString(productTransitions[from], to)
+}
+
+// 旧数据只有 status 字段，已下架的商品归档
+func migrateProductLifecycle() error {
+	return DB.Model(&Product{}).
+		Where("status = ? AND publish_status = ?", 0, ProductStatePublished).
+		Update("publish_status", ProductStateArchived).Error
+}
+
+// 加载当前用户可管理的商品：管理员可管理全部商品，商家只能管理自己创建的商品
+func loadManagedProduct(c *gin.Context) (*Product, bool) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return nil, false
+	}
+
+	var product Product
+	if err := DB.First(&product, productID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return nil, false
+	}
+	if !HasRole(c, RoleAdmin) && (product.SellerID == nil || *product.SellerID != c.GetUint("user_id")) {
+		NotFoundError(c, "商品不存在")
+		return nil, false
+	}
+	return &product, true
+}
+
+// 清除商品详情、商品列表以及包含该商品的专题缓存
+func invalidateProductCaches(productID uint) {
+	DeleteCachedProduct(productID)
+	if keys, _ := RDB.Keys(CTX, "products:list:*").Result(); len(keys) > 0 {
+		RDB.Del(CTX, keys...)
+	}
+
+	var slugs []string
+	DB.Model(&Collection{}).
+		Joins("JOIN collection_items ON collection_items.collection_id = collections.id").
+		Where("collection_items.product_id = ?", productID).
+		Pluck("collections.slug", &slugs)
+	for _, slug := range slugs {
+		DeleteCachedCollection(slug)
+	}
+}
+
+// 按状态机变更商品状态，以当前状态作为条件防止并发重复变更
+func applyProductState(product *Product, to, note string) error {
+	from := product.PublishStatus
+	if !canTransitionProduct(from, to) {
+		return fmt.Errorf("商品当前状态为 %s，不能变更为 %s", from, to)
+	}
+
+	updates := map[string]interface{}{
+		"publish_status": to,
+		"status":         productStatusFlag(to),
+		"review_note":    note,
+	}
+	firstPublish := to == ProductStatePublished && product.PublishedAt == nil
+	if firstPublish {
+		updates["published_at"] = time.Now()
+	}
+	// 记录从前台下架的时间，用于清理购物车中长期失效的商品
+	if productStatusFlag(to) == 1 {
+		updates["delisted_at"] = nil
+	} else if product.Status == 1 {
+		updates["delisted_at"] = time.Now()
+	}
+	result := DB.Model(&Product{}).Where("id = ? AND publish_status = ?", product.ID, from).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("商品状态更新失败")
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("商品状态已变更，请刷新后重试")
+	}
+
+	invalidateProductCaches(product.ID)
+	if firstPublish {
+		notifySavedSearchesOnPublish(product.ID)
+	}
+	return DB.Preload("Category").Preload("Brand").First(product, product.ID).Error
+}
+
+// 变更商品状态并记录操作人，note 记录审核意见
+func changeProductState(c *gin.Context, product *Product, to, note string) bool {
+	if err := applyProductState(product, to, note); err != nil {
+		BadRequestError(c, err.Error())
+		return false
+	}
+	log.Printf("用户 %d 将商品 %d 的状态变更为 %s", c.GetUint("user_id"), product.ID, to)
+	return true
+}
+
+// 执行到期的定时上下架，执行后清除对应的定时设置
+func runProductSchedules(now time.Time) {
+	var due []Product
+	DB.Where("publish_at <= ? AND publish_status <> ?", now, ProductStatePublished).Find(&due)
+	for i := range due {
+		if err := applyProductState(&due[i], ProductStatePublished, ""); err != nil {
+			log.Printf("商品 %d 定时上架失败: %v", due[i].ID, err)
+		} else {
+			log.Printf("商品 %d 已定时上架", due[i].ID)
+		}
+		DB.Model(&Product{}).Where("id = ?", due[i].ID).Update("publish_at", nil)
+	}
+
+	// 到期时未发布的商品只清除定时，避免之后手动发布时被立即下架
+	due = nil
+	DB.Where("unpublish_at <= ?", now).Find(&due)
+	for i := range due {
+		if due[i].PublishStatus != ProductStatePublished {
+			DB.Model(&Product{}).Where("id = ?", due[i].ID).Update("unpublish_at", nil)
+			continue
+		}
+		if err := applyProductState(&due[i], ProductStateArchived, ""); err != nil {
+			log.Printf("商品 %d 定时下架失败: %v", due[i].ID, err)
+		} else {
+			log.Printf("商品 %d 已定时下架", due[i].ID)
+		}
+		DB.Model(&Product{}).Where("id = ?", due[i].ID).Update("unpublish_at", nil)
+	}
+}
+
+// StartProductScheduler 启动商品定时上下架任务，每分钟检查一次
+func StartProductScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			// 多实例部署时同一分钟只由一个实例执行
+			key := "products:schedule:" + time.Now().Format("200601021504")
+			if ok, _ := RDB.SetNX(CTX, key, 1, 2*time.Minute).Result(); ok {
+				runProductSchedules(time.Now())
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("商品定时上下架任务已启动")
+}
+
+// 审核结果通知商家
+func notifyProductSeller(product *Product, title, content string) {
+	if product.SellerID != nil {
+		SendNotification(*product.SellerID, NotificationTypeSystem, title, content)
+	}
+}
+
+// GetSellerProducts 获取商家自己的商品
+// @Summary 获取我的商品（商家端）
+// @Description 商家分页查看自己创建的商品，可按生命周期状态筛选
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param publish_status query string false "商品状态" Enums(draft, pending_review, published, archived)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Product}} "查询成功"
+// @Failure 400 {object} ApiResponse "商品状态无效"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/seller/products [get]
+func GetSellerProducts(c *gin.Context) {
+	listManagedProducts(c, DB.Model(&Product{}).Where("seller_id = ?", c.GetUint("user_id")))
+}
+
+// GetAdminProducts 获取全部商品（管理端）
+// @Summary 获取全部商品（管理端）
+// @Description 管理员分页查看包括草稿、待审核和已归档在内的全部商品，可按状态、商家和关键字筛选；审核队列使用 publish_status=pending_review
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param publish_status query string false "商品状态" Enums(draft, pending_review, published, archived)
+// @Param seller_id query int false "商家用户ID"
+// @Param keyword query string false "商品名称关键字"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Product}} "查询成功"
+// @Failure 400 {object} ApiResponse "商品状态无效"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/products [get]
+func GetAdminProducts(c *gin.Context) {
+	query := DB.Model(&Product{})
+	if value := c.Query("seller_id"); value != "" {
+		if sellerID, err := strconv.ParseUint(value, 10, 32); err == nil {
+			query = query.Where("seller_id = ?", sellerID)
+		}
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	listManagedProducts(c, query)
+}
+
+func listManagedProducts(c *gin.Context, query *gorm.DB) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	if state := c.Query("publish_status"); state != "" {
+		if !validProductState(state) {
+			BadRequestError(c, "商品状态无效")
+			return
+		}
+		query = query.Where("publish_status = ?", state)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var products []Product
+	if err := query.Preload("Category").Preload("Brand").Order("updated_at DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&products).Error; err != nil {
+		InternalServerError(c, "商品查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, products, total, page, pageSize)
+}
+
+// UpdateSellerProduct 商家编辑商品
+// @Summary 编辑我的商品（商家端）
+// @Description 商家只能编辑自己处于草稿状态的商品，被驳回的商品回到草稿后可修改再提交
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param product body UpdateProductRequest true "更新的商品信息"
+// @Success 200 {object} ApiResponse{data=ProductResponse} "更新成功"
+// @Failure 400 {object} ApiResponse "商品不是草稿状态"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/seller/products/{id} [put]
+func UpdateSellerProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStateDraft {
+		BadRequestError(c, "只能编辑草稿状态的商品")
+		return
+	}
+	UpdateProduct(c)
+}
+
+// SubmitProduct 提交商品审核
+// @Summary 提交商品审核
+// @Description 商家将草稿商品提交给管理员审核，审核通过后上架
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=Product} "提交成功"
+// @Failure 400 {object} ApiResponse "商品状态不允许提交"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/seller/products/{id}/submit [put]
+func SubmitProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStateDraft {
+		BadRequestError(c, "只有草稿状态的商品可以提交审核")
+		return
+	}
+	if changeProductState(c, product, ProductStatePendingReview, "") {
+		SuccessResponse(c, product)
+	}
+}
+
+// WithdrawProduct 撤回审核
+// @Summary 撤回商品审核
+// @Description 商家撤回待审核的商品，商品回到草稿状态
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=Product} "撤回成功"
+// @Failure 400 {object} ApiResponse "商品不是待审核状态"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/seller/products/{id}/withdraw [put]
+func WithdrawProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStatePendingReview {
+		BadRequestError(c, "只有待审核的商品可以撤回")
+		return
+	}
+	if changeProductState(c, product, ProductStateDraft, "") {
+		SuccessResponse(c, product)
+	}
+}
+
+// ArchiveProduct 归档商品
+// @Summary 归档商品
+// @Description 将商品归档（下架），商家只能归档自己的商品；归档后可重新编辑或由管理员重新发布
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=Product} "归档成功"
+// @Failure 400 {object} ApiResponse "商品已归档"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/seller/products/{id}/archive [put]
+func ArchiveProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if changeProductState(c, product, ProductStateArchived, "") {
+		SuccessResponse(c, product)
+	}
+}
+
+// RestoreProduct 恢复已归档的商品
+// @Summary 恢复归档商品
+// @Description 将已归档的商品恢复为草稿，修改后可重新提交审核
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=Product} "恢复成功"
+// @Failure 400 {object} ApiResponse "商品不是已归档状态"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/seller/products/{id}/restore [put]
+func RestoreProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStateArchived {
+		BadRequestError(c, "只有已归档的商品可以恢复")
+		return
+	}
+	if changeProductState(c, product, ProductStateDraft, "") {
+		SuccessResponse(c, product)
+	}
+}
+
+// ApproveProduct 审核通过
+// @Summary 审核通过商品
+// @Description 管理员审核通过商家提交的商品，商品立即上架并通知商家
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param review body ReviewProductRequest false "审核意见"
+// @Success 200 {object} ApiResponse{data=Product} "审核通过"
+// @Failure 400 {object} ApiResponse "商品不是待审核状态"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/admin/products/{id}/approve [put]
+func ApproveProduct(c *gin.Context) {
+	var req ReviewProductRequest
+	c.ShouldBindJSON(&req)
+
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStatePendingReview {
+		BadRequestError(c, "只有待审核的商品可以审核")
+		return
+	}
+	if !changeProductState(c, product, ProductStatePublished, req.Note) {
+		return
+	}
+
+	notifyProductSeller(product, "商品审核通过", fmt.Sprintf("您提交的商品「%s」已审核通过并上架。%s", product.Name, req.Note))
+	SuccessResponse(c, product)
+}
+
+// RejectProduct 审核驳回
+// @Summary 驳回商品
+// @Description 管理员驳回商家提交的商品，商品回到草稿状态，驳回原因通知商家
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param review body ReviewProductRequest true "驳回原因"
+// @Success 200 {object} ApiResponse{data=Product} "已驳回"
+// @Failure 400 {object} ApiResponse "缺少驳回原因或商品不是待审核状态"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/admin/products/{id}/reject [put]
+func RejectProduct(c *gin.Context) {
+	var req ReviewProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Note == "" {
+		BadRequestError(c, "请填写驳回原因")
+		return
+	}
+
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if product.PublishStatus != ProductStatePendingReview {
+		BadRequestError(c, "只有待审核的商品可以审核")
+		return
+	}
+	if !changeProductState(c, product, ProductStateDraft, req.Note) {
+		return
+	}
+
+	notifyProductSeller(product, "商品审核未通过", fmt.Sprintf("您提交的商品「%s」未通过审核：%s", product.Name, req.Note))
+	SuccessResponse(c, product)
+}
+
+// PublishProduct 直接发布商品
+// @Summary 发布商品
+// @Description 管理员直接发布草稿或已归档的商品，无需经过审核
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=Product} "发布成功"
+// @Failure 400 {object} ApiResponse "商品状态不允许发布"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/admin/products/{id}/publish [put]
+func PublishProduct(c *gin.Context) {
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+	if changeProductState(c, product, ProductStatePublished, "") {
+		SuccessResponse(c, product)
+	}
+}
+
+// ScheduleProduct 设置定时上下架
+// @Summary 设置定时上下架
+// @Description 设置商品的定时上架和下架时间，到点后自动发布或归档并刷新缓存，可提前准备促销商品；定时上架视为审核通过，草稿、待审核和已归档的商品均可设置
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param schedule body ScheduleProductRequest true "定时设置"
+// @Success 200 {object} ApiResponse{data=Product} "设置成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或时间无效"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Security Bearer
+// @Router /api/admin/products/{id}/schedule [put]
+func ScheduleProduct(c *gin.Context) {
+	var req ScheduleProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	product, ok := loadManagedProduct(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if req.PublishAt != nil && !req.PublishAt.After(now) {
+		BadRequestError(c, "上架时间必须晚于当前时间")
+		return
+	}
+	if req.UnpublishAt != nil && !req.UnpublishAt.After(now) {
+		BadRequestError(c, "下架时间必须晚于当前时间")
+		return
+	}
+	if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		BadRequestError(c, "下架时间必须晚于上架时间")
+		return
+	}
+	if req.PublishAt != nil && product.PublishStatus == ProductStatePublished {
+		BadRequestError(c, "商品已发布，无需定时上架")
+		return
+	}
+
+	if err := DB.Model(product).Updates(map[string]interface{}{
+		"publish_at":   req.PublishAt,
+		"unpublish_at": req.UnpublishAt,
+	}).Error; err != nil {
+		InternalServerError(c, "定时设置保存失败")
+		return
+	}
+	invalidateProductCaches(product.ID)
+
+	DB.Preload("Category").Preload("Brand").First(product, product.ID)
+	SuccessResponse(c, product)
+}
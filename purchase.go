@@ -0,0 +1,529 @@
+package gomall
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 采购单状态
+const (
+	PurchaseStatusDraft    = "draft"    // 草稿，可修改和删除
+	PurchaseStatusOrdered  = "ordered"  // 已向供应商下单，等待收货
+	PurchaseStatusReceived = "received" // 已收货入库
+)
+
+// SupplierRequest 创建或更新供应商请求
+type SupplierRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	ContactName string `json:"contact_name" binding:"max=50"`
+	Phone       string `json:"phone" binding:"max=20"`
+	Email       string `json:"email" binding:"omitempty,email,max=100"`
+	Address     string `json:"address" binding:"max=255"`
+	Status      *int   `json:"status" binding:"omitempty,oneof=0 1"`
+}
+
+// PurchaseOrderItemRequest 采购商品
+type PurchaseOrderItemRequest struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitCost  float64 `json:"unit_cost" binding:"min=0"`
+}
+
+// PurchaseOrderRequest 创建或修改采购单请求
+type PurchaseOrderRequest struct {
+	SupplierID  uint                       `json:"supplier_id" binding:"required"`
+	WarehouseID *uint                      `json:"warehouse_id"` // 收货仓库，商品分仓管理时必填
+	Note        string                     `json:"note"`
+	Items       []PurchaseOrderItemRequest `json:"items" binding:"required,min=1,max=200,dive"`
+}
+
+// 生成采购单号
+func generatePurchaseOrderNumber() string {
+	return fmt.Sprintf("PO%d%04d", time.Now().Unix(), rand.Intn(9999))
+}
+
+// 校验采购单的供应商、仓库和商品，返回采购商品和采购总额
+func buildPurchaseOrderItems(req *PurchaseOrderRequest) ([]PurchaseOrderItem, float64, error) {
+	var supplier Supplier
+	if err := DB.First(&supplier, req.SupplierID).Error; err != nil {
+		return nil, 0, fmt.Errorf("供应商不存在")
+	}
+	if supplier.Status != 1 {
+		return nil, 0, fmt.Errorf("供应商已停止合作")
+	}
+	if req.WarehouseID != nil {
+		var warehouse Warehouse
+		if err := DB.First(&warehouse, *req.WarehouseID).Error; err != nil {
+			return nil, 0, fmt.Errorf("仓库不存在")
+		}
+	}
+
+	items := make([]PurchaseOrderItem, 0, len(req.Items))
+	seen := make(map[uint]bool, len(req.Items))
+	var totalCents int64
+	for _, item := range req.Items {
+		if seen[item.ProductID] {
+			return nil, 0, fmt.Errorf("商品 %d 重复", item.ProductID)
+		}
+		seen[item.ProductID] = true
+
+		var product Product
+		if err := DB.Select("id").First(&product, item.ProductID).Error; err != nil {
+			return nil, 0, fmt.Errorf("商品 %d 不存在", item.ProductID)
+		}
+		items = append(items, PurchaseOrderItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitCost:  item.UnitCost,
+		})
+		totalCents += toCents(item.UnitCost) * int64(item.Quantity)
+	}
+	return items, fromCents(totalCents), nil
+}
+
+// 查询采购单，不存在时返回404
+func findPurchaseOrder(c *gin.Context) (*PurchaseOrder, bool) {
+	purchaseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的采购单ID")
+		return nil, false
+	}
+	var purchase PurchaseOrder
+	if err := DB.Preload("Supplier").Preload("Items.Product").First(&purchase, purchaseID).Error; err != nil {
+		NotFoundError(c, "采购单不存在")
+		return nil, false
+	}
+	return &purchase, true
+}
+
+// GetSuppliers 获取供应商列表
+// @Summary 获取供应商列表
+// @Description 列出全部供应商
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Supplier} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/suppliers [get]
+func GetSuppliers(c *gin.Context) {
+	var suppliers []Supplier
+	if err := DB.Order("status DESC, id ASC").Find(&suppliers).Error; err != nil {
+		InternalServerError(c, "供应商查询失败")
+		return
+	}
+	SuccessResponse(c, suppliers)
+}
+
+// CreateSupplier 创建供应商
+// @Summary 创建供应商
+// @Description 新增供应商，之后可以对其创建采购单
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param supplier body SupplierRequest true "供应商信息"
+// @Success 200 {object} ApiResponse{data=Supplier} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/suppliers [post]
+func CreateSupplier(c *gin.Context) {
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	supplier := Supplier{
+		Name:        req.Name,
+		ContactName: req.ContactName,
+		Phone:       req.Phone,
+		Email:       req.Email,
+		Address:     req.Address,
+		Status:      1,
+	}
+	if err := DB.Create(&supplier).Error; err != nil {
+		InternalServerError(c, "供应商创建失败")
+		return
+	}
+
+	SuccessResponse(c, supplier)
+}
+
+// UpdateSupplier 更新供应商
+// @Summary 更新供应商
+// @Description 修改供应商信息；停止合作的供应商不能再创建采购单，已有采购单不受影响
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Param supplier body SupplierRequest true "供应商信息"
+// @Success 200 {object} ApiResponse{data=Supplier} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "供应商不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/suppliers/{id} [put]
+func UpdateSupplier(c *gin.Context) {
+	supplierID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的供应商ID")
+		return
+	}
+
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var supplier Supplier
+	if err := DB.First(&supplier, supplierID).Error; err != nil {
+		NotFoundError(c, "供应商不存在")
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":         req.Name,
+		"contact_name": req.ContactName,
+		"phone":        req.Phone,
+		"email":        req.Email,
+		"address":      req.Address,
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+	if err := DB.Model(&supplier).Updates(updates).Error; err != nil {
+		InternalServerError(c, "供应商更新失败")
+		return
+	}
+
+	DB.First(&supplier, supplierID)
+	SuccessResponse(c, supplier)
+}
+
+// GetPurchaseOrders 获取采购单列表
+// @Summary 获取采购单列表
+// @Description 按创建时间倒序列出采购单，可按状态和供应商筛选
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param status query string false "采购单状态" Enums(draft, ordered, received)
+// @Param supplier_id query int false "供应商ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]PurchaseOrder}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/purchase-orders [get]
+func GetPurchaseOrders(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&PurchaseOrder{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if value := c.Query("supplier_id"); value != "" {
+		if supplierID, err := strconv.ParseUint(value, 10, 32); err == nil {
+			query = query.Where("supplier_id = ?", supplierID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var purchases []PurchaseOrder
+	if err := query.Preload("Supplier").Preload("Items").Order("created_at DESC, id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&purchases).Error; err != nil {
+		InternalServerError(c, "采购单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, purchases, total, page, pageSize)
+}
+
+// GetPurchaseOrder 获取采购单详情
+// @Summary 获取采购单详情
+// @Description 获取采购单及其商品明细
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购单ID"
+// @Success 200 {object} ApiResponse{data=PurchaseOrder} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的采购单ID"
+// @Failure 404 {object} ApiResponse "采购单不存在"
+// @Security Bearer
+// @Router /api/admin/purchase-orders/{id} [get]
+func GetPurchaseOrder(c *gin.Context) {
+	purchase, ok := findPurchaseOrder(c)
+	if !ok {
+		return
+	}
+	SuccessResponse(c, purchase)
+}
+
+// CreatePurchaseOrder 创建采购单
+// @Summary 创建采购单
+// @Description 向供应商创建采购单草稿，同一商品只能出现一次；草稿确认下单后等待收货，收货时库存才会增加
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param purchase body PurchaseOrderRequest true "采购单信息"
+// @Success 200 {object} ApiResponse{data=PurchaseOrder} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或供应商、仓库、商品不可用"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/purchase-orders [post]
+func CreatePurchaseOrder(c *gin.Context) {
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	items, totalCost, err := buildPurchaseOrderItems(&req)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	purchase := PurchaseOrder{
+		PONo:        generatePurchaseOrderNumber(),
+		SupplierID:  req.SupplierID,
+		WarehouseID: req.WarehouseID,
+		Status:      PurchaseStatusDraft,
+		TotalCost:   totalCost,
+		Note:        req.Note,
+		CreatedBy:   c.GetUint("user_id"),
+		Items:       items,
+	}
+	if err := DB.Create(&purchase).Error; err != nil {
+		InternalServerError(c, "采购单创建失败")
+		return
+	}
+
+	DB.Preload("Supplier").Preload("Items.Product").First(&purchase, purchase.ID)
+	SuccessResponse(c, purchase)
+}
+
+// UpdatePurchaseOrder 修改采购单草稿
+// @Summary 修改采购单草稿
+// @Description 用请求内容整体替换草稿的供应商、收货仓库、备注和商品明细，已下单的采购单不能修改
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购单ID"
+// @Param purchase body PurchaseOrderRequest true "采购单信息"
+// @Success 200 {object} ApiResponse{data=PurchaseOrder} "修改成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或采购单不是草稿"
+// @Failure 404 {object} ApiResponse "采购单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/purchase-orders/{id} [put]
+func UpdatePurchaseOrder(c *gin.Context) {
+	purchase, ok := findPurchaseOrder(c)
+	if !ok {
+		return
+	}
+
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	items, totalCost, err := buildPurchaseOrderItems(&req)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 以状态作为并发保护，下单后不能再修改
+		result := tx.Model(&PurchaseOrder{}).
+			Where("id = ? AND status = ?", purchase.ID, PurchaseStatusDraft).
+			Updates(map[string]interface{}{
+				"supplier_id":  req.SupplierID,
+				"warehouse_id": req.WarehouseID,
+				"total_cost":   totalCost,
+				"note":         req.Note,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("只能修改草稿状态的采购单")
+		}
+		if err := tx.Where("purchase_order_id = ?", purchase.ID).Delete(&PurchaseOrderItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].PurchaseOrderID = purchase.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	DB.Preload("Supplier").Preload("Items.Product").First(purchase, purchase.ID)
+	SuccessResponse(c, purchase)
+}
+
+// DeletePurchaseOrder 删除采购单草稿
+// @Summary 删除采购单草稿
+// @Description 删除草稿状态的采购单，已下单的采购单不能删除
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购单ID"
+// @Success 200 {object} ApiResponse "删除成功"
+// @Failure 400 {object} ApiResponse "采购单不是草稿"
+// @Failure 404 {object} ApiResponse "采购单不存在"
+// @Security Bearer
+// @Router /api/admin/purchase-orders/{id} [delete]
+func DeletePurchaseOrder(c *gin.Context) {
+	purchase, ok := findPurchaseOrder(c)
+	if !ok {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND status = ?", purchase.ID, PurchaseStatusDraft).Delete(&PurchaseOrder{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("只能删除草稿状态的采购单")
+		}
+		return tx.Where("purchase_order_id = ?", purchase.ID).Delete(&PurchaseOrderItem{}).Error
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
+
+// SubmitPurchaseOrder 确认采购下单
+// @Summary 确认采购下单
+// @Description 草稿确认已向供应商下单，之后不能再修改，等待收货
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购单ID"
+// @Success 200 {object} ApiResponse{data=PurchaseOrder} "下单成功"
+// @Failure 400 {object} ApiResponse "采购单不是草稿"
+// @Failure 404 {object} ApiResponse "采购单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/purchase-orders/{id}/order [put]
+func SubmitPurchaseOrder(c *gin.Context) {
+	purchase, ok := findPurchaseOrder(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	result := DB.Model(&PurchaseOrder{}).
+		Where("id = ? AND status = ?", purchase.ID, PurchaseStatusDraft).
+		Updates(map[string]interface{}{"status": PurchaseStatusOrdered, "ordered_at": now})
+	if result.Error != nil {
+		InternalServerError(c, "采购单下单失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		BadRequestError(c, "只能对草稿状态的采购单下单")
+		return
+	}
+
+	purchase.Status = PurchaseStatusOrdered
+	purchase.OrderedAt = &now
+	SuccessResponse(c, purchase)
+}
+
+// ReceivePurchaseOrder 采购收货入库
+// @Summary 采购收货入库
+// @Description 确认已下单的采购单到货，在同一事务中将全部商品计入库存（分仓管理的商品计入采购单的收货仓库）并写入 purchase 类型的库存流水；任一商品入库失败时整单不生效，同一采购单不会重复入库
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购单ID"
+// @Success 200 {object} ApiResponse{data=PurchaseOrder} "收货成功"
+// @Failure 400 {object} ApiResponse "采购单不是已下单状态或商品入库失败"
+// @Failure 404 {object} ApiResponse "采购单不存在"
+// @Security Bearer
+// @Router /api/admin/purchase-orders/{id}/receive [put]
+func ReceivePurchaseOrder(c *gin.Context) {
+	purchase, ok := findPurchaseOrder(c)
+	if !ok {
+		return
+	}
+
+	// 按商品ID顺序入库，与下单时的锁定顺序一致
+	items := append([]PurchaseOrderItem(nil), purchase.Items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+
+	now := time.Now()
+	receivedBy := operatorID(c)
+	movement := InventoryMovement{
+		Type:       MovementTypePurchase,
+		OperatorID: receivedBy,
+		Note:       "采购单 " + purchase.PONo,
+	}
+	stockChanges := make(map[uint][2]int, len(items))
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&PurchaseOrder{}).
+			Where("id = ? AND status = ?", purchase.ID, PurchaseStatusOrdered).
+			Updates(map[string]interface{}{
+				"status":      PurchaseStatusReceived,
+				"received_at": now,
+				"received_by": receivedBy,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("只能对已下单的采购单收货")
+		}
+
+		for _, item := range items {
+			oldStock, newStock, err := adjustStock(tx, purchase.WarehouseID, item.ProductID, item.Quantity, movement)
+			if err != nil {
+				return err
+			}
+			stockChanges[item.ProductID] = [2]int{oldStock, newStock}
+		}
+		return nil
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	for productID, change := range stockChanges {
+		syncAdjustedStock(productID, change[0], change[1])
+	}
+
+	purchase.Status = PurchaseStatusReceived
+	purchase.ReceivedAt = &now
+	purchase.ReceivedBy = receivedBy
+	SuccessResponse(c, purchase)
+}
@@ -0,0 +1,72 @@
+package gomall
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 用户在未取消订单中已购买的商品数量
+func purchasedQuantities(db *gorm.DB, userID uint, productIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		ProductID uint
+		Quantity  int
+	}
+	err := db.Model(&OrderItem{}).
+		Select("order_items.product_id, SUM(order_items.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status <> ? AND order_items.product_id IN ?", userID, OrderStatusCancelled, productIDs).
+		Group("order_items.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	purchased := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		purchased[row.ProductID] = row.Quantity
+	}
+	return purchased, nil
+}
+
+// 再购买 quantity 件是否超出限购，超出时返回提示还能购买多少件的错误
+func purchaseLimitError(product *Product, purchased, quantity int) error {
+	if product.MaxPerUser <= 0 || purchased+quantity <= product.MaxPerUser {
+		return nil
+	}
+	remaining := product.MaxPerUser - purchased
+	if remaining <= 0 {
+		return fmt.Errorf("商品「%s」每人限购 %d 件，您已购买 %d 件，不能再购买", product.Name, product.MaxPerUser, purchased)
+	}
+	return fmt.Errorf("商品「%s」每人限购 %d 件，您已购买 %d 件，最多还能购买 %d 件", product.Name, product.MaxPerUser, purchased, remaining)
+}
+
+// 校验本次购买的数量加上历史购买数量不超过每人限购数量，quantities 为每个商品的购买数量。
+// 下单时在锁定商品行之后调用，同一用户并发下单时不会同时通过校验
+func checkPurchaseLimits(db *gorm.DB, userID uint, quantities map[uint]int) error {
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	var products []Product
+	if err := db.Select("id, name, max_per_user").Where("id IN ? AND max_per_user > 0", productIDs).Find(&products).Error; err != nil {
+		return fmt.Errorf("限购查询失败: %v", err)
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	limited := make([]uint, len(products))
+	for i, product := range products {
+		limited[i] = product.ID
+	}
+	purchased, err := purchasedQuantities(db, userID, limited)
+	if err != nil {
+		return fmt.Errorf("限购查询失败: %v", err)
+	}
+	for i := range products {
+		if err := purchaseLimitError(&products[i], purchased[products[i].ID], quantities[products[i].ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,517 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 询价单状态常量
+const (
+	QuoteStatusPending   = "pending"   // 待报价
+	QuoteStatusQuoted    = "quoted"    // 已报价
+	QuoteStatusAccepted  = "accepted"  // 已接受并下单
+	QuoteStatusRejected  = "rejected"  // 商家拒绝
+	QuoteStatusCancelled = "cancelled" // 买家取消
+	QuoteStatusExpired   = "expired"   // 报价已过期
+)
+
+// 报价默认有效期（小时）
+const defaultQuoteValidHours = 72
+
+// 询价请求结构体
+type CreateQuoteRequest struct {
+	ProductID uint   `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	Note      string `json:"note"`
+}
+
+type RespondQuoteRequest struct {
+	Price      float64 `json:"price" binding:"required,gt=0"`                 // 报价单价
+	ValidHours int     `json:"valid_hours" binding:"omitempty,min=1,max=720"` // 报价有效期（小时），默认72小时
+	Note       string  `json:"note"`
+}
+
+type RejectQuoteRequest struct {
+	Note string `json:"note"`
+}
+
+type AcceptQuoteRequest struct {
+	ShippingAddress string `json:"shipping_address" binding:"required"`
+}
+
+// 将已超过有效期的报价标记为过期
+func expireStaleQuotes() {
+	DB.Model(&Quote{}).
+		Where("status = ? AND valid_until < ?", QuoteStatusQuoted, time.Now()).
+		Update("status", QuoteStatusExpired)
+}
+
+// 解析分页参数
+func quotePagination(c *gin.Context) (int, int) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+	return page, pageSize
+}
+
+// 按报价条款创建订单，库存在事务外通过库存管理器预占
+func createOrderFromQuote(quote *Quote, shippingAddress string) (*Order, error) {
+	items := []shippingItem{{Product: &quote.Product, Quantity: quote.Quantity}}
+	if err := CheckShippingConstraints(items, shippingAddress); err != nil {
+		return nil, err
+	}
+	if err := GlobalStockManager.ReserveStock(quote.ProductID, quote.Quantity); err != nil {
+		return nil, err
+	}
+
+	// 按商品原价记录商品行，协议价差额作为调整项
+	trace := NewPricingTrace()
+	trace.AddLine(&quote.Product, quote.Product.Price, quote.Quantity)
+	trace.AddAdjustment(PricingAdjustmentQuote, fmt.Sprintf("quote:%d", quote.ID),
+		fmt.Sprintf("大宗询价协议单价 %.2f 元", quote.QuotedPrice),
+		(toCents(quote.QuotedPrice)-toCents(quote.Product.Price))*int64(quote.Quantity))
+	addShippingAdjustments(trace, items)
+	addTaxAdjustment(trace)
+
+	now := time.Now()
+	order := Order{
+		UserID:          quote.UserID,
+		OrderNo:         generateOrderNumber(),
+		Status:          OrderStatusPending,
+		StatusChangedAt: &now,
+		ShippingAddress: shippingAddress,
+		SellerID:        quote.Product.SellerID,
+	}
+	applyOrderAmounts(&order, trace)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 以状态作为并发保护，防止同一报价重复下单
+		result := tx.Model(&Quote{}).
+			Where("id = ? AND status = ?", quote.ID, QuoteStatusQuoted).
+			Update("status", QuoteStatusAccepted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("报价状态已变更")
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return fmt.Errorf("订单创建失败: %v", err)
+		}
+		if err := recordOrderEvent(tx, order.ID, OrderEventCreated, OrderActor{Role: RoleCustomer, UserID: quote.UserID},
+			fmt.Sprintf("接受大宗询价报价 #%d", quote.ID)); err != nil {
+			return err
+		}
+
+		orderItem := OrderItem{
+			OrderID:   order.ID,
+			ProductID: quote.ProductID,
+			Quantity:  quote.Quantity,
+			Price:     quote.QuotedPrice,
+			CostPrice: quote.Product.CostPrice,
+		}
+		applyItemFulfillment(&orderItem, &quote.Product)
+		specs, err := productSpecTexts(tx, []uint{quote.ProductID})
+		if err != nil {
+			return err
+		}
+		applyItemSnapshot(&orderItem, &quote.Product, specs)
+		if err := tx.Create(&orderItem).Error; err != nil {
+			return fmt.Errorf("订单项创建失败: %v", err)
+		}
+		if err := reserveStockInTx(tx, &order, map[uint]int{quote.ProductID: quote.Quantity}); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Quote{}).Where("id = ?", quote.ID).Update("order_id", order.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Product{}).Where("id = ?", quote.ProductID).
+			UpdateColumn("sales_count", gorm.Expr("sales_count + ?", quote.Quantity)).Error
+	})
+	if err != nil {
+		GlobalStockManager.ReleaseStock(quote.ProductID, quote.Quantity)
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// CreateQuote 发起询价
+// @Summary 发起大宗采购询价
+// @Description 买家提交商品及采购数量，等待商家给出专属报价；采购数量需达到询价起订量
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param quote body CreateQuoteRequest true "询价信息"
+// @Success 200 {object} ApiResponse{data=Quote} "提交成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或未达到起订量"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/quotes [post]
+func CreateQuote(c *gin.Context) {
+	var req CreateQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	if req.Quantity < AppConfig.QuoteMinQuantity {
+		BadRequestError(c, fmt.Sprintf("询价采购数量不能少于 %d 件", AppConfig.QuoteMinQuantity))
+		return
+	}
+
+	var product Product
+	if err := DB.First(&product, req.ProductID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+	if product.Status != 1 {
+		BadRequestError(c, "商品已下架")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	quote := Quote{
+		UserID:    userID.(uint),
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		BuyerNote: req.Note,
+		Status:    QuoteStatusPending,
+	}
+
+	if err := DB.Create(&quote).Error; err != nil {
+		InternalServerError(c, "询价提交失败")
+		return
+	}
+
+	DB.Preload("Product").First(&quote, quote.ID)
+	SuccessResponse(c, quote)
+}
+
+// GetMyQuotes 获取我的询价单
+// @Summary 获取我的询价单
+// @Description 分页获取当前用户的询价单，可按状态筛选
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param status query string false "询价单状态"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Quote}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/quotes [get]
+func GetMyQuotes(c *gin.Context) {
+	expireStaleQuotes()
+
+	userID, _ := c.Get("user_id")
+	page, pageSize := quotePagination(c)
+
+	query := DB.Model(&Quote{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var quotes []Quote
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Product").Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&quotes).Error; err != nil {
+		InternalServerError(c, "询价单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, quotes, total, page, pageSize)
+}
+
+// GetQuote 获取询价单详情
+// @Summary 获取询价单详情
+// @Description 获取当前用户的询价单详情及商家报价
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param id path int true "询价单ID"
+// @Success 200 {object} ApiResponse{data=Quote} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的询价单ID"
+// @Failure 404 {object} ApiResponse "询价单不存在"
+// @Security Bearer
+// @Router /api/quotes/{id} [get]
+func GetQuote(c *gin.Context) {
+	quoteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的询价单ID")
+		return
+	}
+
+	expireStaleQuotes()
+
+	userID, _ := c.Get("user_id")
+	var quote Quote
+	if err := DB.Preload("Product").Where("id = ? AND user_id = ?", quoteID, userID).First(&quote).Error; err != nil {
+		NotFoundError(c, "询价单不存在")
+		return
+	}
+
+	SuccessResponse(c, quote)
+}
+
+// AcceptQuote 接受报价并下单
+// @Summary 接受报价
+// @Description 在报价有效期内接受商家报价，按报价单价和数量生成待支付订单
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param id path int true "询价单ID"
+// @Param order body AcceptQuoteRequest true "收货信息"
+// @Success 200 {object} ApiResponse{data=Order} "下单成功"
+// @Failure 400 {object} ApiResponse "报价不可接受或库存不足"
+// @Failure 404 {object} ApiResponse "询价单不存在"
+// @Security Bearer
+// @Router /api/quotes/{id}/accept [post]
+func AcceptQuote(c *gin.Context) {
+	quoteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的询价单ID")
+		return
+	}
+
+	var req AcceptQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var quote Quote
+	if err := DB.Preload("Product").Where("id = ? AND user_id = ?", quoteID, userID).First(&quote).Error; err != nil {
+		NotFoundError(c, "询价单不存在")
+		return
+	}
+
+	if quote.Status != QuoteStatusQuoted {
+		BadRequestError(c, "当前询价单没有可接受的报价")
+		return
+	}
+	if quote.ValidUntil != nil && quote.ValidUntil.Before(time.Now()) {
+		DB.Model(&quote).Update("status", QuoteStatusExpired)
+		BadRequestError(c, "报价已过期")
+		return
+	}
+
+	order, err := createOrderFromQuote(&quote, req.ShippingAddress)
+	if err != nil {
+		BadRequestError(c, "下单失败: "+err.Error())
+		return
+	}
+
+	DB.Preload("OrderItems.Product").First(order, order.ID)
+	SuccessResponse(c, order)
+}
+
+// CancelQuote 取消询价
+// @Summary 取消询价
+// @Description 买家取消尚未下单的询价单
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param id path int true "询价单ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "取消成功"
+// @Failure 400 {object} ApiResponse "询价单状态不允许取消"
+// @Failure 404 {object} ApiResponse "询价单不存在"
+// @Security Bearer
+// @Router /api/quotes/{id} [delete]
+func CancelQuote(c *gin.Context) {
+	quoteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的询价单ID")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var quote Quote
+	if err := DB.Where("id = ? AND user_id = ?", quoteID, userID).First(&quote).Error; err != nil {
+		NotFoundError(c, "询价单不存在")
+		return
+	}
+
+	if quote.Status != QuoteStatusPending && quote.Status != QuoteStatusQuoted {
+		BadRequestError(c, "当前询价单状态不允许取消")
+		return
+	}
+
+	if err := DB.Model(&quote).Update("status", QuoteStatusCancelled).Error; err != nil {
+		InternalServerError(c, "询价单取消失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "询价单已取消"})
+}
+
+// GetMerchantQuotes 获取待处理的询价单（商家端）
+// @Summary 获取询价单列表（商家端）
+// @Description 商家分页查看买家提交的询价单，默认只看待报价的询价单
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param status query string false "询价单状态" default(pending)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Quote}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/seller/quotes [get]
+func GetMerchantQuotes(c *gin.Context) {
+	expireStaleQuotes()
+
+	page, pageSize := quotePagination(c)
+
+	status := c.DefaultQuery("status", QuoteStatusPending)
+	query := DB.Model(&Quote{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	var quotes []Quote
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Product").Order("created_at ASC").Limit(pageSize).Offset(offset).Find(&quotes).Error; err != nil {
+		InternalServerError(c, "询价单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, quotes, total, page, pageSize)
+}
+
+// RespondQuote 商家报价
+// @Summary 商家报价
+// @Description 商家对待报价的询价单给出专属单价和有效期，报价后通知买家；报价低于成本价时在 warnings 中提醒
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param id path int true "询价单ID"
+// @Param quote body RespondQuoteRequest true "报价信息"
+// @Success 200 {object} ApiResponse{data=QuoteResponse} "报价成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或询价单状态不允许报价"
+// @Failure 404 {object} ApiResponse "询价单不存在"
+// @Security Bearer
+// @Router /api/seller/quotes/{id}/respond [put]
+func RespondQuote(c *gin.Context) {
+	quoteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的询价单ID")
+		return
+	}
+
+	var req RespondQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var quote Quote
+	if err := DB.Preload("Product").First(&quote, quoteID).Error; err != nil {
+		NotFoundError(c, "询价单不存在")
+		return
+	}
+
+	// 报价过期前允许商家修改报价
+	if quote.Status != QuoteStatusPending && quote.Status != QuoteStatusQuoted {
+		BadRequestError(c, "当前询价单状态不允许报价")
+		return
+	}
+
+	validHours := req.ValidHours
+	if validHours == 0 {
+		validHours = defaultQuoteValidHours
+	}
+	validUntil := time.Now().Add(time.Duration(validHours) * time.Hour)
+
+	merchantID, _ := c.Get("user_id")
+	err = DB.Model(&quote).Updates(map[string]interface{}{
+		"status":        QuoteStatusQuoted,
+		"quoted_price":  req.Price,
+		"valid_until":   validUntil,
+		"merchant_note": req.Note,
+		"responded_by":  merchantID,
+	}).Error
+	if err != nil {
+		InternalServerError(c, "报价失败")
+		return
+	}
+
+	SendNotification(quote.UserID, NotificationTypeOrder, "您的询价已收到报价",
+		fmt.Sprintf("商品「%s」%d件的报价单价为 %.2f 元，有效期至 %s", quote.Product.Name, quote.Quantity, req.Price, validUntil.Format("2006-01-02 15:04")))
+
+	DB.Preload("Product").First(&quote, quote.ID)
+	SuccessResponse(c, QuoteResponse{Quote: quote, Warnings: belowCostWarnings(&quote.Product, req.Price, "报价单价")})
+}
+
+// RejectQuote 商家拒绝询价
+// @Summary 商家拒绝询价
+// @Description 商家拒绝待报价的询价单并通知买家
+// @Tags 大宗询价
+// @Accept json
+// @Produce json
+// @Param id path int true "询价单ID"
+// @Param reason body RejectQuoteRequest false "拒绝说明"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "拒绝成功"
+// @Failure 400 {object} ApiResponse "询价单状态不允许拒绝"
+// @Failure 404 {object} ApiResponse "询价单不存在"
+// @Security Bearer
+// @Router /api/seller/quotes/{id}/reject [put]
+func RejectQuote(c *gin.Context) {
+	quoteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的询价单ID")
+		return
+	}
+
+	var req RejectQuoteRequest
+	c.ShouldBindJSON(&req)
+
+	var quote Quote
+	if err := DB.Preload("Product").First(&quote, quoteID).Error; err != nil {
+		NotFoundError(c, "询价单不存在")
+		return
+	}
+
+	if quote.Status != QuoteStatusPending {
+		BadRequestError(c, "只有待报价的询价单可以拒绝")
+		return
+	}
+
+	merchantID, _ := c.Get("user_id")
+	err = DB.Model(&quote).Updates(map[string]interface{}{
+		"status":        QuoteStatusRejected,
+		"merchant_note": req.Note,
+		"responded_by":  merchantID,
+	}).Error
+	if err != nil {
+		InternalServerError(c, "询价单更新失败")
+		return
+	}
+
+	SendNotification(quote.UserID, NotificationTypeOrder, "您的询价未能报价",
+		fmt.Sprintf("商品「%s」%d件的询价已被商家拒绝。%s", quote.Product.Name, quote.Quantity, req.Note))
+
+	SuccessResponse(c, gin.H{"message": "已拒绝询价"})
+}
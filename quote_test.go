@@ -0,0 +1,41 @@
+package gomall_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 接受报价生成的订单与拆单后的子订单一样归属于商品所属商家
+func TestAcceptQuoteSetsOrderSeller(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+	product := kit.CreateProduct(f.Seller.ID, gomall.Product{Name: "大宗商品", Price: 20, Stock: 500})
+
+	quote := gomall.Quote{
+		UserID:      f.Customer.ID,
+		ProductID:   product.ID,
+		Quantity:    100,
+		Status:      gomall.QuoteStatusQuoted,
+		QuotedPrice: 15,
+	}
+	if err := kit.DB.Create(&quote).Error; err != nil {
+		t.Fatalf("询价单创建失败: %v", err)
+	}
+
+	path := "/api/quotes/" + strconv.FormatUint(uint64(quote.ID), 10) + "/accept"
+	resp := kit.DoAs(f.Customer, http.MethodPost, path, gomall.AcceptQuoteRequest{ShippingAddress: "北京市朝阳区测试路1号"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("接受报价返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+	}
+	var order gomall.Order
+	if err := resp.Decode(&order); err != nil {
+		t.Fatalf("响应解析失败: %v", err)
+	}
+	if order.SellerID == nil || *order.SellerID != f.Seller.ID {
+		t.Fatalf("订单所属商家为 %v，期望 %d", order.SellerID, f.Seller.ID)
+	}
+}
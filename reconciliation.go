@@ -0,0 +1,567 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 对账单来源
+const (
+	StatementSourceAPI    = "api"    // 支付渠道接口拉取
+	StatementSourceImport = "import" // 上传对账文件
+)
+
+// 对账差异类型
+const (
+	DiscrepancyMissingLocal   = "missing_local"   // 渠道有收款，本地没有对应的支付单
+	DiscrepancyMissingRemote  = "missing_remote"  // 本地支付成功，渠道对账单中没有交易
+	DiscrepancyAmountMismatch = "amount_mismatch" // 金额不一致
+	DiscrepancyStatusMismatch = "status_mismatch" // 渠道已收款，本地支付单未成功
+)
+
+// 对账差异状态
+const (
+	DiscrepancyStatusOpen     = "open"     // 待处理
+	DiscrepancyStatusResolved = "resolved" // 已处理
+)
+
+// 单个对账文件的最大行数
+const statementImportMaxRows = 50000
+
+// RunReconciliationRequest 手动对账请求
+type RunReconciliationRequest struct {
+	Channel string `json:"channel" binding:"required"`
+	Date    string `json:"date" binding:"required"` // 对账日期（YYYY-MM-DD）
+}
+
+// ResolveDiscrepancyRequest 处理对账差异请求
+type ResolveDiscrepancyRequest struct {
+	Note string `json:"note" binding:"required,max=500"` // 处理说明，如已补单、已退款
+}
+
+// 解析对账日期，只能对账今天之前的日期
+func parseStatementDate(value string) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("对账日期格式错误，应为YYYY-MM-DD")
+	}
+	today := time.Now()
+	if !day.Before(time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)) {
+		return time.Time{}, fmt.Errorf("只能对账今天之前的日期")
+	}
+	return day, nil
+}
+
+// 核对支付渠道一天的对账单和本地支付单，记录对账结果和差异。同一渠道同一天重新对账时
+// 覆盖上次的结果，未处理的差异重新生成，已处理的差异不会重复出现
+func reconcilePayments(channel string, day time.Time, source string, entries []StatementEntry) (*ReconciliationRun, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 0, 1)
+
+	// 本地当天支付成功的支付单，之后退回钱包的支付单当时也已收款
+	var payments []Payment
+	err := DB.Where("channel = ? AND status IN ? AND paid_at >= ? AND paid_at < ?",
+		channel, []string{PaymentStatusSucceeded, PaymentStatusRefunded}, start, end).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	byTradeNo := make(map[string]*Payment)
+	byPaymentNo := make(map[string]*Payment)
+	run := ReconciliationRun{Channel: channel, StatementDate: start, Source: source, LocalCount: len(payments)}
+	var localCents, remoteCents int64
+	for i := range payments {
+		payment := &payments[i]
+		localCents += toCents(payment.Amount)
+		if payment.TradeNo != "" {
+			byTradeNo[payment.TradeNo] = payment
+		}
+		byPaymentNo[payment.PaymentNo] = payment
+	}
+
+	matched := make(map[uint]bool)
+	var discrepancies []PaymentDiscrepancy
+	for _, entry := range entries {
+		remoteCents += entry.AmountCents
+		payment := byTradeNo[entry.TradeNo]
+		if payment == nil && entry.PaymentNo != "" {
+			payment = byPaymentNo[entry.PaymentNo]
+		}
+		if payment == nil {
+			// 不在当天的本地记录中：可能是跨天的交易，或本地没有记录收款
+			var other Payment
+			found := false
+			if entry.TradeNo != "" {
+				found = DB.Where("channel = ? AND trade_no = ?", channel, entry.TradeNo).Limit(1).Find(&other).RowsAffected > 0
+			}
+			if !found && entry.PaymentNo != "" {
+				found = DB.Where("channel = ? AND payment_no = ?", channel, entry.PaymentNo).Limit(1).Find(&other).RowsAffected > 0
+			}
+			if !found {
+				discrepancies = append(discrepancies, PaymentDiscrepancy{
+					Type:         DiscrepancyMissingLocal,
+					PaymentNo:    entry.PaymentNo,
+					TradeNo:      entry.TradeNo,
+					RemoteAmount: fromCents(entry.AmountCents),
+				})
+				continue
+			}
+			if other.Status != PaymentStatusSucceeded && other.Status != PaymentStatusRefunded {
+				discrepancies = append(discrepancies, PaymentDiscrepancy{
+					Type:         DiscrepancyStatusMismatch,
+					PaymentID:    &other.ID,
+					PaymentNo:    other.PaymentNo,
+					TradeNo:      entry.TradeNo,
+					LocalAmount:  other.Amount,
+					RemoteAmount: fromCents(entry.AmountCents),
+				})
+				continue
+			}
+			payment = &other
+		}
+
+		matched[payment.ID] = true
+		if toCents(payment.Amount) != entry.AmountCents {
+			discrepancies = append(discrepancies, PaymentDiscrepancy{
+				Type:         DiscrepancyAmountMismatch,
+				PaymentID:    &payment.ID,
+				PaymentNo:    payment.PaymentNo,
+				TradeNo:      entry.TradeNo,
+				LocalAmount:  payment.Amount,
+				RemoteAmount: fromCents(entry.AmountCents),
+			})
+			continue
+		}
+		run.MatchedCount++
+	}
+	for i := range payments {
+		payment := &payments[i]
+		if matched[payment.ID] {
+			continue
+		}
+		discrepancies = append(discrepancies, PaymentDiscrepancy{
+			Type:        DiscrepancyMissingRemote,
+			PaymentID:   &payment.ID,
+			PaymentNo:   payment.PaymentNo,
+			TradeNo:     payment.TradeNo,
+			LocalAmount: payment.Amount,
+		})
+	}
+	run.RemoteCount = len(entries)
+	run.RemoteAmount = fromCents(remoteCents)
+	run.LocalAmount = fromCents(localCents)
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var existing ReconciliationRun
+		if tx.Where("channel = ? AND statement_date = ?", channel, start).Limit(1).Find(&existing).RowsAffected > 0 {
+			run.ID = existing.ID
+			run.CreatedAt = existing.CreatedAt
+		}
+		if err := tx.Save(&run).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("run_id = ? AND status = ?", run.ID, DiscrepancyStatusOpen).Delete(&PaymentDiscrepancy{}).Error; err != nil {
+			return err
+		}
+
+		var resolved []PaymentDiscrepancy
+		if err := tx.Where("run_id = ? AND status = ?", run.ID, DiscrepancyStatusResolved).Find(&resolved).Error; err != nil {
+			return err
+		}
+		handled := make(map[string]bool)
+		for _, d := range resolved {
+			handled[d.Type+"|"+d.PaymentNo+"|"+d.TradeNo] = true
+		}
+		run.DiscrepancyCount = 0
+		for i := range discrepancies {
+			d := &discrepancies[i]
+			if handled[d.Type+"|"+d.PaymentNo+"|"+d.TradeNo] {
+				continue
+			}
+			d.RunID = run.ID
+			d.Channel = channel
+			d.StatementDate = start
+			d.Status = DiscrepancyStatusOpen
+			if err := tx.Create(d).Error; err != nil {
+				return err
+			}
+			run.DiscrepancyCount++
+		}
+		return tx.Model(&run).Update("discrepancy_count", run.DiscrepancyCount).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// 通过支付渠道接口拉取对账单并对账
+func reconcileChannel(channel string, day time.Time) (*ReconciliationRun, error) {
+	gateway, ok := PaymentGateways[channel]
+	if !ok {
+		return nil, fmt.Errorf("支付渠道 %s 未启用", channel)
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	entries, err := gateway.FetchStatement(start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return reconcilePayments(channel, start, StatementSourceAPI, entries)
+}
+
+// 每日对账：拉取各支付渠道前一天的对账单，有差异时通知管理员
+func runDailyReconciliation(day time.Time) {
+	for channel := range PaymentGateways {
+		run, err := reconcileChannel(channel, day)
+		if errors.Is(err, errStatementUnavailable) {
+			continue
+		}
+		if err != nil {
+			log.Printf("%s %s 支付对账失败: %v", channel, day.Format("2006-01-02"), err)
+			continue
+		}
+		log.Printf("%s %s 支付对账完成：对账单 %d 笔，本地 %d 笔，差异 %d 笔",
+			channel, day.Format("2006-01-02"), run.RemoteCount, run.LocalCount, run.DiscrepancyCount)
+		if run.DiscrepancyCount > 0 {
+			SendNotifications(adminUserIDs(), NotificationTypeSystem, "支付对账差异",
+				fmt.Sprintf("%s %s 对账发现 %d 笔差异，请及时核查处理。", channel, day.Format("2006-01-02"), run.DiscrepancyCount))
+		}
+	}
+}
+
+// StartPaymentReconciliationScheduler 启动支付对账任务，每天在配置的时刻对账前一天的支付
+func StartPaymentReconciliationScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			if now.Hour() == AppConfig.PaymentReconcileHour {
+				// 多实例部署时只由一个实例对账
+				key := "payments:reconciled:" + now.Format("20060102")
+				if ok, _ := RDB.SetNX(CTX, key, 1, 25*time.Hour).Result(); ok {
+					runDailyReconciliation(now.AddDate(0, 0, -1))
+				}
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("支付对账任务已启动")
+}
+
+// RunReconciliation 手动对账
+// @Summary 手动对账
+// @Description 通过支付渠道接口拉取指定日期的对账单并与本地支付单核对，覆盖该渠道当天之前的对账结果；不支持拉取对账单的渠道需上传对账文件
+// @Tags 支付对账
+// @Accept json
+// @Produce json
+// @Param request body RunReconciliationRequest true "支付渠道和对账日期"
+// @Success 200 {object} ApiResponse{data=ReconciliationRun} "对账完成"
+// @Failure 400 {object} ApiResponse "参数验证失败、支付渠道未启用或不支持拉取对账单"
+// @Failure 500 {object} ApiResponse "对账单拉取失败"
+// @Security Bearer
+// @Router /api/admin/reconciliation/runs [post]
+func RunReconciliation(c *gin.Context) {
+	var req RunReconciliationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	day, err := parseStatementDate(req.Date)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if _, ok := PaymentGateways[req.Channel]; !ok {
+		BadRequestError(c, "支付渠道未启用")
+		return
+	}
+
+	run, err := reconcileChannel(req.Channel, day)
+	if errors.Is(err, errStatementUnavailable) {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if err != nil {
+		InternalServerError(c, "对账失败: "+err.Error())
+		return
+	}
+
+	recordAdminAudit(c, "payment.reconcile", "reconciliation", run.ID, req.Channel+" "+req.Date)
+	SuccessResponse(c, run)
+}
+
+// ImportPaymentStatement 上传对账文件
+// @Summary 上传对账文件
+// @Description 上传支付渠道的对账文件（CSV或XLSX）并与本地支付单核对。表头需包含 trade_no（渠道交易号）和 amount（收款金额，元），可选 payment_no（商户支付单号）；覆盖该渠道当天之前的对账结果
+// @Tags 支付对账
+// @Accept multipart/form-data
+// @Produce json
+// @Param channel formData string true "支付渠道"
+// @Param date formData string true "对账日期（YYYY-MM-DD）"
+// @Param file formData file true "对账文件"
+// @Success 200 {object} ApiResponse{data=ReconciliationRun} "对账完成"
+// @Failure 400 {object} ApiResponse "参数错误或文件格式错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/reconciliation/import [post]
+func ImportPaymentStatement(c *gin.Context) {
+	channel := c.PostForm("channel")
+	if channel == "" || channel == PaymentChannelWallet {
+		BadRequestError(c, "请选择支付渠道")
+		return
+	}
+	day, err := parseStatementDate(c.PostForm("date"))
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	var headers []string
+	var entries []StatementEntry
+	err = readSheetRows(c, func(line int, values []string) error {
+		if headers == nil {
+			headers = make([]string, len(values))
+			for i, value := range values {
+				headers[i] = strings.ToLower(strings.TrimSpace(value))
+			}
+			if !containsString(headers, "trade_no") || !containsString(headers, "amount") {
+				return fmt.Errorf("表头必须包含 trade_no 和 amount 列")
+			}
+			return nil
+		}
+
+		row := make(map[string]string, len(headers))
+		empty := true
+		for i, header := range headers {
+			if i < len(values) {
+				row[header] = strings.TrimSpace(values[i])
+				empty = empty && row[header] == ""
+			}
+		}
+		if empty {
+			return nil
+		}
+		if len(entries) >= statementImportMaxRows {
+			return fmt.Errorf("单个对账文件最多%d行", statementImportMaxRows)
+		}
+		amount, err := strconv.ParseFloat(row["amount"], 64)
+		if err != nil {
+			return fmt.Errorf("第%d行金额格式错误", line)
+		}
+		if row["trade_no"] == "" && row["payment_no"] == "" {
+			return fmt.Errorf("第%d行缺少交易号", line)
+		}
+		entries = append(entries, StatementEntry{
+			PaymentNo:   row["payment_no"],
+			TradeNo:     row["trade_no"],
+			AmountCents: toCents(amount),
+		})
+		return nil
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if headers == nil {
+		BadRequestError(c, "对账文件为空")
+		return
+	}
+
+	run, err := reconcilePayments(channel, day, StatementSourceImport, entries)
+	if err != nil {
+		InternalServerError(c, "对账失败")
+		return
+	}
+
+	recordAdminAudit(c, "payment.reconcile_import", "reconciliation", run.ID, fmt.Sprintf("%s %s，%d 笔", channel, day.Format("2006-01-02"), len(entries)))
+	SuccessResponse(c, run)
+}
+
+// GetReconciliationRuns 获取对账记录
+// @Summary 获取对账记录
+// @Description 按对账日期从新到旧列出各支付渠道的对账结果，包括对账单与本地的笔数、金额和差异数
+// @Tags 支付对账
+// @Accept json
+// @Produce json
+// @Param channel query string false "支付渠道"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]ReconciliationRun}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/reconciliation/runs [get]
+func GetReconciliationRuns(c *gin.Context) {
+	page := 1
+	pageSize := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&ReconciliationRun{})
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []ReconciliationRun
+	if err := query.Order("statement_date DESC, channel").Limit(pageSize).Offset((page - 1) * pageSize).Find(&runs).Error; err != nil {
+		InternalServerError(c, "对账记录查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, runs, total, page, pageSize)
+}
+
+// GetPaymentDiscrepancies 获取对账差异报告
+// @Summary 获取对账差异报告
+// @Description 财务核查对账差异：missing_local 渠道有收款本地无支付单，missing_remote 本地支付成功渠道无交易，amount_mismatch 金额不一致，status_mismatch 渠道已收款本地未成功。默认只返回待处理的差异
+// @Tags 支付对账
+// @Accept json
+// @Produce json
+// @Param channel query string false "支付渠道"
+// @Param start_date query string false "对账开始日期（YYYY-MM-DD）"
+// @Param end_date query string false "对账结束日期（YYYY-MM-DD，含当天）"
+// @Param type query string false "差异类型" Enums(missing_local, missing_remote, amount_mismatch, status_mismatch)
+// @Param status query string false "处理状态" Enums(open, resolved, all) default(open)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]PaymentDiscrepancy}} "查询成功"
+// @Failure 400 {object} ApiResponse "日期格式错误"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/reconciliation/discrepancies [get]
+func GetPaymentDiscrepancies(c *gin.Context) {
+	page := 1
+	pageSize := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&PaymentDiscrepancy{})
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if value := c.Query("start_date"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "开始日期格式错误，应为YYYY-MM-DD")
+			return
+		}
+		query = query.Where("statement_date >= ?", date)
+	}
+	if value := c.Query("end_date"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			BadRequestError(c, "结束日期格式错误，应为YYYY-MM-DD")
+			return
+		}
+		query = query.Where("statement_date <= ?", date)
+	}
+	if discrepancyType := c.Query("type"); discrepancyType != "" {
+		query = query.Where("type = ?", discrepancyType)
+	}
+	if status := c.DefaultQuery("status", DiscrepancyStatusOpen); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var discrepancies []PaymentDiscrepancy
+	if err := query.Order("statement_date DESC, id").Limit(pageSize).Offset((page - 1) * pageSize).Find(&discrepancies).Error; err != nil {
+		InternalServerError(c, "对账差异查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, discrepancies, total, page, pageSize)
+}
+
+// ResolvePaymentDiscrepancy 处理对账差异
+// @Summary 处理对账差异
+// @Description 财务核查并处理差异（如补单、退款或确认为跨天交易）后标记为已处理，重新对账时不会再次出现
+// @Tags 支付对账
+// @Accept json
+// @Produce json
+// @Param id path int true "差异ID"
+// @Param request body ResolveDiscrepancyRequest true "处理说明"
+// @Success 200 {object} ApiResponse{data=PaymentDiscrepancy} "处理成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或差异已处理"
+// @Failure 404 {object} ApiResponse "差异不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/reconciliation/discrepancies/{id}/resolve [put]
+func ResolvePaymentDiscrepancy(c *gin.Context) {
+	discrepancyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的差异ID")
+		return
+	}
+	var req ResolveDiscrepancyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	var discrepancy PaymentDiscrepancy
+	if err := DB.First(&discrepancy, discrepancyID).Error; err != nil {
+		NotFoundError(c, "差异不存在")
+		return
+	}
+	if discrepancy.Status != DiscrepancyStatusOpen {
+		BadRequestError(c, "差异已处理")
+		return
+	}
+
+	now := time.Now()
+	adminID := c.GetUint("user_id")
+	result := DB.Model(&PaymentDiscrepancy{}).
+		Where("id = ? AND status = ?", discrepancy.ID, DiscrepancyStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      DiscrepancyStatusResolved,
+			"note":        req.Note,
+			"resolved_by": adminID,
+			"resolved_at": now,
+		})
+	if result.Error != nil {
+		InternalServerError(c, "差异处理失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		BadRequestError(c, "差异已处理")
+		return
+	}
+
+	recordAdminAudit(c, "payment.discrepancy_resolve", "payment_discrepancy", discrepancy.ID, req.Note)
+	discrepancy.Status = DiscrepancyStatusResolved
+	discrepancy.Note = req.Note
+	discrepancy.ResolvedBy = &adminID
+	discrepancy.ResolvedAt = &now
+	SuccessResponse(c, discrepancy)
+}
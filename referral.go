@@ -0,0 +1,154 @@
+package gomall
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 邀请状态
+const (
+	ReferralStatusPending  = "pending"  // 被邀请人尚未完成首单
+	ReferralStatusRewarded = "rewarded" // 已发放奖励
+)
+
+// 邀请详情中最多返回的邀请记录数
+const referralListLimit = 50
+
+// 邀请记录（被邀请人用户名脱敏）
+type ReferralRecord struct {
+	Username   string     `json:"username"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at"`
+}
+
+// 用户名脱敏，只保留首尾字符
+func maskUsername(username string) string {
+	runes := []rune(username)
+	switch {
+	case len(runes) == 0:
+		return ""
+	case len(runes) <= 2:
+		return string(runes[:1]) + "*"
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// 获取用户的邀请码，首次访问时生成
+func getOrCreateReferralCode(userID uint) (*ReferralCode, error) {
+	var code ReferralCode
+	if err := DB.Where("user_id = ?", userID).First(&code).Error; err == nil {
+		return &code, nil
+	}
+
+	// 邀请码较短，冲突时重新生成
+	for i := 0; i < 5; i++ {
+		token, err := generateSecureToken(4)
+		if err != nil {
+			return nil, err
+		}
+		code = ReferralCode{UserID: userID, Code: strings.ToUpper(token)}
+		if err := DB.Create(&code).Error; err == nil {
+			return &code, nil
+		}
+		// 并发请求可能已为该用户生成邀请码
+		if err := DB.Where("user_id = ?", userID).First(&code).Error; err == nil {
+			return &code, nil
+		}
+	}
+	return nil, fmt.Errorf("邀请码生成失败")
+}
+
+// 根据邀请码查找邀请人
+func findReferrer(code string) (uint, error) {
+	var referralCode ReferralCode
+	if err := DB.Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&referralCode).Error; err != nil {
+		return 0, fmt.Errorf("邀请码无效")
+	}
+	return referralCode.UserID, nil
+}
+
+// 被邀请人首笔订单支付后为双方发放邀请奖励，只发放一次
+func rewardReferral(tx *gorm.DB, order *Order) error {
+	var referral Referral
+	if err := tx.Where("referee_id = ? AND status = ?", order.UserID, ReferralStatusPending).First(&referral).Error; err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	result := tx.Model(&Referral{}).Where("id = ? AND status = ?", referral.ID, ReferralStatusPending).Updates(map[string]interface{}{
+		"status":      ReferralStatusRewarded,
+		"order_id":    order.ID,
+		"rewarded_at": now,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	if points := AppConfig.ReferralReferrerPoints; points > 0 {
+		if err := changePoints(tx, referral.ReferrerID, points, PointsTypeReferral, order.ID, "邀请好友完成首单奖励"); err != nil {
+			return err
+		}
+	}
+	if points := AppConfig.ReferralRefereePoints; points > 0 {
+		if err := changePoints(tx, referral.RefereeID, points, PointsTypeReferral, order.ID, "受邀注册完成首单奖励"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReferral 获取我的邀请信息
+// @Summary 获取我的邀请信息
+// @Description 获取当前用户的邀请码、邀请链接、奖励规则和邀请记录；被邀请人完成首笔支付订单后，双方获得积分奖励
+// @Tags 邀请
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{code=string,link=string,invited=int,rewarded=int,referrer_points=int,referee_points=int,referrals=[]ReferralRecord}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/referral [get]
+func GetReferral(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	code, err := getOrCreateReferralCode(userID.(uint))
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	var invited, rewarded int64
+	DB.Model(&Referral{}).Where("referrer_id = ?", userID).Count(&invited)
+	DB.Model(&Referral{}).Where("referrer_id = ? AND status = ?", userID, ReferralStatusRewarded).Count(&rewarded)
+
+	var referrals []Referral
+	if err := DB.Preload("Referee", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("referrer_id = ?", userID).Order("id DESC").Limit(referralListLimit).Find(&referrals).Error; err != nil {
+		InternalServerError(c, "邀请记录查询失败")
+		return
+	}
+
+	records := make([]ReferralRecord, 0, len(referrals))
+	for _, referral := range referrals {
+		records = append(records, ReferralRecord{
+			Username:   maskUsername(referral.Referee.Username),
+			Status:     referral.Status,
+			CreatedAt:  referral.CreatedAt,
+			RewardedAt: referral.RewardedAt,
+		})
+	}
+
+	SuccessResponse(c, gin.H{
+		"code":            code.Code,
+		"link":            siteURL("/register?ref=" + code.Code),
+		"invited":         invited,
+		"rewarded":        rewarded,
+		"referrer_points": AppConfig.ReferralReferrerPoints,
+		"referee_points":  AppConfig.ReferralRefereePoints,
+		"referrals":       records,
+	})
+}
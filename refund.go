@@ -0,0 +1,915 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 退款状态
+const (
+	RefundStatusPending    = "pending"    // 待审核
+	RefundStatusRejected   = "rejected"   // 已驳回
+	RefundStatusProcessing = "processing" // 退款中
+	RefundStatusSucceeded  = "succeeded"  // 已退款
+	RefundStatusFailed     = "failed"     // 退款失败，可重新审批
+)
+
+// 退款状态名称
+var refundStatusLabels = map[string]string{
+	RefundStatusPending:    "待审核",
+	RefundStatusRejected:   "已驳回",
+	RefundStatusProcessing: "退款中",
+	RefundStatusSucceeded:  "已退款",
+	RefundStatusFailed:     "退款失败",
+}
+
+// RefundChannelOffline 订单没有可原路退回的支付记录（如货到付款）时线下退款
+const RefundChannelOffline = "offline"
+
+var errRefundInProgress = errors.New("订单有未完成的退款，请等待处理完成后再申请")
+
+// 退款商品或金额校验失败，返回给用户
+type refundPlanError struct{ err error }
+
+func (e *refundPlanError) Error() string { return e.err.Error() }
+
+// CreateRefundRequest 申请退款请求
+type CreateRefundRequest struct {
+	Items  []RefundItemRequest `json:"items" binding:"omitempty,dive"` // 退款商品，为空时退回订单剩余的全部商品和金额
+	Reason string              `json:"reason" binding:"required,max=500"`
+}
+
+// RefundItemRequest 退款商品
+type RefundItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+// ApproveRefundRequest 审批通过退款请求
+type ApproveRefundRequest struct {
+	Restock  bool   `json:"restock"`   // 已收到退货，退款后商品重新入库
+	ToWallet bool   `json:"to_wallet"` // 退回钱包余额而不原路退回
+	Note     string `json:"note" binding:"max=500"`
+}
+
+// RejectRefundRequest 驳回退款请求
+type RejectRefundRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+func generateRefundNumber() string {
+	return fmt.Sprintf("RF%s%06d", time.Now().Format("20060102150405"), rand.Intn(1000000))
+}
+
+// 订单各商品已申请退款的数量和已申请的退款金额，被驳回的退款不计入
+func orderRefunded(tx *gorm.DB, orderID uint) (map[uint]int, int64, error) {
+	var refunds []Refund
+	err := tx.Preload("Items").
+		Where("order_id = ? AND status <> ?", orderID, RefundStatusRejected).
+		Find(&refunds).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	quantities := make(map[uint]int)
+	var amountCents int64
+	for _, refund := range refunds {
+		amountCents += toCents(refund.Amount)
+		for _, item := range refund.Items {
+			quantities[item.OrderItemID] += item.Quantity
+		}
+	}
+	return quantities, amountCents, nil
+}
+
+// 计算退款商品和金额。商品退款金额按商品金额占比分摊订单实付金额（不含运费），
+// 退回订单剩余的全部商品时退回剩余的全部金额（含运费）
+func planRefund(tx *gorm.DB, order *Order, requested []RefundItemRequest) ([]RefundItem, float64, error) {
+	refundedQuantities, refundedCents, err := orderRefunded(tx, order.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	remainingCents := toCents(order.TotalAmount) - refundedCents
+	if remainingCents <= 0 {
+		return nil, 0, &refundPlanError{errors.New("订单已全额退款")}
+	}
+
+	itemsByID := make(map[uint]OrderItem)
+	var subtotalCents int64
+	for _, item := range order.OrderItems {
+		itemsByID[item.ID] = item
+		subtotalCents += toCents(item.Price) * int64(item.Quantity)
+	}
+
+	// 未指定商品时退回全部剩余商品
+	if len(requested) == 0 {
+		for _, item := range order.OrderItems {
+			if remaining := item.Quantity - refundedQuantities[item.ID]; remaining > 0 {
+				requested = append(requested, RefundItemRequest{OrderItemID: item.ID, Quantity: remaining})
+			}
+		}
+	}
+
+	quantities := make(map[uint]int)
+	for _, req := range requested {
+		quantities[req.OrderItemID] += req.Quantity
+	}
+	whole := true
+	for _, item := range order.OrderItems {
+		if quantities[item.ID] != item.Quantity-refundedQuantities[item.ID] {
+			whole = false
+		}
+	}
+
+	payableCents := toCents(order.TotalAmount) - toCents(order.ShippingFee)
+	var items []RefundItem
+	var amountCents int64
+	for _, req := range requested {
+		item, ok := itemsByID[req.OrderItemID]
+		if !ok {
+			return nil, 0, &refundPlanError{fmt.Errorf("订单商品 %d 不存在", req.OrderItemID)}
+		}
+		if remaining := item.Quantity - refundedQuantities[item.ID]; quantities[item.ID] > remaining {
+			return nil, 0, &refundPlanError{fmt.Errorf("商品 %s 最多还可退 %d 件", item.ProductName, remaining)}
+		}
+		var cents int64
+		if subtotalCents > 0 && payableCents > 0 {
+			cents = toCents(item.Price) * int64(req.Quantity) * payableCents / subtotalCents
+		}
+		amountCents += cents
+		items = append(items, RefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    req.Quantity,
+			Amount:      fromCents(cents),
+		})
+	}
+	if whole || amountCents > remainingCents {
+		amountCents = remainingCents
+	}
+	return items, fromCents(amountCents), nil
+}
+
+// 按订单ID加载订单，普通用户只能访问自己的订单
+func loadRefundOrder(c *gin.Context) (*Order, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的订单ID")
+		return nil, false
+	}
+	query := DB.Where("id = ?", orderID)
+	if !HasRole(c, RoleAdmin) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order Order
+	if err := query.First(&order).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return nil, false
+	}
+	return &order, true
+}
+
+// RequestRefund 申请退款
+// @Summary 申请退款
+// @Description 为已付款的订单申请退款，提交后等待管理员审核。未发货的订单只能整单退款；已发货的订单可以按商品部分退款，退款金额按商品金额占比分摊实付金额，退回全部剩余商品时包含运费。订单有未完成的退款时不能再次申请
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Param refund body CreateRefundRequest true "退款商品和原因"
+// @Success 200 {object} ApiResponse{data=Refund} "申请成功"
+// @Failure 400 {object} ApiResponse "参数验证失败、订单未付款或退款商品数量超出"
+// @Failure 403 {object} ApiResponse "只有下单用户可以申请退款"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 409 {object} ApiResponse "订单有未完成的退款"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/refunds [post]
+func RequestRefund(c *gin.Context) {
+	var req CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		BadRequestError(c, "退款原因不能为空")
+		return
+	}
+
+	order, ok := loadRefundOrder(c)
+	if !ok {
+		return
+	}
+	if order.UserID != c.GetUint("user_id") {
+		ForbiddenError(c, "只有下单用户可以申请退款")
+		return
+	}
+	if !orderPaid(order) {
+		BadRequestError(c, "订单"+orderStatusLabel(order.Status)+"，不能申请退款")
+		return
+	}
+	if order.Status == OrderStatusPaid && len(req.Items) > 0 {
+		BadRequestError(c, "未发货的订单只能整单退款")
+		return
+	}
+
+	var refund Refund
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定订单行，同一订单的退款申请依次处理
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("OrderItems").First(order, order.ID).Error; err != nil {
+			return err
+		}
+		var open int64
+		tx.Model(&Refund{}).
+			Where("order_id = ? AND status IN ?", order.ID, []string{RefundStatusPending, RefundStatusProcessing, RefundStatusFailed}).
+			Count(&open)
+		if open > 0 {
+			return errRefundInProgress
+		}
+
+		items, amount, err := planRefund(tx, order, req.Items)
+		if err != nil {
+			return err
+		}
+
+		refund = Refund{
+			RefundNo: generateRefundNumber(),
+			OrderID:  order.ID,
+			UserID:   order.UserID,
+			Amount:   amount,
+			Reason:   reason,
+			Status:   RefundStatusPending,
+			Items:    items,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+		actor := OrderActor{Role: RoleCustomer, UserID: order.UserID}
+		return recordOrderEvent(tx, order.ID, OrderEventRefundRequested, actor,
+			fmt.Sprintf("申请退款 %s，金额 %.2f 元：%s", refund.RefundNo, refund.Amount, reason))
+	})
+	var planErr *refundPlanError
+	switch {
+	case errors.Is(err, errRefundInProgress):
+		ConflictError(c, err.Error())
+		return
+	case errors.As(err, &planErr):
+		BadRequestError(c, planErr.Error())
+		return
+	case err != nil:
+		InternalServerError(c, "退款申请失败")
+		return
+	}
+
+	SendNotifications(adminUserIDs(), NotificationTypeSystem, "新的退款申请",
+		fmt.Sprintf("订单 %s 申请退款 %.2f 元，请及时审核。", order.OrderNo, refund.Amount))
+	SuccessResponse(c, refund)
+}
+
+// GetOrderRefunds 获取订单退款记录
+// @Summary 获取订单退款记录
+// @Description 按申请时间顺序返回订单的退款申请及退款商品，下单用户和管理员可以查看
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "订单ID"
+// @Success 200 {object} ApiResponse{data=[]Refund} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的订单ID"
+// @Failure 404 {object} ApiResponse "订单不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/orders/{id}/refunds [get]
+func GetOrderRefunds(c *gin.Context) {
+	order, ok := loadRefundOrder(c)
+	if !ok {
+		return
+	}
+
+	var refunds []Refund
+	if err := DB.Preload("Items").Preload("Legs").Where("order_id = ?", order.ID).Order("id").Find(&refunds).Error; err != nil {
+		InternalServerError(c, "退款记录查询失败")
+		return
+	}
+	SuccessResponse(c, refunds)
+}
+
+// GetAdminRefunds 获取退款申请列表
+// @Summary 获取退款申请列表
+// @Description 管理员按状态查询退款申请，按申请时间从新到旧排列
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param status query string false "退款状态" Enums(pending, rejected, processing, succeeded, failed)
+// @Param order_id query int false "订单ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]Refund}} "查询成功"
+// @Failure 400 {object} ApiResponse "退款状态无效"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/refunds [get]
+func GetAdminRefunds(c *gin.Context) {
+	page := 1
+	pageSize := 20
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&Refund{})
+	if status := c.Query("status"); status != "" {
+		if _, ok := refundStatusLabels[status]; !ok {
+			BadRequestError(c, "无效的退款状态")
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var refunds []Refund
+	if err := query.Preload("Items").Preload("Legs").Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&refunds).Error; err != nil {
+		InternalServerError(c, "退款申请查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, refunds, total, page, pageSize)
+}
+
+// 按路径中的退款ID加载退款申请
+func loadRefund(c *gin.Context) (*Refund, bool) {
+	refundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的退款ID")
+		return nil, false
+	}
+	var refund Refund
+	if err := DB.Preload("Items").Preload("Legs").First(&refund, refundID).Error; err != nil {
+		NotFoundError(c, "退款申请不存在")
+		return nil, false
+	}
+	return &refund, true
+}
+
+// ApproveRefund 审批通过退款
+// @Summary 审批通过退款
+// @Description 审批通过待审核或退款失败的退款申请，退款金额按订单的各笔支付分摊后原路退回：在线支付的部分通过原支付渠道退款，钱包余额支付的部分退回钱包余额，超出支付记录的部分（如货到付款订单）视为线下退款；选择 to_wallet 时全部退回钱包余额。退回钱包余额和线下退款直接完成，退款失败后重新审批只重试失败的部分。未发货的订单先取消订单，退回库存、积分并作废发票；已发货订单的退款可选择将退货商品重新入库。支付渠道异步处理的退款为退款中，由后台任务查询结果
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "退款ID"
+// @Param review body ApproveRefundRequest true "审批信息"
+// @Success 200 {object} ApiResponse{data=Refund} "审批成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或退款状态不允许审批"
+// @Failure 404 {object} ApiResponse "退款申请不存在"
+// @Failure 409 {object} ApiResponse "退款状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "支付渠道退款失败"
+// @Security Bearer
+// @Router /api/admin/refunds/{id}/approve [put]
+func ApproveRefund(c *gin.Context) {
+	var req ApproveRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	refund, ok := loadRefund(c)
+	if !ok {
+		return
+	}
+	if refund.Status != RefundStatusPending && refund.Status != RefundStatusFailed {
+		BadRequestError(c, "退款"+refundStatusLabels[refund.Status]+"，不能审批")
+		return
+	}
+
+	var order Order
+	if err := DB.First(&order, refund.OrderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+	// 未发货订单整单退款，取消订单时已退回全部库存
+	if order.Status == OrderStatusPaid {
+		statusReq := UpdateOrderStatusRequest{
+			Status: OrderStatusCancelled,
+			Note:   "退款 " + refund.RefundNo,
+			actor:  orderActorFromContext(c),
+		}
+		if err := runOrderStatusJob(order.ID, order.UserID, statusReq); err != nil {
+			if errors.Is(err, errOrderJobTimeout) {
+				InternalServerError(c, "订单状态更新超时")
+				return
+			}
+			orderTransitionError(c, err, "订单取消失败")
+			return
+		}
+		order.Status = OrderStatusCancelled
+	}
+	if order.Status == OrderStatusCancelled {
+		req.Restock = false
+	}
+	toWallet := refund.ToWallet || req.ToWallet
+
+	now := time.Now()
+	adminID := c.GetUint("user_id")
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Refund{}).
+			Where("id = ? AND status IN ?", refund.ID, []string{RefundStatusPending, RefundStatusFailed}).
+			Updates(map[string]interface{}{
+				"status":      RefundStatusProcessing,
+				"restock":     req.Restock,
+				"to_wallet":   toWallet,
+				"review_note": req.Note,
+				"reviewed_by": adminID,
+				"reviewed_at": now,
+				"fail_reason": "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderStatusChanged
+		}
+		return prepareRefundLegs(tx, refund, toWallet)
+	})
+	if errors.Is(err, errOrderStatusChanged) {
+		ConflictError(c, "退款状态已被其他操作修改，请刷新后重试")
+		return
+	}
+	if err != nil {
+		InternalServerError(c, "退款审批失败")
+		return
+	}
+	refund.Status = RefundStatusProcessing
+	refund.Restock = req.Restock
+	refund.ToWallet = toWallet
+	refund.ReviewNote = req.Note
+	refund.ReviewedBy = &adminID
+	refund.ReviewedAt = &now
+
+	recordAdminAudit(c, "refund.approve", "refund", refund.ID,
+		fmt.Sprintf("订单 %s 退款 %s，金额 %.2f 元", order.OrderNo, refund.RefundNo, refund.Amount))
+
+	if err := executeRefund(refund); err != nil {
+		InternalServerError(c, "退款失败: "+err.Error())
+		return
+	}
+	DB.Preload("Items").Preload("Legs").First(refund, refund.ID)
+	SuccessResponse(c, refund)
+}
+
+// RejectRefund 驳回退款
+// @Summary 驳回退款
+// @Description 驳回待审核的退款申请，驳回原因会通知用户，用户可以重新申请
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param id path int true "退款ID"
+// @Param review body RejectRefundRequest true "驳回原因"
+// @Success 200 {object} ApiResponse{data=Refund} "驳回成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或退款状态不允许驳回"
+// @Failure 404 {object} ApiResponse "退款申请不存在"
+// @Failure 409 {object} ApiResponse "退款状态已被其他操作修改"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/refunds/{id}/reject [put]
+func RejectRefund(c *gin.Context) {
+	var req RejectRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	refund, ok := loadRefund(c)
+	if !ok {
+		return
+	}
+	if refund.Status != RefundStatusPending {
+		BadRequestError(c, "退款"+refundStatusLabels[refund.Status]+"，不能驳回")
+		return
+	}
+	var order Order
+	if err := DB.First(&order, refund.OrderID).Error; err != nil {
+		NotFoundError(c, "订单不存在")
+		return
+	}
+
+	now := time.Now()
+	adminID := c.GetUint("user_id")
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Refund{}).Where("id = ? AND status = ?", refund.ID, RefundStatusPending).
+			Updates(map[string]interface{}{
+				"status":      RefundStatusRejected,
+				"review_note": req.Reason,
+				"reviewed_by": adminID,
+				"reviewed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderStatusChanged
+		}
+		return recordOrderEvent(tx, order.ID, OrderEventRefundRejected, orderActorFromContext(c),
+			fmt.Sprintf("退款 %s 被驳回：%s", refund.RefundNo, req.Reason))
+	})
+	if errors.Is(err, errOrderStatusChanged) {
+		ConflictError(c, "退款状态已被其他操作修改，请刷新后重试")
+		return
+	}
+	if err != nil {
+		InternalServerError(c, "退款驳回失败")
+		return
+	}
+
+	recordAdminAudit(c, "refund.reject", "refund", refund.ID, req.Reason)
+	SendNotification(order.UserID, NotificationTypeOrder, "退款申请未通过",
+		fmt.Sprintf("您的订单 %s 的退款申请未通过审核：%s", order.OrderNo, req.Reason))
+
+	refund.Status = RefundStatusRejected
+	refund.ReviewNote = req.Reason
+	refund.ReviewedBy = &adminID
+	refund.ReviewedAt = &now
+	SuccessResponse(c, refund)
+}
+
+// 按订单的各笔成功支付分摊退款金额：先退在线支付再退钱包余额，同类支付先退最近的一笔，每笔最多退回未退款的金额；
+// 超出支付记录的部分为线下退款。选择退回钱包余额时各明细都退回钱包
+func allocateRefundLegs(tx *gorm.DB, refund *Refund, toWallet bool) error {
+	var payments []Payment
+	if err := tx.Where("order_id = ? AND status = ?", refund.OrderID, PaymentStatusSucceeded).
+		Order("id DESC").Find(&payments).Error; err != nil {
+		return err
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].Channel != PaymentChannelWallet && payments[j].Channel == PaymentChannelWallet
+	})
+
+	remaining := toCents(refund.Amount)
+	var legs []RefundPayment
+	for i := range payments {
+		if remaining <= 0 {
+			break
+		}
+		payment := &payments[i]
+		amount := toCents(payment.Amount) - toCents(payment.RefundedAmount)
+		if amount <= 0 {
+			continue
+		}
+		if amount > remaining {
+			amount = remaining
+		}
+		channel := payment.Channel
+		if toWallet {
+			channel = PaymentChannelWallet
+		}
+		legs = append(legs, RefundPayment{PaymentID: &payment.ID, Channel: channel, Amount: fromCents(amount)})
+		remaining -= amount
+	}
+	if remaining > 0 {
+		channel := RefundChannelOffline
+		if toWallet {
+			channel = PaymentChannelWallet
+		}
+		legs = append(legs, RefundPayment{Channel: channel, Amount: fromCents(remaining)})
+	}
+	if len(legs) == 0 {
+		return nil
+	}
+
+	for i := range legs {
+		legs[i].RefundID = refund.ID
+		legs[i].RefundNo = fmt.Sprintf("%s-%d", refund.RefundNo, i+1)
+		legs[i].Status = RefundStatusProcessing
+		legs[i].Attempts = 1
+	}
+	if err := tx.Create(&legs).Error; err != nil {
+		return err
+	}
+	refund.Legs = legs
+	return nil
+}
+
+// 审批通过时准备要执行的退款明细：首次审批按支付分摊，退款失败后重新审批只重试失败的明细
+func prepareRefundLegs(tx *gorm.DB, refund *Refund, toWallet bool) error {
+	if len(refund.Legs) == 0 {
+		return allocateRefundLegs(tx, refund, toWallet)
+	}
+	for i := range refund.Legs {
+		leg := &refund.Legs[i]
+		if leg.Status != RefundStatusFailed {
+			continue
+		}
+		updates := map[string]interface{}{
+			"status":      RefundStatusProcessing,
+			"attempts":    gorm.Expr("attempts + 1"),
+			"trade_no":    "",
+			"fail_reason": "",
+		}
+		if toWallet {
+			updates["channel"] = PaymentChannelWallet
+			leg.Channel = PaymentChannelWallet
+		}
+		if err := tx.Model(&RefundPayment{}).Where("id = ?", leg.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		leg.Status = RefundStatusProcessing
+		leg.Attempts++
+		leg.TradeNo = ""
+		leg.FailReason = ""
+	}
+	return nil
+}
+
+// 执行退款中且尚未提交的退款明细，全部明细处理后结算退款
+func executeRefund(refund *Refund) error {
+	for i := range refund.Legs {
+		leg := &refund.Legs[i]
+		if leg.Status != RefundStatusProcessing || leg.TradeNo != "" {
+			continue
+		}
+		if err := executeRefundLeg(refund, leg); err != nil {
+			log.Printf("退款 %s 执行失败: %v", leg.RefundNo, err)
+		}
+	}
+	return settleRefund(refund)
+}
+
+// 退回钱包余额和线下退款直接完成，其余明细通过原支付渠道退款
+func executeRefundLeg(refund *Refund, leg *RefundPayment) error {
+	if leg.Channel == PaymentChannelWallet || leg.Channel == RefundChannelOffline {
+		return completeRefundLeg(refund, leg, "")
+	}
+	var payment Payment
+	if leg.PaymentID == nil || DB.First(&payment, *leg.PaymentID).Error != nil {
+		return failRefundLeg(leg, "支付单不存在")
+	}
+	gateway, ok := PaymentGateways[payment.Channel]
+	if !ok {
+		return failRefundLeg(leg, "支付渠道 "+payment.Channel+" 未启用")
+	}
+
+	result, err := gateway.Refund(&payment, leg)
+	if err != nil {
+		log.Printf("退款 %s 请求支付渠道失败: %v", leg.RefundNo, err)
+		return failRefundLeg(leg, err.Error())
+	}
+	return applyRefundLegResult(refund, leg, result)
+}
+
+// 按支付渠道返回的结果更新退款明细，退款中时只记录渠道退款单号
+func applyRefundLegResult(refund *Refund, leg *RefundPayment, result *RefundResult) error {
+	switch result.Status {
+	case RefundStatusSucceeded:
+		return completeRefundLeg(refund, leg, result.TradeNo)
+	case RefundStatusFailed:
+		reason := result.FailReason
+		if reason == "" {
+			reason = "支付渠道退款失败"
+		}
+		return failRefundLeg(leg, reason)
+	}
+	leg.TradeNo = result.TradeNo
+	return DB.Model(&RefundPayment{}).Where("id = ? AND status = ?", leg.ID, RefundStatusProcessing).
+		Update("trade_no", result.TradeNo).Error
+}
+
+// 退款明细完成：累计原支付单的已退款金额，退回钱包余额的入账
+func completeRefundLeg(refund *Refund, leg *RefundPayment, tradeNo string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&RefundPayment{}).Where("id = ? AND status = ?", leg.ID, RefundStatusProcessing).
+			Updates(map[string]interface{}{
+				"status":      RefundStatusSucceeded,
+				"trade_no":    tradeNo,
+				"refunded_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 已由其他请求或后台任务处理
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var paymentID uint
+		if leg.PaymentID != nil {
+			paymentID = *leg.PaymentID
+			if err := tx.Model(&Payment{}).Where("id = ?", paymentID).
+				UpdateColumn("refunded_amount", gorm.Expr("refunded_amount + ?", leg.Amount)).Error; err != nil {
+				return err
+			}
+		}
+		if leg.Channel == PaymentChannelWallet {
+			if err := changeWallet(tx, refund.UserID, leg.Amount, WalletTypeRefund, refund.OrderID, paymentID,
+				"退款 "+refund.RefundNo+" 退回钱包余额"); err != nil {
+				return err
+			}
+		}
+		leg.Status = RefundStatusSucceeded
+		leg.TradeNo = tradeNo
+		leg.RefundedAt = &now
+		return nil
+	})
+}
+
+// 退款明细失败，管理员重新审批时重试
+func failRefundLeg(leg *RefundPayment, reason string) error {
+	reason = paymentFailReason(reason)
+	DB.Model(&RefundPayment{}).Where("id = ? AND status = ?", leg.ID, RefundStatusProcessing).
+		Updates(map[string]interface{}{"status": RefundStatusFailed, "fail_reason": reason})
+	leg.Status = RefundStatusFailed
+	leg.FailReason = reason
+	return errors.New(reason)
+}
+
+// 按退款明细结算退款：有明细仍在退款中时等待支付渠道结果，有明细失败时退款失败，全部成功后完成退款
+func settleRefund(refund *Refund) error {
+	for _, leg := range refund.Legs {
+		if leg.Status == RefundStatusProcessing {
+			return nil
+		}
+	}
+	for _, leg := range refund.Legs {
+		if leg.Status == RefundStatusFailed {
+			return failRefund(refund, leg.FailReason)
+		}
+	}
+	return completeRefund(refund)
+}
+
+// 退款失败，管理员可以重新审批
+func failRefund(refund *Refund, reason string) error {
+	reason = paymentFailReason(reason)
+	DB.Model(&Refund{}).Where("id = ? AND status = ?", refund.ID, RefundStatusProcessing).
+		Updates(map[string]interface{}{"status": RefundStatusFailed, "fail_reason": reason})
+	refund.Status = RefundStatusFailed
+	refund.FailReason = reason
+	return errors.New(reason)
+}
+
+// 退款完成：退货商品重新入库，扣减用户累计消费金额（订单取消时已扣减），记录订单事件并按退款去向通知用户
+func completeRefund(refund *Refund) error {
+	var order Order
+	if err := DB.First(&order, refund.OrderID).Error; err != nil {
+		return err
+	}
+
+	completed := false
+	restocked := make(map[uint]int)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&Refund{}).Where("id = ? AND status = ?", refund.ID, RefundStatusProcessing).
+			Updates(map[string]interface{}{
+				"status":      RefundStatusSucceeded,
+				"refunded_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 已由其他请求或后台任务处理
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if refund.Restock {
+			for _, item := range refund.Items {
+				restocked[item.ProductID] += item.Quantity
+			}
+			if err := changeOrderStock(tx, &order, restocked, 1, MovementTypeReturn); err != nil {
+				return err
+			}
+		}
+		if order.Status != OrderStatusCancelled {
+			// 不使用 GREATEST，保持与SQLite等数据库兼容
+			if err := tx.Model(&User{}).Where("id = ?", order.UserID).
+				UpdateColumn("total_spent", gorm.Expr("CASE WHEN total_spent > ? THEN total_spent - ? ELSE 0 END", refund.Amount, refund.Amount)).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordOrderEvent(tx, order.ID, OrderEventRefunded, systemOrderActor,
+			fmt.Sprintf("退款 %s 已退回 %.2f 元", refund.RefundNo, refund.Amount)); err != nil {
+			return err
+		}
+		refund.Status = RefundStatusSucceeded
+		refund.RefundedAt = &now
+		completed = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return nil
+	}
+
+	if refund.Restock {
+		GlobalStockManager.releaseAll(restocked)
+	}
+	// 按退款去向汇总：原路退回、退回钱包余额、线下退款
+	var originalCents, walletCents, offlineCents int64
+	for _, leg := range refund.Legs {
+		switch leg.Channel {
+		case PaymentChannelWallet:
+			walletCents += toCents(leg.Amount)
+		case RefundChannelOffline:
+			offlineCents += toCents(leg.Amount)
+		default:
+			originalCents += toCents(leg.Amount)
+		}
+	}
+	var parts []string
+	if originalCents > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f 元已原路退回", fromCents(originalCents)))
+	}
+	if walletCents > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f 元已退回钱包余额", fromCents(walletCents)))
+	}
+	if offlineCents > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f 元已线下退款", fromCents(offlineCents)))
+	}
+	content := fmt.Sprintf("您的订单 %s 退款 %.2f 元已处理完成。", order.OrderNo, refund.Amount)
+	if len(parts) > 0 {
+		content = fmt.Sprintf("您的订单 %s 退款 %.2f 元已处理完成：%s，请注意查收。", order.OrderNo, refund.Amount, strings.Join(parts, "，"))
+	}
+	SendNotification(order.UserID, NotificationTypeOrder, "退款成功", content)
+	return nil
+}
+
+// 查询退款中的退款明细在支付渠道的处理结果，更新后结算所属的退款
+func syncProcessingRefunds() {
+	var legs []RefundPayment
+	err := DB.Where("status = ? AND trade_no <> '' AND payment_id IS NOT NULL", RefundStatusProcessing).
+		Where("updated_at < ?", time.Now().Add(-time.Minute)).
+		Order("id").Limit(100).Find(&legs).Error
+	if err != nil {
+		log.Printf("退款中的退款查询失败: %v", err)
+		return
+	}
+
+	for _, pending := range legs {
+		var refund Refund
+		if err := DB.Preload("Items").Preload("Legs").First(&refund, pending.RefundID).Error; err != nil {
+			continue
+		}
+		var leg *RefundPayment
+		for i := range refund.Legs {
+			if refund.Legs[i].ID == pending.ID {
+				leg = &refund.Legs[i]
+			}
+		}
+		var payment Payment
+		if leg == nil || DB.First(&payment, *leg.PaymentID).Error != nil {
+			continue
+		}
+		gateway, ok := PaymentGateways[payment.Channel]
+		if !ok {
+			continue
+		}
+		result, err := gateway.QueryRefund(&payment, leg)
+		if err != nil {
+			log.Printf("退款 %s 查询失败: %v", leg.RefundNo, err)
+			continue
+		}
+		if err := applyRefundLegResult(&refund, leg, result); err != nil {
+			log.Printf("退款 %s 失败: %v", leg.RefundNo, err)
+		}
+		if err := settleRefund(&refund); err != nil {
+			log.Printf("退款 %s 失败: %v", refund.RefundNo, err)
+		}
+	}
+}
+
+// StartRefundSyncScheduler 启动退款结果查询任务，每分钟查询一次支付渠道异步处理中的退款
+func StartRefundSyncScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// 多实例部署时每分钟只由一个实例查询
+			if ok, _ := RDB.SetNX(CTX, "refunds:sync:lock", 1, 50*time.Second).Result(); ok {
+				syncProcessingRefunds()
+			}
+		}
+	}()
+	log.Println("退款结果查询任务已启动")
+}
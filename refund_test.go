@@ -0,0 +1,64 @@
+package gomall_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 退款完成后扣减累计消费金额，不会扣成负数
+func TestRefundToWalletDeductsTotalSpent(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+
+	if err := kit.DB.Model(&f.Customer).Update("total_spent", 50).Error; err != nil {
+		t.Fatalf("累计消费写入失败: %v", err)
+	}
+	order := gomall.Order{
+		UserID:      f.Customer.ID,
+		OrderNo:     "TESTKIT0002",
+		TotalAmount: f.Product.Price,
+		Status:      gomall.OrderStatusDelivered,
+		OrderItems: []gomall.OrderItem{{
+			ProductID: f.Product.ID,
+			Quantity:  1,
+			Price:     f.Product.Price,
+		}},
+	}
+	if err := kit.DB.Create(&order).Error; err != nil {
+		t.Fatalf("订单创建失败: %v", err)
+	}
+	refund := gomall.Refund{
+		RefundNo: "RFTESTKIT0002",
+		OrderID:  order.ID,
+		UserID:   f.Customer.ID,
+		Amount:   order.TotalAmount,
+		Status:   gomall.RefundStatusPending,
+	}
+	if err := kit.DB.Create(&refund).Error; err != nil {
+		t.Fatalf("退款单创建失败: %v", err)
+	}
+
+	path := "/api/admin/refunds/" + strconv.FormatUint(uint64(refund.ID), 10) + "/approve"
+	resp := kit.DoAs(f.Admin, http.MethodPut, path, gomall.ApproveRefundRequest{ToWallet: true})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("退款审批返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+	}
+
+	if err := kit.DB.First(&refund, refund.ID).Error; err != nil {
+		t.Fatalf("退款单查询失败: %v", err)
+	}
+	if refund.Status != gomall.RefundStatusSucceeded {
+		t.Fatalf("退款状态为 %s，期望 %s（%s）", refund.Status, gomall.RefundStatusSucceeded, refund.FailReason)
+	}
+	var user gomall.User
+	if err := kit.DB.First(&user, f.Customer.ID).Error; err != nil {
+		t.Fatalf("用户查询失败: %v", err)
+	}
+	if user.TotalSpent != 0 {
+		t.Fatalf("累计消费为 %.2f，期望 0", user.TotalSpent)
+	}
+}
@@ -0,0 +1,212 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每类推荐计算的候选数量，返回时按 limit 截取
+const relatedProductsCandidates = 20
+
+// 商品标签限制
+const (
+	productMaxTags   = 20
+	productTagMaxLen = 30
+)
+
+// RelatedProductsResponse 相关商品推荐，三类之间不重复，优先级依次为购买关联、相同标签、同类商品
+type RelatedProductsResponse struct {
+	BoughtTogether []Product `json:"bought_together"` // 经常一起购买
+	SharedTags     []Product `json:"shared_tags"`     // 标签相同
+	SameCategory   []Product `json:"same_category"`   // 同分类热销
+}
+
+// 计算出的推荐商品ID，缓存于Redis
+type relatedProductIDs struct {
+	BoughtTogether []uint `json:"bought_together"`
+	SharedTags     []uint `json:"shared_tags"`
+	SameCategory   []uint `json:"same_category"`
+}
+
+func relatedProductsCacheKey(productID uint) string {
+	return fmt.Sprintf("products:related:%d", productID)
+}
+
+// 规范化商品标签：去空白、去重，存储为逗号分隔
+func normalizeProductTags(tags []string) (string, error) {
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", ""))
+		if tag == "" || containsString(result, tag) {
+			continue
+		}
+		if len([]rune(tag)) > productTagMaxLen {
+			return "", fmt.Errorf("标签「%s」超过%d个字符", tag, productTagMaxLen)
+		}
+		result = append(result, tag)
+	}
+	if len(result) > productMaxTags {
+		return "", fmt.Errorf("商品标签最多%d个", productMaxTags)
+	}
+	return strings.Join(result, ","), nil
+}
+
+// 按订单中同时出现的次数计算经常一起购买的商品
+func boughtTogetherIDs(productID uint) []uint {
+	var rows []struct {
+		ProductID uint
+		Together  int
+	}
+	DB.Table("order_items AS a").
+		Select("b.product_id, COUNT(DISTINCT a.order_id) AS together").
+		Joins("JOIN order_items AS b ON b.order_id = a.order_id AND b.product_id <> a.product_id").
+		Joins("JOIN orders ON orders.id = a.order_id").
+		Where("a.product_id = ? AND orders.status IN ?", productID, reportOrderStatuses).
+		Group("b.product_id").
+		Order("together DESC").
+		Limit(relatedProductsCandidates * 2).
+		Scan(&rows)
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ProductID)
+	}
+	return ids
+}
+
+// 按相同标签数量排序，数量相同时按销量
+func sharedTagIDs(product *Product) []uint {
+	tags := splitEnvList(product.Tags)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	conditions := make([]string, len(tags))
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		conditions[i] = "FIND_IN_SET(?, tags) > 0"
+		args[i] = tag
+	}
+	var candidates []Product
+	DB.Select("id, tags, sales_count").
+		Where("status = ? AND id <> ?", 1, product.ID).
+		Where(strings.Join(conditions, " OR "), args...).
+		Order("sales_count DESC").
+		Limit(relatedProductsCandidates * 10).
+		Find(&candidates)
+
+	shared := make(map[uint]int, len(candidates))
+	for _, candidate := range candidates {
+		for _, tag := range splitEnvList(candidate.Tags) {
+			if containsString(tags, tag) {
+				shared[candidate.ID]++
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return shared[candidates[i].ID] > shared[candidates[j].ID]
+	})
+
+	ids := make([]uint, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.ID)
+	}
+	return ids
+}
+
+// 计算推荐商品ID，结果写入缓存
+func computeRelatedProductIDs(product *Product) relatedProductIDs {
+	var ids relatedProductIDs
+	DB.Model(&Product{}).
+		Where("status = ? AND category_id = ? AND id <> ?", 1, product.CategoryID, product.ID).
+		Order("sales_count DESC, created_at DESC").
+		Limit(relatedProductsCandidates*3).
+		Pluck("id", &ids.SameCategory)
+	ids.BoughtTogether = boughtTogetherIDs(product.ID)
+	ids.SharedTags = sharedTagIDs(product)
+
+	if data, err := json.Marshal(ids); err == nil {
+		RDB.Set(CTX, relatedProductsCacheKey(product.ID), data, AppConfig.RelatedCache.Expiration())
+	}
+	return ids
+}
+
+// 按ID顺序加载在售商品，跳过已出现在其他推荐中的商品
+func loadRelatedProducts(ids []uint, seen map[uint]bool, limit int) []Product {
+	result := []Product{}
+	var candidates []uint
+	for _, id := range ids {
+		if !seen[id] {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return result
+	}
+
+	var products []Product
+	DB.Preload("Category").Preload("Brand").Where("id IN ? AND status = ?", candidates, 1).Find(&products)
+	byID := make(map[uint]Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for _, id := range candidates {
+		if product, ok := byID[id]; ok && len(result) < limit {
+			result = append(result, product)
+			seen[id] = true
+		}
+	}
+	return result
+}
+
+// GetRelatedProducts 获取相关商品
+// @Summary 获取相关商品推荐
+// @Description 返回经常一起购买（按已支付订单中商品同时出现的次数计算）、标签相同和同分类热销的商品，三类之间不重复；计算结果默认缓存6小时，只返回在售商品
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param limit query int false "每类返回数量" default(8) maximum(20)
+// @Success 200 {object} ApiResponse{data=RelatedProductsResponse} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID"
+// @Failure 404 {object} ApiResponse "商品不存在或已下架"
+// @Router /api/products/{id}/related [get]
+func GetRelatedProducts(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	limit := 8
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if l, err := strconv.Atoi(limitParam); err == nil && l > 0 && l <= relatedProductsCandidates {
+			limit = l
+		}
+	}
+
+	var product Product
+	if err := DB.Select("id, category_id, tags, status").First(&product, productID).Error; err != nil || product.Status != 1 {
+		NotFoundError(c, "商品不存在或已下架")
+		return
+	}
+
+	var ids relatedProductIDs
+	data, err := RDB.Get(CTX, relatedProductsCacheKey(product.ID)).Result()
+	if err != nil || json.Unmarshal([]byte(data), &ids) != nil {
+		ids = computeRelatedProductIDs(&product)
+	}
+
+	seen := map[uint]bool{product.ID: true}
+	response := RelatedProductsResponse{}
+	response.BoughtTogether = loadRelatedProducts(ids.BoughtTogether, seen, limit)
+	response.SharedTags = loadRelatedProducts(ids.SharedTags, seen, limit)
+	response.SameCategory = loadRelatedProducts(ids.SameCategory, seen, limit)
+
+	SuccessResponse(c, response)
+}
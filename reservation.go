@@ -0,0 +1,197 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 库存预占状态
+const (
+	ReservationStatusReserved  = "reserved"  // 预占中，只减少可售库存
+	ReservationStatusCommitted = "committed" // 已付款，转为实际扣减
+	ReservationStatusReleased  = "released"  // 已释放
+)
+
+// 商品当前预占中的数量
+func reservedStock(db *gorm.DB, productID uint) int {
+	var total int64
+	db.Model(&StockReservation{}).
+		Where("product_id = ? AND status = ?", productID, ReservationStatusReserved).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&total)
+	return int(total)
+}
+
+// 预占到期时间：货到付款订单不需要在线付款，不过期
+func reservationExpiresAt(paymentMethod string) *time.Time {
+	if paymentMethod == PaymentMethodCOD || AppConfig.StockReservationMinutes <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(AppConfig.StockReservationMinutes) * time.Minute)
+	return &expiresAt
+}
+
+// 在创建订单的事务中锁定商品行、分配发货仓库并记录预占，可售库存不足时返回错误，防止并发下单超卖
+func reserveStockInTx(tx *gorm.DB, order *Order, quantities map[uint]int) error {
+	if err := checkAvailableStock(tx, quantities); err != nil {
+		return err
+	}
+	warehouseID, err := allocateWarehouse(tx, order.ShippingAddress, quantities)
+	if err != nil {
+		return err
+	}
+	if warehouseID != nil {
+		if err := tx.Model(order).Update("warehouse_id", *warehouseID).Error; err != nil {
+			return err
+		}
+		order.WarehouseID = warehouseID
+	}
+	return createStockReservations(tx, order, quantities)
+}
+
+// 记录订单的库存预占，调用前需已锁定商品行并确定发货仓库
+func createStockReservations(tx *gorm.DB, order *Order, quantities map[uint]int) error {
+	expiresAt := reservationExpiresAt(order.PaymentMethod)
+	for productID, quantity := range quantities {
+		reservation := StockReservation{
+			OrderID:   order.ID,
+			ProductID: productID,
+			Quantity:  quantity,
+			Status:    ReservationStatusReserved,
+			ExpiresAt: expiresAt,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return fmt.Errorf("库存预占记录失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// 订单付款或发货时在同一事务中将预占转为实际扣减
+func commitOrderReservations(tx *gorm.DB, orderID uint) error {
+	var reservations []StockReservation
+	if err := tx.Where("order_id = ? AND status = ?", orderID, ReservationStatusReserved).Find(&reservations).Error; err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(reservations))
+	quantities := make(map[uint]int)
+	productIDs := make([]uint, 0, len(reservations))
+	for i, reservation := range reservations {
+		ids[i] = reservation.ID
+		if _, ok := quantities[reservation.ProductID]; !ok {
+			productIDs = append(productIDs, reservation.ProductID)
+		}
+		quantities[reservation.ProductID] += reservation.Quantity
+	}
+	if _, err := lockAvailableStock(tx, productIDs); err != nil {
+		return err
+	}
+	if err := tx.Model(&StockReservation{}).Where("id IN ?", ids).Update("status", ReservationStatusCommitted).Error; err != nil {
+		return err
+	}
+	var order Order
+	if err := tx.Select("id, warehouse_id").First(&order, orderID).Error; err != nil {
+		return err
+	}
+	return deductStockInTx(tx, &order, quantities)
+}
+
+// 在取消订单的事务中退回库存：预占中的直接释放，已扣减的恢复数据库库存；
+// 没有预占记录的订单（导入的订单）按订单项恢复。返回需要退回到可售库存的数量，事务提交后调用 releaseAll
+func releaseOrderStockInTx(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var reservations []StockReservation
+	if err := tx.Where("order_id = ?", orderID).Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+
+	released := make(map[uint]int)
+	committed := make(map[uint]int)
+	if len(reservations) == 0 {
+		var items []OrderItem
+		if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			committed[item.ProductID] += item.Quantity
+		}
+	}
+
+	var ids []uint
+	for _, reservation := range reservations {
+		switch reservation.Status {
+		case ReservationStatusReserved:
+			released[reservation.ProductID] += reservation.Quantity
+		case ReservationStatusCommitted:
+			committed[reservation.ProductID] += reservation.Quantity
+		default:
+			continue
+		}
+		ids = append(ids, reservation.ID)
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&StockReservation{}).Where("id IN ?", ids).Update("status", ReservationStatusReleased).Error; err != nil {
+			return nil, err
+		}
+	}
+	var order Order
+	if err := tx.Select("id, warehouse_id").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+	if err := restoreStockInTx(tx, &order, committed); err != nil {
+		return nil, err
+	}
+
+	for productID, quantity := range committed {
+		released[productID] += quantity
+	}
+	return released, nil
+}
+
+// 预占到期仍未付款的订单自动取消，取消时释放库存
+func expireStockReservations(now time.Time) {
+	var orderIDs []uint
+	DB.Model(&StockReservation{}).
+		Where("status = ? AND expires_at <= ?", ReservationStatusReserved, now).
+		Distinct("order_id").
+		Pluck("order_id", &orderIDs)
+
+	for _, orderID := range orderIDs {
+		var order Order
+		if err := DB.First(&order, orderID).Error; err != nil || order.Status != OrderStatusPending {
+			continue
+		}
+
+		if err := processCancelOrder(OrderJob{OrderID: order.ID, UserID: order.UserID, Type: "cancel"}); err != nil {
+			log.Printf("订单 %s 超时取消失败: %v", order.OrderNo, err)
+			continue
+		}
+		log.Printf("订单 %s 超时未付款，已自动取消", order.OrderNo)
+		SendNotification(order.UserID, NotificationTypeOrder, "订单已自动取消",
+			fmt.Sprintf("订单 %s 超时未付款，已自动取消，预占的库存已释放", order.OrderNo))
+	}
+}
+
+// StartStockReservationExpirer 启动预占超时检查任务，每分钟检查一次
+func StartStockReservationExpirer() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			// 多实例部署时同一分钟只由一个实例执行
+			key := "orders:reservations:expire:" + time.Now().Format("200601021504")
+			if ok, _ := RDB.SetNX(CTX, key, 1, 2*time.Minute).Result(); ok {
+				expireStockReservations(time.Now())
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("库存预占超时检查任务已启动")
+}
@@ -1,4 +1,4 @@
-package main
+package gomall
 
 import (
 	"net/http"
@@ -18,7 +18,7 @@ func SuccessResponse(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, ApiResponse{
 		Code:    200,
 		Message: "success",
-		Data:    data,
+		Data:    FilterFields(c, data),
 	})
 }
 
@@ -40,23 +40,28 @@ type PaginationResponse struct {
 	TotalPages int         `json:"total_pages"` // 总页数
 }
 
-// PaginationSuccessResponse 分页成功响应
-func PaginationSuccessResponse(c *gin.Context, list interface{}, total int64, page, pageSize int) {
+// 构建分页数据，列表按当前用户过滤字段
+func newPaginationResponse(c *gin.Context, list interface{}, total int64, page, pageSize int) PaginationResponse {
 	totalPages := int(total)/pageSize
 	if int(total)%pageSize > 0 {
 		totalPages++
 	}
 
+	return PaginationResponse{
+		List:       FilterFields(c, list),
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}
+}
+
+// PaginationSuccessResponse 分页成功响应
+func PaginationSuccessResponse(c *gin.Context, list interface{}, total int64, page, pageSize int) {
 	c.JSON(http.StatusOK, ApiResponse{
 		Code:    200,
 		Message: "success",
-		Data: PaginationResponse{
-			List:       list,
-			Total:      total,
-			Page:       page,
-			PageSize:   pageSize,
-			TotalPages: totalPages,
-		},
+		Data:    newPaginationResponse(c, list, total, page, pageSize),
 	})
 }
 
@@ -81,6 +86,10 @@ func ConflictError(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusConflict, message)
 }
 
+func TooManyRequestsError(c *gin.Context, message string) {
+	ErrorResponse(c, http.StatusTooManyRequests, message)
+}
+
 func InternalServerError(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusInternalServerError, message)
 }
\ No newline at end of file
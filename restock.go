@@ -0,0 +1,90 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 库存从0补到正数时通知订阅用户
+func notifyRestockIfReplenished(productID uint, oldStock, newStock int) {
+	if oldStock <= 0 && newStock > 0 {
+		go notifyRestockSubscribers(productID)
+	}
+}
+
+// 给商品的全部订阅用户发送到货提醒，发送后删除订阅
+func notifyRestockSubscribers(productID uint) {
+	// 同一商品短时间内多次补货只处理一次
+	key := fmt.Sprintf("products:restock:notifying:%d", productID)
+	if ok, _ := RDB.SetNX(CTX, key, 1, time.Minute).Result(); !ok {
+		return
+	}
+	defer RDB.Del(CTX, key)
+
+	var product Product
+	if err := DB.Select("id, name, status").First(&product, productID).Error; err != nil || product.Status != 1 {
+		return
+	}
+
+	var subscriptions []RestockSubscription
+	if err := DB.Where("product_id = ?", productID).Find(&subscriptions).Error; err != nil || len(subscriptions) == 0 {
+		return
+	}
+	ids := make([]uint, len(subscriptions))
+	userIDs := make([]uint, len(subscriptions))
+	for i, subscription := range subscriptions {
+		ids[i] = subscription.ID
+		userIDs[i] = subscription.UserID
+	}
+
+	content := fmt.Sprintf("您订阅的商品「%s」已到货，库存有限，欢迎尽快购买", product.Name)
+	if err := SendNotifications(userIDs, NotificationTypeRestock, "商品到货提醒", content); err != nil {
+		log.Printf("商品 %d 到货提醒发送失败: %v", productID, err)
+		return
+	}
+	DB.Where("id IN ?", ids).Delete(&RestockSubscription{})
+}
+
+// SubscribeRestock 订阅到货提醒
+// @Summary 订阅到货提醒
+// @Description 订阅缺货商品的到货提醒，商品补货后通过站内通知提醒一次；重复订阅时返回已有的订阅
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=RestockSubscription} "订阅成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID或商品有货"
+// @Failure 404 {object} ApiResponse "商品不存在或已下架"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/products/{id}/notify-restock [post]
+func SubscribeRestock(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	var product Product
+	if err := DB.Where("status = ?", 1).First(&product, productID).Error; err != nil {
+		NotFoundError(c, "商品不存在或已下架")
+		return
+	}
+	if product.Stock > 0 {
+		BadRequestError(c, "商品有货，无需订阅到货提醒")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	subscription := RestockSubscription{UserID: userID, ProductID: product.ID}
+	if err := DB.Where("user_id = ? AND product_id = ?", userID, product.ID).FirstOrCreate(&subscription).Error; err != nil {
+		InternalServerError(c, "订阅失败")
+		return
+	}
+
+	SuccessResponse(c, subscription)
+}
@@ -0,0 +1,310 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 客户分群，根据RFM评分划分
+const (
+	CustomerSegmentChampions   = "champions"   // 重要价值客户：最近买过且购买频繁
+	CustomerSegmentLoyal       = "loyal"       // 忠诚客户：购买频繁
+	CustomerSegmentPotential   = "potential"   // 潜力客户：最近买过，有复购
+	CustomerSegmentNew         = "new"         // 新客户：最近首次购买
+	CustomerSegmentAtRisk      = "at_risk"     // 流失风险：曾经购买频繁，近期未购买
+	CustomerSegmentHibernating = "hibernating" // 沉睡客户：很久未购买且频次低
+	CustomerSegmentOthers      = "others"      // 一般客户
+)
+
+var customerSegments = map[string]bool{
+	CustomerSegmentChampions:   true,
+	CustomerSegmentLoyal:       true,
+	CustomerSegmentPotential:   true,
+	CustomerSegmentNew:         true,
+	CustomerSegmentAtRisk:      true,
+	CustomerSegmentHibernating: true,
+	CustomerSegmentOthers:      true,
+}
+
+// 按R、F评分划分客户群
+func customerSegment(r, f int) string {
+	switch {
+	case r >= 4 && f >= 4:
+		return CustomerSegmentChampions
+	case f >= 4 && r >= 3:
+		return CustomerSegmentLoyal
+	case r >= 4 && f <= 1:
+		return CustomerSegmentNew
+	case r >= 3 && f >= 2:
+		return CustomerSegmentPotential
+	case r <= 2 && f >= 3:
+		return CustomerSegmentAtRisk
+	case r <= 2:
+		return CustomerSegmentHibernating
+	}
+	return CustomerSegmentOthers
+}
+
+// 按五分位计算1-5分，数值越大得分越高，相同数值得分相同
+func quintileScores(values []float64) []int {
+	n := len(values)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	scores := make([]int, n)
+	for rank, index := range order {
+		if rank > 0 && values[index] == values[order[rank-1]] {
+			scores[index] = scores[order[rank-1]]
+			continue
+		}
+		scores[index] = rank*5/n + 1
+	}
+	return scores
+}
+
+// ComputeCustomerMetrics 重新计算所有下过有效订单的客户的RFM评分和生命周期价值
+func ComputeCustomerMetrics() (int, error) {
+	var rows []struct {
+		UserID       uint
+		Frequency    int
+		Monetary     float64
+		FirstOrderAt time.Time
+		LastOrderAt  time.Time
+	}
+	err := DB.Model(&Order{}).
+		Select("user_id, COUNT(*) AS frequency, SUM(total_amount) AS monetary, MIN(created_at) AS first_order_at, MAX(created_at) AS last_order_at").
+		Where("status IN ?", reportOrderStatuses).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, fmt.Errorf("订单统计失败: %v", err)
+	}
+
+	now := time.Now()
+	recency := make([]float64, len(rows))
+	frequency := make([]float64, len(rows))
+	monetary := make([]float64, len(rows))
+	for i, row := range rows {
+		// 距今天数越少越好，取负数使其与F、M方向一致
+		recency[i] = -math.Floor(now.Sub(row.LastOrderAt).Hours() / 24)
+		frequency[i] = float64(row.Frequency)
+		monetary[i] = row.Monetary
+	}
+	rScores, fScores, mScores := quintileScores(recency), quintileScores(frequency), quintileScores(monetary)
+
+	metrics := make([]CustomerMetrics, len(rows))
+	for i, row := range rows {
+		// 生命周期价值 = 客单价 × 年购买次数 × 预计生命周期（年），下单时间不足30天的按30天折算
+		tenureDays := math.Max(now.Sub(row.FirstOrderAt).Hours()/24, 30)
+		averageOrder := row.Monetary / float64(row.Frequency)
+		annualOrders := float64(row.Frequency) * 365 / tenureDays
+		ltv := averageOrder * annualOrders * AppConfig.CustomerLTVYears
+
+		metrics[i] = CustomerMetrics{
+			UserID:       row.UserID,
+			RecencyDays:  int(-recency[i]),
+			Frequency:    row.Frequency,
+			Monetary:     math.Round(row.Monetary*100) / 100,
+			FirstOrderAt: row.FirstOrderAt,
+			LastOrderAt:  row.LastOrderAt,
+			RScore:       rScores[i],
+			FScore:       fScores[i],
+			MScore:       mScores[i],
+			RFMScore:     fmt.Sprintf("%d%d%d", rScores[i], fScores[i], mScores[i]),
+			Segment:      customerSegment(rScores[i], fScores[i]),
+			LTV:          math.Round(ltv*100) / 100,
+			ComputedAt:   now,
+		}
+	}
+
+	// 整表替换，已无有效订单的客户随之移除
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&CustomerMetrics{}).Error; err != nil {
+			return err
+		}
+		if len(metrics) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(metrics, 500).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("客户指标保存失败: %v", err)
+	}
+	return len(metrics), nil
+}
+
+// StartCustomerMetricsScheduler 启动客户价值指标的夜间计算任务
+func StartCustomerMetricsScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			if now.Hour() == AppConfig.RFMComputeHour {
+				// 多实例部署时只由一个实例计算
+				key := "rfm:computed:" + now.Format("20060102")
+				if ok, _ := RDB.SetNX(CTX, key, 1, 25*time.Hour).Result(); ok {
+					if count, err := ComputeCustomerMetrics(); err != nil {
+						log.Printf("客户价值指标计算失败: %v", err)
+					} else {
+						log.Printf("客户价值指标已更新，共 %d 位客户", count)
+					}
+				}
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("客户价值指标计算任务已启动")
+}
+
+// 查询用户所在的客户群，没有有效订单时返回空字符串
+func userCustomerSegment(userID uint) string {
+	var metrics CustomerMetrics
+	if err := DB.Select("segment").Where("user_id = ?", userID).First(&metrics).Error; err != nil {
+		return ""
+	}
+	return metrics.Segment
+}
+
+// AdminUserDetail 管理端用户详情
+type AdminUserDetail struct {
+	User    User             `json:"user"`
+	Roles   []string         `json:"roles"`
+	Metrics *CustomerMetrics `json:"metrics"` // 客户价值指标，没有有效订单时为空
+}
+
+// GetAdminUser 获取用户详情（管理端）
+// @Summary 获取用户详情
+// @Description 获取用户资料、角色和客户价值指标（RFM评分、客户分群、生命周期价值），指标每晚更新
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} ApiResponse{data=AdminUserDetail} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的用户ID"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Security Bearer
+// @Router /api/admin/users/{id} [get]
+func GetAdminUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+
+	var detail AdminUserDetail
+	if err := DB.First(&detail.User, userID).Error; err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+	detail.Roles, _ = GetUserRoles(detail.User.ID)
+
+	var metrics CustomerMetrics
+	if err := DB.Where("user_id = ?", userID).First(&metrics).Error; err == nil {
+		detail.Metrics = &metrics
+	}
+
+	SuccessResponse(c, detail)
+}
+
+// GetCustomerMetrics 获取客户价值指标列表
+// @Summary 获取客户价值指标列表
+// @Description 按客户分群、RFM评分和生命周期价值筛选客户，用于圈选营销人群
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param segment query string false "客户分群" Enums(champions, loyal, potential, new, at_risk, hibernating, others)
+// @Param rfm_score query string false "RFM评分，如 555"
+// @Param min_ltv query number false "最低生命周期价值"
+// @Param sort_by query string false "排序字段" Enums(ltv, monetary, frequency, recency_days) default(ltv)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]CustomerMetrics}} "查询成功"
+// @Failure 400 {object} ApiResponse "客户分群不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/customers/metrics [get]
+func GetCustomerMetrics(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&CustomerMetrics{})
+	if segment := c.Query("segment"); segment != "" {
+		if !customerSegments[segment] {
+			BadRequestError(c, "客户分群不存在")
+			return
+		}
+		query = query.Where("segment = ?", segment)
+	}
+	if score := c.Query("rfm_score"); score != "" {
+		query = query.Where("rfm_score = ?", score)
+	}
+	if value := c.Query("min_ltv"); value != "" {
+		if minLTV, err := strconv.ParseFloat(value, 64); err == nil {
+			query = query.Where("ltv >= ?", minLTV)
+		}
+	}
+
+	orderBy := "ltv DESC"
+	switch c.Query("sort_by") {
+	case "monetary":
+		orderBy = "monetary DESC"
+	case "frequency":
+		orderBy = "frequency DESC"
+	case "recency_days":
+		orderBy = "recency_days ASC"
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var metrics []CustomerMetrics
+	if err := query.Preload("User").Order(orderBy).Limit(pageSize).Offset((page - 1) * pageSize).Find(&metrics).Error; err != nil {
+		InternalServerError(c, "客户指标查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, metrics, total, page, pageSize)
+}
+
+// RefreshCustomerMetrics 立即重新计算客户价值指标
+// @Summary 重新计算客户价值指标
+// @Description 立即重新计算全部客户的RFM评分和生命周期价值，不必等待夜间任务
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{customers=int}} "计算完成"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/customers/metrics/refresh [post]
+func RefreshCustomerMetrics(c *gin.Context) {
+	count, err := ComputeCustomerMetrics()
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+	SuccessResponse(c, gin.H{"customers": count})
+}
@@ -0,0 +1,245 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色常量
+const (
+	RoleAdmin    = "admin"    // 管理员
+	RoleSeller   = "seller"   // 商家
+	RoleCustomer = "customer" // 普通用户
+	RoleCourier  = "courier"  // 配送员
+)
+
+// 默认角色描述
+var defaultRoles = map[string]string{
+	RoleAdmin:    "平台管理员",
+	RoleSeller:   "商家",
+	RoleCustomer: "普通用户",
+	RoleCourier:  "配送员",
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SeedRoles 初始化默认角色
+func SeedRoles() error {
+	for name, desc := range defaultRoles {
+		role := Role{Name: name, Description: desc}
+		if err := DB.Where("name = ?", name).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+	}
+
+	// 兼容旧逻辑：尚无管理员时，将ID为1的用户设为管理员
+	var adminRole Role
+	if err := DB.Where("name = ?", RoleAdmin).First(&adminRole).Error; err != nil {
+		return err
+	}
+	var adminCount int64
+	DB.Model(&UserRole{}).Where("role_id = ?", adminRole.ID).Count(&adminCount)
+	if adminCount == 0 {
+		var user User
+		if err := DB.First(&user, 1).Error; err == nil {
+			if err := AssignRole(user.ID, RoleAdmin); err != nil {
+				return err
+			}
+			log.Printf("已将用户 %s(ID:%d) 设置为管理员", user.Username, user.ID)
+		}
+	}
+
+	return nil
+}
+
+// AssignRole 为用户分配角色
+func AssignRole(userID uint, roleName string) error {
+	var role Role
+	if err := DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return fmt.Errorf("角色 %s 不存在", roleName)
+	}
+
+	userRole := UserRole{UserID: userID, RoleID: role.ID}
+	return DB.Where("user_id = ? AND role_id = ?", userID, role.ID).FirstOrCreate(&userRole).Error
+}
+
+// RevokeRole 撤销用户角色
+func RevokeRole(userID uint, roleName string) error {
+	var role Role
+	if err := DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return fmt.Errorf("角色 %s 不存在", roleName)
+	}
+	return DB.Where("user_id = ? AND role_id = ?", userID, role.ID).Delete(&UserRole{}).Error
+}
+
+// GetUserRoles 获取用户的角色列表
+func GetUserRoles(userID uint) ([]string, error) {
+	var roles []string
+	err := DB.Model(&UserRole{}).
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("roles.name", &roles).Error
+	return roles, err
+}
+
+// HasRole 判断当前请求用户是否拥有指定角色之一
+func HasRole(c *gin.Context, roles ...string) bool {
+	value, exists := c.Get("roles")
+	if !exists {
+		return false
+	}
+	userRoles, _ := value.([]string)
+	for _, userRole := range userRoles {
+		for _, role := range roles {
+			if userRole == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireRole 角色权限中间件，拥有任一指定角色即可访问
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 直接校验token而不嵌套调用 JWTAuthMiddleware，否则其中的 c.Next() 会在检查角色之前执行后续处理函数
+		if message := authenticateRequest(c); message != "" {
+			ErrorResponse(c, http.StatusUnauthorized, message)
+			c.Abort()
+			return
+		}
+
+		if !HasRole(c, roles...) {
+			ErrorResponse(c, http.StatusForbidden, "权限不足")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetRoles 获取角色列表
+// @Summary 获取角色列表
+// @Description 获取系统中所有角色
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Role} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/roles [get]
+func GetRoles(c *gin.Context) {
+	var roles []Role
+	if err := DB.Order("id ASC").Find(&roles).Error; err != nil {
+		InternalServerError(c, "角色查询失败")
+		return
+	}
+	SuccessResponse(c, roles)
+}
+
+// GetUserRolesHandler 获取指定用户的角色
+// @Summary 获取用户角色
+// @Description 获取指定用户拥有的角色
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Success 200 {object} ApiResponse{data=[]string} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的用户ID"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Security Bearer
+// @Router /api/admin/users/{id}/roles [get]
+func GetUserRolesHandler(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+
+	if _, err := GetUserByID(uint(userID)); err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+
+	roles, err := GetUserRoles(uint(userID))
+	if err != nil {
+		InternalServerError(c, "角色查询失败")
+		return
+	}
+	SuccessResponse(c, roles)
+}
+
+// AssignUserRole 为用户分配角色
+// @Summary 分配用户角色
+// @Description 为指定用户分配角色，用户重新登录后生效
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param role body AssignRoleRequest true "角色"
+// @Success 200 {object} ApiResponse{data=[]string} "分配成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或角色不存在"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Security Bearer
+// @Router /api/admin/users/{id}/roles [post]
+func AssignUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	if _, err := GetUserByID(uint(userID)); err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+
+	if err := AssignRole(uint(userID), req.Role); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	roles, _ := GetUserRoles(uint(userID))
+	SuccessResponse(c, roles)
+}
+
+// RevokeUserRole 撤销用户角色
+// @Summary 撤销用户角色
+// @Description 撤销指定用户的某个角色
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param role path string true "角色标识"
+// @Success 200 {object} ApiResponse{data=[]string} "撤销成功"
+// @Failure 400 {object} ApiResponse "无效的用户ID或角色不存在"
+// @Security Bearer
+// @Router /api/admin/users/{id}/roles/{role} [delete]
+func RevokeUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+
+	if err := RevokeRole(uint(userID), c.Param("role")); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	roles, _ := GetUserRoles(uint(userID))
+	SuccessResponse(c, roles)
+}
@@ -0,0 +1,121 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Main 以独立服务方式运行商城：加载配置、处理命令行子命令、初始化连接和后台任务并启动HTTP监听，
+// 由 cmd/gomall 调用；嵌入到其他程序时使用 New
+func Main() {
+	// 加载配置
+	AppConfig = LoadConfig()
+	
+	// 命令行子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "anonymize":
+			if err := RunAnonymizeCommand(os.Args[2:]); err != nil {
+				log.Fatalf("数据脱敏失败: %v", err)
+			}
+			return
+		case "backup":
+			if err := RunBackupCommand(os.Args[2:]); err != nil {
+				log.Fatalf("备份失败: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("未知的子命令: %s", os.Args[1])
+		}
+	}
+	
+	// 创建数据库
+	if err := CreateDatabase(AppConfig); err != nil {
+		log.Fatalf("数据库创建失败: %v", err)
+	}
+	
+	// 初始化数据库连接
+	if err := InitDatabase(AppConfig); err != nil {
+		log.Fatalf("数据库初始化失败: %v", err)
+	}
+	
+	// 初始化Redis连接
+	if err := InitRedis(AppConfig); err != nil {
+		log.Fatalf("Redis初始化失败: %v", err)
+	}
+	
+	// 初始化邮件和短信发送器
+	InitSenders(AppConfig)
+	
+	// 初始化验证码
+	InitCaptcha(AppConfig)
+
+	// 初始化支付渠道
+	InitPaymentGateways(AppConfig)
+	
+	// 初始化订单服务
+	InitOrderService()
+
+	// 加载商品ID集合，防止不存在的商品ID穿透缓存
+	if err := RebuildProductIDSet(); err != nil {
+		log.Printf("商品ID集合加载失败: %v", err)
+	}
+	
+	// 启动公告投递任务
+	StartAnnouncementScheduler()
+
+	// 启动运营日报任务
+	StartDigestScheduler()
+
+	// 启动客户价值指标计算任务
+	StartCustomerMetricsScheduler()
+	StartProductScheduler()
+	StartViewCountFlusher()
+	StartStockSync()
+	StartStockReservationExpirer()
+	StartWMSStockPusher()
+	StartInventorySnapshotScheduler()
+	StartCartCleanupScheduler()
+	StartCartEventListener()
+	StartOrderSLAMonitor()
+	StartRefundSyncScheduler()
+	StartPaymentReconciliationScheduler()
+
+	// 启动sitemap生成任务
+	StartSitemapScheduler()
+
+	// 启动定时备份任务
+	StartBackupScheduler()
+	
+	// 确保程序退出时关闭数据库连接
+	defer CloseDatabase()
+	
+	// 创建HTTP服务（注册中间件和全部路由）
+	r, err := NewServer(AppConfig, ServerDeps{DB: DB, Redis: RDB})
+	if err != nil {
+		log.Fatalf("服务初始化失败: %v", err)
+	}
+	
+	// 监听程序中断信号
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	
+	go func() {
+		<-c
+		log.Println("正在关闭服务器...")
+		CloseDatabase()
+		os.Exit(0)
+	}()
+	
+	// 启动服务器
+	serverAddr := ":" + AppConfig.ServerPort
+	fmt.Printf("GoMall服务器启动成功，访问地址: http://localhost%s\n", serverAddr)
+	log.Printf("服务器监听端口: %s", AppConfig.ServerPort)
+	
+	if err := r.Run(serverAddr); err != nil {
+		log.Fatalf("服务器启动失败: %v", err)
+	}
+}
\ No newline at end of file
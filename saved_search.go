@@ -0,0 +1,222 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个用户最多保存的搜索条件数量
+const savedSearchMaxPerUser = 20
+
+// CreateSavedSearchRequest 保存搜索条件
+type CreateSavedSearchRequest struct {
+	Keyword    string  `json:"keyword" binding:"required"`
+	CategoryID uint    `json:"category_id"`
+	BrandID    uint    `json:"brand_id"`
+	MinPrice   float64 `json:"min_price" binding:"min=0"`
+	MaxPrice   float64 `json:"max_price" binding:"min=0"` // 不含该价格
+}
+
+// 商品是否满足保存的搜索条件，关键字按同义词扩展后匹配名称或描述，与搜索接口一致
+func (s *SavedSearch) matches(product *Product, terms []string) bool {
+	if s.CategoryID > 0 && product.CategoryID != s.CategoryID {
+		return false
+	}
+	if s.BrandID > 0 && (product.BrandID == nil || *product.BrandID != s.BrandID) {
+		return false
+	}
+	if s.MinPrice > 0 && product.Price < s.MinPrice {
+		return false
+	}
+	if s.MaxPrice > 0 && product.Price >= s.MaxPrice {
+		return false
+	}
+
+	name := strings.ToLower(product.Name)
+	description := strings.ToLower(product.Description)
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if strings.Contains(name, term) || strings.Contains(description, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// 商品首次发布时在后台匹配用户保存的搜索
+func notifySavedSearchesOnPublish(productID uint) {
+	go notifySavedSearches(productID)
+}
+
+// 给保存的搜索条件匹配新商品的用户发送通知，同一用户只通知一次
+func notifySavedSearches(productID uint) {
+	key := fmt.Sprintf("products:saved_search:notifying:%d", productID)
+	if ok, _ := RDB.SetNX(CTX, key, 1, 10*time.Minute).Result(); !ok {
+		return
+	}
+
+	var product Product
+	if err := DB.First(&product, productID).Error; err != nil || product.Status != 1 {
+		return
+	}
+
+	// 先按筛选条件在数据库中过滤，关键字在内存中匹配
+	brandID := uint(0)
+	if product.BrandID != nil {
+		brandID = *product.BrandID
+	}
+	var searches []SavedSearch
+	err := DB.Where("category_id = 0 OR category_id = ?", product.CategoryID).
+		Where("brand_id = 0 OR brand_id = ?", brandID).
+		Where("min_price <= ?", product.Price).
+		Where("max_price = 0 OR max_price > ?", product.Price).
+		Order("id ASC").
+		Find(&searches).Error
+	if err != nil {
+		log.Printf("商品 %d 搜索订阅匹配失败: %v", productID, err)
+		return
+	}
+
+	termsByKeyword := make(map[string][]string)
+	keywordByUser := make(map[uint]string)
+	var userIDs []uint
+	var matchedIDs []uint
+	for i := range searches {
+		search := &searches[i]
+		terms, ok := termsByKeyword[search.Keyword]
+		if !ok {
+			terms = expandSearchKeyword(search.Keyword)
+			termsByKeyword[search.Keyword] = terms
+		}
+		if !search.matches(&product, terms) {
+			continue
+		}
+		matchedIDs = append(matchedIDs, search.ID)
+		if _, ok := keywordByUser[search.UserID]; !ok {
+			keywordByUser[search.UserID] = search.Keyword
+			userIDs = append(userIDs, search.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+
+	for _, userID := range userIDs {
+		content := fmt.Sprintf("您保存的搜索「%s」有新商品上架：%s", keywordByUser[userID], product.Name)
+		if err := SendNotification(userID, NotificationTypeSavedSearch, "搜索订阅有新商品", content); err != nil {
+			log.Printf("用户 %d 搜索订阅通知发送失败: %v", userID, err)
+		}
+	}
+	DB.Model(&SavedSearch{}).Where("id IN ?", matchedIDs).Update("last_notified_at", time.Now())
+}
+
+// CreateSavedSearch 保存搜索条件
+// @Summary 保存搜索条件
+// @Description 保存搜索关键字和筛选条件，之后有新发布的商品匹配时通过站内通知提醒；关键字支持同义词扩展，每个用户最多保存20个
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param search body CreateSavedSearchRequest true "搜索条件"
+// @Success 200 {object} ApiResponse{data=SavedSearch} "保存成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或数量超过上限"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/saved-searches [post]
+func CreateSavedSearch(c *gin.Context) {
+	var req CreateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	keyword := normalizeSearchQuery(req.Keyword)
+	if keyword == "" || utf8.RuneCountInString(keyword) > searchQueryMaxLength {
+		BadRequestError(c, "搜索词不能为空或过长")
+		return
+	}
+	if req.MaxPrice > 0 && req.MaxPrice <= req.MinPrice {
+		BadRequestError(c, "最高价格必须大于最低价格")
+		return
+	}
+
+	userID := c.GetUint("user_id")
+
+	var count int64
+	DB.Model(&SavedSearch{}).Where("user_id = ?", userID).Count(&count)
+	if count >= savedSearchMaxPerUser {
+		BadRequestError(c, fmt.Sprintf("最多保存%d个搜索条件", savedSearchMaxPerUser))
+		return
+	}
+
+	search := SavedSearch{
+		UserID:     userID,
+		Keyword:    keyword,
+		CategoryID: req.CategoryID,
+		BrandID:    req.BrandID,
+		MinPrice:   req.MinPrice,
+		MaxPrice:   req.MaxPrice,
+	}
+	if err := DB.Create(&search).Error; err != nil {
+		InternalServerError(c, "搜索条件保存失败")
+		return
+	}
+
+	SuccessResponse(c, search)
+}
+
+// GetSavedSearches 获取保存的搜索条件
+// @Summary 获取保存的搜索条件
+// @Description 获取当前用户保存的全部搜索条件
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]SavedSearch} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/saved-searches [get]
+func GetSavedSearches(c *gin.Context) {
+	var searches []SavedSearch
+	if err := DB.Where("user_id = ?", c.GetUint("user_id")).Order("created_at DESC, id DESC").Find(&searches).Error; err != nil {
+		InternalServerError(c, "搜索条件查询失败")
+		return
+	}
+	SuccessResponse(c, searches)
+}
+
+// DeleteSavedSearch 删除保存的搜索条件
+// @Summary 删除保存的搜索条件
+// @Description 删除后不再接收该搜索条件的新商品通知
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param id path int true "搜索条件ID"
+// @Success 200 {object} ApiResponse "删除成功"
+// @Failure 400 {object} ApiResponse "无效的搜索条件ID"
+// @Failure 404 {object} ApiResponse "搜索条件不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/saved-searches/{id} [delete]
+func DeleteSavedSearch(c *gin.Context) {
+	searchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的搜索条件ID")
+		return
+	}
+
+	result := DB.Where("id = ? AND user_id = ?", searchID, c.GetUint("user_id")).Delete(&SavedSearch{})
+	if result.Error != nil {
+		InternalServerError(c, "搜索条件删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "搜索条件不存在")
+		return
+	}
+
+	SuccessResponse(c, nil)
+}
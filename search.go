@@ -0,0 +1,337 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 热门搜索词有序集合，分数为搜索次数
+const searchQueriesKey = "search:queries"
+
+const (
+	searchQueryMaxLength = 50    // 超过该长度的搜索词不计入热门搜索
+	searchQueriesMaxSize = 20000 // 热门搜索词最多保留的数量，超出时淘汰次数最少的
+	suggestLimit         = 5     // 每类联想结果的数量
+	suggestScanSize      = 1000  // 匹配热门搜索词时扫描的数量
+	suggestCacheTTL      = 5 * time.Minute
+	facetBucketLimit     = 20 // 分类、品牌聚合最多返回的数量
+)
+
+// 搜索聚合维度，计算某个维度的计数时不应用该维度自身的筛选条件，
+// 这样选中一个分类后仍能看到其他分类的数量
+const (
+	facetCategory = "category"
+	facetBrand    = "brand"
+	facetPrice    = "price"
+)
+
+// SuggestItem 联想结果中的商品、分类或品牌
+type SuggestItem struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// SearchSuggestion 搜索联想结果
+type SearchSuggestion struct {
+	Products   []SuggestItem `json:"products"`   // 名称以输入开头的商品
+	Queries    []string      `json:"queries"`    // 以输入开头的热门搜索词
+	Categories []SuggestItem `json:"categories"` // 名称以输入开头的分类
+	Brands     []SuggestItem `json:"brands"`     // 名称以输入开头的品牌
+}
+
+// 统一搜索词格式：去掉首尾空白、合并连续空白并转为小写
+func normalizeSearchQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// 转义 LIKE 通配符，用户输入按字面匹配
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(value)
+}
+
+// 记录一次搜索，用于热门搜索词联想
+func recordSearchQuery(keyword string) {
+	query := normalizeSearchQuery(keyword)
+	if query == "" || utf8.RuneCountInString(query) > searchQueryMaxLength {
+		return
+	}
+	RDB.ZIncrBy(CTX, searchQueriesKey, 1, query)
+	if count, err := RDB.ZCard(CTX, searchQueriesKey).Result(); err == nil && count > searchQueriesMaxSize {
+		RDB.ZRemRangeByRank(CTX, searchQueriesKey, 0, count-searchQueriesMaxSize-1)
+	}
+}
+
+// 热门搜索词中以 prefix 开头的词，按搜索次数排序
+func popularQueriesWithPrefix(prefix string) []string {
+	queries := make([]string, 0, suggestLimit)
+	candidates, err := RDB.ZRevRange(CTX, searchQueriesKey, 0, suggestScanSize-1).Result()
+	if err != nil {
+		return queries
+	}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			queries = append(queries, candidate)
+			if len(queries) == suggestLimit {
+				break
+			}
+		}
+	}
+	return queries
+}
+
+func buildSearchSuggestion(prefix string) (*SearchSuggestion, error) {
+	pattern := escapeLikePattern(prefix) + "%"
+	suggestion := &SearchSuggestion{
+		Products:   make([]SuggestItem, 0),
+		Categories: make([]SuggestItem, 0),
+		Brands:     make([]SuggestItem, 0),
+	}
+
+	if err := DB.Model(&Product{}).Select("id, name").
+		Where("status = ? AND name LIKE ?", 1, pattern).
+		Order("sales_count DESC").Limit(suggestLimit).Scan(&suggestion.Products).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Category{}).Select("id, name").
+		Where("status = ? AND name LIKE ?", 1, pattern).
+		Order("sort_order ASC, id ASC").Limit(suggestLimit).Scan(&suggestion.Categories).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Brand{}).Select("id, name").
+		Where("status = ? AND name LIKE ?", 1, pattern).
+		Order("sort_order ASC, id ASC").Limit(suggestLimit).Scan(&suggestion.Brands).Error; err != nil {
+		return nil, err
+	}
+	suggestion.Queries = popularQueriesWithPrefix(prefix)
+	return suggestion, nil
+}
+
+// SuggestProducts 搜索联想
+// @Summary 搜索联想
+// @Description 根据输入前缀返回商品名称、热门搜索词、分类和品牌，每类最多5条；结果缓存5分钟，适合输入框防抖后调用
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param q query string true "输入前缀（最多50个字符）"
+// @Success 200 {object} ApiResponse{data=SearchSuggestion} "查询成功"
+// @Failure 400 {object} ApiResponse "输入为空或过长"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/products/suggest [get]
+func SuggestProducts(c *gin.Context) {
+	prefix := normalizeSearchQuery(c.Query("q"))
+	if prefix == "" {
+		BadRequestError(c, "输入不能为空")
+		return
+	}
+	if utf8.RuneCountInString(prefix) > searchQueryMaxLength {
+		BadRequestError(c, "输入过长")
+		return
+	}
+
+	cacheKey := "products:suggest:" + prefix
+	if data, err := RDB.Get(CTX, cacheKey).Result(); err == nil {
+		var suggestion SearchSuggestion
+		if json.Unmarshal([]byte(data), &suggestion) == nil {
+			SuccessResponse(c, suggestion)
+			return
+		}
+	}
+
+	suggestion, err := buildSearchSuggestion(prefix)
+	if err != nil {
+		InternalServerError(c, "搜索联想失败")
+		return
+	}
+	if data, err := json.Marshal(suggestion); err == nil {
+		RDB.Set(CTX, cacheKey, data, suggestCacheTTL)
+	}
+
+	SuccessResponse(c, suggestion)
+}
+
+// SearchFilters 搜索筛选条件
+type SearchFilters struct {
+	CategoryID uint    `form:"category_id"`
+	BrandID    uint    `form:"brand_id"`
+	MinPrice   float64 `form:"min_price" binding:"min=0"`
+	MaxPrice   float64 `form:"max_price" binding:"min=0"` // 不含该价格，与价格区间聚合一致
+}
+
+func (f SearchFilters) cacheKey() string {
+	return fmt.Sprintf("%d:%d:%.2f:%.2f", f.CategoryID, f.BrandID, f.MinPrice, f.MaxPrice)
+}
+
+// FacetBucket 分类或品牌的聚合计数
+type FacetBucket struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// PriceFacetBucket 价格区间的聚合计数，区间包含 min 不含 max，max 为空表示不设上限
+type PriceFacetBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+// SearchFacets 搜索结果的聚合计数，用于渲染筛选栏
+type SearchFacets struct {
+	Categories  []FacetBucket      `json:"categories"`
+	Brands      []FacetBucket      `json:"brands"`
+	PriceRanges []PriceFacetBucket `json:"price_ranges"`
+}
+
+// SearchResponse 搜索结果分页及聚合计数
+type SearchResponse struct {
+	PaginationResponse
+	Facets *SearchFacets `json:"facets"`
+}
+
+// 构建搜索查询，名称或描述包含任意一个搜索词即匹配，exclude 指定的聚合维度不应用筛选条件
+func searchQuery(terms []string, filters SearchFilters, exclude string) *gorm.DB {
+	conditions := make([]string, len(terms))
+	args := make([]interface{}, 0, len(terms)*2)
+	for i, term := range terms {
+		conditions[i] = "products.name LIKE ? OR products.description LIKE ?"
+		searchTerm := "%" + term + "%"
+		args = append(args, searchTerm, searchTerm)
+	}
+	query := DB.Model(&Product{}).Where("products.status = ?", 1).Where("("+strings.Join(conditions, " OR ")+")", args...)
+	if filters.CategoryID > 0 && exclude != facetCategory {
+		query = query.Where("products.category_id = ?", filters.CategoryID)
+	}
+	if filters.BrandID > 0 && exclude != facetBrand {
+		query = query.Where("products.brand_id = ?", filters.BrandID)
+	}
+	if exclude != facetPrice {
+		if filters.MinPrice > 0 {
+			query = query.Where("products.price >= ?", filters.MinPrice)
+		}
+		if filters.MaxPrice > 0 {
+			query = query.Where("products.price < ?", filters.MaxPrice)
+		}
+	}
+	return query
+}
+
+// 解析价格区间分界点，如 "0,50,100" 表示 0-50、50-100、100以上
+func parseSearchPriceRanges(value string) []float64 {
+	var bounds []float64
+	for _, item := range splitEnvList(value) {
+		if bound, err := strconv.ParseFloat(item, 64); err == nil && bound >= 0 {
+			bounds = append(bounds, bound)
+		}
+	}
+	sort.Float64s(bounds)
+	return bounds
+}
+
+// 计算价格区间聚合，所有区间在一条查询中统计
+func priceFacets(query *gorm.DB) ([]PriceFacetBucket, error) {
+	bounds := parseSearchPriceRanges(AppConfig.SearchPriceRanges)
+	buckets := make([]PriceFacetBucket, 0, len(bounds))
+	if len(bounds) == 0 {
+		return buckets, nil
+	}
+
+	columns := make([]string, len(bounds))
+	var args []interface{}
+	for i, min := range bounds {
+		bucket := PriceFacetBucket{Min: min}
+		if i+1 < len(bounds) {
+			max := bounds[i+1]
+			bucket.Max = &max
+			columns[i] = "COALESCE(SUM(CASE WHEN products.price >= ? AND products.price < ? THEN 1 ELSE 0 END), 0)"
+			args = append(args, min, max)
+		} else {
+			columns[i] = "COALESCE(SUM(CASE WHEN products.price >= ? THEN 1 ELSE 0 END), 0)"
+			args = append(args, min)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	counts := make([]interface{}, len(buckets))
+	for i := range buckets {
+		counts[i] = &buckets[i].Count
+	}
+	if err := query.Select(strings.Join(columns, ", "), args...).Row().Scan(counts...); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// 计算搜索结果的分类、品牌和价格区间聚合
+func computeSearchFacets(terms []string, filters SearchFilters) (*SearchFacets, error) {
+	facets := &SearchFacets{
+		Categories: make([]FacetBucket, 0),
+		Brands:     make([]FacetBucket, 0),
+	}
+
+	if err := searchQuery(terms, filters, facetCategory).
+		Select("categories.id AS id, categories.name AS name, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id").
+		Group("categories.id, categories.name").
+		Order("count DESC").Limit(facetBucketLimit).
+		Scan(&facets.Categories).Error; err != nil {
+		return nil, err
+	}
+
+	if err := searchQuery(terms, filters, facetBrand).
+		Select("brands.id AS id, brands.name AS name, COUNT(*) AS count").
+		Joins("JOIN brands ON brands.id = products.brand_id").
+		Group("brands.id, brands.name").
+		Order("count DESC").Limit(facetBucketLimit).
+		Scan(&facets.Brands).Error; err != nil {
+		return nil, err
+	}
+
+	prices, err := priceFacets(searchQuery(terms, filters, facetPrice))
+	if err != nil {
+		return nil, err
+	}
+	facets.PriceRanges = prices
+	return facets, nil
+}
+
+// 带缓存的搜索聚合，与搜索结果使用相同的缓存时间
+func getSearchFacets(keyword string, terms []string, filters SearchFilters) (*SearchFacets, error) {
+	cacheKey := fmt.Sprintf("products:search:facets:%s:%s", keyword, filters.cacheKey())
+	if data, err := RDB.Get(CTX, cacheKey).Result(); err == nil {
+		var facets SearchFacets
+		if json.Unmarshal([]byte(data), &facets) == nil {
+			return &facets, nil
+		}
+	}
+
+	facets, err := computeSearchFacets(terms, filters)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(facets); err == nil {
+		RDB.Set(CTX, cacheKey, data, AppConfig.ProductListCache.Expiration())
+	}
+	return facets, nil
+}
+
+// 返回搜索结果分页和聚合计数
+func searchSuccessResponse(c *gin.Context, products []Product, total int64, page, pageSize int, facets *SearchFacets) {
+	c.JSON(http.StatusOK, ApiResponse{
+		Code:    200,
+		Message: "success",
+		Data: SearchResponse{
+			PaginationResponse: newPaginationResponse(c, products, total, page, pageSize),
+			Facets:             facets,
+		},
+	})
+}
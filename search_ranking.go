@@ -0,0 +1,224 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 搜索干预动作
+const (
+	SearchRulePin  = "pin"  // 置顶
+	SearchRuleBury = "bury" // 沉底
+)
+
+// 搜索结果排序方式
+const (
+	SearchSortRelevance = "relevance"  // 综合排序，应用置顶/沉底规则
+	SearchSortSales     = "sales"      // 销量从高到低
+	SearchSortNewest    = "newest"     // 最新上架
+	SearchSortPriceAsc  = "price_asc"  // 价格从低到高
+	SearchSortPriceDesc = "price_desc" // 价格从高到低
+)
+
+// CreateSearchRuleRequest 创建搜索干预规则
+type CreateSearchRuleRequest struct {
+	Keyword   string `json:"keyword" binding:"required"`
+	ProductID uint   `json:"product_id" binding:"required"`
+	Action    string `json:"action" binding:"required,oneof=pin bury"`
+	Position  int    `json:"position"` // 置顶顺序，越小越靠前
+}
+
+// 排序方式是否有效
+func validSearchSort(sort string) bool {
+	switch sort {
+	case SearchSortRelevance, SearchSortSales, SearchSortNewest, SearchSortPriceAsc, SearchSortPriceDesc:
+		return true
+	}
+	return false
+}
+
+// 综合排序得分：名称命中、销量、有货和新品加分，权重由配置决定
+func searchScoreExpr(terms []string) clause.Expr {
+	nameConditions := make([]string, len(terms))
+	var vars []interface{}
+	for i, term := range terms {
+		nameConditions[i] = "products.name LIKE ?"
+		vars = append(vars, "%"+term+"%")
+	}
+
+	sql := "(CASE WHEN " + strings.Join(nameConditions, " OR ") + " THEN ? ELSE 0 END)" +
+		" + ? * LN(1 + GREATEST(products.sales_count, 0))" +
+		" + (CASE WHEN products.stock > 0 THEN ? ELSE 0 END)"
+	vars = append(vars, AppConfig.SearchNameMatchBoost, AppConfig.SearchSalesWeight, AppConfig.SearchInStockBoost)
+	if AppConfig.SearchNewnessDays > 0 {
+		sql += " + ? * GREATEST(0, 1 - TIMESTAMPDIFF(DAY, products.created_at, NOW()) / ?)"
+		vars = append(vars, AppConfig.SearchNewnessBoost, AppConfig.SearchNewnessDays)
+	}
+	return clause.Expr{SQL: sql, Vars: vars}
+}
+
+// 搜索词对应的置顶和沉底商品，置顶商品按 position 排列
+func searchRulesFor(keyword string) (pinned []uint, buried []uint) {
+	var rules []SearchRule
+	DB.Where("keyword = ?", normalizeSearchQuery(keyword)).Order("position ASC, id ASC").Find(&rules)
+	for _, rule := range rules {
+		if rule.Action == SearchRulePin {
+			pinned = append(pinned, rule.ProductID)
+		} else {
+			buried = append(buried, rule.ProductID)
+		}
+	}
+	return pinned, buried
+}
+
+// 按排序方式为搜索查询添加排序：综合排序时置顶商品在最前、沉底商品在最后，其余按得分排序
+func applySearchRanking(query *gorm.DB, keyword string, terms []string, sort string) *gorm.DB {
+	switch sort {
+	case SearchSortSales:
+		return query.Order("products.sales_count DESC, products.created_at DESC")
+	case SearchSortNewest:
+		return query.Order("products.created_at DESC")
+	case SearchSortPriceAsc:
+		return query.Order("products.price ASC, products.id ASC")
+	case SearchSortPriceDesc:
+		return query.Order("products.price DESC, products.id ASC")
+	}
+
+	var parts []string
+	var vars []interface{}
+	pinned, buried := searchRulesFor(keyword)
+	if len(pinned) > 0 {
+		sql := "CASE products.id"
+		for i, productID := range pinned {
+			sql += fmt.Sprintf(" WHEN ? THEN %d", i)
+			vars = append(vars, productID)
+		}
+		parts = append(parts, sql+fmt.Sprintf(" ELSE %d END", len(pinned)))
+	}
+	if len(buried) > 0 {
+		parts = append(parts, "CASE WHEN products.id IN ? THEN 1 ELSE 0 END")
+		vars = append(vars, buried)
+	}
+	score := searchScoreExpr(terms)
+	parts = append(parts, "("+score.SQL+") DESC", "products.id DESC")
+	vars = append(vars, score.Vars...)
+
+	return query.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(parts, ", "), Vars: vars}})
+}
+
+// 搜索干预规则或同义词变更后清除搜索结果缓存
+func invalidateSearchResultCaches() {
+	if keys, _ := RDB.Keys(CTX, "products:search:*").Result(); len(keys) > 0 {
+		RDB.Del(CTX, keys...)
+	}
+}
+
+// GetSearchRules 获取搜索干预规则
+// @Summary 获取搜索干预规则
+// @Description 获取置顶/沉底规则，可按搜索词筛选
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param keyword query string false "搜索词"
+// @Success 200 {object} ApiResponse{data=[]SearchRule} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/rules [get]
+func GetSearchRules(c *gin.Context) {
+	query := DB.Preload("Product")
+	if keyword := normalizeSearchQuery(c.Query("keyword")); keyword != "" {
+		query = query.Where("keyword = ?", keyword)
+	}
+
+	var rules []SearchRule
+	if err := query.Order("keyword ASC, action DESC, position ASC, id ASC").Find(&rules).Error; err != nil {
+		InternalServerError(c, "搜索干预规则查询失败")
+		return
+	}
+	SuccessResponse(c, rules)
+}
+
+// CreateSearchRule 置顶或沉底商品
+// @Summary 置顶或沉底商品
+// @Description 在指定搜索词的综合排序结果中置顶（pin）或沉底（bury）商品；同一搜索词下重复设置同一商品时覆盖原规则
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param rule body CreateSearchRuleRequest true "干预规则"
+// @Success 200 {object} ApiResponse{data=SearchRule} "设置成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "商品不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/rules [post]
+func CreateSearchRule(c *gin.Context) {
+	var req CreateSearchRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	keyword := normalizeSearchQuery(req.Keyword)
+	if keyword == "" || utf8.RuneCountInString(keyword) > searchQueryMaxLength {
+		BadRequestError(c, "搜索词不能为空或过长")
+		return
+	}
+
+	var product Product
+	if err := DB.Select("id").First(&product, req.ProductID).Error; err != nil {
+		NotFoundError(c, "商品不存在")
+		return
+	}
+
+	rule := SearchRule{Keyword: keyword, ProductID: product.ID}
+	err := DB.Where("keyword = ? AND product_id = ?", keyword, product.ID).
+		Assign(SearchRule{Action: req.Action, Position: req.Position, CreatedBy: c.GetUint("user_id")}).
+		FirstOrCreate(&rule).Error
+	if err != nil {
+		InternalServerError(c, "搜索干预规则保存失败")
+		return
+	}
+	invalidateSearchResultCaches()
+
+	DB.Preload("Product").First(&rule, rule.ID)
+	SuccessResponse(c, rule)
+}
+
+// DeleteSearchRule 删除搜索干预规则
+// @Summary 删除搜索干预规则
+// @Description 取消商品在搜索词下的置顶或沉底
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param id path int true "规则ID"
+// @Success 200 {object} ApiResponse "删除成功"
+// @Failure 400 {object} ApiResponse "无效的规则ID"
+// @Failure 404 {object} ApiResponse "规则不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/rules/{id} [delete]
+func DeleteSearchRule(c *gin.Context) {
+	ruleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的规则ID")
+		return
+	}
+
+	result := DB.Delete(&SearchRule{}, ruleID)
+	if result.Error != nil {
+		InternalServerError(c, "搜索干预规则删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "规则不存在")
+		return
+	}
+	invalidateSearchResultCaches()
+
+	SuccessResponse(c, nil)
+}
@@ -0,0 +1,237 @@
+package gomall
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 同义词表缓存：搜索词到同义词列表的映射
+const searchSynonymsCacheKey = "search:synonyms"
+
+const searchSynonymsCacheTTL = 24 * time.Hour
+
+// SearchSynonymRequest 创建或更新同义词组
+type SearchSynonymRequest struct {
+	Terms []string `json:"terms" binding:"required,min=2,max=20"` // 同义词，如 ["笔记本", "laptop", "notebook"]
+}
+
+// 规范化同义词：统一格式并去重，至少保留两个不同的词
+func normalizeSynonymTerms(terms []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = normalizeSearchQuery(term)
+		if term == "" || seen[term] {
+			continue
+		}
+		if utf8.RuneCountInString(term) > searchQueryMaxLength || strings.Contains(term, ",") {
+			return nil, fmt.Errorf("同义词「%s」过长或包含逗号", term)
+		}
+		seen[term] = true
+		normalized = append(normalized, term)
+	}
+	if len(normalized) < 2 {
+		return nil, fmt.Errorf("同义词组至少需要两个不同的词")
+	}
+	if len(strings.Join(normalized, ",")) > 500 {
+		return nil, fmt.Errorf("同义词组过长")
+	}
+	return normalized, nil
+}
+
+// 从数据库构建同义词映射，一个词出现在多个组中时合并各组的词
+func buildSynonymMap() (map[string][]string, error) {
+	var synonyms []SearchSynonym
+	if err := DB.Find(&synonyms).Error; err != nil {
+		return nil, err
+	}
+	synonymMap := make(map[string][]string)
+	for _, synonym := range synonyms {
+		terms := strings.Split(synonym.Terms, ",")
+		for _, term := range terms {
+			for _, other := range terms {
+				if other != term && !containsString(synonymMap[term], other) {
+					synonymMap[term] = append(synonymMap[term], other)
+				}
+			}
+		}
+	}
+	return synonymMap, nil
+}
+
+// 读取同义词映射，缓存不存在时从数据库加载
+func loadSynonymMap() map[string][]string {
+	if data, err := RDB.Get(CTX, searchSynonymsCacheKey).Result(); err == nil {
+		var synonymMap map[string][]string
+		if json.Unmarshal([]byte(data), &synonymMap) == nil {
+			return synonymMap
+		}
+	}
+
+	synonymMap, err := buildSynonymMap()
+	if err != nil {
+		return nil
+	}
+	if data, err := json.Marshal(synonymMap); err == nil {
+		RDB.Set(CTX, searchSynonymsCacheKey, data, searchSynonymsCacheTTL)
+	}
+	return synonymMap
+}
+
+// 按同义词扩展搜索关键字，返回的第一个词为原关键字
+func expandSearchKeyword(keyword string) []string {
+	terms := []string{keyword}
+	normalized := normalizeSearchQuery(keyword)
+	for _, synonym := range loadSynonymMap()[normalized] {
+		if synonym != normalized {
+			terms = append(terms, synonym)
+		}
+	}
+	return terms
+}
+
+// 同义词变更后清除同义词缓存和搜索结果缓存
+func invalidateSynonymCaches() {
+	RDB.Del(CTX, searchSynonymsCacheKey)
+	invalidateSearchResultCaches()
+}
+
+// GetSearchSynonyms 获取同义词列表
+// @Summary 获取同义词列表
+// @Description 获取全部搜索同义词组
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]SearchSynonym} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/synonyms [get]
+func GetSearchSynonyms(c *gin.Context) {
+	var synonyms []SearchSynonym
+	if err := DB.Order("id ASC").Find(&synonyms).Error; err != nil {
+		InternalServerError(c, "同义词查询失败")
+		return
+	}
+	SuccessResponse(c, synonyms)
+}
+
+// CreateSearchSynonym 创建同义词组
+// @Summary 创建同义词组
+// @Description 创建一组同义词，搜索其中任意一个词时同时匹配其他词；词语统一转为小写
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param synonym body SearchSynonymRequest true "同义词"
+// @Success 200 {object} ApiResponse{data=SearchSynonym} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/synonyms [post]
+func CreateSearchSynonym(c *gin.Context) {
+	var req SearchSynonymRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	terms, err := normalizeSynonymTerms(req.Terms)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	synonym := SearchSynonym{Terms: strings.Join(terms, ",")}
+	if err := DB.Create(&synonym).Error; err != nil {
+		InternalServerError(c, "同义词创建失败")
+		return
+	}
+	invalidateSynonymCaches()
+
+	SuccessResponse(c, synonym)
+}
+
+// UpdateSearchSynonym 更新同义词组
+// @Summary 更新同义词组
+// @Description 用新的词语替换同义词组
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param id path int true "同义词组ID"
+// @Param synonym body SearchSynonymRequest true "同义词"
+// @Success 200 {object} ApiResponse{data=SearchSynonym} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "同义词组不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/synonyms/{id} [put]
+func UpdateSearchSynonym(c *gin.Context) {
+	synonymID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的同义词组ID")
+		return
+	}
+
+	var req SearchSynonymRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	terms, err := normalizeSynonymTerms(req.Terms)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	var synonym SearchSynonym
+	if err := DB.First(&synonym, synonymID).Error; err != nil {
+		NotFoundError(c, "同义词组不存在")
+		return
+	}
+	if err := DB.Model(&synonym).Update("terms", strings.Join(terms, ",")).Error; err != nil {
+		InternalServerError(c, "同义词更新失败")
+		return
+	}
+	DB.First(&synonym, synonym.ID)
+	invalidateSynonymCaches()
+
+	SuccessResponse(c, synonym)
+}
+
+// DeleteSearchSynonym 删除同义词组
+// @Summary 删除同义词组
+// @Description 删除同义词组，搜索结果缓存随之清除
+// @Tags 商品搜索
+// @Accept json
+// @Produce json
+// @Param id path int true "同义词组ID"
+// @Success 200 {object} ApiResponse "删除成功"
+// @Failure 400 {object} ApiResponse "无效的同义词组ID"
+// @Failure 404 {object} ApiResponse "同义词组不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/search/synonyms/{id} [delete]
+func DeleteSearchSynonym(c *gin.Context) {
+	synonymID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的同义词组ID")
+		return
+	}
+
+	result := DB.Delete(&SearchSynonym{}, synonymID)
+	if result.Error != nil {
+		InternalServerError(c, "同义词删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "同义词组不存在")
+		return
+	}
+	invalidateSynonymCaches()
+
+	SuccessResponse(c, nil)
+}
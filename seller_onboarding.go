@@ -0,0 +1,411 @@
+package gomall
+
+import (
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 入驻申请状态
+const (
+	SellerApplicationPending  = "pending"  // 待审核
+	SellerApplicationApproved = "approved" // 已通过
+	SellerApplicationRejected = "rejected" // 已驳回
+)
+
+// 入驻资料类型，对应表单中的文件字段名
+const (
+	KYCDocumentLicense = "license"  // 营业执照
+	KYCDocumentIDFront = "id_front" // 身份证人像面
+	KYCDocumentIDBack  = "id_back"  // 身份证国徽面
+)
+
+// 新开通店铺默认的无理由退货天数
+const defaultShopReturnDays = 7
+
+// 入驻资料允许的文件格式
+var kycDocumentExts = []string{".jpg", ".jpeg", ".png", ".pdf"}
+
+// RejectSellerApplicationRequest 驳回入驻申请
+type RejectSellerApplicationRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// 入驻资料保存在不对外公开的目录，只能由管理员通过接口查看
+func saveKYCDocument(c *gin.Context, file *multipart.FileHeader, userID uint, kind string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !containsString(kycDocumentExts, ext) {
+		return "", fmt.Errorf("文件 %s 格式不支持，请上传 jpg、png 或 pdf", file.Filename)
+	}
+	if file.Size > AppConfig.MaxFileSize {
+		return "", fmt.Errorf("文件 %s 大小超过限制", file.Filename)
+	}
+
+	os.MkdirAll(AppConfig.KYCUploadPath, 0700)
+	random, err := generateSecureToken(12)
+	if err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("kyc_%d_%s_%s%s", userID, kind, random, ext)
+	path := filepath.Join(AppConfig.KYCUploadPath, filename)
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		return "", fmt.Errorf("文件 %s 保存失败", file.Filename)
+	}
+
+	uploadedFile := UploadedFile{
+		OriginalName: file.Filename,
+		FileName:     filename,
+		FilePath:     path,
+		FileSize:     file.Size,
+		MimeType:     file.Header.Get("Content-Type"),
+		UploadedBy:   userID,
+	}
+	if err := DB.Create(&uploadedFile).Error; err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("文件 %s 记录失败", file.Filename)
+	}
+	return path, nil
+}
+
+func (a *SellerApplication) documentPath(kind string) string {
+	switch kind {
+	case KYCDocumentLicense:
+		return a.LicenseFile
+	case KYCDocumentIDFront:
+		return a.IDFrontFile
+	case KYCDocumentIDBack:
+		return a.IDBackFile
+	}
+	return ""
+}
+
+// 审核通过后开通店铺并授予商家角色
+func provisionShop(tx *gorm.DB, application *SellerApplication) (*Shop, error) {
+	shop := Shop{
+		OwnerID:      application.UserID,
+		Name:         application.ShopName,
+		ContactPhone: application.ContactPhone,
+		ReturnDays:   defaultShopReturnDays,
+		Status:       1,
+	}
+	if err := tx.Create(&shop).Error; err != nil {
+		return nil, fmt.Errorf("店铺创建失败")
+	}
+
+	var role Role
+	if err := tx.Where("name = ?", RoleSeller).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("角色 %s 不存在", RoleSeller)
+	}
+	userRole := UserRole{UserID: application.UserID, RoleID: role.ID}
+	if err := tx.Where("user_id = ? AND role_id = ?", application.UserID, role.ID).FirstOrCreate(&userRole).Error; err != nil {
+		return nil, fmt.Errorf("商家角色分配失败")
+	}
+	return &shop, nil
+}
+
+func loadSellerApplication(c *gin.Context) (*SellerApplication, bool) {
+	applicationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的申请ID")
+		return nil, false
+	}
+	var application SellerApplication
+	if err := DB.Preload("User").First(&application, applicationID).Error; err != nil {
+		NotFoundError(c, "入驻申请不存在")
+		return nil, false
+	}
+	return &application, true
+}
+
+// 以待审核状态为条件更新，防止重复审核
+func reviewSellerApplication(tx *gorm.DB, application *SellerApplication, reviewerID uint, status, reason string) error {
+	now := time.Now()
+	result := tx.Model(&SellerApplication{}).
+		Where("id = ? AND status = ?", application.ID, SellerApplicationPending).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"reject_reason": reason,
+			"reviewed_by":   reviewerID,
+			"reviewed_at":   now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("申请已被审核")
+	}
+	application.Status = status
+	application.RejectReason = reason
+	application.ReviewedBy = &reviewerID
+	application.ReviewedAt = &now
+	return nil
+}
+
+// SubmitSellerApplication 提交商家入驻申请
+// @Summary 提交商家入驻申请
+// @Description 上传营业执照和法人身份证（jpg、png 或 pdf）申请成为商家；同一用户同时只能有一个待审核的申请，已开店的用户不能再申请。资料仅管理员可查看
+// @Tags 商家入驻
+// @Accept multipart/form-data
+// @Produce json
+// @Param shop_name formData string true "店铺名称"
+// @Param business_name formData string true "营业执照上的企业名称"
+// @Param license_number formData string true "统一社会信用代码"
+// @Param contact_phone formData string true "联系电话"
+// @Param license formData file true "营业执照"
+// @Param id_front formData file true "身份证人像面"
+// @Param id_back formData file true "身份证国徽面"
+// @Success 200 {object} ApiResponse{data=SellerApplication} "提交成功"
+// @Failure 400 {object} ApiResponse "参数错误、已有待审核申请或已开店"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/seller-applications [post]
+func SubmitSellerApplication(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	application := SellerApplication{
+		UserID:        userID,
+		ShopName:      strings.TrimSpace(c.PostForm("shop_name")),
+		BusinessName:  strings.TrimSpace(c.PostForm("business_name")),
+		LicenseNumber: strings.TrimSpace(c.PostForm("license_number")),
+		ContactPhone:  strings.TrimSpace(c.PostForm("contact_phone")),
+		Status:        SellerApplicationPending,
+	}
+	if application.ShopName == "" || application.BusinessName == "" ||
+		application.LicenseNumber == "" || application.ContactPhone == "" {
+		BadRequestError(c, "店铺名称、企业名称、统一社会信用代码和联系电话均为必填")
+		return
+	}
+	if len([]rune(application.ShopName)) > 50 || len([]rune(application.BusinessName)) > 100 ||
+		len(application.LicenseNumber) > 50 || len(application.ContactPhone) > 20 {
+		BadRequestError(c, "填写的内容过长")
+		return
+	}
+
+	var count int64
+	DB.Model(&Shop{}).Where("owner_id = ?", userID).Count(&count)
+	if count > 0 {
+		BadRequestError(c, "您已开通店铺")
+		return
+	}
+	DB.Model(&SellerApplication{}).Where("user_id = ? AND status = ?", userID, SellerApplicationPending).Count(&count)
+	if count > 0 {
+		BadRequestError(c, "您已有待审核的入驻申请")
+		return
+	}
+	DB.Model(&Shop{}).Where("name = ?", application.ShopName).Count(&count)
+	if count > 0 {
+		BadRequestError(c, "店铺名称已被使用")
+		return
+	}
+
+	for _, kind := range []string{KYCDocumentLicense, KYCDocumentIDFront, KYCDocumentIDBack} {
+		file, err := c.FormFile(kind)
+		if err != nil {
+			BadRequestError(c, "请上传营业执照和身份证正反面")
+			return
+		}
+		path, err := saveKYCDocument(c, file, userID, kind)
+		if err != nil {
+			BadRequestError(c, err.Error())
+			return
+		}
+		switch kind {
+		case KYCDocumentLicense:
+			application.LicenseFile = path
+		case KYCDocumentIDFront:
+			application.IDFrontFile = path
+		case KYCDocumentIDBack:
+			application.IDBackFile = path
+		}
+	}
+
+	if err := DB.Create(&application).Error; err != nil {
+		InternalServerError(c, "入驻申请提交失败")
+		return
+	}
+
+	SuccessResponse(c, application)
+}
+
+// GetMySellerApplications 获取我的入驻申请
+// @Summary 获取我的入驻申请
+// @Description 查看自己提交的入驻申请及审核结果（含驳回原因），按提交时间倒序
+// @Tags 商家入驻
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{applications=[]SellerApplication,shop=Shop}} "查询成功"
+// @Security Bearer
+// @Router /api/seller-applications/mine [get]
+func GetMySellerApplications(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	applications := []SellerApplication{}
+	DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&applications)
+
+	response := gin.H{"applications": applications, "shop": nil}
+	var shop Shop
+	if err := DB.Where("owner_id = ?", userID).First(&shop).Error; err == nil {
+		response["shop"] = shop
+	}
+	SuccessResponse(c, response)
+}
+
+// GetSellerApplications 获取入驻申请审核队列
+// @Summary 获取入驻申请审核队列
+// @Description 按状态分页查看商家入驻申请，待审核的申请按提交时间从早到晚排列
+// @Tags 商家入驻
+// @Accept json
+// @Produce json
+// @Param status query string false "申请状态" Enums(pending, approved, rejected) default(pending)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]SellerApplication}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/seller-applications [get]
+func GetSellerApplications(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	status := c.DefaultQuery("status", SellerApplicationPending)
+	query := DB.Model(&SellerApplication{}).Where("status = ?", status)
+
+	var total int64
+	query.Count(&total)
+
+	orderBy := "created_at ASC"
+	if status != SellerApplicationPending {
+		orderBy = "reviewed_at DESC"
+	}
+	var applications []SellerApplication
+	if err := query.Preload("User").Order(orderBy).Limit(pageSize).Offset((page - 1) * pageSize).Find(&applications).Error; err != nil {
+		InternalServerError(c, "入驻申请查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, applications, total, page, pageSize)
+}
+
+// GetSellerApplicationDocument 查看入驻资料
+// @Summary 查看入驻资料
+// @Description 管理员查看入驻申请上传的营业执照或身份证文件
+// @Tags 商家入驻
+// @Produce octet-stream
+// @Param id path int true "申请ID"
+// @Param kind path string true "资料类型" Enums(license, id_front, id_back)
+// @Success 200 {file} file "资料文件"
+// @Failure 404 {object} ApiResponse "申请或资料不存在"
+// @Security Bearer
+// @Router /api/admin/seller-applications/{id}/documents/{kind} [get]
+func GetSellerApplicationDocument(c *gin.Context) {
+	application, ok := loadSellerApplication(c)
+	if !ok {
+		return
+	}
+
+	path := application.documentPath(c.Param("kind"))
+	if path == "" {
+		NotFoundError(c, "资料不存在")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		NotFoundError(c, "资料文件不存在")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.File(path)
+}
+
+// ApproveSellerApplication 通过入驻申请
+// @Summary 通过入驻申请
+// @Description 审核通过后自动开通店铺（默认7天退货、营业中）并授予商家角色，申请人刷新令牌或重新登录后即可管理商品
+// @Tags 商家入驻
+// @Accept json
+// @Produce json
+// @Param id path int true "申请ID"
+// @Success 200 {object} ApiResponse{data=object{application=SellerApplication,shop=Shop}} "审核成功"
+// @Failure 400 {object} ApiResponse "申请已被审核或店铺名称已被使用"
+// @Failure 404 {object} ApiResponse "入驻申请不存在"
+// @Security Bearer
+// @Router /api/admin/seller-applications/{id}/approve [put]
+func ApproveSellerApplication(c *gin.Context) {
+	application, ok := loadSellerApplication(c)
+	if !ok {
+		return
+	}
+
+	var shop *Shop
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := reviewSellerApplication(tx, application, c.GetUint("user_id"), SellerApplicationApproved, ""); err != nil {
+			return err
+		}
+		var err error
+		shop, err = provisionShop(tx, application)
+		return err
+	})
+	if err != nil {
+		BadRequestError(c, "审核失败: "+err.Error())
+		return
+	}
+
+	SendNotification(application.UserID, NotificationTypeSystem, "商家入驻申请已通过",
+		fmt.Sprintf("您的店铺「%s」已开通，刷新登录状态后即可在商家后台发布商品。", shop.Name))
+
+	SuccessResponse(c, gin.H{"application": application, "shop": shop})
+}
+
+// RejectSellerApplication 驳回入驻申请
+// @Summary 驳回入驻申请
+// @Description 驳回入驻申请并填写原因，原因会通知申请人，申请人可修改资料后重新提交
+// @Tags 商家入驻
+// @Accept json
+// @Produce json
+// @Param id path int true "申请ID"
+// @Param reject body RejectSellerApplicationRequest true "驳回原因"
+// @Success 200 {object} ApiResponse{data=SellerApplication} "驳回成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或申请已被审核"
+// @Failure 404 {object} ApiResponse "入驻申请不存在"
+// @Security Bearer
+// @Router /api/admin/seller-applications/{id}/reject [put]
+func RejectSellerApplication(c *gin.Context) {
+	var req RejectSellerApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	application, ok := loadSellerApplication(c)
+	if !ok {
+		return
+	}
+
+	if err := reviewSellerApplication(DB, application, c.GetUint("user_id"), SellerApplicationRejected, req.Reason); err != nil {
+		BadRequestError(c, "审核失败: "+err.Error())
+		return
+	}
+
+	SendNotification(application.UserID, NotificationTypeSystem, "商家入驻申请未通过",
+		"您的入驻申请未通过审核，原因："+req.Reason+"。您可以修改资料后重新提交。")
+
+	SuccessResponse(c, application)
+}
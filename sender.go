@@ -0,0 +1,247 @@
+package gomall
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EmailSender 邮件发送接口
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+// SMSSender 短信发送接口
+// 国内短信通道只能按审核过的模板发送，因此接口只约定发送验证码
+type SMSSender interface {
+	SendVerifyCode(phone, code string) error
+}
+
+var (
+	// 全局邮件发送器
+	Mailer EmailSender
+	// 全局短信发送器
+	SMSProvider SMSSender
+)
+
+// InitSenders 根据配置初始化邮件和短信发送器
+func InitSenders(config *Config) {
+	if config.SMTPHost != "" {
+		Mailer = &SMTPEmailSender{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+		}
+	} else {
+		Mailer = &LogEmailSender{}
+	}
+	// 所有邮件发送前检查屏蔽名单
+	Mailer = &SuppressedEmailSender{Next: Mailer}
+
+	switch config.SMSProvider {
+	case "aliyun":
+		SMSProvider = &AliyunSMSSender{
+			AccessKeyID:     config.AliyunSMSAccessKeyID,
+			AccessKeySecret: config.AliyunSMSAccessKeySecret,
+			SignName:        config.AliyunSMSSignName,
+			TemplateCode:    config.AliyunSMSTemplateCode,
+		}
+	case "twilio":
+		SMSProvider = &TwilioSMSSender{
+			AccountSID:  config.TwilioAccountSID,
+			AuthToken:   config.TwilioAuthToken,
+			From:        config.TwilioFromNumber,
+			CountryCode: config.SMSDefaultCountryCode,
+		}
+	default:
+		SMSProvider = &LogSMSSender{}
+	}
+	SMSProvider = &SuppressedSMSSender{Next: SMSProvider}
+}
+
+// 短信验证码文案（用于可以发送自定义内容的通道）
+func verifyCodeMessage(code string) string {
+	return fmt.Sprintf("您的GoMall验证码为 %s，请勿泄露给他人。如非本人操作请忽略。", code)
+}
+
+// 短信接口统一使用的HTTP客户端
+var smsHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// SMTPEmailSender 基于SMTP的邮件发送器
+type SMTPEmailSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SendEmail 发送邮件
+func (s *SMTPEmailSender) SendEmail(to, subject, body string) error {
+	headers := []string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" + body
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
+	if err := smtp.SendMail(addr, auth, s.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("邮件发送失败: %v", err)
+	}
+	return nil
+}
+
+// LogEmailSender 仅输出日志的邮件发送器（开发环境使用）
+type LogEmailSender struct{}
+
+// SendEmail 将邮件内容写入日志
+func (s *LogEmailSender) SendEmail(to, subject, body string) error {
+	log.Printf("[邮件] 收件人: %s 主题: %s 内容: %s", to, subject, body)
+	return nil
+}
+
+// LogSMSSender 仅输出日志的短信发送器（开发环境使用）
+type LogSMSSender struct{}
+
+// SendVerifyCode 将验证码短信写入日志
+func (s *LogSMSSender) SendVerifyCode(phone, code string) error {
+	log.Printf("[短信] 手机号: %s 内容: %s", phone, verifyCodeMessage(code))
+	return nil
+}
+
+// AliyunSMSSender 阿里云短信发送器
+type AliyunSMSSender struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	TemplateCode    string // 验证码模板，模板变量为 ${code}
+}
+
+// 阿里云API要求的URL编码
+func aliyunPercentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
+
+// SendVerifyCode 通过阿里云短信模板发送验证码
+func (s *AliyunSMSSender) SendVerifyCode(phone, code string) error {
+	nonce, err := generateSecureToken(8)
+	if err != nil {
+		return err
+	}
+	templateParam, _ := json.Marshal(map[string]string{"code": code})
+
+	params := map[string]string{
+		"AccessKeyId":      s.AccessKeyID,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"PhoneNumbers":     phone,
+		"RegionId":         "cn-hangzhou",
+		"SignName":         s.SignName,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   nonce,
+		"SignatureVersion": "1.0",
+		"TemplateCode":     s.TemplateCode,
+		"TemplateParam":    string(templateParam),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+	}
+
+	// 按参数名排序后签名
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, aliyunPercentEncode(key)+"="+aliyunPercentEncode(params[key]))
+	}
+	query := strings.Join(pairs, "&")
+
+	mac := hmac.New(sha1.New, []byte(s.AccessKeySecret+"&"))
+	mac.Write([]byte("GET&%2F&" + aliyunPercentEncode(query)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	resp, err := smsHTTPClient.Get("https://dysmsapi.aliyuncs.com/?Signature=" + aliyunPercentEncode(signature) + "&" + query)
+	if err != nil {
+		return fmt.Errorf("短信发送失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("短信发送失败: %v", err)
+	}
+	if result.Code != "OK" {
+		return fmt.Errorf("短信发送失败: %s %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// TwilioSMSSender Twilio短信发送器
+type TwilioSMSSender struct {
+	AccountSID  string
+	AuthToken   string
+	From        string
+	CountryCode string // 手机号未带国际区号时补全，如 +86
+}
+
+// SendVerifyCode 通过Twilio发送验证码短信
+func (s *TwilioSMSSender) SendVerifyCode(phone, code string) error {
+	if !strings.HasPrefix(phone, "+") {
+		phone = s.CountryCode + phone
+	}
+
+	form := url.Values{}
+	form.Set("To", phone)
+	form.Set("From", s.From)
+	form.Set("Body", verifyCodeMessage(code))
+
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", s.AccountSID)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := smsHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("短信发送失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var result struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return fmt.Errorf("短信发送失败: %d %s", resp.StatusCode, result.Message)
+	}
+	return nil
+}
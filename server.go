@@ -0,0 +1,481 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ServerDeps 服务依赖的外部资源，为空时使用已初始化的全局连接
+type ServerDeps struct {
+	DB    *gorm.DB
+	Redis *redis.Client
+}
+
+// Option 嵌入模式的配置项
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	config       *Config
+	deps         ServerDeps
+	routesPrefix string
+}
+
+// WithConfig 使用指定配置，未设置时从环境变量加载
+func WithConfig(cfg *Config) Option {
+	return func(o *serverOptions) { o.config = cfg }
+}
+
+// WithDB 使用宿主程序已有的数据库连接
+func WithDB(db *gorm.DB) Option {
+	return func(o *serverOptions) { o.deps.DB = db }
+}
+
+// WithRedis 使用宿主程序已有的Redis连接
+func WithRedis(rdb *redis.Client) Option {
+	return func(o *serverOptions) { o.deps.Redis = rdb }
+}
+
+// WithRoutesPrefix 将全部路由挂载到指定前缀下，如 "/shop"
+func WithRoutesPrefix(prefix string) Option {
+	return func(o *serverOptions) { o.routesPrefix = prefix }
+}
+
+// New 以嵌入模式创建商城服务，返回可挂载到宿主程序的 http.Handler。
+// 会迁移表结构并初始化发送器、验证码、支付渠道和订单服务，只启动库存对账、库存预占超时释放和购物车事件转发这几个
+// 与请求处理直接相关的后台任务，其他定时任务由宿主程序自行调度；
+// 服务状态保存在包级变量中，每个进程只应调用一次
+func New(opts ...Option) (http.Handler, error) {
+	o := serverOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.config == nil {
+		o.config = LoadConfig()
+	}
+	if o.deps.DB == nil || o.deps.Redis == nil {
+		return nil, fmt.Errorf("嵌入模式需要通过 WithDB 和 WithRedis 提供数据库和Redis连接")
+	}
+
+	DB = o.deps.DB
+	RDB = o.deps.Redis
+	if err := PrepareSchema(); err != nil {
+		return nil, err
+	}
+	InitSenders(o.config)
+	InitCaptcha(o.config)
+	InitPaymentGateways(o.config)
+	InitOrderService()
+
+	engine, err := newEngine(o.config, o.deps, o.routesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	// Redis中的可售库存需要定期与数据库对账，嵌入时也要启动
+	StartStockSync()
+	// 未付款订单的库存预占需要到期释放，否则会一直占用库存
+	StartStockReservationExpirer()
+	// 购物车事件流依赖Redis订阅转发，嵌入时也要启动
+	StartCartEventListener()
+	return engine, nil
+}
+
+// NewServer 按配置创建Gin引擎并注册中间件和全部路由，不启动监听和后台任务，
+// 便于在测试或其他程序中通过 httptest 直接调用接口
+func NewServer(cfg *Config, deps ServerDeps) (*gin.Engine, error) {
+	return newEngine(cfg, deps, "")
+}
+
+// 创建Gin引擎，路由注册在 prefix 下，prefix 为空时挂载在根路径
+func newEngine(cfg *Config, deps ServerDeps, prefix string) (*gin.Engine, error) {
+	AppConfig = cfg
+	if deps.DB != nil {
+		DB = deps.DB
+	}
+	if deps.Redis != nil {
+		RDB = deps.Redis
+	}
+	if DB == nil || RDB == nil {
+		return nil, fmt.Errorf("数据库或Redis未初始化")
+	}
+
+	// 设置Gin模式
+	switch AppConfig.GinMode {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+	default:
+		log.Printf("警告：无效的GIN_MODE %q，将使用debug模式", AppConfig.GinMode)
+		AppConfig.GinMode = gin.DebugMode
+	}
+	gin.SetMode(AppConfig.GinMode)
+
+	// 创建Gin引擎
+	r := gin.Default()
+
+	// 配置可信代理，确保c.ClientIP()在Nginx/负载均衡后返回真实客户端IP
+	r.RemoteIPHeaders = splitEnvList(AppConfig.RemoteIPHeaders)
+	if err := r.SetTrustedProxies(splitEnvList(AppConfig.TrustedProxies)); err != nil {
+		return nil, fmt.Errorf("可信代理配置错误: %v", err)
+	}
+
+	// 维护模式，需在注册路由之前启用
+	r.Use(MaintenanceMode())
+
+	// 加载HTML模板，嵌入或测试时工作目录下可能没有模板目录，此时跳过以免 LoadHTMLGlob panic
+	if templates, _ := filepath.Glob("templates/*"); len(templates) > 0 {
+		r.LoadHTMLGlob("templates/*")
+	}
+
+	// 路由前缀，嵌入模式下挂载到宿主程序的子路径
+	root := r.Group("/" + strings.Trim(prefix, "/"))
+
+	// 设置静态文件路由
+	root.Static("/public", "./public")
+	root.Static("/upload", "./upload")
+
+	// 基础路由
+	root.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"title": "GoMall - Go语言电商平台",
+		})
+	})
+
+	// 健康检查
+	root.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "ok",
+			"message": "GoMall服务运行正常",
+		})
+	})
+
+	// SEO
+	root.GET("/robots.txt", GetRobotsTxt)
+	root.GET("/sitemap.xml", GetSitemap)
+	root.GET("/sitemaps/:file", GetSitemapPage)
+
+	// API路由组
+	api := root.Group("/api")
+	{
+		// 验证码API
+		api.GET("/captcha", GetCaptcha) // 获取图片验证码
+
+		// 用户相关API
+		users := api.Group("/users")
+		{
+			users.POST("/register", RequireCaptcha(), UserRegister)               // 用户注册
+			users.POST("/login", UserLogin)                                       // 用户登录
+			users.POST("/sms-code", RequireCaptcha(), SendSMSCode)                // 发送短信登录验证码
+			users.POST("/login/sms", SMSLogin)                                    // 短信验证码登录
+			users.POST("/refresh", RefreshAccessToken)                            // 刷新访问令牌
+			users.POST("/logout", RequireUser(), UserLogout)                      // 用户登出
+			users.GET("/profile", RequireUser(), GetUserProfile)                  // 获取用户信息
+			users.PUT("/profile", RequireUser(), UpdateUserProfile)               // 更新用户信息
+			users.POST("/avatar", RequireUser(), UploadAvatar)                    // 上传头像
+			users.PUT("/password", RequireUser(), ChangePassword)                 // 修改密码
+			users.GET("/points", RequireUser(), GetMemberPoints)                  // 获取会员积分和等级
+			users.GET("/points/history", RequireUser(), GetPointsHistory)         // 获取积分流水
+			users.GET("/wallet", RequireUser(), GetWallet)                        // 获取钱包余额
+			users.GET("/wallet/history", RequireUser(), GetWalletHistory)         // 获取钱包流水
+			users.POST("/wallet/topup", RequireUser(), TopupWallet)               // 钱包充值
+			users.GET("/wallet/topup/:payment_no", RequireUser(), GetTopupStatus) // 查询充值结果
+			users.POST("/saved-searches", RequireUser(), CreateSavedSearch)       // 保存搜索条件
+			users.GET("/saved-searches", RequireUser(), GetSavedSearches)         // 获取保存的搜索条件
+			users.DELETE("/saved-searches/:id", RequireUser(), DeleteSavedSearch) // 删除保存的搜索条件
+			users.GET("/referral", RequireUser(), GetReferral)                    // 获取邀请码和邀请记录
+			users.GET("/export", RequireUser(), ExportUserData)                   // 导出个人数据
+			users.DELETE("/account", RequireUser(), DeleteAccount)                // 注销账号
+			users.GET("/security/anomalies", RequireUser(), GetSecurityAnomalies) // 获取账号安全异常记录
+			users.GET("/sessions", RequireUser(), GetUserSessions)                // 获取登录设备列表
+			users.DELETE("/sessions/:id", RequireUser(), RevokeUserSession)       // 注销登录设备
+			users.POST("/password/forgot", RequireCaptcha(), ForgotPassword)      // 发送找回密码验证码
+			users.POST("/password/reset", RequireCaptcha(), ResetPassword)        // 重置密码
+		}
+
+		// 商品相关API
+		products := api.Group("/products")
+		{
+			products.GET("", GetProducts)                                                        // 获取商品列表
+			products.GET("/hot", GetHotProducts)                                                 // 获取热门商品
+			products.GET("/suggest", SuggestProducts)                                            // 搜索联想
+			products.GET("/by-barcode/:code", OptionalUser(), GetProductByBarcode)               // 按条形码查询商品
+			products.GET("/search", SearchProducts)                                              // 搜索商品
+			products.GET("/:id", OptionalUser(), GetProduct)                                     // 获取商品详情
+			products.POST("", RequireAdmin(), CreateProduct)                                     // 创建商品
+			products.PUT("/:id", RequireAdmin(), UpdateProduct)                                  // 更新商品
+			products.DELETE("/:id", RequireAdmin(), DeleteProduct)                               // 删除商品
+			products.GET("/:id/content", GetProductContent)                                      // 获取商品详情内容
+			products.GET("/:id/related", GetRelatedProducts)                                     // 获取相关商品推荐
+			products.POST("/:id/notify-restock", RequireUser(), SubscribeRestock)                // 订阅到货提醒
+			products.GET("/:id/attributes", GetProductAttributes)                                // 获取商品属性
+			products.PUT("/:id/attributes", RequireAdmin(), SetProductAttributes)                // 设置商品属性
+			products.GET("/:id/price-history", RequireAdmin(), GetProductPriceHistory)           // 获取商品价格历史
+			products.PUT("/:id/content", RequireAdmin(), ReplaceProductContent)                  // 替换商品详情内容
+			products.POST("/:id/content", RequireAdmin(), AddProductContentBlock)                // 追加详情内容块
+			products.DELETE("/:id/content/:block_id", RequireAdmin(), DeleteProductContentBlock) // 删除详情内容块
+		}
+
+		// 商品分类API
+		categories := api.Group("/categories")
+		{
+			categories.GET("", GetCategories)                                                      // 获取分类列表
+			categories.GET("/:id", GetCategory)                                                    // 获取分类详情
+			categories.POST("", RequireAdmin(), CreateCategory)                                    // 创建分类
+			categories.PUT("/:id", RequireAdmin(), UpdateCategory)                                 // 更新分类
+			categories.DELETE("/:id", RequireAdmin(), DeleteCategory)                              // 删除分类
+			categories.PUT("/:id/move", RequireAdmin(), MoveCategory)                              // 移动分类（含子分类）
+			categories.POST("/:id/merge", RequireAdmin(), MergeCategory)                           // 合并分类
+			categories.GET("/:id/attributes", GetCategoryAttributes)                               // 获取分类属性（含继承）
+			categories.POST("/:id/attributes", RequireAdmin(), CreateCategoryAttribute)            // 创建分类属性
+			categories.PUT("/:id/attributes/:attr_id", RequireAdmin(), UpdateCategoryAttribute)    // 更新分类属性
+			categories.DELETE("/:id/attributes/:attr_id", RequireAdmin(), DeleteCategoryAttribute) // 删除分类属性
+		}
+
+		// 商品品牌API
+		brands := api.Group("/brands")
+		{
+			brands.GET("", GetBrands)                          // 获取品牌列表
+			brands.GET("/:id", GetBrand)                       // 获取品牌详情
+			brands.POST("", RequireAdmin(), CreateBrand)       // 创建品牌
+			brands.PUT("/:id", RequireAdmin(), UpdateBrand)    // 更新品牌
+			brands.DELETE("/:id", RequireAdmin(), DeleteBrand) // 删除品牌
+		}
+
+		// 商品专题API
+		collections := api.Group("/collections")
+		{
+			collections.GET("/:slug", GetCollection) // 获取专题商品
+		}
+
+		// 站内通知API
+		notifications := api.Group("/notifications", RequireUser())
+		{
+			notifications.GET("", GetNotifications)                        // 获取通知列表
+			notifications.GET("/unread-count", GetUnreadNotificationCount) // 获取未读数量
+			notifications.PUT("/:id/read", MarkNotificationRead)           // 标记已读
+			notifications.PUT("/read-all", MarkAllNotificationsRead)       // 全部标记已读
+		}
+
+		// 公告API
+		announcements := api.Group("/announcements")
+		{
+			announcements.GET("/active", OptionalUser(), GetActiveAnnouncements) // 获取生效公告
+		}
+
+		// 文件上传API
+		upload := api.Group("/upload")
+		{
+			upload.POST("/images", RequireAdmin(), UploadProductImages) // 上传商品图片
+		}
+
+		// 购物车相关API
+		cart := api.Group("/cart")
+		{
+			cart.GET("", RequireUser(), GetCart)                             // 获取购物车
+			cart.GET("/validate", RequireUser(), ValidateCart)               // 结算前校验价格、库存和上架状态
+			cart.GET("/events", RequireUser(), CartEvents)                   // 购物车变更事件流（SSE），多设备同步
+			cart.POST("/add", RequireUser(), AddToCart)                      // 添加到购物车
+			cart.POST("/batch-add", RequireUser(), BatchAddToCart)           // 批量加入购物车
+			cart.PUT("/:id", RequireUser(), UpdateCartItem)                  // 更新购物车项
+			cart.DELETE("/:id", RequireUser(), DeleteCartItem)               // 删除购物车项
+			cart.DELETE("/clear", RequireUser(), ClearCart)                  // 清空购物车
+			cart.POST("/:id/save", RequireUser(), SaveCartItem)              // 移入稍后购买
+			cart.GET("/saved", RequireUser(), GetSavedItems)                 // 稍后购买列表
+			cart.POST("/saved/:id/move", RequireUser(), MoveSavedItemToCart) // 移回购物车
+			cart.DELETE("/saved/:id", RequireUser(), DeleteSavedItem)        // 删除稍后购买的商品
+			cart.POST("/shares", RequireUser(), CreateCartShare)             // 创建分享链接
+			cart.GET("/shares", RequireUser(), GetMyCartShares)              // 获取我的分享链接
+			cart.DELETE("/shares/:id", RequireUser(), RevokeCartShare)       // 关闭分享链接
+		}
+
+		// 商品收藏API
+		favorites := api.Group("/favorites", RequireUser())
+		{
+			favorites.GET("", GetFavorites)                  // 获取收藏列表
+			favorites.POST("", AddFavorite)                  // 收藏商品
+			favorites.DELETE("/:product_id", RemoveFavorite) // 取消收藏
+		}
+
+		// 购物车分享API
+		shares := api.Group("/shares")
+		{
+			shares.GET("/:token", OptionalUser(), GetCartShare)              // 查看分享链接
+			shares.POST("/:token/cart", RequireUser(), AddSharedItemsToCart) // 分享商品加入购物车
+		}
+
+		// 订单相关API
+		orders := api.Group("/orders")
+		{
+			orders.GET("", RequireUser(), GetOrders)                             // 获取订单列表
+			orders.GET("/cod-eligibility", RequireUser(), GetCODEligibility)     // 查询货到付款可用性
+			orders.GET("/shipping-quote", RequireUser(), GetShippingQuote)       // 运费试算
+			orders.GET("/gifts-received", RequireUser(), GetReceivedGifts)       // 收到的礼物
+			orders.GET("/gifts-received/:id", RequireUser(), GetReceivedGift)    // 收到的礼物详情
+			orders.GET("/:id/digital", RequireUser(), GetOrderDigitalDeliveries) // 获取订单的虚拟商品
+			orders.GET("/:id/history", RequireUser(), GetOrderStatusHistory)     // 获取订单状态变更历史
+			orders.GET("/:id/timeline", RequireUser(), GetOrderTimeline)         // 获取订单时间线
+			orders.POST("/:id/invoice", RequireUser(), RequestInvoice)           // 申请电子发票
+			orders.GET("/:id/invoice", RequireUser(), GetInvoice)                // 下载电子发票（PDF）
+			orders.GET("/:id", RequireUser(), GetOrder)                          // 获取订单详情
+			orders.POST("", RequireUser(), CreateOrder)                          // 创建订单
+			orders.POST("/buy-now", RequireUser(), BuyNow)                       // 立即购买，不经过购物车
+			orders.POST("/:id/rebuy", RequireUser(), RebuyOrder)                 // 再次购买，订单商品加入购物车
+			orders.PUT("/:id/status", RequireUser(), UpdateOrderStatus)          // 更新订单状态
+			orders.POST("/:id/pay", RequireUser(), PayOrder)                     // 发起支付
+			orders.GET("/:id/payment-status", RequireUser(), GetPaymentStatus)   // 查询支付状态（支付返回后轮询）
+			orders.POST("/:id/refunds", RequireUser(), RequestRefund)            // 申请退款（未发货整单退款，已发货可按商品部分退款）
+			orders.GET("/:id/refunds", RequireUser(), GetOrderRefunds)           // 获取订单退款记录
+			orders.DELETE("/:id", RequireUser(), CancelOrder)                    // 取消订单
+		}
+
+		// 虚拟商品下载API，下载令牌即凭证，无需登录
+		api.GET("/downloads/:token", DownloadDigitalProduct) // 下载虚拟商品
+
+		// 配送API
+		delivery := api.Group("/delivery", RequireRole(RoleCourier, RoleAdmin))
+		{
+			delivery.POST("/orders/:id/proofs", UploadDeliveryProof)    // 上传签收凭证
+			delivery.POST("/orders/:id/cod-collect", CollectCODPayment) // 货到付款收款
+		}
+
+		// 大宗询价API
+		quotes := api.Group("/quotes", RequireUser())
+		{
+			quotes.POST("", CreateQuote)            // 发起询价
+			quotes.GET("", GetMyQuotes)             // 获取我的询价单
+			quotes.GET("/:id", GetQuote)            // 获取询价单详情
+			quotes.POST("/:id/accept", AcceptQuote) // 接受报价并下单
+			quotes.DELETE("/:id", CancelQuote)      // 取消询价
+		}
+
+		// 商家入驻API
+		sellerApplications := api.Group("/seller-applications", RequireUser())
+		{
+			sellerApplications.POST("", SubmitSellerApplication)     // 提交入驻申请（上传资质）
+			sellerApplications.GET("/mine", GetMySellerApplications) // 获取我的入驻申请
+		}
+
+		// 商家API
+		seller := api.Group("/seller", RequireRole(RoleSeller, RoleAdmin))
+		{
+			seller.GET("/quotes", GetMerchantQuotes)                     // 获取询价单列表
+			seller.PUT("/quotes/:id/respond", RespondQuote)              // 报价
+			seller.PUT("/quotes/:id/reject", RejectQuote)                // 拒绝询价
+			seller.GET("/products", GetSellerProducts)                   // 获取我的商品
+			seller.POST("/products", CreateProduct)                      // 创建商品（草稿）
+			seller.PUT("/products/:id", UpdateSellerProduct)             // 编辑草稿商品
+			seller.PUT("/products/:id/submit", SubmitProduct)            // 提交审核
+			seller.PUT("/products/:id/withdraw", WithdrawProduct)        // 撤回审核
+			seller.PUT("/products/:id/archive", ArchiveProduct)          // 归档商品
+			seller.PUT("/products/:id/restore", RestoreProduct)          // 恢复归档商品
+			seller.PUT("/products/:id/content", ReplaceProductContent)   // 编辑草稿商品详情内容
+			seller.PUT("/products/:id/attributes", SetProductAttributes) // 编辑草稿商品属性
+		}
+
+		// 管理后台API
+		admin := api.Group("/admin", RequireAdmin())
+		{
+			admin.GET("/roles", GetRoles)                                                       // 获取角色列表
+			admin.GET("/users/:id", GetAdminUser)                                               // 获取用户详情（含客户价值指标）
+			admin.PUT("/users/:id/status", UpdateUserStatus)                                    // 启用/禁用用户
+			admin.GET("/users/:id/wallet/history", GetUserWalletHistory)                        // 获取用户钱包流水
+			admin.POST("/users/:id/wallet/adjust", AdjustUserWallet)                            // 调整用户钱包余额
+			admin.GET("/users/:id/roles", GetUserRolesHandler)                                  // 获取用户角色
+			admin.POST("/users/:id/roles", AssignUserRole)                                      // 分配用户角色
+			admin.DELETE("/users/:id/roles/:role", RevokeUserRole)                              // 撤销用户角色
+			admin.GET("/collections", GetCollections)                                           // 获取专题列表
+			admin.POST("/collections", CreateCollection)                                        // 创建专题
+			admin.PUT("/collections/:id", UpdateCollection)                                     // 更新专题
+			admin.DELETE("/collections/:id", DeleteCollection)                                  // 删除专题
+			admin.PUT("/collections/:id/products", SetCollectionProducts)                       // 设置专题商品
+			admin.GET("/customers/metrics", GetCustomerMetrics)                                 // 客户价值指标（RFM/LTV）
+			admin.POST("/customers/metrics/refresh", RefreshCustomerMetrics)                    // 重新计算客户价值指标
+			admin.GET("/announcements", GetAnnouncements)                                       // 获取公告列表
+			admin.POST("/announcements", CreateAnnouncement)                                    // 创建公告
+			admin.PUT("/announcements/:id", UpdateAnnouncement)                                 // 更新公告
+			admin.DELETE("/announcements/:id", DeleteAnnouncement)                              // 删除公告
+			admin.GET("/search/synonyms", GetSearchSynonyms)                                    // 获取搜索同义词
+			admin.POST("/search/synonyms", CreateSearchSynonym)                                 // 创建同义词组
+			admin.PUT("/search/synonyms/:id", UpdateSearchSynonym)                              // 更新同义词组
+			admin.DELETE("/search/synonyms/:id", DeleteSearchSynonym)                           // 删除同义词组
+			admin.GET("/search/rules", GetSearchRules)                                          // 获取搜索干预规则
+			admin.POST("/search/rules", CreateSearchRule)                                       // 置顶或沉底商品
+			admin.DELETE("/search/rules/:id", DeleteSearchRule)                                 // 删除搜索干预规则
+			admin.GET("/warehouses", GetWarehouses)                                             // 获取仓库列表
+			admin.POST("/warehouses", CreateWarehouse)                                          // 创建仓库
+			admin.PUT("/warehouses/:id", UpdateWarehouse)                                       // 更新仓库
+			admin.POST("/inventory/adjustments", AdjustInventory)                               // 调整库存（含原因码）
+			admin.POST("/inventory/transfers", TransferInventory)                               // 仓库间调拨
+			admin.POST("/inventory/stocktakes", StocktakeInventory)                             // 提交盘点结果
+			admin.GET("/inventory/audit", GetInventoryAudit)                                    // 库存审计（超卖与流失检测）
+			admin.GET("/products/:id/inventory", GetProductInventory)                           // 获取商品分仓库存
+			admin.GET("/products/:id/inventory/movements", GetInventoryMovements)               // 商品库存流水
+			admin.GET("/suppliers", GetSuppliers)                                               // 获取供应商列表
+			admin.POST("/suppliers", CreateSupplier)                                            // 创建供应商
+			admin.PUT("/suppliers/:id", UpdateSupplier)                                         // 更新供应商
+			admin.GET("/purchase-orders", GetPurchaseOrders)                                    // 获取采购单列表
+			admin.POST("/purchase-orders", CreatePurchaseOrder)                                 // 创建采购单草稿
+			admin.GET("/purchase-orders/:id", GetPurchaseOrder)                                 // 获取采购单详情
+			admin.PUT("/purchase-orders/:id", UpdatePurchaseOrder)                              // 修改采购单草稿
+			admin.DELETE("/purchase-orders/:id", DeletePurchaseOrder)                           // 删除采购单草稿
+			admin.PUT("/purchase-orders/:id/order", SubmitPurchaseOrder)                        // 确认采购下单
+			admin.PUT("/purchase-orders/:id/receive", ReceivePurchaseOrder)                     // 采购收货入库
+			admin.GET("/products/media-issues", GetProductMediaIssues)                          // 图片问题商品报告
+			admin.GET("/products", GetAdminProducts)                                            // 获取全部商品（含草稿、待审核）
+			admin.PUT("/products/:id/approve", ApproveProduct)                                  // 审核通过商品
+			admin.PUT("/products/:id/reject", RejectProduct)                                    // 驳回商品
+			admin.PUT("/products/:id/publish", PublishProduct)                                  // 直接发布商品
+			admin.PUT("/products/:id/schedule", ScheduleProduct)                                // 定时上下架
+			admin.POST("/products/import", ImportProducts)                                      // 批量导入商品（CSV/XLSX）
+			admin.GET("/products/export", ExportProducts)                                       // 导出商品目录
+			admin.POST("/orders/import", ImportOfflineOrders)                                   // 导入线下/电话订单
+			admin.GET("/orders", GetAdminOrders)                                                // 获取全部订单，支持筛选
+			admin.GET("/orders/export", ExportAdminOrders)                                      // 导出订单及商品明细（CSV/XLSX）
+			admin.GET("/orders/sla-breaches", GetOrderSLABreaches)                              // 获取履约超时订单
+			admin.GET("/orders/:id", GetAdminOrder)                                             // 获取订单详情（含计价明细）
+			admin.PUT("/orders/:id/address", UpdateOrderAddress)                                // 修改收货地址（发货前）
+			admin.POST("/orders/:id/notes", AddOrderNote)                                       // 添加订单内部备注
+			admin.POST("/orders/:id/cancel", ForceCancelOrder)                                  // 取消订单（退回库存和积分）
+			admin.PUT("/orders/:id/ship", ShipOrder)                                            // 登记物流信息并发货
+			admin.GET("/refunds", GetAdminRefunds)                                              // 获取退款申请列表
+			admin.PUT("/refunds/:id/approve", ApproveRefund)                                    // 通过退款并原路退回
+			admin.PUT("/refunds/:id/reject", RejectRefund)                                      // 驳回退款申请
+			admin.GET("/reconciliation/runs", GetReconciliationRuns)                            // 获取支付对账记录
+			admin.POST("/reconciliation/runs", RunReconciliation)                               // 拉取渠道对账单并对账
+			admin.POST("/reconciliation/import", ImportPaymentStatement)                        // 上传对账文件并对账
+			admin.GET("/reconciliation/discrepancies", GetPaymentDiscrepancies)                 // 对账差异报告
+			admin.PUT("/reconciliation/discrepancies/:id/resolve", ResolvePaymentDiscrepancy)   // 标记对账差异已处理
+			admin.GET("/audit-logs", GetAdminAuditLogs)                                         // 管理员操作日志
+			admin.GET("/reports/sales", GetSalesReport)                                         // 销售毛利报表
+			admin.GET("/cod-settlements", GetCODSettlements)                                    // 获取货到付款结算列表
+			admin.PUT("/cod-settlements/:id/settle", SettleCODPayment)                          // 确认货款已交回
+			admin.GET("/maintenance", GetMaintenance)                                           // 获取维护模式状态
+			admin.PUT("/maintenance", UpdateMaintenance)                                        // 开启/关闭维护模式
+			admin.GET("/suppressions", GetSuppressions)                                         // 获取屏蔽名单
+			admin.POST("/suppressions", CreateSuppression)                                      // 添加屏蔽记录
+			admin.DELETE("/suppressions/:id", DeleteSuppression)                                // 删除屏蔽记录
+			admin.GET("/seller-applications", GetSellerApplications)                            // 获取入驻申请审核队列
+			admin.GET("/seller-applications/:id/documents/:kind", GetSellerApplicationDocument) // 查看入驻资料
+			admin.PUT("/seller-applications/:id/approve", ApproveSellerApplication)             // 通过入驻申请并开通店铺
+			admin.PUT("/seller-applications/:id/reject", RejectSellerApplication)               // 驳回入驻申请
+		}
+
+		// 消息服务商回调API
+		webhooks := api.Group("/webhooks", RequireWebhookToken())
+		{
+			webhooks.POST("/sendgrid", SendGridWebhook) // SendGrid退信与退订事件
+			webhooks.POST("/ses", SESWebhook)           // Amazon SES退信与投诉通知
+			webhooks.POST("/twilio", TwilioWebhook)     // Twilio短信状态回调
+		}
+		// 外部仓储系统库存回调，使用请求签名校验
+		api.POST("/webhooks/wms", WMSStockWebhook)
+		// 支付渠道回调，按渠道规则校验签名
+		api.POST("/webhooks/payments/:channel", PaymentNotify)
+	}
+
+	return r, nil
+}
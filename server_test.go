@@ -0,0 +1,63 @@
+package gomall_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+func TestHealth(t *testing.T) {
+	kit := testkit.New(t)
+
+	resp := kit.Do(http.MethodGet, "/health", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("健康检查返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	kit := testkit.New(t)
+	f := kit.Fixtures
+
+	if resp := kit.Do(http.MethodGet, "/api/admin/roles", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("未登录访问管理接口返回 %d，期望 401", resp.StatusCode)
+	}
+	if resp := kit.Do(http.MethodGet, "/api/admin/roles", "invalid-token", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("无效令牌访问管理接口返回 %d，期望 401", resp.StatusCode)
+	}
+
+	for _, user := range []gomall.User{f.Customer, f.Seller} {
+		if resp := kit.DoAs(user, http.MethodGet, "/api/admin/roles", nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s 访问管理接口返回 %d，期望 403", user.Username, resp.StatusCode)
+		}
+	}
+
+	if resp := kit.DoAs(f.Admin, http.MethodGet, "/api/admin/roles", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("管理员访问管理接口返回 %d，期望 200: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+// 普通用户调用分配角色接口时必须在执行处理函数之前被拒绝，不能给自己授予管理员角色
+func TestCustomerCannotGrantAdminRole(t *testing.T) {
+	kit := testkit.New(t)
+	customer := kit.Fixtures.Customer
+
+	path := "/api/admin/users/" + strconv.FormatUint(uint64(customer.ID), 10) + "/roles"
+	resp := kit.DoAs(customer, http.MethodPost, path, gomall.AssignRoleRequest{Role: gomall.RoleAdmin})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("普通用户分配角色返回 %d，期望 403: %s", resp.StatusCode, resp.Body)
+	}
+
+	roles, err := gomall.GetUserRoles(customer.ID)
+	if err != nil {
+		t.Fatalf("查询用户角色失败: %v", err)
+	}
+	for _, role := range roles {
+		if role == gomall.RoleAdmin {
+			t.Fatalf("被拒绝的请求仍然授予了管理员角色: %v", roles)
+		}
+	}
+}
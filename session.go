@@ -0,0 +1,379 @@
+package gomall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// 会话异常处理策略
+const (
+	SessionAnomalyFlag      = "flag"      // 仅记录
+	SessionAnomalyChallenge = "challenge" // 记录并要求重新登录验证
+)
+
+// 每个用户保留的异常记录条数
+const sessionAnomalyKeep = 50
+
+// SessionInfo 登录会话信息
+type SessionInfo struct {
+	ID          string    `json:"id"`
+	UserID      uint      `json:"user_id"`
+	IP          string    `json:"ip"`           // 登录IP
+	UserAgent   string    `json:"user_agent"`   // 登录设备UA
+	Fingerprint string    `json:"-"`            // 设备指纹
+	LastIP      string    `json:"last_ip"`      // 最近访问IP
+	CreatedAt   time.Time `json:"created_at"`   // 登录时间
+	LastSeenAt  time.Time `json:"last_seen_at"` // 最近访问时间
+}
+
+// SessionAnomaly 会话异常记录
+type SessionAnomaly struct {
+	SessionID  string    `json:"session_id"`
+	Reason     string    `json:"reason"`
+	Action     string    `json:"action"`
+	ExpectedIP string    `json:"expected_ip"`
+	ActualIP   string    `json:"actual_ip"`
+	UserAgent  string    `json:"user_agent"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("sessions:user:%d", userID)
+}
+
+func sessionAnomaliesKey(userID uint) string {
+	return fmt.Sprintf("security:anomalies:%d", userID)
+}
+
+// 根据UA和语言生成设备指纹
+func deviceFingerprint(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.Request.UserAgent() + "|" + c.GetHeader("Accept-Language")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 判断两个IP是否属于同一网段（IPv4取/16，IPv6取/32）
+func sameNetwork(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil && v4B != nil {
+		mask := net.CIDRMask(16, 32)
+		return v4A.Mask(mask).Equal(v4B.Mask(mask))
+	}
+	mask := net.CIDRMask(32, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// CreateSession 为用户创建登录会话
+func CreateSession(userID uint, c *gin.Context) (*SessionInfo, error) {
+	sessionID, err := generateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &SessionInfo{
+		ID:          sessionID,
+		UserID:      userID,
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		Fingerprint: deviceFingerprint(c),
+		LastIP:      c.ClientIP(),
+		CreatedAt:   now,
+		LastSeenAt:  now,
+	}
+
+	ttl := refreshTokenTTL()
+	pipe := RDB.TxPipeline()
+	pipe.HSet(CTX, sessionKey(sessionID),
+		"user_id", userID,
+		"ip", session.IP,
+		"user_agent", session.UserAgent,
+		"fingerprint", session.Fingerprint,
+		"last_ip", session.LastIP,
+		"created_at", now.Unix(),
+		"last_seen_at", now.Unix(),
+	)
+	pipe.Expire(CTX, sessionKey(sessionID), ttl)
+	pipe.ZAdd(CTX, userSessionsKey(userID), &redis.Z{Score: float64(now.UnixNano()), Member: sessionID})
+	pipe.Expire(CTX, userSessionsKey(userID), ttl)
+	if _, err := pipe.Exec(CTX); err != nil {
+		return nil, err
+	}
+	enforceSessionLimit(userID, sessionID)
+
+	return session, nil
+}
+
+// 会话数超过上限时按登录时间从早到晚退出多余的会话，新建的会话始终保留
+func enforceSessionLimit(userID uint, currentSessionID string) {
+	limit := AppConfig.MaxSessionsPerUser
+	if limit <= 0 {
+		return
+	}
+
+	sessionIDs, err := RDB.ZRange(CTX, userSessionsKey(userID), 0, -1).Result()
+	if err != nil {
+		return
+	}
+	var active []string
+	for _, sessionID := range sessionIDs {
+		if exists, _ := RDB.Exists(CTX, sessionKey(sessionID)).Result(); exists == 0 {
+			// 会话已过期，顺带清理索引
+			RDB.ZRem(CTX, userSessionsKey(userID), sessionID)
+			continue
+		}
+		active = append(active, sessionID)
+	}
+
+	evicted := 0
+	for _, sessionID := range active {
+		if len(active)-evicted <= limit {
+			break
+		}
+		if sessionID == currentSessionID {
+			continue
+		}
+		if err := DeleteSession(userID, sessionID); err == nil {
+			evicted++
+		}
+	}
+	if evicted > 0 {
+		SendNotification(userID, NotificationTypeSystem, "登录设备已达上限",
+			fmt.Sprintf("您的账号最多同时在 %d 台设备登录，本次新设备登录后，最早登录的 %d 台设备已自动退出。如非本人操作，请及时修改密码。", limit, evicted))
+	}
+}
+
+// GetSession 获取会话信息
+func GetSession(sessionID string) (*SessionInfo, error) {
+	values, err := RDB.HGetAll(CTX, sessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("会话不存在或已过期")
+	}
+
+	userID, _ := strconv.ParseUint(values["user_id"], 10, 32)
+	createdAt, _ := strconv.ParseInt(values["created_at"], 10, 64)
+	lastSeenAt, _ := strconv.ParseInt(values["last_seen_at"], 10, 64)
+
+	return &SessionInfo{
+		ID:          sessionID,
+		UserID:      uint(userID),
+		IP:          values["ip"],
+		UserAgent:   values["user_agent"],
+		Fingerprint: values["fingerprint"],
+		LastIP:      values["last_ip"],
+		CreatedAt:   time.Unix(createdAt, 0),
+		LastSeenAt:  time.Unix(lastSeenAt, 0),
+	}, nil
+}
+
+// 只在会话仍存在时更新字段。会话可能在校验后被退出登录或挤下线删除，直接 HSET 会重建一个没有过期时间的会话
+var updateSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// 更新会话字段，fields 为交替的字段名和值
+func updateSessionFields(sessionID string, fields ...interface{}) error {
+	return updateSessionScript.Run(CTX, RDB, []string{sessionKey(sessionID)}, fields...).Err()
+}
+
+// TouchSession 更新会话最近访问信息
+func TouchSession(sessionID, ip string) error {
+	return updateSessionFields(sessionID, "last_ip", ip, "last_seen_at", time.Now().Unix())
+}
+
+// ExtendSession 刷新令牌时延长会话有效期
+func ExtendSession(session *SessionInfo) error {
+	ttl := refreshTokenTTL()
+	pipe := RDB.TxPipeline()
+	pipe.Expire(CTX, sessionKey(session.ID), ttl)
+	pipe.Expire(CTX, userSessionsKey(session.UserID), ttl)
+	_, err := pipe.Exec(CTX)
+	return err
+}
+
+// DeleteSession 删除单个会话
+func DeleteSession(userID uint, sessionID string) error {
+	pipe := RDB.TxPipeline()
+	pipe.Del(CTX, sessionKey(sessionID))
+	pipe.ZRem(CTX, userSessionsKey(userID), sessionID)
+	_, err := pipe.Exec(CTX)
+	return err
+}
+
+// DeleteAllSessions 删除用户的全部会话
+func DeleteAllSessions(userID uint) error {
+	sessionIDs, err := RDB.ZRange(CTX, userSessionsKey(userID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, sessionKey(sessionID))
+	}
+	keys = append(keys, userSessionsKey(userID))
+	return RDB.Del(CTX, keys...).Err()
+}
+
+// DetectSessionAnomaly 检查当前请求与会话绑定的设备和网络是否一致，返回异常原因
+func DetectSessionAnomaly(session *SessionInfo, c *gin.Context) string {
+	if session.Fingerprint != "" && session.Fingerprint != deviceFingerprint(c) {
+		return "设备信息与登录时不一致"
+	}
+	if !sameNetwork(session.IP, c.ClientIP()) && !sameNetwork(session.LastIP, c.ClientIP()) {
+		return "访问IP与登录网络差异较大"
+	}
+	return ""
+}
+
+// RecordSessionAnomaly 记录会话异常
+func RecordSessionAnomaly(session *SessionInfo, c *gin.Context, reason, action string) error {
+	anomaly := SessionAnomaly{
+		SessionID:  session.ID,
+		Reason:     reason,
+		Action:     action,
+		ExpectedIP: session.IP,
+		ActualIP:   c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		DetectedAt: time.Now(),
+	}
+	data, err := json.Marshal(anomaly)
+	if err != nil {
+		return err
+	}
+
+	key := sessionAnomaliesKey(session.UserID)
+	pipe := RDB.TxPipeline()
+	pipe.LPush(CTX, key, data)
+	pipe.LTrim(CTX, key, 0, sessionAnomalyKeep-1)
+	_, err = pipe.Exec(CTX)
+	return err
+}
+
+// GetSecurityAnomalies 获取账号安全异常记录
+// @Summary 获取账号安全异常记录
+// @Description 获取当前用户最近的会话异常记录（设备或IP突变），用于账号安全页面展示
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]SessionAnomaly} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/security/anomalies [get]
+func GetSecurityAnomalies(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	records, err := RDB.LRange(CTX, sessionAnomaliesKey(userID.(uint)), 0, -1).Result()
+	if err != nil {
+		InternalServerError(c, "安全记录查询失败")
+		return
+	}
+
+	anomalies := make([]SessionAnomaly, 0, len(records))
+	for _, record := range records {
+		var anomaly SessionAnomaly
+		if err := json.Unmarshal([]byte(record), &anomaly); err == nil {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+
+	SuccessResponse(c, anomalies)
+}
+
+// ListUserSessions 获取用户当前有效的全部会话，按登录时间倒序
+func ListUserSessions(userID uint) ([]SessionInfo, error) {
+	sessionIDs, err := RDB.ZRevRange(CTX, userSessionsKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionInfo, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := GetSession(sessionID)
+		if err != nil {
+			// 会话已过期，顺带清理索引
+			RDB.ZRem(CTX, userSessionsKey(userID), sessionID)
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+// GetUserSessions 获取登录设备列表
+// @Summary 获取登录设备列表
+// @Description 获取当前用户所有有效的登录会话及设备、IP信息，以及同时登录设备上限（max_sessions，0表示不限制）；超出上限时最早登录的设备被退出
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{sessions=[]SessionInfo,current_session_id=string,max_sessions=int,eviction_policy=string}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/sessions [get]
+func GetUserSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	sessions, err := ListUserSessions(userID.(uint))
+	if err != nil {
+		InternalServerError(c, "会话查询失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{
+		"sessions":           sessions,
+		"current_session_id": c.GetString("session_id"),
+		"max_sessions":       AppConfig.MaxSessionsPerUser,
+		"eviction_policy":    "oldest", // 超出上限时退出最早登录的设备
+	})
+}
+
+// RevokeUserSession 注销指定登录设备
+// @Summary 注销登录设备
+// @Description 注销当前用户的指定登录会话，该设备的令牌立即失效
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "注销成功"
+// @Failure 404 {object} ApiResponse "会话不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/sessions/{id} [delete]
+func RevokeUserSession(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID := c.Param("id")
+
+	session, err := GetSession(sessionID)
+	if err != nil || session.UserID != userID.(uint) {
+		NotFoundError(c, "会话不存在")
+		return
+	}
+
+	if err := DeleteSession(session.UserID, session.ID); err != nil {
+		InternalServerError(c, "会话注销失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "设备已退出登录"})
+}
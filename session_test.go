@@ -0,0 +1,34 @@
+package gomall_test
+
+import (
+	"testing"
+	"time"
+
+	gomall "GoMall"
+	"GoMall/testkit"
+)
+
+// 更新已删除的会话时不能重建会话，更新仍存在的会话时保留过期时间
+func TestTouchSessionDoesNotRecreateDeletedSession(t *testing.T) {
+	kit := testkit.New(t)
+	ctx := kit.Redis.Context()
+
+	if err := gomall.TouchSession("deleted", "203.0.113.1"); err != nil {
+		t.Fatalf("更新会话失败: %v", err)
+	}
+	if kit.Redis.Exists(ctx, "session:deleted").Val() != 0 {
+		t.Fatalf("更新已删除的会话时重建了会话")
+	}
+
+	kit.Redis.HSet(ctx, "session:alive", "user_id", kit.Fixtures.Customer.ID)
+	kit.Redis.Expire(ctx, "session:alive", time.Hour)
+	if err := gomall.TouchSession("alive", "203.0.113.2"); err != nil {
+		t.Fatalf("更新会话失败: %v", err)
+	}
+	if ip := kit.Redis.HGet(ctx, "session:alive", "last_ip").Val(); ip != "203.0.113.2" {
+		t.Fatalf("最近访问IP为 %q，期望 203.0.113.2", ip)
+	}
+	if ttl := kit.Redis.TTL(ctx, "session:alive").Val(); ttl <= 0 {
+		t.Fatalf("会话更新后丢失了过期时间: %v", ttl)
+	}
+}
@@ -0,0 +1,187 @@
+package gomall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 基础运费的规则标识，拆单时只计入一个子订单
+const shippingBaseRuleID = "shipping:base"
+
+// 参与运费计算和配送限制校验的商品行
+type shippingItem struct {
+	Product  *Product
+	Quantity int
+}
+
+// ShippingFeeLine 运费明细
+type ShippingFeeLine struct {
+	RuleID      string  `json:"rule_id"` // shipping:base 或 shipping:oversize:商品ID
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// ShippingQuote 运费试算结果
+type ShippingQuote struct {
+	Subtotal float64           `json:"subtotal"`
+	Fee      float64           `json:"fee"`
+	Lines    []ShippingFeeLine `json:"lines"`
+}
+
+// 按收货地址前缀匹配地区，与货到付款地区规则一致
+func addressInRegions(address string, regions []string) bool {
+	address = strings.TrimSpace(address)
+	for _, region := range regions {
+		if strings.HasPrefix(address, region) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckShippingConstraints 校验商品能否配送到收货地址：限定配送地区、禁止空运的商品不能发往只能空运的地区
+func CheckShippingConstraints(items []shippingItem, shippingAddress string) error {
+	airOnly := addressInRegions(shippingAddress, splitEnvList(AppConfig.ShippingAirOnlyRegions))
+	for _, item := range items {
+		if item.Product.IsDigital() {
+			continue
+		}
+		if regions := splitEnvList(item.Product.ShipRegions); len(regions) > 0 && !addressInRegions(shippingAddress, regions) {
+			return fmt.Errorf("商品「%s」仅配送至 %s", item.Product.Name, strings.Join(regions, "、"))
+		}
+		if item.Product.NoAirTransport && airOnly {
+			return fmt.Errorf("商品「%s」含电池等禁止空运的物品，无法配送到该地区", item.Product.Name)
+		}
+	}
+	return nil
+}
+
+// 是否包含需要配送的实物商品
+func hasPhysicalItems(items []shippingItem) bool {
+	for _, item := range items {
+		if !item.Product.IsDigital() {
+			return true
+		}
+	}
+	return false
+}
+
+// 计算运费（单位：分）：基础运费满额包邮，超大件附加费按件收取且不参与包邮；只含虚拟商品时不收运费
+func shippingFeeLines(items []shippingItem, subtotalCents int64) []ShippingFeeLine {
+	var lines []ShippingFeeLine
+
+	baseCents := toCents(AppConfig.ShippingBaseFee)
+	freeCents := toCents(AppConfig.FreeShippingThreshold)
+	if baseCents > 0 && (freeCents == 0 || subtotalCents < freeCents) && hasPhysicalItems(items) {
+		lines = append(lines, ShippingFeeLine{RuleID: shippingBaseRuleID, Description: "基础运费", Amount: fromCents(baseCents)})
+	}
+
+	for _, item := range items {
+		if feeCents := toCents(item.Product.OversizeFee); feeCents > 0 {
+			lines = append(lines, ShippingFeeLine{
+				RuleID:      fmt.Sprintf("shipping:oversize:%d", item.Product.ID),
+				Description: fmt.Sprintf("「%s」超大件附加费 %.2f 元 × %d", item.Product.Name, item.Product.OversizeFee, item.Quantity),
+				Amount:      fromCents(feeCents * int64(item.Quantity)),
+			})
+		}
+	}
+	return lines
+}
+
+// 将运费记入计价明细，须在商品行全部记录之后调用
+func addShippingAdjustments(trace *PricingTrace, items []shippingItem) {
+	for _, line := range shippingFeeLines(items, trace.BaseCents) {
+		trace.AddAdjustment(PricingAdjustmentShipping, line.RuleID, line.Description, toCents(line.Amount))
+	}
+}
+
+// 计算运费合计（元）
+func calculateShippingFee(items []shippingItem, subtotal float64) float64 {
+	var totalCents int64
+	for _, line := range shippingFeeLines(items, toCents(subtotal)) {
+		totalCents += toCents(line.Amount)
+	}
+	return fromCents(totalCents)
+}
+
+// 订单商品行转换为运费计算的商品行
+func orderShippingItems(lines []CartItem) []shippingItem {
+	items := make([]shippingItem, 0, len(lines))
+	for i := range lines {
+		items = append(items, shippingItem{Product: &lines[i].Product, Quantity: lines[i].Quantity})
+	}
+	return items
+}
+
+// 加载当前用户的购物车项作为运费计算的商品行
+func loadCartShippingItems(userID uint, cartItemIDs []uint) ([]shippingItem, error) {
+	items := make([]shippingItem, 0, len(cartItemIDs))
+	for _, itemID := range cartItemIDs {
+		var cartItem CartItem
+		if err := DB.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
+			return nil, fmt.Errorf("购物车项 %d 不存在", itemID)
+		}
+		items = append(items, shippingItem{Product: &cartItem.Product, Quantity: cartItem.Quantity})
+	}
+	return items, nil
+}
+
+// GetShippingQuote 运费试算
+// @Summary 运费试算
+// @Description 结算页根据所选购物车项和收货地址校验配送限制（限定地区、禁止空运）并计算运费，运费包括基础运费（满额包邮）和超大件附加费
+// @Tags 订单管理
+// @Accept json
+// @Produce json
+// @Param cart_item_ids query string true "购物车项ID，逗号分隔"
+// @Param shipping_address query string true "收货地址"
+// @Success 200 {object} ApiResponse{data=ShippingQuote} "试算成功"
+// @Failure 400 {object} ApiResponse "参数错误或商品无法配送到该地址"
+// @Security Bearer
+// @Router /api/orders/shipping-quote [get]
+func GetShippingQuote(c *gin.Context) {
+	address := c.Query("shipping_address")
+	if strings.TrimSpace(address) == "" {
+		BadRequestError(c, "收货地址不能为空")
+		return
+	}
+
+	var cartItemIDs []uint
+	for _, value := range splitEnvList(c.Query("cart_item_ids")) {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			BadRequestError(c, "无效的购物车项ID")
+			return
+		}
+		cartItemIDs = append(cartItemIDs, uint(id))
+	}
+	if len(cartItemIDs) == 0 {
+		BadRequestError(c, "请选择要结算的商品")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	items, err := loadCartShippingItems(userID.(uint), cartItemIDs)
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if err := CheckShippingConstraints(items, address); err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+
+	var subtotalCents int64
+	for _, item := range items {
+		subtotalCents += toCents(item.Product.Price) * int64(item.Quantity)
+	}
+	quote := ShippingQuote{Subtotal: fromCents(subtotalCents), Lines: shippingFeeLines(items, subtotalCents)}
+	if quote.Lines == nil {
+		quote.Lines = []ShippingFeeLine{}
+	}
+	quote.Fee = calculateShippingFee(items, quote.Subtotal)
+
+	SuccessResponse(c, quote)
+}
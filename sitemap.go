@@ -0,0 +1,254 @@
+package gomall
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单个sitemap文件最多包含的URL数量（协议上限为50000）
+const sitemapMaxURLs = 50000
+
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapURL sitemap中的一条URL
+type SitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+type sitemapIndexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name            `xml:"sitemapindex"`
+	XMLNS    string              `xml:"xmlns,attr"`
+	Sitemaps []sitemapIndexEntry `xml:"sitemap"`
+}
+
+// 已生成的sitemap，定时任务刷新后原子替换
+var sitemapCache struct {
+	sync.RWMutex
+	index       []byte   // 多个分页时的sitemap索引
+	pages       [][]byte // 各分页内容
+	generatedAt time.Time
+}
+
+// 站点绝对地址
+func siteURL(path string) string {
+	return strings.TrimRight(AppConfig.SiteBaseURL, "/") + path
+}
+
+func sitemapDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// 收集需要收录的页面：首页、在售商品、启用的分类和专题页
+func collectSitemapURLs() ([]SitemapURL, error) {
+	urls := []SitemapURL{{Loc: siteURL("/"), ChangeFreq: "daily", Priority: "1.0"}}
+
+	var categories []Category
+	if err := DB.Select("id, created_at").Where("status = ?", 1).Order("id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		urls = append(urls, SitemapURL{
+			Loc:        siteURL(fmt.Sprintf("/categories/%d", category.ID)),
+			LastMod:    sitemapDate(category.CreatedAt),
+			ChangeFreq: "weekly",
+			Priority:   "0.6",
+		})
+	}
+
+	var collections []Collection
+	if err := DB.Select("slug, updated_at").Where("status = ?", 1).Order("sort_order").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	for _, collection := range collections {
+		urls = append(urls, SitemapURL{
+			Loc:        siteURL("/collections/" + collection.Slug),
+			LastMod:    sitemapDate(collection.UpdatedAt),
+			ChangeFreq: "weekly",
+			Priority:   "0.7",
+		})
+	}
+
+	// 商品数量可能很大，分批读取
+	var products []Product
+	err := DB.Select("id, created_at").Where("status = ?", 1).Order("id").
+		FindInBatches(&products, 5000, func(tx *gorm.DB, batch int) error {
+			for _, product := range products {
+				urls = append(urls, SitemapURL{
+					Loc:        siteURL(fmt.Sprintf("/products/%d", product.ID)),
+					LastMod:    sitemapDate(product.CreatedAt),
+					ChangeFreq: "daily",
+					Priority:   "0.8",
+				})
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+func marshalSitemapXML(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// RefreshSitemap 重新生成sitemap，URL超过单文件上限时拆分并生成索引
+func RefreshSitemap() error {
+	urls, err := collectSitemapURLs()
+	if err != nil {
+		return fmt.Errorf("sitemap数据查询失败: %v", err)
+	}
+
+	now := time.Now()
+	var pages [][]byte
+	for start := 0; start < len(urls); start += sitemapMaxURLs {
+		end := min(start+sitemapMaxURLs, len(urls))
+		page, err := marshalSitemapXML(sitemapURLSet{XMLNS: sitemapXMLNS, URLs: urls[start:end]})
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+
+	var index []byte
+	if len(pages) > 1 {
+		entries := make([]sitemapIndexEntry, len(pages))
+		for i := range pages {
+			entries[i] = sitemapIndexEntry{Loc: siteURL(fmt.Sprintf("/sitemaps/%d.xml", i+1)), LastMod: sitemapDate(now)}
+		}
+		if index, err = marshalSitemapXML(sitemapIndex{XMLNS: sitemapXMLNS, Sitemaps: entries}); err != nil {
+			return err
+		}
+	}
+
+	sitemapCache.Lock()
+	sitemapCache.index = index
+	sitemapCache.pages = pages
+	sitemapCache.generatedAt = now
+	sitemapCache.Unlock()
+
+	log.Printf("sitemap已生成，共 %d 个URL，%d 个文件", len(urls), len(pages))
+	return nil
+}
+
+// StartSitemapScheduler 启动sitemap定时生成任务
+func StartSitemapScheduler() {
+	interval := time.Duration(AppConfig.SitemapRefreshMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := RefreshSitemap(); err != nil {
+				log.Printf("sitemap生成失败: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+	log.Println("sitemap生成任务已启动")
+}
+
+// GetSitemap 获取sitemap.xml
+// @Summary 获取sitemap.xml
+// @Description 返回定时生成的sitemap；URL数量超过50000时返回sitemap索引，分页文件地址为 /sitemaps/{n}.xml
+// @Tags 站点
+// @Produce xml
+// @Success 200 {string} string "sitemap内容"
+// @Failure 503 {object} ApiResponse "sitemap尚未生成"
+// @Router /sitemap.xml [get]
+func GetSitemap(c *gin.Context) {
+	sitemapCache.RLock()
+	defer sitemapCache.RUnlock()
+
+	c.Header("Last-Modified", sitemapCache.generatedAt.UTC().Format(http.TimeFormat))
+	switch {
+	case sitemapCache.index != nil:
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", sitemapCache.index)
+	case len(sitemapCache.pages) == 1:
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", sitemapCache.pages[0])
+	default:
+		ErrorResponse(c, http.StatusServiceUnavailable, "sitemap尚未生成")
+	}
+}
+
+// GetSitemapPage 获取sitemap分页文件
+// @Summary 获取sitemap分页文件
+// @Description 大型商品目录拆分后的sitemap分页文件
+// @Tags 站点
+// @Produce xml
+// @Param file path string true "分页文件名，如 1.xml"
+// @Success 200 {string} string "sitemap内容"
+// @Failure 404 {object} ApiResponse "分页不存在"
+// @Router /sitemaps/{file} [get]
+func GetSitemapPage(c *gin.Context) {
+	page, err := strconv.Atoi(strings.TrimSuffix(c.Param("file"), ".xml"))
+
+	sitemapCache.RLock()
+	defer sitemapCache.RUnlock()
+
+	if err != nil || page < 1 || page > len(sitemapCache.pages) {
+		NotFoundError(c, "sitemap分页不存在")
+		return
+	}
+	c.Header("Last-Modified", sitemapCache.generatedAt.UTC().Format(http.TimeFormat))
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", sitemapCache.pages[page-1])
+}
+
+// GetRobotsTxt 获取robots.txt
+// @Summary 获取robots.txt
+// @Description 根据配置生成爬虫规则，并声明sitemap地址；ROBOTS_DISALLOW_ALL 开启时禁止所有爬虫（用于测试环境）
+// @Tags 站点
+// @Produce plain
+// @Success 200 {string} string "robots.txt内容"
+// @Router /robots.txt [get]
+func GetRobotsTxt(c *gin.Context) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if AppConfig.RobotsDisallowAll {
+		b.WriteString("Disallow: /\n")
+	} else {
+		for _, path := range splitEnvList(AppConfig.RobotsAllow) {
+			b.WriteString("Allow: " + path + "\n")
+		}
+		for _, path := range splitEnvList(AppConfig.RobotsDisallow) {
+			b.WriteString("Disallow: " + path + "\n")
+		}
+		b.WriteString("\nSitemap: " + siteURL("/sitemap.xml") + "\n")
+	}
+	c.String(http.StatusOK, b.String())
+}
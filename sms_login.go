@@ -0,0 +1,202 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 短信登录验证码的最大校验次数
+const smsCodeMaxAttempts = 5
+
+// 同一IP每小时可请求的短信验证码数量，防止批量刷短信
+const smsCodeIPHourlyLimit = 20
+
+// 手机号格式（大陆11位手机号或带国际区号的号码）
+var phonePattern = regexp.MustCompile(`^(1[3-9]\d{9}|\+[1-9]\d{6,14})$`)
+
+type SendSMSCodeRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+type SMSLoginRequest struct {
+	Phone string `json:"phone" binding:"required"`
+	Code  string `json:"code" binding:"required,len=6"`
+}
+
+func smsLoginCodeKey(phone string) string {
+	return "sms_login:code:" + phone
+}
+
+func smsLoginCooldownKey(phone string) string {
+	return "sms_login:cooldown:" + phone
+}
+
+func smsLoginDailyKey(phone string) string {
+	return fmt.Sprintf("sms_login:daily:%s:%s", phone, time.Now().Format("20060102"))
+}
+
+func smsLoginIPKey(ip string) string {
+	return "sms_login:ip:" + ip
+}
+
+// 计数并判断是否超过上限，首次计数时设置过期时间
+func incrWithinLimit(key string, limit int, window time.Duration) (bool, error) {
+	count, err := RDB.Incr(CTX, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		RDB.Expire(CTX, key, window)
+	}
+	return count <= int64(limit), nil
+}
+
+// SendSMSCode 发送短信登录验证码
+// @Summary 发送短信登录验证码
+// @Description 向手机号发送6位登录验证码，同一手机号有发送间隔和每日次数限制，同一IP有每小时次数限制
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body SendSMSCodeRequest true "手机号"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "发送成功"
+// @Failure 400 {object} ApiResponse "手机号格式错误"
+// @Failure 429 {object} ApiResponse "请求过于频繁"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/users/sms-code [post]
+func SendSMSCode(c *gin.Context) {
+	var req SendSMSCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	if !phonePattern.MatchString(req.Phone) {
+		BadRequestError(c, "手机号格式错误")
+		return
+	}
+
+	if ok, err := incrWithinLimit(smsLoginIPKey(c.ClientIP()), smsCodeIPHourlyLimit, time.Hour); err != nil {
+		InternalServerError(c, "验证码发送失败")
+		return
+	} else if !ok {
+		TooManyRequestsError(c, "请求过于频繁，请稍后再试")
+		return
+	}
+
+	// 发送间隔限制
+	cooldown := time.Duration(AppConfig.SMSCodeCooldownSeconds) * time.Second
+	if ok, _ := RDB.SetNX(CTX, smsLoginCooldownKey(req.Phone), 1, cooldown).Result(); !ok {
+		TooManyRequestsError(c, "验证码发送过于频繁，请稍后再试")
+		return
+	}
+
+	// 每日发送次数限制
+	if ok, err := incrWithinLimit(smsLoginDailyKey(req.Phone), AppConfig.SMSCodeDailyLimit, 24*time.Hour); err != nil {
+		InternalServerError(c, "验证码发送失败")
+		return
+	} else if !ok {
+		TooManyRequestsError(c, "该手机号今日验证码发送次数已达上限")
+		return
+	}
+
+	// 手机号未注册时返回相同提示，避免泄露账号信息
+	var user User
+	if err := DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		SuccessResponse(c, gin.H{"message": "验证码已发送"})
+		return
+	}
+
+	code, err := generateVerifyCode()
+	if err != nil {
+		InternalServerError(c, "验证码生成失败")
+		return
+	}
+
+	ttl := time.Duration(AppConfig.SMSCodeTTLMinutes) * time.Minute
+	codeKey := smsLoginCodeKey(req.Phone)
+	pipe := RDB.TxPipeline()
+	pipe.HSet(CTX, codeKey, "code", code, "attempts", 0)
+	pipe.Expire(CTX, codeKey, ttl)
+	if _, err := pipe.Exec(CTX); err != nil {
+		InternalServerError(c, "验证码保存失败")
+		return
+	}
+
+	if err := SMSProvider.SendVerifyCode(req.Phone, code); err != nil {
+		RDB.Del(CTX, codeKey)
+		if errors.Is(err, ErrMessageSuppressed) {
+			BadRequestError(c, "该手机号无法接收短信，请使用其他方式登录")
+			return
+		}
+		InternalServerError(c, "验证码发送失败")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "验证码已发送"})
+}
+
+// SMSLogin 短信验证码登录
+// @Summary 短信验证码登录
+// @Description 使用手机号和短信验证码登录，验证码错误次数过多后作废
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param request body SMSLoginRequest true "手机号与验证码"
+// @Success 200 {object} ApiResponse{data=LoginResponse} "登录成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 401 {object} ApiResponse "验证码错误或已过期"
+// @Failure 403 {object} ApiResponse "用户账号已被禁用"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Router /api/users/login/sms [post]
+func SMSLogin(c *gin.Context) {
+	var req SMSLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	codeKey := smsLoginCodeKey(req.Phone)
+	stored, err := RDB.HGet(CTX, codeKey, "code").Result()
+	if err != nil {
+		UnauthorizedError(c, "验证码错误或已过期")
+		return
+	}
+
+	// 校验次数限制，超过后验证码作废
+	attempts, _ := RDB.HIncrBy(CTX, codeKey, "attempts", 1).Result()
+	if attempts > smsCodeMaxAttempts {
+		RDB.Del(CTX, codeKey)
+		UnauthorizedError(c, "验证码错误次数过多，请重新获取")
+		return
+	}
+
+	if stored != req.Code {
+		UnauthorizedError(c, "验证码错误或已过期")
+		return
+	}
+	RDB.Del(CTX, codeKey)
+
+	var user User
+	if err := DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		UnauthorizedError(c, "验证码错误或已过期")
+		return
+	}
+
+	if user.Status != 1 {
+		ErrorResponse(c, http.StatusForbidden, "用户账号已被禁用")
+		return
+	}
+
+	resp, err := LoginUser(&user, c)
+	if err != nil {
+		InternalServerError(c, "token生成失败")
+		return
+	}
+
+	SuccessResponse(c, resp)
+}
@@ -0,0 +1,307 @@
+package gomall
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 库存以数据库为准，所有库存写入都在订单事务中锁定商品行后执行；
+// Redis中保存可售库存（实际库存减去预占数量），下单前通过Lua脚本原子预扣，
+// 商品售罄时直接拒绝，不必进入数据库抢锁
+const (
+	stockKeyPrefix = "products:stock:"
+	stockSyncLock  = "products:stock:syncing"
+)
+
+// 库存脚本返回的状态码
+const (
+	stockResultOK           = 0
+	stockResultInsufficient = 1
+	stockResultNotLoaded    = 2
+)
+
+// 扣减可售库存，库存不足时不做任何修改
+var deductStockScript = redis.NewScript(`
+local stock = redis.call('GET', KEYS[1])
+if not stock then
+	return {2, 0}
+end
+stock = tonumber(stock)
+local quantity = tonumber(ARGV[1])
+if stock < quantity then
+	return {1, stock}
+end
+return {0, redis.call('DECRBY', KEYS[1], quantity)}
+`)
+
+// 恢复可售库存
+var restoreStockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {2, 0}
+end
+return {0, redis.call('INCRBY', KEYS[1], ARGV[1])}
+`)
+
+// 对账修正可售库存，读取后被其他请求修改过时放弃本次修正
+var reconcileStockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1
+`)
+
+// StockManager 库存管理器，维护Redis中的可售库存，重启和多实例部署时保持一致
+type StockManager struct{}
+
+func stockKey(productID uint) string {
+	return stockKeyPrefix + strconv.FormatUint(uint64(productID), 10)
+}
+
+// 首次访问时从数据库加载可售库存，已有值时不覆盖
+func (sm *StockManager) loadStock(productID uint) error {
+	var product Product
+	if err := DB.Select("id, stock, presale, presale_quota").First(&product, productID).Error; err != nil {
+		return fmt.Errorf("无法获取商品 %d 的库存信息", productID)
+	}
+	return RDB.SetNX(CTX, stockKey(productID), product.orderableStock()-reservedStock(DB, productID), 0).Err()
+}
+
+// 执行库存脚本，库存未加载时先从数据库加载再重试一次
+func (sm *StockManager) runStockScript(script *redis.Script, productID uint, quantity int) (code int64, stock int, err error) {
+	keys := []string{stockKey(productID)}
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := script.Run(CTX, RDB, keys, quantity).Slice()
+		if err != nil {
+			return 0, 0, fmt.Errorf("商品 %d 库存操作失败: %v", productID, err)
+		}
+		code, stock := result[0].(int64), result[1].(int64)
+		if code != stockResultNotLoaded {
+			return code, int(stock), nil
+		}
+		if err := sm.loadStock(productID); err != nil {
+			return 0, 0, err
+		}
+	}
+	return 0, 0, fmt.Errorf("无法获取商品 %d 的库存信息", productID)
+}
+
+// ReserveStock 预扣可售库存，调用方随后需在事务中写入数据库库存，事务失败时调用 ReleaseStock
+func (sm *StockManager) ReserveStock(productID uint, quantity int) error {
+	code, stock, err := sm.runStockScript(deductStockScript, productID, quantity)
+	if err != nil {
+		return err
+	}
+	if code == stockResultInsufficient {
+		return fmt.Errorf("商品 %d 库存不足，当前库存: %d，需要: %d", productID, stock, quantity)
+	}
+	return nil
+}
+
+// ReleaseStock 退回可售库存，从缺货恢复时发送到货提醒
+func (sm *StockManager) ReleaseStock(productID uint, quantity int) error {
+	_, stock, err := sm.runStockScript(restoreStockScript, productID, quantity)
+	if err != nil {
+		return err
+	}
+	notifyRestockIfReplenished(productID, stock-quantity, stock)
+	return nil
+}
+
+// 按商品汇总的库存变动，事务提交后退回可售库存
+func (sm *StockManager) releaseAll(quantities map[uint]int) {
+	for productID, quantity := range quantities {
+		if err := sm.ReleaseStock(productID, quantity); err != nil {
+			log.Printf("恢复库存失败 - 商品ID: %d, 数量: %d, 错误: %v", productID, quantity, err)
+		}
+	}
+}
+
+// SetStock 后台直接修改数据库库存或预售设置后同步到Redis，可售库存需减去预占的数量，预售商品加上可预售的数量
+func (sm *StockManager) SetStock(productID uint, stock int) {
+	available := stock - reservedStock(DB, productID)
+	if allowances, err := presaleAllowances(DB, []uint{productID}); err == nil {
+		available += allowances[productID]
+	}
+	if err := RDB.Set(CTX, stockKey(productID), available, 0).Err(); err != nil {
+		// 同步失败时删除缓存的库存，下次访问重新从数据库加载
+		RDB.Del(CTX, stockKey(productID))
+		log.Printf("商品 %d 库存同步到Redis失败: %v", productID, err)
+	}
+}
+
+// 在事务中按商品ID顺序锁定商品行（SELECT ... FOR UPDATE），并发下单时不会死锁，
+// 返回实际库存减去预占数量后的可售库存
+func lockAvailableStock(tx *gorm.DB, productIDs []uint) (map[uint]int, error) {
+	ids := append([]uint(nil), productIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var products []Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, stock").
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[uint]int, len(products))
+	for _, product := range products {
+		available[product.ID] = product.Stock
+	}
+
+	var reserved []struct {
+		ProductID uint
+		Quantity  int
+	}
+	err = tx.Model(&StockReservation{}).
+		Select("product_id, SUM(quantity) AS quantity").
+		Where("product_id IN ? AND status = ?", ids, ReservationStatusReserved).
+		Group("product_id").
+		Scan(&reserved).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reserved {
+		available[r.ProductID] -= r.Quantity
+	}
+	return available, nil
+}
+
+// 在事务中检查可售库存是否足够，quantities 为每个商品的需求数量；预售商品可以超出库存预售
+func checkAvailableStock(tx *gorm.DB, quantities map[uint]int) error {
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	available, err := lockAvailableStock(tx, productIDs)
+	if err != nil {
+		return fmt.Errorf("库存查询失败: %v", err)
+	}
+	allowances, err := presaleAllowances(tx, productIDs)
+	if err != nil {
+		return fmt.Errorf("库存查询失败: %v", err)
+	}
+	for productID, quantity := range quantities {
+		stock, ok := available[productID]
+		if !ok {
+			return fmt.Errorf("商品 %d 不存在", productID)
+		}
+		stock += allowances[productID]
+		if stock < quantity {
+			return fmt.Errorf("商品 %d 库存不足，当前库存: %d，需要: %d", productID, stock, quantity)
+		}
+	}
+	return nil
+}
+
+// 在事务中扣减数据库库存和订单发货仓库的库存并写入库存流水，需先通过 checkAvailableStock 锁定商品行
+func deductStockInTx(tx *gorm.DB, order *Order, quantities map[uint]int) error {
+	return changeOrderStock(tx, order, quantities, -1, MovementTypeOrder)
+}
+
+// 在事务中恢复订单扣减的数据库库存和发货仓库的库存并写入库存流水
+func restoreStockInTx(tx *gorm.DB, order *Order, quantities map[uint]int) error {
+	return changeOrderStock(tx, order, quantities, 1, MovementTypeCancel)
+}
+
+func changeOrderStock(tx *gorm.DB, order *Order, quantities map[uint]int, sign int, movementType string) error {
+	for productID, quantity := range quantities {
+		delta := sign * quantity
+		err := tx.Model(&Product{}).Where("id = ?", productID).
+			UpdateColumn("stock", gorm.Expr("stock + ?", delta)).Error
+		if err != nil {
+			return err
+		}
+		warehouseID, err := changeWarehouseStock(tx, order.WarehouseID, productID, delta)
+		if err != nil {
+			return err
+		}
+		err = recordInventoryMovement(tx, InventoryMovement{
+			ProductID:   productID,
+			WarehouseID: warehouseID,
+			Type:        movementType,
+			Quantity:    delta,
+			OrderID:     &order.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 以数据库为准修正Redis中的可售库存
+func reconcileStock() {
+	var cursor uint64
+	fixed := 0
+	for {
+		keys, next, err := RDB.Scan(CTX, cursor, stockKeyPrefix+"[0-9]*", 500).Result()
+		if err != nil {
+			log.Printf("库存对账扫描失败: %v", err)
+			return
+		}
+		for _, key := range keys {
+			productID, err := strconv.ParseUint(key[len(stockKeyPrefix):], 10, 32)
+			if err != nil {
+				continue
+			}
+			cached, err := RDB.Get(CTX, key).Result()
+			if err != nil {
+				continue
+			}
+
+			var product Product
+			if err := DB.Select("id, stock, presale, presale_quota").First(&product, productID).Error; err != nil {
+				RDB.Del(CTX, key) // 商品已删除
+				continue
+			}
+			expected := product.orderableStock() - reservedStock(DB, uint(productID))
+			if cached == strconv.Itoa(expected) {
+				continue
+			}
+			changed, err := reconcileStockScript.Run(CTX, RDB, []string{key}, cached, expected).Int()
+			if err != nil || changed == 0 {
+				continue // 对账期间有新的下单或退回，留到下次对账
+			}
+			log.Printf("商品 %d 可售库存不一致，Redis %s，数据库应为 %d，已修正", productID, cached, expected)
+			fixed++
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	if fixed > 0 {
+		log.Printf("库存对账完成，修正 %d 个商品", fixed)
+	}
+}
+
+// StartStockSync 启动库存对账任务，多实例部署时同一时间只由一个实例执行
+func StartStockSync() {
+	interval := time.Duration(AppConfig.StockReconcileMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 30 * time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if ok, _ := RDB.SetNX(CTX, stockSyncLock, 1, 10*time.Minute).Result(); ok {
+				reconcileStock()
+				RDB.Del(CTX, stockSyncLock)
+			}
+		}
+	}()
+	log.Println("库存对账任务已启动")
+}
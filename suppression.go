@@ -0,0 +1,436 @@
+package gomall
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// 消息通道
+const (
+	MessageChannelEmail = "email"
+	MessageChannelSMS   = "sms"
+)
+
+// 屏蔽原因
+const (
+	SuppressionHardBounce  = "hard_bounce" // 地址无效或永久退信
+	SuppressionUnsubscribe = "unsubscribe" // 用户退订营销消息
+	SuppressionLegalBlock  = "legal_block" // 法务或监管要求停止联系
+)
+
+// 屏蔽记录来源
+const (
+	SuppressionSourceAdmin    = "admin"
+	SuppressionSourceSendGrid = "sendgrid"
+	SuppressionSourceSES      = "ses"
+	SuppressionSourceTwilio   = "twilio"
+)
+
+// 确认SNS订阅使用的HTTP客户端
+var webhookHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// ErrMessageSuppressed 收件地址在屏蔽名单中
+var ErrMessageSuppressed = errors.New("收件地址已被屏蔽")
+
+// 交易类消息（验证码、密码重置等）只受退信和法务屏蔽限制，营销消息还受退订限制
+var transactionalSuppressionReasons = []string{SuppressionHardBounce, SuppressionLegalBlock}
+
+type CreateSuppressionRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email sms"`
+	Address string `json:"address" binding:"required,max=255"`
+	Reason  string `json:"reason" binding:"required,oneof=hard_bounce unsubscribe legal_block"`
+	Note    string `json:"note" binding:"max=500"`
+}
+
+// 统一地址格式：邮箱不区分大小写，手机号去掉默认国际区号以便与用户资料匹配
+func normalizeMessageAddress(channel, address string) string {
+	address = strings.TrimSpace(address)
+	if channel == MessageChannelEmail {
+		return strings.ToLower(address)
+	}
+	if code := AppConfig.SMSDefaultCountryCode; code != "" {
+		address = strings.TrimPrefix(address, code)
+	}
+	return address
+}
+
+// IsSuppressed 检查地址是否被屏蔽，marketing 为 true 时同时检查退订
+func IsSuppressed(channel, address string, marketing bool) bool {
+	query := DB.Model(&MessageSuppression{}).
+		Where("channel = ? AND address = ?", channel, normalizeMessageAddress(channel, address))
+	if !marketing {
+		query = query.Where("reason IN ?", transactionalSuppressionReasons)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		// 查询失败时不阻断交易消息，但营销消息宁可不发
+		log.Printf("屏蔽名单查询失败: %v", err)
+		return marketing
+	}
+	return count > 0
+}
+
+// AddSuppression 添加屏蔽记录，同一地址同一原因只保留一条
+func AddSuppression(channel, address, reason, source, note string, createdBy uint) (*MessageSuppression, error) {
+	suppression := MessageSuppression{
+		Channel:   channel,
+		Address:   normalizeMessageAddress(channel, address),
+		Reason:    reason,
+		Source:    source,
+		Note:      note,
+		CreatedBy: createdBy,
+	}
+	if suppression.Address == "" {
+		return nil, fmt.Errorf("地址不能为空")
+	}
+
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&suppression).Error
+	if err != nil {
+		return nil, err
+	}
+	if suppression.ID == 0 {
+		DB.Where("channel = ? AND address = ? AND reason = ?", suppression.Channel, suppression.Address, reason).First(&suppression)
+	}
+	return &suppression, nil
+}
+
+// SuppressedEmailSender 发送前检查屏蔽名单的邮件发送器
+type SuppressedEmailSender struct {
+	Next EmailSender
+}
+
+// SendEmail 发送交易类邮件
+func (s *SuppressedEmailSender) SendEmail(to, subject, body string) error {
+	if IsSuppressed(MessageChannelEmail, to, false) {
+		log.Printf("邮件 %s 未发送: %s 在屏蔽名单中", subject, to)
+		return ErrMessageSuppressed
+	}
+	return s.Next.SendEmail(to, subject, body)
+}
+
+// SuppressedSMSSender 发送前检查屏蔽名单的短信发送器
+type SuppressedSMSSender struct {
+	Next SMSSender
+}
+
+// SendVerifyCode 发送验证码短信
+func (s *SuppressedSMSSender) SendVerifyCode(phone, code string) error {
+	if IsSuppressed(MessageChannelSMS, phone, false) {
+		log.Printf("验证码短信未发送: %s 在屏蔽名单中", phone)
+		return ErrMessageSuppressed
+	}
+	return s.Next.SendVerifyCode(phone, code)
+}
+
+// SendMarketingEmail 发送营销邮件，已退订的地址同样会被跳过
+func SendMarketingEmail(to, subject, body string) error {
+	if IsSuppressed(MessageChannelEmail, to, true) {
+		return ErrMessageSuppressed
+	}
+	return Mailer.SendEmail(to, subject, body)
+}
+
+// GetSuppressions 获取屏蔽名单（管理端）
+// @Summary 获取屏蔽名单
+// @Description 分页获取邮件和短信屏蔽记录，可按通道、原因、地址筛选
+// @Tags 消息屏蔽
+// @Accept json
+// @Produce json
+// @Param channel query string false "通道：email、sms"
+// @Param reason query string false "原因：hard_bounce、unsubscribe、legal_block"
+// @Param address query string false "地址（模糊匹配）"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]MessageSuppression}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/suppressions [get]
+func GetSuppressions(c *gin.Context) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&MessageSuppression{})
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if address := strings.TrimSpace(c.Query("address")); address != "" {
+		query = query.Where("address LIKE ?", "%"+strings.ToLower(address)+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var suppressions []MessageSuppression
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&suppressions).Error; err != nil {
+		InternalServerError(c, "屏蔽名单查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, suppressions, total, page, pageSize)
+}
+
+// CreateSuppression 添加屏蔽记录（管理端）
+// @Summary 添加屏蔽记录
+// @Description 手动将邮箱或手机号加入屏蔽名单；退订只屏蔽营销消息，退信和法务屏蔽同时屏蔽验证码等交易消息
+// @Tags 消息屏蔽
+// @Accept json
+// @Produce json
+// @Param suppression body CreateSuppressionRequest true "屏蔽信息"
+// @Success 200 {object} ApiResponse{data=MessageSuppression} "添加成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/suppressions [post]
+func CreateSuppression(c *gin.Context) {
+	var req CreateSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	suppression, err := AddSuppression(req.Channel, req.Address, req.Reason, SuppressionSourceAdmin, req.Note, userID.(uint))
+	if err != nil {
+		InternalServerError(c, "屏蔽记录添加失败")
+		return
+	}
+
+	SuccessResponse(c, suppression)
+}
+
+// DeleteSuppression 删除屏蔽记录（管理端）
+// @Summary 删除屏蔽记录
+// @Description 解除屏蔽，例如用户更换邮箱服务商后重新订阅
+// @Tags 消息屏蔽
+// @Accept json
+// @Produce json
+// @Param id path int true "屏蔽记录ID"
+// @Success 200 {object} ApiResponse{data=object{message=string}} "删除成功"
+// @Failure 400 {object} ApiResponse "无效的屏蔽记录ID"
+// @Failure 404 {object} ApiResponse "屏蔽记录不存在"
+// @Security Bearer
+// @Router /api/admin/suppressions/{id} [delete]
+func DeleteSuppression(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的屏蔽记录ID")
+		return
+	}
+
+	result := DB.Delete(&MessageSuppression{}, id)
+	if result.Error != nil {
+		InternalServerError(c, "屏蔽记录删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		NotFoundError(c, "屏蔽记录不存在")
+		return
+	}
+
+	SuccessResponse(c, gin.H{"message": "屏蔽记录已删除"})
+}
+
+// RequireWebhookToken 校验服务商回调地址中携带的令牌
+func RequireWebhookToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if AppConfig.SuppressionWebhookToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(AppConfig.SuppressionWebhookToken)) != 1 {
+			UnauthorizedError(c, "无效的回调令牌")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SendGridWebhook 接收SendGrid事件回调
+// @Summary SendGrid事件回调
+// @Description 接收SendGrid Event Webhook，将永久退信、退订和垃圾邮件投诉加入屏蔽名单
+// @Tags 消息屏蔽
+// @Accept json
+// @Produce json
+// @Param token query string true "回调令牌"
+// @Success 200 {object} ApiResponse{data=object{suppressed=int}} "处理成功"
+// @Failure 400 {object} ApiResponse "回调数据格式错误"
+// @Failure 401 {object} ApiResponse "无效的回调令牌"
+// @Router /api/webhooks/sendgrid [post]
+func SendGridWebhook(c *gin.Context) {
+	var events []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&events); err != nil {
+		BadRequestError(c, "回调数据格式错误")
+		return
+	}
+
+	suppressed := 0
+	for _, event := range events {
+		reason := ""
+		switch event.Event {
+		case "bounce":
+			// SendGrid 用 type=blocked 表示临时拦截，只有 bounce 类型才是永久退信
+			if event.Type != "blocked" {
+				reason = SuppressionHardBounce
+			}
+		case "unsubscribe", "group_unsubscribe", "spamreport":
+			reason = SuppressionUnsubscribe
+		}
+		if reason == "" {
+			continue
+		}
+		if _, err := AddSuppression(MessageChannelEmail, event.Email, reason, SuppressionSourceSendGrid, event.Reason, 0); err == nil {
+			suppressed++
+		}
+	}
+
+	SuccessResponse(c, gin.H{"suppressed": suppressed})
+}
+
+// SESWebhook 接收Amazon SES通过SNS推送的退信通知
+// @Summary Amazon SES退信回调
+// @Description 接收SNS推送的SES退信和投诉通知，永久退信加入退信屏蔽，投诉加入退订屏蔽；首次配置时自动确认SNS订阅
+// @Tags 消息屏蔽
+// @Accept json
+// @Produce json
+// @Param token query string true "回调令牌"
+// @Success 200 {object} ApiResponse{data=object{suppressed=int}} "处理成功"
+// @Failure 400 {object} ApiResponse "回调数据格式错误"
+// @Failure 401 {object} ApiResponse "无效的回调令牌"
+// @Router /api/webhooks/ses [post]
+func SESWebhook(c *gin.Context) {
+	// SNS 推送的 Content-Type 为 text/plain，需要手动解析
+	var envelope struct {
+		Type         string `json:"Type"`
+		Message      string `json:"Message"`
+		SubscribeURL string `json:"SubscribeURL"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&envelope); err != nil {
+		BadRequestError(c, "回调数据格式错误")
+		return
+	}
+
+	if envelope.Type == "SubscriptionConfirmation" {
+		subscribeURL, err := url.Parse(envelope.SubscribeURL)
+		if err != nil || subscribeURL.Scheme != "https" || !strings.HasSuffix(subscribeURL.Host, ".amazonaws.com") {
+			BadRequestError(c, "无效的订阅确认地址")
+			return
+		}
+		resp, err := webhookHTTPClient.Get(subscribeURL.String())
+		if err != nil {
+			InternalServerError(c, "订阅确认失败")
+			return
+		}
+		resp.Body.Close()
+		SuccessResponse(c, gin.H{"suppressed": 0})
+		return
+	}
+
+	var notification struct {
+		NotificationType string `json:"notificationType"`
+		Bounce           struct {
+			BounceType        string `json:"bounceType"`
+			BouncedRecipients []struct {
+				EmailAddress   string `json:"emailAddress"`
+				DiagnosticCode string `json:"diagnosticCode"`
+			} `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplainedRecipients []struct {
+				EmailAddress string `json:"emailAddress"`
+			} `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+	if err := json.Unmarshal([]byte(envelope.Message), &notification); err != nil {
+		BadRequestError(c, "回调数据格式错误")
+		return
+	}
+
+	suppressed := 0
+	switch notification.NotificationType {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			break
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			if _, err := AddSuppression(MessageChannelEmail, recipient.EmailAddress, SuppressionHardBounce, SuppressionSourceSES, recipient.DiagnosticCode, 0); err == nil {
+				suppressed++
+			}
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			if _, err := AddSuppression(MessageChannelEmail, recipient.EmailAddress, SuppressionUnsubscribe, SuppressionSourceSES, "投诉", 0); err == nil {
+				suppressed++
+			}
+		}
+	}
+
+	SuccessResponse(c, gin.H{"suppressed": suppressed})
+}
+
+// Twilio 中表示号码永久不可达或用户已回复STOP的错误码
+var twilioSuppressionErrors = map[string]string{
+	"21211": SuppressionHardBounce,  // 无效号码
+	"21614": SuppressionHardBounce,  // 非手机号码
+	"30005": SuppressionHardBounce,  // 号码不存在
+	"30006": SuppressionHardBounce,  // 固话或运营商不可达
+	"21610": SuppressionUnsubscribe, // 用户已退订（回复STOP）
+}
+
+// TwilioWebhook 接收Twilio短信状态回调
+// @Summary Twilio短信状态回调
+// @Description 接收Twilio状态回调，号码无效或不可达时加入退信屏蔽，用户回复STOP时加入退订屏蔽
+// @Tags 消息屏蔽
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token query string true "回调令牌"
+// @Success 200 {object} ApiResponse{data=object{suppressed=int}} "处理成功"
+// @Failure 401 {object} ApiResponse "无效的回调令牌"
+// @Router /api/webhooks/twilio [post]
+func TwilioWebhook(c *gin.Context) {
+	status := c.PostForm("MessageStatus")
+	reason, ok := twilioSuppressionErrors[c.PostForm("ErrorCode")]
+	if !ok || (status != "failed" && status != "undelivered") {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	suppressed := 0
+	note := "Twilio错误码 " + c.PostForm("ErrorCode")
+	if _, err := AddSuppression(MessageChannelSMS, c.PostForm("To"), reason, SuppressionSourceTwilio, note, 0); err == nil {
+		suppressed++
+	}
+
+	SuccessResponse(c, gin.H{"suppressed": suppressed})
+}
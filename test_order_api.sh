@@ -130,16 +130,31 @@ echo "订单详情响应: $ORDER_DETAIL_RESPONSE"
 
 echo ""
 
-# 9. 更新订单状态（模拟支付）
-echo "9. 更新订单状态（模拟支付）..."
-UPDATE_ORDER_STATUS_RESPONSE=$(curl -s -X PUT http://localhost:8080/api/orders/$ORDER_ID/status \
+# 9. 发起支付并模拟支付回调（服务端需启用 mock 渠道并设置相同的 PAYMENT_MOCK_SECRET）
+echo "9. 发起支付（模拟支付）..."
+PAY_RESPONSE=$(curl -s -X POST http://localhost:8080/api/orders/$ORDER_ID/pay \
   -H "Content-Type: application/json" \
   -H "Authorization: Bearer $TOKEN" \
   -d '{
-    "status": "paid"
+    "channel": "mock"
   }')
 
-echo "支付成功响应: $UPDATE_ORDER_STATUS_RESPONSE"
+echo "发起支付响应: $PAY_RESPONSE"
+
+PAYMENT_NO=$(echo $PAY_RESPONSE | grep -o '"payment_no":"[^"]*' | cut -d'"' -f4)
+PAY_AMOUNT=$(echo $PAY_RESPONSE | grep -o '"amount":[0-9.]*' | cut -d':' -f2)
+AMOUNT_CENTS=$(awk "BEGIN { printf \"%d\", $PAY_AMOUNT * 100 + 0.5 }")
+NOTIFY_BODY="{\"payment_no\":\"$PAYMENT_NO\",\"trade_no\":\"MOCK$PAYMENT_NO\",\"amount_cents\":$AMOUNT_CENTS,\"status\":\"succeeded\"}"
+NOTIFY_TIMESTAMP=$(date +%s)
+NOTIFY_SIGNATURE=$(printf '%s.%s' "$NOTIFY_TIMESTAMP" "$NOTIFY_BODY" | openssl dgst -sha256 -hmac "${PAYMENT_MOCK_SECRET:-test-secret}" | awk '{print $NF}')
+
+UPDATE_ORDER_STATUS_RESPONSE=$(curl -s -X POST http://localhost:8080/api/webhooks/payments/mock \
+  -H "Content-Type: application/json" \
+  -H "X-Signature-Timestamp: $NOTIFY_TIMESTAMP" \
+  -H "X-Signature: $NOTIFY_SIGNATURE" \
+  -d "$NOTIFY_BODY")
+
+echo "支付回调响应: $UPDATE_ORDER_STATUS_RESPONSE"
 
 echo ""
 
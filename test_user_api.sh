@@ -1,6 +1,7 @@
 #!/bin/bash
 
 # 测试用户API脚本
+# 注册接口需要验证码，运行前请以 CAPTCHA_PROVIDER=none 启动服务
 
 echo "=== GoMall 用户管理API测试 ==="
 
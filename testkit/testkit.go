@@ -0,0 +1,255 @@
+// Package testkit 在进程内启动 GoMall 服务用于集成测试：数据库使用临时目录中的 SQLite，
+// Redis 使用 miniredis，HTTP 服务使用 httptest，并预置管理员、商家、普通用户和一个已发布的商品。
+//
+// 服务状态保存在 gomall 的包级变量中，同一时间只能有一个 Kit，使用 testkit 的测试不能并行执行。
+// 服务按相对路径加载 templates 目录，在模块根目录之外运行时首页模板不可用，不影响 API 接口。
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	gomall "GoMall"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// FixturePassword 预置用户的登录密码
+const FixturePassword = "Passw0rd!"
+
+// 订单服务的工作协程在进程内只启动一次
+var orderServiceOnce sync.Once
+
+// Fixtures 预置的测试数据
+type Fixtures struct {
+	Admin    gomall.User // 管理员
+	Seller   gomall.User // 商家，Product 的所属商家
+	Customer gomall.User // 普通用户
+	Category gomall.Category
+	Product  gomall.Product // Seller 发布的商品，带成本价和下载地址
+}
+
+// Kit 运行中的测试服务
+type Kit struct {
+	Server   *httptest.Server
+	DB       *gorm.DB
+	Redis    *redis.Client
+	Config   *gomall.Config
+	Fixtures Fixtures
+
+	t      testing.TB
+	tokens map[uint]string
+}
+
+// Response 接口响应
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Decode 将响应中的 data 字段解析到 v
+func (r *Response) Decode(v interface{}) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(r.Body, &envelope); err != nil {
+		return err
+	}
+	return json.Unmarshal(envelope.Data, v)
+}
+
+// New 启动测试服务并写入预置数据，测试结束时自动关闭
+func New(t testing.TB) *Kit {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	dsn := filepath.Join(t.TempDir(), "gomall.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("testkit: 打开SQLite失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("testkit: 获取数据库实例失败: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	cfg := gomall.LoadConfig()
+	cfg.GinMode = gin.TestMode
+	cfg.JWTSecret = "testkit-secret"
+	cfg.CaptchaProvider = gomall.CaptchaProviderNone
+	cfg.DigitalDownloadHosts = "files.example.com"
+
+	gomall.AppConfig = cfg
+	gomall.DB = db
+	gomall.RDB = rdb
+	// 空库没有需要补全的旧数据，且补全迁移使用MySQL专有语法，这里只建表和初始化角色
+	if err := gomall.AutoMigrate(); err != nil {
+		t.Fatalf("testkit: 表结构迁移失败: %v", err)
+	}
+	if err := gomall.SeedRoles(); err != nil {
+		t.Fatalf("testkit: 角色初始化失败: %v", err)
+	}
+	gomall.InitSenders(cfg)
+	gomall.InitCaptcha(cfg)
+	gomall.InitPaymentGateways(cfg)
+	orderServiceOnce.Do(gomall.InitOrderService)
+
+	engine, err := gomall.NewServer(cfg, gomall.ServerDeps{DB: db, Redis: rdb})
+	if err != nil {
+		t.Fatalf("testkit: 服务创建失败: %v", err)
+	}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	k := &Kit{
+		Server: server,
+		DB:     db,
+		Redis:  rdb,
+		Config: cfg,
+		t:      t,
+		tokens: make(map[uint]string),
+	}
+	k.seed()
+	return k
+}
+
+// 写入预置用户、分类和商品
+func (k *Kit) seed() {
+	k.t.Helper()
+	k.Fixtures.Admin = k.CreateUser("admin", gomall.RoleAdmin)
+	k.Fixtures.Seller = k.CreateUser("seller", gomall.RoleSeller)
+	k.Fixtures.Customer = k.CreateUser("customer")
+
+	k.Fixtures.Category = gomall.Category{Name: "测试分类"}
+	if err := k.DB.Create(&k.Fixtures.Category).Error; err != nil {
+		k.t.Fatalf("testkit: 分类创建失败: %v", err)
+	}
+	k.Fixtures.Product = k.CreateProduct(k.Fixtures.Seller.ID, gomall.Product{
+		Name:        "测试商品",
+		Price:       99,
+		CostPrice:   40,
+		Stock:       100,
+		ProductType: gomall.ProductTypeDigital,
+		DownloadURL: "https://files.example.com/testkit.zip",
+	})
+}
+
+// CreateUser 创建可登录的用户，所有用户都有普通用户角色，roles 为额外的角色
+func (k *Kit) CreateUser(username string, roles ...string) gomall.User {
+	k.t.Helper()
+	user := gomall.User{
+		Username:     username,
+		Email:        username + "@testkit.local",
+		PasswordHash: gomall.HashPassword(FixturePassword),
+		Status:       1,
+	}
+	if err := k.DB.Create(&user).Error; err != nil {
+		k.t.Fatalf("testkit: 用户 %s 创建失败: %v", username, err)
+	}
+	for _, role := range append([]string{gomall.RoleCustomer}, roles...) {
+		if err := gomall.AssignRole(user.ID, role); err != nil {
+			k.t.Fatalf("testkit: 用户 %s 分配角色失败: %v", username, err)
+		}
+	}
+	return user
+}
+
+// CreateProduct 创建已发布的商品，sellerID 为0时为管理员创建的商品，未指定分类时使用预置分类
+func (k *Kit) CreateProduct(sellerID uint, product gomall.Product) gomall.Product {
+	k.t.Helper()
+	if sellerID != 0 {
+		product.SellerID = &sellerID
+	}
+	if product.CategoryID == 0 {
+		product.CategoryID = k.Fixtures.Category.ID
+	}
+	if product.ProductType == "" {
+		product.ProductType = gomall.ProductTypePhysical
+	}
+	product.Status = 1
+	product.PublishStatus = gomall.ProductStatePublished
+	if err := k.DB.Create(&product).Error; err != nil {
+		k.t.Fatalf("testkit: 商品创建失败: %v", err)
+	}
+	// 新商品需要加入商品ID集合，否则详情接口按不存在处理
+	if err := gomall.RebuildProductIDSet(); err != nil {
+		k.t.Fatalf("testkit: 商品ID集合重建失败: %v", err)
+	}
+	return product
+}
+
+// Token 通过登录接口获取用户的访问令牌，同一用户只登录一次
+func (k *Kit) Token(user gomall.User) string {
+	k.t.Helper()
+	if token, ok := k.tokens[user.ID]; ok {
+		return token
+	}
+	resp := k.Do(http.MethodPost, "/api/users/login", "", map[string]string{
+		"username": user.Username,
+		"password": FixturePassword,
+	})
+	var login gomall.LoginResponse
+	if resp.StatusCode != http.StatusOK {
+		k.t.Fatalf("testkit: 用户 %s 登录失败: %d %s", user.Username, resp.StatusCode, resp.Body)
+	}
+	if err := resp.Decode(&login); err != nil {
+		k.t.Fatalf("testkit: 登录响应解析失败: %v", err)
+	}
+	k.tokens[user.ID] = login.Token
+	return login.Token
+}
+
+// Do 调用接口，token 为空时不携带认证信息，body 不为空时按JSON发送
+func (k *Kit) Do(method, path, token string, body interface{}) *Response {
+	k.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			k.t.Fatalf("testkit: 请求体序列化失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, k.Server.URL+path, reader)
+	if err != nil {
+		k.t.Fatalf("testkit: 请求创建失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := k.Server.Client().Do(req)
+	if err != nil {
+		k.t.Fatalf("testkit: %s %s 请求失败: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		k.t.Fatalf("testkit: 响应读取失败: %v", err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Body: data}
+}
+
+// DoAs 以用户身份调用接口
+func (k *Kit) DoAs(user gomall.User, method, path string, body interface{}) *Response {
+	k.t.Helper()
+	return k.Do(method, path, k.Token(user), body)
+}
@@ -0,0 +1,213 @@
+package gomall
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// 访问令牌有效期
+func accessTokenTTL() time.Duration {
+	return time.Duration(AppConfig.AccessTokenExpireMinutes) * time.Minute
+}
+
+// 刷新令牌有效期
+func refreshTokenTTL() time.Duration {
+	return time.Duration(AppConfig.RefreshTokenExpireHours) * time.Hour
+}
+
+func refreshTokenKey(token string) string {
+	return "refresh_token:" + token
+}
+
+func userRefreshTokensKey(userID uint) string {
+	return fmt.Sprintf("refresh_tokens:user:%d", userID)
+}
+
+// 生成随机令牌字符串
+func generateSecureToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreateRefreshToken 生成绑定会话的刷新令牌并保存到Redis
+func CreateRefreshToken(userID uint, sessionID string) (string, error) {
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := refreshTokenTTL()
+	pipe := RDB.TxPipeline()
+	pipe.Set(CTX, refreshTokenKey(token), fmt.Sprintf("%d:%s", userID, sessionID), ttl)
+	pipe.SAdd(CTX, userRefreshTokensKey(userID), token)
+	pipe.Expire(CTX, userRefreshTokensKey(userID), ttl)
+	if _, err := pipe.Exec(CTX); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeRefreshToken 校验并作废刷新令牌，返回所属用户ID和会话ID
+func ConsumeRefreshToken(token string) (uint, string, error) {
+	value, err := RDB.GetDel(CTX, refreshTokenKey(token)).Result()
+	if err != nil {
+		return 0, "", fmt.Errorf("刷新令牌无效或已过期")
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || len(parts) != 2 {
+		return 0, "", fmt.Errorf("刷新令牌无效")
+	}
+
+	RDB.SRem(CTX, userRefreshTokensKey(uint(userID)), token)
+	return uint(userID), parts[1], nil
+}
+
+// RevokeUserRefreshTokens 撤销用户的全部刷新令牌
+func RevokeUserRefreshTokens(userID uint) error {
+	setKey := userRefreshTokensKey(userID)
+	tokens, err := RDB.SMembers(CTX, setKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, refreshTokenKey(token))
+	}
+	keys = append(keys, setKey)
+	return RDB.Del(CTX, keys...).Err()
+}
+
+func tokenBlacklistKey(jti string) string {
+	return "token:blacklist:" + jti
+}
+
+func userTokensRevokedKey(userID uint) string {
+	return fmt.Sprintf("token:revoked_before:%d", userID)
+}
+
+// BlacklistToken 将访问令牌加入黑名单，直到其自然过期
+func BlacklistToken(jti string, expiresAt int64) error {
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return RDB.Set(CTX, tokenBlacklistKey(jti), 1, ttl).Err()
+}
+
+// IsTokenRevoked 判断访问令牌是否已被拉黑，或签发时间早于用户的全局撤销时间
+func IsTokenRevoked(claims *JWTClaims) bool {
+	if n, _ := RDB.Exists(CTX, tokenBlacklistKey(claims.Id)).Result(); n > 0 {
+		return true
+	}
+	revokedBefore, err := RDB.Get(CTX, userTokensRevokedKey(claims.UserID)).Int64()
+	return err == nil && claims.IssuedAt < revokedBefore
+}
+
+// InvalidateUserSessions 使用户已签发的全部令牌和登录会话失效（修改密码、重置密码、禁用账号时调用）
+func InvalidateUserSessions(userID uint) {
+	RDB.Set(CTX, userTokensRevokedKey(userID), time.Now().Unix(), accessTokenTTL())
+	DeleteAllSessions(userID)
+	RevokeUserRefreshTokens(userID)
+}
+
+// LoginUser 创建登录会话并签发令牌
+func LoginUser(user *User, c *gin.Context) (*LoginResponse, error) {
+	session, err := CreateSession(user.ID, c)
+	if err != nil {
+		return nil, err
+	}
+	// 登录成功后本次响应按该用户身份过滤字段
+	c.Set("user_id", user.ID)
+	return IssueTokens(user, session.ID)
+}
+
+// IssueTokens 为用户的登录会话签发访问令牌和刷新令牌
+func IssueTokens(user *User, sessionID string) (*LoginResponse, error) {
+	token, err := GenerateJWT(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := CreateRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL().Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// RefreshAccessToken 使用刷新令牌换取新的访问令牌
+// @Summary 刷新访问令牌
+// @Description 使用刷新令牌换取新的访问令牌，旧的刷新令牌同时作废（轮换）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param token body RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} ApiResponse{data=LoginResponse} "刷新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 401 {object} ApiResponse "刷新令牌无效或已过期"
+// @Failure 403 {object} ApiResponse "用户账号已被禁用"
+// @Router /api/users/refresh [post]
+func RefreshAccessToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	userID, sessionID, err := ConsumeRefreshToken(req.RefreshToken)
+	if err != nil {
+		UnauthorizedError(c, err.Error())
+		return
+	}
+
+	// 会话已被注销时刷新令牌随之失效
+	session, err := GetSession(sessionID)
+	if err != nil || session.UserID != userID {
+		UnauthorizedError(c, "登录会话已失效，请重新登录")
+		return
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		UnauthorizedError(c, "用户不存在")
+		return
+	}
+
+	if user.Status != 1 {
+		ErrorResponse(c, http.StatusForbidden, "用户账号已被禁用")
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	resp, err := IssueTokens(user, session.ID)
+	if err != nil {
+		InternalServerError(c, "token生成失败")
+		return
+	}
+	ExtendSession(session)
+
+	SuccessResponse(c, resp)
+}
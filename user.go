@@ -1,9 +1,10 @@
-package main
+package gomall
 
 import (
 	"crypto/md5"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -12,19 +13,22 @@ import (
 
 // JWTClaims JWT声明结构体
 type JWTClaims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    uint     `json:"user_id"`
+	Username  string   `json:"username"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"sid"` // 登录会话ID
 	jwt.StandardClaims
 }
 
 // 请求和响应结构体
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Phone    string `json:"phone"`
-	RealName string `json:"real_name"`
+	Username     string `json:"username" binding:"required,min=3,max=50"`
+	Email        string `json:"email" binding:"required,email"`
+	Password     string `json:"password" binding:"required,min=6"`
+	Phone        string `json:"phone"`
+	RealName     string `json:"real_name"`
+	ReferralCode string `json:"referral_code"` // 邀请码（可选）
 }
 
 type LoginRequest struct {
@@ -33,8 +37,10 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌有效期（秒）
+	User         User   `json:"user"`
 }
 
 type UpdateProfileRequest struct {
@@ -48,6 +54,10 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
 
+type UpdateUserStatusRequest struct {
+	Status *int `json:"status" binding:"required,oneof=0 1"` // 1正常 0禁用
+}
+
 // MD5加密密码
 func HashPassword(password string) string {
 	hash := md5.Sum([]byte(password))
@@ -60,15 +70,28 @@ func VerifyPassword(password, hashedPassword string) bool {
 }
 
 // 生成JWT Token
-func GenerateJWT(user *User) (string, error) {
+func GenerateJWT(user *User, sessionID string) (string, error) {
+	roles, err := GetUserRoles(user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := generateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := JWTClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Roles:     roles,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24 * 7).Unix(), // 7天过期
+			ExpiresAt: time.Now().Add(accessTokenTTL()).Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Issuer:    "gomall",
+			Id:        jti,
 		},
 	}
 
@@ -93,40 +116,82 @@ func ParseJWT(tokenString string) (*JWTClaims, error) {
 	return nil, fmt.Errorf("无效的token")
 }
 
-// JWT认证中间件
-func JWTAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			ErrorResponse(c, http.StatusUnauthorized, "缺少认证token")
-			c.Abort()
-			return
-		}
+// 校验请求携带的token和登录会话，成功时将用户信息保存到上下文，失败时返回错误信息
+func authenticateRequest(c *gin.Context) string {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		return "缺少认证token"
+	}
 
-		// 处理Bearer token格式
-		if len(token) > 7 && token[:7] == "Bearer " {
-			token = token[7:]
-		}
+	// 处理Bearer token格式
+	if len(token) > 7 && token[:7] == "Bearer " {
+		token = token[7:]
+	}
 
-		claims, err := ParseJWT(token)
-		if err != nil {
-			ErrorResponse(c, http.StatusUnauthorized, "无效的token")
-			c.Abort()
-			return
+	claims, err := ParseJWT(token)
+	if err != nil {
+		return "无效的token"
+	}
+
+	// 检查token是否过期
+	if claims.ExpiresAt < time.Now().Unix() {
+		return "token已过期"
+	}
+
+	// 检查token是否已被注销
+	if IsTokenRevoked(claims) {
+		return "token已失效，请重新登录"
+	}
+
+	// 校验登录会话是否仍然有效
+	session, err := GetSession(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return "登录会话已失效，请重新登录"
+	}
+
+	// 检查设备和网络是否与登录时一致
+	if reason := DetectSessionAnomaly(session, c); reason != "" {
+		action := AppConfig.SessionAnomalyAction
+		RecordSessionAnomaly(session, c, reason, action)
+		if action == SessionAnomalyChallenge {
+			DeleteSession(session.UserID, session.ID)
+			return "检测到异常访问环境，请重新登录验证"
 		}
+		// 仅记录时接受新设备，避免重复告警
+		updateSessionFields(session.ID, "fingerprint", deviceFingerprint(c))
+	}
+	TouchSession(session.ID, c.ClientIP())
+
+	// 将用户信息保存到上下文
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("email", claims.Email)
+	c.Set("roles", claims.Roles)
+	c.Set("session_id", claims.SessionID)
+	c.Set("token_id", claims.Id)
+	c.Set("token_expires_at", claims.ExpiresAt)
+	return ""
+}
 
-		// 检查token是否过期
-		if claims.ExpiresAt < time.Now().Unix() {
-			ErrorResponse(c, http.StatusUnauthorized, "token已过期")
+// JWT认证中间件
+func JWTAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if message := authenticateRequest(c); message != "" {
+			ErrorResponse(c, http.StatusUnauthorized, message)
 			c.Abort()
 			return
 		}
 
-		// 将用户信息保存到上下文
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
+		c.Next()
+	}
+}
 
+// 可选认证中间件：携带有效token时识别用户，否则按游客继续处理
+func OptionalUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticateRequest(c)
+		}
 		c.Next()
 	}
 }
@@ -152,6 +217,17 @@ func UserRegister(c *gin.Context) {
 		return
 	}
 
+	// 校验邀请码
+	var referrerID uint
+	if req.ReferralCode != "" {
+		id, err := findReferrer(req.ReferralCode)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		referrerID = id
+	}
+
 	// 创建新用户
 	user := User{
 		Username:     req.Username,
@@ -167,20 +243,25 @@ func UserRegister(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	token, err := GenerateJWT(&user)
+	// 分配默认角色
+	if err := AssignRole(user.ID, RoleCustomer); err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "用户角色分配失败")
+		return
+	}
+
+	// 记录邀请关系，首单支付后发放奖励
+	if referrerID != 0 {
+		DB.Create(&Referral{ReferrerID: referrerID, RefereeID: user.ID, Status: ReferralStatusPending})
+	}
+
+	// 创建登录会话并签发令牌
+	resp, err := LoginUser(&user, c)
 	if err != nil {
 		ErrorResponse(c, http.StatusInternalServerError, "token生成失败")
 		return
 	}
 
-	// 缓存用户会话到Redis
-	CacheUserSession(user.ID, token)
-
-	SuccessResponse(c, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	SuccessResponse(c, resp)
 }
 
 // 用户登录
@@ -191,18 +272,27 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
+	// 多次登录失败后需要验证码
+	if LoginCaptchaRequired(c, req.Username) && !VerifyCaptcha(c) {
+		ErrorResponse(c, http.StatusBadRequest, "登录失败次数过多，请输入验证码")
+		return
+	}
+
 	// 查找用户（支持用户名或邮箱登录）
 	var user User
 	if err := DB.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
+		RecordLoginFailure(c, req.Username)
 		ErrorResponse(c, http.StatusUnauthorized, "用户不存在或密码错误")
 		return
 	}
 
 	// 验证密码
 	if !VerifyPassword(req.Password, user.PasswordHash) {
+		RecordLoginFailure(c, req.Username)
 		ErrorResponse(c, http.StatusUnauthorized, "用户不存在或密码错误")
 		return
 	}
+	ClearLoginFailures(req.Username)
 
 	// 检查用户状态
 	if user.Status != 1 {
@@ -210,20 +300,14 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	token, err := GenerateJWT(&user)
+	// 创建登录会话并签发令牌
+	resp, err := LoginUser(&user, c)
 	if err != nil {
 		ErrorResponse(c, http.StatusInternalServerError, "token生成失败")
 		return
 	}
 
-	// 缓存用户会话到Redis
-	CacheUserSession(user.ID, token)
-
-	SuccessResponse(c, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	SuccessResponse(c, resp)
 }
 
 // 获取用户信息
@@ -319,25 +403,10 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	SuccessResponse(c, gin.H{"message": "密码修改成功"})
-}
-
-// 用户会话缓存管理
-func CacheUserSession(userID uint, token string) error {
-	key := fmt.Sprintf("session:user:%d", userID)
-	return RDB.Set(CTX, key, token, time.Hour*24*7).Err() // 7天过期
-}
-
-// 获取用户会话缓存
-func GetUserSession(userID uint) (string, error) {
-	key := fmt.Sprintf("session:user:%d", userID)
-	return RDB.Get(CTX, key).Result()
-}
+	// 修改密码后所有已登录设备需要重新登录
+	InvalidateUserSessions(user.ID)
 
-// 删除用户会话缓存
-func DeleteUserSession(userID uint) error {
-	key := fmt.Sprintf("session:user:%d", userID)
-	return RDB.Del(CTX, key).Err()
+	SuccessResponse(c, gin.H{"message": "密码修改成功，请重新登录"})
 }
 
 // 用户登出
@@ -348,14 +417,48 @@ func UserLogout(c *gin.Context) {
 		return
 	}
 
-	// 删除Redis中的会话缓存
+	// 拉黑当前访问令牌并删除登录会话，绑定的刷新令牌随之失效
 	if uid, ok := userID.(uint); ok {
-		DeleteUserSession(uid)
+		BlacklistToken(c.GetString("token_id"), c.GetInt64("token_expires_at"))
+		DeleteSession(uid, c.GetString("session_id"))
 	}
 
 	SuccessResponse(c, gin.H{"message": "退出登录成功"})
 }
 
+// 管理员启用/禁用用户账号
+func UpdateUserStatus(c *gin.Context) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	var req UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "参数验证失败: "+err.Error())
+		return
+	}
+
+	user, err := GetUserByID(uint(targetID))
+	if err != nil {
+		ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	if err := DB.Model(user).Update("status", *req.Status).Error; err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "用户状态更新失败")
+		return
+	}
+
+	// 禁用账号后立即终止其所有登录
+	if *req.Status != 1 {
+		InvalidateUserSessions(user.ID)
+	}
+
+	SuccessResponse(c, gin.H{"message": "用户状态更新成功"})
+}
+
 // 根据ID获取用户信息（内部使用）
 func GetUserByID(userID uint) (*User, error) {
 	var user User
@@ -370,29 +473,7 @@ func RequireUser() gin.HandlerFunc {
 	return JWTAuthMiddleware()
 }
 
-// 管理员权限中间件（如果需要）
+// 管理员权限中间件
 func RequireAdmin() gin.HandlerFunc {
-	return gin.HandlerFunc(func(c *gin.Context) {
-		JWTAuthMiddleware()(c)
-		if c.IsAborted() {
-			return
-		}
-
-		userID, _ := c.Get("user_id")
-		user, err := GetUserByID(userID.(uint))
-		if err != nil {
-			ErrorResponse(c, http.StatusUnauthorized, "用户不存在")
-			c.Abort()
-			return
-		}
-
-		// 这里可以检查用户角色，目前暂时使用用户ID=1作为管理员
-		if user.ID != 1 {
-			ErrorResponse(c, http.StatusForbidden, "需要管理员权限")
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	})
-}
\ No newline at end of file
+	return RequireRole(RoleAdmin)
+}
@@ -0,0 +1,406 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 钱包余额支付不经过支付渠道，支付单的渠道记为 wallet
+const PaymentChannelWallet = "wallet"
+
+// 支付单用途
+const (
+	PaymentPurposeOrder = "order" // 订单支付
+	PaymentPurposeTopup = "topup" // 钱包充值
+)
+
+// 钱包流水类型
+const (
+	WalletTypeTopup   = "topup"   // 充值
+	WalletTypePayment = "payment" // 订单支付
+	WalletTypeRefund  = "refund"  // 退款或取消订单退回
+	WalletTypeAdjust  = "adjust"  // 管理员调整
+)
+
+var errInsufficientBalance = errors.New("钱包余额不足")
+
+// TopupWalletRequest 钱包充值请求
+type TopupWalletRequest struct {
+	Amount  float64 `json:"amount" binding:"required,gt=0,max=50000"`
+	Channel string  `json:"channel" binding:"required"` // 支付渠道：mock、stripe，须已在 PAYMENT_CHANNELS 中启用
+}
+
+// AdjustWalletRequest 管理员调整钱包余额请求
+type AdjustWalletRequest struct {
+	Amount      float64 `json:"amount" binding:"required"` // 调整金额，增加为正数，扣减为负数
+	Description string  `json:"description" binding:"required,max=255"`
+}
+
+// 在事务中变更用户钱包余额并记录流水，扣减时余额不足返回 errInsufficientBalance
+func changeWallet(tx *gorm.DB, userID uint, amount float64, walletType string, orderID, paymentID uint, description string) error {
+	query := tx.Model(&User{}).Where("id = ?", userID)
+	if amount < 0 {
+		// 条件更新保证并发下余额不会被扣成负数
+		query = query.Where("balance >= ?", -amount)
+	}
+	result := query.UpdateColumn("balance", gorm.Expr("balance + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientBalance
+	}
+
+	var balance float64
+	if err := tx.Model(&User{}).Where("id = ?", userID).Pluck("balance", &balance).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&WalletLedger{
+		UserID:      userID,
+		Type:        walletType,
+		Amount:      amount,
+		Balance:     balance,
+		OrderID:     orderID,
+		PaymentID:   paymentID,
+		Description: description,
+	}).Error
+}
+
+// 订单已支付成功的金额（分），包括钱包余额支付和支付渠道支付
+func orderPaidCents(tx *gorm.DB, orderID uint) int64 {
+	var paid float64
+	tx.Model(&Payment{}).Select("COALESCE(SUM(amount), 0)").
+		Where("order_id = ? AND status = ?", orderID, PaymentStatusSucceeded).Scan(&paid)
+	return toCents(paid)
+}
+
+// 使用钱包余额支付订单的部分或全部待支付金额，生成一笔钱包支付单
+func payOrderFromWallet(order *Order, amountCents int64) (*Payment, error) {
+	var payment Payment
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定订单行，同一订单的支付依次处理
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(order, order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status != OrderStatusPending {
+			return errOrderStatusChanged
+		}
+		if amountCents > toCents(order.TotalAmount)-orderPaidCents(tx, order.ID) {
+			return errOrderStatusChanged
+		}
+
+		now := time.Now()
+		payment = Payment{
+			PaymentNo: generatePaymentNumber(),
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			Purpose:   PaymentPurposeOrder,
+			Channel:   PaymentChannelWallet,
+			Amount:    fromCents(amountCents),
+			Status:    PaymentStatusSucceeded,
+			PaidAt:    &now,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return changeWallet(tx, order.UserID, -payment.Amount, WalletTypePayment, order.ID, payment.ID,
+			fmt.Sprintf("订单 %s 支付", order.OrderNo))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// 未付清的订单取消时，在同一事务中将已用钱包余额支付的金额退回钱包
+func returnOrderWalletPayments(tx *gorm.DB, order *Order) error {
+	var payments []Payment
+	err := tx.Where("order_id = ? AND channel = ? AND status = ?", order.ID, PaymentChannelWallet, PaymentStatusSucceeded).
+		Find(&payments).Error
+	if err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		result := tx.Model(&Payment{}).Where("id = ? AND status = ?", payment.ID, PaymentStatusSucceeded).
+			Updates(map[string]interface{}{"status": PaymentStatusRefunded, "refunded_amount": gorm.Expr("amount")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := changeWallet(tx, order.UserID, payment.Amount, WalletTypeRefund, order.ID, payment.ID,
+			fmt.Sprintf("订单 %s 取消退回", order.OrderNo)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 充值支付成功后余额入账，支付单状态按条件更新，重复通知只入账一次
+func completeTopup(payment *Payment, tradeNo string) error {
+	credited := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Payment{}).Where("id = ? AND status <> ?", payment.ID, PaymentStatusSucceeded).
+			Updates(map[string]interface{}{
+				"status":      PaymentStatusSucceeded,
+				"trade_no":    tradeNo,
+				"paid_at":     time.Now(),
+				"fail_reason": "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		credited = true
+		return changeWallet(tx, payment.UserID, payment.Amount, WalletTypeTopup, 0, payment.ID,
+			fmt.Sprintf("%s 充值，交易号 %s", payment.Channel, tradeNo))
+	})
+	if err != nil {
+		return err
+	}
+	if credited {
+		SendNotification(payment.UserID, NotificationTypeSystem, "充值成功",
+			fmt.Sprintf("您已成功充值 %.2f 元到钱包余额。", payment.Amount))
+	}
+	return nil
+}
+
+// 分页查询用户的钱包流水
+func listWalletLedger(c *gin.Context, userID uint) {
+	page := 1
+	pageSize := 10
+
+	if pageParam := c.Query("page"); pageParam != "" {
+		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if sizeParam := c.Query("page_size"); sizeParam != "" {
+		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
+			pageSize = s
+		}
+	}
+
+	query := DB.Model(&WalletLedger{}).Where("user_id = ?", userID)
+	if walletType := c.Query("type"); walletType != "" {
+		query = query.Where("type = ?", walletType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []WalletLedger
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&entries).Error; err != nil {
+		InternalServerError(c, "钱包流水查询失败")
+		return
+	}
+
+	PaginationSuccessResponse(c, entries, total, page, pageSize)
+}
+
+// GetWallet 获取钱包余额
+// @Summary 获取钱包余额
+// @Description 获取当前用户的钱包余额，余额可用于支付订单
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=object{balance=number}} "查询成功"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Security Bearer
+// @Router /api/users/wallet [get]
+func GetWallet(c *gin.Context) {
+	var user User
+	if err := DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+	SuccessResponse(c, gin.H{"balance": user.Balance})
+}
+
+// GetWalletHistory 获取钱包流水
+// @Summary 获取钱包流水
+// @Description 分页获取当前用户的钱包充值、订单支付、退款退回和管理员调整记录
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Param type query string false "流水类型：topup、payment、refund、adjust"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]WalletLedger}} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/users/wallet/history [get]
+func GetWalletHistory(c *gin.Context) {
+	listWalletLedger(c, c.GetUint("user_id"))
+}
+
+// TopupWallet 钱包充值
+// @Summary 钱包充值
+// @Description 创建充值支付单并在支付渠道创建交易，返回客户端拉起支付所需的参数；支付渠道回调支付成功后余额入账
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Param topup body TopupWalletRequest true "充值金额和支付渠道"
+// @Success 200 {object} ApiResponse{data=PayOrderResponse} "充值支付单创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或不支持的支付渠道"
+// @Failure 500 {object} ApiResponse "支付渠道创建交易失败"
+// @Security Bearer
+// @Router /api/users/wallet/topup [post]
+func TopupWallet(c *gin.Context) {
+	var req TopupWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	gateway, ok := PaymentGateways[req.Channel]
+	if !ok {
+		BadRequestError(c, "不支持的支付渠道")
+		return
+	}
+
+	payment := Payment{
+		PaymentNo: generatePaymentNumber(),
+		UserID:    c.GetUint("user_id"),
+		Purpose:   PaymentPurposeTopup,
+		Channel:   req.Channel,
+		Amount:    fromCents(toCents(req.Amount)),
+		Status:    PaymentStatusPending,
+	}
+	if err := DB.Create(&payment).Error; err != nil {
+		InternalServerError(c, "充值支付单创建失败")
+		return
+	}
+
+	intent, err := gateway.CreatePayment(&payment, "GoMall钱包充值")
+	if err != nil {
+		DB.Model(&payment).Updates(map[string]interface{}{"status": PaymentStatusFailed, "fail_reason": paymentFailReason(err.Error())})
+		log.Printf("充值支付单 %s 创建交易失败: %v", payment.PaymentNo, err)
+		InternalServerError(c, "支付渠道暂时不可用，请稍后重试")
+		return
+	}
+	if intent.TradeNo != "" {
+		payment.TradeNo = intent.TradeNo
+		DB.Model(&payment).Update("trade_no", payment.TradeNo)
+	}
+
+	SuccessResponse(c, PayOrderResponse{Payment: payment, Intent: intent})
+}
+
+// GetTopupStatus 查询充值结果
+// @Summary 查询充值结果
+// @Description 客户端支付返回后轮询充值支付单的状态，支付单未完成时向支付渠道主动查询（同一支付单 PAYMENT_QUERY_SECONDS 秒内只查询一次）
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Param payment_no path string true "充值支付单号"
+// @Success 200 {object} ApiResponse{data=Payment} "查询成功"
+// @Failure 404 {object} ApiResponse "充值支付单不存在"
+// @Security Bearer
+// @Router /api/users/wallet/topup/{payment_no} [get]
+func GetTopupStatus(c *gin.Context) {
+	var payment Payment
+	err := DB.Where("payment_no = ? AND user_id = ? AND purpose = ?", c.Param("payment_no"), c.GetUint("user_id"), PaymentPurposeTopup).
+		First(&payment).Error
+	if err != nil {
+		NotFoundError(c, "充值支付单不存在")
+		return
+	}
+	if payment.Status == PaymentStatusPending {
+		syncPaymentWithGateway(&payment)
+		DB.First(&payment, payment.ID)
+	}
+	SuccessResponse(c, payment)
+}
+
+// GetUserWalletHistory 获取用户钱包流水（管理员）
+// @Summary 获取用户钱包流水
+// @Description 管理员分页查看指定用户的钱包流水，用户详情中包含当前余额
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param type query string false "流水类型：topup、payment、refund、adjust"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10) maximum(100)
+// @Success 200 {object} ApiResponse{data=PaginationResponse{list=[]WalletLedger}} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的用户ID"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/users/{id}/wallet/history [get]
+func GetUserWalletHistory(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+	listWalletLedger(c, uint(userID))
+}
+
+// AdjustUserWallet 调整用户钱包余额（管理员）
+// @Summary 调整用户钱包余额
+// @Description 管理员为用户增加或扣减钱包余额（如客服补偿、线下充值），扣减后余额不能为负数，操作记入管理员操作日志
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param adjust body AdjustWalletRequest true "调整金额和说明"
+// @Success 200 {object} ApiResponse{data=object{balance=number}} "调整成功"
+// @Failure 400 {object} ApiResponse "参数验证失败或余额不足"
+// @Failure 404 {object} ApiResponse "用户不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/users/{id}/wallet/adjust [post]
+func AdjustUserWallet(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的用户ID")
+		return
+	}
+	var req AdjustWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	amount := fromCents(toCents(req.Amount))
+	description := strings.TrimSpace(req.Description)
+	if amount == 0 || description == "" {
+		BadRequestError(c, "调整金额不能为0，说明不能为空")
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		NotFoundError(c, "用户不存在")
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		return changeWallet(tx, user.ID, amount, WalletTypeAdjust, 0, 0, description)
+	})
+	if errors.Is(err, errInsufficientBalance) {
+		BadRequestError(c, err.Error())
+		return
+	}
+	if err != nil {
+		InternalServerError(c, "钱包余额调整失败")
+		return
+	}
+
+	recordAdminAudit(c, "wallet.adjust", "user", user.ID, fmt.Sprintf("%+.2f 元：%s", amount, description))
+	DB.First(&user, user.ID)
+	SuccessResponse(c, gin.H{"balance": user.Balance})
+}
@@ -0,0 +1,428 @@
+package gomall
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateWarehouseRequest 创建仓库请求
+type CreateWarehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=32"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Regions  string `json:"regions" binding:"max=500"` // 就近发货的地区，逗号分隔
+	Address  string `json:"address" binding:"max=255"`
+	Priority int    `json:"priority"`
+}
+
+// UpdateWarehouseRequest 更新仓库请求
+type UpdateWarehouseRequest struct {
+	Name     string  `json:"name,omitempty" binding:"max=100"`
+	Regions  *string `json:"regions,omitempty" binding:"omitempty,max=500"`
+	Address  *string `json:"address,omitempty" binding:"omitempty,max=255"`
+	Priority *int    `json:"priority,omitempty"`
+	Status   *int    `json:"status,omitempty" binding:"omitempty,oneof=0 1"`
+}
+
+// TransferInventoryRequest 仓库间调拨请求
+type TransferInventoryRequest struct {
+	FromWarehouseID uint `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   uint `json:"to_warehouse_id" binding:"required"`
+	ProductID       uint `json:"product_id" binding:"required"`
+	Quantity        int  `json:"quantity" binding:"required,min=1"`
+}
+
+// WarehouseStock 商品在单个仓库的库存
+type WarehouseStock struct {
+	WarehouseID   uint   `json:"warehouse_id"`
+	WarehouseCode string `json:"warehouse_code"`
+	WarehouseName string `json:"warehouse_name"`
+	Quantity      int    `json:"quantity"`
+	Reserved      int    `json:"reserved"`  // 已分配到该仓库、尚未付款的订单占用
+	Available     int    `json:"available"` // 可分配给新订单的数量
+}
+
+// 各仓库中已分配给待付款订单的预占数量，按仓库和商品汇总
+func warehouseReservedStock(tx *gorm.DB, productIDs []uint) (map[uint]map[uint]int, error) {
+	var rows []struct {
+		WarehouseID uint
+		ProductID   uint
+		Quantity    int
+	}
+	err := tx.Model(&StockReservation{}).
+		Select("orders.warehouse_id, stock_reservations.product_id, SUM(stock_reservations.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = stock_reservations.order_id").
+		Where("stock_reservations.product_id IN ? AND stock_reservations.status = ? AND orders.warehouse_id IS NOT NULL",
+			productIDs, ReservationStatusReserved).
+		Group("orders.warehouse_id, stock_reservations.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uint]map[uint]int)
+	for _, row := range rows {
+		if reserved[row.WarehouseID] == nil {
+			reserved[row.WarehouseID] = make(map[uint]int)
+		}
+		reserved[row.WarehouseID][row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
+
+// 为订单分配发货仓库：只考虑能整单发货的启用仓库，收货地址在仓库发货地区内的优先，其次按优先级；
+// 订单商品都未分仓管理时返回 nil。调用前需通过 checkAvailableStock 锁定商品行
+func allocateWarehouse(tx *gorm.DB, address string, quantities map[uint]int) (*uint, error) {
+	productIDs := make([]uint, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+
+	var records []InventoryRecord
+	if err := tx.Where("product_id IN ?", productIDs).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// 预售商品可以超出仓库库存下单，不要求仓库有足够的库存
+	allowances, err := presaleAllowances(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	managed := make(map[uint]bool)
+	stock := make(map[uint]map[uint]int)
+	for _, record := range records {
+		if _, presale := allowances[record.ProductID]; !presale {
+			managed[record.ProductID] = true
+		}
+		if stock[record.WarehouseID] == nil {
+			stock[record.WarehouseID] = make(map[uint]int)
+		}
+		stock[record.WarehouseID][record.ProductID] = record.Quantity
+	}
+	reserved, err := warehouseReservedStock(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var warehouses []Warehouse
+	if err := tx.Where("status = 1").Order("priority DESC, id ASC").Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		return addressInRegions(address, splitEnvList(warehouses[i].Regions)) &&
+			!addressInRegions(address, splitEnvList(warehouses[j].Regions))
+	})
+
+	for _, warehouse := range warehouses {
+		fulfillable := true
+		for productID := range managed {
+			if stock[warehouse.ID][productID]-reserved[warehouse.ID][productID] < quantities[productID] {
+				fulfillable = false
+				break
+			}
+		}
+		if fulfillable {
+			warehouseID := warehouse.ID
+			return &warehouseID, nil
+		}
+	}
+	return nil, fmt.Errorf("没有可以整单发货的仓库，请减少购买数量或分开下单")
+}
+
+// 修改商品在订单发货仓库中的库存，返回实际修改的仓库；发货仓库没有该商品的记录时
+// （商品在订单分配仓库之后才开始分仓管理），改为修改该商品优先级最高的仓库，商品未分仓管理时不做处理
+func changeWarehouseStock(tx *gorm.DB, warehouseID *uint, productID uint, delta int) (*uint, error) {
+	if warehouseID != nil {
+		result := tx.Model(&InventoryRecord{}).
+			Where("warehouse_id = ? AND product_id = ?", *warehouseID, productID).
+			Update("quantity", gorm.Expr("quantity + ?", delta))
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return warehouseID, nil
+		}
+	}
+
+	var record InventoryRecord
+	err := tx.Joins("JOIN warehouses ON warehouses.id = inventory_records.warehouse_id").
+		Where("inventory_records.product_id = ?", productID).
+		Order("warehouses.status DESC, warehouses.priority DESC, warehouses.id ASC").
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&record).Update("quantity", gorm.Expr("quantity + ?", delta)).Error; err != nil {
+		return nil, err
+	}
+	return &record.WarehouseID, nil
+}
+
+// 商品是否已分仓管理，分仓管理的商品只能通过仓库调整库存
+func productHasWarehouseStock(db *gorm.DB, productID uint) bool {
+	var count int64
+	db.Model(&InventoryRecord{}).Where("product_id = ?", productID).Count(&count)
+	return count > 0
+}
+
+// 商品在各仓库的库存明细
+func productWarehouseStocks(productID uint) ([]WarehouseStock, error) {
+	var records []InventoryRecord
+	if err := DB.Where("product_id = ?", productID).Order("warehouse_id ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	reserved, err := warehouseReservedStock(DB, []uint{productID})
+	if err != nil {
+		return nil, err
+	}
+
+	warehouseIDs := make([]uint, len(records))
+	for i, record := range records {
+		warehouseIDs[i] = record.WarehouseID
+	}
+	var warehouses []Warehouse
+	DB.Where("id IN ?", warehouseIDs).Find(&warehouses)
+	warehouseByID := make(map[uint]Warehouse, len(warehouses))
+	for _, warehouse := range warehouses {
+		warehouseByID[warehouse.ID] = warehouse
+	}
+
+	stocks := make([]WarehouseStock, 0, len(records))
+	for _, record := range records {
+		warehouse := warehouseByID[record.WarehouseID]
+		held := reserved[record.WarehouseID][record.ProductID]
+		stocks = append(stocks, WarehouseStock{
+			WarehouseID:   record.WarehouseID,
+			WarehouseCode: warehouse.Code,
+			WarehouseName: warehouse.Name,
+			Quantity:      record.Quantity,
+			Reserved:      held,
+			Available:     record.Quantity - held,
+		})
+	}
+	return stocks, nil
+}
+
+// 查询仓库，不存在时返回404
+func findWarehouse(c *gin.Context, warehouseID uint) (*Warehouse, bool) {
+	var warehouse Warehouse
+	if err := DB.First(&warehouse, warehouseID).Error; err != nil {
+		NotFoundError(c, fmt.Sprintf("仓库 %d 不存在", warehouseID))
+		return nil, false
+	}
+	return &warehouse, true
+}
+
+// GetWarehouses 获取仓库列表
+// @Summary 获取仓库列表
+// @Description 按分配优先级列出全部仓库
+// @Tags 仓库管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} ApiResponse{data=[]Warehouse} "查询成功"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/warehouses [get]
+func GetWarehouses(c *gin.Context) {
+	var warehouses []Warehouse
+	if err := DB.Order("priority DESC, id ASC").Find(&warehouses).Error; err != nil {
+		InternalServerError(c, "仓库查询失败")
+		return
+	}
+	SuccessResponse(c, warehouses)
+}
+
+// CreateWarehouse 创建仓库
+// @Summary 创建仓库
+// @Description 新建发货仓库；regions 为就近发货的地区（按收货地址匹配），下单时优先分配给收货地址所在地区的仓库，其次按 priority 从高到低
+// @Tags 仓库管理
+// @Accept json
+// @Produce json
+// @Param warehouse body CreateWarehouseRequest true "仓库信息"
+// @Success 200 {object} ApiResponse{data=Warehouse} "创建成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 409 {object} ApiResponse "仓库编码已存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/warehouses [post]
+func CreateWarehouse(c *gin.Context) {
+	var req CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	var count int64
+	DB.Model(&Warehouse{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		ConflictError(c, "仓库编码已存在")
+		return
+	}
+
+	warehouse := Warehouse{
+		Code:     code,
+		Name:     req.Name,
+		Regions:  req.Regions,
+		Address:  req.Address,
+		Priority: req.Priority,
+		Status:   1,
+	}
+	if err := DB.Create(&warehouse).Error; err != nil {
+		InternalServerError(c, "仓库创建失败")
+		return
+	}
+
+	SuccessResponse(c, warehouse)
+}
+
+// UpdateWarehouse 更新仓库
+// @Summary 更新仓库
+// @Description 修改仓库信息；停用的仓库不再分配新订单，已分配的订单和库存不受影响
+// @Tags 仓库管理
+// @Accept json
+// @Produce json
+// @Param id path int true "仓库ID"
+// @Param warehouse body UpdateWarehouseRequest true "更新的仓库信息"
+// @Success 200 {object} ApiResponse{data=Warehouse} "更新成功"
+// @Failure 400 {object} ApiResponse "参数验证失败"
+// @Failure 404 {object} ApiResponse "仓库不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/warehouses/{id} [put]
+func UpdateWarehouse(c *gin.Context) {
+	warehouseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的仓库ID")
+		return
+	}
+
+	var req UpdateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	warehouse, ok := findWarehouse(c, uint(warehouseID))
+	if !ok {
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Regions != nil {
+		updates["regions"] = *req.Regions
+	}
+	if req.Address != nil {
+		updates["address"] = *req.Address
+	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if err := DB.Model(warehouse).Updates(updates).Error; err != nil {
+		InternalServerError(c, "仓库更新失败")
+		return
+	}
+
+	DB.First(warehouse, warehouse.ID)
+	SuccessResponse(c, warehouse)
+}
+
+// GetProductInventory 获取商品分仓库存
+// @Summary 获取商品分仓库存
+// @Description 列出商品在各仓库的库存、已被待付款订单占用的数量和可分配数量；商品未分仓管理时返回空列表
+// @Tags 仓库管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} ApiResponse{data=[]WarehouseStock} "查询成功"
+// @Failure 400 {object} ApiResponse "无效的商品ID"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/products/{id}/inventory [get]
+func GetProductInventory(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequestError(c, "无效的商品ID")
+		return
+	}
+
+	stocks, err := productWarehouseStocks(uint(productID))
+	if err != nil {
+		InternalServerError(c, "库存查询失败")
+		return
+	}
+	SuccessResponse(c, stocks)
+}
+
+// TransferInventory 仓库间调拨
+// @Summary 仓库间调拨
+// @Description 将商品库存从一个仓库调拨到另一个仓库，商品总库存不变；调出数量不能超过调出仓库中未被待付款订单占用的库存
+// @Tags 仓库管理
+// @Accept json
+// @Produce json
+// @Param transfer body TransferInventoryRequest true "调拨信息"
+// @Success 200 {object} ApiResponse{data=[]WarehouseStock} "调拨成功，返回商品分仓库存"
+// @Failure 400 {object} ApiResponse "参数验证失败或库存不足"
+// @Failure 404 {object} ApiResponse "仓库不存在"
+// @Failure 500 {object} ApiResponse "服务器内部错误"
+// @Security Bearer
+// @Router /api/admin/inventory/transfers [post]
+func TransferInventory(c *gin.Context) {
+	var req TransferInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+	if req.FromWarehouseID == req.ToWarehouseID {
+		BadRequestError(c, "调出和调入仓库不能相同")
+		return
+	}
+	if _, ok := findWarehouse(c, req.FromWarehouseID); !ok {
+		return
+	}
+	if _, ok := findWarehouse(c, req.ToWarehouseID); !ok {
+		return
+	}
+
+	movement := InventoryMovement{
+		Type:       MovementTypeTransfer,
+		OperatorID: operatorID(c),
+		Note:       fmt.Sprintf("仓库 %d 调拨至仓库 %d", req.FromWarehouseID, req.ToWarehouseID),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if !productHasWarehouseStock(tx, req.ProductID) {
+			return fmt.Errorf("商品尚未分仓管理，请先通过库存调整入库")
+		}
+		if _, _, err := adjustStock(tx, &req.FromWarehouseID, req.ProductID, -req.Quantity, movement); err != nil {
+			return err
+		}
+		_, _, err := adjustStock(tx, &req.ToWarehouseID, req.ProductID, req.Quantity, movement)
+		return err
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	DeleteCachedProduct(req.ProductID)
+
+	stocks, _ := productWarehouseStocks(req.ProductID)
+	SuccessResponse(c, stocks)
+}
@@ -0,0 +1,363 @@
+package gomall
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// 与外部仓储系统（WMS）双向同步库存的签名请求头，签名为 HMAC-SHA256(WMS_WEBHOOK_SECRET, 时间戳 + "." + 请求体) 的十六进制
+const (
+	wmsTimestampHeader = "X-Signature-Timestamp"
+	wmsSignatureHeader = "X-Signature"
+)
+
+const (
+	wmsSignatureMaxAge = 5 * time.Minute   // 回调时间戳与服务器时间的最大误差，防止重放
+	wmsPushBatchSize   = 100               // 每次推送的最大流水条数
+	wmsPushCursorKey   = "wms:push:cursor" // 已推送到WMS的最后一条库存流水ID
+	wmsPushLock        = "lock:wms:push"
+	wmsPushSettleDelay = 30 * time.Second // 流水写入后等待的时间，避免先分配ID、后提交的事务中的流水被跳过
+)
+
+// 推送库存变动使用的HTTP客户端
+var wmsHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// WMSStockEvent 推送给WMS的库存变动
+type WMSStockEvent struct {
+	ID            uint      `json:"id"` // 库存流水ID，WMS可据此去重
+	ProductID     uint      `json:"product_id"`
+	Barcode       string    `json:"barcode,omitempty"`
+	WarehouseCode string    `json:"warehouse_code,omitempty"`
+	Type          string    `json:"type"`
+	Quantity      int       `json:"quantity"`    // 变动数量，增加为正，减少为负
+	StockAfter    int       `json:"stock_after"` // 变动后的商品总库存
+	OrderID       *uint     `json:"order_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WMSStockUpdate WMS回传的单个商品实物库存
+type WMSStockUpdate struct {
+	WarehouseCode string `json:"warehouse_code"` // 商品未分仓管理时不填
+	ProductID     uint   `json:"product_id"`     // 商品ID和条形码二选一
+	Barcode       string `json:"barcode"`
+	Quantity      int    `json:"quantity" binding:"min=0"` // WMS中的实物数量
+}
+
+// WMSStockUpdateRequest WMS库存回调请求
+type WMSStockUpdateRequest struct {
+	Updates []WMSStockUpdate `json:"updates" binding:"required,min=1,max=200,dive"`
+}
+
+// WMSStockLine 回调处理结果
+type WMSStockLine struct {
+	ProductID     uint   `json:"product_id"`
+	WarehouseCode string `json:"warehouse_code,omitempty"`
+	Previous      int    `json:"previous"`   // 同步前的账面数量
+	Quantity      int    `json:"quantity"`   // WMS中的实物数量
+	Difference    int    `json:"difference"` // 账面数量的变化
+}
+
+// 计算请求签名
+func wmsSignature(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(AppConfig.WMSWebhookSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验WMS回调的签名和时间戳
+func verifyWMSSignature(timestamp, signature string, body []byte) bool {
+	if AppConfig.WMSWebhookSecret == "" {
+		return false
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > wmsSignatureMaxAge || age < -wmsSignatureMaxAge {
+		return false
+	}
+	expected := wmsSignature(timestamp, body)
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1
+}
+
+// 将库存变动签名后推送到WMS
+func postWMSStockEvents(events []WMSStockEvent) error {
+	body, err := json.Marshal(gin.H{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, AppConfig.WMSWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(wmsTimestampHeader, timestamp)
+	req.Header.Set(wmsSignatureHeader, wmsSignature(timestamp, body))
+
+	resp, err := wmsHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("库存推送失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("库存推送失败: WMS返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 将库存流水转换为推送事件，补充条形码和仓库编码
+func wmsStockEvents(movements []InventoryMovement) []WMSStockEvent {
+	productIDs := make([]uint, 0, len(movements))
+	warehouseIDs := make([]uint, 0)
+	for _, movement := range movements {
+		productIDs = append(productIDs, movement.ProductID)
+		if movement.WarehouseID != nil {
+			warehouseIDs = append(warehouseIDs, *movement.WarehouseID)
+		}
+	}
+
+	var products []Product
+	DB.Select("id, barcode").Where("id IN ?", productIDs).Find(&products)
+	barcodes := make(map[uint]string, len(products))
+	for _, product := range products {
+		if product.Barcode != nil {
+			barcodes[product.ID] = *product.Barcode
+		}
+	}
+	codes := make(map[uint]string)
+	if len(warehouseIDs) > 0 {
+		var warehouses []Warehouse
+		DB.Select("id, code").Where("id IN ?", warehouseIDs).Find(&warehouses)
+		for _, warehouse := range warehouses {
+			codes[warehouse.ID] = warehouse.Code
+		}
+	}
+
+	events := make([]WMSStockEvent, len(movements))
+	for i, movement := range movements {
+		events[i] = WMSStockEvent{
+			ID:         movement.ID,
+			ProductID:  movement.ProductID,
+			Barcode:    barcodes[movement.ProductID],
+			Type:       movement.Type,
+			Quantity:   movement.Quantity,
+			StockAfter: movement.StockAfter,
+			OrderID:    movement.OrderID,
+			CreatedAt:  movement.CreatedAt,
+		}
+		if movement.WarehouseID != nil {
+			events[i].WarehouseCode = codes[*movement.WarehouseID]
+		}
+	}
+	return events
+}
+
+// 按流水ID顺序推送上次推送之后的库存变动，推送失败时保留进度下次重试。
+// 由WMS回调产生的变动不再推送回WMS
+func pushStockMovements() error {
+	cursor, err := RDB.Get(CTX, wmsPushCursorKey).Uint64()
+	if errors.Is(err, redis.Nil) {
+		// 首次启用时从当前流水开始推送，历史库存由WMS初始化时全量导入
+		var lastID uint
+		DB.Model(&InventoryMovement{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID)
+		return RDB.SetNX(CTX, wmsPushCursorKey, lastID, 0).Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		var movements []InventoryMovement
+		err := DB.Where("id > ? AND created_at < ?", cursor, time.Now().Add(-wmsPushSettleDelay)).
+			Order("id").Limit(wmsPushBatchSize).Find(&movements).Error
+		if err != nil {
+			return err
+		}
+		if len(movements) == 0 {
+			return nil
+		}
+
+		pending := make([]InventoryMovement, 0, len(movements))
+		for _, movement := range movements {
+			if movement.Type != MovementTypeWMS {
+				pending = append(pending, movement)
+			}
+		}
+		if len(pending) > 0 {
+			if err := postWMSStockEvents(wmsStockEvents(pending)); err != nil {
+				return err
+			}
+		}
+		cursor = uint64(movements[len(movements)-1].ID)
+		if err := RDB.Set(CTX, wmsPushCursorKey, cursor, 0).Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// StartWMSStockPusher 启动库存变动推送任务，未配置WMS_WEBHOOK_URL时不启动
+func StartWMSStockPusher() {
+	if AppConfig.WMSWebhookURL == "" {
+		return
+	}
+	interval := time.Duration(AppConfig.WMSPushSeconds) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// 多实例部署时只由一个实例推送，保证流水按顺序送达
+			if ok, _ := RDB.SetNX(CTX, wmsPushLock, 1, 5*time.Minute).Result(); ok {
+				if err := pushStockMovements(); err != nil {
+					log.Printf("WMS库存推送失败: %v", err)
+				}
+				RDB.Del(CTX, wmsPushLock)
+			}
+		}
+	}()
+	log.Println("WMS库存推送任务已启动")
+}
+
+// 解析回调中的商品和仓库，返回商品ID和仓库ID
+func resolveWMSStockUpdate(tx *gorm.DB, update WMSStockUpdate) (uint, *uint, error) {
+	productID := update.ProductID
+	if productID == 0 {
+		if update.Barcode == "" {
+			return 0, nil, fmt.Errorf("商品ID和条形码不能同时为空")
+		}
+		var product Product
+		if err := tx.Select("id").Where("barcode = ?", update.Barcode).First(&product).Error; err != nil {
+			return 0, nil, fmt.Errorf("条形码 %s 对应的商品不存在", update.Barcode)
+		}
+		productID = product.ID
+	}
+	if update.WarehouseCode == "" {
+		return productID, nil, nil
+	}
+	var warehouse Warehouse
+	if err := tx.Select("id").Where("code = ?", update.WarehouseCode).First(&warehouse).Error; err != nil {
+		return 0, nil, fmt.Errorf("仓库 %s 不存在", update.WarehouseCode)
+	}
+	return productID, &warehouse.ID, nil
+}
+
+// WMSStockWebhook 接收WMS回传的库存
+// @Summary WMS库存回调
+// @Description 接收外部仓储系统回传的实物库存（按仓库编码和商品ID或条形码），按差异修正账面库存并写入 wms 类型的库存流水；请求需携带 X-Signature-Timestamp（Unix秒）和 X-Signature（HMAC-SHA256(WMS_WEBHOOK_SECRET, 时间戳.请求体) 的十六进制），时间戳误差超过5分钟拒绝。同一请求的商品在同一事务中处理，任一商品失败时全部不生效
+// @Tags 库存管理
+// @Accept json
+// @Produce json
+// @Param X-Signature-Timestamp header string true "Unix时间戳（秒）"
+// @Param X-Signature header string true "请求签名"
+// @Param updates body WMSStockUpdateRequest true "实物库存"
+// @Success 200 {object} ApiResponse{data=[]WMSStockLine} "同步成功，返回每个商品的差异"
+// @Failure 400 {object} ApiResponse "参数验证失败或库存不足以覆盖待付款订单"
+// @Failure 401 {object} ApiResponse "签名无效"
+// @Router /api/webhooks/wms [post]
+func WMSStockWebhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		BadRequestError(c, "请求体读取失败")
+		return
+	}
+	if !verifyWMSSignature(c.GetHeader(wmsTimestampHeader), c.GetHeader(wmsSignatureHeader), body) {
+		UnauthorizedError(c, "签名无效")
+		return
+	}
+
+	var req WMSStockUpdateRequest
+	if err := binding.JSON.BindBody(body, &req); err != nil {
+		BadRequestError(c, "参数验证失败: "+err.Error())
+		return
+	}
+
+	movement := InventoryMovement{Type: MovementTypeWMS, Note: "WMS库存回传"}
+	lines := make([]WMSStockLine, 0, len(req.Updates))
+	stockChanges := make(map[uint][2]int)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		type location struct {
+			productID   uint
+			warehouseID *uint
+		}
+		locations := make([]location, len(req.Updates))
+		productIDs := make([]uint, 0, len(req.Updates))
+		seen := make(map[string]bool, len(req.Updates))
+		for i, update := range req.Updates {
+			productID, warehouseID, err := resolveWMSStockUpdate(tx, update)
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprintf("%d:%s", productID, update.WarehouseCode)
+			if seen[key] {
+				return fmt.Errorf("商品 %d 重复", productID)
+			}
+			seen[key] = true
+			locations[i] = location{productID, warehouseID}
+			productIDs = append(productIDs, productID)
+		}
+
+		// 先按顺序锁定全部商品，同步期间账面数量不会被订单修改
+		if _, err := lockAvailableStock(tx, productIDs); err != nil {
+			return err
+		}
+		for i, update := range req.Updates {
+			productID, warehouseID := locations[i].productID, locations[i].warehouseID
+			previous, err := locationStock(tx, warehouseID, productID)
+			if err != nil {
+				return err
+			}
+			difference := update.Quantity - previous
+			lines = append(lines, WMSStockLine{
+				ProductID:     productID,
+				WarehouseCode: update.WarehouseCode,
+				Previous:      previous,
+				Quantity:      update.Quantity,
+				Difference:    difference,
+			})
+			if difference == 0 {
+				continue
+			}
+			oldStock, newStock, err := adjustStock(tx, warehouseID, productID, difference, movement)
+			if err != nil {
+				return err
+			}
+			if change, ok := stockChanges[productID]; ok {
+				oldStock = change[0]
+			}
+			stockChanges[productID] = [2]int{oldStock, newStock}
+		}
+		return nil
+	})
+	if err != nil {
+		BadRequestError(c, err.Error())
+		return
+	}
+	for productID, change := range stockChanges {
+		syncAdjustedStock(productID, change[0], change[1])
+	}
+
+	SuccessResponse(c, lines)
+}